@@ -0,0 +1,30 @@
+package admin
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFS holds the admin panel, compiled into the binary so it is available
+// without the static/ directory
+//
+//go:embed ui
+var uiFS embed.FS
+
+// Handler serves the admin panel. Mount it under /admin/ with the prefix
+// stripped; the panel itself calls the /api/admin endpoints.
+func Handler() http.Handler {
+	ui, err := fs.Sub(uiFS, "ui")
+	if err != nil {
+		panic(err)
+	}
+
+	files := http.FileServer(http.FS(ui))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Data comes from the API; only the shell is cached
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Frame-Options", "DENY")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/logging"
+	"oculo-pilot-server/middleware"
+	"oculo-pilot-server/websocket"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// maxLogLines bounds a single logs response
+const maxLogLines = 1000
+
+// maxCommands bounds a single command timeline response
+const maxCommands = 256
+
+// maxDeadLetters bounds a single dead-letter queue response
+const maxDeadLetters = 256
+
+// writeJSON writes an admin API response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
+
+// UsersResponse lists users
+type UsersResponse struct {
+	Users []*auth.User `json:"users"`
+}
+
+// UsersHandler lists users (admin only)
+type UsersHandler struct {
+	authService *auth.Service
+}
+
+// NewUsersHandler creates a new user list handler
+func NewUsersHandler(authService *auth.Service) *UsersHandler {
+	return &UsersHandler{authService: authService}
+}
+
+// ServeHTTP handles user list requests: ?status=pending lists the approval queue
+func (h *UsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" {
+		if err := auth.ValidateStatus(status); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	users, err := h.authService.ListUsers()
+	if err != nil {
+		log.Printf("❌ Failed to list users: %v", err)
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	filtered := make([]*auth.User, 0, len(users))
+	for _, user := range users {
+		if status == "" || user.Status == status {
+			filtered = append(filtered, user)
+		}
+	}
+
+	writeJSON(w, UsersResponse{Users: filtered})
+}
+
+// ConnectionsResponse lists connected WebSocket clients
+type ConnectionsResponse struct {
+	Stats       map[string]interface{}     `json:"stats"`
+	Connections []websocket.ConnectionInfo `json:"connections"`
+}
+
+// ConnectionsHandler lists connected WebSocket clients (admin only)
+type ConnectionsHandler struct {
+	hub *websocket.Hub
+}
+
+// NewConnectionsHandler creates a new connections handler
+func NewConnectionsHandler(hub *websocket.Hub) *ConnectionsHandler {
+	return &ConnectionsHandler{hub: hub}
+}
+
+// ServeHTTP handles connection list requests
+func (h *ConnectionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, ConnectionsResponse{
+		Stats:       h.hub.GetStats(),
+		Connections: h.hub.Connections(),
+	})
+}
+
+// DisconnectClientRequest is the optional body of a disconnect request
+type DisconnectClientRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DisconnectClientHandler closes a WebSocket connection by connection ID
+// (admin only)
+type DisconnectClientHandler struct {
+	hub *websocket.Hub
+}
+
+// NewDisconnectClientHandler creates a new client disconnect handler
+func NewDisconnectClientHandler(hub *websocket.Hub) *DisconnectClientHandler {
+	return &DisconnectClientHandler{hub: hub}
+}
+
+// ServeHTTP handles client disconnect requests
+func (h *DisconnectClientHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DisconnectClientRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	connectionID := mux.Vars(r)["connection_id"]
+	admin, _ := middleware.GetUsername(r)
+	if err := h.hub.DisconnectClient(connectionID, admin, req.Reason); err != nil {
+		if err == websocket.ErrConnectionNotFound {
+			http.Error(w, "Connection not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "disconnected", "connection_id": connectionID})
+}
+
+// BroadcastResponse reports how many clients received a system notice
+type BroadcastResponse struct {
+	Delivered int `json:"delivered"`
+}
+
+// BroadcastHandler sends system notices to connected clients (admin only)
+type BroadcastHandler struct {
+	hub *websocket.Hub
+}
+
+// NewBroadcastHandler creates a new system notice handler
+func NewBroadcastHandler(hub *websocket.Hub) *BroadcastHandler {
+	return &BroadcastHandler{hub: hub}
+}
+
+// ServeHTTP handles system notice requests
+func (h *BroadcastHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var notice websocket.SystemNotice
+	if err := json.NewDecoder(r.Body).Decode(&notice); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	admin, _ := middleware.GetUsername(r)
+	delivered, err := h.hub.BroadcastNotice(notice, admin)
+	if err != nil {
+		if errors.Is(err, websocket.ErrInvalidNotice) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("❌ System notice failed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, BroadcastResponse{Delivered: delivered})
+}
+
+// EmergencyStopHandler serves the emergency stop state (admin only)
+type EmergencyStopHandler struct {
+	hub *websocket.Hub
+}
+
+// NewEmergencyStopHandler creates a new emergency stop state handler
+func NewEmergencyStopHandler(hub *websocket.Hub) *EmergencyStopHandler {
+	return &EmergencyStopHandler{hub: hub}
+}
+
+// ServeHTTP handles emergency stop state requests
+func (h *EmergencyStopHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.hub.EmergencyStop())
+}
+
+// RoutesResponse lists message routes
+type RoutesResponse struct {
+	Routes []websocket.Route `json:"routes"`
+}
+
+// RoutesHandler serves the message routing table (admin only)
+type RoutesHandler struct {
+	hub *websocket.Hub
+}
+
+// NewRoutesHandler creates a new routing table handler
+func NewRoutesHandler(hub *websocket.Hub) *RoutesHandler {
+	return &RoutesHandler{hub: hub}
+}
+
+// ServeHTTP handles routing table requests
+func (h *RoutesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, RoutesResponse{Routes: h.hub.Routes()})
+}
+
+// WhitelistProvider reports the effective WebSocket IP whitelist
+type WhitelistProvider interface {
+	Whitelist() websocket.WhitelistStatus
+}
+
+// WhitelistHandler serves the effective IP whitelist (admin only)
+type WhitelistHandler struct {
+	provider WhitelistProvider
+}
+
+// NewWhitelistHandler creates a new whitelist handler
+func NewWhitelistHandler(provider WhitelistProvider) *WhitelistHandler {
+	return &WhitelistHandler{provider: provider}
+}
+
+// ServeHTTP handles whitelist requests
+func (h *WhitelistHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.provider.Whitelist())
+}
+
+// LogsResponse holds recent log lines
+type LogsResponse struct {
+	Lines []string `json:"lines"`
+}
+
+// LogsHandler serves the tail of the server log (admin only)
+type LogsHandler struct {
+	tail *logging.Tail
+}
+
+// NewLogsHandler creates a new logs handler
+func NewLogsHandler(tail *logging.Tail) *LogsHandler {
+	return &LogsHandler{tail: tail}
+}
+
+// ServeHTTP handles log tail requests: ?lines=<n>
+func (h *LogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lines := 200
+	if value := r.URL.Query().Get("lines"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid lines parameter", http.StatusBadRequest)
+			return
+		}
+		lines = n
+	}
+	if lines > maxLogLines {
+		lines = maxLogLines
+	}
+
+	writeJSON(w, LogsResponse{Lines: h.tail.Lines(lines)})
+}
+
+// CommandsResponse holds recent correlated control commands
+type CommandsResponse struct {
+	Commands []websocket.CommandRecord `json:"commands"`
+}
+
+// CommandsHandler serves the control command timeline (admin only)
+type CommandsHandler struct {
+	hub *websocket.Hub
+}
+
+// NewCommandsHandler creates a new command timeline handler
+func NewCommandsHandler(hub *websocket.Hub) *CommandsHandler {
+	return &CommandsHandler{hub: hub}
+}
+
+// ServeHTTP handles command timeline requests: ?user=<name>&limit=<n>
+func (h *CommandsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if limit > maxCommands {
+		limit = maxCommands
+	}
+
+	writeJSON(w, CommandsResponse{Commands: h.hub.CommandTimeline(r.URL.Query().Get("user"), limit)})
+}
+
+// DeadLettersResponse holds recent undeliverable messages
+type DeadLettersResponse struct {
+	DeadLetters []websocket.DeadLetter `json:"dead_letters"`
+}
+
+// DeadLettersHandler lists and clears the dead-letter queue (admin only)
+type DeadLettersHandler struct {
+	hub *websocket.Hub
+}
+
+// NewDeadLettersHandler creates a new dead-letter queue handler
+func NewDeadLettersHandler(hub *websocket.Hub) *DeadLettersHandler {
+	return &DeadLettersHandler{hub: hub}
+}
+
+// ServeHTTP handles GET (?type=<message type>&limit=<n>) and DELETE
+func (h *DeadLettersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		count := h.hub.ClearDeadLetters()
+		admin, _ := middleware.GetUsername(r)
+		log.Printf("📭 %d dead letters cleared by %s", count, admin)
+		writeJSON(w, map[string]int{"cleared": count})
+		return
+	}
+
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if limit > maxDeadLetters {
+		limit = maxDeadLetters
+	}
+
+	writeJSON(w, DeadLettersResponse{DeadLetters: h.hub.DeadLetters(r.URL.Query().Get("type"), limit)})
+}
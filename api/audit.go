@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"oculo-pilot-server/audit"
+	"strconv"
+	"time"
+)
+
+// maxAuditEvents bounds a single audit response
+const maxAuditEvents = 1000
+
+// AuditHandler serves recorded audit events (admin only)
+type AuditHandler struct {
+	log *audit.Log
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(log *audit.Log) *AuditHandler {
+	return &AuditHandler{log: log}
+}
+
+// ServeHTTP handles audit queries:
+// ?since=<duration|RFC3339>&action=<prefix>&actor=<username>&limit=<n>
+func (h *AuditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+	filter := audit.Filter{
+		Since:  now.Add(-24 * time.Hour),
+		Action: query.Get("action"),
+		Actor:  query.Get("actor"),
+		Limit:  100,
+	}
+	if value := query.Get("since"); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			filter.Since = now.Add(-duration)
+		} else if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+			filter.Since = timestamp
+		} else {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+	if filter.Limit > maxAuditEvents {
+		filter.Limit = maxAuditEvents
+	}
+
+	events, err := h.log.Query(filter)
+	if err != nil {
+		http.Error(w, "Failed to load audit events", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"since":   filter.Since,
+		"events":  events,
+		"dropped": h.log.Dropped(),
+	})
+}
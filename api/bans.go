@@ -0,0 +1,55 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"oculo-pilot-server/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// BansResponse lists temporarily banned IPs
+type BansResponse struct {
+	Bans []middleware.Ban `json:"bans"`
+}
+
+// BansHandler lists and clears IP bans for repeated authentication
+// failures (admin only). Bans added by an admin are in the blocklist, see
+// BlocklistHandler.
+type BansHandler struct {
+	bans *middleware.BanList
+}
+
+// NewBansHandler creates a new ban list handler
+func NewBansHandler(bans *middleware.BanList) *BansHandler {
+	return &BansHandler{bans: bans}
+}
+
+// ServeHTTP handles GET and DELETE on /api/admin/bans and DELETE on
+// /api/admin/bans/{ip}
+func (h *BansHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip, single := mux.Vars(r)["ip"]
+
+	switch {
+	case r.Method == http.MethodGet && !single:
+		writeJSON(w, BansResponse{Bans: h.bans.Bans()})
+
+	case r.Method == http.MethodDelete && single:
+		if !h.bans.Clear(ip) {
+			http.Error(w, "IP is not banned", http.StatusNotFound)
+			return
+		}
+		admin, _ := middleware.GetUsername(r)
+		log.Printf("🔓 Ban on %s lifted by %s", ip, admin)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodDelete:
+		count := h.bans.ClearAll()
+		admin, _ := middleware.GetUsername(r)
+		log.Printf("🔓 %d bans lifted by %s", count, admin)
+		writeJSON(w, map[string]int{"cleared": count})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
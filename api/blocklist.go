@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+	"oculo-pilot-server/websocket"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// BlocklistResponse lists the bans in effect
+type BlocklistResponse struct {
+	Bans []*auth.Ban `json:"bans"`
+}
+
+// BanRequest adds a ban. Duration (e.g. "24h") is an alternative to
+// expires_at; neither bans until removed.
+type BanRequest struct {
+	auth.BanSpec
+	Duration string `json:"duration"`
+}
+
+// BanResponse is a new ban and how many connections it closed
+type BanResponse struct {
+	*auth.Ban
+	Disconnected int `json:"disconnected"`
+}
+
+// BlocklistHandler manages the persisted blocklist of users and networks
+// (admin only). Adding a ban disconnects the connections it covers. The
+// automatic per-IP bans for failed authentication are separate, see
+// BansHandler.
+type BlocklistHandler struct {
+	authService *auth.Service
+	hub         *websocket.Hub
+}
+
+// NewBlocklistHandler creates a new blocklist handler
+func NewBlocklistHandler(authService *auth.Service, hub *websocket.Hub) *BlocklistHandler {
+	return &BlocklistHandler{authService: authService, hub: hub}
+}
+
+// ServeHTTP handles GET and POST on /api/admin/blocklist and DELETE on
+// /api/admin/blocklist/{id}
+func (h *BlocklistHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	idParam, single := mux.Vars(r)["id"]
+	admin, _ := middleware.GetUsername(r)
+
+	switch {
+	case r.Method == http.MethodGet && !single:
+		writeJSON(w, BlocklistResponse{Bans: h.authService.ListBans()})
+
+	case r.Method == http.MethodPost && !single:
+		var req BanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Duration != "" {
+			duration, err := time.ParseDuration(req.Duration)
+			if err != nil || duration <= 0 || req.ExpiresAt != nil {
+				http.Error(w, "Invalid duration", http.StatusBadRequest)
+				return
+			}
+			expiresAt := time.Now().Add(duration)
+			req.ExpiresAt = &expiresAt
+		}
+
+		ban, err := h.authService.AddBan(&req.BanSpec, admin)
+		if err != nil {
+			writeBanError(w, err)
+			return
+		}
+		disconnected := h.hub.DisconnectBanned(ban, admin, ban.Reason)
+		log.Printf("⛔ Ban %d on %s%s added by %s (%d disconnected)", ban.ID, ban.Username, ban.Network, admin, disconnected)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Location", "/api/admin/blocklist/"+strconv.FormatInt(ban.ID, 10))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(BanResponse{Ban: ban, Disconnected: disconnected})
+
+	case r.Method == http.MethodDelete && single:
+		id, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			http.Error(w, "Ban not found", http.StatusNotFound)
+			return
+		}
+		if err := h.authService.RemoveBan(id); err != nil {
+			writeBanError(w, err)
+			return
+		}
+		log.Printf("🔓 Ban %d removed by %s", id, admin)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeBanError maps ban management errors to HTTP statuses
+func writeBanError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrBanNotFound):
+		http.Error(w, "Ban not found", http.StatusNotFound)
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrInvalidBan), errors.Is(err, auth.ErrInvalidNetwork):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("❌ Ban management failed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"oculo-pilot-server/config"
+)
+
+// ConfigResponse is the effective configuration with secrets redacted
+type ConfigResponse struct {
+	Version  string           `json:"version"`
+	Settings []config.Setting `json:"settings"`
+}
+
+// ConfigHandler reports the effective configuration (admin only)
+type ConfigHandler struct {
+	cfg     *config.Config
+	version string
+}
+
+// NewConfigHandler creates a new config dump handler
+func NewConfigHandler(cfg *config.Config, version string) *ConfigHandler {
+	return &ConfigHandler{cfg: cfg, version: version}
+}
+
+// ServeHTTP handles config dump requests. ?source=env|file|default filters
+// the settings by where they came from.
+func (h *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	switch source {
+	case "", config.SourceEnv, config.SourceFile, config.SourceDefault:
+	default:
+		http.Error(w, "Invalid source", http.StatusBadRequest)
+		return
+	}
+
+	settings := make([]config.Setting, 0)
+	for _, setting := range h.cfg.Settings() {
+		if source == "" || setting.Source == source {
+			settings = append(settings, setting)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(ConfigResponse{Version: h.version, Settings: settings})
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// GroupsResponse lists groups
+type GroupsResponse struct {
+	Groups []*auth.Group `json:"groups"`
+}
+
+// GroupsHandler lists groups (admin only)
+type GroupsHandler struct {
+	authService *auth.Service
+}
+
+// NewGroupsHandler creates a new group list handler
+func NewGroupsHandler(authService *auth.Service) *GroupsHandler {
+	return &GroupsHandler{authService: authService}
+}
+
+// ServeHTTP handles group list requests
+func (h *GroupsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, GroupsResponse{Groups: h.authService.ListGroups()})
+}
+
+// GroupHandler manages a single group by name (admin only). PUT replaces
+// the group's members and robots.
+type GroupHandler struct {
+	authService *auth.Service
+}
+
+// NewGroupHandler creates a new group resource handler
+func NewGroupHandler(authService *auth.Service) *GroupHandler {
+	return &GroupHandler{authService: authService}
+}
+
+// ServeHTTP handles GET, PUT and DELETE on /api/admin/groups/{name}
+func (h *GroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	admin, _ := middleware.GetUsername(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		group, err := h.authService.GetGroup(name)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		writeJSON(w, group)
+
+	case http.MethodPut:
+		var spec auth.GroupSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		group, created, err := h.authService.PutGroup(name, &spec)
+		if err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		log.Printf("👥 Group %s saved by %s: members=%v robots=%v", group.Name, admin, group.Members, group.Robots)
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+			w.Header().Set("Location", "/api/admin/groups/"+group.Name)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(group)
+
+	case http.MethodDelete:
+		if err := h.authService.DeleteGroup(name); err != nil {
+			writeGroupError(w, err)
+			return
+		}
+		log.Printf("👥 Group %s deleted by %s", name, admin)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeGroupError maps group management errors to HTTP statuses
+func writeGroupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrGroupNotFound):
+		http.Error(w, "Group not found", http.StatusNotFound)
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrInvalidGroup), errors.Is(err, auth.ErrInvalidRobot):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("❌ Group management failed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"oculo-pilot-server/wireguard"
 	"time"
 )
 
@@ -11,11 +12,27 @@ type HealthResponse struct {
 	Status    string    `json:"status"`
 	Timestamp time.Time `json:"timestamp"`
 	Version   string    `json:"version"`
+
+	Tunnel *TunnelHealth `json:"tunnel,omitempty"`
+}
+
+// TunnelHealth summarizes the WireGuard tunnel for the unauthenticated
+// health check; peer keys, endpoints and counters are admin only (see
+// TunnelHandler)
+type TunnelHealth struct {
+	Up             bool `json:"up"`
+	ConnectedPeers int  `json:"connected_peers"`
+}
+
+// TunnelStatusProvider reports the status of the WireGuard tunnel
+type TunnelStatusProvider interface {
+	Status() wireguard.Status
 }
 
 // HealthHandler handles health check requests
 type HealthHandler struct {
 	version string
+	tunnel  TunnelStatusProvider
 }
 
 // NewHealthHandler creates a new health handler
@@ -23,6 +40,11 @@ func NewHealthHandler(version string) *HealthHandler {
 	return &HealthHandler{version: version}
 }
 
+// SetTunnel includes WireGuard tunnel status in health responses
+func (h *HealthHandler) SetTunnel(tunnel TunnelStatusProvider) {
+	h.tunnel = tunnel
+}
+
 // ServeHTTP handles health check requests
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	response := HealthResponse{
@@ -31,6 +53,30 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Version:   h.version,
 	}
 
+	if h.tunnel != nil {
+		status := h.tunnel.Status()
+		response.Tunnel = &TunnelHealth{Up: status.Up, ConnectedPeers: status.ConnectedPeers}
+		if !status.Up {
+			response.Status = "degraded"
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// TunnelHandler returns the full WireGuard tunnel status with per-peer
+// details (admin only)
+type TunnelHandler struct {
+	tunnel TunnelStatusProvider
+}
+
+// NewTunnelHandler creates a new tunnel status handler
+func NewTunnelHandler(tunnel TunnelStatusProvider) *TunnelHandler {
+	return &TunnelHandler{tunnel: tunnel}
+}
+
+// ServeHTTP handles tunnel status requests
+func (h *TunnelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.tunnel.Status())
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"oculo-pilot-server/incident"
+	"oculo-pilot-server/middleware"
+	"time"
+)
+
+// defaultIncidentWindow is how far back a bundle reaches without since
+const defaultIncidentWindow = 15 * time.Minute
+
+// IncidentRequest selects what an incident bundle covers
+type IncidentRequest struct {
+	Since    string `json:"since"` // Duration before until or RFC3339 (default 15m)
+	Until    string `json:"until"` // RFC3339 (default now)
+	Room     string `json:"room"`
+	RobotID  string `json:"robot_id"`
+	Username string `json:"username"`
+	Hold     bool   `json:"hold"`   // Place the recordings in the window under legal hold
+	Format   string `json:"format"` // "json" (default) or "zip"
+}
+
+// IncidentHandler bundles the session timeline, audit events, telemetry
+// and recording references of a time window for post-incident analysis
+// (admin only)
+type IncidentHandler struct {
+	builder *incident.Builder
+}
+
+// NewIncidentHandler creates a new incident bundle handler
+func NewIncidentHandler(builder *incident.Builder) *IncidentHandler {
+	return &IncidentHandler{builder: builder}
+}
+
+// ServeHTTP handles POST on /api/incidents
+func (h *IncidentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req IncidentRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Format != "" && req.Format != "json" && req.Format != "zip" {
+		http.Error(w, "format must be json or zip", http.StatusBadRequest)
+		return
+	}
+
+	request := incident.Request{
+		Until:    time.Now(),
+		Room:     req.Room,
+		RobotID:  req.RobotID,
+		Username: req.Username,
+		Hold:     req.Hold,
+	}
+	if req.Until != "" {
+		timestamp, err := time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			http.Error(w, "Invalid until", http.StatusBadRequest)
+			return
+		}
+		request.Until = timestamp
+	}
+	request.Since = request.Until.Add(-defaultIncidentWindow)
+	if req.Since != "" {
+		if duration, err := time.ParseDuration(req.Since); err == nil {
+			request.Since = request.Until.Add(-duration)
+		} else if timestamp, err := time.Parse(time.RFC3339, req.Since); err == nil {
+			request.Since = timestamp
+		} else {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+
+	admin, _ := middleware.GetUsername(r)
+	bundle, err := h.builder.Build(request, admin)
+	if errors.Is(err, incident.ErrInvalidWindow) {
+		http.Error(w, "The window must end after it starts and span at most "+incident.MaxWindow.String(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to build incident bundle: %v", err)
+		http.Error(w, "Failed to build incident bundle", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🧾 Incident bundle %s - %s built by %s (%d timeline entries, %d recordings)",
+		bundle.Since.Format(time.RFC3339), bundle.Until.Format(time.RFC3339), admin,
+		len(bundle.Timeline), len(bundle.Recordings))
+
+	name := "incident-" + bundle.GeneratedAt.Format("20060102T150405Z")
+	w.Header().Set("Cache-Control", "no-store")
+	if req.Format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+		err = bundle.WriteZip(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		err = bundle.WriteJSON(w)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to write incident bundle: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+)
+
+// IntrospectHandler answers RFC 7662 token introspection requests from
+// internal services authenticated with HTTP Basic client credentials
+type IntrospectHandler struct {
+	authService *auth.Service
+	clients     map[string]string // Client ID -> secret
+}
+
+// NewIntrospectHandler creates a new introspection handler
+func NewIntrospectHandler(authService *auth.Service, clients map[string]string) *IntrospectHandler {
+	return &IntrospectHandler{authService: authService, clients: clients}
+}
+
+// ServeHTTP handles POST /api/token/introspect with a form-encoded token
+func (h *IntrospectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authenticate(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+		http.Error(w, "Invalid client credentials", http.StatusUnauthorized)
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, h.authService.Introspect(token))
+}
+
+// authenticate checks the caller's client credentials
+func (h *IntrospectHandler) authenticate(r *http.Request) bool {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	expected, known := h.clients[id]
+	if !known || expected == "" {
+		log.Printf("⚠️  Introspection rejected for unknown client %q", id)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) != 1 {
+		log.Printf("⚠️  Introspection rejected for client %q: wrong secret", id)
+		return false
+	}
+	return true
+}
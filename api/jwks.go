@@ -0,0 +1,24 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"oculo-pilot-server/auth"
+)
+
+// JWKSHandler serves the public token verification keys as a JSON Web Key Set
+type JWKSHandler struct {
+	authService *auth.Service
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(authService *auth.Service) *JWKSHandler {
+	return &JWKSHandler{authService: authService}
+}
+
+// ServeHTTP handles JWKS requests
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.authService.JWKS())
+}
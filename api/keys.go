@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+)
+
+// SigningKeysResponse lists the JWT signing keys accepted for verification
+type SigningKeysResponse struct {
+	Keys []auth.SigningKeyInfo `json:"keys"`
+}
+
+// SigningKeysHandler lists JWT signing keys (admin only)
+type SigningKeysHandler struct {
+	authService *auth.Service
+}
+
+// NewSigningKeysHandler creates a new signing keys handler
+func NewSigningKeysHandler(authService *auth.Service) *SigningKeysHandler {
+	return &SigningKeysHandler{authService: authService}
+}
+
+// ServeHTTP handles signing key list requests
+func (h *SigningKeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SigningKeysResponse{Keys: h.authService.SigningKeys()})
+}
+
+// RotateKeyHandler rotates the JWT signing key (admin only)
+type RotateKeyHandler struct {
+	authService *auth.Service
+}
+
+// NewRotateKeyHandler creates a new key rotation handler
+func NewRotateKeyHandler(authService *auth.Service) *RotateKeyHandler {
+	return &RotateKeyHandler{authService: authService}
+}
+
+// ServeHTTP handles key rotation requests
+func (h *RotateKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, err := h.authService.RotateSigningKey()
+	if err != nil {
+		log.Printf("❌ Signing key rotation failed: %v", err)
+		http.Error(w, "Failed to rotate signing key", http.StatusInternalServerError)
+		return
+	}
+
+	username, _ := middleware.GetUsername(r)
+	log.Printf("🔑 JWT signing key rotated by %s (kid=%s, alg=%s)", username, key.ID, key.Method.Alg())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key.Info())
+}
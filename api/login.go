@@ -4,16 +4,70 @@ import (
 	"encoding/json"
 	"net/http"
 	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+	"oculo-pilot-server/websocket"
 )
 
+// LoginNotifier tells a user's live sessions about a new login (see
+// websocket.Hub.NotifyLogin)
+type LoginNotifier interface {
+	NotifyLogin(username, remoteAddr string, terminate bool) int
+}
+
+// AuthFailureRecorder counts failed logins per source IP (see
+// middleware.BanList)
+type AuthFailureRecorder interface {
+	RecordFailure(ip string) bool
+	RecordSuccess(ip string)
+}
+
+// SecurityEventPublisher delivers security events to admin dashboards (see
+// websocket.Hub.PublishSecurityEvent)
+type SecurityEventPublisher interface {
+	PublishSecurityEvent(kind string, detail map[string]interface{}) int
+}
+
+// loginRequest is a login, optionally asking for a session cookie instead
+// of a token in the response body
+type loginRequest struct {
+	auth.LoginRequest
+	Cookie bool `json:"cookie,omitempty"`
+}
+
+// cookieLoginResponse is returned when the token went into the session
+// cookie
+type cookieLoginResponse struct {
+	User                   *auth.User `json:"user"`
+	PasswordChangeRequired bool       `json:"password_change_required,omitempty"`
+	Session                string     `json:"session"`
+}
+
 // LoginHandler handles user login
 type LoginHandler struct {
 	authService *auth.Service
+	notifier    LoginNotifier
+	failures    AuthFailureRecorder
+	events      SecurityEventPublisher
+	cookie      *middleware.SessionCookie
+}
+
+// NewLoginHandler creates a new login handler; notifier and failures may
+// be nil
+func NewLoginHandler(authService *auth.Service, notifier LoginNotifier, failures AuthFailureRecorder) *LoginHandler {
+	return &LoginHandler{authService: authService, notifier: notifier, failures: failures}
 }
 
-// NewLoginHandler creates a new login handler
-func NewLoginHandler(authService *auth.Service) *LoginHandler {
-	return &LoginHandler{authService: authService}
+// SetSecurityEvents publishes a login_failed event for every rejected
+// login attempt with invalid credentials, and an ip_blocked event for
+// logins from outside the user's allowed networks
+func (h *LoginHandler) SetSecurityEvents(events SecurityEventPublisher) {
+	h.events = events
+}
+
+// SetSessionCookie enables cookie sessions: logins asking for one get the
+// token in an HttpOnly cookie instead of the response body
+func (h *LoginHandler) SetSessionCookie(cookie *middleware.SessionCookie) {
+	h.cookie = cookie
 }
 
 // ServeHTTP handles login requests
@@ -23,18 +77,89 @@ func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req auth.LoginRequest
+	var req loginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	req.RemoteAddr = middleware.ClientIP(r)
+	response, err := h.authService.Login(&req.LoginRequest)
+	if h.events != nil {
+		switch err {
+		case auth.ErrInvalidCredentials:
+			h.events.PublishSecurityEvent(websocket.SecurityLoginFailed, map[string]interface{}{
+				"username":    req.Username,
+				"remote_addr": middleware.ClientIP(r),
+			})
+		case auth.ErrSourceNotAllowed:
+			h.events.PublishSecurityEvent(websocket.SecurityIPBlocked, map[string]interface{}{
+				"username":    req.Username,
+				"remote_addr": middleware.ClientIP(r),
+				"reason":      "user_networks",
+			})
+		case auth.ErrBanned:
+			h.events.PublishSecurityEvent(websocket.SecurityBanned, map[string]interface{}{
+				"username":    req.Username,
+				"remote_addr": middleware.ClientIP(r),
+			})
+		}
+	}
+	if h.failures != nil {
+		if err == auth.ErrInvalidCredentials {
+			h.failures.RecordFailure(middleware.ClientIP(r))
+		} else if err == nil {
+			h.failures.RecordSuccess(middleware.ClientIP(r))
+		}
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		status := http.StatusUnauthorized
+		switch err {
+		case auth.ErrInvalidScope:
+			status = http.StatusBadRequest
+		case auth.ErrAccountPending, auth.ErrAccountDisabled, auth.ErrSourceNotAllowed, auth.ErrBanned:
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
+	// Apply the concurrent-login policy to the account's existing sessions
+	if policy := h.authService.LoginPolicy(); policy != auth.LoginPolicyAllow && h.notifier != nil {
+		h.notifier.NotifyLogin(response.User.Username, middleware.ClientIP(r), policy == auth.LoginPolicyTerminate)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	if req.Cookie && h.cookie != nil {
+		h.cookie.Set(w, response.Token)
+		json.NewEncoder(w).Encode(cookieLoginResponse{
+			User:                   response.User,
+			PasswordChangeRequired: response.PasswordChangeRequired,
+			Session:                "cookie",
+		})
+		return
+	}
 	json.NewEncoder(w).Encode(response)
 }
+
+// LogoutHandler ends a cookie session
+type LogoutHandler struct {
+	cookie *middleware.SessionCookie
+}
+
+// NewLogoutHandler creates a new logout handler
+func NewLogoutHandler(cookie *middleware.SessionCookie) *LogoutHandler {
+	return &LogoutHandler{cookie: cookie}
+}
+
+// ServeHTTP handles POST /api/logout by clearing the session cookie. The
+// token itself stays valid until it expires.
+func (h *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.cookie.Clear(w)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+	"strings"
+	"time"
+)
+
+// MailSender delivers plain-text email (see mailer.SMTP)
+type MailSender interface {
+	Send(to, subject, body string) error
+}
+
+// magicLinkRequest names the account by username or email address
+type magicLinkRequest struct {
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// MagicLinkHandler emails passwordless login links
+type MagicLinkHandler struct {
+	authService *auth.Service
+	sender      MailSender
+	baseURL     string
+}
+
+// NewMagicLinkHandler creates a new magic-link handler. baseURL is the
+// public address of this server, e.g. https://pilot.example.com.
+func NewMagicLinkHandler(authService *auth.Service, sender MailSender, baseURL string) *MagicLinkHandler {
+	return &MagicLinkHandler{authService: authService, sender: sender, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// ServeHTTP handles POST /api/login/magic. The response is the same whether
+// or not a link was sent, so it cannot be used to discover accounts.
+func (h *MagicLinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req magicLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	login := req.Email
+	if login == "" {
+		login = req.Username
+	}
+	if login == "" {
+		http.Error(w, "username or email is required", http.StatusBadRequest)
+		return
+	}
+
+	user, token, expiresAt, err := h.authService.IssueMagicLink(login)
+	switch err {
+	case nil:
+		link := h.baseURL + "/api/login/magic/verify?token=" + url.QueryEscape(token)
+		body := fmt.Sprintf("Use this link to log in to Oculo Pilot as %s:\n\n%s\n\n"+
+			"The link works once and expires at %s. If you did not ask for it, you can ignore this email.\n",
+			user.Username, link, expiresAt.UTC().Format(time.RFC1123))
+
+		// Send in the background so response time does not reveal whether
+		// the account exists
+		go func() {
+			if err := h.sender.Send(user.Email, "Oculo Pilot login link", body); err != nil {
+				log.Printf("❌ Failed to send login link to %s: %v", user.Username, err)
+				return
+			}
+			log.Printf("✉️  Login link sent to %s", user.Username)
+		}()
+	case auth.ErrUserNotFound, auth.ErrMagicLinkThrottled:
+	default:
+		log.Printf("❌ Magic link failed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "If the account has an email address, a login link has been sent"})
+}
+
+// MagicLinkVerifyHandler logs in the user a magic link was issued to
+type MagicLinkVerifyHandler struct {
+	authService *auth.Service
+	cookie      *middleware.SessionCookie
+}
+
+// NewMagicLinkVerifyHandler creates a new verification handler
+func NewMagicLinkVerifyHandler(authService *auth.Service) *MagicLinkVerifyHandler {
+	return &MagicLinkVerifyHandler{authService: authService}
+}
+
+// SetSessionCookie makes a visited link start a cookie session and
+// redirect to the dashboard instead of returning the token
+func (h *MagicLinkVerifyHandler) SetSessionCookie(cookie *middleware.SessionCookie) {
+	h.cookie = cookie
+}
+
+// ServeHTTP handles GET /api/login/magic/verify?token=...
+func (h *MagicLinkVerifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response, err := h.authService.RedeemMagicLink(r.URL.Query().Get("token"), middleware.ClientIP(r))
+	if err != nil {
+		status := http.StatusUnauthorized
+		switch err {
+		case auth.ErrAccountPending, auth.ErrAccountDisabled, auth.ErrSourceNotAllowed, auth.ErrBanned, auth.ErrInvalidScope:
+			status = http.StatusForbidden
+		case auth.ErrMagicLinksDisabled:
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Printf("✅ Magic link login: %s from %s", response.User.Username, middleware.ClientIP(r))
+
+	if h.cookie != nil {
+		h.cookie.Set(w, response.Token)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	writeJSON(w, response)
+}
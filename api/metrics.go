@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"oculo-pilot-server/metrics"
+	"strconv"
+	"time"
+)
+
+// maxHistorySamples bounds a single history response
+const maxHistorySamples = 10000
+
+// MetricsHandler serves the current hub stats
+type MetricsHandler struct {
+	source metrics.StatsSource
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler(source metrics.StatsSource) *MetricsHandler {
+	return &MetricsHandler{source: source}
+}
+
+// ServeHTTP returns the hub stats, including throughput counters
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"timestamp": time.Now(),
+		"stats":     h.source.GetStats(),
+	})
+}
+
+// MetricsHistoryHandler serves persisted hub stats samples
+type MetricsHistoryHandler struct {
+	history *metrics.History
+}
+
+// NewMetricsHistoryHandler creates a new metrics history handler
+func NewMetricsHistoryHandler(history *metrics.History) *MetricsHistoryHandler {
+	return &MetricsHistoryHandler{history: history}
+}
+
+// ServeHTTP handles history queries: ?since=<duration|RFC3339>&limit=<n>
+func (h *MetricsHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	since := now.Add(-24 * time.Hour)
+	if value := r.URL.Query().Get("since"); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			since = now.Add(-duration)
+		} else if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+			since = timestamp
+		} else {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Never look further back than the retention window
+	if oldest := now.Add(-h.history.Retention()); since.Before(oldest) {
+		since = oldest
+	}
+
+	limit := maxHistorySamples
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	samples, err := h.history.Since(since, limit)
+	if err != nil {
+		http.Error(w, "Failed to load metrics history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"since":   since,
+		"samples": samples,
+	})
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// PairingHandler issues and cancels device pairing codes (admin only)
+type PairingHandler struct {
+	authService *auth.Service
+}
+
+// NewPairingHandler creates a new pairing code handler
+func NewPairingHandler(authService *auth.Service) *PairingHandler {
+	return &PairingHandler{authService: authService}
+}
+
+// ServeHTTP handles POST /api/admin/pairing and DELETE
+// /api/admin/pairing/{name}
+func (h *PairingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	admin, _ := middleware.GetUsername(r)
+
+	switch r.Method {
+	case http.MethodPost:
+		var spec auth.ServiceAccountSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		pairing, err := h.authService.CreatePairingCode(&spec)
+		if err != nil {
+			writeServiceAccountError(w, err)
+			return
+		}
+		log.Printf("📟 Pairing code for %s issued by %s (expires %s)",
+			pairing.Name, admin, pairing.ExpiresAt.Format("15:04:05"))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pairing)
+
+	case http.MethodDelete:
+		name := mux.Vars(r)["name"]
+		if !h.authService.CancelPairing(name) {
+			http.Error(w, "No pending pairing code", http.StatusNotFound)
+			return
+		}
+		log.Printf("📟 Pairing code for %s cancelled by %s", name, admin)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// pairRequest is a device submitting its pairing code
+type pairRequest struct {
+	Code string `json:"code"`
+}
+
+// PairHandler exchanges a pairing code for the device's service account
+// credentials
+type PairHandler struct {
+	authService *auth.Service
+	failures    AuthFailureRecorder
+}
+
+// NewPairHandler creates a new pairing handler; failures may be nil
+func NewPairHandler(authService *auth.Service, failures AuthFailureRecorder) *PairHandler {
+	return &PairHandler{authService: authService, failures: failures}
+}
+
+// ServeHTTP handles POST /api/pair
+func (h *PairHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req pairRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	credentials, err := h.authService.RedeemPairingCode(req.Code)
+	if err == auth.ErrInvalidPairingCode {
+		if h.failures != nil {
+			h.failures.RecordFailure(middleware.ClientIP(r))
+		}
+		log.Printf("⚠️  Invalid pairing code from %s", middleware.ClientIP(r))
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		writeServiceAccountError(w, err)
+		return
+	}
+	log.Printf("📟 Device paired as %s from %s", credentials.Account.Username, middleware.ClientIP(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Location", "/api/admin/users/"+credentials.Account.Username)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(credentials)
+}
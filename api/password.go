@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+)
+
+// ChangePasswordHandler handles password changes for the authenticated user
+type ChangePasswordHandler struct {
+	authService *auth.Service
+}
+
+// NewChangePasswordHandler creates a new change password handler
+func NewChangePasswordHandler(authService *auth.Service) *ChangePasswordHandler {
+	return &ChangePasswordHandler{authService: authService}
+}
+
+// ServeHTTP handles password change requests
+func (h *ChangePasswordHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req auth.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.ChangePassword(userID, &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == auth.ErrInvalidCredentials || err == auth.ErrUserNotFound {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
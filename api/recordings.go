@@ -0,0 +1,309 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"oculo-pilot-server/middleware"
+	"oculo-pilot-server/recording"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxRecordingSessions bounds a single session list response
+	maxRecordingSessions = 500
+
+	// maxRecordedMessages bounds a single recorded message response
+	maxRecordedMessages = 1000
+)
+
+// RecordingsResponse lists recording sessions
+type RecordingsResponse struct {
+	Active   *recording.Session   `json:"active"`
+	Sessions []*recording.Session `json:"sessions"`
+	Dropped  int64                `json:"dropped"`
+}
+
+// StartRecordingRequest starts a recording session
+type StartRecordingRequest struct {
+	Name string `json:"name"`
+	Room string `json:"room"` // Empty records every room
+}
+
+// RecordingsHandler lists recording sessions and starts one (admin only)
+type RecordingsHandler struct {
+	recorder *recording.Recorder
+}
+
+// NewRecordingsHandler creates a new recording session list handler
+func NewRecordingsHandler(recorder *recording.Recorder) *RecordingsHandler {
+	return &RecordingsHandler{recorder: recorder}
+}
+
+// ServeHTTP handles GET (?limit=<n>) and POST on /api/admin/recordings
+func (h *RecordingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		limit, ok := queryLimit(w, r, 50, maxRecordingSessions)
+		if !ok {
+			return
+		}
+		sessions, err := h.recorder.Sessions(limit)
+		if err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+		writeJSON(w, RecordingsResponse{Active: h.recorder.Active(), Sessions: sessions, Dropped: h.recorder.Dropped()})
+
+	case http.MethodPost:
+		var req StartRecordingRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		admin, _ := middleware.GetUsername(r)
+		session, err := h.recorder.StartSession(req.Name, req.Room, admin)
+		if err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Location", "/api/admin/recordings/"+strconv.FormatInt(session.ID, 10))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(session)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// StopRecordingHandler stops the active recording session (admin only)
+type StopRecordingHandler struct {
+	recorder *recording.Recorder
+}
+
+// NewStopRecordingHandler creates a new stop recording handler
+func NewStopRecordingHandler(recorder *recording.Recorder) *StopRecordingHandler {
+	return &StopRecordingHandler{recorder: recorder}
+}
+
+// ServeHTTP handles POST on /api/admin/recordings/stop
+func (h *StopRecordingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, err := h.recorder.StopSession()
+	if err != nil {
+		writeRecordingError(w, err)
+		return
+	}
+	writeJSON(w, session)
+}
+
+// UpdateRecordingRequest changes how long a recording session is kept;
+// omitted fields are left unchanged
+type UpdateRecordingRequest struct {
+	LegalHold *bool   `json:"legal_hold"`
+	Retention *string `json:"retention"` // e.g. "2160h"; empty restores the default
+}
+
+// RecordingHandler returns, updates or deletes one recording session
+// (admin only). GET pages through its messages with
+// ?after=<message id>&limit=<n>.
+type RecordingHandler struct {
+	recorder *recording.Recorder
+}
+
+// NewRecordingHandler creates a new recording session resource handler
+func NewRecordingHandler(recorder *recording.Recorder) *RecordingHandler {
+	return &RecordingHandler{recorder: recorder}
+}
+
+// ServeHTTP handles GET, PATCH and DELETE on /api/admin/recordings/{id}
+func (h *RecordingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Recording session not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var after int64
+		if value := r.URL.Query().Get("after"); value != "" {
+			if after, err = strconv.ParseInt(value, 10, 64); err != nil || after < 0 {
+				http.Error(w, "Invalid after parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		limit, ok := queryLimit(w, r, maxRecordedMessages, maxRecordedMessages)
+		if !ok {
+			return
+		}
+
+		session, err := h.recorder.Session(id)
+		if err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+		messages, err := h.recorder.Messages(id, after, limit)
+		if err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"session":  session,
+			"messages": messages,
+		})
+
+	case http.MethodPatch:
+		var req UpdateRecordingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		var retention time.Duration
+		if req.Retention != nil && *req.Retention != "" {
+			if retention, err = time.ParseDuration(*req.Retention); err != nil || retention <= 0 {
+				http.Error(w, "Invalid retention", http.StatusBadRequest)
+				return
+			}
+		}
+		if _, err := h.recorder.Session(id); err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+
+		admin, _ := middleware.GetUsername(r)
+		if req.Retention != nil {
+			if err := h.recorder.SetRetention(id, retention); err != nil {
+				writeRecordingError(w, err)
+				return
+			}
+			log.Printf("🗄️  Recording session %d retention set to %q by %s", id, *req.Retention, admin)
+		}
+		if req.LegalHold != nil {
+			if err := h.recorder.SetLegalHold(id, *req.LegalHold); err != nil {
+				writeRecordingError(w, err)
+				return
+			}
+			log.Printf("🔒 Recording session %d legal hold %v by %s", id, *req.LegalHold, admin)
+		}
+
+		session, err := h.recorder.Session(id)
+		if err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+		writeJSON(w, session)
+
+	case http.MethodDelete:
+		if err := h.recorder.DeleteSession(id); err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+		admin, _ := middleware.GetUsername(r)
+		log.Printf("🗑️  Recording session %d deleted by %s", id, admin)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// queryLimit parses ?limit=<n>, capped at max (fallback when absent)
+func queryLimit(w http.ResponseWriter, r *http.Request, fallback, max int) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+		return 0, false
+	}
+	if n > max {
+		n = max
+	}
+	return n, true
+}
+
+// writeRecordingError maps recording and playback errors to HTTP statuses
+func writeRecordingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, recording.ErrSessionNotFound):
+		http.Error(w, "Recording session not found", http.StatusNotFound)
+	case errors.Is(err, recording.ErrInvalidSpeed), errors.Is(err, recording.ErrInvalidRetention):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, recording.ErrRecording), errors.Is(err, recording.ErrNotRecording),
+		errors.Is(err, recording.ErrPlaying), errors.Is(err, recording.ErrNotPlaying),
+		errors.Is(err, recording.ErrLegalHold):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("❌ Recording request failed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// StartPlaybackRequest plays a recorded session back to the replay room
+type StartPlaybackRequest struct {
+	SessionID int64   `json:"session_id"`
+	Speed     float64 `json:"speed"` // 0 plays at the original pace
+}
+
+// PlaybackHandler starts, reports and stops playback of recorded sessions
+// to web clients in the replay room (admin only)
+type PlaybackHandler struct {
+	player *recording.Player
+}
+
+// NewPlaybackHandler creates a new playback handler
+func NewPlaybackHandler(player *recording.Player) *PlaybackHandler {
+	return &PlaybackHandler{player: player}
+}
+
+// ServeHTTP handles GET, POST and DELETE on /api/admin/playback
+func (h *PlaybackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{"playback": h.player.Status()})
+
+	case http.MethodPost:
+		var req StartPlaybackRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Speed == 0 {
+			req.Speed = 1
+		}
+		admin, _ := middleware.GetUsername(r)
+		playback, err := h.player.Start(req.SessionID, req.Speed, admin)
+		if err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(playback)
+
+	case http.MethodDelete:
+		if err := h.player.Stop(); err != nil {
+			writeRecordingError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -2,18 +2,29 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
 )
 
+// registerRequest is a registration with the answer to the optional
+// registration challenge
+type registerRequest struct {
+	auth.CreateUserRequest
+	auth.ChallengeResponse
+}
+
 // RegisterHandler handles user registration
 type RegisterHandler struct {
 	authService *auth.Service
+	challenge   auth.RegistrationChallenge // nil when no challenge is required
 }
 
 // NewRegisterHandler creates a new register handler
-func NewRegisterHandler(authService *auth.Service) *RegisterHandler {
-	return &RegisterHandler{authService: authService}
+func NewRegisterHandler(authService *auth.Service, challenge auth.RegistrationChallenge) *RegisterHandler {
+	return &RegisterHandler{authService: authService, challenge: challenge}
 }
 
 // ServeHTTP handles registration requests
@@ -23,21 +34,74 @@ func (h *RegisterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req auth.CreateUserRequest
+	var req registerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	user, err := h.authService.Register(&req)
+	if h.challenge != nil {
+		if err := h.challenge.Verify(&req.ChallengeResponse, middleware.ClientIP(r)); err != nil {
+			if !errors.Is(err, auth.ErrChallengeFailed) {
+				log.Printf("❌ Registration challenge unavailable: %v", err)
+				http.Error(w, "Registration challenge unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	user, err := h.authService.Register(&req.CreateUserRequest)
+	if err == auth.ErrRegistrationDisabled {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Pending accounts are accepted but cannot log in until approved
+	status := http.StatusCreated
+	if user.Status == auth.StatusPending {
+		status = http.StatusAccepted
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"user": user,
 	})
 }
+
+// RegisterChallengeHandler issues the registration challenge a client must
+// solve before POST /api/register
+type RegisterChallengeHandler struct {
+	challenge auth.RegistrationChallenge // nil when no challenge is required
+}
+
+// NewRegisterChallengeHandler creates a new registration challenge handler
+func NewRegisterChallengeHandler(challenge auth.RegistrationChallenge) *RegisterChallengeHandler {
+	return &RegisterChallengeHandler{challenge: challenge}
+}
+
+// ServeHTTP handles GET /api/register/challenge
+func (h *RegisterChallengeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.challenge == nil {
+		writeJSON(w, map[string]interface{}{"type": "none"})
+		return
+	}
+
+	challenge, err := h.challenge.Issue()
+	if err != nil {
+		http.Error(w, "Failed to issue challenge", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, challenge)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"oculo-pilot-server/websocket"
+
+	"github.com/gorilla/mux"
+)
+
+// RoomStatusHandler serves the state of one room: connected clients,
+// traffic, emergency stop and control lock
+type RoomStatusHandler struct {
+	hub *websocket.Hub
+}
+
+// NewRoomStatusHandler creates a new room status handler
+func NewRoomStatusHandler(hub *websocket.Hub) *RoomStatusHandler {
+	return &RoomStatusHandler{hub: hub}
+}
+
+// ServeHTTP handles GET /api/rooms/{id}/status
+func (h *RoomStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, ok := h.hub.RoomStatus(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, status)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ServiceAccountsResponse lists service accounts
+type ServiceAccountsResponse struct {
+	Accounts []*auth.User `json:"accounts"`
+}
+
+// ServiceAccountsHandler lists and creates service accounts (admin only)
+type ServiceAccountsHandler struct {
+	authService *auth.Service
+}
+
+// NewServiceAccountsHandler creates a new service account handler
+func NewServiceAccountsHandler(authService *auth.Service) *ServiceAccountsHandler {
+	return &ServiceAccountsHandler{authService: authService}
+}
+
+// ServeHTTP handles GET and POST on /api/admin/service-accounts
+func (h *ServiceAccountsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		accounts, err := h.authService.ListServiceAccounts()
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		writeJSON(w, ServiceAccountsResponse{Accounts: accounts})
+
+	case http.MethodPost:
+		var spec auth.ServiceAccountSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		credentials, err := h.authService.CreateServiceAccount(&spec)
+		if err != nil {
+			writeServiceAccountError(w, err)
+			return
+		}
+
+		admin, _ := middleware.GetUsername(r)
+		log.Printf("🤖 Service account %s created by %s (role=%s)",
+			credentials.Account.Username, admin, credentials.Account.Role)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Location", "/api/admin/users/"+credentials.Account.Username)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(credentials)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RotateServiceAccountHandler issues a new service account secret (admin only)
+type RotateServiceAccountHandler struct {
+	authService *auth.Service
+}
+
+// NewRotateServiceAccountHandler creates a new secret rotation handler
+func NewRotateServiceAccountHandler(authService *auth.Service) *RotateServiceAccountHandler {
+	return &RotateServiceAccountHandler{authService: authService}
+}
+
+// ServeHTTP handles POST /api/admin/service-accounts/{name}/rotate
+func (h *RotateServiceAccountHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	credentials, err := h.authService.RotateServiceAccount(mux.Vars(r)["name"])
+	if err != nil {
+		writeServiceAccountError(w, err)
+		return
+	}
+
+	admin, _ := middleware.GetUsername(r)
+	log.Printf("🔑 Service account %s secret rotated by %s", credentials.Account.Username, admin)
+
+	writeJSON(w, credentials)
+}
+
+// writeServiceAccountError maps service account errors to HTTP statuses
+func writeServiceAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUsernameTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, auth.ErrNotServiceAccount):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeUserError(w, err)
+	}
+}
+
+// ServiceTokenHandler exchanges service account credentials, sent with HTTP
+// Basic authentication, for a token
+type ServiceTokenHandler struct {
+	authService *auth.Service
+}
+
+// NewServiceTokenHandler creates a new service token handler
+func NewServiceTokenHandler(authService *auth.Service) *ServiceTokenHandler {
+	return &ServiceTokenHandler{authService: authService}
+}
+
+// ServeHTTP handles POST /api/token with an optional form-encoded,
+// space-separated scope and an optional client_type/device_id binding
+func (h *ServiceTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, secret, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="service-account"`)
+		http.Error(w, "Missing service account credentials", http.StatusUnauthorized)
+		return
+	}
+
+	binding := auth.TokenBinding{
+		ClientType: r.PostFormValue("client_type"),
+		DeviceID:   r.PostFormValue("device_id"),
+	}
+	response, err := h.authService.BoundServiceToken(name, secret, strings.Fields(r.PostFormValue("scope")), binding)
+	if err != nil {
+		status := http.StatusUnauthorized
+		switch err {
+		case auth.ErrInvalidScope, auth.ErrInvalidBinding:
+			status = http.StatusBadRequest
+		case auth.ErrAccountDisabled, auth.ErrBanned:
+			status = http.StatusForbidden
+		}
+		if status != http.StatusBadRequest {
+			log.Printf("⚠️  Service token rejected for %q: %v", name, err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, response)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+)
+
+// SetupHandler creates the initial admin with the first-run setup token
+type SetupHandler struct {
+	authService *auth.Service
+}
+
+// NewSetupHandler creates a new setup handler
+func NewSetupHandler(authService *auth.Service) *SetupHandler {
+	return &SetupHandler{authService: authService}
+}
+
+// ServeHTTP reports whether setup is available (GET) or completes it (POST)
+func (h *SetupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		available, err := h.authService.SetupAvailable()
+		if err != nil {
+			http.Error(w, "Failed to check setup state", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]bool{"setup_required": available})
+	case http.MethodPost:
+		h.setup(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setup creates the initial admin and logs it in
+func (h *SetupHandler) setup(w http.ResponseWriter, r *http.Request) {
+	var req auth.SetupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.Setup(&req)
+	switch {
+	case err == auth.ErrSetupUnavailable:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err == auth.ErrInvalidSetupToken:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("🔑 Initial admin %s created via first-run setup", response.User.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"oculo-pilot-server/telemetry"
+	"strconv"
+	"time"
+)
+
+// maxTelemetrySamples bounds a single telemetry response
+const maxTelemetrySamples = 5000
+
+// TelemetryHandler serves persisted telemetry (admin only)
+type TelemetryHandler struct {
+	store *telemetry.Store
+}
+
+// NewTelemetryHandler creates a new telemetry handler
+func NewTelemetryHandler(store *telemetry.Store) *TelemetryHandler {
+	return &TelemetryHandler{store: store}
+}
+
+// ServeHTTP handles telemetry queries:
+// ?robot_id=<id>&type=<message type>&since=<duration|RFC3339>&until=<RFC3339>&limit=<n>
+func (h *TelemetryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+	filter := telemetry.Filter{
+		RobotID: query.Get("robot_id"),
+		Type:    query.Get("type"),
+		Since:   now.Add(-time.Hour),
+		Limit:   1000,
+	}
+	if value := query.Get("since"); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			filter.Since = now.Add(-duration)
+		} else if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+			filter.Since = timestamp
+		} else {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("until"); value != "" {
+		timestamp, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid until parameter", http.StatusBadRequest)
+			return
+		}
+		filter.Until = timestamp
+	}
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+	if filter.Limit > maxTelemetrySamples {
+		filter.Limit = maxTelemetrySamples
+	}
+
+	samples, err := h.store.Query(filter)
+	if err != nil {
+		http.Error(w, "Failed to load telemetry", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"since":   filter.Since,
+		"samples": samples,
+		"dropped": h.store.Dropped(),
+	})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CertificateInfo describes the server certificate so machine clients can
+// pin it when a public CA is not practical
+type CertificateInfo struct {
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	DNSNames   []string  `json:"dns_names,omitempty"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	SHA256     string    `json:"sha256"`      // Certificate fingerprint, colon-separated hex
+	SPKISHA256 string    `json:"spki_sha256"` // Base64 public key pin; survives renewal with the same key
+}
+
+// DescribeCertificate computes the fingerprints of a certificate
+func DescribeCertificate(cert *x509.Certificate) *CertificateInfo {
+	sum := sha256.Sum256(cert.Raw)
+	hexSum := strings.ToUpper(hex.EncodeToString(sum[:]))
+	pairs := make([]string, 0, len(sum))
+	for i := 0; i < len(hexSum); i += 2 {
+		pairs = append(pairs, hexSum[i:i+2])
+	}
+
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return &CertificateInfo{
+		Subject:    cert.Subject.String(),
+		Issuer:     cert.Issuer.String(),
+		DNSNames:   cert.DNSNames,
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		SHA256:     strings.Join(pairs, ":"),
+		SPKISHA256: base64.StdEncoding.EncodeToString(spki[:]),
+	}
+}
+
+// LoadCertificateInfo describes the leaf certificate of a PEM key pair
+func LoadCertificateInfo(certFile, keyFile string) (*CertificateInfo, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	return DescribeCertificate(leaf), nil
+}
+
+// TLSHandler serves the fingerprint of the certificate presented by the
+// TLS device listener (admin only)
+type TLSHandler struct {
+	cert *CertificateInfo // nil when no TLS listener is configured
+}
+
+// NewTLSHandler creates a new TLS certificate handler
+func NewTLSHandler(cert *CertificateInfo) *TLSHandler {
+	return &TLSHandler{cert: cert}
+}
+
+// ServeHTTP handles GET /api/admin/tls
+func (h *TLSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.cert == nil {
+		http.Error(w, "No TLS listener configured", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, h.cert)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"oculo-pilot-server/middleware"
+	"strconv"
+	"time"
+)
+
+// TURNCredentialsHandler issues time-limited TURN credentials with the
+// TURN REST scheme coturn implements (use-auth-secret): the username is
+// "<expiry unix time>:<user>" and the password its HMAC-SHA1 under the
+// secret shared with the TURN server. Browsers get credentials that stop
+// working on their own instead of the long-lived TURN secret.
+type TURNCredentialsHandler struct {
+	secret []byte
+	uris   []string
+	ttl    time.Duration
+}
+
+// turnCredentials is the response of GET /api/turn-credentials
+type turnCredentials struct {
+	Username  string   `json:"username"`
+	Password  string   `json:"password"`
+	TTL       int64    `json:"ttl"`        // Seconds
+	ExpiresAt int64    `json:"expires_at"` // Unix time
+	URIs      []string `json:"uris"`
+}
+
+// NewTURNCredentialsHandler creates a new TURN credentials handler
+func NewTURNCredentialsHandler(secret string, uris []string, ttl time.Duration) *TURNCredentialsHandler {
+	return &TURNCredentialsHandler{secret: []byte(secret), uris: uris, ttl: ttl}
+}
+
+// ServeHTTP handles GET /api/turn-credentials. It must be wrapped by
+// middleware.Auth.
+func (h *TURNCredentialsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _ := middleware.GetUsername(r)
+	expiresAt := time.Now().Add(h.ttl).Unix()
+	username := strconv.FormatInt(expiresAt, 10)
+	if user != "" {
+		username += ":" + user
+	}
+
+	mac := hmac.New(sha1.New, h.secret)
+	mac.Write([]byte(username))
+	password := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	writeJSON(w, turnCredentials{
+		Username:  username,
+		Password:  password,
+		TTL:       int64(h.ttl / time.Second),
+		ExpiresAt: expiresAt,
+		URIs:      h.uris,
+	})
+}
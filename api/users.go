@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// UserHandler manages a single user by name with idempotent PUT/DELETE and
+// ETag concurrency control (admin only), for declarative tooling
+type UserHandler struct {
+	authService *auth.Service
+}
+
+// NewUserHandler creates a new user resource handler
+func NewUserHandler(authService *auth.Service) *UserHandler {
+	return &UserHandler{authService: authService}
+}
+
+// ServeHTTP handles GET, PUT and DELETE on /api/admin/users/{username}
+func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	pre := auth.Precondition{
+		IfMatch:     r.Header.Get("If-Match"),
+		IfNoneMatch: r.Header.Get("If-None-Match"),
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, username, pre)
+	case http.MethodPut:
+		h.put(w, r, username, pre)
+	case http.MethodDelete:
+		h.delete(w, r, username, pre)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// get returns the user with its ETag
+func (h *UserHandler) get(w http.ResponseWriter, username string, pre auth.Precondition) {
+	user, err := h.authService.GetUser(username)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", user.ETag())
+	if pre.IfNoneMatch != "" && pre.IfNoneMatch == user.ETag() {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, user)
+}
+
+// put creates or updates the user to match the request body
+func (h *UserHandler) put(w http.ResponseWriter, r *http.Request, username string, pre auth.Precondition) {
+	var spec auth.UserSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Refuse to lock the caller out of the admin API
+	if current, _ := middleware.GetUsername(r); current == username && spec.Role != auth.RoleAdmin {
+		http.Error(w, "Cannot remove your own admin role", http.StatusConflict)
+		return
+	}
+
+	user, created, err := h.authService.PutUser(username, &spec, pre)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		w.Header().Set("Location", "/api/admin/users/"+user.Username)
+		admin, _ := middleware.GetUsername(r)
+		log.Printf("👤 User %s created by %s (role=%s)", user.Username, admin, user.Role)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("ETag", user.ETag())
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(user)
+}
+
+// delete removes the user
+func (h *UserHandler) delete(w http.ResponseWriter, r *http.Request, username string, pre auth.Precondition) {
+	admin, _ := middleware.GetUsername(r)
+	if admin == username {
+		http.Error(w, "Cannot delete your own account", http.StatusConflict)
+		return
+	}
+
+	if err := h.authService.DeleteUserByName(username, pre); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	log.Printf("👤 User %s deleted by %s", username, admin)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeUserError maps user management errors to HTTP statuses
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, auth.ErrPreconditionFailed):
+		http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+	case errors.Is(err, auth.ErrServiceAccount), errors.Is(err, auth.ErrAccountPending),
+		errors.Is(err, auth.ErrEmailTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, auth.ErrInvalidUsername), errors.Is(err, auth.ErrInvalidPassword),
+		errors.Is(err, auth.ErrInvalidRole), errors.Is(err, auth.ErrInvalidScope),
+		errors.Is(err, auth.ErrInvalidNetwork), errors.Is(err, auth.ErrInvalidEmail):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("❌ User management failed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// ApproveUserHandler activates a pending registration (admin only)
+type ApproveUserHandler struct {
+	authService *auth.Service
+}
+
+// NewApproveUserHandler creates a new approval handler
+func NewApproveUserHandler(authService *auth.Service) *ApproveUserHandler {
+	return &ApproveUserHandler{authService: authService}
+}
+
+// ServeHTTP handles POST /api/admin/users/{username}/approve
+func (h *ApproveUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, err := h.authService.ApproveUser(mux.Vars(r)["username"])
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	admin, _ := middleware.GetUsername(r)
+	log.Printf("👤 User %s approved by %s", user.Username, admin)
+
+	w.Header().Set("ETag", user.ETag())
+	writeJSON(w, user)
+}
+
+// UserActivationHandler deactivates or reactivates an account (admin only)
+type UserActivationHandler struct {
+	authService *auth.Service
+	active      bool
+}
+
+// NewUserActivationHandler creates a handler that reactivates accounts when
+// active is true and deactivates them otherwise
+func NewUserActivationHandler(authService *auth.Service, active bool) *UserActivationHandler {
+	return &UserActivationHandler{authService: authService, active: active}
+}
+
+// ServeHTTP handles POST /api/admin/users/{username}/deactivate and /reactivate
+func (h *UserActivationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := mux.Vars(r)["username"]
+	admin, _ := middleware.GetUsername(r)
+	if !h.active && admin == username {
+		http.Error(w, "Cannot deactivate your own account", http.StatusConflict)
+		return
+	}
+
+	update, action := h.authService.DeactivateUser, "deactivated"
+	if h.active {
+		update, action = h.authService.ReactivateUser, "reactivated"
+	}
+
+	user, err := update(username)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	log.Printf("👤 User %s %s by %s", user.Username, action, admin)
+
+	w.Header().Set("ETag", user.ETag())
+	writeJSON(w, user)
+}
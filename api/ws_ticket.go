@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"oculo-pilot-server/auth"
+	"oculo-pilot-server/middleware"
+)
+
+// WSTicketHandler exchanges the caller's token for a one-time WebSocket
+// ticket, so browsers can connect with /ws?ticket=... instead of putting a
+// long-lived JWT in the URL
+type WSTicketHandler struct {
+	authService *auth.Service
+}
+
+// NewWSTicketHandler creates a new ticket handler
+func NewWSTicketHandler(authService *auth.Service) *WSTicketHandler {
+	return &WSTicketHandler{authService: authService}
+}
+
+// ServeHTTP handles POST /api/ws-ticket. It must be wrapped by
+// middleware.Auth.
+func (h *WSTicketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ticket, err := h.authService.IssueTicket(middleware.RequestToken(r))
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, ticket)
+}
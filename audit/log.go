@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"oculo-pilot-server/store"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queueSize bounds events waiting to be written; further events are
+// dropped so recording never blocks the caller
+const queueSize = 1024
+
+// Event is one recorded action
+type Event struct {
+	ID     int64                  `json:"id"`
+	Time   time.Time              `json:"time"`
+	Actor  string                 `json:"actor"`
+	Action string                 `json:"action"`
+	Target string                 `json:"target,omitempty"`
+	Detail map[string]interface{} `json:"detail,omitempty"`
+}
+
+// Filter selects events in Query
+type Filter struct {
+	Since  time.Time
+	Until  time.Time // Zero for no upper bound
+	Action string    // Prefix, e.g. "ws." for every WebSocket event
+	Actor  string
+	Limit  int
+}
+
+// Log persists audit events asynchronously and answers queries
+type Log struct {
+	db        *store.DB
+	retention time.Duration
+
+	events  chan Event
+	dropped atomic.Int64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewLog creates an audit log keeping events for retention
+func NewLog(db *store.DB, retention time.Duration) *Log {
+	return &Log{
+		db:        db,
+		retention: retention,
+		events:    make(chan Event, queueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Record queues an event without blocking
+func (l *Log) Record(actor, action, target string, detail map[string]interface{}) {
+	event := Event{Time: time.Now(), Actor: actor, Action: action, Target: target, Detail: detail}
+
+	select {
+	case l.events <- event:
+	default:
+		if l.dropped.Add(1) == 1 {
+			log.Printf("⚠️  Audit queue full, dropping events")
+		}
+	}
+}
+
+// Dropped returns how many events were lost because the queue was full
+func (l *Log) Dropped() int64 {
+	return l.dropped.Load()
+}
+
+// Run writes queued events and prunes old ones hourly until Stop is called
+func (l *Log) Run() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-l.events:
+			l.write(event)
+
+		case now := <-ticker.C:
+			if removed, err := l.Prune(now); err != nil {
+				log.Printf("❌ Failed to prune audit log: %v", err)
+			} else if removed > 0 {
+				log.Printf("🧹 Pruned %d audit events older than %v", removed, l.retention)
+			}
+
+		case <-l.stop:
+			// Write what is already queued so shutdown events are kept
+			for {
+				select {
+				case event := <-l.events:
+					l.write(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stop writes the remaining queued events and ends the loop
+func (l *Log) Stop() {
+	l.once.Do(func() { close(l.stop) })
+	<-l.done
+}
+
+// write stores one event
+func (l *Log) write(event Event) {
+	if err := l.Write(event); err != nil {
+		log.Printf("❌ Failed to write audit event %s: %v", event.Action, err)
+	}
+}
+
+// Write stores an event synchronously
+func (l *Log) Write(event Event) error {
+	detail := "{}"
+	if len(event.Detail) > 0 {
+		data, err := json.Marshal(event.Detail)
+		if err != nil {
+			return err
+		}
+		detail = string(data)
+	}
+
+	_, err := l.db.Exec(
+		"INSERT INTO audit_events (occurred_at, actor, action, target, detail) VALUES (?, ?, ?, ?, ?)",
+		event.Time.UTC(), event.Actor, event.Action, event.Target, detail,
+	)
+	return err
+}
+
+// Query returns matching events, newest first
+func (l *Log) Query(filter Filter) ([]Event, error) {
+	query := "SELECT id, occurred_at, actor, action, target, detail FROM audit_events WHERE occurred_at >= ?"
+	args := []interface{}{filter.Since.UTC()}
+	if !filter.Until.IsZero() {
+		query += " AND occurred_at < ?"
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.Action != "" {
+		query += " AND action LIKE ? ESCAPE '\\'"
+		args = append(args, escapeLike(filter.Action)+"%")
+	}
+	if filter.Actor != "" {
+		query += " AND actor = ?"
+		args = append(args, filter.Actor)
+	}
+	query += " ORDER BY occurred_at DESC, id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := l.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var (
+			event  Event
+			detail string
+		)
+		if err := rows.Scan(&event.ID, &event.Time, &event.Actor, &event.Action, &event.Target, &detail); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(detail), &event.Detail); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// Prune deletes events older than the retention window
+func (l *Log) Prune(now time.Time) (int64, error) {
+	result, err := l.db.Exec(
+		"DELETE FROM audit_events WHERE occurred_at < ?",
+		now.Add(-l.retention).UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Retention returns how long events are kept
+func (l *Log) Retention() time.Duration {
+	return l.retention
+}
+
+// escapeLike makes LIKE wildcards in a prefix match literally
+func escapeLike(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"oculo-pilot-server/store"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLogRecordAndQuery tests asynchronous recording, filtering and pruning
+func TestLogRecordAndQuery(t *testing.T) {
+	db, err := store.Open(store.DriverSQLite, filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Migrate(Schema); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	auditLog := NewLog(db, 48*time.Hour)
+	go auditLog.Run()
+
+	auditLog.Record("alice", "ws.connect", "conn-1", map[string]interface{}{"remote_addr": "10.0.0.5"})
+	auditLog.Record("alice", "ws.disconnect", "conn-1", map[string]interface{}{"reason": "client_closed"})
+	auditLog.Record("bob", "user.deactivate", "carol", nil)
+	auditLog.Stop()
+
+	now := time.Now()
+	if err := auditLog.Write(Event{Time: now.Add(-72 * time.Hour), Actor: "old", Action: "ws.connect"}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	events, err := auditLog.Query(Filter{Since: now.Add(-time.Hour), Action: "ws.", Limit: 10})
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 WebSocket events, got %d", len(events))
+	}
+	if events[0].Action != "ws.disconnect" || events[0].Detail["reason"] != "client_closed" {
+		t.Errorf("Expected newest event first with its detail, got %+v", events[0])
+	}
+
+	events, err = auditLog.Query(Filter{Since: now.Add(-time.Hour), Actor: "bob", Limit: 10})
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Target != "carol" {
+		t.Errorf("Expected bob's single event, got %+v", events)
+	}
+
+	// Wildcards in the prefix match literally
+	events, err = auditLog.Query(Filter{Since: now.Add(-time.Hour), Action: "ws_", Limit: 10})
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events for prefix ws_, got %d", len(events))
+	}
+
+	removed, err := auditLog.Prune(now)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 pruned event, got %d", removed)
+	}
+}
@@ -0,0 +1,19 @@
+package audit
+
+import (
+	"embed"
+	"oculo-pilot-server/store"
+)
+
+// migrationFS holds the versioned schema migrations for every dialect
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+// Schema is the migrations for the audit_events table
+var Schema = store.Schema{
+	Component: "audit",
+	FS:        migrationFS,
+	// Version in the schema_migrations table all subsystems shared
+	Legacy: map[int]int{10: 1},
+}
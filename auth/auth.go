@@ -2,6 +2,7 @@ package auth
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,38 +10,138 @@ import (
 
 // Service handles authentication logic
 type Service struct {
-	db        *DB
+	store     UserStore
 	jwtSecret []byte
 	jwtExpiry time.Duration
+
+	// issuer and audience are set on new tokens and, when configured,
+	// required on every validated token
+	issuer   string
+	audience string
+
+	// keys holds rotated signing keys, oldest first; the newest active key
+	// signs new tokens instead of jwtSecret
+	keys     []*SigningKey
+	keyStore KeyStore
+	keysMu   sync.RWMutex
+
+	// legacyTokens keeps accepting tokens signed with jwtSecret and no
+	// "kid" after keys took over (see AcceptLegacyTokens)
+	legacyTokens bool
+
+	// provisionMu serializes declarative user writes (see PutUser) and
+	// guards setupToken
+	provisionMu sync.Mutex
+
+	// setupToken authorizes creating the initial admin (see Setup)
+	setupToken string
+
+	// Self-registration policy (see ConfigureRegistration)
+	registrationDisabled bool
+	registrationApproval bool
+
+	// Concurrent-login policy and, under terminate, the per-user cutoff
+	// before which tokens are rejected (see replaceSessions)
+	loginPolicy       string
+	sessionsNotBefore map[int64]time.Time
+	sessionsMu        sync.RWMutex
+
+	// Groups granting robot control (see UseGroupStore)
+	groups groupAccess
+
+	// Banned users and networks (see UseBanStore)
+	bans banList
+
+	// Previous passwords that may not be reused (see UsePasswordHistory)
+	passwordHistory passwordHistory
+
+	// Cached account status lookups for token validation (see checkActive)
+	activeCache map[int64]activeEntry
+	activeMu    sync.Mutex
+
+	// Unredeemed WebSocket tickets (see IssueTicket)
+	tickets   map[string]ticketEntry
+	ticketsMu sync.Mutex
+
+	// Magic-link login settings and single-use bookkeeping (see
+	// EnableMagicLinks)
+	magicLinks magicLinks
+
+	// Pending device pairing codes (see CreatePairingCode)
+	pairings   pairings
+	pairingsMu sync.Mutex
 }
 
 // Claims represents JWT claims
 type Claims struct {
 	UserID   int64  `json:"user_id"`
 	Username string `json:"username"`
+
+	// PasswordChange marks a restricted token that only allows changing the password
+	PasswordChange bool `json:"pwd_change,omitempty"`
+
+	// Scopes lists the capabilities granted to this token (see HasScope)
+	Scopes []string `json:"scopes,omitempty"`
+
+	// TokenBinding optionally restricts the token to one client type or device
+	TokenBinding
 	jwt.RegisteredClaims
 }
 
 // NewService creates a new auth service
-func NewService(db *DB, jwtSecret string, jwtExpiry time.Duration) *Service {
+func NewService(store UserStore, jwtSecret string, jwtExpiry time.Duration) *Service {
 	return &Service{
-		db:        db,
+		store:     store,
 		jwtSecret: []byte(jwtSecret),
 		jwtExpiry: jwtExpiry,
 	}
 }
 
+// SetTokenIdentity sets the iss and aud claims of new tokens. Tokens whose
+// claims do not match, including tokens without them, are then rejected.
+func (s *Service) SetTokenIdentity(issuer, audience string) {
+	s.issuer = issuer
+	s.audience = audience
+}
+
+// ConfigureRegistration sets the self-registration policy: disabled
+// entirely, or accounts held as pending until an admin approves them
+func (s *Service) ConfigureRegistration(enabled, requireApproval bool) {
+	s.registrationDisabled = !enabled
+	s.registrationApproval = requireApproval
+}
+
 // Register creates a new user
 func (s *Service) Register(req *CreateUserRequest) (*User, error) {
+	if s.registrationDisabled {
+		return nil, ErrRegistrationDisabled
+	}
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
 
-	user, err := s.db.CreateUser(req.Username, req.Password)
+	// Held accounts are created pending, so they are never briefly active
+	status := StatusActive
+	if s.registrationApproval {
+		status = StatusPending
+	}
+	return s.store.CreateUser(req.Username, req.Password, status)
+}
+
+// ApproveUser activates a pending account
+func (s *Service) ApproveUser(username string) (*User, error) {
+	user, err := s.store.GetUserByUsername(username)
 	if err != nil {
 		return nil, err
 	}
+	if user.Status == StatusActive {
+		return user, nil
+	}
 
+	if err := s.store.SetUserStatus(user.ID, StatusActive); err != nil {
+		return nil, err
+	}
+	user.Status = StatusActive
 	return user, nil
 }
 
@@ -51,7 +152,7 @@ func (s *Service) Login(req *LoginRequest) (*LoginResponse, error) {
 	}
 
 	// Get user by username
-	user, err := s.db.GetUserByUsername(req.Username)
+	user, err := s.store.GetUserByUsername(req.Username)
 	if err != nil {
 		if err == ErrUserNotFound {
 			return nil, ErrInvalidCredentials
@@ -59,18 +160,111 @@ func (s *Service) Login(req *LoginRequest) (*LoginResponse, error) {
 		return nil, err
 	}
 
-	// Check password
-	if !CheckPassword(req.Password, user.PasswordHash) {
+	// Check password; service account secrets are only accepted by
+	// ServiceToken
+	if user.Kind == KindService || !CheckPassword(req.Password, user.PasswordHash) {
 		return nil, ErrInvalidCredentials
 	}
 
+	// Only reveal the pending and disabled states to someone who knows the
+	// password
+	if user.Status == StatusPending {
+		return nil, ErrAccountPending
+	}
+	if user.Status == StatusDisabled {
+		return nil, ErrAccountDisabled
+	}
+	if !user.AllowsAddr(req.RemoteAddr) {
+		return nil, ErrSourceNotAllowed
+	}
+	if err := s.CheckBan(user.ID, req.RemoteAddr); err != nil {
+		return nil, err
+	}
+
+	// Upgrade hashes produced by an outdated algorithm or parameters
+	if NeedsRehash(user.PasswordHash) {
+		s.rehashPassword(user, req.Password)
+	}
+
+	scopes, err := narrowScopes(user.AllowedScopes(), req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(user, scopes)
+}
+
+// completeLogin issues the token for an authenticated user, applying the
+// concurrent-login policy
+func (s *Service) completeLogin(user *User, scopes []string) (*LoginResponse, error) {
+	// Under the terminate policy, tokens from earlier logins stop working
+	if s.LoginPolicy() == LoginPolicyTerminate {
+		s.replaceSessions(user.ID, time.Now())
+	}
+
 	// Update last login
-	if err := s.db.UpdateLastLogin(user.ID); err != nil {
+	if err := s.store.UpdateLastLogin(user.ID); err != nil {
 		// Log error but don't fail login
 		fmt.Printf("Failed to update last login for user %d: %v\n", user.ID, err)
 	}
 
 	// Generate JWT token
+	token, err := s.GenerateScopedToken(user, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:                  token,
+		User:                   user,
+		PasswordChangeRequired: user.MustChangePassword,
+	}, nil
+}
+
+// rehashPassword stores a fresh hash of a verified password
+func (s *Service) rehashPassword(user *User, password string) {
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		fmt.Printf("Failed to rehash password for user %d: %v\n", user.ID, err)
+		return
+	}
+
+	if err := s.store.SetPasswordHash(user.ID, passwordHash); err != nil {
+		// Log error but don't fail login
+		fmt.Printf("Failed to store rehashed password for user %d: %v\n", user.ID, err)
+		return
+	}
+	user.PasswordHash = passwordHash
+}
+
+// ChangePassword verifies the current password, stores the new one and
+// returns a fresh unrestricted token
+func (s *Service) ChangePassword(userID int64, req *ChangePasswordRequest) (*LoginResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Kind == KindService {
+		return nil, ErrServiceAccount
+	}
+
+	if !CheckPassword(req.CurrentPassword, user.PasswordHash) {
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.checkPasswordHistory(user, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.UpdatePassword(user.ID, req.NewPassword); err != nil {
+		return nil, err
+	}
+	s.recordPasswordHistory(user.ID, user.PasswordHash)
+	user.MustChangePassword = false
+
 	token, err := s.GenerateToken(user)
 	if err != nil {
 		return nil, err
@@ -82,43 +276,128 @@ func (s *Service) Login(req *LoginRequest) (*LoginResponse, error) {
 	}, nil
 }
 
-// GenerateToken generates a JWT token for a user
+// GenerateToken generates a JWT token for a user with all of the user's scopes
 func (s *Service) GenerateToken(user *User) (string, error) {
+	return s.GenerateScopedToken(user, user.AllowedScopes())
+}
+
+// GenerateScopedToken generates a JWT token carrying the given scopes
+func (s *Service) GenerateScopedToken(user *User, scopes []string) (string, error) {
+	return s.GenerateBoundToken(user, scopes, TokenBinding{})
+}
+
+// GenerateBoundToken generates a JWT token carrying the given scopes that is
+// only usable by the bound client type and device
+func (s *Service) GenerateBoundToken(user *User, scopes []string, binding TokenBinding) (string, error) {
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
+		UserID:         user.ID,
+		Username:       user.Username,
+		PasswordChange: user.MustChangePassword,
+		Scopes:         scopes,
+		TokenBinding:   binding,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    s.issuer,
 		},
 	}
+	if s.audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.audience}
+	}
+
+	if key := s.currentKey(); key != nil {
+		token := jwt.NewWithClaims(key.Method, claims)
+		token.Header["kid"] = key.ID
+		return token.SignedString(key.signingMaterial())
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(s.jwtSecret)
 }
 
-// ValidateToken validates a JWT token and returns claims
-func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
+// verificationKey selects the key that verifies a parsed token: the key
+// named by its "kid" header, or the legacy HMAC secret for tokens without
+// one while that secret is still usable (see legacySecretUsable)
+func (s *Service) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		if !s.legacySecretUsable(time.Now()) {
+			return nil, fmt.Errorf("tokens without a signing key ID are no longer accepted")
+		}
 		return s.jwtSecret, nil
-	})
+	}
+
+	key := s.keyByID(kid, time.Now())
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verificationMaterial(), nil
+}
+
+// ValidateToken validates a JWT token and returns claims
+//
+// Restricted tokens issued to users that must change their password are
+// rejected; use ValidatePasswordChangeToken for the password-change endpoint.
+func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := s.ValidatePasswordChangeToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.PasswordChange {
+		return nil, ErrPasswordChangeRequired
+	}
+
+	return claims, nil
+}
+
+// ValidatePasswordChangeToken validates a JWT token, accepting restricted
+// password-change tokens as well as regular ones
+func (s *Service) ValidatePasswordChangeToken(tokenString string) (*Claims, error) {
+	var options []jwt.ParserOption
+	if s.issuer != "" {
+		options = append(options, jwt.WithIssuer(s.issuer))
+	}
+	if s.audience != "" {
+		options = append(options, jwt.WithAudience(s.audience))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.verificationKey, options...)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		if err := s.checkSession(claims); err != nil {
+			return nil, err
+		}
+		if err := s.checkActive(claims.UserID); err != nil {
+			return nil, err
+		}
 		return claims, nil
 	}
 
 	return nil, ErrUnauthorized
 }
 
+// HasRole reports whether a user currently holds a role
+func (s *Service) HasRole(userID int64, role string) (bool, error) {
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.Role == role, nil
+}
+
 // GetUserFromToken validates token and retrieves user
 func (s *Service) GetUserFromToken(tokenString string) (*User, error) {
 	claims, err := s.ValidateToken(tokenString)
@@ -126,10 +405,15 @@ func (s *Service) GetUserFromToken(tokenString string) (*User, error) {
 		return nil, err
 	}
 
-	user, err := s.db.GetUserByID(claims.UserID)
+	user, err := s.store.GetUserByID(claims.UserID)
 	if err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
+
+// ListUsers returns every user
+func (s *Service) ListUsers() ([]*User, error) {
+	return s.store.ListUsers()
+}
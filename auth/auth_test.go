@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTokenIdentity tests issuer and audience validation
+func TestTokenIdentity(t *testing.T) {
+	db := newTestDB(t)
+	user, err := db.CreateUser("operator", "password123", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	production := NewService(db, "shared-secret", time.Hour)
+	production.SetTokenIdentity("https://pilot.example.com", "production")
+	staging := NewService(db, "shared-secret", time.Hour)
+	staging.SetTokenIdentity("https://pilot.example.com", "staging")
+	legacy := NewService(db, "shared-secret", time.Hour)
+
+	token, err := production.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	claims, err := production.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Expected own token to validate, got %v", err)
+	}
+	if claims.Issuer != "https://pilot.example.com" || len(claims.Audience) != 1 || claims.Audience[0] != "production" {
+		t.Errorf("Unexpected claims: iss=%q aud=%v", claims.Issuer, claims.Audience)
+	}
+
+	if _, err := staging.ValidateToken(token); err == nil {
+		t.Error("Expected token for another audience to be rejected")
+	}
+
+	// Tokens without iss/aud are rejected once they are configured
+	unscoped, _ := legacy.GenerateToken(user)
+	if _, err := production.ValidateToken(unscoped); err == nil {
+		t.Error("Expected token without iss/aud to be rejected")
+	}
+	if _, err := legacy.ValidateToken(token); err != nil {
+		t.Errorf("Expected service without iss/aud to accept the token, got %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxBanReason bounds the length of a ban reason
+const maxBanReason = 256
+
+// Ban blocks a user account or a network from logging in and connecting
+// until it expires or is removed. Exactly one of UserID and Network is set.
+type Ban struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Network   string     `json:"network,omitempty"`
+	Reason    string     `json:"reason"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// ipNet is the parsed Network
+	ipNet *net.IPNet
+}
+
+// BanSpec describes a new ban. ExpiresAt nil bans until removed.
+type BanSpec struct {
+	Username  string     `json:"username"`
+	Network   string     `json:"network"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// BanStore persists bans
+type BanStore interface {
+	ListBans() ([]*Ban, error)
+	CreateBan(ban *Ban) (int64, error)
+	DeleteBan(id int64) error
+}
+
+// DB implements BanStore
+var _ BanStore = (*DB)(nil)
+
+// Expired reports whether the ban no longer applies at now
+func (b *Ban) Expired(now time.Time) bool {
+	return b.ExpiresAt != nil && !now.Before(*b.ExpiresAt)
+}
+
+// Covers reports whether the ban applies to a user connecting from addr (an
+// IP address, optionally with a port). A zero userID or empty addr only
+// matches bans of the other kind.
+func (b *Ban) Covers(userID int64, addr string) bool {
+	if b.UserID != 0 {
+		return userID == b.UserID
+	}
+	if b.ipNet == nil || addr == "" {
+		return false
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && b.ipNet.Contains(ip)
+}
+
+// banList caches the persisted bans
+type banList struct {
+	store BanStore
+	bans  []*Ban
+
+	mu sync.RWMutex
+}
+
+// UseBanStore loads bans and enforces them at login and on CheckBan
+func (s *Service) UseBanStore(store BanStore) error {
+	s.bans.mu.Lock()
+	s.bans.store = store
+	s.bans.mu.Unlock()
+
+	return s.reloadBans()
+}
+
+// reloadBans refreshes the cached bans from the store
+func (s *Service) reloadBans() error {
+	s.bans.mu.Lock()
+	defer s.bans.mu.Unlock()
+
+	if s.bans.store == nil {
+		return nil
+	}
+	bans, err := s.bans.store.ListBans()
+	if err != nil {
+		return err
+	}
+	for _, ban := range bans {
+		if ban.Network != "" {
+			ban.ipNet, _ = parseNetwork(ban.Network)
+		}
+	}
+	s.bans.bans = bans
+	return nil
+}
+
+// ListBans returns the bans in effect, oldest first
+func (s *Service) ListBans() []*Ban {
+	now := time.Now()
+
+	s.bans.mu.RLock()
+	defer s.bans.mu.RUnlock()
+
+	bans := make([]*Ban, 0, len(s.bans.bans))
+	for _, ban := range s.bans.bans {
+		if !ban.Expired(now) {
+			bans = append(bans, ban)
+		}
+	}
+	return bans
+}
+
+// AddBan bans a user or a network on behalf of an admin (by)
+func (s *Service) AddBan(spec *BanSpec, by string) (*Ban, error) {
+	if s.bans.store == nil {
+		return nil, fmt.Errorf("bans are not enabled")
+	}
+	if (spec.Username == "") == (spec.Network == "") {
+		return nil, fmt.Errorf("%w: set either username or network", ErrInvalidBan)
+	}
+	if len(spec.Reason) > maxBanReason {
+		return nil, fmt.Errorf("%w: reason longer than %d characters", ErrInvalidBan, maxBanReason)
+	}
+	now := time.Now()
+	if spec.ExpiresAt != nil && !spec.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expires_at is in the past", ErrInvalidBan)
+	}
+
+	ban := &Ban{
+		Reason:    spec.Reason,
+		CreatedBy: by,
+		CreatedAt: now,
+		ExpiresAt: spec.ExpiresAt,
+	}
+	if spec.Username != "" {
+		user, err := s.store.GetUserByUsername(spec.Username)
+		if err != nil {
+			return nil, err
+		}
+		ban.UserID, ban.Username = user.ID, user.Username
+	} else {
+		ipNet, err := parseNetwork(spec.Network)
+		if err != nil {
+			return nil, ErrInvalidNetwork
+		}
+		ban.Network, ban.ipNet = ipNet.String(), ipNet
+	}
+
+	id, err := s.bans.store.CreateBan(ban)
+	if err != nil {
+		return nil, err
+	}
+	ban.ID = id
+	if err := s.reloadBans(); err != nil {
+		return nil, err
+	}
+	return ban, nil
+}
+
+// RemoveBan lifts a ban by ID
+func (s *Service) RemoveBan(id int64) error {
+	if s.bans.store == nil {
+		return ErrBanNotFound
+	}
+	if err := s.bans.store.DeleteBan(id); err != nil {
+		return err
+	}
+	return s.reloadBans()
+}
+
+// CheckBan returns ErrBanned when a ban in effect covers the user or addr
+func (s *Service) CheckBan(userID int64, addr string) error {
+	now := time.Now()
+
+	s.bans.mu.RLock()
+	defer s.bans.mu.RUnlock()
+
+	for _, ban := range s.bans.bans {
+		if !ban.Expired(now) && ban.Covers(userID, addr) {
+			return ErrBanned
+		}
+	}
+	return nil
+}
+
+// ListBans returns every stored ban, oldest first, with the banned user's
+// current name
+func (db *DB) ListBans() ([]*Ban, error) {
+	rows, err := db.conn.Query(
+		"SELECT b.id, b.user_id, u.username, b.network, b.reason, b.created_by, b.created_at, b.expires_at " +
+			"FROM bans b LEFT JOIN users u ON u.id = b.user_id ORDER BY b.id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []*Ban
+	for rows.Next() {
+		var userID *int64
+		var username, network *string
+		ban := &Ban{}
+		if err := rows.Scan(&ban.ID, &userID, &username, &network, &ban.Reason, &ban.CreatedBy, &ban.CreatedAt, &ban.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if userID != nil {
+			ban.UserID = *userID
+		}
+		if username != nil {
+			ban.Username = *username
+		}
+		if network != nil {
+			ban.Network = *network
+		}
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
+
+// CreateBan stores a ban and returns its ID
+func (db *DB) CreateBan(ban *Ban) (int64, error) {
+	var userID, network interface{}
+	if ban.UserID != 0 {
+		userID = ban.UserID
+	}
+	if ban.Network != "" {
+		network = ban.Network
+	}
+	return db.conn.Insert(
+		"INSERT INTO bans (user_id, network, reason, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, network, ban.Reason, ban.CreatedBy, ban.CreatedAt, ban.ExpiresAt,
+	)
+}
+
+// DeleteBan deletes a ban by ID
+func (db *DB) DeleteBan(id int64) error {
+	result, err := db.conn.Exec("DELETE FROM bans WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrBanNotFound
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestBans tests banning users and networks at login
+func TestBans(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	if err := service.UseBanStore(db); err != nil {
+		t.Fatalf("UseBanStore failed: %v", err)
+	}
+	if _, err := service.Register(&CreateUserRequest{Username: "pilot", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	login := &LoginRequest{Username: "pilot", Password: "password123", RemoteAddr: "10.1.2.3"}
+
+	userBan, err := service.AddBan(&BanSpec{Username: "pilot", Reason: "abuse"}, "admin")
+	if err != nil {
+		t.Fatalf("AddBan failed: %v", err)
+	}
+	if userBan.UserID == 0 || userBan.CreatedBy != "admin" || !userBan.Covers(userBan.UserID, "") {
+		t.Errorf("Unexpected user ban: %+v", userBan)
+	}
+	if _, err := service.Login(login); err != ErrBanned {
+		t.Errorf("Expected ErrBanned for a banned user, got %v", err)
+	}
+	if err := service.RemoveBan(userBan.ID); err != nil {
+		t.Fatalf("RemoveBan failed: %v", err)
+	}
+	if _, err := service.Login(login); err != nil {
+		t.Errorf("Expected login after the ban was lifted, got %v", err)
+	}
+
+	networkBan, err := service.AddBan(&BanSpec{Network: "10.1.0.0/16"}, "admin")
+	if err != nil {
+		t.Fatalf("AddBan failed: %v", err)
+	}
+	if _, err := service.Login(login); err != ErrBanned {
+		t.Errorf("Expected ErrBanned from a banned network, got %v", err)
+	}
+	if err := service.CheckBan(0, "10.2.0.1:443"); err != nil {
+		t.Errorf("Expected an address outside the network to pass, got %v", err)
+	}
+
+	// Bans survive a restart
+	restarted := NewService(db, "secret", time.Hour)
+	if err := restarted.UseBanStore(db); err != nil {
+		t.Fatalf("UseBanStore failed: %v", err)
+	}
+	if bans := restarted.ListBans(); len(bans) != 1 || bans[0].Network != "10.1.0.0/16" {
+		t.Errorf("Expected the network ban to be loaded, got %+v", bans)
+	}
+	if err := restarted.CheckBan(0, "10.1.9.9"); err != ErrBanned {
+		t.Errorf("Expected a loaded ban to be enforced, got %v", err)
+	}
+	if err := service.RemoveBan(networkBan.ID); err != nil {
+		t.Fatalf("RemoveBan failed: %v", err)
+	}
+	if err := service.RemoveBan(networkBan.ID); err != ErrBanNotFound {
+		t.Errorf("Expected ErrBanNotFound, got %v", err)
+	}
+}
+
+// TestBanValidation tests rejected ban specs and expiry
+func TestBanValidation(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	if err := service.UseBanStore(db); err != nil {
+		t.Fatalf("UseBanStore failed: %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	for _, spec := range []*BanSpec{
+		{},
+		{Username: "pilot", Network: "10.0.0.0/8"},
+		{Network: "10.0.0.0/8", ExpiresAt: &past},
+	} {
+		if _, err := service.AddBan(spec, "admin"); !errors.Is(err, ErrInvalidBan) {
+			t.Errorf("Expected ErrInvalidBan for %+v, got %v", spec, err)
+		}
+	}
+	if _, err := service.AddBan(&BanSpec{Network: "not-a-network"}, "admin"); err != ErrInvalidNetwork {
+		t.Errorf("Expected ErrInvalidNetwork, got %v", err)
+	}
+	if _, err := service.AddBan(&BanSpec{Username: "nobody"}, "admin"); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	ban, err := service.AddBan(&BanSpec{Network: "192.0.2.7"}, "admin")
+	if err != nil {
+		t.Fatalf("AddBan failed: %v", err)
+	}
+	if ban.Network != "192.0.2.7/32" {
+		t.Errorf("Expected a bare address to become a /32, got %q", ban.Network)
+	}
+	if ban.Expired(time.Now()) {
+		t.Error("Expected a ban without expiry not to expire")
+	}
+	soon := time.Now().Add(time.Hour)
+	ban.ExpiresAt = &soon
+	if !ban.Expired(soon) {
+		t.Error("Expected the ban to expire at expires_at")
+	}
+}
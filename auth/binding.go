@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"regexp"
+)
+
+// bindableClientTypes lists the WebSocket client types a token may be bound
+// to (must match the websocket package)
+var bindableClientTypes = map[string]bool{
+	"web":       true,
+	"video":     true,
+	"control":   true,
+	"telemetry": true,
+	"audio":     true,
+	"monitor":   true,
+}
+
+// deviceIDRegex limits device IDs to printable identifiers such as serial
+// numbers or hostnames
+var deviceIDRegex = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,64}$`)
+
+// TokenBinding restricts where a token may be used. A bound token can only
+// open a WebSocket connection whose handshake names the same client type
+// and device ID. Empty fields are unrestricted.
+type TokenBinding struct {
+	ClientType string `json:"client_type,omitempty"`
+	DeviceID   string `json:"device_id,omitempty"`
+}
+
+// ValidateBinding checks that a binding names a known client type and a
+// well-formed device ID
+func ValidateBinding(binding TokenBinding) error {
+	if binding.ClientType != "" && !bindableClientTypes[binding.ClientType] {
+		return ErrInvalidBinding
+	}
+	if binding.DeviceID != "" && !deviceIDRegex.MatchString(binding.DeviceID) {
+		return ErrInvalidBinding
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registration challenge types (REGISTRATION_CHALLENGE)
+const (
+	ChallengeHCaptcha    = "hcaptcha"
+	ChallengeTurnstile   = "turnstile"
+	ChallengeProofOfWork = "pow"
+)
+
+// captchaVerifyURLs are the providers' server-side verification endpoints
+var captchaVerifyURLs = map[string]string{
+	ChallengeHCaptcha:  "https://api.hcaptcha.com/siteverify",
+	ChallengeTurnstile: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+// powChallengeTTL is how long an issued proof-of-work challenge stays valid
+const powChallengeTTL = 5 * time.Minute
+
+// ChallengeResponse carries a client's answer to the registration challenge
+type ChallengeResponse struct {
+	CaptchaToken string `json:"captcha_token,omitempty"` // hCaptcha/Turnstile widget response
+	PowChallenge string `json:"pow_challenge,omitempty"` // Challenge from GET /api/register/challenge
+	PowNonce     string `json:"pow_nonce,omitempty"`     // Solution found by the client
+}
+
+// RegistrationChallenge stops automated account creation by requiring a
+// solved challenge with every registration
+type RegistrationChallenge interface {
+	// Issue returns what a client needs to solve the challenge
+	Issue() (map[string]interface{}, error)
+
+	// Verify checks a client's answer; remoteIP may be empty
+	Verify(response *ChallengeResponse, remoteIP string) error
+}
+
+// NewRegistrationChallenge creates the challenge for kind ("" disables it)
+func NewRegistrationChallenge(kind, siteKey, secret string, difficulty int) (RegistrationChallenge, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case ChallengeHCaptcha, ChallengeTurnstile:
+		if siteKey == "" || secret == "" {
+			return nil, fmt.Errorf("%s requires a site key and secret", kind)
+		}
+		return &CaptchaChallenge{
+			provider:  kind,
+			siteKey:   siteKey,
+			secret:    secret,
+			verifyURL: captchaVerifyURLs[kind],
+			client:    &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	case ChallengeProofOfWork:
+		pow, err := NewProofOfWork(difficulty)
+		if err != nil {
+			return nil, err
+		}
+		return pow, nil
+	}
+	return nil, fmt.Errorf("unknown registration challenge %q (expected hcaptcha, turnstile or pow)", kind)
+}
+
+// CaptchaChallenge verifies hCaptcha or Cloudflare Turnstile tokens. Both
+// providers share the same siteverify protocol.
+type CaptchaChallenge struct {
+	provider  string
+	siteKey   string
+	secret    string
+	verifyURL string
+	client    *http.Client
+}
+
+// Issue tells the client which widget to render
+func (c *CaptchaChallenge) Issue() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"type":     c.provider,
+		"site_key": c.siteKey,
+	}, nil
+}
+
+// Verify asks the provider whether the token is valid
+func (c *CaptchaChallenge) Verify(response *ChallengeResponse, remoteIP string) error {
+	if response.CaptchaToken == "" {
+		return ErrChallengeFailed
+	}
+
+	form := url.Values{"secret": {c.secret}, "response": {response.CaptchaToken}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	resp, err := c.client.PostForm(c.verifyURL, form)
+	if err != nil {
+		return fmt.Errorf("%s verification failed: %w", c.provider, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s verification failed: %w", c.provider, err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrChallengeFailed, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
+
+// ProofOfWork is a captcha-free challenge for headless deployments: the
+// client must find a nonce such that SHA-256(challenge + nonce) starts with
+// difficulty zero bits. Challenges are signed, so none are stored until
+// spent, and each can be used once.
+type ProofOfWork struct {
+	key        []byte
+	difficulty int
+
+	spent map[string]time.Time // Challenge -> expiry
+	mu    sync.Mutex
+}
+
+// NewProofOfWork creates a proof-of-work challenge with a random signing
+// key. Challenges are only valid on the server instance that issued them.
+func NewProofOfWork(difficulty int) (*ProofOfWork, error) {
+	if difficulty < 1 || difficulty > 32 {
+		return nil, fmt.Errorf("proof-of-work difficulty must be between 1 and 32, got %d", difficulty)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &ProofOfWork{key: key, difficulty: difficulty, spent: make(map[string]time.Time)}, nil
+}
+
+// Issue returns a new signed challenge: "<expiry>.<random>.<signature>"
+func (p *ProofOfWork) Issue() (map[string]interface{}, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(powChallengeTTL)
+	payload := strconv.FormatInt(expiresAt.Unix(), 10) + "." + hex.EncodeToString(random)
+	return map[string]interface{}{
+		"type":       ChallengeProofOfWork,
+		"challenge":  payload + "." + p.sign(payload),
+		"difficulty": p.difficulty,
+		"expires_at": expiresAt.UTC(),
+	}, nil
+}
+
+// Verify checks the signature, expiry and work of a solved challenge
+func (p *ProofOfWork) Verify(response *ChallengeResponse, _ string) error {
+	challenge := response.PowChallenge
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 3 || response.PowNonce == "" {
+		return ErrChallengeFailed
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(p.sign(payload))) {
+		return ErrChallengeFailed
+	}
+
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ErrChallengeFailed
+	}
+	expiresAt := time.Unix(expiry, 0)
+	now := time.Now()
+	if now.After(expiresAt) {
+		return fmt.Errorf("%w: challenge expired", ErrChallengeFailed)
+	}
+
+	sum := sha256.Sum256([]byte(challenge + response.PowNonce))
+	if leadingZeroBits(sum[:]) < p.difficulty {
+		return ErrChallengeFailed
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for spent, expiresAt := range p.spent {
+		if now.After(expiresAt) {
+			delete(p.spent, spent)
+		}
+	}
+	if _, ok := p.spent[challenge]; ok {
+		return fmt.Errorf("%w: challenge already used", ErrChallengeFailed)
+	}
+	p.spent[challenge] = expiresAt
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of a challenge payload
+func (p *ProofOfWork) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// leadingZeroBits counts the zero bits at the start of a hash
+func leadingZeroBits(sum []byte) int {
+	count := 0
+	for _, b := range sum {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// solve finds a nonce meeting the difficulty of a proof-of-work challenge
+func solve(challenge string, difficulty int) string {
+	for i := 0; ; i++ {
+		nonce := strconv.Itoa(i)
+		sum := sha256.Sum256([]byte(challenge + nonce))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return nonce
+		}
+	}
+}
+
+// TestProofOfWork tests solving, tampering and replaying challenges
+func TestProofOfWork(t *testing.T) {
+	pow, err := NewProofOfWork(8)
+	if err != nil {
+		t.Fatalf("NewProofOfWork() failed: %v", err)
+	}
+
+	issued, err := pow.Issue()
+	if err != nil {
+		t.Fatalf("Issue() failed: %v", err)
+	}
+	challenge := issued["challenge"].(string)
+	nonce := solve(challenge, 8)
+
+	if err := pow.Verify(&ChallengeResponse{PowChallenge: challenge + "0", PowNonce: nonce}, ""); !errors.Is(err, ErrChallengeFailed) {
+		t.Errorf("Expected tampered challenge to fail, got %v", err)
+	}
+	if err := pow.Verify(&ChallengeResponse{PowChallenge: challenge}, ""); !errors.Is(err, ErrChallengeFailed) {
+		t.Errorf("Expected missing nonce to fail, got %v", err)
+	}
+
+	response := &ChallengeResponse{PowChallenge: challenge, PowNonce: nonce}
+	if err := pow.Verify(response, ""); err != nil {
+		t.Fatalf("Expected solved challenge to pass, got %v", err)
+	}
+	if err := pow.Verify(response, ""); !errors.Is(err, ErrChallengeFailed) {
+		t.Errorf("Expected replayed challenge to fail, got %v", err)
+	}
+
+	if _, err := NewProofOfWork(40); err == nil {
+		t.Error("Expected difficulty above 32 to be rejected")
+	}
+}
+
+// TestCaptchaChallenge tests token verification against a siteverify endpoint
+func TestCaptchaChallenge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("secret") != "server-secret" || r.PostFormValue("remoteip") != "10.0.0.5" {
+			t.Errorf("Unexpected verification request: %v", r.PostForm)
+		}
+		if r.PostFormValue("response") == "good-token" {
+			w.Write([]byte(`{"success":true}`))
+			return
+		}
+		w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer server.Close()
+
+	challenge, err := NewRegistrationChallenge(ChallengeTurnstile, "site-key", "server-secret", 0)
+	if err != nil {
+		t.Fatalf("NewRegistrationChallenge() failed: %v", err)
+	}
+	captcha := challenge.(*CaptchaChallenge)
+	captcha.verifyURL = server.URL
+
+	if issued, _ := captcha.Issue(); issued["site_key"] != "site-key" || issued["type"] != ChallengeTurnstile {
+		t.Errorf("Unexpected issued challenge: %v", issued)
+	}
+	if err := captcha.Verify(&ChallengeResponse{CaptchaToken: "good-token"}, "10.0.0.5"); err != nil {
+		t.Errorf("Expected valid token to pass, got %v", err)
+	}
+	if err := captcha.Verify(&ChallengeResponse{CaptchaToken: "bad-token"}, "10.0.0.5"); !errors.Is(err, ErrChallengeFailed) {
+		t.Errorf("Expected invalid token to fail, got %v", err)
+	}
+	if err := captcha.Verify(&ChallengeResponse{}, "10.0.0.5"); !errors.Is(err, ErrChallengeFailed) {
+		t.Errorf("Expected missing token to fail, got %v", err)
+	}
+
+	if _, err := NewRegistrationChallenge(ChallengeHCaptcha, "", "", 0); err == nil {
+		t.Error("Expected hcaptcha without keys to be rejected")
+	}
+	if _, err := NewRegistrationChallenge("recaptcha", "a", "b", 0); err == nil {
+		t.Error("Expected unknown challenge type to be rejected")
+	}
+}
@@ -2,30 +2,55 @@ package auth
 
 import (
 	"database/sql"
+	"oculo-pilot-server/store"
+	"strings"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
+// userColumns lists the users table columns in the order scanned by scanUser
+const userColumns = "id, username, password_hash, created_at, updated_at, last_login_at, must_change_password, role, scopes, status, kind, allowed_networks, email"
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser scans a users row selected with userColumns
+func scanUser(row rowScanner) (*User, error) {
+	user := &User{}
+	var scopes, networks string
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt, &user.MustChangePassword, &user.Role, &scopes, &user.Status, &user.Kind, &networks, &user.Email)
+	if err != nil {
+		return nil, err
+	}
+	user.Scopes = strings.Fields(scopes)
+	user.AllowedNetworks = strings.Fields(networks)
+	return user, nil
+}
+
 // DB wraps database operations for user management
 type DB struct {
-	conn *sql.DB
+	conn *store.DB
 }
 
-// NewDB creates a new database connection and initializes schema
+// NewDB creates a new SQLite database connection and applies the auth
+// migrations
 func NewDB(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", dbPath)
+	conn, err := store.Open(store.DriverSQLite, dbPath)
 	if err != nil {
 		return nil, err
 	}
-
-	// Initialize schema
-	if err := initSchema(conn); err != nil {
+	if _, err := conn.Migrate(Schema); err != nil {
 		conn.Close()
 		return nil, err
 	}
+	return FromStore(conn), nil
+}
 
-	return &DB{conn: conn}, nil
+// FromStore keeps users in an open database, whose schema must include
+// the auth migrations (see Schema)
+func FromStore(conn *store.DB) *DB {
+	return &DB{conn: conn}
 }
 
 // Close closes the database connection
@@ -33,32 +58,17 @@ func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
-// initSchema creates the users table if it doesn't exist
-func initSchema(conn *sql.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT UNIQUE NOT NULL,
-		password_hash TEXT NOT NULL,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL,
-		last_login_at DATETIME
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
-	`
-
-	_, err := conn.Exec(schema)
-	return err
-}
-
-// CreateUser creates a new user with hashed password
-func (db *DB) CreateUser(username, password string) (*User, error) {
+// CreateUser creates a new user with hashed password and the given
+// account status
+func (db *DB) CreateUser(username, password, status string) (*User, error) {
 	// Validate input
 	req := CreateUserRequest{Username: username, Password: password}
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
+	if err := ValidateStatus(status); err != nil {
+		return nil, err
+	}
 
 	// Check if username already exists
 	exists, err := db.UsernameExists(username)
@@ -77,15 +87,46 @@ func (db *DB) CreateUser(username, password string) (*User, error) {
 
 	// Insert user
 	now := time.Now()
-	result, err := db.conn.Exec(
-		"INSERT INTO users (username, password_hash, created_at, updated_at) VALUES (?, ?, ?, ?)",
-		username, passwordHash, now, now,
+	id, err := db.conn.Insert(
+		"INSERT INTO users (username, password_hash, created_at, updated_at, status) VALUES (?, ?, ?, ?, ?)",
+		username, passwordHash, now, now, status,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	return &User{
+		ID:           id,
+		Username:     username,
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Role:         RoleUser,
+		Status:       status,
+		Kind:         KindUser,
+	}, nil
+}
+
+// CreateServiceAccount creates a service account authenticated by an
+// already hashed secret
+func (db *DB) CreateServiceAccount(username, secretHash string) (*User, error) {
+	if err := ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	exists, err := db.UsernameExists(username)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrUsernameTaken
+	}
+
+	now := time.Now()
+	id, err := db.conn.Insert(
+		"INSERT INTO users (username, password_hash, created_at, updated_at, kind) VALUES (?, ?, ?, ?, ?)",
+		username, secretHash, now, now, KindService,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -93,19 +134,42 @@ func (db *DB) CreateUser(username, password string) (*User, error) {
 	return &User{
 		ID:           id,
 		Username:     username,
-		PasswordHash: passwordHash,
+		PasswordHash: secretHash,
 		CreatedAt:    now,
 		UpdatedAt:    now,
+		Role:         RoleUser,
+		Status:       StatusActive,
+		Kind:         KindService,
 	}, nil
 }
 
 // GetUserByUsername retrieves a user by username
 func (db *DB) GetUserByUsername(username string) (*User, error) {
-	user := &User{}
-	err := db.conn.QueryRow(
-		"SELECT id, username, password_hash, created_at, updated_at, last_login_at FROM users WHERE username = ?",
+	user, err := scanUser(db.conn.QueryRow(
+		"SELECT "+userColumns+" FROM users WHERE username = ?",
 		username,
-	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
+	))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail retrieves a user by email address, ignoring case
+func (db *DB) GetUserByEmail(email string) (*User, error) {
+	if email == "" {
+		return nil, ErrUserNotFound
+	}
+
+	user, err := scanUser(db.conn.QueryRow(
+		"SELECT "+userColumns+" FROM users WHERE email = ?",
+		strings.ToLower(email),
+	))
 
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
@@ -119,11 +183,10 @@ func (db *DB) GetUserByUsername(username string) (*User, error) {
 
 // GetUserByID retrieves a user by ID
 func (db *DB) GetUserByID(id int64) (*User, error) {
-	user := &User{}
-	err := db.conn.QueryRow(
-		"SELECT id, username, password_hash, created_at, updated_at, last_login_at FROM users WHERE id = ?",
+	user, err := scanUser(db.conn.QueryRow(
+		"SELECT "+userColumns+" FROM users WHERE id = ?",
 		id,
-	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
+	))
 
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
@@ -155,10 +218,229 @@ func (db *DB) UpdateLastLogin(userID int64) error {
 	return err
 }
 
+// SetMustChangePassword sets whether a user must change password before getting full access
+func (db *DB) SetMustChangePassword(userID int64, mustChange bool) error {
+	result, err := db.conn.Exec(
+		"UPDATE users SET must_change_password = ?, updated_at = ? WHERE id = ?",
+		mustChange, time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetUserRole changes a user's role
+func (db *DB) SetUserRole(userID int64, role string) error {
+	if err := ValidateRole(role); err != nil {
+		return err
+	}
+
+	result, err := db.conn.Exec(
+		"UPDATE users SET role = ?, updated_at = ? WHERE id = ?",
+		role, time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetUserStatus changes a user's account status
+func (db *DB) SetUserStatus(userID int64, status string) error {
+	if err := ValidateStatus(status); err != nil {
+		return err
+	}
+
+	result, err := db.conn.Exec(
+		"UPDATE users SET status = ?, updated_at = ? WHERE id = ?",
+		status, time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetUserScopes overrides a user's token scopes; nil restores the role defaults
+func (db *DB) SetUserScopes(userID int64, scopes []string) error {
+	if err := ValidateScopes(scopes); err != nil {
+		return err
+	}
+
+	result, err := db.conn.Exec(
+		"UPDATE users SET scopes = ?, updated_at = ? WHERE id = ?",
+		joinScopes(scopes), time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetUserEmail sets a user's email address, stored lowercase; empty
+// clears it
+func (db *DB) SetUserEmail(userID int64, email string) error {
+	if err := ValidateEmail(email); err != nil {
+		return err
+	}
+	email = strings.ToLower(email)
+
+	if email != "" {
+		existing, err := db.GetUserByEmail(email)
+		if err == nil && existing.ID != userID {
+			return ErrEmailTaken
+		}
+		if err != nil && err != ErrUserNotFound {
+			return err
+		}
+	}
+
+	result, err := db.conn.Exec(
+		"UPDATE users SET email = ?, updated_at = ? WHERE id = ?",
+		email, time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetUserNetworks restricts the networks a user may log in and connect
+// from; nil lifts the restriction
+func (db *DB) SetUserNetworks(userID int64, networks []string) error {
+	if err := ValidateNetworks(networks); err != nil {
+		return err
+	}
+
+	result, err := db.conn.Exec(
+		"UPDATE users SET allowed_networks = ?, updated_at = ? WHERE id = ?",
+		strings.Join(networks, " "), time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces a user's password and clears the forced change flag
+func (db *DB) UpdatePassword(userID int64, password string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.conn.Exec(
+		"UPDATE users SET password_hash = ?, must_change_password = ?, updated_at = ? WHERE id = ?",
+		passwordHash, false, time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetPasswordHash replaces a user's stored hash, e.g. after rehashing with
+// the currently configured algorithm
+func (db *DB) SetPasswordHash(userID int64, passwordHash string) error {
+	result, err := db.conn.Exec(
+		"UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?",
+		passwordHash, time.Now(), userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 // ListUsers returns all users (for admin purposes)
 func (db *DB) ListUsers() ([]*User, error) {
 	rows, err := db.conn.Query(
-		"SELECT id, username, password_hash, created_at, updated_at, last_login_at FROM users ORDER BY created_at DESC",
+		"SELECT " + userColumns + " FROM users ORDER BY created_at DESC",
 	)
 	if err != nil {
 		return nil, err
@@ -167,8 +449,8 @@ func (db *DB) ListUsers() ([]*User, error) {
 
 	var users []*User
 	for rows.Next() {
-		user := &User{}
-		if err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -179,6 +461,13 @@ func (db *DB) ListUsers() ([]*User, error) {
 
 // DeleteUser deletes a user by ID
 func (db *DB) DeleteUser(userID int64) error {
+	if _, err := db.conn.Exec("DELETE FROM group_members WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := db.conn.Exec("DELETE FROM password_history WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
 	result, err := db.conn.Exec("DELETE FROM users WHERE id = ?", userID)
 	if err != nil {
 		return err
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB creates a SQLite database in a temporary directory
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := NewDB(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("NewDB() failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// TestUserLifecycle tests the SQLite store end to end
+func TestUserLifecycle(t *testing.T) {
+	db := newTestDB(t)
+
+	user, err := db.CreateUser("operator", "password123", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	if _, err := db.CreateUser("operator", "password123", StatusActive); err != ErrUsernameTaken {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+	if _, err := db.CreateUser("pilot", "password123", "suspended"); err != ErrInvalidStatus {
+		t.Errorf("Expected ErrInvalidStatus, got %v", err)
+	}
+
+	if err := db.SetMustChangePassword(user.ID, true); err != nil {
+		t.Fatalf("SetMustChangePassword() failed: %v", err)
+	}
+
+	loaded, err := db.GetUserByUsername("operator")
+	if err != nil {
+		t.Fatalf("GetUserByUsername() failed: %v", err)
+	}
+	if !loaded.MustChangePassword {
+		t.Error("Expected MustChangePassword to be set")
+	}
+	if loaded.Role != RoleUser {
+		t.Errorf("Expected default role %q, got %q", RoleUser, loaded.Role)
+	}
+
+	if err := db.SetUserRole(user.ID, "superuser"); err != ErrInvalidRole {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+	if err := db.SetUserRole(user.ID, RoleAdmin); err != nil {
+		t.Fatalf("SetUserRole() failed: %v", err)
+	}
+
+	if err := db.UpdatePassword(user.ID, "newpassword123"); err != nil {
+		t.Fatalf("UpdatePassword() failed: %v", err)
+	}
+
+	loaded, err = db.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() failed: %v", err)
+	}
+	if loaded.MustChangePassword {
+		t.Error("Expected MustChangePassword to be cleared by UpdatePassword")
+	}
+	if loaded.Role != RoleAdmin {
+		t.Errorf("Expected role %q, got %q", RoleAdmin, loaded.Role)
+	}
+	if !CheckPassword("newpassword123", loaded.PasswordHash) {
+		t.Error("Expected new password to verify")
+	}
+
+	if err := db.DeleteUser(user.ID); err != nil {
+		t.Fatalf("DeleteUser() failed: %v", err)
+	}
+	if _, err := db.GetUserByID(user.ID); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"time"
+)
+
+// activeCacheTTL bounds how long a token validation may rely on a cached
+// account status, so a deactivation made by another server instance
+// takes effect within this time
+const activeCacheTTL = 10 * time.Second
+
+// activeEntry is a cached account status lookup
+type activeEntry struct {
+	active    bool
+	checkedAt time.Time
+}
+
+// DeactivateUser suspends an account without deleting it. The user can no
+// longer log in and existing tokens are rejected until reactivated.
+func (s *Service) DeactivateUser(username string) (*User, error) {
+	return s.setActive(username, false)
+}
+
+// ReactivateUser lifts a deactivation
+func (s *Service) ReactivateUser(username string) (*User, error) {
+	return s.setActive(username, true)
+}
+
+// setActive switches an account between active and disabled. Pending
+// registrations are approved with ApproveUser instead.
+func (s *Service) setActive(username string, active bool) (*User, error) {
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+
+	user, err := s.store.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == StatusPending {
+		return nil, ErrAccountPending
+	}
+
+	status := StatusDisabled
+	if active {
+		status = StatusActive
+	}
+	if user.Status != status {
+		if err := s.store.SetUserStatus(user.ID, status); err != nil {
+			return nil, err
+		}
+		user.Status = status
+	}
+
+	s.activeMu.Lock()
+	delete(s.activeCache, user.ID)
+	s.activeMu.Unlock()
+
+	return user, nil
+}
+
+// checkActive rejects tokens of deactivated or deleted accounts. Lookups
+// are cached for activeCacheTTL.
+func (s *Service) checkActive(userID int64) error {
+	now := time.Now()
+
+	s.activeMu.Lock()
+	entry, ok := s.activeCache[userID]
+	s.activeMu.Unlock()
+
+	if !ok || now.Sub(entry.checkedAt) >= activeCacheTTL {
+		user, err := s.store.GetUserByID(userID)
+		if err != nil && err != ErrUserNotFound {
+			return err
+		}
+		entry = activeEntry{active: err == nil && user.Status == StatusActive, checkedAt: now}
+
+		s.activeMu.Lock()
+		if s.activeCache == nil {
+			s.activeCache = make(map[int64]activeEntry)
+		}
+		s.activeCache[userID] = entry
+		s.activeMu.Unlock()
+	}
+
+	if !entry.active {
+		return ErrAccountDisabled
+	}
+	return nil
+}
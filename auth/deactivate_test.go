@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDeactivateUser tests suspending and restoring an account
+func TestDeactivateUser(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+	if _, err := service.Register(&CreateUserRequest{Username: "pilot", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	login := &LoginRequest{Username: "pilot", Password: "password123"}
+
+	response, err := service.Login(login)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if _, err := service.ValidateToken(response.Token); err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+
+	user, err := service.DeactivateUser("pilot")
+	if err != nil {
+		t.Fatalf("DeactivateUser failed: %v", err)
+	}
+	if user.Status != StatusDisabled {
+		t.Errorf("Expected disabled status, got %s", user.Status)
+	}
+	if _, err := service.ValidateToken(response.Token); err != ErrAccountDisabled {
+		t.Errorf("Expected ErrAccountDisabled for existing token, got %v", err)
+	}
+	if _, err := service.Login(login); err != ErrAccountDisabled {
+		t.Errorf("Expected ErrAccountDisabled on login, got %v", err)
+	}
+
+	if _, err := service.ReactivateUser("pilot"); err != nil {
+		t.Fatalf("ReactivateUser failed: %v", err)
+	}
+	if _, err := service.ValidateToken(response.Token); err != nil {
+		t.Errorf("Expected token to be accepted after reactivation, got %v", err)
+	}
+	if _, err := service.Login(login); err != nil {
+		t.Errorf("Expected login after reactivation, got %v", err)
+	}
+}
+
+// TestDeletedUserToken tests that tokens of deleted accounts are rejected
+func TestDeletedUserToken(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+	if _, err := service.Register(&CreateUserRequest{Username: "pilot", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	response, err := service.Login(&LoginRequest{Username: "pilot", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if _, err := service.ValidateToken(response.Token); err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+
+	if err := service.DeleteUserByName("pilot", Precondition{}); err != nil {
+		t.Fatalf("DeleteUserByName failed: %v", err)
+	}
+	if _, err := service.ValidateToken(response.Token); err != ErrAccountDisabled {
+		t.Errorf("Expected token of deleted user to be rejected, got %v", err)
+	}
+}
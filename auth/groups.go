@@ -0,0 +1,318 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Group is a team of users granted control of a set of robots. A robot is
+// named by the account (or device identity) its control client connects as.
+type Group struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Members   []string  `json:"members"`
+	Robots    []string  `json:"robots"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GroupSpec is the desired membership and robots of a group
+type GroupSpec struct {
+	Members []string `json:"members"`
+	Robots  []string `json:"robots"`
+}
+
+// GroupStore persists groups
+type GroupStore interface {
+	ListGroups() ([]*Group, error)
+	SaveGroup(name string, memberIDs []int64, robots []string) (created bool, err error)
+	DeleteGroup(name string) error
+}
+
+// DB implements GroupStore
+var _ GroupStore = (*DB)(nil)
+
+var groupNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ValidateGroupName checks if a group name meets requirements
+func ValidateGroupName(name string) error {
+	if !groupNameRegex.MatchString(name) {
+		return ErrInvalidGroup
+	}
+	return nil
+}
+
+// groupAccess caches which users may control each robot
+type groupAccess struct {
+	store  GroupStore
+	groups []*Group
+
+	// robots maps a robot assigned to any group to the users allowed to
+	// control it; robots in no group are not restricted
+	robots map[string]map[string]bool
+
+	mu sync.RWMutex
+}
+
+// UseGroupStore loads groups and enables group-based robot access
+func (s *Service) UseGroupStore(store GroupStore) error {
+	s.groups.mu.Lock()
+	s.groups.store = store
+	s.groups.mu.Unlock()
+
+	return s.reloadGroups()
+}
+
+// reloadGroups refreshes the cached groups from the store
+func (s *Service) reloadGroups() error {
+	s.groups.mu.Lock()
+	defer s.groups.mu.Unlock()
+
+	if s.groups.store == nil {
+		return nil
+	}
+	groups, err := s.groups.store.ListGroups()
+	if err != nil {
+		return err
+	}
+
+	robots := make(map[string]map[string]bool)
+	for _, group := range groups {
+		for _, robot := range group.Robots {
+			if robots[robot] == nil {
+				robots[robot] = make(map[string]bool)
+			}
+			for _, member := range group.Members {
+				robots[robot][member] = true
+			}
+		}
+	}
+
+	s.groups.groups = groups
+	s.groups.robots = robots
+	return nil
+}
+
+// ListGroups returns every group ordered by name
+func (s *Service) ListGroups() []*Group {
+	s.groups.mu.RLock()
+	defer s.groups.mu.RUnlock()
+
+	groups := make([]*Group, len(s.groups.groups))
+	copy(groups, s.groups.groups)
+	return groups
+}
+
+// GetGroup returns a group by name
+func (s *Service) GetGroup(name string) (*Group, error) {
+	for _, group := range s.ListGroups() {
+		if group.Name == name {
+			return group, nil
+		}
+	}
+	return nil, ErrGroupNotFound
+}
+
+// PutGroup creates or replaces a group and reports whether it was created
+func (s *Service) PutGroup(name string, spec *GroupSpec) (*Group, bool, error) {
+	if err := ValidateGroupName(name); err != nil {
+		return nil, false, err
+	}
+	if s.groups.store == nil {
+		return nil, false, fmt.Errorf("groups are not enabled")
+	}
+
+	memberIDs := make([]int64, 0, len(spec.Members))
+	for _, username := range uniqueSorted(spec.Members) {
+		user, err := s.store.GetUserByUsername(username)
+		if err != nil {
+			return nil, false, fmt.Errorf("member %s: %w", username, err)
+		}
+		memberIDs = append(memberIDs, user.ID)
+	}
+	robots := uniqueSorted(spec.Robots)
+	for _, robot := range robots {
+		if robot == "" || len(robot) > 128 || strings.ContainsAny(robot, " \t\r\n") {
+			return nil, false, fmt.Errorf("%w: %q", ErrInvalidRobot, robot)
+		}
+	}
+
+	created, err := s.groups.store.SaveGroup(name, memberIDs, robots)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := s.reloadGroups(); err != nil {
+		return nil, false, err
+	}
+
+	group, err := s.GetGroup(name)
+	return group, created, err
+}
+
+// DeleteGroup deletes a group
+func (s *Service) DeleteGroup(name string) error {
+	if s.groups.store == nil {
+		return ErrGroupNotFound
+	}
+	if err := s.groups.store.DeleteGroup(name); err != nil {
+		return err
+	}
+	return s.reloadGroups()
+}
+
+// CanControl reports whether a user may send commands to a robot. Robots
+// not assigned to any group may be controlled by anyone with control scope.
+func (s *Service) CanControl(username, robot string) bool {
+	s.groups.mu.RLock()
+	defer s.groups.mu.RUnlock()
+
+	allowed, restricted := s.groups.robots[robot]
+	return !restricted || allowed[username]
+}
+
+// uniqueSorted returns the distinct values in sorted order
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ListGroups returns every group with its members and robots
+func (db *DB) ListGroups() ([]*Group, error) {
+	rows, err := db.conn.Query("SELECT id, name, created_at FROM user_groups ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*Group
+	byID := make(map[int64]*Group)
+	for rows.Next() {
+		group := &Group{Members: []string{}, Robots: []string{}}
+		if err := rows.Scan(&group.ID, &group.Name, &group.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+		byID[group.ID] = group
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := db.conn.Query(
+		"SELECT m.group_id, u.username FROM group_members m JOIN users u ON u.id = m.user_id ORDER BY u.username",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer members.Close()
+	for members.Next() {
+		var groupID int64
+		var username string
+		if err := members.Scan(&groupID, &username); err != nil {
+			return nil, err
+		}
+		if group := byID[groupID]; group != nil {
+			group.Members = append(group.Members, username)
+		}
+	}
+	if err := members.Err(); err != nil {
+		return nil, err
+	}
+
+	robots, err := db.conn.Query("SELECT group_id, robot FROM group_robots ORDER BY robot")
+	if err != nil {
+		return nil, err
+	}
+	defer robots.Close()
+	for robots.Next() {
+		var groupID int64
+		var robot string
+		if err := robots.Scan(&groupID, &robot); err != nil {
+			return nil, err
+		}
+		if group := byID[groupID]; group != nil {
+			group.Robots = append(group.Robots, robot)
+		}
+	}
+
+	return groups, robots.Err()
+}
+
+// SaveGroup creates a group or replaces its members and robots
+func (db *DB) SaveGroup(name string, memberIDs []int64, robots []string) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var groupID int64
+	created := false
+	err = tx.QueryRow("SELECT id FROM user_groups WHERE name = ?", name).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		created = true
+		groupID, err = tx.Insert("INSERT INTO user_groups (name, created_at) VALUES (?, ?)", name, time.Now())
+	}
+	if err != nil {
+		return false, err
+	}
+
+	exec := func(query string, args ...interface{}) error {
+		_, err := tx.Exec(query, args...)
+		return err
+	}
+
+	if err := exec("DELETE FROM group_members WHERE group_id = ?", groupID); err != nil {
+		return false, err
+	}
+	if err := exec("DELETE FROM group_robots WHERE group_id = ?", groupID); err != nil {
+		return false, err
+	}
+	for _, userID := range memberIDs {
+		if err := exec("INSERT INTO group_members (group_id, user_id) VALUES (?, ?)", groupID, userID); err != nil {
+			return false, err
+		}
+	}
+	for _, robot := range robots {
+		if err := exec("INSERT INTO group_robots (group_id, robot) VALUES (?, ?)", groupID, robot); err != nil {
+			return false, err
+		}
+	}
+
+	return created, tx.Commit()
+}
+
+// DeleteGroup deletes a group and its memberships
+func (db *DB) DeleteGroup(name string) error {
+	var groupID int64
+	err := db.conn.QueryRow("SELECT id FROM user_groups WHERE name = ?", name).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM group_members WHERE group_id = ?",
+		"DELETE FROM group_robots WHERE group_id = ?",
+		"DELETE FROM user_groups WHERE id = ?",
+	} {
+		if _, err := db.conn.Exec(query, groupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestGroups tests group management and robot control permissions
+func TestGroups(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	if err := service.UseGroupStore(db); err != nil {
+		t.Fatalf("UseGroupStore failed: %v", err)
+	}
+	for _, username := range []string{"alice", "bob"} {
+		if _, err := service.Register(&CreateUserRequest{Username: username, Password: "password123"}); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+	}
+
+	// Without groups every robot is unrestricted
+	if !service.CanControl("bob", "robot-1") {
+		t.Error("Expected unassigned robot to be unrestricted")
+	}
+
+	group, created, err := service.PutGroup("field-team", &GroupSpec{
+		Members: []string{"alice", "alice"},
+		Robots:  []string{"robot-1", "device:arm-2"},
+	})
+	if err != nil || !created {
+		t.Fatalf("PutGroup failed: %v (created=%v)", err, created)
+	}
+	if len(group.Members) != 1 || len(group.Robots) != 2 {
+		t.Errorf("Unexpected group: %+v", group)
+	}
+
+	if !service.CanControl("alice", "robot-1") || !service.CanControl("alice", "device:arm-2") {
+		t.Error("Expected member to control the group's robots")
+	}
+	if service.CanControl("bob", "robot-1") {
+		t.Error("Expected non-member to be denied")
+	}
+	if !service.CanControl("bob", "robot-3") {
+		t.Error("Expected robot outside any group to stay unrestricted")
+	}
+
+	// PUT replaces membership
+	if _, created, err := service.PutGroup("field-team", &GroupSpec{Members: []string{"bob"}, Robots: []string{"robot-1"}}); err != nil || created {
+		t.Fatalf("PutGroup update failed: %v (created=%v)", err, created)
+	}
+	if service.CanControl("alice", "robot-1") || !service.CanControl("bob", "robot-1") {
+		t.Error("Expected membership to be replaced")
+	}
+
+	if _, _, err := service.PutGroup("field-team", &GroupSpec{Members: []string{"nobody"}}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound for unknown member, got %v", err)
+	}
+	if _, _, err := service.PutGroup("bad name", &GroupSpec{}); err != ErrInvalidGroup {
+		t.Errorf("Expected ErrInvalidGroup, got %v", err)
+	}
+
+	// Deleting a member drops their access
+	if err := service.DeleteUserByName("bob", Precondition{}); err != nil {
+		t.Fatalf("DeleteUserByName failed: %v", err)
+	}
+	if group, _ := service.GetGroup("field-team"); len(group.Members) != 0 {
+		t.Errorf("Expected deleted user to leave the group, got %v", group.Members)
+	}
+
+	if err := service.DeleteGroup("field-team"); err != nil {
+		t.Fatalf("DeleteGroup failed: %v", err)
+	}
+	if err := service.DeleteGroup("field-team"); err != ErrGroupNotFound {
+		t.Errorf("Expected ErrGroupNotFound, got %v", err)
+	}
+	if !service.CanControl("alice", "robot-1") {
+		t.Error("Expected robot to be unrestricted after its group was deleted")
+	}
+}
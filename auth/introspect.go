@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"strconv"
+)
+
+// Introspection is a token introspection response (RFC 7662). Inactive
+// tokens carry no other fields.
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	Subject   string   `json:"sub,omitempty"` // User ID
+	Role      string   `json:"role,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+
+	// Binding restricts where the token may be used (see TokenBinding)
+	ClientType string `json:"client_type,omitempty"`
+	DeviceID   string `json:"device_id,omitempty"`
+}
+
+// Introspect reports whether a token is currently usable and whom it
+// identifies. Besides signature and expiry, the user must still exist and
+// be active, so deleted or suspended accounts read as inactive.
+func (s *Service) Introspect(tokenString string) *Introspection {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return &Introspection{Active: false}
+	}
+
+	user, err := s.store.GetUserByID(claims.UserID)
+	if err != nil || user.Status != StatusActive {
+		return &Introspection{Active: false}
+	}
+
+	// Tokens issued before scopes existed carry the user's full scopes
+	scopes := claims.Scopes
+	if scopes == nil {
+		scopes = user.AllowedScopes()
+	}
+
+	result := &Introspection{
+		Active:    true,
+		Scope:     joinScopes(scopes),
+		Username:  user.Username,
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Role:      user.Role,
+		TokenType: "Bearer",
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+
+		ClientType: claims.ClientType,
+		DeviceID:   claims.DeviceID,
+	}
+	if claims.ExpiresAt != nil {
+		result.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		result.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		result.NotBefore = claims.NotBefore.Unix()
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestIntrospect tests introspection of valid, invalid and orphaned tokens
+func TestIntrospect(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	service.SetTokenIdentity("pilot", "production")
+	user, err := service.Register(&CreateUserRequest{Username: "operator", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	response, err := service.Login(&LoginRequest{Username: "operator", Password: "password123", Scopes: []string{ScopeWSView}})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	result := service.Introspect(response.Token)
+	if !result.Active || result.Username != "operator" || result.Scope != ScopeWSView {
+		t.Errorf("Unexpected introspection: %+v", result)
+	}
+	if result.Issuer != "pilot" || len(result.Audience) != 1 || result.ExpiresAt == 0 {
+		t.Errorf("Expected iss, aud and exp, got %+v", result)
+	}
+
+	if result := service.Introspect("not-a-token"); result.Active || result.Username != "" {
+		t.Errorf("Expected bare inactive response, got %+v", result)
+	}
+
+	if err := db.DeleteUser(user.ID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if service.Introspect(response.Token).Active {
+		t.Error("Expected token of a deleted user to be inactive")
+	}
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a key used to sign JWTs: either an asymmetric private key
+// (RS256/ES256) or an HMAC secret (HS256)
+type SigningKey struct {
+	// ID is published as the token "kid" header and in the JWKS
+	ID string
+
+	Method  jwt.SigningMethod
+	Private crypto.Signer // RS256/ES256
+	Secret  []byte        // HS256
+
+	CreatedAt time.Time
+	RetiredAt *time.Time // set once a newer key took over signing
+}
+
+// SigningKeyInfo describes a signing key without its secret material
+type SigningKeyInfo struct {
+	ID        string     `json:"kid"`
+	Algorithm string     `json:"alg"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+	Active    bool       `json:"active"`
+}
+
+// JWK is a JSON Web Key (RFC 7517) describing a public signing key
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA public key
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC public key
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadSigningKey reads a PEM-encoded RSA or P-256 EC private key.
+// RSA keys sign with RS256 and EC keys with ES256.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKey(data)
+}
+
+// ParseSigningKey parses a PEM-encoded private key (PKCS#1, SEC 1 or PKCS#8)
+func ParseSigningKey(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found in signing key")
+	}
+
+	var private interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(private)
+}
+
+// NewSigningKey wraps an RSA or P-256 EC private key
+func NewSigningKey(private interface{}) (*SigningKey, error) {
+	key := &SigningKey{}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA signing key must be at least 2048 bits")
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.Private = k
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("EC signing key must use the P-256 curve")
+		}
+		key.Method = jwt.SigningMethodES256
+		key.Private = k
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", private)
+	}
+
+	id, err := keyThumbprint(key.Private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+
+	return key, nil
+}
+
+// NewHMACKey creates an HS256 key with a random secret and key ID
+func NewHMACKey() (*SigningKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:     base64.RawURLEncoding.EncodeToString(id),
+		Method: jwt.SigningMethodHS256,
+		Secret: secret,
+	}, nil
+}
+
+// GenerateSigningKey creates a new key for the given algorithm
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	switch alg {
+	case "HS256":
+		return NewHMACKey()
+	case "RS256":
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(private)
+	case "ES256":
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(private)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+}
+
+// Symmetric reports whether the key is an HMAC secret
+func (k *SigningKey) Symmetric() bool {
+	return k.Private == nil
+}
+
+// Public returns the public half of the key (nil for HMAC keys)
+func (k *SigningKey) Public() crypto.PublicKey {
+	if k.Symmetric() {
+		return nil
+	}
+	return k.Private.Public()
+}
+
+// Info describes the key without its secret material
+func (k *SigningKey) Info() SigningKeyInfo {
+	return SigningKeyInfo{
+		ID:        k.ID,
+		Algorithm: k.Method.Alg(),
+		CreatedAt: k.CreatedAt,
+		RetiredAt: k.RetiredAt,
+		Active:    k.RetiredAt == nil,
+	}
+}
+
+// signingMaterial returns the value passed to jwt.Token.SignedString
+func (k *SigningKey) signingMaterial() interface{} {
+	if k.Symmetric() {
+		return k.Secret
+	}
+	return k.Private
+}
+
+// verificationMaterial returns the value returned from a jwt.Keyfunc
+func (k *SigningKey) verificationMaterial() interface{} {
+	if k.Symmetric() {
+		return k.Secret
+	}
+	return k.Public()
+}
+
+// marshal encodes the key material for storage: base64 for HMAC secrets,
+// PEM PKCS#8 for private keys
+func (k *SigningKey) marshal() (string, error) {
+	if k.Symmetric() {
+		return base64.StdEncoding.EncodeToString(k.Secret), nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// unmarshalSigningKey decodes stored key material
+func unmarshalSigningKey(id, alg, data string) (*SigningKey, error) {
+	if alg == "HS256" {
+		secret, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodHS256, Secret: secret}, nil
+	}
+
+	key, err := ParseSigningKey([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	if key.Method.Alg() != alg {
+		return nil, fmt.Errorf("stored key %s is %s, expected %s", id, key.Method.Alg(), alg)
+	}
+	key.ID = id
+	return key, nil
+}
+
+// JWK returns the public key in JWK form
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{
+		Kid: k.ID,
+		Use: "sig",
+		Alg: k.Method.Alg(),
+	}
+
+	switch pub := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))
+	}
+
+	return jwk
+}
+
+// keyThumbprint derives a stable key ID from the public key
+func keyThumbprint(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:16]), nil
+}
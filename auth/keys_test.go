@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyFile PEM-encodes a private key into a temp file
+func writeKeyFile(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return path
+}
+
+// TestLoadSigningKey tests loading RSA and EC keys in the supported PEM formats
+func TestLoadSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	pkcs8DER, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+
+	tests := []struct {
+		name      string
+		blockType string
+		der       []byte
+		alg       string
+		kty       string
+	}{
+		{"pkcs1 rsa", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), "RS256", "RSA"},
+		{"pkcs8 rsa", "PRIVATE KEY", pkcs8DER, "RS256", "RSA"},
+		{"sec1 ec", "EC PRIVATE KEY", ecDER, "ES256", "EC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadSigningKey(writeKeyFile(t, tt.blockType, tt.der))
+			if err != nil {
+				t.Fatalf("LoadSigningKey() failed: %v", err)
+			}
+			if key.Method.Alg() != tt.alg {
+				t.Errorf("Expected %s, got %s", tt.alg, key.Method.Alg())
+			}
+
+			jwk := key.JWK()
+			if jwk.Kty != tt.kty || jwk.Kid != key.ID || jwk.Alg != tt.alg {
+				t.Errorf("Unexpected JWK: %+v", jwk)
+			}
+		})
+	}
+}
+
+// TestLoadSigningKeyRejectsUnsupported tests rejection of unsupported keys
+func TestLoadSigningKeyRejectsUnsupported(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	der, _ := x509.MarshalECPrivateKey(p384)
+
+	if _, err := LoadSigningKey(writeKeyFile(t, "EC PRIVATE KEY", der)); err == nil {
+		t.Error("Expected P-384 key to be rejected")
+	}
+	if _, err := ParseSigningKey([]byte("not a key")); err == nil {
+		t.Error("Expected non-PEM input to be rejected")
+	}
+}
+
+// TestAsymmetricTokens tests signing with an EC key and verifying via kid
+func TestAsymmetricTokens(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	key, err := NewSigningKey(ecKey)
+	if err != nil {
+		t.Fatalf("NewSigningKey() failed: %v", err)
+	}
+
+	db := newTestDB(t)
+	user, err := db.CreateUser("pilot", "password123", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	legacy := NewService(db, "secret", time.Hour)
+	hmacToken, err := legacy.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken() failed: %v", err)
+	}
+
+	service := NewService(db, "secret", time.Hour)
+	if err := service.UseSigningKey(key); err != nil {
+		t.Fatalf("UseSigningKey() failed: %v", err)
+	}
+
+	token, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken() failed: %v", err)
+	}
+	claims, err := service.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken() failed: %v", err)
+	}
+	if claims.Username != "pilot" {
+		t.Errorf("Expected pilot, got %s", claims.Username)
+	}
+
+	// Tokens issued before switching keys keep working
+	if _, err := service.ValidateToken(hmacToken); err != nil {
+		t.Errorf("Expected HMAC token to validate: %v", err)
+	}
+
+	// A service without the key cannot verify the asymmetric token
+	if _, err := legacy.ValidateToken(token); err == nil {
+		t.Error("Expected asymmetric token to fail without signing key")
+	}
+
+	jwks := service.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != key.ID {
+		t.Errorf("Unexpected JWKS: %+v", jwks)
+	}
+	if len(legacy.JWKS().Keys) != 0 {
+		t.Error("Expected empty JWKS without signing key")
+	}
+}
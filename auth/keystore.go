@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"time"
+)
+
+// KeyStore persists JWT signing keys so rotated keys survive restarts.
+// Retired keys are kept so a configured key file is not reactivated.
+type KeyStore interface {
+	ListSigningKeys() ([]*SigningKey, error)
+	SaveSigningKey(key *SigningKey) error
+	RetireSigningKey(id string, at time.Time) error
+}
+
+// DB implements KeyStore
+var _ KeyStore = (*DB)(nil)
+
+// ListSigningKeys returns all stored signing keys, oldest first
+func (db *DB) ListSigningKeys() ([]*SigningKey, error) {
+	rows, err := db.conn.Query(
+		"SELECT id, algorithm, key_data, created_at, retired_at FROM signing_keys ORDER BY created_at",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*SigningKey
+	for rows.Next() {
+		var (
+			id, alg, data string
+			createdAt     time.Time
+			retiredAt     *time.Time
+		)
+		if err := rows.Scan(&id, &alg, &data, &createdAt, &retiredAt); err != nil {
+			return nil, err
+		}
+
+		key, err := unmarshalSigningKey(id, alg, data)
+		if err != nil {
+			return nil, err
+		}
+		key.CreatedAt = createdAt
+		key.RetiredAt = retiredAt
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// SaveSigningKey stores a new signing key
+func (db *DB) SaveSigningKey(key *SigningKey) error {
+	data, err := key.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.conn.Exec(
+		"INSERT INTO signing_keys (id, algorithm, key_data, created_at, retired_at) VALUES (?, ?, ?, ?, ?)",
+		key.ID, key.Method.Alg(), data, key.CreatedAt, key.RetiredAt,
+	)
+	return err
+}
+
+// RetireSigningKey marks a key as no longer used for signing
+func (db *DB) RetireSigningKey(id string, at time.Time) error {
+	_, err := db.conn.Exec(
+		"UPDATE signing_keys SET retired_at = ? WHERE id = ? AND retired_at IS NULL",
+		at, id,
+	)
+	return err
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+)
+
+// magicLinkPrefix marks magic-link tokens so they are not mistaken for JWTs
+const magicLinkPrefix = "ml_"
+
+// magicLinkInterval is the minimum time between links for one account, so
+// the endpoint cannot be used to flood an inbox
+const magicLinkInterval = time.Minute
+
+// magicLinkPayloadSize is user ID, expiry and nonce
+const magicLinkPayloadSize = 8 + 8 + 16
+
+// magicLinks holds the magic-link policy and the nonces already redeemed
+type magicLinks struct {
+	enabled bool
+	ttl     time.Duration
+	scopes  []string // Narrow tokens issued through a link; empty grants the user's scopes
+
+	used     map[string]time.Time // Redeemed nonces until they expire
+	lastSent map[int64]time.Time
+	mu       sync.Mutex
+}
+
+// EnableMagicLinks allows passwordless login through signed, single-use
+// links valid for ttl. Tokens issued through a link are narrowed to scopes,
+// e.g. ["ws:view"] so a lost link cannot be used to drive a robot; empty
+// grants the user's usual scopes.
+func (s *Service) EnableMagicLinks(ttl time.Duration, scopes []string) error {
+	if err := ValidateScopes(scopes); err != nil {
+		return err
+	}
+	s.magicLinks.mu.Lock()
+	defer s.magicLinks.mu.Unlock()
+	s.magicLinks.enabled = true
+	s.magicLinks.ttl = ttl
+	s.magicLinks.scopes = scopes
+	return nil
+}
+
+// magicLinkKey derives the link signing key from the JWT secret, so links
+// and tokens are never signed with the same key
+func (s *Service) magicLinkKey() []byte {
+	mac := hmac.New(sha256.New, s.jwtSecret)
+	mac.Write([]byte("magic-link"))
+	return mac.Sum(nil)
+}
+
+// IssueMagicLink creates a login token for the user with this username or
+// email address. The caller sends it to user.Email; users without an
+// email, service accounts and inactive accounts get ErrUserNotFound, so
+// callers can treat every failure alike and not reveal which accounts
+// exist.
+func (s *Service) IssueMagicLink(login string) (*User, string, time.Time, error) {
+	s.magicLinks.mu.Lock()
+	enabled, ttl := s.magicLinks.enabled, s.magicLinks.ttl
+	s.magicLinks.mu.Unlock()
+	if !enabled {
+		return nil, "", time.Time{}, ErrMagicLinksDisabled
+	}
+
+	var user *User
+	var err error
+	if strings.Contains(login, "@") {
+		user, err = s.store.GetUserByEmail(login)
+	} else {
+		user, err = s.store.GetUserByUsername(login)
+	}
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if user.Email == "" || user.Kind == KindService || user.Status != StatusActive {
+		return nil, "", time.Time{}, ErrUserNotFound
+	}
+
+	now := time.Now()
+	s.magicLinks.mu.Lock()
+	if last, ok := s.magicLinks.lastSent[user.ID]; ok && now.Sub(last) < magicLinkInterval {
+		s.magicLinks.mu.Unlock()
+		return nil, "", time.Time{}, ErrMagicLinkThrottled
+	}
+	if s.magicLinks.lastSent == nil {
+		s.magicLinks.lastSent = make(map[int64]time.Time)
+	}
+	for id, last := range s.magicLinks.lastSent {
+		if now.Sub(last) >= magicLinkInterval {
+			delete(s.magicLinks.lastSent, id)
+		}
+	}
+	s.magicLinks.lastSent[user.ID] = now
+	s.magicLinks.mu.Unlock()
+
+	expiresAt := now.Add(ttl)
+	payload := make([]byte, magicLinkPayloadSize)
+	binary.BigEndian.PutUint64(payload[0:8], uint64(user.ID))
+	binary.BigEndian.PutUint64(payload[8:16], uint64(expiresAt.Unix()))
+	if _, err := rand.Read(payload[16:]); err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	mac := hmac.New(sha256.New, s.magicLinkKey())
+	mac.Write(payload)
+	token := magicLinkPrefix + base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return user, token, expiresAt, nil
+}
+
+// RedeemMagicLink verifies a link token and logs its user in from
+// remoteAddr. Each link works once, and only while the account is active.
+func (s *Service) RedeemMagicLink(token, remoteAddr string) (*LoginResponse, error) {
+	encoded, signature, ok := strings.Cut(strings.TrimPrefix(token, magicLinkPrefix), ".")
+	if !ok || !strings.HasPrefix(token, magicLinkPrefix) {
+		return nil, ErrInvalidMagicLink
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) != magicLinkPayloadSize {
+		return nil, ErrInvalidMagicLink
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+	mac := hmac.New(sha256.New, s.magicLinkKey())
+	mac.Write(payload)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, ErrInvalidMagicLink
+	}
+
+	userID := int64(binary.BigEndian.Uint64(payload[0:8]))
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[8:16])), 0)
+	nonce := string(payload[16:])
+	now := time.Now()
+	if now.After(expiresAt) {
+		return nil, ErrInvalidMagicLink
+	}
+
+	s.magicLinks.mu.Lock()
+	if !s.magicLinks.enabled {
+		s.magicLinks.mu.Unlock()
+		return nil, ErrMagicLinksDisabled
+	}
+	if _, used := s.magicLinks.used[nonce]; used {
+		s.magicLinks.mu.Unlock()
+		return nil, ErrInvalidMagicLink
+	}
+	if s.magicLinks.used == nil {
+		s.magicLinks.used = make(map[string]time.Time)
+	}
+	for key, until := range s.magicLinks.used {
+		if now.After(until) {
+			delete(s.magicLinks.used, key)
+		}
+	}
+	s.magicLinks.used[nonce] = expiresAt
+	scopes := s.magicLinks.scopes
+	s.magicLinks.mu.Unlock()
+
+	user, err := s.store.GetUserByID(userID)
+	if err == ErrUserNotFound {
+		return nil, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == StatusPending {
+		return nil, ErrAccountPending
+	}
+	if user.Status == StatusDisabled {
+		return nil, ErrAccountDisabled
+	}
+	if !user.AllowsAddr(remoteAddr) {
+		return nil, ErrSourceNotAllowed
+	}
+	if err := s.CheckBan(user.ID, remoteAddr); err != nil {
+		return nil, err
+	}
+
+	granted := user.AllowedScopes()
+	if len(scopes) > 0 {
+		// Only the link scopes the user actually holds; an empty list would
+		// read as an unrestricted legacy token
+		if granted = intersectScopes(granted, scopes); len(granted) == 0 {
+			return nil, ErrInvalidScope
+		}
+	}
+	return s.completeLogin(user, granted)
+}
+
+// intersectScopes returns the scopes in both lists, in the order of a
+func intersectScopes(a, b []string) []string {
+	var scopes []string
+	for _, scope := range a {
+		for _, other := range b {
+			if scope == other {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	return scopes
+}
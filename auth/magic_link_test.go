@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMagicLink tests issuing links by username or email, single use,
+// tampering, throttling and scope narrowing
+func TestMagicLink(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+	if _, _, _, err := service.IssueMagicLink("viewer"); err != ErrMagicLinksDisabled {
+		t.Errorf("Expected ErrMagicLinksDisabled, got %v", err)
+	}
+	if err := service.EnableMagicLinks(15*time.Minute, []string{ScopeWSView}); err != nil {
+		t.Fatalf("EnableMagicLinks failed: %v", err)
+	}
+
+	if _, _, err := service.PutUser("viewer", &UserSpec{Password: "password123", Email: "Viewer@Example.com"}, Precondition{}); err != nil {
+		t.Fatalf("PutUser failed: %v", err)
+	}
+	if _, _, err := service.PutUser("other", &UserSpec{Password: "password123", Email: "viewer@example.com"}, Precondition{}); err != ErrEmailTaken {
+		t.Errorf("Expected ErrEmailTaken, got %v", err)
+	}
+	if _, _, err := service.PutUser("other", &UserSpec{Password: "password123", Email: "Viewer <viewer@example.com>"}, Precondition{}); err != ErrInvalidEmail {
+		t.Errorf("Expected ErrInvalidEmail, got %v", err)
+	}
+	if _, _, err := service.PutUser("nomail", &UserSpec{Password: "password123"}, Precondition{}); err != nil {
+		t.Fatalf("PutUser failed: %v", err)
+	}
+
+	user, token, _, err := service.IssueMagicLink("VIEWER@example.com")
+	if err != nil {
+		t.Fatalf("IssueMagicLink failed: %v", err)
+	}
+	if user.Username != "viewer" || user.Email != "viewer@example.com" || !strings.HasPrefix(token, magicLinkPrefix) {
+		t.Errorf("Unexpected link for %+v: %s", user, token)
+	}
+	if _, _, _, err := service.IssueMagicLink("viewer"); err != ErrMagicLinkThrottled {
+		t.Errorf("Expected a second link within a minute to be throttled, got %v", err)
+	}
+	if _, _, _, err := service.IssueMagicLink("nomail"); err != ErrUserNotFound {
+		t.Errorf("Expected users without email to be treated as unknown, got %v", err)
+	}
+
+	// Tampered signature
+	if _, err := service.RedeemMagicLink(token[:len(token)-2]+"xx", "10.0.0.1"); err != ErrInvalidMagicLink {
+		t.Errorf("Expected a tampered link to be rejected, got %v", err)
+	}
+
+	response, err := service.RedeemMagicLink(token, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("RedeemMagicLink failed: %v", err)
+	}
+	claims, err := service.ValidateToken(response.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if claims.Username != "viewer" || len(claims.Scopes) != 1 || claims.Scopes[0] != ScopeWSView {
+		t.Errorf("Expected a ws:view token for viewer, got %s %v", claims.Username, claims.Scopes)
+	}
+	if _, err := service.RedeemMagicLink(token, "10.0.0.1"); err != ErrInvalidMagicLink {
+		t.Errorf("Expected a redeemed link to be rejected, got %v", err)
+	}
+
+	// Expired
+	service.EnableMagicLinks(-time.Second, nil)
+	service.magicLinks.lastSent = nil
+	_, token, _, _ = service.IssueMagicLink("viewer")
+	if _, err := service.RedeemMagicLink(token, "10.0.0.1"); err != ErrInvalidMagicLink {
+		t.Errorf("Expected an expired link to be rejected, got %v", err)
+	}
+
+	// Deactivated after the link was sent
+	service.EnableMagicLinks(time.Minute, nil)
+	service.magicLinks.lastSent = nil
+	_, token, _, _ = service.IssueMagicLink("viewer")
+	if _, err := service.DeactivateUser("viewer"); err != nil {
+		t.Fatalf("DeactivateUser failed: %v", err)
+	}
+	if _, err := service.RedeemMagicLink(token, "10.0.0.1"); err != ErrAccountDisabled {
+		t.Errorf("Expected ErrAccountDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"embed"
+	"oculo-pilot-server/store"
+)
+
+// migrationFS holds the versioned schema migrations for every dialect
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+// Schema is the migrations for users, groups, signing keys, password
+// history and bans
+var Schema = store.Schema{
+	Component: "auth",
+	FS:        migrationFS,
+	// Versions in the schema_migrations table all subsystems shared
+	Legacy: map[int]int{
+		1: 1, 2: 2, 4: 3, 5: 4, 6: 5, 7: 6, 8: 7, 9: 8, 11: 9, 12: 10, 13: 11, 16: 12,
+	},
+	Baseline: baselineLegacySchema,
+}
+
+// baselineLegacySchema reports the migrations already reflected in a
+// database created before versioned migrations existed, so they are not
+// re-run
+func baselineLegacySchema(db *store.DB) ([]int, error) {
+	hasUsers, err := db.HasColumn("users", "id")
+	if err != nil || !hasUsers {
+		return nil, err
+	}
+
+	versions := []int{1}
+	hasFlag, err := db.HasColumn("users", "must_change_password")
+	if err != nil {
+		return nil, err
+	}
+	if hasFlag {
+		versions = append(versions, 2)
+	}
+	return versions, nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"database/sql"
+	"oculo-pilot-server/store"
+	"path/filepath"
+	"testing"
+)
+
+// TestMigrationsMatchAcrossDialects tests that every dialect ships the same versions
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	sqliteMigrations, err := Schema.Load(store.DriverSQLite)
+	if err != nil {
+		t.Fatalf("Failed to load SQLite migrations: %v", err)
+	}
+	postgresMigrations, err := Schema.Load(store.DriverPostgres)
+	if err != nil {
+		t.Fatalf("Failed to load Postgres migrations: %v", err)
+	}
+
+	if len(sqliteMigrations) != len(postgresMigrations) {
+		t.Fatalf("Expected same number of migrations, got sqlite=%d postgres=%d",
+			len(sqliteMigrations), len(postgresMigrations))
+	}
+
+	for i := range sqliteMigrations {
+		if sqliteMigrations[i].Version != postgresMigrations[i].Version ||
+			sqliteMigrations[i].Name != postgresMigrations[i].Name {
+			t.Errorf("Migration mismatch at %d: sqlite=%04d_%s postgres=%04d_%s", i,
+				sqliteMigrations[i].Version, sqliteMigrations[i].Name,
+				postgresMigrations[i].Version, postgresMigrations[i].Name)
+		}
+	}
+}
+
+// TestMigrateFreshDatabase tests that all migrations apply once
+func TestMigrateFreshDatabase(t *testing.T) {
+	db := newTestDB(t)
+
+	statuses, err := db.conn.MigrationStatus(Schema)
+	if err != nil {
+		t.Fatalf("MigrationStatus() failed: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Migration %04d_%s not applied", status.Version, status.Name)
+		}
+	}
+
+	applied, err := db.conn.Migrate(Schema)
+	if err != nil {
+		t.Fatalf("Second Migrate() failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations on second run, got %d", len(applied))
+	}
+}
+
+// TestMigrateLegacyDatabase tests upgrading a database created by the old initSchema
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(`
+	CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT UNIQUE NOT NULL,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		last_login_at DATETIME
+	);
+	INSERT INTO users (username, password_hash, created_at, updated_at)
+	VALUES ('legacy', 'hash', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+	       ('admin', 'hash', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+	`)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB() on legacy database failed: %v", err)
+	}
+	defer db.Close()
+
+	user, err := db.GetUserByUsername("legacy")
+	if err != nil {
+		t.Fatalf("Legacy user not readable after migration: %v", err)
+	}
+	if user.MustChangePassword {
+		t.Error("Expected must_change_password to default to false")
+	}
+
+	// A self-registered "admin" is not promoted; first-run setup grants
+	// the first admin
+	admin, err := db.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatalf("Legacy admin not readable after migration: %v", err)
+	}
+	if admin.Role != RoleUser {
+		t.Errorf("Expected an existing account named admin to keep role %q, got %q", RoleUser, admin.Role)
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS signing_keys (
+	id TEXT PRIMARY KEY,
+	algorithm TEXT NOT NULL,
+	key_data TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	retired_at TIMESTAMPTZ
+);
@@ -0,0 +1,2 @@
+-- Space-separated token scopes; empty uses the role defaults
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scopes TEXT NOT NULL DEFAULT '';
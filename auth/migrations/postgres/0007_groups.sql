@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user_groups (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+	group_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	PRIMARY KEY (group_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS group_robots (
+	group_id BIGINT NOT NULL,
+	robot TEXT NOT NULL,
+	PRIMARY KEY (group_id, robot)
+);
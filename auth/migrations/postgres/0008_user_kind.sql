@@ -0,0 +1,2 @@
+-- Account kind: user, or service for non-interactive accounts without password login
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'user';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_history (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(user_id, id);
@@ -0,0 +1,2 @@
+-- Space-separated CIDRs the user may log in and connect from; empty allows any
+ALTER TABLE users ADD COLUMN IF NOT EXISTS allowed_networks TEXT NOT NULL DEFAULT '';
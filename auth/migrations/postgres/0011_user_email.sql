@@ -0,0 +1,3 @@
+-- Email address for magic-link login; empty when not set
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email) WHERE email != '';
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS bans (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT,
+	network TEXT,
+	reason TEXT NOT NULL DEFAULT '',
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ
+);
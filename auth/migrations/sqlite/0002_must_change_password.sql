@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN must_change_password INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Account status: active, or pending until an admin approves a registration
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
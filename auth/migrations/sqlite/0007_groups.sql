@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user_groups (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS group_members (
+	group_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	PRIMARY KEY (group_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS group_robots (
+	group_id INTEGER NOT NULL,
+	robot TEXT NOT NULL,
+	PRIMARY KEY (group_id, robot)
+);
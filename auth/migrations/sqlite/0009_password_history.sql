@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(user_id, id);
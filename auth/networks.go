@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"net"
+	"strings"
+)
+
+// ValidateNetworks checks that every entry is a CIDR or a single IP address
+func ValidateNetworks(networks []string) error {
+	for _, network := range networks {
+		if _, err := parseNetwork(network); err != nil {
+			return ErrInvalidNetwork
+		}
+	}
+	return nil
+}
+
+// parseNetwork parses a CIDR, treating a bare IP address as a single-host
+// network
+func parseNetwork(network string) (*net.IPNet, error) {
+	if !strings.Contains(network, "/") {
+		ip := net.ParseIP(network)
+		if ip == nil {
+			return nil, ErrInvalidNetwork
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(network)
+	return ipNet, err
+}
+
+// AllowsAddr reports whether the user may log in or connect from addr (an
+// IP address, optionally with a port). Users without allowed networks are
+// not restricted.
+func (u *User) AllowsAddr(addr string) bool {
+	if len(u.AllowedNetworks) == 0 {
+		return true
+	}
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range u.AllowedNetworks {
+		if ipNet, err := parseNetwork(network); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckSource returns ErrSourceNotAllowed when the user may not connect
+// from addr
+func (s *Service) CheckSource(userID int64, addr string) error {
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.AllowsAddr(addr) {
+		return ErrSourceNotAllowed
+	}
+	return nil
+}
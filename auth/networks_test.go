@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAllowsAddr tests matching addresses against a user's networks
+func TestAllowsAddr(t *testing.T) {
+	if err := ValidateNetworks([]string{"10.0.0.0/8", "192.168.1.5", "fd00::/8"}); err != nil {
+		t.Errorf("Expected valid networks, got %v", err)
+	}
+	for _, network := range []string{"10.0.0.0/33", "ops-center", ""} {
+		if err := ValidateNetworks([]string{network}); err != ErrInvalidNetwork {
+			t.Errorf("Expected ErrInvalidNetwork for %q, got %v", network, err)
+		}
+	}
+
+	unrestricted := &User{}
+	if !unrestricted.AllowsAddr("203.0.113.7") {
+		t.Error("Expected a user without networks to be unrestricted")
+	}
+
+	user := &User{AllowedNetworks: []string{"10.1.0.0/16", "192.168.1.5", "fd00::/8"}}
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{"10.1.2.3", true},
+		{"10.1.2.3:52000", true},
+		{"192.168.1.5", true},
+		{"[fd00::1]:443", true},
+		{"10.2.0.1", false},
+		{"192.168.1.6", false},
+		{"", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := user.AllowsAddr(tt.addr); got != tt.allowed {
+			t.Errorf("AllowsAddr(%q) = %v, expected %v", tt.addr, got, tt.allowed)
+		}
+	}
+}
+
+// TestLoginAllowedNetworks tests that logins and connections from outside a
+// user's networks are refused
+func TestLoginAllowedNetworks(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+	user, _, err := service.PutUser("operator", &UserSpec{
+		Password:        "password123",
+		AllowedNetworks: []string{"10.20.0.0/16"},
+	}, Precondition{})
+	if err != nil {
+		t.Fatalf("PutUser failed: %v", err)
+	}
+	if len(user.AllowedNetworks) != 1 || user.AllowedNetworks[0] != "10.20.0.0/16" {
+		t.Fatalf("Expected networks to be stored, got %v", user.AllowedNetworks)
+	}
+
+	request := &LoginRequest{Username: "operator", Password: "password123", RemoteAddr: "198.51.100.4"}
+	if _, err := service.Login(request); err != ErrSourceNotAllowed {
+		t.Errorf("Expected ErrSourceNotAllowed, got %v", err)
+	}
+	request.RemoteAddr = "10.20.3.4"
+	if _, err := service.Login(request); err != nil {
+		t.Errorf("Expected login from the allowed network, got %v", err)
+	}
+
+	if err := service.CheckSource(user.ID, "198.51.100.4:40000"); err != ErrSourceNotAllowed {
+		t.Errorf("Expected ErrSourceNotAllowed, got %v", err)
+	}
+	if err := service.CheckSource(user.ID, "10.20.3.4:40000"); err != nil {
+		t.Errorf("Expected allowed source, got %v", err)
+	}
+
+	if _, _, err := service.PutUser("operator", &UserSpec{AllowedNetworks: []string{"ops"}}, Precondition{}); err != ErrInvalidNetwork {
+		t.Errorf("Expected ErrInvalidNetwork, got %v", err)
+	}
+
+	// Clearing the list lifts the restriction
+	if _, _, err := service.PutUser("operator", &UserSpec{}, Precondition{}); err != nil {
+		t.Fatalf("PutUser failed: %v", err)
+	}
+	request.RemoteAddr = "198.51.100.4"
+	if _, err := service.Login(request); err != nil {
+		t.Errorf("Expected unrestricted login, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+)
+
+// PairingTTL is how long a pairing code can be redeemed
+const PairingTTL = 10 * time.Minute
+
+// PairingMaxFailures is how many wrong codes, from any source, a pending
+// code survives. Per-source bans alone do not stop a guesser spread over
+// many addresses from covering all 10^6 codes within the TTL; with the cap
+// a guess hits a given code with probability at most 20 in 10^6.
+const PairingMaxFailures = 20
+
+// pairingEntry is an unredeemed pairing code and the account it creates
+type pairingEntry struct {
+	spec      ServiceAccountSpec
+	expiresAt time.Time
+	failures  int // Wrong codes submitted while this one was pending
+}
+
+// pairings holds the pending pairing codes
+type pairings struct {
+	pending map[string]pairingEntry
+}
+
+// Pairing is a short code an admin reads to a device so it can fetch its
+// own service account credentials, instead of a secret being copied onto
+// the device by hand
+type Pairing struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresIn int       `json:"expires_in"` // Seconds
+}
+
+// CreatePairingCode validates spec and returns a 6-digit code that creates
+// the service account when redeemed. Codes are kept in memory, so they can
+// only be redeemed on the instance that issued them.
+func (s *Service) CreatePairingCode(spec *ServiceAccountSpec) (*Pairing, error) {
+	if err := ValidateUsername(spec.Name); err != nil {
+		return nil, err
+	}
+	if spec.Role != "" {
+		if err := ValidateRole(spec.Role); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateScopes(spec.Scopes); err != nil {
+		return nil, err
+	}
+	if _, err := s.store.GetUserByUsername(spec.Name); err == nil {
+		return nil, ErrUsernameTaken
+	} else if err != ErrUserNotFound {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(PairingTTL)
+
+	s.pairingsMu.Lock()
+	defer s.pairingsMu.Unlock()
+	if s.pairings.pending == nil {
+		s.pairings.pending = make(map[string]pairingEntry)
+	}
+	for code, entry := range s.pairings.pending {
+		if now.After(entry.expiresAt) {
+			delete(s.pairings.pending, code)
+		} else if entry.spec.Name == spec.Name {
+			return nil, ErrUsernameTaken
+		}
+	}
+
+	var code string
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err != nil {
+			return nil, err
+		}
+		code = fmt.Sprintf("%06d", n.Int64())
+		if _, taken := s.pairings.pending[code]; !taken {
+			break
+		}
+	}
+	s.pairings.pending[code] = pairingEntry{spec: *spec, expiresAt: expiresAt}
+
+	return &Pairing{Code: code, Name: spec.Name, ExpiresAt: expiresAt, ExpiresIn: int(PairingTTL / time.Second)}, nil
+}
+
+// RedeemPairingCode consumes a pairing code, creates its service account
+// and returns the account's first secret. Each code works once. Every
+// wrong code counts against all pending codes, and a code is revoked after
+// PairingMaxFailures of them; the caller also limits guesses per source
+// (see middleware.BanList).
+func (s *Service) RedeemPairingCode(code string) (*ServiceAccountCredentials, error) {
+	s.pairingsMu.Lock()
+	entry, ok := s.pairings.pending[code]
+	delete(s.pairings.pending, code)
+	if !ok {
+		s.pairings.recordFailure()
+	}
+	s.pairingsMu.Unlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, ErrInvalidPairingCode
+	}
+
+	return s.CreateServiceAccount(&entry.spec)
+}
+
+// recordFailure counts a wrong code against every pending code and revokes
+// those that reached PairingMaxFailures; pairingsMu must be held
+func (p *pairings) recordFailure() {
+	for code, entry := range p.pending {
+		entry.failures++
+		if entry.failures >= PairingMaxFailures {
+			log.Printf("⛔ Pairing code for %s revoked after %d wrong codes", entry.spec.Name, entry.failures)
+			delete(p.pending, code)
+			continue
+		}
+		p.pending[code] = entry
+	}
+}
+
+// CancelPairing revokes the pending pairing code for a service account name
+// and reports whether there was one
+func (s *Service) CancelPairing(name string) bool {
+	s.pairingsMu.Lock()
+	defer s.pairingsMu.Unlock()
+	for code, entry := range s.pairings.pending {
+		if entry.spec.Name == name {
+			delete(s.pairings.pending, code)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPairing tests that a pairing code provisions a service account once
+func TestPairing(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+
+	pairing, err := service.CreatePairingCode(&ServiceAccountSpec{Name: "robot_7", Scopes: []string{ScopeWSControl}})
+	if err != nil {
+		t.Fatalf("CreatePairingCode failed: %v", err)
+	}
+	if len(pairing.Code) != 6 || pairing.Name != "robot_7" {
+		t.Errorf("Unexpected pairing %+v", pairing)
+	}
+	if _, err := service.CreatePairingCode(&ServiceAccountSpec{Name: "robot_7"}); err != ErrUsernameTaken {
+		t.Errorf("Expected a second pending code for the same name to be rejected, got %v", err)
+	}
+	if _, err := service.CreatePairingCode(&ServiceAccountSpec{Name: "robot_8", Scopes: []string{"fly"}}); err != ErrInvalidScope {
+		t.Errorf("Expected ErrInvalidScope, got %v", err)
+	}
+
+	credentials, err := service.RedeemPairingCode(pairing.Code)
+	if err != nil {
+		t.Fatalf("RedeemPairingCode failed: %v", err)
+	}
+	if credentials.Account.Kind != KindService || credentials.Account.Username != "robot_7" {
+		t.Errorf("Expected service account robot_7, got %+v", credentials.Account)
+	}
+	if _, err := service.ServiceToken("robot_7", credentials.Secret, nil); err != nil {
+		t.Errorf("Expected the paired secret to work, got %v", err)
+	}
+
+	if _, err := service.RedeemPairingCode(pairing.Code); err != ErrInvalidPairingCode {
+		t.Errorf("Expected a redeemed code to be rejected, got %v", err)
+	}
+	if _, err := service.CreatePairingCode(&ServiceAccountSpec{Name: "robot_7"}); err != ErrUsernameTaken {
+		t.Errorf("Expected ErrUsernameTaken for an existing account, got %v", err)
+	}
+}
+
+// TestPairingGuessing tests that wrong guesses from any source revoke a
+// pending code at the cap, and that cancelled codes stop working
+func TestPairingGuessing(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+
+	pairing, err := service.CreatePairingCode(&ServiceAccountSpec{Name: "robot_7"})
+	if err != nil {
+		t.Fatalf("CreatePairingCode failed: %v", err)
+	}
+	wrong := "not-a-code"
+	for i := 0; i < PairingMaxFailures-1; i++ {
+		if _, err := service.RedeemPairingCode(wrong); err != ErrInvalidPairingCode {
+			t.Fatalf("Expected ErrInvalidPairingCode, got %v", err)
+		}
+	}
+	if _, err := service.RedeemPairingCode(pairing.Code); err != nil {
+		t.Errorf("Expected the code to survive guesses below the cap, got %v", err)
+	}
+
+	pairing, _ = service.CreatePairingCode(&ServiceAccountSpec{Name: "robot_9"})
+	for i := 0; i < PairingMaxFailures; i++ {
+		service.RedeemPairingCode(wrong)
+	}
+	if _, err := service.RedeemPairingCode(pairing.Code); err != ErrInvalidPairingCode {
+		t.Errorf("Expected the code to be revoked at the cap, got %v", err)
+	}
+
+	pairing, _ = service.CreatePairingCode(&ServiceAccountSpec{Name: "robot_8"})
+	if !service.CancelPairing("robot_8") {
+		t.Error("Expected a pending code to cancel")
+	}
+	if _, err := service.RedeemPairingCode(pairing.Code); err != ErrInvalidPairingCode {
+		t.Errorf("Expected a cancelled code to be rejected, got %v", err)
+	}
+}
@@ -1,17 +1,108 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	// Cost for bcrypt hashing (higher = more secure but slower)
+	// Default cost for bcrypt hashing (higher = more secure but slower)
 	// 12 is a good balance between security and performance
-	bcryptCost = 12
+	defaultBcryptCost = 12
+)
+
+// Password hashing algorithms
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
 )
 
-// HashPassword generates bcrypt hash from plain text password
+// HashConfig selects the algorithm used for new password hashes
+type HashConfig struct {
+	Algorithm string
+
+	// BcryptCost is the bcrypt work factor (4-31, 0 uses the default 12)
+	BcryptCost int
+
+	// Argon2id parameters (memory in KiB)
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+// argon2Params holds Argon2id cost parameters
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	saltLen uint32
+	keyLen  uint32
+}
+
+// defaultArgon2Params follows the RFC 9106 second recommended option,
+// with parallelism reduced for small devices
+var defaultArgon2Params = argon2Params{
+	memory:  64 * 1024,
+	time:    3,
+	threads: 2,
+	saltLen: 16,
+	keyLen:  32,
+}
+
+var (
+	hashAlgorithm = AlgorithmBcrypt
+	bcryptCost    = defaultBcryptCost
+	argon2Config  = defaultArgon2Params
+)
+
+// ConfigureHashing sets the algorithm and parameters used by HashPassword.
+// Existing hashes of any supported format keep verifying.
+func ConfigureHashing(cfg HashConfig) error {
+	switch cfg.Algorithm {
+	case "", AlgorithmBcrypt:
+		hashAlgorithm = AlgorithmBcrypt
+	case AlgorithmArgon2id:
+		hashAlgorithm = AlgorithmArgon2id
+	default:
+		return fmt.Errorf("unsupported password hash algorithm: %s", cfg.Algorithm)
+	}
+
+	cost := defaultBcryptCost
+	if cfg.BcryptCost != 0 {
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		cost = cfg.BcryptCost
+	}
+	bcryptCost = cost
+
+	params := defaultArgon2Params
+	if cfg.Argon2Memory > 0 {
+		params.memory = cfg.Argon2Memory
+	}
+	if cfg.Argon2Time > 0 {
+		params.time = cfg.Argon2Time
+	}
+	if cfg.Argon2Threads > 0 {
+		params.threads = cfg.Argon2Threads
+	}
+	argon2Config = params
+
+	return nil
+}
+
+// HashPassword hashes a plain text password with the configured algorithm
 func HashPassword(password string) (string, error) {
+	if hashAlgorithm == AlgorithmArgon2id {
+		return hashArgon2id(password, argon2Config)
+	}
+
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	if err != nil {
 		return "", err
@@ -19,8 +110,94 @@ func HashPassword(password string) (string, error) {
 	return string(bytes), nil
 }
 
-// CheckPassword compares plain text password with hash
+// CheckPassword compares plain text password with hash, detecting the hash format
 func CheckPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return checkArgon2id(password, hash)
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// NeedsRehash reports whether a stored hash was produced by a different
+// algorithm or parameters than the ones currently configured
+func NeedsRehash(hash string) bool {
+	if hashAlgorithm == AlgorithmArgon2id {
+		params, _, _, err := decodeArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		return params.memory != argon2Config.memory ||
+			params.time != argon2Config.time ||
+			params.threads != argon2Config.threads
+	}
+
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != bcryptCost
+}
+
+// hashArgon2id hashes a password into the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+func hashArgon2id(password string, params argon2Params) (string, error) {
+	salt := make([]byte, params.saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, params.keyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.memory, params.time, params.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// checkArgon2id verifies a password against an Argon2id PHC string
+func checkArgon2id(password, hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1
+}
+
+// decodeArgon2id parses an Argon2id PHC string
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, err
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, err
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestForcedPasswordChange tests that a user who must change their password
+// gets a restricted token that only the password-change endpoint accepts,
+// and an unrestricted one after changing it
+func TestForcedPasswordChange(t *testing.T) {
+	useHashConfig(t, HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	user, err := db.CreateUser("admin", "admin123", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+	if err := db.SetMustChangePassword(user.ID, true); err != nil {
+		t.Fatalf("SetMustChangePassword() failed: %v", err)
+	}
+
+	response, err := service.Login(&LoginRequest{Username: "admin", Password: "admin123"})
+	if err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+	if !response.PasswordChangeRequired {
+		t.Error("Expected the login to report a required password change")
+	}
+
+	// Regular routes reject the restricted token; /api/password validates
+	// with ValidatePasswordChangeToken and accepts it
+	if _, err := service.ValidateToken(response.Token); err != ErrPasswordChangeRequired {
+		t.Errorf("Expected ErrPasswordChangeRequired, got %v", err)
+	}
+	claims, err := service.ValidatePasswordChangeToken(response.Token)
+	if err != nil {
+		t.Fatalf("Expected the password-change endpoint to accept the token, got %v", err)
+	}
+	if !claims.PasswordChange || claims.UserID != user.ID {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	changed, err := service.ChangePassword(claims.UserID, &ChangePasswordRequest{CurrentPassword: "admin123", NewPassword: "a-new-passphrase"})
+	if err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+	if changed.PasswordChangeRequired {
+		t.Error("Expected no password change to be required after changing it")
+	}
+	if claims, err := service.ValidateToken(changed.Token); err != nil || claims.PasswordChange {
+		t.Errorf("Expected an unrestricted token after the change, got %+v, %v", claims, err)
+	}
+
+	// Later logins are unrestricted too
+	response, err = service.Login(&LoginRequest{Username: "admin", Password: "a-new-passphrase"})
+	if err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+	if response.PasswordChangeRequired {
+		t.Error("Expected later logins not to require a password change")
+	}
+	if _, err := service.ValidateToken(response.Token); err != nil {
+		t.Errorf("Expected the new login's token to validate, got %v", err)
+	}
+}
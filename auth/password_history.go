@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+)
+
+// PasswordHistoryStore persists previous password hashes
+type PasswordHistoryStore interface {
+	PasswordHistory(userID int64, limit int) ([]string, error)
+	AddPasswordHistory(userID int64, passwordHash string, keep int) error
+}
+
+// DB implements PasswordHistoryStore
+var _ PasswordHistoryStore = (*DB)(nil)
+
+// passwordHistory remembers previous passwords so they cannot be reused
+type passwordHistory struct {
+	store PasswordHistoryStore
+
+	// depth is how many recent passwords, including the current one, a
+	// new password must differ from
+	depth int
+}
+
+// UsePasswordHistory rejects password changes matching any of the last
+// depth passwords, including the current one (depth <= 1 only rejects the
+// current password)
+func (s *Service) UsePasswordHistory(store PasswordHistoryStore, depth int) {
+	s.passwordHistory = passwordHistory{store: store, depth: depth}
+}
+
+// checkPasswordHistory rejects a new password that was used recently
+// (ChangePasswordRequest.Validate already rejects the current password)
+func (s *Service) checkPasswordHistory(user *User, password string) error {
+	h := s.passwordHistory
+	if h.store == nil || h.depth <= 1 {
+		return nil
+	}
+	hashes, err := h.store.PasswordHistory(user.ID, h.depth-1)
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if CheckPassword(password, hash) {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// recordPasswordHistory remembers a replaced password hash
+func (s *Service) recordPasswordHistory(userID int64, oldHash string) {
+	h := s.passwordHistory
+	if h.store == nil || h.depth <= 1 || oldHash == "" {
+		return
+	}
+	if err := h.store.AddPasswordHistory(userID, oldHash, h.depth-1); err != nil {
+		// Log error but don't fail the change
+		fmt.Printf("Failed to record password history for user %d: %v\n", userID, err)
+	}
+}
+
+// PasswordHistory returns a user's previous password hashes, newest first
+func (db *DB) PasswordHistory(userID int64, limit int) ([]string, error) {
+	rows, err := db.conn.Query(
+		"SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?",
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// AddPasswordHistory records a previous password hash and keeps only the
+// newest keep entries for the user
+func (db *DB) AddPasswordHistory(userID int64, passwordHash string, keep int) error {
+	if _, err := db.conn.Exec(
+		"INSERT INTO password_history (user_id, password_hash, created_at) VALUES (?, ?, ?)",
+		userID, passwordHash, time.Now(),
+	); err != nil {
+		return err
+	}
+
+	_, err := db.conn.Exec(
+		`DELETE FROM password_history WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?)`,
+		userID, userID, keep,
+	)
+	return err
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestPasswordHistory tests that recent passwords cannot be reused and
+// that only the configured number are remembered
+func TestPasswordHistory(t *testing.T) {
+	useHashConfig(t, HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	service.UsePasswordHistory(db, 3)
+
+	user, err := db.CreateUser("pilot", "password-one", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	change := func(current, next string) error {
+		_, err := service.ChangePassword(user.ID, &ChangePasswordRequest{CurrentPassword: current, NewPassword: next})
+		return err
+	}
+
+	if err := change("password-one", "password-two"); err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+	if err := change("password-two", "password-one"); err != ErrPasswordReused {
+		t.Errorf("Expected ErrPasswordReused for the previous password, got %v", err)
+	}
+	if err := change("password-two", "password-three"); err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+	if err := change("password-three", "password-one"); err != ErrPasswordReused {
+		t.Errorf("Expected ErrPasswordReused two changes later, got %v", err)
+	}
+	if err := change("password-three", "password-four"); err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+
+	// Only the last 3 (four, three, two) are remembered
+	if err := change("password-four", "password-one"); err != nil {
+		t.Errorf("Expected a password older than the history to be allowed, got %v", err)
+	}
+
+	hashes, err := db.PasswordHistory(user.ID, 10)
+	if err != nil {
+		t.Fatalf("PasswordHistory() failed: %v", err)
+	}
+	if len(hashes) != 2 {
+		t.Errorf("Expected 2 stored previous hashes, got %d", len(hashes))
+	}
+
+	if err := db.DeleteUser(user.ID); err != nil {
+		t.Fatalf("DeleteUser() failed: %v", err)
+	}
+	if hashes, _ := db.PasswordHistory(user.ID, 10); len(hashes) != 0 {
+		t.Errorf("Expected history to be deleted with the user, got %d", len(hashes))
+	}
+}
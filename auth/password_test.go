@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// useHashConfig applies a hashing config for the duration of a test
+func useHashConfig(t *testing.T, cfg HashConfig) {
+	t.Helper()
+
+	if err := ConfigureHashing(cfg); err != nil {
+		t.Fatalf("ConfigureHashing() failed: %v", err)
+	}
+	t.Cleanup(func() { ConfigureHashing(HashConfig{Algorithm: AlgorithmBcrypt}) })
+}
+
+// fastArgon2 keeps Argon2id tests quick
+var fastArgon2 = HashConfig{
+	Algorithm:     AlgorithmArgon2id,
+	Argon2Memory:  1024,
+	Argon2Time:    1,
+	Argon2Threads: 1,
+}
+
+// TestArgon2idRoundTrip tests hashing and verifying with Argon2id
+func TestArgon2idRoundTrip(t *testing.T) {
+	useHashConfig(t, fastArgon2)
+
+	hash, err := HashPassword("password123")
+	if err != nil {
+		t.Fatalf("HashPassword() failed: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Unexpected hash format: %s", hash)
+	}
+	if !CheckPassword("password123", hash) {
+		t.Error("Expected correct password to verify")
+	}
+	if CheckPassword("wrongpassword", hash) {
+		t.Error("Expected wrong password to fail")
+	}
+	if NeedsRehash(hash) {
+		t.Error("Hash with current parameters should not need rehash")
+	}
+}
+
+// TestNeedsRehash tests detection of outdated hash formats
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, err := HashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRehash(bcryptHash) {
+		t.Error("bcrypt hash should not need rehash while bcrypt is configured")
+	}
+
+	useHashConfig(t, fastArgon2)
+
+	if !NeedsRehash(bcryptHash) {
+		t.Error("bcrypt hash should need rehash once argon2id is configured")
+	}
+	if !CheckPassword("password123", bcryptHash) {
+		t.Error("bcrypt hash should keep verifying after switching algorithm")
+	}
+
+	argonHash, err := HashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useHashConfig(t, HashConfig{Algorithm: AlgorithmArgon2id, Argon2Memory: 2048, Argon2Time: 1, Argon2Threads: 1})
+	if !NeedsRehash(argonHash) {
+		t.Error("argon2id hash with outdated parameters should need rehash")
+	}
+}
+
+// TestLoginUpgradesHash tests transparent rehash on successful login
+func TestLoginUpgradesHash(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.CreateUser("operator", "password123", StatusActive); err != nil {
+		t.Fatal(err)
+	}
+
+	useHashConfig(t, fastArgon2)
+	service := NewService(db, "secret", time.Hour)
+
+	if _, err := service.Login(&LoginRequest{Username: "operator", Password: "password123"}); err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	user, err := db.GetUserByUsername("operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(user.PasswordHash, "$argon2id$") {
+		t.Errorf("Expected hash to be upgraded to argon2id, got %s", user.PasswordHash)
+	}
+
+	if _, err := service.Login(&LoginRequest{Username: "operator", Password: "password123"}); err != nil {
+		t.Errorf("Login() with upgraded hash failed: %v", err)
+	}
+}
+
+// TestConfigureHashingRejectsUnknownAlgorithm tests algorithm validation
+func TestConfigureHashingRejectsUnknownAlgorithm(t *testing.T) {
+	if err := ConfigureHashing(HashConfig{Algorithm: "md5"}); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
+
+// TestBcryptCostRehash tests rehashing bcrypt hashes with an outdated cost
+func TestBcryptCostRehash(t *testing.T) {
+	useHashConfig(t, HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	db := newTestDB(t)
+	if _, err := db.CreateUser("operator", "password123", StatusActive); err != nil {
+		t.Fatal(err)
+	}
+
+	useHashConfig(t, HashConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1})
+	service := NewService(db, "secret", time.Hour)
+	if _, err := service.Login(&LoginRequest{Username: "operator", Password: "password123"}); err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+
+	user, err := db.GetUserByUsername("operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, _ := bcrypt.Cost([]byte(user.PasswordHash)); cost != bcrypt.MinCost+1 {
+		t.Errorf("Expected hash to be upgraded to cost %d, got %d", bcrypt.MinCost+1, cost)
+	}
+	if NeedsRehash(user.PasswordHash) {
+		t.Error("Upgraded hash should not need rehash")
+	}
+}
+
+// TestConfigureHashingRejectsBcryptCost tests bcrypt cost validation
+func TestConfigureHashingRejectsBcryptCost(t *testing.T) {
+	for _, cost := range []int{bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if err := ConfigureHashing(HashConfig{BcryptCost: cost}); err == nil {
+			t.Errorf("Expected error for bcrypt cost %d", cost)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy defines the requirements new passwords must meet
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// Banned holds lowercased passwords that are always rejected
+	Banned map[string]bool
+}
+
+// commonPasswords is a small built-in list of frequently used passwords
+var commonPasswords = []string{
+	"12345678", "123456789", "1234567890", "password", "password1",
+	"password123", "qwerty123", "qwertyuiop", "iloveyou", "admin123",
+	"administrator", "welcome1", "letmein1", "abc12345", "11111111",
+	"00000000", "87654321", "sunshine", "football", "baseball",
+	"superman", "trustno1", "changeme", "passw0rd", "p@ssw0rd",
+}
+
+// DefaultPasswordPolicy returns the policy used when nothing is configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength: 8,
+		Banned:    make(map[string]bool),
+	}
+}
+
+var passwordPolicy = DefaultPasswordPolicy()
+
+// SetPasswordPolicy replaces the policy enforced by ValidatePassword
+func SetPasswordPolicy(policy PasswordPolicy) {
+	if policy.Banned == nil {
+		policy.Banned = make(map[string]bool)
+	}
+	passwordPolicy = policy
+}
+
+// BanCommonPasswords adds the built-in common password list to the policy
+func (p *PasswordPolicy) BanCommonPasswords() {
+	for _, password := range commonPasswords {
+		p.Banned[password] = true
+	}
+}
+
+// LoadBannedPasswords adds passwords from a file (one per line, # comments)
+func (p *PasswordPolicy) LoadBannedPasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.Banned[strings.ToLower(line)] = true
+	}
+
+	return scanner.Err()
+}
+
+// Validate checks a password against the policy
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrInvalidPassword, p.MinLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	var missing []string
+	if p.RequireUpper && !hasUpper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		missing = append(missing, "a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: must contain %s", ErrInvalidPassword, strings.Join(missing, ", "))
+	}
+
+	if p.Banned[strings.ToLower(password)] {
+		return fmt.Errorf("%w: password is too common", ErrInvalidPassword)
+	}
+
+	return nil
+}
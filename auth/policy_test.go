@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPasswordPolicyValidate tests policy rules
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		Banned:        make(map[string]bool),
+	}
+	policy.BanCommonPasswords()
+	policy.Banned["correcthorse1!a"] = true
+
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"Too short", "Ab1!", false},
+		{"Missing upper", "abcdefgh1!", false},
+		{"Missing lower", "ABCDEFGH1!", false},
+		{"Missing digit", "Abcdefghi!", false},
+		{"Missing symbol", "Abcdefghi1", false},
+		{"Banned case-insensitive", "CorrectHorse1!A", false},
+		{"Valid", "Tr0ub4dor&3x", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			if tt.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got %v", tt.password, err)
+			}
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("Expected %q to be rejected", tt.password)
+				} else if !errors.Is(err, ErrInvalidPassword) {
+					t.Errorf("Expected ErrInvalidPassword, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+// TestDefaultPasswordPolicy tests backwards-compatible defaults
+func TestDefaultPasswordPolicy(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+
+	if err := policy.Validate("short"); err == nil || err.Error() != "invalid password: must be at least 8 characters" {
+		t.Errorf("Unexpected error for short password: %v", err)
+	}
+	if err := policy.Validate("admin123"); err != nil {
+		t.Errorf("Default policy should accept 8 characters, got %v", err)
+	}
+}
+
+// TestLoadBannedPasswords tests loading a banned password file
+func TestLoadBannedPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(path, []byte("# company names\nOculoPilot2024\n\nrobotics123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := DefaultPasswordPolicy()
+	if err := policy.LoadBannedPasswords(path); err != nil {
+		t.Fatalf("LoadBannedPasswords() failed: %v", err)
+	}
+
+	if err := policy.Validate("oculopilot2024"); err == nil {
+		t.Error("Expected banned password to be rejected")
+	}
+	if err := policy.Validate("robotics123"); err == nil {
+		t.Error("Expected banned password to be rejected")
+	}
+	if len(policy.Banned) != 2 {
+		t.Errorf("Expected 2 banned passwords, got %d", len(policy.Banned))
+	}
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// UserSpec is the desired state of a user for declarative (PUT) management.
+// Applying the same spec twice leaves the user unchanged.
+type UserSpec struct {
+	// Password is required when creating; on update it is only applied when
+	// it differs from the stored password
+	Password string `json:"password,omitempty"`
+
+	Role               string   `json:"role,omitempty"`   // Defaults to RoleUser
+	Scopes             []string `json:"scopes,omitempty"` // Empty uses the role defaults
+	MustChangePassword bool     `json:"must_change_password"`
+
+	// AllowedNetworks restricts the user to these CIDRs; empty allows any
+	AllowedNetworks []string `json:"allowed_networks,omitempty"`
+
+	// Email receives magic login links; empty clears it
+	Email string `json:"email,omitempty"`
+}
+
+// Precondition guards a write against concurrent modification, mirroring
+// the HTTP If-Match and If-None-Match headers
+type Precondition struct {
+	IfMatch     string // Current ETag must be listed ("*" requires the user to exist)
+	IfNoneMatch string // "*" requires the user not to exist
+}
+
+// ETag identifies the managed state of a user. It changes whenever the
+// role, scopes, password, status, allowed networks, email or forced-change
+// flag change, but not on login.
+func (u *User) ETag() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%s|%s|%t|%s|%s|%s|%s|%s",
+		u.ID, u.Username, u.Role, joinScopes(u.Scopes), u.MustChangePassword, u.PasswordHash, u.Status, u.Kind,
+		strings.Join(u.AllowedNetworks, " "), u.Email)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// check verifies the precondition against the current user (nil if absent)
+func (p Precondition) check(user *User) error {
+	if p.IfNoneMatch != "" && user != nil && etagListed(p.IfNoneMatch, user.ETag()) {
+		return ErrPreconditionFailed
+	}
+	if p.IfMatch != "" && (user == nil || !etagListed(p.IfMatch, user.ETag())) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// etagListed reports whether an If-Match style header lists etag
+func etagListed(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUser returns a user by username
+func (s *Service) GetUser(username string) (*User, error) {
+	return s.store.GetUserByUsername(username)
+}
+
+// PutUser creates or updates a user to match spec and reports whether the
+// user was created
+func (s *Service) PutUser(username string, spec *UserSpec, pre Precondition) (*User, bool, error) {
+	if err := ValidateUsername(username); err != nil {
+		return nil, false, err
+	}
+	role := spec.Role
+	if role == "" {
+		role = RoleUser
+	}
+	if err := ValidateRole(role); err != nil {
+		return nil, false, err
+	}
+	if err := ValidateScopes(spec.Scopes); err != nil {
+		return nil, false, err
+	}
+	if err := ValidateNetworks(spec.AllowedNetworks); err != nil {
+		return nil, false, err
+	}
+	if err := ValidateEmail(spec.Email); err != nil {
+		return nil, false, err
+	}
+
+	// Serialize check-then-write so concurrent PUTs cannot both pass If-Match
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+
+	user, err := s.store.GetUserByUsername(username)
+	if err != nil && err != ErrUserNotFound {
+		return nil, false, err
+	}
+	if err == ErrUserNotFound {
+		user = nil
+	}
+	if err := pre.check(user); err != nil {
+		return nil, false, err
+	}
+
+	// Service account secrets are rotated, never set
+	if user != nil && user.Kind == KindService && (spec.Password != "" || spec.MustChangePassword) {
+		return nil, false, ErrServiceAccount
+	}
+
+	created := user == nil
+	if created {
+		if err := ValidatePassword(spec.Password); err != nil {
+			return nil, false, err
+		}
+		if user, err = s.store.CreateUser(username, spec.Password, StatusActive); err != nil {
+			return nil, false, err
+		}
+	} else if spec.Password != "" && !CheckPassword(spec.Password, user.PasswordHash) {
+		if err := s.store.UpdatePassword(user.ID, spec.Password); err != nil {
+			return nil, false, err
+		}
+		s.recordPasswordHistory(user.ID, user.PasswordHash)
+		user.MustChangePassword = false
+	}
+
+	if user.Role != role {
+		if err := s.store.SetUserRole(user.ID, role); err != nil {
+			return nil, false, err
+		}
+	}
+	if joinScopes(user.Scopes) != joinScopes(spec.Scopes) {
+		if err := s.store.SetUserScopes(user.ID, spec.Scopes); err != nil {
+			return nil, false, err
+		}
+	}
+	if strings.Join(user.AllowedNetworks, " ") != strings.Join(spec.AllowedNetworks, " ") {
+		if err := s.store.SetUserNetworks(user.ID, spec.AllowedNetworks); err != nil {
+			return nil, false, err
+		}
+	}
+	if user.Email != strings.ToLower(spec.Email) {
+		if err := s.store.SetUserEmail(user.ID, spec.Email); err != nil {
+			return nil, false, err
+		}
+	}
+	if user.MustChangePassword != spec.MustChangePassword {
+		if err := s.store.SetMustChangePassword(user.ID, spec.MustChangePassword); err != nil {
+			return nil, false, err
+		}
+	}
+
+	user, err = s.store.GetUserByID(user.ID)
+	return user, created, err
+}
+
+// DeleteUserByName deletes a user if the precondition holds
+func (s *Service) DeleteUserByName(username string, pre Precondition) error {
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+
+	user, err := s.store.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	if err := pre.check(user); err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteUser(user.ID); err != nil {
+		return err
+	}
+
+	s.activeMu.Lock()
+	delete(s.activeCache, user.ID)
+	s.activeMu.Unlock()
+
+	return s.reloadGroups()
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestPutUserIdempotent tests PUT-create, no-op re-apply and updates
+func TestPutUserIdempotent(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+
+	spec := &UserSpec{Password: "password123", Role: RoleAdmin, Scopes: []string{ScopeWSView}}
+
+	user, created, err := service.PutUser("operator", spec, Precondition{})
+	if err != nil || !created {
+		t.Fatalf("Expected create, got created=%v err=%v", created, err)
+	}
+	if user.Role != RoleAdmin || len(user.Scopes) != 1 {
+		t.Errorf("Unexpected created user: %+v", user)
+	}
+	etag := user.ETag()
+
+	// Re-applying the same spec changes nothing
+	again, created, err := service.PutUser("operator", spec, Precondition{IfMatch: etag})
+	if err != nil || created {
+		t.Fatalf("Expected no-op update, got created=%v err=%v", created, err)
+	}
+	if again.ID != user.ID || again.ETag() != etag {
+		t.Errorf("Expected stable ID and ETag, got %d %s (was %d %s)", again.ID, again.ETag(), user.ID, etag)
+	}
+
+	// Logging in does not change the ETag
+	if _, err := service.Login(&LoginRequest{Username: "operator", Password: "password123"}); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	if current, _ := service.GetUser("operator"); current.ETag() != etag {
+		t.Error("Expected login to keep the ETag")
+	}
+
+	// Changes produce a new ETag; the old one no longer matches
+	updated, _, err := service.PutUser("operator", &UserSpec{Role: RoleUser}, Precondition{IfMatch: etag})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Role != RoleUser || len(updated.Scopes) != 0 || updated.ETag() == etag {
+		t.Errorf("Unexpected updated user: %+v", updated)
+	}
+	if _, _, err := service.PutUser("operator", spec, Precondition{IfMatch: etag}); err != ErrPreconditionFailed {
+		t.Errorf("Expected ErrPreconditionFailed for stale ETag, got %v", err)
+	}
+	if _, _, err := service.PutUser("operator", spec, Precondition{IfNoneMatch: "*"}); err != ErrPreconditionFailed {
+		t.Errorf("Expected ErrPreconditionFailed for create-only PUT, got %v", err)
+	}
+}
+
+// TestPutUserValidation tests rejected specs and preconditions on missing users
+func TestPutUserValidation(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+
+	tests := []struct {
+		name string
+		spec *UserSpec
+		pre  Precondition
+		err  error
+	}{
+		{"missing password", &UserSpec{}, Precondition{}, ErrInvalidPassword},
+		{"bad role", &UserSpec{Password: "password123", Role: "root"}, Precondition{}, ErrInvalidRole},
+		{"bad scope", &UserSpec{Password: "password123", Scopes: []string{"x"}}, Precondition{}, ErrInvalidScope},
+		{"must exist", &UserSpec{Password: "password123"}, Precondition{IfMatch: "*"}, ErrPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := service.PutUser("newuser", tt.spec, tt.pre); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// TestDeleteUserByName tests conditional deletes
+func TestDeleteUserByName(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+
+	user, _, err := service.PutUser("operator", &UserSpec{Password: "password123"}, Precondition{})
+	if err != nil {
+		t.Fatalf("PutUser failed: %v", err)
+	}
+
+	if err := service.DeleteUserByName("operator", Precondition{IfMatch: `"stale"`}); err != ErrPreconditionFailed {
+		t.Errorf("Expected ErrPreconditionFailed, got %v", err)
+	}
+	if err := service.DeleteUserByName("operator", Precondition{IfMatch: user.ETag()}); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := service.DeleteUserByName("operator", Precondition{}); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
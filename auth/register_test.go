@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRegistrationPolicy tests disabled registration and the approval queue
+func TestRegistrationPolicy(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	req := &CreateUserRequest{Username: "newpilot", Password: "password123"}
+
+	service.ConfigureRegistration(false, false)
+	if _, err := service.Register(req); err != ErrRegistrationDisabled {
+		t.Fatalf("Expected ErrRegistrationDisabled, got %v", err)
+	}
+
+	service.ConfigureRegistration(true, true)
+	user, err := service.Register(req)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if user.Status != StatusPending {
+		t.Errorf("Expected pending status, got %s", user.Status)
+	}
+
+	login := &LoginRequest{Username: "newpilot", Password: "password123"}
+	if _, err := service.Login(login); err != ErrAccountPending {
+		t.Errorf("Expected ErrAccountPending, got %v", err)
+	}
+	// A wrong password does not reveal the pending state
+	if _, err := service.Login(&LoginRequest{Username: "newpilot", Password: "wrong-pass"}); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+
+	approved, err := service.ApproveUser("newpilot")
+	if err != nil || approved.Status != StatusActive {
+		t.Fatalf("ApproveUser failed: %v (%+v)", err, approved)
+	}
+	if _, err := service.Login(login); err != nil {
+		t.Errorf("Expected login after approval, got %v", err)
+	}
+
+	if _, err := service.ApproveUser("nobody"); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"time"
+)
+
+// UseKeyStore loads persisted signing keys and persists future rotations
+func (s *Service) UseKeyStore(store KeyStore) error {
+	keys, err := store.ListSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	s.keyStore = store
+	s.keys = keys
+	return nil
+}
+
+// UseSigningKey adds a key (e.g. from JWT_SIGNING_KEY_FILE) and signs new
+// tokens with it. A key that is already known, including one retired by an
+// earlier rotation, is left as it is.
+func (s *Service) UseSigningKey(key *SigningKey) error {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	for _, existing := range s.keys {
+		if existing.ID == key.ID {
+			return nil
+		}
+	}
+
+	return s.addKeyLocked(key, time.Now())
+}
+
+// RotateSigningKey generates a new key with the current algorithm (HS256
+// when only JWT_SECRET is configured) and signs new tokens with it. Tokens
+// signed with earlier keys keep validating until they expire.
+func (s *Service) RotateSigningKey() (*SigningKey, error) {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+
+	alg := "HS256"
+	if current := s.currentKeyLocked(); current != nil {
+		alg = current.Method.Alg()
+	}
+
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.addKeyLocked(key, time.Now()); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// addKeyLocked makes key the active signing key and retires the previous one
+func (s *Service) addKeyLocked(key *SigningKey, now time.Time) error {
+	key.CreatedAt = now
+	key.RetiredAt = nil
+
+	if s.keyStore != nil {
+		if err := s.keyStore.SaveSigningKey(key); err != nil {
+			return err
+		}
+	}
+
+	for _, existing := range s.keys {
+		if existing.RetiredAt != nil {
+			continue
+		}
+		if s.keyStore != nil {
+			if err := s.keyStore.RetireSigningKey(existing.ID, now); err != nil {
+				return err
+			}
+		}
+		retiredAt := now
+		existing.RetiredAt = &retiredAt
+	}
+
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// currentKey returns the key that signs new tokens (nil for JWT_SECRET)
+func (s *Service) currentKey() *SigningKey {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+	return s.currentKeyLocked()
+}
+
+// currentKeyLocked returns the newest active key
+func (s *Service) currentKeyLocked() *SigningKey {
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if s.keys[i].RetiredAt == nil {
+			return s.keys[i]
+		}
+	}
+	return nil
+}
+
+// usable reports whether a key may still verify tokens: active keys always,
+// retired keys until every token they signed has expired
+func (s *Service) usable(key *SigningKey, now time.Time) bool {
+	return key.RetiredAt == nil || now.Before(key.RetiredAt.Add(s.jwtExpiry))
+}
+
+// AcceptLegacyTokens keeps accepting tokens signed with JWT_SECRET and no
+// "kid" header after signing keys took over, instead of only until the
+// last of them has expired. Call before serving.
+func (s *Service) AcceptLegacyTokens(accept bool) {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	s.legacyTokens = accept
+}
+
+// legacySecretUsable reports whether jwtSecret may verify a token without a
+// "kid". It signed tokens until the first key was created, so like a
+// retired key it stays usable for one token lifetime after that.
+func (s *Service) legacySecretUsable(now time.Time) bool {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	if s.legacyTokens || len(s.keys) == 0 {
+		return true
+	}
+	return now.Before(s.keys[0].CreatedAt.Add(s.jwtExpiry))
+}
+
+// keyByID returns a usable key by its ID
+func (s *Service) keyByID(id string, now time.Time) *SigningKey {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.ID == id && s.usable(key, now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// SigningKeys lists the keys currently accepted for verification
+func (s *Service) SigningKeys() []SigningKeyInfo {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	now := time.Now()
+	infos := []SigningKeyInfo{}
+	for _, key := range s.keys {
+		if s.usable(key, now) {
+			infos = append(infos, key.Info())
+		}
+	}
+	return infos
+}
+
+// JWKS returns the public keys that verify tokens issued by this service
+func (s *Service) JWKS() JWKSet {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+
+	now := time.Now()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range s.keys {
+		if !key.Symmetric() && s.usable(key, now) {
+			set.Keys = append(set.Keys, key.JWK())
+		}
+	}
+	return set
+}
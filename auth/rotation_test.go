@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRotateSigningKey tests that rotation keeps earlier tokens valid and
+// survives a restart through the key store
+func TestRotateSigningKey(t *testing.T) {
+	db := newTestDB(t)
+	user, err := db.CreateUser("pilot", "password123", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	service := NewService(db, "secret", time.Hour)
+	if err := service.UseKeyStore(db); err != nil {
+		t.Fatalf("UseKeyStore() failed: %v", err)
+	}
+
+	legacyToken, _ := service.GenerateToken(user)
+
+	first, err := service.RotateSigningKey()
+	if err != nil {
+		t.Fatalf("RotateSigningKey() failed: %v", err)
+	}
+	if first.Method.Alg() != "HS256" {
+		t.Errorf("Expected HS256 rotation from JWT_SECRET, got %s", first.Method.Alg())
+	}
+	firstToken, _ := service.GenerateToken(user)
+
+	second, err := service.RotateSigningKey()
+	if err != nil {
+		t.Fatalf("RotateSigningKey() failed: %v", err)
+	}
+	secondToken, _ := service.GenerateToken(user)
+
+	if first.ID == second.ID {
+		t.Fatal("Expected a new key ID after rotation")
+	}
+
+	// A restarted service loads the same keys from the store
+	restarted := NewService(db, "secret", time.Hour)
+	if err := restarted.UseKeyStore(db); err != nil {
+		t.Fatalf("UseKeyStore() failed: %v", err)
+	}
+
+	for name, token := range map[string]string{"legacy": legacyToken, "first": firstToken, "second": secondToken} {
+		if _, err := restarted.ValidateToken(token); err != nil {
+			t.Errorf("Expected %s token to validate after restart: %v", name, err)
+		}
+	}
+
+	keys := restarted.SigningKeys()
+	if len(keys) != 2 || !keys[1].Active || keys[0].Active || keys[1].ID != second.ID {
+		t.Errorf("Unexpected key list: %+v", keys)
+	}
+
+	// New tokens are signed with the newest key
+	token, _ := restarted.GenerateToken(user)
+	if _, err := service.ValidateToken(token); err != nil {
+		t.Errorf("Expected token from restarted service to validate: %v", err)
+	}
+}
+
+// TestRetiredKeyExpires tests that retired keys stop verifying after the token expiry
+func TestRetiredKeyExpires(t *testing.T) {
+	db := newTestDB(t)
+	user, err := db.CreateUser("pilot", "password123", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	service := NewService(db, "secret", time.Hour)
+	first, err := service.RotateSigningKey()
+	if err != nil {
+		t.Fatalf("RotateSigningKey() failed: %v", err)
+	}
+	token, _ := service.GenerateToken(user)
+
+	if _, err := service.RotateSigningKey(); err != nil {
+		t.Fatalf("RotateSigningKey() failed: %v", err)
+	}
+
+	// Pretend the key was retired longer ago than the token lifetime
+	retiredAt := time.Now().Add(-2 * time.Hour)
+	first.RetiredAt = &retiredAt
+
+	if _, err := service.ValidateToken(token); err == nil {
+		t.Error("Expected token signed with expired key to be rejected")
+	}
+	if len(service.SigningKeys()) != 1 {
+		t.Errorf("Expected only the active key to be listed, got %d", len(service.SigningKeys()))
+	}
+}
+
+// TestLegacyTokenExpires tests that tokens signed with the JWT secret and no
+// kid stop validating one token lifetime after signing keys took over,
+// unless legacy tokens are explicitly accepted
+func TestLegacyTokenExpires(t *testing.T) {
+	db := newTestDB(t)
+	user, err := db.CreateUser("pilot", "password123", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	service := NewService(db, "secret", time.Hour)
+	legacyToken, _ := service.GenerateToken(user)
+	key, err := GenerateSigningKey("ES256")
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() failed: %v", err)
+	}
+	if err := service.UseSigningKey(key); err != nil {
+		t.Fatalf("UseSigningKey() failed: %v", err)
+	}
+	if _, err := service.ValidateToken(legacyToken); err != nil {
+		t.Errorf("Expected the legacy token to validate during migration: %v", err)
+	}
+
+	// Pretend the key took over longer ago than the token lifetime; a
+	// kid-less HS256 token, even a freshly forged one, is now rejected
+	key.CreatedAt = time.Now().Add(-2 * time.Hour)
+	forged, _ := NewService(db, "secret", time.Hour).GenerateToken(user)
+	if _, err := service.ValidateToken(forged); err == nil {
+		t.Error("Expected a kid-less HS256 token to be rejected after the migration period")
+	}
+
+	service.AcceptLegacyTokens(true)
+	if _, err := service.ValidateToken(forged); err != nil {
+		t.Errorf("Expected legacy tokens to validate when explicitly accepted: %v", err)
+	}
+}
+
+// TestUseSigningKeyKeepsRotation tests that a configured key file does not
+// take over again once it has been rotated out
+func TestUseSigningKeyKeepsRotation(t *testing.T) {
+	db := newTestDB(t)
+
+	fileKey, err := GenerateSigningKey("ES256")
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() failed: %v", err)
+	}
+
+	service := NewService(db, "secret", time.Hour)
+	service.UseKeyStore(db)
+	if err := service.UseSigningKey(fileKey); err != nil {
+		t.Fatalf("UseSigningKey() failed: %v", err)
+	}
+	rotated, err := service.RotateSigningKey()
+	if err != nil {
+		t.Fatalf("RotateSigningKey() failed: %v", err)
+	}
+	if rotated.Method.Alg() != "ES256" {
+		t.Errorf("Expected rotation to keep ES256, got %s", rotated.Method.Alg())
+	}
+
+	restarted := NewService(db, "secret", time.Hour)
+	restarted.UseKeyStore(db)
+	if err := restarted.UseSigningKey(fileKey); err != nil {
+		t.Fatalf("UseSigningKey() failed: %v", err)
+	}
+
+	if current := restarted.currentKey(); current == nil || current.ID != rotated.ID {
+		t.Error("Expected rotated key to remain active after restart")
+	}
+	if len(restarted.JWKS().Keys) != 2 {
+		t.Errorf("Expected both public keys in JWKS, got %d", len(restarted.JWKS().Keys))
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"strings"
+)
+
+// Token scopes
+const (
+	ScopeWSView    = "ws:view"    // connect and receive video/telemetry
+	ScopeWSControl = "ws:control" // send commands and act as a robot-side client
+	ScopeAPIAdmin  = "api:admin"  // administrative REST endpoints
+)
+
+// knownScopes lists every scope that can be granted
+var knownScopes = map[string]bool{
+	ScopeWSView:    true,
+	ScopeWSControl: true,
+	ScopeAPIAdmin:  true,
+}
+
+// DefaultScopes returns the scopes granted to a role when the user has no
+// explicit scopes
+func DefaultScopes(role string) []string {
+	if role == RoleAdmin {
+		return []string{ScopeWSView, ScopeWSControl, ScopeAPIAdmin}
+	}
+	return []string{ScopeWSView, ScopeWSControl}
+}
+
+// ValidateScopes checks that every scope is known
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return ErrInvalidScope
+		}
+	}
+	return nil
+}
+
+// AllowedScopes returns the scopes tokens for this user may carry
+func (u *User) AllowedScopes() []string {
+	if len(u.Scopes) > 0 {
+		return u.Scopes
+	}
+	return DefaultScopes(u.Role)
+}
+
+// narrowScopes returns the requested scopes if all are allowed, or every
+// allowed scope when none are requested
+func narrowScopes(allowed, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+
+	permitted := make(map[string]bool, len(allowed))
+	for _, scope := range allowed {
+		permitted[scope] = true
+	}
+
+	var scopes []string
+	seen := make(map[string]bool, len(requested))
+	for _, scope := range requested {
+		if !permitted[scope] {
+			return nil, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// HasScope reports whether the token grants a scope. Tokens issued before
+// scopes existed carry none and keep their previous unrestricted access.
+func (c *Claims) HasScope(scope string) bool {
+	if c.Scopes == nil {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// joinScopes encodes scopes for storage
+func joinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestLoginScopes tests default, narrowed and rejected token scopes
+func TestLoginScopes(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+
+	user, err := db.CreateUser("pilot", "password123", StatusActive)
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		requested []string
+		expected  []string
+		err       error
+	}{
+		{"role defaults", nil, []string{ScopeWSView, ScopeWSControl}, nil},
+		{"view only", []string{ScopeWSView}, []string{ScopeWSView}, nil},
+		{"not granted", []string{ScopeAPIAdmin}, nil, ErrInvalidScope},
+		{"unknown", []string{"ws:everything"}, nil, ErrInvalidScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := service.Login(&LoginRequest{Username: "pilot", Password: "password123", Scopes: tt.requested})
+			if err != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			claims, err := service.ValidateToken(resp.Token)
+			if err != nil {
+				t.Fatalf("ValidateToken() failed: %v", err)
+			}
+			if !reflect.DeepEqual(claims.Scopes, tt.expected) {
+				t.Errorf("Expected scopes %v, got %v", tt.expected, claims.Scopes)
+			}
+		})
+	}
+
+	// Per-user scopes replace the role defaults
+	if err := db.SetUserScopes(user.ID, []string{ScopeWSView}); err != nil {
+		t.Fatalf("SetUserScopes() failed: %v", err)
+	}
+	if _, err := service.Login(&LoginRequest{Username: "pilot", Password: "password123", Scopes: []string{ScopeWSControl}}); err != ErrInvalidScope {
+		t.Errorf("Expected ErrInvalidScope for revoked scope, got %v", err)
+	}
+	if err := db.SetUserScopes(user.ID, []string{"bogus"}); err != ErrInvalidScope {
+		t.Errorf("Expected ErrInvalidScope, got %v", err)
+	}
+}
+
+// TestDefaultScopes tests role defaults
+func TestDefaultScopes(t *testing.T) {
+	admin := &User{Role: RoleAdmin}
+	claims := &Claims{Scopes: admin.AllowedScopes()}
+	if !claims.HasScope(ScopeAPIAdmin) {
+		t.Error("Expected admin to be granted api:admin")
+	}
+
+	user := &User{Role: RoleUser}
+	claims = &Claims{Scopes: user.AllowedScopes()}
+	if claims.HasScope(ScopeAPIAdmin) {
+		t.Error("Expected user not to be granted api:admin")
+	}
+
+	legacy := &Claims{}
+	if !legacy.HasScope(ScopeWSControl) {
+		t.Error("Expected token without scopes claim to be unrestricted")
+	}
+}
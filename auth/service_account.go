@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// serviceSecretPrefix marks service account secrets so they are easy to
+// spot in configuration and secret scanners
+const serviceSecretPrefix = "sa_"
+
+// ServiceAccountSpec describes a new service account
+type ServiceAccountSpec struct {
+	Name   string   `json:"name"`
+	Role   string   `json:"role,omitempty"`   // Defaults to RoleUser
+	Scopes []string `json:"scopes,omitempty"` // Empty uses the role defaults
+}
+
+// ServiceAccountCredentials is returned when a service account secret is
+// issued. The secret is only shown once; only its hash is stored.
+type ServiceAccountCredentials struct {
+	Account *User  `json:"account"`
+	Secret  string `json:"secret"`
+}
+
+// newServiceSecret generates a random service account secret and its hash
+func newServiceSecret() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret := serviceSecretPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	hash, err := HashPassword(secret)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, hash, nil
+}
+
+// CreateServiceAccount creates a service account and returns its first
+// secret
+func (s *Service) CreateServiceAccount(spec *ServiceAccountSpec) (*ServiceAccountCredentials, error) {
+	if err := ValidateUsername(spec.Name); err != nil {
+		return nil, err
+	}
+	role := spec.Role
+	if role == "" {
+		role = RoleUser
+	}
+	if err := ValidateRole(role); err != nil {
+		return nil, err
+	}
+	if err := ValidateScopes(spec.Scopes); err != nil {
+		return nil, err
+	}
+
+	secret, hash, err := newServiceSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+
+	user, err := s.store.CreateServiceAccount(spec.Name, hash)
+	if err != nil {
+		return nil, err
+	}
+	if role != RoleUser {
+		if err := s.store.SetUserRole(user.ID, role); err != nil {
+			return nil, err
+		}
+	}
+	if len(spec.Scopes) > 0 {
+		if err := s.store.SetUserScopes(user.ID, spec.Scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	if user, err = s.store.GetUserByID(user.ID); err != nil {
+		return nil, err
+	}
+	return &ServiceAccountCredentials{Account: user, Secret: secret}, nil
+}
+
+// RotateServiceAccount replaces a service account's secret. The old secret
+// stops working immediately and tokens issued with it are rejected.
+func (s *Service) RotateServiceAccount(name string) (*ServiceAccountCredentials, error) {
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+
+	user, err := s.store.GetUserByUsername(name)
+	if err != nil {
+		return nil, err
+	}
+	if user.Kind != KindService {
+		return nil, ErrNotServiceAccount
+	}
+
+	secret, hash, err := newServiceSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SetPasswordHash(user.ID, hash); err != nil {
+		return nil, err
+	}
+	s.replaceSessions(user.ID, time.Now())
+
+	if user, err = s.store.GetUserByID(user.ID); err != nil {
+		return nil, err
+	}
+	return &ServiceAccountCredentials{Account: user, Secret: secret}, nil
+}
+
+// ListServiceAccounts returns every service account
+func (s *Service) ListServiceAccounts() ([]*User, error) {
+	users, err := s.store.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*User, 0)
+	for _, user := range users {
+		if user.Kind == KindService {
+			accounts = append(accounts, user)
+		}
+	}
+	return accounts, nil
+}
+
+// ServiceToken exchanges a service account's secret for a token, optionally
+// narrowed to the requested scopes
+func (s *Service) ServiceToken(name, secret string, scopes []string) (*LoginResponse, error) {
+	return s.BoundServiceToken(name, secret, scopes, TokenBinding{})
+}
+
+// BoundServiceToken is ServiceToken for a token bound to one client type or
+// device, e.g. so a token minted for a camera cannot open a control
+// connection
+func (s *Service) BoundServiceToken(name, secret string, scopes []string, binding TokenBinding) (*LoginResponse, error) {
+	if err := ValidateBinding(binding); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.GetUserByUsername(name)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if user.Kind != KindService || !CheckPassword(secret, user.PasswordHash) {
+		return nil, ErrInvalidCredentials
+	}
+	if user.Status == StatusDisabled {
+		return nil, ErrAccountDisabled
+	}
+	if err := s.CheckBan(user.ID, ""); err != nil {
+		return nil, err
+	}
+
+	if NeedsRehash(user.PasswordHash) {
+		s.rehashPassword(user, secret)
+	}
+
+	granted, err := narrowScopes(user.AllowedScopes(), scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.UpdateLastLogin(user.ID); err != nil {
+		// Log error but don't fail the exchange
+		fmt.Printf("Failed to update last login for service account %d: %v\n", user.ID, err)
+	}
+
+	token, err := s.GenerateBoundToken(user, granted, binding)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResponse{Token: token, User: user}, nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestServiceAccount tests creating a service account, exchanging its
+// secret for a token and rotating the secret
+func TestServiceAccount(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+
+	credentials, err := service.CreateServiceAccount(&ServiceAccountSpec{
+		Name:   "ros_bridge",
+		Scopes: []string{ScopeWSView},
+	})
+	if err != nil {
+		t.Fatalf("CreateServiceAccount failed: %v", err)
+	}
+	if credentials.Account.Kind != KindService || credentials.Secret == "" {
+		t.Fatalf("Expected service account with secret, got %+v", credentials)
+	}
+
+	// No password login, not even with the secret
+	if _, err := service.Login(&LoginRequest{Username: "ros_bridge", Password: credentials.Secret}); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials for password login, got %v", err)
+	}
+	if _, _, err := service.PutUser("ros_bridge", &UserSpec{Password: "password123"}, Precondition{}); err != ErrServiceAccount {
+		t.Errorf("Expected ErrServiceAccount when setting a password, got %v", err)
+	}
+
+	response, err := service.ServiceToken("ros_bridge", credentials.Secret, nil)
+	if err != nil {
+		t.Fatalf("ServiceToken failed: %v", err)
+	}
+	claims, err := service.ValidateToken(response.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if len(claims.Scopes) != 1 || claims.Scopes[0] != ScopeWSView {
+		t.Errorf("Expected ws:view scope, got %v", claims.Scopes)
+	}
+
+	rotated, err := service.RotateServiceAccount("ros_bridge")
+	if err != nil {
+		t.Fatalf("RotateServiceAccount failed: %v", err)
+	}
+	if _, err := service.ServiceToken("ros_bridge", credentials.Secret, nil); err != ErrInvalidCredentials {
+		t.Errorf("Expected old secret to be rejected, got %v", err)
+	}
+	if _, err := service.ServiceToken("ros_bridge", rotated.Secret, nil); err != nil {
+		t.Errorf("Expected new secret to work, got %v", err)
+	}
+
+	accounts, err := service.ListServiceAccounts()
+	if err != nil || len(accounts) != 1 || accounts[0].Username != "ros_bridge" {
+		t.Errorf("Expected one service account, got %v (%v)", accounts, err)
+	}
+}
+
+// TestBoundServiceToken tests that token bindings are validated and carried
+// in the token and its introspection
+func TestBoundServiceToken(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+	credentials, err := service.CreateServiceAccount(&ServiceAccountSpec{Name: "video_pi"})
+	if err != nil {
+		t.Fatalf("CreateServiceAccount failed: %v", err)
+	}
+
+	binding := TokenBinding{ClientType: "video", DeviceID: "video-pi-01"}
+	response, err := service.BoundServiceToken("video_pi", credentials.Secret, nil, binding)
+	if err != nil {
+		t.Fatalf("BoundServiceToken failed: %v", err)
+	}
+	claims, err := service.ValidateToken(response.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if claims.TokenBinding != binding {
+		t.Errorf("Expected binding %+v, got %+v", binding, claims.TokenBinding)
+	}
+	if info := service.Introspect(response.Token); info.ClientType != "video" || info.DeviceID != "video-pi-01" {
+		t.Errorf("Expected binding in introspection, got %+v", info)
+	}
+
+	for _, invalid := range []TokenBinding{{ClientType: "robot"}, {DeviceID: "bad id"}} {
+		if _, err := service.BoundServiceToken("video_pi", credentials.Secret, nil, invalid); err != ErrInvalidBinding {
+			t.Errorf("Expected ErrInvalidBinding for %+v, got %v", invalid, err)
+		}
+	}
+}
+
+// TestServiceTokenRejectsUsers tests that interactive users cannot use the
+// service token exchange or be rotated
+func TestServiceTokenRejectsUsers(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+	if _, err := service.Register(&CreateUserRequest{Username: "pilot", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if _, err := service.ServiceToken("pilot", "password123", nil); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := service.RotateServiceAccount("pilot"); err != ErrNotServiceAccount {
+		t.Errorf("Expected ErrNotServiceAccount, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+)
+
+// Concurrent-login policies: what happens to a user's existing sessions when
+// the same account logs in again
+const (
+	LoginPolicyAllow     = "allow"     // nothing
+	LoginPolicyWarn      = "warn"      // connected sessions are notified
+	LoginPolicyTerminate = "terminate" // older sessions are notified and ended
+)
+
+// SetLoginPolicy sets the concurrent-login policy (warn by default)
+func (s *Service) SetLoginPolicy(policy string) error {
+	switch policy {
+	case LoginPolicyAllow, LoginPolicyWarn, LoginPolicyTerminate:
+	default:
+		return fmt.Errorf("unsupported login policy: %s", policy)
+	}
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	s.loginPolicy = policy
+	return nil
+}
+
+// LoginPolicy returns the concurrent-login policy
+func (s *Service) LoginPolicy() string {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	if s.loginPolicy == "" {
+		return LoginPolicyWarn
+	}
+	return s.loginPolicy
+}
+
+// replaceSessions invalidates a user's tokens issued before the given time.
+// Token issue times have second resolution, so the cutoff is truncated to
+// keep the token being issued valid.
+func (s *Service) replaceSessions(userID int64, issuedAt time.Time) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if s.sessionsNotBefore == nil {
+		s.sessionsNotBefore = make(map[int64]time.Time)
+	}
+	s.sessionsNotBefore[userID] = issuedAt.Truncate(time.Second)
+}
+
+// checkSession rejects tokens replaced by a newer login. The cutoffs are
+// kept in memory only, so they do not survive a restart.
+func (s *Service) checkSession(claims *Claims) error {
+	s.sessionsMu.RLock()
+	notBefore, ok := s.sessionsNotBefore[claims.UserID]
+	s.sessionsMu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(notBefore) {
+		return ErrSessionReplaced
+	}
+	return nil
+}
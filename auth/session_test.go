@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLoginPolicyTerminate tests that a new login invalidates older tokens
+func TestLoginPolicyTerminate(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	if _, err := service.Register(&CreateUserRequest{Username: "operator", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	login := &LoginRequest{Username: "operator", Password: "password123"}
+
+	if service.LoginPolicy() != LoginPolicyWarn {
+		t.Errorf("Expected warn by default, got %s", service.LoginPolicy())
+	}
+	if err := service.SetLoginPolicy("kick"); err == nil {
+		t.Error("Expected unsupported policy to be rejected")
+	}
+
+	// Under warn, both tokens stay valid
+	first, _ := service.Login(login)
+	second, _ := service.Login(login)
+	for _, response := range []*LoginResponse{first, second} {
+		if _, err := service.ValidateToken(response.Token); err != nil {
+			t.Errorf("Expected token to stay valid under warn, got %v", err)
+		}
+	}
+
+	if err := service.SetLoginPolicy(LoginPolicyTerminate); err != nil {
+		t.Fatalf("SetLoginPolicy failed: %v", err)
+	}
+	// Token issue times have second resolution
+	time.Sleep(time.Second)
+	latest, err := service.Login(login)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	if _, err := service.ValidateToken(first.Token); err != ErrSessionReplaced {
+		t.Errorf("Expected ErrSessionReplaced for older token, got %v", err)
+	}
+	if _, err := service.ValidateToken(latest.Token); err != nil {
+		t.Errorf("Expected latest token to be valid, got %v", err)
+	}
+}
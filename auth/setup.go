@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// SetupRequest creates the initial admin using the one-time setup token
+type SetupRequest struct {
+	SetupToken string `json:"setup_token"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+}
+
+// EnableSetup arms first-run setup with the given token, or a random one
+// when empty, and returns the token. Setup stays available until an admin
+// account exists.
+func (s *Service) EnableSetup(token string) (string, error) {
+	if token == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		token = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+	s.setupToken = token
+	return token, nil
+}
+
+// SetupRequired reports whether no admin account exists yet
+func (s *Service) SetupRequired() (bool, error) {
+	users, err := s.store.ListUsers()
+	if err != nil {
+		return false, err
+	}
+	for _, user := range users {
+		if user.Role == RoleAdmin {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// SetupAvailable reports whether setup is armed and still required
+func (s *Service) SetupAvailable() (bool, error) {
+	s.provisionMu.Lock()
+	armed := s.setupToken != ""
+	s.provisionMu.Unlock()
+	if !armed {
+		return false, nil
+	}
+	return s.SetupRequired()
+}
+
+// Setup creates the initial admin and consumes the setup token
+func (s *Service) Setup(req *SetupRequest) (*LoginResponse, error) {
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+
+	if s.setupToken == "" {
+		return nil, ErrSetupUnavailable
+	}
+	if subtle.ConstantTimeCompare([]byte(req.SetupToken), []byte(s.setupToken)) != 1 {
+		return nil, ErrInvalidSetupToken
+	}
+
+	required, err := s.SetupRequired()
+	if err != nil {
+		return nil, err
+	}
+	if !required {
+		s.setupToken = ""
+		return nil, ErrSetupUnavailable
+	}
+
+	account := CreateUserRequest{Username: req.Username, Password: req.Password}
+	if err := account.Validate(); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.CreateUser(req.Username, req.Password, StatusActive)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SetUserRole(user.ID, RoleAdmin); err != nil {
+		return nil, err
+	}
+	user.Role = RoleAdmin
+	s.setupToken = ""
+
+	token, err := s.GenerateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{Token: token, User: user}, nil
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSetup tests creating the initial admin with the setup token
+func TestSetup(t *testing.T) {
+	db := newTestDB(t)
+	service := NewService(db, "secret", time.Hour)
+	req := &SetupRequest{SetupToken: "setup-token", Username: "operator", Password: "password123"}
+
+	// Not armed: setup is unavailable even on an empty database
+	if _, err := service.Setup(req); err != ErrSetupUnavailable {
+		t.Fatalf("Expected ErrSetupUnavailable, got %v", err)
+	}
+
+	if _, err := service.EnableSetup("setup-token"); err != nil {
+		t.Fatalf("EnableSetup failed: %v", err)
+	}
+	// A registered non-admin user does not block setup
+	if _, err := service.Register(&CreateUserRequest{Username: "pilot", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if available, err := service.SetupAvailable(); err != nil || !available {
+		t.Fatalf("Expected setup to be available, got %v (%v)", available, err)
+	}
+
+	wrong := *req
+	wrong.SetupToken = "wrong-token"
+	if _, err := service.Setup(&wrong); err != ErrInvalidSetupToken {
+		t.Errorf("Expected ErrInvalidSetupToken, got %v", err)
+	}
+
+	response, err := service.Setup(req)
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if response.User.Role != RoleAdmin || response.Token == "" {
+		t.Errorf("Expected admin user with token, got %+v", response)
+	}
+	if _, err := service.Login(&LoginRequest{Username: "operator", Password: "password123"}); err != nil {
+		t.Errorf("Expected admin login, got %v", err)
+	}
+
+	// The token is single use
+	if _, err := service.Setup(req); err != ErrSetupUnavailable {
+		t.Errorf("Expected ErrSetupUnavailable after setup, got %v", err)
+	}
+	if available, _ := service.SetupAvailable(); available {
+		t.Error("Expected setup to be unavailable after setup")
+	}
+}
+
+// TestEnableSetupGeneratesToken tests the generated setup token
+func TestEnableSetupGeneratesToken(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+
+	token, err := service.EnableSetup("")
+	if err != nil {
+		t.Fatalf("EnableSetup failed: %v", err)
+	}
+	if len(token) < 32 {
+		t.Errorf("Expected a long random token, got %q", token)
+	}
+}
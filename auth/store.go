@@ -0,0 +1,26 @@
+package auth
+
+// UserStore persists users and their credentials
+type UserStore interface {
+	CreateUser(username, password, status string) (*User, error)
+	CreateServiceAccount(username, secretHash string) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	GetUserByID(id int64) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+	UsernameExists(username string) (bool, error)
+	UpdateLastLogin(userID int64) error
+	SetMustChangePassword(userID int64, mustChange bool) error
+	SetUserRole(userID int64, role string) error
+	SetUserScopes(userID int64, scopes []string) error
+	SetUserNetworks(userID int64, networks []string) error
+	SetUserEmail(userID int64, email string) error
+	SetUserStatus(userID int64, status string) error
+	UpdatePassword(userID int64, password string) error
+	SetPasswordHash(userID int64, passwordHash string) error
+	ListUsers() ([]*User, error)
+	DeleteUser(userID int64) error
+	Close() error
+}
+
+// DB implements UserStore for every supported driver
+var _ UserStore = (*DB)(nil)
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// TicketTTL is how long a WebSocket ticket can be redeemed
+const TicketTTL = 30 * time.Second
+
+// ticketPrefix marks WebSocket tickets so they are not mistaken for JWTs
+const ticketPrefix = "wst_"
+
+// ticketEntry is an unredeemed ticket and the token claims it stands for
+type ticketEntry struct {
+	claims    *Claims
+	expiresAt time.Time
+}
+
+// Ticket is a short-lived, single-use credential for opening a WebSocket
+// connection, so long-lived JWTs stay out of URLs (and with them, proxy
+// logs and browser history)
+type Ticket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresIn int       `json:"expires_in"` // Seconds
+}
+
+// IssueTicket exchanges a valid token for a ticket carrying the same user,
+// scopes and binding. Tickets are kept in memory, so they can only be
+// redeemed on the instance that issued them.
+func (s *Service) IssueTicket(tokenString string) (*Ticket, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	ticket := ticketPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+	expiresAt := now.Add(TicketTTL)
+
+	s.ticketsMu.Lock()
+	defer s.ticketsMu.Unlock()
+	if s.tickets == nil {
+		s.tickets = make(map[string]ticketEntry)
+	}
+	for key, entry := range s.tickets {
+		if now.After(entry.expiresAt) {
+			delete(s.tickets, key)
+		}
+	}
+	s.tickets[ticket] = ticketEntry{claims: claims, expiresAt: expiresAt}
+
+	return &Ticket{Ticket: ticket, ExpiresAt: expiresAt, ExpiresIn: int(TicketTTL / time.Second)}, nil
+}
+
+// RedeemTicket consumes a ticket and returns the claims of the token it was
+// issued for. Each ticket works once; the account must still be active.
+func (s *Service) RedeemTicket(ticket string) (*Claims, error) {
+	s.ticketsMu.Lock()
+	entry, ok := s.tickets[ticket]
+	delete(s.tickets, ticket)
+	s.ticketsMu.Unlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, ErrInvalidTicket
+	}
+	if err := s.checkSession(entry.claims); err != nil {
+		return nil, err
+	}
+	if err := s.checkActive(entry.claims.UserID); err != nil {
+		return nil, err
+	}
+	return entry.claims, nil
+}
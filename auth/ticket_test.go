@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTicket tests that tickets are single-use, expire and carry the
+// token's claims
+func TestTicket(t *testing.T) {
+	service := NewService(newTestDB(t), "secret", time.Hour)
+	if _, err := service.Register(&CreateUserRequest{Username: "pilot", Password: "password123"}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	response, err := service.Login(&LoginRequest{Username: "pilot", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	if _, err := service.IssueTicket("not-a-token"); err == nil {
+		t.Error("Expected an invalid token to be refused a ticket")
+	}
+
+	ticket, err := service.IssueTicket(response.Token)
+	if err != nil {
+		t.Fatalf("IssueTicket failed: %v", err)
+	}
+	if !strings.HasPrefix(ticket.Ticket, ticketPrefix) || ticket.ExpiresIn != int(TicketTTL/time.Second) {
+		t.Errorf("Unexpected ticket: %+v", ticket)
+	}
+
+	claims, err := service.RedeemTicket(ticket.Ticket)
+	if err != nil {
+		t.Fatalf("RedeemTicket failed: %v", err)
+	}
+	if claims.Username != "pilot" {
+		t.Errorf("Expected pilot, got %s", claims.Username)
+	}
+	if _, err := service.RedeemTicket(ticket.Ticket); err != ErrInvalidTicket {
+		t.Errorf("Expected a redeemed ticket to be rejected, got %v", err)
+	}
+
+	// Expired
+	ticket, _ = service.IssueTicket(response.Token)
+	service.ticketsMu.Lock()
+	entry := service.tickets[ticket.Ticket]
+	entry.expiresAt = time.Now().Add(-time.Second)
+	service.tickets[ticket.Ticket] = entry
+	service.ticketsMu.Unlock()
+	if _, err := service.RedeemTicket(ticket.Ticket); err != ErrInvalidTicket {
+		t.Errorf("Expected an expired ticket to be rejected, got %v", err)
+	}
+
+	// Deactivated before redeeming
+	ticket, _ = service.IssueTicket(response.Token)
+	if _, err := service.DeactivateUser("pilot"); err != nil {
+		t.Fatalf("DeactivateUser failed: %v", err)
+	}
+	if _, err := service.RedeemTicket(ticket.Ticket); err != ErrAccountDisabled {
+		t.Errorf("Expected ErrAccountDisabled, got %v", err)
+	}
+}
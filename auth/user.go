@@ -2,20 +2,64 @@ package auth
 
 import (
 	"errors"
+	"net/mail"
 	"regexp"
 	"time"
 )
 
 // User represents a user in the system
 type User struct {
-	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"` // Never expose password hash
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           int64      `json:"id"`
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"-"` // Never expose password hash
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+
+	// MustChangePassword restricts the user to the password-change endpoint
+	MustChangePassword bool `json:"must_change_password"`
+
+	// Role grants access to administrative endpoints (RoleAdmin)
+	Role string `json:"role"`
+
+	// Scopes overrides the role's default token scopes when set
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Status is StatusActive, StatusPending until an admin approves, or
+	// StatusDisabled while the account is deactivated
+	Status string `json:"status"`
+
+	// Kind is KindUser, or KindService for accounts that authenticate with
+	// a rotatable secret instead of a password (see ServiceToken)
+	Kind string `json:"kind"`
+
+	// AllowedNetworks restricts logins and WebSocket connections to these
+	// CIDRs when set
+	AllowedNetworks []string `json:"allowed_networks,omitempty"`
+
+	// Email receives magic login links (see IssueMagicLink)
+	Email string `json:"email,omitempty"`
 }
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Account statuses
+const (
+	StatusActive   = "active"
+	StatusPending  = "pending"
+	StatusDisabled = "disabled"
+)
+
+// Account kinds
+const (
+	KindUser    = "user"
+	KindService = "service"
+)
+
 // CreateUserRequest represents user creation request
 type CreateUserRequest struct {
 	Username string `json:"username"`
@@ -26,21 +70,68 @@ type CreateUserRequest struct {
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// Scopes optionally narrows the issued token, e.g. ["ws:view"] for a
+	// view-only session
+	Scopes []string `json:"scopes,omitempty"`
+
+	// RemoteAddr is the client address, checked against the user's
+	// allowed networks
+	RemoteAddr string `json:"-"`
 }
 
 // LoginResponse represents login response
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token                  string `json:"token"`
+	User                   *User  `json:"user"`
+	PasswordChangeRequired bool   `json:"password_change_required,omitempty"`
+}
+
+// ChangePasswordRequest represents password change request
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
 }
 
 var (
-	ErrInvalidUsername      = errors.New("invalid username: must be 3-20 characters, alphanumeric and underscore only")
-	ErrInvalidPassword      = errors.New("invalid password: must be at least 8 characters")
-	ErrUsernameTaken        = errors.New("username already taken")
-	ErrUserNotFound         = errors.New("user not found")
-	ErrInvalidCredentials   = errors.New("invalid credentials")
-	ErrUnauthorized         = errors.New("unauthorized")
+	ErrInvalidUsername        = errors.New("invalid username: must be 3-20 characters, alphanumeric and underscore only")
+	ErrInvalidPassword        = errors.New("invalid password")
+	ErrUsernameTaken          = errors.New("username already taken")
+	ErrUserNotFound           = errors.New("user not found")
+	ErrInvalidCredentials     = errors.New("invalid credentials")
+	ErrUnauthorized           = errors.New("unauthorized")
+	ErrPasswordChangeRequired = errors.New("password change required")
+	ErrPasswordUnchanged      = errors.New("new password must differ from current password")
+	ErrPasswordReused         = errors.New("new password must differ from recently used passwords")
+	ErrInvalidRole            = errors.New("invalid role")
+	ErrInvalidScope           = errors.New("invalid scope")
+	ErrPreconditionFailed     = errors.New("precondition failed")
+	ErrInvalidStatus          = errors.New("invalid account status")
+	ErrAccountPending         = errors.New("account pending approval")
+	ErrAccountDisabled        = errors.New("account deactivated")
+	ErrRegistrationDisabled   = errors.New("registration is disabled")
+	ErrSetupUnavailable       = errors.New("setup already completed")
+	ErrInvalidSetupToken      = errors.New("invalid setup token")
+	ErrSessionReplaced        = errors.New("session replaced by a newer login")
+	ErrInvalidGroup           = errors.New("invalid group name: must be 1-64 characters, alphanumeric, dash and underscore only")
+	ErrInvalidRobot           = errors.New("invalid robot name")
+	ErrGroupNotFound          = errors.New("group not found")
+	ErrServiceAccount         = errors.New("service accounts have no password")
+	ErrNotServiceAccount      = errors.New("not a service account")
+	ErrChallengeFailed        = errors.New("registration challenge failed")
+	ErrInvalidBinding         = errors.New("invalid token binding")
+	ErrInvalidTicket          = errors.New("invalid or expired ticket")
+	ErrInvalidNetwork         = errors.New("invalid network: must be a CIDR or IP address")
+	ErrSourceNotAllowed       = errors.New("access from this address is not allowed")
+	ErrInvalidEmail           = errors.New("invalid email address")
+	ErrEmailTaken             = errors.New("email address already in use")
+	ErrMagicLinksDisabled     = errors.New("magic links are disabled")
+	ErrInvalidMagicLink       = errors.New("invalid or expired login link")
+	ErrMagicLinkThrottled     = errors.New("a login link was sent recently")
+	ErrInvalidPairingCode     = errors.New("invalid or expired pairing code")
+	ErrBanned                 = errors.New("access is banned")
+	ErrInvalidBan             = errors.New("invalid ban")
+	ErrBanNotFound            = errors.New("ban not found")
 )
 
 // Username validation regex: 3-20 characters, alphanumeric and underscore
@@ -54,10 +145,36 @@ func ValidateUsername(username string) error {
 	return nil
 }
 
-// ValidatePassword checks if password meets requirements
+// ValidateEmail checks that email is a bare address such as
+// ops@example.com; empty is allowed and clears it
+func ValidateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+// ValidatePassword checks if password meets the configured policy
 func ValidatePassword(password string) error {
-	if len(password) < 8 {
-		return ErrInvalidPassword
+	return passwordPolicy.Validate(password)
+}
+
+// ValidateRole checks that a role is known
+func ValidateRole(role string) error {
+	if role != RoleUser && role != RoleAdmin {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+// ValidateStatus checks that an account status is known
+func ValidateStatus(status string) error {
+	if status != StatusActive && status != StatusPending && status != StatusDisabled {
+		return ErrInvalidStatus
 	}
 	return nil
 }
@@ -80,3 +197,17 @@ func (r *LoginRequest) Validate() error {
 	}
 	return nil
 }
+
+// Validate validates password change request
+func (r *ChangePasswordRequest) Validate() error {
+	if r.CurrentPassword == "" {
+		return ErrInvalidCredentials
+	}
+	if err := ValidatePassword(r.NewPassword); err != nil {
+		return err
+	}
+	if r.NewPassword == r.CurrentPassword {
+		return ErrPasswordUnchanged
+	}
+	return nil
+}
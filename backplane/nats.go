@@ -0,0 +1,392 @@
+package backplane
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsMaxPayload bounds a message read from the server
+const natsMaxPayload = 64 << 20
+
+// NATS is a backplane over a NATS server. Hub channels are published as
+// subjects under prefix.hub; PublishSubject and QueueSubscribe exchange
+// messages with edge services on other subjects (see websocket.EdgeBus).
+type NATS struct {
+	addr     string
+	user     string
+	password string
+	token    string
+	prefix   string
+
+	// TLS settings when the URL uses tls:// or a CA or client certificate
+	// is configured; servers that require TLS get it either way
+	tls *tls.Config
+
+	// Connection and subscriptions by ID, replayed on reconnect (protected
+	// by mu)
+	conn    net.Conn
+	w       *bufio.Writer
+	subs    map[int]natsSubscription
+	nextSID int
+	mu      sync.Mutex
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+// natsSubscription is a subject, its optional queue group and its handler
+type natsSubscription struct {
+	subject string
+	queue   string
+	handler func(subject string, payload []byte)
+}
+
+// natsInfo is the part of the server's INFO greeting the client checks
+type natsInfo struct {
+	TLSRequired  bool `json:"tls_required"`
+	TLSAvailable bool `json:"tls_available"`
+}
+
+// NewNATS connects to the server at rawURL
+// ({nats,tls}://[user:password@|token@]host[:port]) and publishes hub
+// channels under prefix. tlsConfig (see LoadTLS) sets the CA and client
+// certificate; it may be nil, which uses the system roots when TLS is on.
+func NewNATS(rawURL, prefix string, tlsConfig *tls.Config) (*NATS, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL: %w", err)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("invalid NATS URL: unsupported scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.Scheme == "tls" && tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		if tlsConfig.MinVersion == 0 {
+			tlsConfig.MinVersion = tls.VersionTLS12
+		}
+	}
+
+	n := &NATS{addr: addr, prefix: prefix, tls: tlsConfig, subs: make(map[int]natsSubscription), closed: make(chan struct{})}
+	if u.User != nil {
+		if password, set := u.User.Password(); set {
+			n.user, n.password = u.User.Username(), password
+		} else {
+			n.token = u.User.Username()
+		}
+	}
+
+	conn, r, err := n.dial()
+	if err != nil {
+		return nil, err
+	}
+	n.conn, n.w = conn, bufio.NewWriter(conn)
+	go n.run(conn, r)
+	return n, nil
+}
+
+// dial connects, checks the greeting, upgrades to TLS when either side
+// wants it and authenticates; the server's PONG confirms CONNECT was
+// accepted
+func (n *NATS) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", n.addr, dialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	// INFO lists cluster URLs and can outgrow the default buffer
+	r := bufio.NewReaderSize(conn, 64<<10)
+
+	fail := func(err error) (net.Conn, *bufio.Reader, error) {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	line, err := readLine(r)
+	if err != nil {
+		return fail(err)
+	}
+	if !strings.HasPrefix(string(line), "INFO ") {
+		return fail(fmt.Errorf("nats: unexpected greeting %q", line))
+	}
+	var info natsInfo
+	if err := json.Unmarshal(line[len("INFO "):], &info); err != nil {
+		return fail(fmt.Errorf("nats: invalid INFO: %w", err))
+	}
+	// The server sends INFO in the clear and then expects the handshake;
+	// credentials are only sent once the connection is encrypted
+	config := n.tls
+	if config == nil && info.TLSRequired {
+		host, _, _ := net.SplitHostPort(n.addr)
+		config = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	if config != nil {
+		if !info.TLSRequired && !info.TLSAvailable {
+			return fail(errors.New("nats: TLS is configured but the server does not offer it"))
+		}
+		secure := tls.Client(conn, config)
+		conn = secure
+		if err := secure.Handshake(); err != nil {
+			return fail(fmt.Errorf("nats: TLS handshake: %w", err))
+		}
+		r = bufio.NewReaderSize(conn, 64<<10)
+	}
+
+	connect, _ := json.Marshal(map[string]interface{}{
+		"verbose":      false,
+		"pedantic":     false,
+		"name":         "oculo-pilot-server",
+		"lang":         "go",
+		"protocol":     1,
+		"tls_required": config != nil,
+		"user":         n.user,
+		"pass":         n.password,
+		"auth_token":   n.token,
+	})
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return fail(err)
+	}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return fail(err)
+		}
+		switch {
+		case string(line) == "PONG":
+			conn.SetDeadline(time.Time{})
+			return conn, r, nil
+		case strings.HasPrefix(string(line), "-ERR"):
+			return fail(fmt.Errorf("nats: %s", strings.TrimSpace(string(line[len("-ERR"):]))))
+		}
+	}
+}
+
+// run reads from the connection, reconnecting with backoff and replaying
+// subscriptions when it drops, until Close
+func (n *NATS) run(conn net.Conn, r *bufio.Reader) {
+	for {
+		err := n.read(r)
+		conn.Close()
+		select {
+		case <-n.closed:
+			return
+		default:
+		}
+		log.Printf("🛰️  NATS connection lost: %v", err)
+
+		n.mu.Lock()
+		n.conn, n.w = nil, nil
+		n.mu.Unlock()
+
+		delay := time.Second
+		for {
+			select {
+			case <-n.closed:
+				return
+			case <-time.After(delay):
+			}
+			if conn, r, err = n.dial(); err == nil && n.resubscribe(conn) == nil {
+				log.Printf("🛰️  NATS connection restored")
+				break
+			}
+			if conn != nil {
+				conn.Close()
+			}
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+		}
+	}
+}
+
+// resubscribe makes conn current and replays every subscription on it
+func (n *NATS) resubscribe(conn net.Conn) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	select {
+	case <-n.closed:
+		return ErrClosed
+	default:
+	}
+	w := bufio.NewWriter(conn)
+	for sid, sub := range n.subs {
+		writeSub(w, sid, sub)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	n.conn, n.w = conn, w
+	return nil
+}
+
+// read dispatches messages and answers pings until the connection fails
+func (n *NATS) read(r *bufio.Reader) error {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			if len(fields) != 4 && len(fields) != 5 {
+				return fmt.Errorf("nats: malformed MSG %q", line)
+			}
+			sid, _ := strconv.Atoi(fields[2])
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 || size > natsMaxPayload {
+				return fmt.Errorf("nats: invalid MSG size %q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return err
+			}
+
+			n.mu.Lock()
+			sub, ok := n.subs[sid]
+			n.mu.Unlock()
+			if ok {
+				sub.handler(fields[1], payload[:size])
+			}
+		case "PING":
+			n.write("PONG\r\n")
+		case "-ERR":
+			log.Printf("🛰️  NATS error: %s", strings.TrimSpace(string(line[len("-ERR"):])))
+		}
+	}
+}
+
+// write sends a protocol line on the current connection
+func (n *NATS) write(format string, args ...interface{}) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.w == nil {
+		return errors.New("nats: not connected")
+	}
+	fmt.Fprintf(n.w, format, args...)
+	return n.w.Flush()
+}
+
+// Publish sends payload on the hub subject for channel
+func (n *NATS) Publish(channel string, payload []byte) error {
+	return n.PublishSubject(n.prefix+".hub."+channel, payload)
+}
+
+// Subscribe receives every hub channel until Close
+func (n *NATS) Subscribe(handler func(channel string, payload []byte)) error {
+	hub := n.prefix + ".hub."
+	return n.subscribe(natsSubscription{subject: hub + ">", handler: func(subject string, payload []byte) {
+		handler(strings.TrimPrefix(subject, hub), payload)
+	}})
+}
+
+// PublishSubject sends payload on a subject
+func (n *NATS) PublishSubject(subject string, payload []byte) error {
+	select {
+	case <-n.closed:
+		return ErrClosed
+	default:
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.w == nil {
+		return errors.New("nats: not connected")
+	}
+	fmt.Fprintf(n.w, "PUB %s %d\r\n", subject, len(payload))
+	n.w.Write(payload)
+	n.w.WriteString("\r\n")
+	return n.w.Flush()
+}
+
+// QueueSubscribe calls handler for messages on subject, which may use the
+// * and > wildcards, until Close. Each message goes to only one of the
+// subscribers sharing a queue group, so replicas do not all handle it.
+func (n *NATS) QueueSubscribe(subject, queue string, handler func(subject string, payload []byte)) error {
+	return n.subscribe(natsSubscription{subject: subject, queue: queue, handler: handler})
+}
+
+// subscribe registers a subscription and sends it unless disconnected
+func (n *NATS) subscribe(sub natsSubscription) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.nextSID++
+	n.subs[n.nextSID] = sub
+	if n.w == nil {
+		// Sent when the connection is restored
+		return nil
+	}
+	writeSub(n.w, n.nextSID, sub)
+	return n.w.Flush()
+}
+
+// writeSub writes the SUB line of a subscription
+func writeSub(w *bufio.Writer, sid int, sub natsSubscription) {
+	if sub.queue != "" {
+		fmt.Fprintf(w, "SUB %s %s %d\r\n", sub.subject, sub.queue, sid)
+		return
+	}
+	fmt.Fprintf(w, "SUB %s %d\r\n", sub.subject, sid)
+}
+
+// Close closes the connection and stops reconnecting
+func (n *NATS) Close() error {
+	n.once.Do(func() {
+		n.mu.Lock()
+		close(n.closed)
+		if n.conn != nil {
+			n.conn.Close()
+			n.conn, n.w = nil, nil
+		}
+		n.mu.Unlock()
+	})
+	return nil
+}
+
+// String describes the connection for logs, without credentials
+func (n *NATS) String() string {
+	scheme := "nats://"
+	if n.tls != nil {
+		scheme = "tls://"
+	}
+	return scheme + n.addr + " (prefix " + strconv.Quote(n.prefix) + ")"
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,312 @@
+package backplane
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNATS is a NATS server that understands CONNECT with a token, PING,
+// PUB and SUB with wildcards and queue groups, optionally requiring TLS
+type fakeNATS struct {
+	listener net.Listener
+	token    string
+	tls      *tls.Config
+
+	mu    sync.Mutex
+	subs  []fakeNATSSub
+	conns []net.Conn
+}
+
+// fakeNATSSub is one subscription of a connection
+type fakeNATSSub struct {
+	w       *bufio.Writer
+	subject string
+	queue   string
+	sid     string
+}
+
+func newFakeNATS(t *testing.T, token string, config *tls.Config) *fakeNATS {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	f := &fakeNATS{listener: listener, token: token, tls: config}
+	t.Cleanup(func() { listener.Close(); f.dropConnections() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns = append(f.conns, conn)
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// dropConnections closes every client connection, as a server restart would
+func (f *fakeNATS) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+	f.subs = nil
+}
+
+func (f *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	if f.tls != nil {
+		conn.Write([]byte(`INFO {"server_id":"fake","max_payload":1048576,"tls_required":true}` + "\r\n"))
+		conn = tls.Server(conn, f.tls)
+	} else {
+		conn.Write([]byte(`INFO {"server_id":"fake","max_payload":1048576}` + "\r\n"))
+	}
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			continue
+		}
+
+		f.mu.Lock()
+		switch fields[0] {
+		case "CONNECT":
+			var options struct {
+				AuthToken string `json:"auth_token"`
+			}
+			json.Unmarshal(line[len("CONNECT "):], &options)
+			if options.AuthToken != f.token {
+				w.WriteString("-ERR 'Authorization Violation'\r\n")
+				w.Flush()
+				f.mu.Unlock()
+				return
+			}
+		case "PING":
+			w.WriteString("PONG\r\n")
+		case "SUB":
+			sub := fakeNATSSub{w: w, subject: fields[1], sid: fields[len(fields)-1]}
+			if len(fields) == 4 {
+				sub.queue = fields[2]
+			}
+			f.subs = append(f.subs, sub)
+		case "PUB":
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				f.mu.Unlock()
+				return
+			}
+			queues := make(map[string]bool)
+			for _, sub := range f.subs {
+				if !subjectMatches(sub.subject, fields[1]) || queues[sub.queue] {
+					continue
+				}
+				if sub.queue != "" {
+					queues[sub.queue] = true
+				}
+				fmt.Fprintf(sub.w, "MSG %s %s %d\r\n%s\r\n", fields[1], sub.sid, size, payload[:size])
+				sub.w.Flush()
+			}
+		}
+		w.Flush()
+		f.mu.Unlock()
+	}
+}
+
+// subjectMatches reports whether subject matches a pattern with * and >
+func subjectMatches(pattern, subject string) bool {
+	patternTokens, tokens := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(tokens) > i
+		}
+		if i >= len(tokens) || (token != "*" && token != tokens[i]) {
+			return false
+		}
+	}
+	return len(tokens) == len(patternTokens)
+}
+
+// TestNATSPubSub tests hub channels and subjects through a NATS server,
+// including authentication, prefix isolation, queue groups and reconnecting
+func TestNATSPubSub(t *testing.T) {
+	server := newFakeNATS(t, "s3cret", nil)
+	addr := server.listener.Addr().String()
+
+	if _, err := NewNATS("nats://wrong@"+addr, "pilot", nil); err == nil {
+		t.Error("Expected a wrong token to be rejected")
+	}
+	if _, err := NewNATS("tls://s3cret@"+addr, "pilot", nil); err == nil {
+		t.Error("Expected tls:// to be rejected by a server without TLS")
+	}
+	if _, err := NewNATS("redis://"+addr, "pilot", nil); err == nil {
+		t.Error("Expected a non-nats URL to be rejected")
+	}
+
+	a, err := NewNATS("nats://s3cret@"+addr, "pilot", nil)
+	if err != nil {
+		t.Fatalf("NewNATS failed: %v", err)
+	}
+	defer a.Close()
+	b, err := NewNATS("nats://s3cret@"+addr, "pilot", nil)
+	if err != nil {
+		t.Fatalf("NewNATS failed: %v", err)
+	}
+	defer b.Close()
+	other, err := NewNATS("nats://s3cret@"+addr, "staging", nil)
+	if err != nil {
+		t.Fatalf("NewNATS failed: %v", err)
+	}
+	defer other.Close()
+
+	received := make(chan string, 10)
+	if err := b.Subscribe(func(channel string, payload []byte) {
+		received <- channel + "=" + string(payload)
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	queued := make(chan string, 10)
+	for _, n := range []*NATS{a, b} {
+		if err := n.QueueSubscribe("pilot.telemetry.>", "workers", func(subject string, payload []byte) {
+			queued <- subject
+		}); err != nil {
+			t.Fatalf("QueueSubscribe failed: %v", err)
+		}
+	}
+
+	if err := other.Publish("lab.web", []byte("ignored")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	// The server handles each connection separately, so a subscription may
+	// not be in place yet when the first message is published
+	publishUntil(t, received, `lab.web={"n":1}`, func() error {
+		return a.Publish("lab.web", []byte(`{"n":1}`))
+	})
+	publishUntil(t, queued, "pilot.telemetry.lab.location_update", func() error {
+		return other.PublishSubject("pilot.telemetry.lab.location_update", []byte("{}"))
+	})
+	select {
+	case got := <-queued:
+		t.Errorf("Expected one queue group member to receive it, got a second %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Subscriptions come back after the server drops the connections
+	server.dropConnections()
+	publishUntil(t, received, "lab.web=again", func() error {
+		a.Publish("lab.web", []byte("again"))
+		return nil
+	})
+}
+
+// publishUntil publishes until want arrives on received, failing after a
+// few seconds or on any other message
+func publishUntil(t *testing.T, received chan string, want string, publish func() error) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := publish(); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("Expected %q, got %q", want, got)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+}
+
+// TestNATSTLS tests that the client upgrades to TLS when the server
+// requires it and checks the server certificate
+func TestNATSTLS(t *testing.T) {
+	cert, roots := testCertificate(t)
+	server := newFakeNATS(t, "s3cret", &tls.Config{Certificates: []tls.Certificate{cert}})
+	addr := server.listener.Addr().String()
+
+	if _, err := NewNATS("nats://s3cret@"+addr, "pilot", nil); err == nil {
+		t.Error("Expected an untrusted server certificate to be rejected")
+	}
+
+	n, err := NewNATS("nats://s3cret@"+addr, "pilot", &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("NewNATS over TLS failed: %v", err)
+	}
+	defer n.Close()
+	if !strings.HasPrefix(n.String(), "tls://") {
+		t.Errorf("Expected a tls:// description, got %s", n)
+	}
+
+	received := make(chan string, 10)
+	if err := n.Subscribe(func(channel string, payload []byte) {
+		received <- channel + "=" + string(payload)
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	publishUntil(t, received, "lab.web=secure", func() error {
+		return n.Publish("lab.web", []byte("secure"))
+	})
+}
+
+// testCertificate creates a self-signed certificate for 127.0.0.1 and a
+// pool that trusts it
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake-nats"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
@@ -0,0 +1,183 @@
+// Package backplane connects the WebSocket hubs of several server instances
+// through a message broker, so one deployment can run more than one replica
+// behind a load balancer (see websocket.Backplane).
+package backplane
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dialTimeout bounds connecting and authenticating to the broker
+const dialTimeout = 5 * time.Second
+
+// maxReconnectDelay caps the backoff between subscription reconnects
+const maxReconnectDelay = 30 * time.Second
+
+// ErrClosed is returned after Close
+var ErrClosed = errors.New("backplane closed")
+
+// Redis is a backplane over Redis pub/sub. Channels are prefixed, so
+// several deployments can share one server.
+type Redis struct {
+	client *redis.Client
+	prefix string
+
+	// Subscription, closed by Close to stop the reader (protected by subMu)
+	sub    *redis.PubSub
+	subMu  sync.Mutex
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewRedis connects to the server at rawURL
+// (redis[s]://[user:password@]host[:port]) and publishes on channels under
+// prefix. rediss:// connects over TLS; tlsConfig (see LoadTLS) sets the CA
+// and client certificate and turns TLS on for redis:// as well.
+func NewRedis(rawURL, prefix string, tlsConfig *tls.Config) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid Redis URL: unsupported scheme %q", u.Scheme)
+	}
+	options, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	if _, set := u.User.Password(); u.User != nil && !set {
+		// redis://secret@host is a password without a user
+		options.Username, options.Password = "", options.Username
+	}
+	if tlsConfig != nil {
+		options.TLSConfig = tlsConfig.Clone()
+		if options.TLSConfig.ServerName == "" {
+			options.TLSConfig.ServerName = u.Hostname()
+		}
+	}
+	// RESP2 with AUTH also works with servers older than Redis 6
+	options.Protocol = 2
+	options.DialTimeout = dialTimeout
+	options.MaxRetryBackoff = maxReconnectDelay
+
+	r := &Redis{client: redis.NewClient(options), prefix: prefix + ":", closed: make(chan struct{})}
+
+	// Fail at startup rather than on the first broadcast
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		r.client.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Publish sends payload on prefix:channel; the client reconnects if the
+// connection was lost
+func (r *Redis) Publish(channel string, payload []byte) error {
+	select {
+	case <-r.closed:
+		return ErrClosed
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	return r.client.Publish(ctx, r.prefix+channel, payload).Err()
+}
+
+// Subscribe receives every channel under the prefix until Close. The
+// first subscription is confirmed before it returns; when the connection
+// drops the client resubscribes, retried with backoff.
+func (r *Redis) Subscribe(handler func(channel string, payload []byte)) error {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	select {
+	case <-r.closed:
+		return ErrClosed
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	sub := r.client.PSubscribe(ctx, r.prefix+"*")
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return err
+	}
+	r.sub = sub
+
+	go r.receive(sub, handler)
+	return nil
+}
+
+// receive reads messages until Close, waiting with backoff while the
+// subscription cannot be restored
+func (r *Redis) receive(sub *redis.PubSub, handler func(channel string, payload []byte)) {
+	delay := time.Second
+	lost := false
+	for {
+		message, err := sub.ReceiveMessage(context.Background())
+		if err == nil {
+			if lost {
+				log.Printf("🛰️  Redis subscription restored")
+				lost, delay = false, time.Second
+			}
+			handler(strings.TrimPrefix(message.Channel, r.prefix), []byte(message.Payload))
+			continue
+		}
+
+		select {
+		case <-r.closed:
+			return
+		default:
+		}
+		if !lost {
+			log.Printf("🛰️  Redis subscription lost: %v", err)
+			lost = true
+		}
+		select {
+		case <-r.closed:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// Close stops the subscription and closes the connections
+func (r *Redis) Close() error {
+	r.once.Do(func() {
+		r.subMu.Lock()
+		close(r.closed)
+		if r.sub != nil {
+			r.sub.Close()
+		}
+		r.subMu.Unlock()
+		r.client.Close()
+	})
+	return nil
+}
+
+// String describes the backplane for logs, without credentials
+func (r *Redis) String() string {
+	options := r.client.Options()
+	scheme := "redis://"
+	if options.TLSConfig != nil {
+		scheme = "rediss://"
+	}
+	return scheme + options.Addr + " (prefix " + strconv.Quote(strings.TrimSuffix(r.prefix, ":")) + ")"
+}
@@ -0,0 +1,248 @@
+package backplane
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a Redis 5 server (no HELLO) that understands AUTH, PING,
+// PUBLISH and PSUBSCRIBE with trailing-* patterns
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu          sync.Mutex
+	subscribers map[*bufio.Writer]string // Pattern prefix by connection
+	conns       []net.Conn
+}
+
+func newFakeRedis(t *testing.T, password string, config *tls.Config) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+	}
+	f := &fakeRedis{listener: listener, password: password, subscribers: make(map[*bufio.Writer]string)}
+	t.Cleanup(func() { listener.Close(); f.dropConnections() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns = append(f.conns, conn)
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// dropConnections closes every client connection, as a server restart would
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+	f.subscribers = make(map[*bufio.Writer]string)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil || len(args) == 0 {
+			return
+		}
+
+		f.mu.Lock()
+		switch {
+		case strings.EqualFold(args[0], "AUTH"):
+			if args[len(args)-1] == f.password {
+				authed = true
+				w.WriteString("+OK\r\n")
+			} else {
+				w.WriteString("-WRONGPASS invalid password\r\n")
+			}
+		case strings.EqualFold(args[0], "HELLO"):
+			w.WriteString("-ERR unknown command 'HELLO'\r\n")
+		case !authed:
+			w.WriteString("-NOAUTH Authentication required.\r\n")
+		case strings.EqualFold(args[0], "PING"):
+			w.WriteString("+PONG\r\n")
+		case strings.EqualFold(args[0], "PSUBSCRIBE"):
+			f.subscribers[w] = strings.TrimSuffix(args[1], "*")
+			w.WriteString("*3\r\n")
+			writeBulk(w, "psubscribe", args[1])
+			w.WriteString(":1\r\n")
+		case strings.EqualFold(args[0], "PUBLISH"):
+			delivered := 0
+			for sub, prefix := range f.subscribers {
+				if strings.HasPrefix(args[1], prefix) {
+					sub.WriteString("*4\r\n")
+					writeBulk(sub, "pmessage", prefix+"*", args[1], args[2])
+					sub.Flush()
+					delivered++
+				}
+			}
+			w.WriteString(":" + strconv.Itoa(delivered) + "\r\n")
+		default:
+			w.WriteString("-ERR unknown command\r\n")
+		}
+		w.Flush()
+		f.mu.Unlock()
+	}
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+	n, _ := strconv.Atoi(string(line[1:]))
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if len(line) == 0 || line[0] != '$' || err != nil {
+			return nil, fmt.Errorf("unexpected argument %q", line)
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args = append(args, string(arg[:size]))
+	}
+	return args, nil
+}
+
+// writeBulk writes items as RESP bulk strings
+func writeBulk(w *bufio.Writer, items ...string) {
+	for _, item := range items {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(item), item)
+	}
+}
+
+// TestRedisPubSub tests publishing and receiving through a Redis server,
+// including authentication, prefix isolation and reconnecting
+func TestRedisPubSub(t *testing.T) {
+	server := newFakeRedis(t, "s3cret", nil)
+	addr := server.listener.Addr().String()
+
+	if _, err := NewRedis("redis://:wrong@"+addr, "pilot", nil); err == nil {
+		t.Error("Expected a wrong password to be rejected")
+	}
+	if _, err := NewRedis("http://"+addr, "pilot", nil); err == nil {
+		t.Error("Expected a non-redis URL to be rejected")
+	}
+
+	a, err := NewRedis("redis://:s3cret@"+addr, "pilot", nil)
+	if err != nil {
+		t.Fatalf("NewRedis failed: %v", err)
+	}
+	defer a.Close()
+	b, err := NewRedis("redis://s3cret@"+addr, "pilot", nil)
+	if err != nil {
+		t.Fatalf("NewRedis failed: %v", err)
+	}
+	defer b.Close()
+	other, err := NewRedis("redis://:s3cret@"+addr, "staging", nil)
+	if err != nil {
+		t.Fatalf("NewRedis failed: %v", err)
+	}
+	defer other.Close()
+
+	received := make(chan string, 10)
+	if err := b.Subscribe(func(channel string, payload []byte) {
+		received <- channel + "=" + string(payload)
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := other.Publish("lab.web", []byte("ignored")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := a.Publish("lab.web", []byte(`{"n":1}`)); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got != `lab.web={"n":1}` {
+			t.Errorf("Expected the pilot message without prefix, got %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the message")
+	}
+
+	// Both connections come back after the server drops them
+	server.dropConnections()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := a.Publish("lab.web", []byte("again")); err != nil {
+			t.Fatalf("Publish after reconnect failed: %v", err)
+		}
+		select {
+		case got := <-received:
+			if got != "lab.web=again" {
+				t.Errorf("Unexpected message %q", got)
+			}
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Subscription was not restored")
+		}
+	}
+}
+
+// TestRedisTLS tests rediss:// with a configured CA
+func TestRedisTLS(t *testing.T) {
+	cert, roots := testCertificate(t)
+	server := newFakeRedis(t, "s3cret", &tls.Config{Certificates: []tls.Certificate{cert}})
+	addr := server.listener.Addr().String()
+
+	if _, err := NewRedis("rediss://:s3cret@"+addr, "pilot", nil); err == nil {
+		t.Error("Expected an untrusted server certificate to be rejected")
+	}
+	r, err := NewRedis("rediss://:s3cret@"+addr, "pilot", &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("NewRedis over TLS failed: %v", err)
+	}
+	defer r.Close()
+	if !strings.HasPrefix(r.String(), "rediss://") {
+		t.Errorf("Expected a rediss:// description, got %s", r)
+	}
+
+	received := make(chan string, 10)
+	if err := r.Subscribe(func(channel string, payload []byte) {
+		received <- channel + "=" + string(payload)
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	publishUntil(t, received, "lab.web=secure", func() error {
+		return r.Publish("lab.web", []byte("secure"))
+	})
+}
@@ -0,0 +1,130 @@
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"oculo-pilot-server/auth"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a declarative description of server state applied at startup.
+// Unknown sections are rejected so typos do not silently do nothing.
+type File struct {
+	Users []User `yaml:"users"`
+}
+
+// User is a user to provision
+type User struct {
+	Username string `yaml:"username"`
+
+	// Password is the initial password. ${VAR} references are expanded from
+	// the environment so the file itself need not contain secrets.
+	Password string `yaml:"password"`
+
+	Role               string   `yaml:"role"`   // Defaults to user
+	Scopes             []string `yaml:"scopes"` // Empty uses the role defaults
+	MustChangePassword bool     `yaml:"must_change_password"`
+
+	// AllowedNetworks restricts the user to these CIDRs; empty allows any
+	AllowedNetworks []string `yaml:"allowed_networks"`
+
+	// Email receives magic login links
+	Email string `yaml:"email"`
+}
+
+// Provisioner creates and updates users (implemented by auth.Service)
+type Provisioner interface {
+	GetUser(username string) (*auth.User, error)
+	PutUser(username string, spec *auth.UserSpec, pre auth.Precondition) (*auth.User, bool, error)
+}
+
+// Result lists what Apply changed
+type Result struct {
+	Created   []string
+	Updated   []string
+	Unchanged []string
+}
+
+// Load reads and validates a bootstrap file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a bootstrap document
+func Parse(data []byte) (*File, error) {
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap file: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Users {
+		user := &file.Users[i]
+		if user.Username == "" {
+			return nil, fmt.Errorf("bootstrap user %d has no username", i+1)
+		}
+		if seen[user.Username] {
+			return nil, fmt.Errorf("duplicate bootstrap user %s", user.Username)
+		}
+		seen[user.Username] = true
+		user.Password = os.ExpandEnv(user.Password)
+	}
+
+	return &file, nil
+}
+
+// Apply creates missing users and reconciles the role, scopes, allowed
+// networks and email of existing ones. Passwords and must_change_password only apply
+// when a user is created, so credentials changed at runtime survive restarts.
+func (f *File) Apply(p Provisioner) (Result, error) {
+	var result Result
+
+	for _, user := range f.Users {
+		spec := &auth.UserSpec{
+			Password:           user.Password,
+			Role:               user.Role,
+			Scopes:             user.Scopes,
+			MustChangePassword: user.MustChangePassword,
+			AllowedNetworks:    user.AllowedNetworks,
+			Email:              user.Email,
+		}
+
+		existing, err := p.GetUser(user.Username)
+		if err != nil && err != auth.ErrUserNotFound {
+			return result, err
+		}
+		pre := auth.Precondition{IfNoneMatch: "*"}
+		if existing != nil {
+			spec.Password = ""
+			spec.MustChangePassword = existing.MustChangePassword
+			pre = auth.Precondition{IfMatch: existing.ETag()}
+		}
+
+		applied, created, err := p.PutUser(user.Username, spec, pre)
+		if err != nil {
+			return result, fmt.Errorf("bootstrap user %s: %v", user.Username, err)
+		}
+
+		switch {
+		case created:
+			result.Created = append(result.Created, user.Username)
+			log.Printf("👤 Bootstrap created user %s (role=%s)", applied.Username, applied.Role)
+		case applied.ETag() != existing.ETag():
+			result.Updated = append(result.Updated, user.Username)
+			log.Printf("👤 Bootstrap updated user %s (role=%s)", applied.Username, applied.Role)
+		default:
+			result.Unchanged = append(result.Unchanged, user.Username)
+		}
+	}
+
+	return result, nil
+}
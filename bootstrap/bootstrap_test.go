@@ -0,0 +1,107 @@
+package bootstrap
+
+import (
+	"oculo-pilot-server/auth"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestService creates an auth service backed by a temporary database
+func newTestService(t *testing.T) *auth.Service {
+	t.Helper()
+
+	db, err := auth.NewDB(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("NewDB() failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return auth.NewService(db, "secret", time.Hour)
+}
+
+// TestParse tests env expansion and validation
+func TestParse(t *testing.T) {
+	t.Setenv("OPS_PASSWORD", "from-env-123")
+
+	file, err := Parse([]byte(`
+users:
+  - username: ops
+    password: ${OPS_PASSWORD}
+    role: admin
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if file.Users[0].Password != "from-env-123" {
+		t.Errorf("Expected expanded password, got %q", file.Users[0].Password)
+	}
+
+	invalid := map[string]string{
+		"unknown section": "robots:\n  - name: r1\n",
+		"no username":     "users:\n  - password: x\n",
+		"duplicate":       "users:\n  - username: a\n  - username: a\n",
+	}
+	for name, doc := range invalid {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestApplyIdempotent tests that re-applying creates nothing and keeps
+// runtime password changes
+func TestApplyIdempotent(t *testing.T) {
+	service := newTestService(t)
+	file, err := Parse([]byte(`
+users:
+  - username: ops
+    password: initial-pass-1
+    role: admin
+    must_change_password: true
+  - username: viewer
+    password: initial-pass-2
+    scopes: [ws:view]
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	result, err := file.Apply(service)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if strings.Join(result.Created, ",") != "ops,viewer" {
+		t.Errorf("Expected both users created, got %+v", result)
+	}
+
+	// The user changes their password at runtime
+	ops, _ := service.GetUser("ops")
+	if _, err := service.ChangePassword(ops.ID, &auth.ChangePasswordRequest{
+		CurrentPassword: "initial-pass-1", NewPassword: "changed-pass-1",
+	}); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	result, err = file.Apply(service)
+	if err != nil {
+		t.Fatalf("Second apply failed: %v", err)
+	}
+	if len(result.Created) != 0 || len(result.Updated) != 0 || len(result.Unchanged) != 2 {
+		t.Errorf("Expected no changes on re-apply, got %+v", result)
+	}
+	if _, err := service.Login(&auth.LoginRequest{Username: "ops", Password: "changed-pass-1"}); err != nil {
+		t.Errorf("Runtime password change was lost: %v", err)
+	}
+
+	// Role drift is reconciled
+	file.Users[1].Role = auth.RoleAdmin
+	result, err = file.Apply(service)
+	if err != nil || len(result.Updated) != 1 {
+		t.Fatalf("Expected viewer to be updated, got %+v (%v)", result, err)
+	}
+	if viewer, _ := service.GetUser("viewer"); viewer.Role != auth.RoleAdmin {
+		t.Errorf("Expected viewer role admin, got %s", viewer.Role)
+	}
+}
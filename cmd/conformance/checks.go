@@ -0,0 +1,462 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// quietPeriod is how long a check waits to be sure a message is not coming
+const quietPeriod = 500 * time.Millisecond
+
+// suite is the target under test
+type suite struct {
+	url            string
+	token          string
+	timeout        time.Duration
+	maxMessageSize int
+	runID          string // Makes command IDs unique per run
+}
+
+// check is one named protocol check
+type check struct {
+	name string
+	run  func(s *suite) error
+}
+
+// checks is the battery, in run order
+var checks = []check{
+	{"auth/missing_token", (*suite).checkMissingToken},
+	{"auth/invalid_token", (*suite).checkInvalidToken},
+	{"handshake/request", (*suite).checkHandshakeRequest},
+	{"handshake/feature_negotiation", (*suite).checkFeatureNegotiation},
+	{"handshake/wrong_connection_id", (*suite).checkWrongConnectionID},
+	{"handshake/invalid_client_type", (*suite).checkInvalidClientType},
+	{"ping/pong", (*suite).checkPing},
+	{"status/get_status", (*suite).checkStatus},
+	{"routing/control_command", (*suite).checkControlCommand},
+	{"routing/room_isolation", (*suite).checkRoomIsolation},
+	{"ack/control_response_collapsed", (*suite).checkResponseCollapsed},
+	{"estop/latching", (*suite).checkEmergencyStopLatching},
+	{"close/normal", (*suite).checkNormalClose},
+	{"close/message_too_big", (*suite).checkMessageTooBig},
+}
+
+// isType matches messages of a type
+func isType(msgType string) func(message) bool {
+	return func(m message) bool { return m.str("type") == msgType }
+}
+
+// connect opens a handshaken connection as clientType
+func (s *suite) connect(clientType string) (*client, error) {
+	return connect(s.url, s.token, clientType, "", s.timeout)
+}
+
+// checkMissingToken expects the upgrade to be refused with 401
+func (s *suite) checkMissingToken() error {
+	return s.expectRefused("")
+}
+
+// checkInvalidToken expects the upgrade to be refused with 401
+func (s *suite) checkInvalidToken() error {
+	return s.expectRefused("conformance.invalid.token")
+}
+
+// expectRefused dials with token and expects a 401 response
+func (s *suite) expectRefused(token string) error {
+	c, resp, err := dial(s.url, token, s.timeout)
+	if err == nil {
+		c.close()
+		return errors.New("upgrade succeeded without a valid token")
+	}
+	if resp == nil {
+		return fmt.Errorf("no HTTP response: %w", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return fmt.Errorf("expected status 401, got %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkHandshakeRequest checks the handshake_request fields and completes
+// the handshake as a web client
+func (s *suite) checkHandshakeRequest() error {
+	c, _, err := dial(s.url, s.token, s.timeout)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	request, err := c.handshakeRequest()
+	if err != nil {
+		return err
+	}
+	if c.connectionID == "" {
+		return errors.New("handshake_request has no connection_id")
+	}
+
+	supported, _ := request["supported_client_types"].([]interface{})
+	for _, want := range []string{"web", "video", "control", "telemetry", "audio"} {
+		found := false
+		for _, clientType := range supported {
+			if clientType == want {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("supported_client_types %v lacks %q", supported, want)
+		}
+	}
+
+	return c.handshake("web", c.connectionID, "")
+}
+
+// checkFeatureNegotiation expects a version 2 handshake to be answered
+// with the features both sides support, ignoring unknown ones
+func (s *suite) checkFeatureNegotiation() error {
+	c, _, err := dial(s.url, s.token, s.timeout)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	request, err := c.handshakeRequest()
+	if err != nil {
+		return err
+	}
+	if version, _ := request["protocol_version"].(float64); version < 2 {
+		return fmt.Errorf("handshake_request protocol_version %v, expected 2 or later", request["protocol_version"])
+	}
+	if err := c.send(message{
+		"type":             "handshake_response",
+		"connection_id":    c.connectionID,
+		"client_type":      "web",
+		"protocol_version": 2,
+		"features":         []string{"ack", "conformance-unknown"},
+	}); err != nil {
+		return err
+	}
+
+	msg, err := c.expect(isType("connection_established"))
+	if err != nil {
+		return fmt.Errorf("connection_established: %w", err)
+	}
+	if version, _ := msg["protocol_version"].(float64); version != 2 {
+		return fmt.Errorf("connection_established protocol_version %v, expected 2", msg["protocol_version"])
+	}
+	features, _ := msg["features"].([]interface{})
+	if len(features) != 1 || features[0] != "ack" {
+		return fmt.Errorf("agreed features %v, expected [ack]", features)
+	}
+	return nil
+}
+
+// checkWrongConnectionID expects a handshake naming another connection to
+// be ignored, and the correct one to still succeed afterwards
+func (s *suite) checkWrongConnectionID() error {
+	c, _, err := dial(s.url, s.token, s.timeout)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	if _, err := c.handshakeRequest(); err != nil {
+		return err
+	}
+	if err := c.send(message{
+		"type":          "handshake_response",
+		"connection_id": c.connectionID + "_wrong",
+		"client_type":   "web",
+	}); err != nil {
+		return err
+	}
+	if err := c.expectNone(quietPeriod, isType("connection_established")); err != nil {
+		return fmt.Errorf("wrong connection_id accepted: %w", err)
+	}
+
+	return c.handshake("web", c.connectionID, "")
+}
+
+// checkInvalidClientType expects an unknown client type to be ignored
+func (s *suite) checkInvalidClientType() error {
+	c, _, err := dial(s.url, s.token, s.timeout)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	if _, err := c.handshakeRequest(); err != nil {
+		return err
+	}
+	if err := c.send(message{
+		"type":          "handshake_response",
+		"connection_id": c.connectionID,
+		"client_type":   "toaster",
+	}); err != nil {
+		return err
+	}
+	if err := c.expectNone(quietPeriod, isType("connection_established")); err != nil {
+		return fmt.Errorf("unknown client type accepted: %w", err)
+	}
+	return nil
+}
+
+// checkPing expects a pong echoing the ping timestamp
+func (s *suite) checkPing() error {
+	c, err := s.connect("web")
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	timestamp := float64(time.Now().UnixNano() / int64(time.Millisecond))
+	if err := c.send(message{"type": "ping", "timestamp": timestamp}); err != nil {
+		return err
+	}
+	pong, err := c.expect(isType("pong"))
+	if err != nil {
+		return fmt.Errorf("pong: %w", err)
+	}
+	if pong["timestamp"] != timestamp {
+		return fmt.Errorf("pong timestamp %v, expected %v", pong["timestamp"], timestamp)
+	}
+	return nil
+}
+
+// checkStatus expects a status_response with client counts
+func (s *suite) checkStatus() error {
+	c, err := s.connect("web")
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	status, err := s.status(c)
+	if err != nil {
+		return err
+	}
+	stats, _ := status["stats"].(map[string]interface{})
+	if web, _ := stats["web"].(float64); web < 1 {
+		return fmt.Errorf("status counts %v web clients, expected at least 1", stats["web"])
+	}
+	return nil
+}
+
+// status requests and returns a status_response
+func (s *suite) status(c *client) (message, error) {
+	if err := c.send(message{"type": "get_status"}); err != nil {
+		return nil, err
+	}
+	status, err := c.expect(isType("status_response"))
+	if err != nil {
+		return nil, fmt.Errorf("status_response: %w", err)
+	}
+	return status, nil
+}
+
+// pair connects a web client and a control client
+func (s *suite) pair() (*client, *client, error) {
+	web, err := s.connect("web")
+	if err != nil {
+		return nil, nil, fmt.Errorf("web: %w", err)
+	}
+	control, err := s.connect("control")
+	if err != nil {
+		web.close()
+		return nil, nil, fmt.Errorf("control: %w", err)
+	}
+	return web, control, nil
+}
+
+// commandID returns a command ID unique to this run
+func (s *suite) commandID(name string) string {
+	return fmt.Sprintf("conformance-%s-%s", s.runID, name)
+}
+
+// checkControlCommand expects a web client's control_command to reach a
+// control client unchanged
+func (s *suite) checkControlCommand() error {
+	web, control, err := s.pair()
+	if err != nil {
+		return err
+	}
+	defer web.close()
+	defer control.close()
+
+	id := s.commandID("route")
+	if err := web.send(message{"type": "control_command", "id": id, "data": message{"action": "noop"}}); err != nil {
+		return err
+	}
+	if _, err := control.expect(func(m message) bool {
+		return m.str("type") == "control_command" && m.str("id") == id
+	}); err != nil {
+		return fmt.Errorf("control client did not receive the command: %w", err)
+	}
+	return nil
+}
+
+// checkRoomIsolation expects a control_command not to reach a control
+// client that joined another room
+func (s *suite) checkRoomIsolation() error {
+	web, err := s.connect("web")
+	if err != nil {
+		return fmt.Errorf("web: %w", err)
+	}
+	defer web.close()
+	control, err := connect(s.url, s.token, "control", "conformance-"+s.runID, s.timeout)
+	if err != nil {
+		return fmt.Errorf("control: %w", err)
+	}
+	defer control.close()
+
+	id := s.commandID("room")
+	if err := web.send(message{"type": "control_command", "id": id, "data": message{"action": "noop"}}); err != nil {
+		return err
+	}
+	if err := control.expectNone(quietPeriod, func(m message) bool { return m.str("id") == id }); err != nil {
+		return fmt.Errorf("command crossed rooms: %w", err)
+	}
+	return nil
+}
+
+// checkResponseCollapsed expects a control_response to be acknowledged to
+// web clients once, even when the robot answers the same command twice
+func (s *suite) checkResponseCollapsed() error {
+	web, control, err := s.pair()
+	if err != nil {
+		return err
+	}
+	defer web.close()
+	defer control.close()
+
+	id := s.commandID("ack")
+	if err := web.send(message{"type": "control_command", "id": id, "data": message{"action": "noop"}}); err != nil {
+		return err
+	}
+	if _, err := control.expect(func(m message) bool { return m.str("id") == id }); err != nil {
+		return fmt.Errorf("control client did not receive the command: %w", err)
+	}
+
+	isResponse := func(m message) bool {
+		return m.str("type") == "control_response" && m.str("correlation_id") == id
+	}
+	for i := 0; i < 2; i++ {
+		if err := control.send(message{"type": "control_response", "correlation_id": id, "status": "ok"}); err != nil {
+			return err
+		}
+	}
+	if _, err := web.expect(isResponse); err != nil {
+		return fmt.Errorf("web client did not receive the response: %w", err)
+	}
+	if err := web.expectNone(quietPeriod, isResponse); err != nil {
+		return fmt.Errorf("duplicate response forwarded: %w", err)
+	}
+	return nil
+}
+
+// checkEmergencyStopLatching expects an emergency_stop to reach control
+// clients and stay active in the room status until reset
+func (s *suite) checkEmergencyStopLatching() error {
+	web, control, err := s.pair()
+	if err != nil {
+		return err
+	}
+	defer web.close()
+	defer control.close()
+
+	if err := web.send(message{"type": "emergency_stop", "reason": "conformance"}); err != nil {
+		return err
+	}
+	if _, err := control.expect(isType("emergency_stop")); err != nil {
+		return fmt.Errorf("control client did not receive emergency_stop: %w", err)
+	}
+
+	// Still active after a while, and after unrelated traffic
+	time.Sleep(quietPeriod)
+	web.send(message{"type": "ping", "timestamp": 0})
+	if active, err := s.emergencyStopActive(web); err != nil {
+		return err
+	} else if !active {
+		return errors.New("emergency stop not latched in room status")
+	}
+
+	if err := web.send(message{"type": "emergency_stop_reset", "reason": "conformance"}); err != nil {
+		return err
+	}
+	if _, err := control.expect(isType("emergency_stop_reset")); err != nil {
+		return fmt.Errorf("control client did not receive emergency_stop_reset: %w", err)
+	}
+	if active, err := s.emergencyStopActive(web); err != nil {
+		return err
+	} else if active {
+		return errors.New("emergency stop still active after reset")
+	}
+	return nil
+}
+
+// emergencyStopActive reads the e-stop state of c's room from get_status
+func (s *suite) emergencyStopActive(c *client) (bool, error) {
+	status, err := s.status(c)
+	if err != nil {
+		return false, err
+	}
+
+	stats, _ := status["stats"].(map[string]interface{})
+	rooms, _ := stats["rooms"].(map[string]interface{})
+	room, _ := rooms[status.str("room")].(map[string]interface{})
+	estop, ok := room["emergency_stop"].(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("status_response has no emergency_stop for room %q", status.str("room"))
+	}
+	active, _ := estop["active"].(bool)
+	return active, nil
+}
+
+// checkNormalClose expects the server to answer a 1000 close frame
+func (s *suite) checkNormalClose() error {
+	c, err := s.connect("web")
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	if err := c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "conformance"), time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	code, err := c.expectClose()
+	if err != nil {
+		return err
+	}
+	if code != websocket.CloseNormalClosure {
+		return fmt.Errorf("expected close code %d, got %d", websocket.CloseNormalClosure, code)
+	}
+	return nil
+}
+
+// checkMessageTooBig expects a message over the size limit to be answered
+// with close code 1009
+func (s *suite) checkMessageTooBig() error {
+	c, err := s.connect("web")
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	padding := strings.Repeat("x", s.maxMessageSize)
+	if err := c.send(message{"type": "ping", "padding": padding}); err != nil {
+		return err
+	}
+	code, err := c.expectClose()
+	if err != nil {
+		return err
+	}
+	if code != websocket.CloseMessageTooBig {
+		return fmt.Errorf("expected close code %d, got %d", websocket.CloseMessageTooBig, code)
+	}
+	return nil
+}
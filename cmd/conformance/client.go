@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// errNoMessage is returned by expect when no matching message arrived
+var errNoMessage = errors.New("no matching message")
+
+// message is a decoded server message
+type message map[string]interface{}
+
+// str returns a string field ("" when absent or not a string)
+func (m message) str(key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// client is one protocol connection under test. A reader goroutine
+// delivers messages, since a timed out read would break the connection.
+type client struct {
+	conn         *websocket.Conn
+	connectionID string
+	timeout      time.Duration
+
+	messages chan message
+	readErr  error // Set before messages is closed
+	done     chan struct{}
+	closed   sync.Once
+}
+
+// dial opens a WebSocket connection authenticated with token. On an
+// upgrade failure the HTTP response is returned when there is one.
+func dial(target, token string, timeout time.Duration) (*client, *http.Response, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != "" {
+		query := u.Query()
+		query.Set("token", token)
+		u.RawQuery = query.Encode()
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: timeout}
+	conn, resp, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	c := &client{conn: conn, timeout: timeout, messages: make(chan message, 64), done: make(chan struct{})}
+	conn.SetCloseHandler(func(code int, text string) error {
+		// Echo the close frame but report the server's code, even when
+		// this side already sent one
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(timeout))
+		return nil
+	})
+	go c.readLoop()
+	return c, resp, nil
+}
+
+// readLoop decodes messages until the connection fails
+func (c *client) readLoop() {
+	defer close(c.messages)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.readErr = err
+			return
+		}
+
+		// The server may batch queued messages into one frame, one JSON
+		// value per line
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var msg message
+			if err := decoder.Decode(&msg); err != nil {
+				break
+			}
+			select {
+			case c.messages <- msg:
+			case <-c.done:
+				return
+			}
+		}
+	}
+}
+
+// connect dials and completes the handshake as clientType, joining room
+// ("" for the default room)
+func connect(target, token string, clientType, room string, timeout time.Duration) (*client, error) {
+	c, _, err := dial(target, token, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.handshakeRequest(); err != nil {
+		c.close()
+		return nil, err
+	}
+	if err := c.handshake(clientType, c.connectionID, room); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshakeRequest waits for the server's handshake_request
+func (c *client) handshakeRequest() (message, error) {
+	msg, err := c.expect(func(m message) bool { return m.str("type") == "handshake_request" })
+	if err != nil {
+		return nil, fmt.Errorf("handshake_request: %w", err)
+	}
+	c.connectionID = msg.str("connection_id")
+	return msg, nil
+}
+
+// handshake answers the handshake_request and waits for
+// connection_established
+func (c *client) handshake(clientType, connectionID, room string) error {
+	response := message{
+		"type":          "handshake_response",
+		"connection_id": connectionID,
+		"client_type":   clientType,
+	}
+	if room != "" {
+		response["room"] = room
+	}
+	if err := c.send(response); err != nil {
+		return err
+	}
+
+	msg, err := c.expect(func(m message) bool { return m.str("type") == "connection_established" })
+	if err != nil {
+		return fmt.Errorf("connection_established: %w", err)
+	}
+	if msg.str("client_type") != clientType {
+		return fmt.Errorf("connection_established for client_type %q, expected %q", msg.str("client_type"), clientType)
+	}
+	if room != "" && msg.str("room") != room {
+		return fmt.Errorf("connection_established for room %q, expected %q", msg.str("room"), room)
+	}
+	return nil
+}
+
+// send writes a JSON message
+func (c *client) send(msg message) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	return c.conn.WriteJSON(msg)
+}
+
+// expect reads messages until one matches, skipping the rest, or the
+// timeout passes
+func (c *client) expect(match func(message) bool) (message, error) {
+	return c.expectWithin(c.timeout, match)
+}
+
+// expectWithin is expect with an explicit timeout
+func (c *client) expectWithin(timeout time.Duration, match func(message) bool) (message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				return nil, fmt.Errorf("connection closed: %w", c.readErr)
+			}
+			if match(msg) {
+				return msg, nil
+			}
+		case <-timer.C:
+			return nil, errNoMessage
+		}
+	}
+}
+
+// expectNone fails if a matching message arrives within timeout
+func (c *client) expectNone(timeout time.Duration, match func(message) bool) error {
+	msg, err := c.expectWithin(timeout, match)
+	if err == errNoMessage {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("unexpected %s message: %v", msg.str("type"), msg)
+}
+
+// expectClose reads until the server closes the connection and returns
+// the close code
+func (c *client) expectClose() (int, error) {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case _, ok := <-c.messages:
+			if ok {
+				continue
+			}
+			var closeErr *websocket.CloseError
+			if errors.As(c.readErr, &closeErr) {
+				return closeErr.Code, nil
+			}
+			return 0, fmt.Errorf("connection ended without a close frame: %w", c.readErr)
+		case <-timer.C:
+			return 0, errors.New("server did not close the connection")
+		}
+	}
+}
+
+// close closes the connection without a close handshake
+func (c *client) close() {
+	c.closed.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
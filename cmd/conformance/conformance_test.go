@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"oculo-pilot-server/websocket"
+	"strings"
+	"testing"
+	"time"
+)
+
+// staticValidator accepts a single token
+type staticValidator struct {
+	token string
+}
+
+func (v staticValidator) ValidateToken(token string) (int64, string, error) {
+	if token != v.token {
+		return 0, "", errors.New("invalid token")
+	}
+	return 1, "conformance", nil
+}
+
+// TestConformanceAgainstHub runs the full battery against this build's
+// hub and handler
+func TestConformanceAgainstHub(t *testing.T) {
+	hub := websocket.NewHub()
+	go hub.Run()
+
+	handler := websocket.NewHandler(hub, staticValidator{token: "good"}, nil, false, 10*time.Second, 4096)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	s := &suite{
+		url:            "ws" + strings.TrimPrefix(server.URL, "http") + "/ws",
+		token:          "good",
+		timeout:        2 * time.Second,
+		maxMessageSize: 4096,
+		runID:          "test",
+	}
+	report := s.run(nil)
+	for _, result := range report.Results {
+		if result.Status != statusPassed {
+			t.Errorf("%s %s: %s", result.Name, result.Status, result.Error)
+		}
+	}
+
+	var junit strings.Builder
+	if err := report.writeJUnit(&junit); err != nil {
+		t.Fatalf("writeJUnit failed: %v", err)
+	}
+	if !strings.Contains(junit.String(), `<testcase name="estop/latching" classname="conformance"`) {
+		t.Errorf("Unexpected JUnit report:\n%s", junit.String())
+	}
+}
@@ -0,0 +1,153 @@
+// Command conformance runs a battery of WebSocket protocol checks against a
+// server and writes a JSON or JUnit report, so firmware and server builds
+// can be checked for compatibility before rollout.
+//
+// The checks send real control_command, emergency_stop and
+// emergency_stop_reset messages; run them against a test server, not one
+// with robots in the field.
+//
+//	conformance -url ws://localhost:8080/ws -username admin -password ... -format junit -out report.xml
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func main() {
+	target := flag.String("url", "ws://localhost:8080/ws", "WebSocket endpoint under test")
+	token := flag.String("token", os.Getenv("CONFORMANCE_TOKEN"), "JWT to connect with (default $CONFORMANCE_TOKEN)")
+	username := flag.String("username", "", "log in with this user instead of -token")
+	password := flag.String("password", os.Getenv("CONFORMANCE_PASSWORD"), "password for -username (default $CONFORMANCE_PASSWORD)")
+	timeout := flag.Duration("timeout", 5*time.Second, "how long to wait for each expected message")
+	maxMessageSize := flag.Int("max-message-size", 65536, "server MAX_MESSAGE_SIZE, for the message_too_big check")
+	run := flag.String("run", "", "only run checks whose name matches this regexp")
+	format := flag.String("format", "json", "report format: json or junit")
+	out := flag.String("out", "", "write the report to this file instead of stdout")
+	flag.Parse()
+
+	if *format != "json" && *format != "junit" {
+		log.Fatalf("Unknown report format %q (expected json or junit)", *format)
+	}
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			log.Fatalf("Invalid -run pattern: %v", err)
+		}
+	}
+
+	if *username != "" {
+		var err error
+		if *token, err = login(*target, *username, *password, *timeout); err != nil {
+			log.Fatalf("Login failed: %v", err)
+		}
+	}
+	if *token == "" {
+		log.Fatal("A token is required: pass -token or -username/-password")
+	}
+
+	s := &suite{
+		url:            *target,
+		token:          *token,
+		timeout:        *timeout,
+		maxMessageSize: *maxMessageSize,
+		runID:          fmt.Sprint(time.Now().UnixNano()),
+	}
+	report := s.run(filter)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create report: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	var err error
+	if *format == "junit" {
+		err = report.writeJUnit(w)
+	} else {
+		err = report.writeJSON(w)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	log.Printf("%d passed, %d failed, %d skipped", report.Passed, report.Failed, report.Skipped)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// run executes every check matching filter (nil runs all)
+func (s *suite) run(filter *regexp.Regexp) *Report {
+	report := &Report{Target: s.url, StartedAt: time.Now(), Results: make([]Result, 0, len(checks))}
+
+	for _, c := range checks {
+		if filter != nil && !filter.MatchString(c.name) {
+			report.add(Result{Name: c.name, Status: statusSkipped})
+			continue
+		}
+
+		started := time.Now()
+		err := c.run(s)
+		result := Result{Name: c.name, Status: statusPassed, Duration: time.Since(started).Seconds()}
+		if err != nil {
+			result.Status = statusFailed
+			result.Error = err.Error()
+			log.Printf("❌ %s: %v", c.name, err)
+		} else {
+			log.Printf("✅ %s", c.name)
+		}
+		report.add(result)
+	}
+
+	report.Duration = time.Since(report.StartedAt).Seconds()
+	return report
+}
+
+// login exchanges a username and password for a token at the /api/login
+// endpoint of the server hosting target
+func login(target, username, password string, timeout time.Duration) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.Replace(u.Scheme, "ws", "http", 1)
+	u.Path = "/api/login"
+	u.RawQuery = ""
+
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(u.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	return response.Token, nil
+}
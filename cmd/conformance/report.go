@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Result statuses
+const (
+	statusPassed  = "passed"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+)
+
+// Result is the outcome of one check
+type Result struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+// Report is the outcome of a conformance run
+type Report struct {
+	Target    string    `json:"target"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  float64   `json:"duration_seconds"`
+	Passed    int       `json:"passed"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+	Results   []Result  `json:"results"`
+}
+
+// add records a result and updates the totals
+func (r *Report) add(result Result) {
+	r.Results = append(r.Results, result)
+	switch result.Status {
+	case statusPassed:
+		r.Passed++
+	case statusFailed:
+		r.Failed++
+	case statusSkipped:
+		r.Skipped++
+	}
+}
+
+// writeJSON writes the report as indented JSON
+func (r *Report) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// JUnit XML elements, as understood by CI systems
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes the report as JUnit XML with one test suite
+func (r *Report) writeJUnit(w io.Writer) error {
+	suite := junitSuite{
+		Name:      "conformance " + r.Target,
+		Tests:     len(r.Results),
+		Failures:  r.Failed,
+		Skipped:   r.Skipped,
+		Time:      fmt.Sprintf("%.3f", r.Duration),
+		Timestamp: r.StartedAt.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, result := range r.Results {
+		testCase := junitCase{
+			Name:      result.Name,
+			Classname: "conformance",
+			Time:      fmt.Sprintf("%.3f", result.Duration),
+		}
+		switch result.Status {
+		case statusFailed:
+			testCase.Failure = &junitFailure{Message: result.Error}
+		case statusSkipped:
+			testCase.Skipped = &struct{}{}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
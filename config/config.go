@@ -11,126 +11,769 @@ import (
 
 // Config holds application configuration
 type Config struct {
-	Server ServerConfig
-	Auth   AuthConfig
-	DB     DBConfig
-	TURN   TURNConfig
+	Server    ServerConfig
+	Auth      AuthConfig
+	DB        DBConfig
+	TURN      TURNConfig
+	Anomaly   AnomalyConfig
+	Inference InferenceConfig
+	Metrics   MetricsConfig
+	Audit     AuditConfig
+	Recording RecordingConfig
+	Telemetry TelemetryConfig
+	WireGuard WireGuardConfig
+	Logging   LoggingConfig
+	Shutdown  ShutdownConfig
+	MTLS      MTLSConfig
+	SMTP      SMTPConfig
+	Backplane BackplaneConfig
+
+	settings []Setting // Every variable read by Load, in order
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
-	Host                 string
-	Port                 string
-	AllowedOrigins       []string
-	AllowedNetworks      []string // IP whitelist (CIDR format)
-	RateLimit            int
-	HandshakeTimeout     time.Duration
-	EnableIPWhitelist    bool
-	MaxMessageSize       int64
+	Host              string
+	Port              string
+	AllowedOrigins    []string
+	AllowedNetworks   []string // IP whitelist (CIDR format)
+	HandshakeTimeout  time.Duration
+	HandshakeResend   time.Duration // Resend an unanswered handshake_request this often (0 sends it once)
+	EnableIPWhitelist bool
+	MaxMessageSize    int64
+
+	// Refresh bounds for hostnames in AllowedNetworks (DNS TTL is clamped to these)
+	DNSRefreshMin time.Duration
+	DNSRefreshMax time.Duration
+
+	// ListenAddrs replaces Host:Port with several listen addresses, e.g.
+	// 0.0.0.0:8080 and [::]:8080, or an internal-only port for devices. IP
+	// literals are bound to their own family only.
+	ListenAddrs []string
+
+	// RateLimit is how many WebSocket messages per second a connection may
+	// send, in bursts of up to RateLimitBurst (0 disables). RateLimits
+	// overrides it per client type, e.g. telemetry: 20. A connection
+	// warned RateLimitStrikes times before its limit refills is closed (0
+	// never closes).
+	RateLimit        int
+	RateLimitBurst   int
+	RateLimits       map[string]int
+	RateLimitStrikes int
+
+	// SendQueueSize is how many outbound messages a WebSocket connection
+	// can have queued. SendQueuePolicies sets per client type what happens
+	// when the queue is full: drop_oldest (the default) or disconnect.
+	SendQueueSize     int
+	SendQueuePolicies map[string]string
+
+	// EgressLimits caps the bytes per second written to each connection
+	// of a client type, e.g. telemetry: 65536, so chatty feeds leave
+	// room for media on a constrained uplink (unlisted types are unlimited)
+	EgressLimits map[string]int
+
+	// MessageTTLs is the default time-to-live of forwarded messages by
+	// type, e.g. location_update: 2s; copies still queued for a slow
+	// client after it are dropped. Messages may set ttl_ms themselves.
+	MessageTTLs map[string]time.Duration
+
+	// DedupWindows drops messages of each type whose id the same sender
+	// already used within the window, e.g. control_command: 30s, so
+	// a command retried after a reconnect does not run twice
+	DedupWindows map[string]time.Duration
+
+	// Compression negotiates permessage-deflate; frames to
+	// CompressionTypes are compressed at CompressionLevel (1-9)
+	Compression      bool
+	CompressionTypes []string
+	CompressionLevel int
+
+	// Max*Clients cap connected clients per type so a flood of viewers
+	// cannot starve the control path; handshakes beyond the cap are
+	// rejected (0 is unlimited)
+	MaxWebClients       int
+	MaxVideoClients     int
+	MaxControlClients   int
+	MaxTelemetryClients int
+	MaxAudioClients     int
+	MaxMonitorClients   int
+
+	// StaleTimeout evicts WebSocket clients that sent no message or pong
+	// for this long (0 disables)
+	StaleTimeout time.Duration
+
+	// ResumeWindow keeps a dropped WebSocket client's place for this long
+	// so it can reconnect with its resume token (0 disables)
+	ResumeWindow time.Duration
+
+	// CommandQoSTTL keeps at-least-once control commands a robot has not
+	// acknowledged for this long, resending them when it reconnects
+	CommandQoSTTL time.Duration
+
+	// CommandActions limits control_command data.action to these values
+	// (empty allows any). CommandLimits bounds numeric fields by dotted
+	// path, e.g. {"data.speed": "-1:1"}. CommandRequired lists dotted paths
+	// every control_command must carry.
+	CommandActions  []string
+	CommandLimits   map[string]string
+	CommandRequired []string
+
+	// CommandTimeout tells web clients with command_timeout when robots do
+	// not answer a control_command with an ID in time (0 disables)
+	CommandTimeout time.Duration
+
+	// ControlIdleTimeout releases the control lock of a web client that sent
+	// no control_command or heartbeat for this long (0 disables)
+	ControlIdleTimeout time.Duration
+
+	// TrustedProxies are reverse proxies (CIDRs or IPs) whose
+	// X-Forwarded-For header names the client; from anyone else the header
+	// is ignored, so it cannot dodge bans or IP restrictions
+	TrustedProxies []string
+
+	// ProxyProtocol expects a HAProxy PROXY protocol header from peers in
+	// ProxyProtocolTrusted (every peer when empty), for TCP load balancers
+	// that cannot add X-Forwarded-For. The header address is then the only
+	// one used: TrustedProxies is ignored.
+	ProxyProtocol        bool
+	ProxyProtocolTrusted []string
+
+	// GeoIPDatabase is a MaxMind DB (.mmdb); when set, API and WebSocket
+	// requests are only accepted from the countries (e.g. "KR") and regions
+	// (e.g. "US-CA") in GeoIPAllowed. Addresses missing from the database,
+	// such as private ranges, pass when GeoIPAllowUnknown is set.
+	GeoIPDatabase     string
+	GeoIPAllowed      []string
+	GeoIPAllowUnknown bool
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
 	JWTSecret string
 	JWTExpiry time.Duration
+
+	// JWTSigningKeyFile is a PEM RSA or P-256 EC private key; when set,
+	// tokens are signed with RS256/ES256 and published at /.well-known/jwks.json
+	JWTSigningKeyFile string
+
+	// JWTLegacyTokens keeps accepting JWT_SECRET tokens without a "kid"
+	// once signing keys are in use; by default they are only accepted for
+	// one JWTExpiry after the first key took over
+	JWTLegacyTokens bool
+
+	// JWTIssuer and JWTAudience are set as iss/aud on new tokens and
+	// required on incoming ones, so tokens from other environments are rejected
+	JWTIssuer   string
+	JWTAudience string
+
+	// IntrospectionClients maps client IDs to secrets allowed to call
+	// POST /api/token/introspect; the endpoint is disabled when empty
+	IntrospectionClients map[string]string
+
+	// Password hashing
+	PasswordHash  string // bcrypt or argon2id
+	BcryptCost    int    // 4-31
+	Argon2Memory  int    // KiB
+	Argon2Time    int
+	Argon2Threads int
+
+	// Password policy
+	PasswordMinLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordBanCommon     bool   // Reject built-in list of common passwords
+	PasswordBannedFile    string // Additional banned passwords, one per line
+	PasswordHistory       int    // Recent passwords, including the current one, that may not be reused (0 disables)
+
+	// Self-registration via POST /api/register; with approval, new accounts
+	// stay pending until an admin approves them
+	EnableRegistration   bool
+	RegistrationApproval bool
+
+	// RegistrationChallenge guards POST /api/register: hcaptcha, turnstile
+	// or pow (proof-of-work for headless deployments); empty disables it
+	RegistrationChallenge string
+	CaptchaSiteKey        string
+	CaptchaSecret         string
+	PowDifficulty         int // Leading zero bits, 1-32
+
+	// LoginPolicy applies when an account logs in while it has live
+	// sessions: allow, warn (notify them) or terminate (notify and end them)
+	LoginPolicy string
+
+	// Source IPs reaching BanThreshold failed logins or WebSocket token
+	// validations within BanWindow are banned for BanDuration (0 disables)
+	BanThreshold int
+	BanWindow    time.Duration
+	BanDuration  time.Duration
+
+	// SessionCookie lets browsers log in with {"cookie": true} and get an
+	// HttpOnly, SameSite=Strict session cookie instead of a token in the
+	// response body; SessionCookieSecure marks it HTTPS-only
+	SessionCookie       bool
+	SessionCookieSecure bool
+
+	// MagicLinkURL is the public base URL of this server (e.g.
+	// https://pilot.example.com); with SMTP configured it enables
+	// passwordless login links valid for MagicLinkTTL. Tokens issued through
+	// a link are narrowed to MagicLinkScopes (empty keeps the user's scopes).
+	MagicLinkURL    string
+	MagicLinkTTL    time.Duration
+	MagicLinkScopes []string
+
+	// BootstrapFile is a YAML file of users applied at startup
+	BootstrapFile string
+
+	// SetupToken authorizes POST /api/setup while no admin exists; a random
+	// token is generated and logged when empty
+	SetupToken string
 }
 
 // DBConfig holds database configuration
 type DBConfig struct {
-	Path string
+	Driver string // sqlite3 or postgres
+	DSN    string // Connection string (defaults to Path for sqlite3)
+	Path   string
 }
 
 // TURNConfig holds TURN server configuration
 type TURNConfig struct {
-	Server   string
+	Server   string // Comma-separated TURN/STUN URIs
 	Username string
 	Password string
+
+	// Secret is the TURN server's shared REST secret (coturn
+	// static-auth-secret); when set, GET /api/turn-credentials issues
+	// credentials derived from it that expire after CredentialTTL
+	Secret        string
+	CredentialTTL time.Duration
+}
+
+// AnomalyConfig holds telemetry anomaly detection configuration
+type AnomalyConfig struct {
+	Enabled   bool
+	Fields    []string // Dotted paths, ":rate" suffix for rate of change
+	Alpha     float64  // EWMA smoothing factor
+	Threshold float64  // z-score threshold
+	Warmup    int      // Samples before alerting
+}
+
+// InferenceConfig holds external telemetry inference configuration
+type InferenceConfig struct {
+	URL        string            // Default inference endpoint (empty disables)
+	RobotURLs  map[string]string // Per-robot endpoint overrides
+	WindowSize int               // Telemetry messages per request
+	Timeout    time.Duration
+}
+
+// MetricsConfig holds stats history configuration
+type MetricsConfig struct {
+	HistoryEnabled bool
+	SampleInterval time.Duration
+	Retention      time.Duration
+}
+
+// AuditConfig holds audit log configuration
+type AuditConfig struct {
+	Enabled   bool
+	Retention time.Duration
+}
+
+// RecordingConfig holds WebSocket message recording configuration
+type RecordingConfig struct {
+	Enabled   bool // Start a recording session at startup (admins can toggle it later)
+	Retention time.Duration
+}
+
+// TelemetryConfig holds telemetry persistence configuration
+type TelemetryConfig struct {
+	Enabled       bool
+	BatchSize     int           // Samples written per INSERT
+	FlushInterval time.Duration // Longest a sample waits for its batch to fill
+	Retention     time.Duration
+}
+
+// WireGuardConfig holds WireGuard deployment mode configuration
+type WireGuardConfig struct {
+	Interface       string // Bind to this interface and require peers from its allowed IPs (empty disables)
+	RefreshInterval time.Duration
+}
+
+// LoggingConfig holds log sampling configuration for repetitive errors
+type LoggingConfig struct {
+	SampleBurst  int           // Messages logged per key per window
+	SampleWindow time.Duration // Window after which suppressed counts are summarized
+}
+
+// ShutdownConfig holds graceful shutdown configuration
+type ShutdownConfig struct {
+	Timeout    time.Duration // Maximum time to drain HTTP requests and WebSocket queues
+	WebhookURL string        // Receives the shutdown report as JSON (empty disables)
+}
+
+// MTLSConfig holds the client-certificate listener for devices
+type MTLSConfig struct {
+	Addr         string // Listen address, e.g. ":8443" (empty disables)
+	CertFile     string // Server certificate (PEM)
+	KeyFile      string // Server private key (PEM)
+	ClientCAFile string // CA bundle that issues device certificates (PEM)
+}
+
+// SMTPConfig holds the outgoing mail server, used for magic login links
+type SMTPConfig struct {
+	Host     string // Empty disables email
+	Port     int
+	Username string // Empty skips authentication
+	Password string
+	From     string
+}
+
+// BackplaneConfig holds the broker that connects the hubs of several
+// server instances
+type BackplaneConfig struct {
+	RedisURL string // redis://[user:password@]host[:port] (empty runs a single instance)
+	NATSURL  string // {nats,tls}://[user:password@|token@]host[:port], instead of Redis
+	Prefix   string // Channel prefix, so deployments can share a broker
+
+	// TLS to the broker and the bridge: CA bundle replacing the system
+	// roots, and a client certificate and key (all optional)
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// NATS server through which edge services inject telemetry and receive
+	// commands on subjects under Prefix (empty disables the bridge)
+	BridgeURL string
+}
+
+// Keys are the client and message types the server knows. Entries of
+// per-type settings naming any other type are reported invalid; a nil
+// list accepts any name.
+type Keys struct {
+	ClientTypes  []string
+	MessageTypes []string
 }
 
 // Load loads configuration from environment variables
-func Load() (*Config, error) {
+func Load(keys Keys) (*Config, error) {
+	// Remember which .env keys are not already set, so their source can be reported
+	l := newLoader()
+
 	// Try to load .env file (ignore error if it doesn't exist)
 	_ = godotenv.Load()
 
-	return &Config{
+	cfg := &Config{
 		Server: ServerConfig{
-			Host:              getEnv("SERVER_HOST", "0.0.0.0"),
-			Port:              getEnv("SERVER_PORT", "8080"),
-			AllowedOrigins:    getEnvSlice("ALLOWED_ORIGINS", ",", []string{"*"}),
-			AllowedNetworks:   getEnvSlice("ALLOWED_NETWORKS", ",", []string{"0.0.0.0/0"}), // Allow all by default
-			RateLimit:         getEnvInt("RATE_LIMIT", 100),
-			HandshakeTimeout:  getEnvDuration("HANDSHAKE_TIMEOUT", "10s"),
-			EnableIPWhitelist: getEnvBool("ENABLE_IP_WHITELIST", false),
-			MaxMessageSize:    int64(getEnvInt("MAX_MESSAGE_SIZE", 65536)), // 64KB
+			Host:              l.getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:              l.getEnv("SERVER_PORT", "8080"),
+			AllowedOrigins:    l.getEnvSlice("ALLOWED_ORIGINS", ",", nil),
+			AllowedNetworks:   l.getEnvSlice("ALLOWED_NETWORKS", ",", []string{"0.0.0.0/0", "::/0"}), // Allow all by default
+			HandshakeTimeout:  l.getEnvDuration("HANDSHAKE_TIMEOUT", "10s"),
+			HandshakeResend:   l.getEnvDuration("HANDSHAKE_RESEND", "2s"),
+			EnableIPWhitelist: l.getEnvBool("ENABLE_IP_WHITELIST", false),
+			MaxMessageSize:    int64(l.getEnvInt("MAX_MESSAGE_SIZE", 65536)), // 64KB
+			DNSRefreshMin:     l.getEnvDuration("DNS_REFRESH_MIN", "30s"),
+			DNSRefreshMax:     l.getEnvDuration("DNS_REFRESH_MAX", "10m"),
+
+			ListenAddrs: l.getEnvSlice("LISTEN_ADDRS", ",", nil),
+
+			RateLimit:        l.getEnvInt("RATE_LIMIT", 100),
+			RateLimitBurst:   l.getEnvInt("RATE_LIMIT_BURST", 200),
+			RateLimits:       l.getEnvIntMap("RATE_LIMITS", keys.ClientTypes),
+			RateLimitStrikes: l.getEnvInt("RATE_LIMIT_STRIKES", 10),
+
+			SendQueueSize:     l.getEnvInt("SEND_QUEUE_SIZE", 256),
+			SendQueuePolicies: l.getEnvChoiceMap("SEND_QUEUE_POLICIES", keys.ClientTypes, "drop_oldest", "disconnect"),
+			EgressLimits:      l.getEnvIntMap("EGRESS_LIMITS", keys.ClientTypes),
+			MessageTTLs:       l.getEnvDurationMap("MESSAGE_TTLS", keys.MessageTypes),
+			DedupWindows:      l.getEnvDurationMap("DEDUP_WINDOWS", keys.MessageTypes),
+
+			Compression:      l.getEnvBool("WS_COMPRESSION", true),
+			CompressionTypes: l.getEnvSlice("WS_COMPRESSION_TYPES", ",", []string{"web", "telemetry", "monitor"}),
+			CompressionLevel: l.getEnvInt("WS_COMPRESSION_LEVEL", 1),
+
+			MaxWebClients:       l.getEnvInt("MAX_WEB_CLIENTS", 0),
+			MaxVideoClients:     l.getEnvInt("MAX_VIDEO_CLIENTS", 0),
+			MaxControlClients:   l.getEnvInt("MAX_CONTROL_CLIENTS", 0),
+			MaxTelemetryClients: l.getEnvInt("MAX_TELEMETRY_CLIENTS", 0),
+			MaxAudioClients:     l.getEnvInt("MAX_AUDIO_CLIENTS", 0),
+			MaxMonitorClients:   l.getEnvInt("MAX_MONITOR_CLIENTS", 0),
+
+			ControlIdleTimeout: l.getEnvDuration("CONTROL_IDLE_TIMEOUT", "5m"),
+			StaleTimeout:       l.getEnvDuration("CLIENT_STALE_TIMEOUT", "0"),
+			ResumeWindow:       l.getEnvDuration("RESUME_WINDOW", "30s"),
+			CommandQoSTTL:      l.getEnvDuration("COMMAND_QOS_TTL", "30s"),
+			CommandTimeout:     l.getEnvDuration("COMMAND_TIMEOUT", "0"),
+			CommandActions:     l.getEnvSlice("COMMAND_ACTIONS", ",", nil),
+			CommandLimits:      l.getEnvMap("COMMAND_LIMITS", ",", "="),
+			CommandRequired:    l.getEnvSlice("COMMAND_REQUIRED", ",", nil),
+
+			TrustedProxies: l.getEnvSlice("TRUSTED_PROXIES", ",", nil),
+
+			ProxyProtocol:        l.getEnvBool("PROXY_PROTOCOL", false),
+			ProxyProtocolTrusted: l.getEnvSlice("PROXY_PROTOCOL_TRUSTED", ",", nil),
+
+			GeoIPDatabase:     l.getEnv("GEOIP_DATABASE", ""),
+			GeoIPAllowed:      l.getEnvSlice("GEOIP_ALLOWED", ",", nil),
+			GeoIPAllowUnknown: l.getEnvBool("GEOIP_ALLOW_UNKNOWN", true),
 		},
 		Auth: AuthConfig{
-			JWTSecret: getEnv("JWT_SECRET", "change-this-secret-key-in-production"),
-			JWTExpiry: getEnvDuration("JWT_EXPIRY", "24h"),
+			JWTSecret: l.getEnv("JWT_SECRET", "change-this-secret-key-in-production"),
+			JWTExpiry: l.getEnvDuration("JWT_EXPIRY", "24h"),
+
+			JWTSigningKeyFile: l.getEnv("JWT_SIGNING_KEY_FILE", ""),
+			JWTLegacyTokens:   l.getEnvBool("JWT_LEGACY_TOKENS", false),
+			JWTIssuer:         l.getEnv("JWT_ISSUER", ""),
+			JWTAudience:       l.getEnv("JWT_AUDIENCE", ""),
+
+			IntrospectionClients: l.getEnvMap("INTROSPECTION_CLIENTS", ",", ":"),
+
+			PasswordHash:  l.getEnv("PASSWORD_HASH", "bcrypt"),
+			BcryptCost:    l.getEnvInt("BCRYPT_COST", 12),
+			Argon2Memory:  l.getEnvInt("ARGON2_MEMORY", 64*1024),
+			Argon2Time:    l.getEnvInt("ARGON2_TIME", 3),
+			Argon2Threads: l.getEnvInt("ARGON2_THREADS", 2),
+
+			PasswordMinLength:     l.getEnvInt("PASSWORD_MIN_LENGTH", 8),
+			PasswordRequireUpper:  l.getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+			PasswordRequireLower:  l.getEnvBool("PASSWORD_REQUIRE_LOWER", false),
+			PasswordRequireDigit:  l.getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+			PasswordRequireSymbol: l.getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			PasswordBanCommon:     l.getEnvBool("PASSWORD_BAN_COMMON", false),
+			PasswordBannedFile:    l.getEnv("PASSWORD_BANNED_FILE", ""),
+			PasswordHistory:       l.getEnvInt("PASSWORD_HISTORY", 0),
+
+			EnableRegistration:   l.getEnvBool("ENABLE_REGISTRATION", true),
+			RegistrationApproval: l.getEnvBool("REGISTRATION_APPROVAL", false),
+			LoginPolicy:          l.getEnv("LOGIN_POLICY", "warn"),
+
+			BanThreshold: l.getEnvInt("AUTH_BAN_THRESHOLD", 10),
+			BanWindow:    l.getEnvDuration("AUTH_BAN_WINDOW", "10m"),
+			BanDuration:  l.getEnvDuration("AUTH_BAN_DURATION", "15m"),
+
+			SessionCookie:       l.getEnvBool("SESSION_COOKIE", false),
+			SessionCookieSecure: l.getEnvBool("SESSION_COOKIE_SECURE", true),
+
+			RegistrationChallenge: l.getEnv("REGISTRATION_CHALLENGE", ""),
+			CaptchaSiteKey:        l.getEnv("CAPTCHA_SITE_KEY", ""),
+			CaptchaSecret:         l.getEnv("CAPTCHA_SECRET", ""),
+			PowDifficulty:         l.getEnvInt("POW_DIFFICULTY", 20),
+
+			MagicLinkURL:    l.getEnv("MAGIC_LINK_URL", ""),
+			MagicLinkTTL:    l.getEnvDuration("MAGIC_LINK_TTL", "15m"),
+			MagicLinkScopes: l.getEnvSlice("MAGIC_LINK_SCOPES", ",", []string{"ws:view"}),
+
+			BootstrapFile: l.getEnv("BOOTSTRAP_FILE", ""),
+			SetupToken:    l.getEnv("SETUP_TOKEN", ""),
 		},
 		DB: DBConfig{
-			Path: getEnv("DB_PATH", "./users.db"),
+			Driver: l.getEnv("DB_DRIVER", "sqlite3"),
+			DSN:    l.getEnv("DB_DSN", ""),
+			Path:   l.getEnv("DB_PATH", "./users.db"),
 		},
 		TURN: TURNConfig{
-			Server:   getEnv("TURN_SERVER", ""),
-			Username: getEnv("TURN_USERNAME", ""),
-			Password: getEnv("TURN_PASSWORD", ""),
+			Server:   l.getEnv("TURN_SERVER", ""),
+			Username: l.getEnv("TURN_USERNAME", ""),
+			Password: l.getEnv("TURN_PASSWORD", ""),
+
+			Secret:        l.getEnv("TURN_SECRET", ""),
+			CredentialTTL: l.getEnvDuration("TURN_CREDENTIAL_TTL", "1h"),
+		},
+		Anomaly: AnomalyConfig{
+			Enabled:   l.getEnvBool("ANOMALY_DETECTION", false),
+			Fields:    l.getEnvSlice("ANOMALY_FIELDS", ",", []string{"data.speed", "data.battery:rate"}),
+			Alpha:     l.getEnvFloat("ANOMALY_ALPHA", 0.1),
+			Threshold: l.getEnvFloat("ANOMALY_ZSCORE", 3.0),
+			Warmup:    l.getEnvInt("ANOMALY_WARMUP", 30),
+		},
+		Inference: InferenceConfig{
+			URL:        l.getEnv("INFERENCE_URL", ""),
+			RobotURLs:  l.getEnvMap("INFERENCE_ROBOT_URLS", ",", "="),
+			WindowSize: l.getEnvInt("INFERENCE_WINDOW", 20),
+			Timeout:    l.getEnvDuration("INFERENCE_TIMEOUT", "5s"),
+		},
+		Metrics: MetricsConfig{
+			HistoryEnabled: l.getEnvBool("METRICS_HISTORY", true),
+			SampleInterval: l.getEnvDuration("METRICS_SAMPLE_INTERVAL", "1m"),
+			Retention:      l.getEnvDuration("METRICS_RETENTION", "720h"), // 30 days
+		},
+		Audit: AuditConfig{
+			Enabled:   l.getEnvBool("AUDIT_ENABLED", true),
+			Retention: l.getEnvDuration("AUDIT_RETENTION", "2160h"), // 90 days
+		},
+		Recording: RecordingConfig{
+			Enabled:   l.getEnvBool("RECORDING_ENABLED", false),
+			Retention: l.getEnvDuration("RECORDING_RETENTION", "168h"), // 7 days
+		},
+		Telemetry: TelemetryConfig{
+			Enabled:       l.getEnvBool("TELEMETRY_PERSIST", false),
+			BatchSize:     l.getEnvInt("TELEMETRY_BATCH_SIZE", 100),
+			FlushInterval: l.getEnvDuration("TELEMETRY_FLUSH_INTERVAL", "1s"),
+			Retention:     l.getEnvDuration("TELEMETRY_RETENTION", "720h"), // 30 days
 		},
-	}, nil
+		WireGuard: WireGuardConfig{
+			Interface:       l.getEnv("WIREGUARD_INTERFACE", ""),
+			RefreshInterval: l.getEnvDuration("WIREGUARD_REFRESH", "10s"),
+		},
+		Logging: LoggingConfig{
+			SampleBurst:  l.getEnvInt("LOG_SAMPLE_BURST", 5),
+			SampleWindow: l.getEnvDuration("LOG_SAMPLE_WINDOW", "1m"),
+		},
+		Shutdown: ShutdownConfig{
+			Timeout:    l.getEnvDuration("SHUTDOWN_TIMEOUT", "10s"),
+			WebhookURL: l.getEnv("SHUTDOWN_WEBHOOK_URL", ""),
+		},
+		MTLS: MTLSConfig{
+			Addr:         l.getEnv("MTLS_ADDR", ""),
+			CertFile:     l.getEnv("MTLS_CERT_FILE", ""),
+			KeyFile:      l.getEnv("MTLS_KEY_FILE", ""),
+			ClientCAFile: l.getEnv("MTLS_CLIENT_CA_FILE", ""),
+		},
+		SMTP: SMTPConfig{
+			Host:     l.getEnv("SMTP_HOST", ""),
+			Port:     l.getEnvInt("SMTP_PORT", 587),
+			Username: l.getEnv("SMTP_USERNAME", ""),
+			Password: l.getEnv("SMTP_PASSWORD", ""),
+			From:     l.getEnv("SMTP_FROM", ""),
+		},
+		Backplane: BackplaneConfig{
+			RedisURL:  l.getEnv("BACKPLANE_REDIS_URL", ""),
+			NATSURL:   l.getEnv("BACKPLANE_NATS_URL", ""),
+			Prefix:    l.getEnv("BACKPLANE_PREFIX", "oculo-pilot"),
+			BridgeURL: l.getEnv("NATS_BRIDGE_URL", ""),
+
+			TLSCAFile:   l.getEnv("BACKPLANE_TLS_CA_FILE", ""),
+			TLSCertFile: l.getEnv("BACKPLANE_TLS_CERT_FILE", ""),
+			TLSKeyFile:  l.getEnv("BACKPLANE_TLS_KEY_FILE", ""),
+		},
+	}
+	cfg.settings = l.settings
+
+	return cfg, nil
+}
+
+// DataSource returns the DSN for the configured driver
+func (c DBConfig) DataSource() string {
+	if c.DSN == "" && (c.Driver == "sqlite3" || c.Driver == "sqlite") {
+		return c.Path
+	}
+	return c.DSN
+}
+
+// URIs returns the configured TURN/STUN URIs
+func (c TURNConfig) URIs() []string {
+	uris := make([]string, 0)
+	for _, uri := range strings.Split(c.Server, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
 }
 
 // getEnv gets environment variable or returns default value
-func getEnv(key, defaultValue string) string {
+func (l *loader) getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
+		l.record(key, defaultValue, false)
 		return defaultValue
 	}
+	l.record(key, value, true)
 	return value
 }
 
 // getEnvInt gets environment variable as int or returns default value
-func getEnvInt(key string, defaultValue int) int {
+func (l *loader) getEnvInt(key string, defaultValue int) int {
 	value := os.Getenv(key)
 	if value == "" {
+		l.record(key, strconv.Itoa(defaultValue), false)
 		return defaultValue
 	}
 
 	intVal, err := strconv.Atoi(value)
 	if err != nil {
+		l.invalid(key, strconv.Itoa(defaultValue))
 		return defaultValue
 	}
+	l.record(key, strconv.Itoa(intVal), true)
 	return intVal
 }
 
+// getEnvFloat gets environment variable as float or returns default value
+func (l *loader) getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		l.record(key, formatFloat(defaultValue), false)
+		return defaultValue
+	}
+
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.invalid(key, formatFloat(defaultValue))
+		return defaultValue
+	}
+	l.record(key, formatFloat(floatVal), true)
+	return floatVal
+}
+
 // getEnvSlice gets environment variable as slice or returns default value
-func getEnvSlice(key, separator string, defaultValue []string) []string {
+func (l *loader) getEnvSlice(key, separator string, defaultValue []string) []string {
 	value := os.Getenv(key)
 	if value == "" {
+		l.record(key, strings.Join(defaultValue, separator), false)
 		return defaultValue
 	}
 
+	l.record(key, value, true)
 	return strings.Split(value, separator)
 }
 
+// getEnvMap gets environment variable as key/value map (e.g. "a=1,b=2")
+func (l *loader) getEnvMap(key, separator, assign string) map[string]string {
+	result := make(map[string]string)
+	value := os.Getenv(key)
+	if value == "" {
+		l.record(key, "", false)
+		return result
+	}
+
+	for _, pair := range strings.Split(value, separator) {
+		k, v, ok := strings.Cut(pair, assign)
+		if !ok {
+			continue
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	l.record(key, value, true)
+	return result
+}
+
+// getEnvIntMap gets environment variable as a map of non-negative ints
+// keyed by one of known (e.g. "telemetry=20,web=50")
+func (l *loader) getEnvIntMap(key string, known []string) map[string]int {
+	result := make(map[string]int)
+	l.getEnvEntries(key, known, func(name, value string) (string, bool) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return "", false
+		}
+		result[name] = n
+		return strconv.Itoa(n), true
+	})
+	return result
+}
+
+// getEnvDurationMap gets environment variable as a map of non-negative
+// durations keyed by one of known (e.g. "control_command=30s")
+func (l *loader) getEnvDurationMap(key string, known []string) map[string]time.Duration {
+	result := make(map[string]time.Duration)
+	l.getEnvEntries(key, known, func(name, value string) (string, bool) {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 {
+			return "", false
+		}
+		result[name] = duration
+		return duration.String(), true
+	})
+	return result
+}
+
+// getEnvChoiceMap gets environment variable as a map keyed by one of known
+// whose values are each one of choices (e.g. "control=disconnect")
+func (l *loader) getEnvChoiceMap(key string, known []string, choices ...string) map[string]string {
+	result := make(map[string]string)
+	l.getEnvEntries(key, known, func(name, value string) (string, bool) {
+		if !contains(choices, value) {
+			return "", false
+		}
+		result[name] = value
+		return value, true
+	})
+	return result
+}
+
+// getEnvEntries parses the "name=value,..." entries of a variable with
+// parse, which returns the value's effective form. Entries that do not
+// parse or name something not in known (nil accepts any name) are left
+// out, and the variable is then reported invalid with the entries kept.
+func (l *loader) getEnvEntries(key string, known []string, parse func(name, value string) (string, bool)) {
+	value := os.Getenv(key)
+	if value == "" {
+		l.record(key, "", false)
+		return
+	}
+
+	var kept []string
+	valid := true
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, entry, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || (known != nil && !contains(known, name)) {
+			valid = false
+			continue
+		}
+		effective, ok := parse(name, strings.TrimSpace(entry))
+		if !ok {
+			valid = false
+			continue
+		}
+		kept = append(kept, name+"="+effective)
+	}
+
+	if !valid {
+		l.invalid(key, strings.Join(kept, ","))
+		return
+	}
+	l.record(key, strings.Join(kept, ","), true)
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // getEnvDuration gets environment variable as duration or returns default value
-func getEnvDuration(key, defaultValue string) time.Duration {
-	value := getEnv(key, defaultValue)
+func (l *loader) getEnvDuration(key, defaultValue string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		duration, _ := time.ParseDuration(defaultValue)
+		l.record(key, duration.String(), false)
+		return duration
+	}
+
 	duration, err := time.ParseDuration(value)
 	if err != nil {
 		duration, _ = time.ParseDuration(defaultValue)
+		l.invalid(key, duration.String())
+		return duration
 	}
+	l.record(key, duration.String(), true)
 	return duration
 }
 
 // getEnvBool gets environment variable as bool or returns default value
-func getEnvBool(key string, defaultValue bool) bool {
+func (l *loader) getEnvBool(key string, defaultValue bool) bool {
 	value := os.Getenv(key)
 	if value == "" {
+		l.record(key, strconv.FormatBool(defaultValue), false)
 		return defaultValue
 	}
 
 	boolVal, err := strconv.ParseBool(value)
 	if err != nil {
+		l.invalid(key, strconv.FormatBool(defaultValue))
 		return defaultValue
 	}
+	l.record(key, strconv.FormatBool(boolVal), true)
 	return boolVal
 }
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSettingsSources tests source and invalid annotations
+func TestSettingsSources(t *testing.T) {
+	t.Setenv("SERVER_PORT", "9090")
+	t.Setenv("ENABLE_IP_WHITELIST", "yes")
+	t.Setenv("JWT_SECRET", "super-secret")
+
+	cfg, err := Load(Keys{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	settings := make(map[string]Setting)
+	for _, s := range cfg.Settings() {
+		settings[s.Key] = s
+	}
+
+	if s := settings["SERVER_PORT"]; s.Source != SourceEnv || s.Value != "9090" {
+		t.Errorf("Unexpected SERVER_PORT setting: %+v", s)
+	}
+	if s := settings["SERVER_HOST"]; s.Source != SourceDefault || s.Value != "0.0.0.0" {
+		t.Errorf("Unexpected SERVER_HOST setting: %+v", s)
+	}
+	if s := settings["ENABLE_IP_WHITELIST"]; !s.Invalid || s.Value != "false" || s.Source != SourceEnv {
+		t.Errorf("Expected invalid ENABLE_IP_WHITELIST to fall back to default: %+v", s)
+	}
+	if s := settings["JWT_SECRET"]; s.Value != redacted {
+		t.Errorf("JWT_SECRET not redacted: %+v", s)
+	}
+	if s := settings["JWT_EXPIRY"]; s.Value != "24h0m0s" {
+		t.Errorf("Expected effective duration, got %q", s.Value)
+	}
+}
+
+// TestRedact tests credential redaction in URLs and DSNs
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		key, value, want string
+	}{
+		{"TURN_PASSWORD", "hunter2", redacted},
+		{"TURN_PASSWORD", "", ""},
+		{"TURN_SECRET", "shared", redacted},
+		{"SERVER_HOST", "0.0.0.0", "0.0.0.0"},
+		{"DB_DSN", "postgres://oculo:pw@db:5432/oculo?sslmode=disable", "postgres://oculo:[REDACTED]@db:5432/oculo?[REDACTED]"},
+		{"DB_DSN", "host=db user=oculo password='p w' dbname=oculo", "host=db user=oculo password=[REDACTED] dbname=oculo"},
+		{"SHUTDOWN_WEBHOOK_URL", "https://hooks.example.com/services/T000/B000/XXX", "https://hooks.example.com/[REDACTED]"},
+		{"INFERENCE_ROBOT_URLS", "r1=http://u:p@ml:8000/infer,r2=http://ml:8001/", "r1=http://u:[REDACTED]@ml:8000/[REDACTED],r2=http://ml:8001/"},
+	}
+
+	for _, tt := range tests {
+		if got := redact(tt.key, tt.value); got != tt.want {
+			t.Errorf("redact(%s, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestEnvMaps tests that per-type map entries with a bad value or an
+// unknown key are dropped and reported
+func TestEnvMaps(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "telemetry=20, web=fast,robot=5")
+	t.Setenv("SEND_QUEUE_POLICIES", "control=disconnect,web=drop_newest")
+	t.Setenv("MESSAGE_TTLS", "location_update=2000ms,")
+	t.Setenv("DEDUP_WINDOWS", "control_command=-1s,unknown_type=30s")
+
+	cfg, err := Load(Keys{
+		ClientTypes:  []string{"web", "control", "telemetry"},
+		MessageTypes: []string{"control_command", "location_update"},
+	})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if limits := cfg.Server.RateLimits; len(limits) != 1 || limits["telemetry"] != 20 {
+		t.Errorf("Expected only telemetry=20, got %v", limits)
+	}
+	if policies := cfg.Server.SendQueuePolicies; len(policies) != 1 || policies["control"] != "disconnect" {
+		t.Errorf("Expected only control=disconnect, got %v", policies)
+	}
+	if ttls := cfg.Server.MessageTTLs; len(ttls) != 1 || ttls["location_update"] != 2*time.Second {
+		t.Errorf("Expected location_update=2s, got %v", ttls)
+	}
+	if windows := cfg.Server.DedupWindows; len(windows) != 0 {
+		t.Errorf("Expected no dedup windows, got %v", windows)
+	}
+
+	settings := make(map[string]Setting)
+	for _, s := range cfg.Settings() {
+		settings[s.Key] = s
+	}
+	want := map[string]Setting{
+		"RATE_LIMITS":         {Key: "RATE_LIMITS", Value: "telemetry=20", Source: SourceEnv, Invalid: true},
+		"SEND_QUEUE_POLICIES": {Key: "SEND_QUEUE_POLICIES", Value: "control=disconnect", Source: SourceEnv, Invalid: true},
+		"MESSAGE_TTLS":        {Key: "MESSAGE_TTLS", Value: "location_update=2s", Source: SourceEnv},
+		"DEDUP_WINDOWS":       {Key: "DEDUP_WINDOWS", Value: "", Source: SourceEnv, Invalid: true},
+		"EGRESS_LIMITS":       {Key: "EGRESS_LIMITS", Value: "", Source: SourceDefault},
+	}
+	for key, setting := range want {
+		if settings[key] != setting {
+			t.Errorf("Expected %+v, got %+v", setting, settings[key])
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Setting sources
+const (
+	SourceEnv     = "env"
+	SourceFile    = "file" // .env
+	SourceDefault = "default"
+)
+
+// redacted replaces secret values in Settings
+const redacted = "[REDACTED]"
+
+// secretKeys are never reported, only whether they are set
+var secretKeys = map[string]bool{
+	"CAPTCHA_SECRET":        true,
+	"JWT_SECRET":            true,
+	"INTROSPECTION_CLIENTS": true,
+	"SETUP_TOKEN":           true,
+	"SMTP_PASSWORD":         true,
+	"TURN_PASSWORD":         true,
+	"TURN_SECRET":           true,
+}
+
+// credentialKeys may embed credentials in a URL or DSN; those parts are redacted
+var credentialKeys = map[string]bool{
+	"BACKPLANE_NATS_URL":   true,
+	"BACKPLANE_REDIS_URL":  true,
+	"DB_DSN":               true,
+	"INFERENCE_URL":        true,
+	"INFERENCE_ROBOT_URLS": true,
+	"NATS_BRIDGE_URL":      true,
+	"SHUTDOWN_WEBHOOK_URL": true,
+}
+
+// Setting is one configuration variable as resolved by Load
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+
+	// Invalid is set when the variable was present but could not be parsed,
+	// so the default was used instead
+	Invalid bool `json:"invalid,omitempty"`
+}
+
+// loader records where each configuration variable came from
+type loader struct {
+	fromFile map[string]bool
+	settings []Setting
+}
+
+// newLoader must run before the .env file is loaded: godotenv does not
+// override variables that are already set, so a .env key absent from the
+// environment at this point is supplied by the file
+func newLoader() *loader {
+	fromFile := make(map[string]bool)
+	if fileVars, err := godotenv.Read(); err == nil {
+		for key := range fileVars {
+			if _, set := os.LookupEnv(key); !set {
+				fromFile[key] = true
+			}
+		}
+	}
+
+	return &loader{fromFile: fromFile}
+}
+
+// record stores the effective value of a variable
+func (l *loader) record(key, value string, set bool) {
+	source := SourceDefault
+	if set {
+		source = SourceEnv
+		if l.fromFile[key] {
+			source = SourceFile
+		}
+	}
+
+	l.settings = append(l.settings, Setting{
+		Key:    key,
+		Value:  redact(key, value),
+		Source: source,
+	})
+}
+
+// invalid records a variable that was set but fell back to its default.
+// The source still names where the unparsable value came from.
+func (l *loader) invalid(key, defaultValue string) {
+	l.record(key, defaultValue, true)
+	l.settings[len(l.settings)-1].Invalid = true
+}
+
+// Settings returns every variable read by Load with its effective value
+// and source. Secrets are redacted.
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, len(c.settings))
+	copy(settings, c.settings)
+	return settings
+}
+
+// formatFloat formats a float setting without trailing zeros
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// redact hides secrets and credentials embedded in a setting value
+func redact(key, value string) string {
+	if value == "" {
+		return value
+	}
+	if secretKeys[key] {
+		return redacted
+	}
+	if !credentialKeys[key] {
+		return value
+	}
+
+	// URL lists and maps such as INFERENCE_ROBOT_URLS are redacted per entry
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		prefix := ""
+		if name, rest, ok := strings.Cut(part, "="); ok && strings.Contains(rest, "://") {
+			prefix, part = name+"=", rest
+		}
+		parts[i] = prefix + redactCredentials(part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// dsnPasswordPattern matches password=... in key/value PostgreSQL DSNs
+var dsnPasswordPattern = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// redactCredentials strips the password, query and path from a URL (webhook
+// URLs commonly carry their token in the path) or the password from a
+// key/value DSN
+func redactCredentials(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return dsnPasswordPattern.ReplaceAllString(value, "${1}"+redacted)
+	}
+
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	if u.Scheme == "http" || u.Scheme == "https" {
+		if u.Path != "" && u.Path != "/" {
+			u.Path = "/" + redacted
+		}
+	}
+
+	// Keep the placeholder readable instead of percent-encoded
+	return strings.NewReplacer("%5BREDACTED%5D", redacted).Replace(u.String())
+}
@@ -0,0 +1,201 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Data section field types
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds nesting so a corrupt file cannot recurse forever
+const maxDepth = 32
+
+var errCorrupt = errors.New("corrupt data section")
+
+// decoder decodes values from a data section. Pointers are offsets from
+// the start of buf.
+type decoder struct {
+	buf   []byte
+	depth int
+}
+
+// decode returns the value at offset and the offset following it
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		return nil, 0, errCorrupt
+	}
+
+	typeNum, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typeNum == typePointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target)
+		return value, next, err
+	}
+
+	switch typeNum {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errCorrupt
+			}
+			if m[k], offset, err = d.decode(next); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, size)
+		for i := range a {
+			if a[i], offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) {
+		return nil, 0, errCorrupt
+	}
+	b := d.buf[offset:end]
+
+	switch typeNum {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return append([]byte(nil), b...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errCorrupt
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64, typeInt32:
+		if size > 8 {
+			return nil, 0, errCorrupt
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		if typeNum == typeInt32 {
+			return int64(int32(v)), end, nil
+		}
+		return v, end, nil
+	case typeUint128:
+		// Too big for the fields used here; keep the raw bytes
+		return append([]byte(nil), b...), end, nil
+	default:
+		return nil, 0, errCorrupt
+	}
+}
+
+// control reads a control byte and returns the field type, its size and
+// the offset of its payload
+func (d *decoder) control(offset uint) (int, uint, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errCorrupt
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typeNum := int(ctrl >> 5)
+	if typeNum == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errCorrupt
+		}
+		typeNum = 7 + int(d.buf[offset])
+		offset++
+	}
+	if typeNum == typePointer {
+		// Pointers encode their own size; see pointer
+		return typeNum, uint(ctrl & 0x1f), offset, nil
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return 0, 0, 0, errCorrupt
+		}
+		var extra uint
+		for _, c := range d.buf[offset : offset+n] {
+			extra = extra<<8 | uint(c)
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + extra
+		case 30:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+	return typeNum, size, offset, nil
+}
+
+// pointer decodes a pointer whose control bits are bits, returning its
+// target and the offset following it
+func (d *decoder) pointer(bits, offset uint) (uint, uint, error) {
+	n := (bits>>3)&0x3 + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errCorrupt
+	}
+	b := d.buf[offset : offset+n]
+
+	var target uint
+	if n < 4 {
+		target = bits & 0x7
+	}
+	for _, c := range b {
+		target = target<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		target += 2048
+	case 3:
+		target += 526336
+	}
+	return target, offset + n, nil
+}
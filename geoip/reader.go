@@ -0,0 +1,189 @@
+// Package geoip looks up the country and region of an IP address in a
+// MaxMind DB (.mmdb) file, such as GeoLite2-Country or GeoIP2-City.
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// metadataMarker precedes the metadata map at the end of the file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the run of zero bytes between the search tree
+// and the data section
+const dataSectionSeparator = 16
+
+// ErrInvalidDatabase is returned for files that are not MaxMind DBs
+var ErrInvalidDatabase = errors.New("invalid MaxMind database")
+
+// Location is what the database knows about an address
+type Location struct {
+	Country string // ISO 3166-1 alpha-2, e.g. "KR"; empty when unknown
+
+	// Regions are ISO 3166-2 subdivisions, most general first, e.g.
+	// ["US-CA"]; only City databases have them
+	Regions []string
+}
+
+// Reader looks up addresses in a database loaded into memory
+type Reader struct {
+	buf  []byte
+	tree []byte
+	data []byte
+
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint // Node reached after the ::/96 prefix of IPv4 addresses
+
+	// DatabaseType is e.g. "GeoLite2-Country"
+	DatabaseType string
+}
+
+// Open loads a database file
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses a database held in memory
+func FromBytes(buf []byte) (*Reader, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, ErrInvalidDatabase
+	}
+	metaBuf := buf[start+len(metadataMarker):]
+	value, _, err := (&decoder{buf: metaBuf}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidDatabase, err)
+	}
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidDatabase
+	}
+
+	r := &Reader{buf: buf}
+	r.nodeCount = metaUint(metadata, "node_count")
+	r.recordSize = metaUint(metadata, "record_size")
+	r.ipVersion = metaUint(metadata, "ip_version")
+	r.DatabaseType, _ = metadata["database_type"].(string)
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", ErrInvalidDatabase, r.ipVersion)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(start) {
+		return nil, fmt.Errorf("%w: search tree exceeds file", ErrInvalidDatabase)
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[treeSize+dataSectionSeparator : start]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// metaUint reads an unsigned metadata field
+func metaUint(metadata map[string]interface{}, key string) uint {
+	v, _ := metadata[key].(uint64)
+	return uint(v)
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node
+func (r *Reader) record(node uint, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		b := r.tree[node*8+bit*4:]
+		return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+	}
+}
+
+// Lookup returns the location of ip; both fields are empty when the
+// database has no entry (e.g. private addresses)
+func (r *Reader) Lookup(ip net.IP) (*Location, error) {
+	value, err := r.lookupValue(ip)
+	if err != nil || value == nil {
+		return &Location{}, err
+	}
+	return newLocation(value), nil
+}
+
+// lookupValue returns the decoded record for ip, or nil when there is none
+func (r *Reader) lookupValue(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		node = r.ipv4Start
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	} else if ip = ip.To16(); ip == nil {
+		return nil, errors.New("invalid IP address")
+	}
+
+	for i := 0; i < len(ip)*8 && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("%w: search tree too deep", ErrInvalidDatabase)
+	}
+	offset := node - r.nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.data)) {
+		return nil, fmt.Errorf("%w: record points outside data section", ErrInvalidDatabase)
+	}
+	value, _, err := (&decoder{buf: r.data}).decode(offset)
+	return value, err
+}
+
+// newLocation extracts the country and regions from a City or Country
+// record, falling back to the registered country
+func newLocation(value interface{}) *Location {
+	record, _ := value.(map[string]interface{})
+	location := &Location{}
+
+	for _, key := range []string{"country", "registered_country"} {
+		country, _ := record[key].(map[string]interface{})
+		if code, ok := country["iso_code"].(string); ok && code != "" {
+			location.Country = strings.ToUpper(code)
+			break
+		}
+	}
+
+	subdivisions, _ := record["subdivisions"].([]interface{})
+	for _, s := range subdivisions {
+		subdivision, _ := s.(map[string]interface{})
+		if code, ok := subdivision["iso_code"].(string); ok && code != "" && location.Country != "" {
+			location.Regions = append(location.Regions, location.Country+"-"+strings.ToUpper(code))
+		}
+	}
+	return location
+}
@@ -0,0 +1,179 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"testing"
+)
+
+// testDB builds a small IPv6 MaxMind DB in memory
+type testDB struct {
+	nodes [][2]int // -1 is empty, -2-n points to data offset n
+	data  bytes.Buffer
+}
+
+func newTestDB() *testDB {
+	return &testDB{nodes: [][2]int{{-1, -1}}}
+}
+
+// insert maps a network to a value
+func (db *testDB) insert(cidr string, value []byte) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	// IPv4 networks live under ::/96
+	ip, bits := network.IP.To16(), 0
+	if ip4 := network.IP.To4(); ip4 != nil {
+		ip = append(make(net.IP, 12), ip4...)
+		ones, _ := network.Mask.Size()
+		bits = 96 + ones
+	} else {
+		bits, _ = network.Mask.Size()
+	}
+
+	offset := db.data.Len()
+	db.data.Write(value)
+
+	node := 0
+	for i := 0; i < bits; i++ {
+		bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+		if i == bits-1 {
+			db.nodes[node][bit] = -2 - offset
+			break
+		}
+		if db.nodes[node][bit] < 0 {
+			db.nodes = append(db.nodes, [2]int{-1, -1})
+			db.nodes[node][bit] = len(db.nodes) - 1
+		}
+		node = db.nodes[node][bit]
+	}
+}
+
+// build serializes the database with the given record size
+func (db *testDB) build(recordSize int) []byte {
+	count := len(db.nodes)
+	value := func(record int) uint32 {
+		switch {
+		case record == -1:
+			return uint32(count)
+		case record < -1:
+			return uint32(count + dataSectionSeparator + (-2 - record))
+		default:
+			return uint32(record)
+		}
+	}
+
+	var out bytes.Buffer
+	for _, node := range db.nodes {
+		left, right := value(node[0]), value(node[1])
+		switch recordSize {
+		case 24:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>20)&0xf0 | byte(right>>24)&0x0f, byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			out.Write([]byte{byte(left >> 24), byte(left >> 16), byte(left >> 8), byte(left),
+				byte(right >> 24), byte(right >> 16), byte(right >> 8), byte(right)})
+		}
+	}
+	out.Write(make([]byte, dataSectionSeparator))
+	out.Write(db.data.Bytes())
+	out.Write(metadataMarker)
+	out.Write(encode(map[string]interface{}{
+		"node_count":    uint32(count),
+		"record_size":   uint32(recordSize),
+		"ip_version":    uint32(6),
+		"database_type": "Test-City",
+	}))
+	return out.Bytes()
+}
+
+// encode serializes a value in the data section format (sizes under 29)
+func encode(v interface{}) []byte {
+	var b bytes.Buffer
+	switch v := v.(type) {
+	case string:
+		b.WriteByte(typeString<<5 | byte(len(v)))
+		b.WriteString(v)
+	case uint32:
+		b.Write([]byte{typeUint32<<5 | 4, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	case map[string]interface{}:
+		b.WriteByte(typeMap<<5 | byte(len(v)))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.Write(encode(k))
+			b.Write(encode(v[k]))
+		}
+	case []interface{}:
+		b.Write([]byte{byte(len(v)), typeArray - 7})
+		for _, item := range v {
+			b.Write(encode(item))
+		}
+	case []byte: // Pre-encoded, e.g. a pointer
+		b.Write(v)
+	}
+	return b.Bytes()
+}
+
+// TestLookup tests country and region lookups for every record size
+func TestLookup(t *testing.T) {
+	db := newTestDB()
+	db.insert("203.0.113.0/24", encode(map[string]interface{}{
+		"country":      map[string]interface{}{"iso_code": "US"},
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "CA"}},
+	}))
+	db.insert("2001:db8::/32", encode(map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "de"},
+	}))
+	// registered_country points at the "de" country map above (after the
+	// 1-byte map header and the encoded "country" key)
+	pointer := []byte{typePointer << 5, byte(db.data.Len() - len(encode(map[string]interface{}{"country": map[string]interface{}{"iso_code": "de"}})) + 1 + len(encode("country")))}
+	db.insert("198.51.100.0/24", encode(map[string]interface{}{
+		"registered_country": pointer,
+	}))
+
+	tests := []struct {
+		ip      string
+		country string
+		regions []string
+	}{
+		{"203.0.113.9", "US", []string{"US-CA"}},
+		{"::ffff:203.0.113.9", "US", []string{"US-CA"}},
+		{"2001:db8::1", "DE", nil},
+		{"198.51.100.1", "DE", nil},
+		{"10.0.0.1", "", nil},
+		{"2001:db9::1", "", nil},
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		r, err := FromBytes(db.build(recordSize))
+		if err != nil {
+			t.Fatalf("FromBytes(%d) failed: %v", recordSize, err)
+		}
+		if r.DatabaseType != "Test-City" {
+			t.Errorf("Expected database type Test-City, got %q", r.DatabaseType)
+		}
+		for _, tt := range tests {
+			location, err := r.Lookup(net.ParseIP(tt.ip))
+			if err != nil {
+				t.Errorf("Lookup(%s) with %d-bit records failed: %v", tt.ip, recordSize, err)
+				continue
+			}
+			if location.Country != tt.country || len(location.Regions) != len(tt.regions) ||
+				(len(tt.regions) > 0 && location.Regions[0] != tt.regions[0]) {
+				t.Errorf("Lookup(%s) with %d-bit records = %+v, expected %s %v", tt.ip, recordSize, location, tt.country, tt.regions)
+			}
+		}
+	}
+
+	if _, err := FromBytes([]byte("not a database")); err == nil {
+		t.Error("Expected an invalid database to be rejected")
+	}
+}
@@ -0,0 +1,306 @@
+package incident
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"oculo-pilot-server/audit"
+	"oculo-pilot-server/recording"
+	"oculo-pilot-server/telemetry"
+	"oculo-pilot-server/websocket"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxWindow bounds how much time one bundle covers
+	MaxWindow = 24 * time.Hour
+
+	// maxEntries bounds each section of a bundle: audit events, telemetry
+	// samples and recorded messages (across every session)
+	maxEntries = 5000
+)
+
+// ErrInvalidWindow is returned for a window that is empty, reversed or
+// longer than MaxWindow
+var ErrInvalidWindow = errors.New("invalid incident window")
+
+// Request selects what a bundle covers. Room, RobotID and Username narrow
+// telemetry and recorded messages; Username also narrows audit events.
+type Request struct {
+	Since    time.Time
+	Until    time.Time
+	Room     string // As in /api/rooms/{id}; empty covers every room
+	RobotID  string
+	Username string
+
+	// Hold places the recording sessions in the window under legal hold,
+	// so pruning cannot remove what the bundle references
+	Hold bool
+}
+
+// Entry is one step of the session timeline: a connection lifecycle audit
+// event or a recorded message
+type Entry struct {
+	Time         time.Time       `json:"time"`
+	Source       string          `json:"source"` // "audit" or "recording"
+	Kind         string          `json:"kind"`   // Audit action or message type
+	Actor        string          `json:"actor"`
+	ConnectionID string          `json:"connection_id,omitempty"`
+	Direction    string          `json:"direction,omitempty"`
+	RobotID      string          `json:"robot_id,omitempty"`
+	Room         string          `json:"room,omitempty"`
+	RecordingID  int64           `json:"recording_id,omitempty"`
+	MessageID    int64           `json:"message_id,omitempty"`
+	Detail       interface{}     `json:"detail,omitempty"`
+	Payload      json.RawMessage `json:"payload,omitempty"`
+}
+
+// Manifest describes a bundle: the window and filters it covers
+type Manifest struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	GeneratedBy string    `json:"generated_by"`
+	Since       time.Time `json:"since"`
+	Until       time.Time `json:"until"`
+	Room        string    `json:"room,omitempty"`
+	RobotID     string    `json:"robot_id,omitempty"`
+	Username    string    `json:"username,omitempty"`
+
+	// Sections that hit their size limit, and those whose store is
+	// disabled in this deployment
+	Truncated   []string `json:"truncated,omitempty"`
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// Bundle is everything recorded about an incident window
+type Bundle struct {
+	Manifest
+
+	Timeline   []Entry              `json:"timeline"`
+	Audit      []audit.Event        `json:"audit"`
+	Telemetry  []telemetry.Sample   `json:"telemetry"`
+	Recordings []*recording.Session `json:"recordings"`
+}
+
+// Builder collects bundles from the recording, audit and telemetry stores.
+// The audit log and telemetry store may be nil when they are disabled.
+type Builder struct {
+	recorder  *recording.Recorder
+	audit     *audit.Log
+	telemetry *telemetry.Store
+}
+
+// NewBuilder creates a bundle builder
+func NewBuilder(recorder *recording.Recorder, auditLog *audit.Log, telemetryStore *telemetry.Store) *Builder {
+	return &Builder{recorder: recorder, audit: auditLog, telemetry: telemetryStore}
+}
+
+// Build snapshots the stores for request on behalf of generatedBy
+func (b *Builder) Build(request Request, generatedBy string) (*Bundle, error) {
+	if !request.Since.Before(request.Until) || request.Until.Sub(request.Since) > MaxWindow {
+		return nil, ErrInvalidWindow
+	}
+
+	bundle := &Bundle{
+		Manifest: Manifest{
+			GeneratedAt: time.Now().UTC(),
+			GeneratedBy: generatedBy,
+			Since:       request.Since.UTC(),
+			Until:       request.Until.UTC(),
+			Room:        request.Room,
+			RobotID:     request.RobotID,
+			Username:    request.Username,
+		},
+		Timeline:   []Entry{},
+		Audit:      []audit.Event{},
+		Telemetry:  []telemetry.Sample{},
+		Recordings: []*recording.Session{},
+	}
+	room := ""
+	if request.Room != "" {
+		room = websocket.RoomID(request.Room)
+	}
+
+	if err := b.collectAudit(bundle, request); err != nil {
+		return nil, err
+	}
+	if err := b.collectTelemetry(bundle, request, room); err != nil {
+		return nil, err
+	}
+	if err := b.collectRecordings(bundle, request, room); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(bundle.Timeline, func(i, j int) bool {
+		return bundle.Timeline[i].Time.Before(bundle.Timeline[j].Time)
+	})
+	return bundle, nil
+}
+
+// collectAudit adds the audit events in the window, oldest first, and the
+// connection lifecycle ones to the timeline
+func (b *Builder) collectAudit(bundle *Bundle, request Request) error {
+	if b.audit == nil {
+		bundle.Unavailable = append(bundle.Unavailable, "audit")
+		return nil
+	}
+	events, err := b.audit.Query(audit.Filter{
+		Since: request.Since,
+		Until: request.Until,
+		Actor: request.Username,
+		Limit: maxEntries + 1,
+	})
+	if err != nil {
+		return err
+	}
+	if len(events) > maxEntries {
+		// Keep the newest, closest to when the incident was noticed
+		events = events[:maxEntries]
+		bundle.Truncated = append(bundle.Truncated, "audit")
+	}
+
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		bundle.Audit = append(bundle.Audit, event)
+		if strings.HasPrefix(event.Action, "ws.") {
+			bundle.Timeline = append(bundle.Timeline, Entry{
+				Time:         event.Time,
+				Source:       "audit",
+				Kind:         event.Action,
+				Actor:        event.Actor,
+				ConnectionID: event.Target,
+				Detail:       event.Detail,
+			})
+		}
+	}
+	return nil
+}
+
+// collectTelemetry adds the telemetry samples in the window, oldest first
+func (b *Builder) collectTelemetry(bundle *Bundle, request Request, room string) error {
+	if b.telemetry == nil {
+		bundle.Unavailable = append(bundle.Unavailable, "telemetry")
+		return nil
+	}
+	samples, err := b.telemetry.Query(telemetry.Filter{
+		RobotID: request.RobotID,
+		Room:    room,
+		Since:   request.Since,
+		Until:   request.Until,
+		Limit:   maxEntries + 1,
+	})
+	if err != nil {
+		return err
+	}
+	if len(samples) > maxEntries {
+		samples = samples[:maxEntries]
+		bundle.Truncated = append(bundle.Truncated, "telemetry")
+	}
+	for i := len(samples) - 1; i >= 0; i-- {
+		bundle.Telemetry = append(bundle.Telemetry, samples[i])
+	}
+	return nil
+}
+
+// collectRecordings adds references to the recording sessions in the
+// window, placing them under legal hold when asked, and their messages in
+// the window to the timeline
+func (b *Builder) collectRecordings(bundle *Bundle, request Request, room string) error {
+	sessions, err := b.recorder.SessionsBetween(request.Since, request.Until)
+	if err != nil {
+		return err
+	}
+
+	remaining := maxEntries
+	for _, session := range sessions {
+		if request.Hold && !session.LegalHold {
+			if err := b.recorder.SetLegalHold(session.ID, true); err != nil {
+				return err
+			}
+			session.LegalHold = true
+			log.Printf("⚖️  Recording session %d placed under legal hold by %s for an incident", session.ID, bundle.GeneratedBy)
+		}
+		bundle.Recordings = append(bundle.Recordings, session)
+
+		if remaining < 0 {
+			continue
+		}
+		messages, err := b.recorder.FindMessages(session.ID, recording.MessageFilter{
+			Since:    request.Since,
+			Until:    request.Until,
+			Room:     room,
+			RobotID:  request.RobotID,
+			Username: request.Username,
+			Limit:    remaining + 1,
+		})
+		if err != nil {
+			return err
+		}
+		if len(messages) > remaining {
+			messages = messages[:remaining]
+			bundle.Truncated = append(bundle.Truncated, "timeline")
+			remaining = -1 // Only references for the later sessions
+		} else {
+			remaining -= len(messages)
+		}
+
+		for _, message := range messages {
+			bundle.Timeline = append(bundle.Timeline, Entry{
+				Time:         message.Time,
+				Source:       "recording",
+				Kind:         message.Type,
+				Actor:        message.Username,
+				ConnectionID: message.ConnectionID,
+				Direction:    message.Direction,
+				RobotID:      message.RobotID,
+				Room:         message.Room,
+				RecordingID:  session.ID,
+				MessageID:    message.ID,
+				Payload:      message.Payload,
+			})
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the bundle as one JSON document
+func (b *Bundle) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(b)
+}
+
+// WriteZip writes the bundle as a zip archive with one JSON file per
+// section and a manifest describing the window
+func (b *Bundle) WriteZip(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"manifest.json", b.Manifest},
+		{"timeline.json", b.Timeline},
+		{"audit.json", b.Audit},
+		{"telemetry.json", b.Telemetry},
+		{"recordings.json", b.Recordings},
+	}
+	for _, file := range files {
+		out, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: b.GeneratedAt,
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.v); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,163 @@
+package incident
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"oculo-pilot-server/audit"
+	"oculo-pilot-server/recording"
+	"oculo-pilot-server/store"
+	"oculo-pilot-server/telemetry"
+	"oculo-pilot-server/websocket"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestBuildBundle tests that a bundle holds the window's audit events,
+// telemetry and recordings, with a merged timeline and legal hold applied
+func TestBuildBundle(t *testing.T) {
+	db, err := store.Open(store.DriverSQLite, filepath.Join(t.TempDir(), "incident.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Migrate(audit.Schema, recording.Schema, telemetry.Schema); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	auditLog := audit.NewLog(db, 24*time.Hour)
+	telemetryStore := telemetry.NewStore(db, telemetry.Config{BatchSize: 10, FlushInterval: time.Hour, Retention: 24 * time.Hour})
+	recorder := recording.NewRecorder(db, 24*time.Hour)
+	go recorder.Run()
+
+	start := time.Now()
+	session, err := recorder.StartSession("shift", "", "admin")
+	if err != nil {
+		t.Fatalf("StartSession() failed: %v", err)
+	}
+	at := func(offset time.Duration) time.Time { return start.Add(offset) }
+	record := func(offset time.Duration, username, data string) {
+		recorder.RecordMessage(websocket.RecordedMessage{
+			Time:         at(offset),
+			Direction:    websocket.DirectionIn,
+			ConnectionID: "conn-" + username,
+			Username:     username,
+			ClientType:   websocket.ClientTypeWeb,
+			Data:         []byte(data),
+		})
+	}
+	record(time.Second, "alice", `{"type":"control_command"}`)
+	record(3*time.Second, "bob", `{"type":"control_command"}`)
+	record(time.Hour, "alice", `{"type":"emergency_stop"}`) // After the window
+	recorder.Stop()
+
+	for _, event := range []audit.Event{
+		{Time: at(0), Actor: "alice", Action: websocket.AuditConnect, Target: "conn-alice"},
+		{Time: at(2 * time.Second), Actor: "admin", Action: "user.deactivate", Target: "bob"},
+		{Time: at(-time.Hour), Actor: "alice", Action: websocket.AuditDisconnect, Target: "conn-old"},
+	} {
+		if err := auditLog.Write(event); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+	if err := telemetryStore.Write([]websocket.TelemetrySample{
+		{Time: at(2 * time.Second), RobotID: "rover-1", Room: "default", Type: "location_update", Data: []byte(`{}`)},
+		{Time: at(2 * time.Second), RobotID: "rover-2", Room: "lab", Type: "location_update", Data: []byte(`{}`)},
+	}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	builder := NewBuilder(recorder, auditLog, telemetryStore)
+	request := Request{Since: at(-time.Second), Until: at(time.Minute), Room: "default", Hold: true}
+	bundle, err := builder.Build(request, "admin")
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	var kinds []string
+	for _, entry := range bundle.Timeline {
+		kinds = append(kinds, entry.Source+":"+entry.Actor+":"+entry.Kind)
+	}
+	want := []string{"audit:alice:ws.connect", "recording:alice:control_command", "recording:bob:control_command"}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Expected timeline %v, got %v", want, kinds)
+	}
+	if len(bundle.Audit) != 2 || bundle.Audit[0].Action != websocket.AuditConnect {
+		t.Errorf("Expected the 2 audit events in the window oldest first, got %+v", bundle.Audit)
+	}
+	if len(bundle.Telemetry) != 1 || bundle.Telemetry[0].RobotID != "rover-1" {
+		t.Errorf("Expected only the default room's telemetry, got %+v", bundle.Telemetry)
+	}
+	if len(bundle.Recordings) != 1 || bundle.Recordings[0].ID != session.ID || !bundle.Recordings[0].LegalHold {
+		t.Fatalf("Expected the held session referenced, got %+v", bundle.Recordings)
+	}
+	if stored, err := recorder.Session(session.ID); err != nil || !stored.LegalHold {
+		t.Errorf("Expected the session under legal hold, got %+v (%v)", stored, err)
+	}
+
+	// A username narrows every section
+	request = Request{Since: at(-time.Second), Until: at(time.Minute), Username: "bob"}
+	if bundle, err = builder.Build(request, "admin"); err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	if len(bundle.Timeline) != 1 || len(bundle.Audit) != 0 {
+		t.Errorf("Expected only bob's message, got %d timeline entries and %d audit events", len(bundle.Timeline), len(bundle.Audit))
+	}
+
+	var archive bytes.Buffer
+	if err := bundle.WriteZip(&archive); err != nil {
+		t.Fatalf("WriteZip() failed: %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read the archive: %v", err)
+	}
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	if want := []string{"manifest.json", "timeline.json", "audit.json", "telemetry.json", "recordings.json"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected files %v, got %v", want, names)
+	}
+	manifestFile, _ := reader.Open("manifest.json")
+	var manifest Manifest
+	if err := json.NewDecoder(manifestFile).Decode(&manifest); err != nil || manifest.Username != "bob" {
+		t.Errorf("Expected the manifest to describe the request, got %+v (%v)", manifest, err)
+	}
+}
+
+// TestBuildWithoutStores tests that disabled stores are reported and bad
+// windows refused
+func TestBuildWithoutStores(t *testing.T) {
+	db, err := store.Open(store.DriverSQLite, filepath.Join(t.TempDir(), "incident.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Migrate(recording.Schema); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	builder := NewBuilder(recording.NewRecorder(db, time.Hour), nil, nil)
+
+	now := time.Now()
+	bundle, err := builder.Build(Request{Since: now.Add(-time.Minute), Until: now}, "admin")
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+	if !reflect.DeepEqual(bundle.Unavailable, []string{"audit", "telemetry"}) {
+		t.Errorf("Expected audit and telemetry unavailable, got %v", bundle.Unavailable)
+	}
+
+	for _, request := range []Request{
+		{Since: now, Until: now},
+		{Since: now, Until: now.Add(-time.Minute)},
+		{Since: now.Add(-MaxWindow - time.Second), Until: now},
+	} {
+		if _, err := builder.Build(request, "admin"); !errors.Is(err, ErrInvalidWindow) {
+			t.Errorf("Expected ErrInvalidWindow for %v - %v, got %v", request.Since, request.Until, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net"
+	"oculo-pilot-server/config"
+	"oculo-pilot-server/proxyproto"
+)
+
+// listenNetwork picks the socket family for a LISTEN_ADDRS entry. IP
+// literals are bound to their own family only, so 0.0.0.0:8080 and
+// [::]:8080 can be listed side by side; hostnames use both.
+func listenNetwork(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// listen opens a listener per address, wrapped for the PROXY protocol when
+// enabled. On failure, listeners already opened are closed.
+func listen(addrs []string, network func(string) string, cfg config.ServerConfig) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addrs))
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	for _, addr := range addrs {
+		l, err := net.Listen(network(addr), addr)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		if cfg.ProxyProtocol {
+			proxied, err := proxyproto.NewListener(l, cfg.ProxyProtocolTrusted)
+			if err != nil {
+				l.Close()
+				closeAll()
+				return nil, err
+			}
+			l = proxied
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,175 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sampleEntry counts occurrences of one message key within a window
+type sampleEntry struct {
+	windowStart time.Time
+	logged      int
+	suppressed  int
+	lastMessage string
+}
+
+// Sampler rate-limits repetitive log lines: for each key, the first burst
+// messages in every window are logged and the rest are only counted, with
+// one summary line per key when the window closes
+type Sampler struct {
+	burst  int
+	window time.Duration
+
+	entries map[string]*sampleEntry
+	mu      sync.Mutex
+
+	// printf writes a log line (log.Printf by default)
+	printf func(format string, args ...interface{})
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewSampler creates a sampler logging up to burst messages per key per window
+func NewSampler(burst int, window time.Duration) *Sampler {
+	if burst < 1 {
+		burst = 1
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	return &Sampler{
+		burst:   burst,
+		window:  window,
+		entries: make(map[string]*sampleEntry),
+		printf:  log.Printf,
+		stop:    make(chan struct{}),
+	}
+}
+
+// defaultSampler is used by the package-level Sampled function
+var defaultSampler = NewSampler(5, time.Minute)
+
+// Default returns the process-wide sampler
+func Default() *Sampler {
+	return defaultSampler
+}
+
+// Configure replaces the process-wide sampler settings
+func Configure(burst int, window time.Duration) {
+	configured := NewSampler(burst, window)
+
+	defaultSampler.mu.Lock()
+	defer defaultSampler.mu.Unlock()
+	defaultSampler.burst = configured.burst
+	defaultSampler.window = configured.window
+}
+
+// Sampled logs through the process-wide sampler
+func Sampled(key, format string, args ...interface{}) {
+	defaultSampler.Printf(key, format, args...)
+}
+
+// Printf logs a message unless its key already hit the burst limit in the
+// current window
+func (s *Sampler) Printf(key, format string, args ...interface{}) {
+	s.printfAt(time.Now(), key, format, args...)
+}
+
+// printfAt is Printf with an explicit clock for tests
+func (s *Sampler) printfAt(now time.Time, key, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+
+	s.mu.Lock()
+	entry := s.entries[key]
+	if entry != nil && now.Sub(entry.windowStart) >= s.window {
+		s.summarizeLocked(key, entry)
+		entry = nil
+	}
+	if entry == nil {
+		entry = &sampleEntry{windowStart: now}
+		s.entries[key] = entry
+	}
+
+	if entry.logged >= s.burst {
+		entry.suppressed++
+		entry.lastMessage = message
+		s.mu.Unlock()
+		return
+	}
+	entry.logged++
+	burstReached := entry.logged == s.burst
+	window := s.window
+	s.mu.Unlock()
+
+	if burstReached {
+		s.printf("%s (further %q messages sampled for %v)", message, key, window)
+		return
+	}
+	s.printf("%s", message)
+}
+
+// Flush emits summaries for windows that have closed and forgets idle keys
+func (s *Sampler) Flush() {
+	s.flushAt(time.Now())
+}
+
+// flushAt is Flush with an explicit clock for tests
+func (s *Sampler) flushAt(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry := s.entries[key]
+		if now.Sub(entry.windowStart) < s.window {
+			continue
+		}
+		s.summarizeLocked(key, entry)
+		delete(s.entries, key)
+	}
+}
+
+// summarizeLocked logs how many messages were suppressed for a key
+func (s *Sampler) summarizeLocked(key string, entry *sampleEntry) {
+	if entry.suppressed == 0 {
+		return
+	}
+	s.printf("🔁 %d more %q messages suppressed in the last %v (latest: %s)",
+		entry.suppressed, key, s.window, entry.lastMessage)
+}
+
+// Run flushes summaries once per window until Stop is called
+func (s *Sampler) Run() {
+	s.mu.Lock()
+	window := s.window
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			// Summarize open windows too so no counts are lost on shutdown
+			s.flushAt(time.Now().Add(window))
+			return
+		case <-ticker.C:
+			s.Flush()
+		}
+	}
+}
+
+// Stop ends the flush loop after a final flush
+func (s *Sampler) Stop() {
+	s.once.Do(func() { close(s.stop) })
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSampler returns a sampler that records lines instead of logging
+func newTestSampler(burst int, window time.Duration) (*Sampler, *[]string) {
+	var lines []string
+	s := NewSampler(burst, window)
+	s.printf = func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	return s, &lines
+}
+
+// TestSamplerSuppressesAfterBurst tests burst limiting and the summary line
+func TestSamplerSuppressesAfterBurst(t *testing.T) {
+	s, lines := newTestSampler(3, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		s.printfAt(now.Add(time.Duration(i)*time.Second), "invalid_token", "bad token %d", i)
+	}
+	if len(*lines) != 3 {
+		t.Fatalf("Expected 3 lines within burst, got %d: %v", len(*lines), *lines)
+	}
+	if !strings.Contains((*lines)[2], "sampled") {
+		t.Errorf("Expected last burst line to announce sampling: %s", (*lines)[2])
+	}
+
+	// Flushing before the window closes emits nothing
+	s.flushAt(now.Add(30 * time.Second))
+	if len(*lines) != 3 {
+		t.Errorf("Expected no summary before window closes, got %v", *lines)
+	}
+
+	s.flushAt(now.Add(time.Minute))
+	if len(*lines) != 4 {
+		t.Fatalf("Expected summary line, got %v", *lines)
+	}
+	if !strings.Contains((*lines)[3], "7 more") || !strings.Contains((*lines)[3], "bad token 9") {
+		t.Errorf("Unexpected summary: %s", (*lines)[3])
+	}
+}
+
+// TestSamplerNewWindow tests that a new window logs again and summarizes the old one
+func TestSamplerNewWindow(t *testing.T) {
+	s, lines := newTestSampler(1, time.Minute)
+	now := time.Now()
+
+	s.printfAt(now, "malformed", "first")
+	s.printfAt(now.Add(time.Second), "malformed", "second")
+	s.printfAt(now.Add(2*time.Minute), "malformed", "third")
+
+	expected := []string{"first", "1 more", "third"}
+	if len(*lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %v", len(expected), *lines)
+	}
+	for i, want := range expected {
+		if !strings.Contains((*lines)[i], want) {
+			t.Errorf("Line %d = %q, expected to contain %q", i, (*lines)[i], want)
+		}
+	}
+}
+
+// TestSamplerKeysIndependent tests that keys are limited separately
+func TestSamplerKeysIndependent(t *testing.T) {
+	s, lines := newTestSampler(1, time.Minute)
+	now := time.Now()
+
+	s.printfAt(now, "a", "a1")
+	s.printfAt(now, "a", "a2")
+	s.printfAt(now, "b", "b1")
+
+	if len(*lines) != 2 {
+		t.Errorf("Expected one line per key, got %v", *lines)
+	}
+}
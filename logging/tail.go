@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"strings"
+	"sync"
+)
+
+// Tail keeps the most recent log lines in memory for the admin panel.
+// It is an io.Writer meant to be added to the standard logger's output;
+// the log package issues one Write per entry.
+type Tail struct {
+	lines []string
+	next  int
+	full  bool
+	mu    sync.Mutex
+}
+
+// NewTail creates a tail holding up to size lines
+func NewTail(size int) *Tail {
+	if size < 1 {
+		size = 1
+	}
+	return &Tail{lines: make([]string, size)}
+}
+
+// defaultTail captures the process log when installed by main
+var defaultTail = NewTail(1000)
+
+// DefaultTail returns the process-wide log tail
+func DefaultTail() *Tail {
+	return defaultTail
+}
+
+// Write stores each line of p
+func (t *Tail) Write(p []byte) (int, error) {
+	text := strings.TrimRight(string(p), "\n")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, line := range strings.Split(text, "\n") {
+		t.lines[t.next] = line
+		t.next = (t.next + 1) % len(t.lines)
+		if t.next == 0 {
+			t.full = true
+		}
+	}
+	return len(p), nil
+}
+
+// Lines returns up to n of the most recent lines, oldest first
+func (t *Tail) Lines(n int) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := t.next
+	if t.full {
+		count = len(t.lines)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+
+	result := make([]string, 0, n)
+	start := t.next - n
+	if start < 0 {
+		start += len(t.lines)
+	}
+	for i := 0; i < n; i++ {
+		result = append(result, t.lines[(start+i)%len(t.lines)])
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// TestTailLines tests keeping only the most recent lines
+func TestTailLines(t *testing.T) {
+	tail := NewTail(3)
+
+	if lines := tail.Lines(10); len(lines) != 0 {
+		t.Fatalf("Expected empty tail, got %v", lines)
+	}
+
+	for i := 1; i <= 2; i++ {
+		fmt.Fprintf(tail, "line %d\n", i)
+	}
+	if lines := tail.Lines(10); !reflect.DeepEqual(lines, []string{"line 1", "line 2"}) {
+		t.Errorf("Unexpected lines before wrap: %v", lines)
+	}
+
+	for i := 3; i <= 5; i++ {
+		fmt.Fprintf(tail, "line %d\n", i)
+	}
+	if lines := tail.Lines(0); !reflect.DeepEqual(lines, []string{"line 3", "line 4", "line 5"}) {
+		t.Errorf("Unexpected lines after wrap: %v", lines)
+	}
+	if lines := tail.Lines(2); !reflect.DeepEqual(lines, []string{"line 4", "line 5"}) {
+		t.Errorf("Unexpected last 2 lines: %v", lines)
+	}
+
+	// Multi-line writes are split
+	fmt.Fprint(tail, "a\nb\n")
+	if lines := tail.Lines(2); !reflect.DeepEqual(lines, []string{"a", "b"}) {
+		t.Errorf("Unexpected multi-line split: %v", lines)
+	}
+}
@@ -0,0 +1,61 @@
+// Package mailer sends plain-text email over SMTP
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP sends mail through one SMTP server. STARTTLS is used whenever the
+// server offers it.
+type SMTP struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTP creates a sender; authentication is skipped when username is empty
+func NewSMTP(host string, port int, username, password, from string) *SMTP {
+	return &SMTP{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send delivers a plain-text message to one recipient
+func (m *SMTP) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.addr, auth, m.from, []string{to}, message(m.from, to, subject, body, time.Now()))
+}
+
+// message formats a UTF-8 plain-text message. Header values are stripped
+// of line breaks so they cannot inject headers.
+func message(from, to, subject, body string, date time.Time) []byte {
+	clean := strings.NewReplacer("\r", "", "\n", "")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", clean.Replace(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", clean.Replace(to))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", clean.Replace(subject)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMessage tests header formatting and that header values cannot
+// inject extra headers
+func TestMessage(t *testing.T) {
+	date := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := string(message("pilot@example.com", "ops@example.com\r\nBcc: mallory@example.com", "로그인 링크", "line 1\nline 2", date))
+
+	headers, body, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("Expected a blank line between headers and body: %q", msg)
+	}
+	if strings.Contains(headers, "\r\nBcc:") {
+		t.Errorf("Expected line breaks in headers to be stripped: %q", headers)
+	}
+	for _, want := range []string{
+		"From: pilot@example.com\r\n",
+		"Subject: =?utf-8?q?",
+		"Date: Fri, 02 Jan 2026 03:04:05 +0000",
+		"Content-Type: text/plain; charset=utf-8",
+	} {
+		if !strings.Contains(headers, want) {
+			t.Errorf("Expected %q in headers: %q", want, headers)
+		}
+	}
+	if body != "line 1\r\nline 2" {
+		t.Errorf("Expected CRLF line endings in the body, got %q", body)
+	}
+}
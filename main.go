@@ -2,52 +2,409 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"oculo-pilot-server/admin"
 	"oculo-pilot-server/api"
+	"oculo-pilot-server/audit"
 	"oculo-pilot-server/auth"
+	"oculo-pilot-server/backplane"
+	"oculo-pilot-server/bootstrap"
 	"oculo-pilot-server/config"
+	"oculo-pilot-server/geoip"
+	"oculo-pilot-server/incident"
+	"oculo-pilot-server/logging"
+	"oculo-pilot-server/mailer"
+	"oculo-pilot-server/metrics"
 	"oculo-pilot-server/middleware"
+	"oculo-pilot-server/recording"
+	"oculo-pilot-server/store"
+	"oculo-pilot-server/telemetry"
 	"oculo-pilot-server/websocket"
+	"oculo-pilot-server/wireguard"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 const version = "1.0.0"
 
+// pairingBanThreshold is how many wrong pairing codes one IP may submit
+// within a code's lifetime when authentication bans are otherwise disabled
+const pairingBanThreshold = 10
+
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	startedAt := time.Now()
+
+	// Load configuration; per-type settings may only name the client types
+	// and the message types the hub has handlers for
+	hub := websocket.NewHub()
+	cfg, err := config.Load(configKeys(hub))
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fingerprint" {
+		if err := runFingerprint(cfg); err != nil {
+			log.Fatalf("Fingerprint failed: %v", err)
+		}
+		return
+	}
+
+	// Sample repetitive error logs (invalid token floods, malformed messages)
+	logging.Configure(cfg.Logging.SampleBurst, cfg.Logging.SampleWindow)
+	go logging.Default().Run()
+	defer logging.Default().Stop()
+
+	// Keep recent log lines for the admin panel
+	log.SetOutput(io.MultiWriter(os.Stderr, logging.DefaultTail()))
+
+	// Unparsable settings fall back to their defaults, or lose the bad
+	// entries for per-type maps; see /api/admin/config
+	for _, setting := range cfg.Settings() {
+		if setting.Invalid {
+			log.Printf("⚠️  Invalid %s, using %q", setting.Key, setting.Value)
+		}
+	}
+
+	// Configure password hashing
+	if err := auth.ConfigureHashing(auth.HashConfig{
+		Algorithm:     cfg.Auth.PasswordHash,
+		BcryptCost:    cfg.Auth.BcryptCost,
+		Argon2Memory:  uint32(cfg.Auth.Argon2Memory),
+		Argon2Time:    uint32(cfg.Auth.Argon2Time),
+		Argon2Threads: uint8(cfg.Auth.Argon2Threads),
+	}); err != nil {
+		log.Fatalf("Invalid password hashing config: %v", err)
+	}
+
+	// Configure password policy
+	policy := auth.DefaultPasswordPolicy()
+	policy.MinLength = cfg.Auth.PasswordMinLength
+	policy.RequireUpper = cfg.Auth.PasswordRequireUpper
+	policy.RequireLower = cfg.Auth.PasswordRequireLower
+	policy.RequireDigit = cfg.Auth.PasswordRequireDigit
+	policy.RequireSymbol = cfg.Auth.PasswordRequireSymbol
+	if cfg.Auth.PasswordBanCommon {
+		policy.BanCommonPasswords()
+	}
+	if cfg.Auth.PasswordBannedFile != "" {
+		if err := policy.LoadBannedPasswords(cfg.Auth.PasswordBannedFile); err != nil {
+			log.Fatalf("Failed to load banned passwords: %v", err)
+		}
+	}
+	auth.SetPasswordPolicy(policy)
+
 	// Initialize database
-	db, err := auth.NewDB(cfg.DB.Path)
+	db, err := store.Open(cfg.DB.Driver, cfg.DB.DataSource())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
+	if _, err := db.Migrate(schemas...); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	users := auth.FromStore(db)
 
-	log.Println("✅ Database initialized")
+	log.Printf("✅ Database initialized (driver=%s)", cfg.DB.Driver)
 
-	// Create default admin user if no users exist
-	if err := createDefaultUser(db); err != nil {
-		log.Printf("Warning: %v", err)
+	// Initialize auth service
+	authService := auth.NewService(users, cfg.Auth.JWTSecret, cfg.Auth.JWTExpiry)
+	authService.SetTokenIdentity(cfg.Auth.JWTIssuer, cfg.Auth.JWTAudience)
+	if cfg.Auth.JWTIssuer != "" || cfg.Auth.JWTAudience != "" {
+		log.Printf("🔐 JWT issuer=%q audience=%q required", cfg.Auth.JWTIssuer, cfg.Auth.JWTAudience)
+	}
+	authService.ConfigureRegistration(cfg.Auth.EnableRegistration, cfg.Auth.RegistrationApproval)
+	if !cfg.Auth.EnableRegistration {
+		log.Println("🚪 Self-registration disabled")
+	} else if cfg.Auth.RegistrationApproval {
+		log.Println("🚪 New registrations require admin approval")
+	}
+	registrationChallenge, err := auth.NewRegistrationChallenge(cfg.Auth.RegistrationChallenge,
+		cfg.Auth.CaptchaSiteKey, cfg.Auth.CaptchaSecret, cfg.Auth.PowDifficulty)
+	if err != nil {
+		log.Fatalf("Invalid registration challenge: %v", err)
+	}
+	if registrationChallenge != nil {
+		log.Printf("🧩 Registration requires a %s challenge", cfg.Auth.RegistrationChallenge)
+	}
+	if err := authService.SetLoginPolicy(cfg.Auth.LoginPolicy); err != nil {
+		log.Fatalf("Invalid login policy: %v", err)
+	}
+	log.Printf("👥 Concurrent login policy: %s", cfg.Auth.LoginPolicy)
+	if cfg.Auth.PasswordHistory > 0 {
+		authService.UsePasswordHistory(users, cfg.Auth.PasswordHistory)
+		log.Printf("🔁 Passwords may not repeat the last %d", cfg.Auth.PasswordHistory)
 	}
 
-	// Initialize auth service
-	authService := auth.NewService(db, cfg.Auth.JWTSecret, cfg.Auth.JWTExpiry)
+	// Provision users from the bootstrap file; without an admin account the
+	// initial admin is created through first-run setup
+	if err := applyBootstrap(authService, cfg.Auth.BootstrapFile); err != nil {
+		log.Fatalf("Failed to apply bootstrap file: %v", err)
+	}
+	if err := enableSetup(authService, cfg.Auth.SetupToken); err != nil {
+		log.Fatalf("Failed to enable setup: %v", err)
+	}
+	if err := authService.UseKeyStore(users); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	if err := authService.UseGroupStore(users); err != nil {
+		log.Fatalf("Failed to load groups: %v", err)
+	}
+	if err := authService.UseBanStore(users); err != nil {
+		log.Fatalf("Failed to load bans: %v", err)
+	}
+	if cfg.Auth.JWTSigningKeyFile != "" {
+		signingKey, err := auth.LoadSigningKey(cfg.Auth.JWTSigningKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to load JWT signing key: %v", err)
+		}
+		if err := authService.UseSigningKey(signingKey); err != nil {
+			log.Fatalf("Failed to store JWT signing key: %v", err)
+		}
+	}
+	if keys := authService.SigningKeys(); len(keys) > 0 {
+		current := keys[len(keys)-1]
+		log.Printf("🔏 JWT signing with %s (kid=%s, %d key(s) accepted)", current.Algorithm, current.ID, len(keys))
+	}
+	if cfg.Auth.JWTLegacyTokens {
+		authService.AcceptLegacyTokens(true)
+		log.Printf("⚠️  Tokens signed with JWT_SECRET and no kid are always accepted (JWT_LEGACY_TOKENS)")
+	}
 
-	// Initialize WebSocket hub
-	hub := websocket.NewHub()
+	// Configure the WebSocket hub; robots assigned to groups only accept
+	// commands from group members
+	hub.SetControlPolicy(authService)
+	hub.SetControlIdleTimeout(cfg.Server.ControlIdleTimeout)
+	hub.SetStaleTimeout(cfg.Server.StaleTimeout)
+	hub.SetResumeWindow(cfg.Server.ResumeWindow)
+	hub.SetCommandQoSTTL(cfg.Server.CommandQoSTTL)
+	hub.SetCommandTimeout(cfg.Server.CommandTimeout)
+
+	// Reject control commands outside what this deployment's robots accept
+	commandRules := websocket.CommandRules{
+		Actions:  cfg.Server.CommandActions,
+		Limits:   make(map[string]websocket.CommandRange),
+		Required: cfg.Server.CommandRequired,
+	}
+	for field, value := range cfg.Server.CommandLimits {
+		limit, err := websocket.ParseCommandRange(value)
+		if err != nil {
+			log.Fatalf("Invalid COMMAND_LIMITS entry %s=%s: %v", field, value, err)
+		}
+		commandRules.Limits[field] = limit
+	}
+	hub.SetCommandRules(commandRules)
+
+	// Limit how fast each connection may send messages; per-type overrides
+	// burst to twice their rate like the default
+	rateLimits := websocket.RateLimitConfig{
+		Default:    websocket.RateLimit{PerSecond: float64(cfg.Server.RateLimit), Burst: cfg.Server.RateLimitBurst},
+		Types:      make(map[websocket.ClientType]websocket.RateLimit),
+		MaxStrikes: cfg.Server.RateLimitStrikes,
+	}
+	for clientType, perSecond := range cfg.Server.RateLimits {
+		rateLimits.Types[websocket.ClientType(clientType)] = websocket.RateLimit{PerSecond: float64(perSecond), Burst: 2 * perSecond}
+	}
+	hub.SetRateLimits(rateLimits)
+
+	// Robots are disconnected when they stop reading commands unless
+	// configured otherwise; everyone else skips stale messages
+	sendQueue := websocket.SendQueueConfig{
+		Size:     cfg.Server.SendQueueSize,
+		Policies: map[websocket.ClientType]websocket.SendPolicy{websocket.ClientTypeControl: websocket.SendDisconnect},
+	}
+	for clientType, policy := range cfg.Server.SendQueuePolicies {
+		sendQueue.Policies[websocket.ClientType(clientType)] = websocket.SendPolicy(policy)
+	}
+	hub.SetSendQueue(sendQueue)
+
+	// Throttle what chatty client types are sent so media keeps its share
+	// of a constrained uplink
+	egressLimits := make(websocket.EgressLimits)
+	for clientType, perSecond := range cfg.Server.EgressLimits {
+		egressLimits[websocket.ClientType(clientType)] = perSecond
+	}
+	hub.SetEgressLimits(egressLimits)
+
+	// Slow viewers skip stale telemetry instead of replaying a backlog
+	hub.SetMessageTTLs(cfg.Server.MessageTTLs)
+
+	// A command retried after a reconnect must not run twice on the robot
+	hub.SetDedupWindows(cfg.Server.DedupWindows)
+
+	// Keep room for robots when many viewers connect
+	hub.SetClientLimits(websocket.ClientLimits{
+		websocket.ClientTypeWeb:       cfg.Server.MaxWebClients,
+		websocket.ClientTypeVideo:     cfg.Server.MaxVideoClients,
+		websocket.ClientTypeControl:   cfg.Server.MaxControlClients,
+		websocket.ClientTypeTelemetry: cfg.Server.MaxTelemetryClients,
+		websocket.ClientTypeAudio:     cfg.Server.MaxAudioClients,
+		websocket.ClientTypeMonitor:   cfg.Server.MaxMonitorClients,
+	})
+
+	// Compress verbose JSON for browsers and telemetry; Pi video and
+	// control clients keep their CPU for streaming by default
+	if cfg.Server.Compression {
+		if cfg.Server.CompressionLevel < 1 || cfg.Server.CompressionLevel > 9 {
+			log.Fatalf("Invalid WS_COMPRESSION_LEVEL %d (want 1-9)", cfg.Server.CompressionLevel)
+		}
+		compression := websocket.CompressionConfig{
+			Types: make(map[websocket.ClientType]bool),
+			Level: cfg.Server.CompressionLevel,
+		}
+		for _, clientType := range cfg.Server.CompressionTypes {
+			compression.Types[websocket.ClientType(strings.TrimSpace(clientType))] = true
+		}
+		hub.SetCompression(compression)
+	}
+	if cfg.Anomaly.Enabled {
+		hub.SetAnomalyDetector(websocket.NewAnomalyDetector(websocket.AnomalyConfig{
+			Fields:    cfg.Anomaly.Fields,
+			Alpha:     cfg.Anomaly.Alpha,
+			Threshold: cfg.Anomaly.Threshold,
+			Warmup:    cfg.Anomaly.Warmup,
+		}))
+		log.Printf("📈 Telemetry anomaly detection enabled: %v", cfg.Anomaly.Fields)
+	}
+	if cfg.Inference.URL != "" || len(cfg.Inference.RobotURLs) > 0 {
+		hub.SetInferenceHook(websocket.NewInferenceHook(websocket.InferenceConfig{
+			DefaultURL: cfg.Inference.URL,
+			RobotURLs:  cfg.Inference.RobotURLs,
+			WindowSize: cfg.Inference.WindowSize,
+			Timeout:    cfg.Inference.Timeout,
+		}))
+		log.Printf("🧠 Telemetry inference hook enabled (window=%d)", cfg.Inference.WindowSize)
+	}
+
+	// Record connection lifecycle events for /api/audit
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		auditLog = audit.NewLog(db, cfg.Audit.Retention)
+		go auditLog.Run()
+		defer auditLog.Stop()
+		hub.SetAuditRecorder(auditLog)
+		log.Printf("📝 Audit log enabled (kept %v)", cfg.Audit.Retention)
+	}
+
+	// Record routed messages for incident review; admins start and stop
+	// sessions through /api/admin/recordings
+	recorder := recording.NewRecorder(db, cfg.Recording.Retention)
+	go recorder.Run()
+	defer recorder.Stop()
+	hub.SetMessageRecorder(recorder)
+	if cfg.Recording.Enabled {
+		if _, err := recorder.StartSession("startup", "", "system"); err != nil {
+			log.Fatalf("Failed to start recording session: %v", err)
+		}
+		log.Printf("⏺️  Recording WebSocket messages (kept %v)", cfg.Recording.Retention)
+	}
+	player := recording.NewPlayer(recorder, hub)
+	defer player.Stop()
+
+	// Persist routed telemetry for /api/admin/telemetry
+	var telemetryStore *telemetry.Store
+	if cfg.Telemetry.Enabled {
+		if cfg.Telemetry.BatchSize < 1 || cfg.Telemetry.BatchSize > telemetry.MaxBatchSize {
+			log.Fatalf("TELEMETRY_BATCH_SIZE must be between 1 and %d", telemetry.MaxBatchSize)
+		}
+		telemetryStore = telemetry.NewStore(db, telemetry.Config{
+			BatchSize:     cfg.Telemetry.BatchSize,
+			FlushInterval: cfg.Telemetry.FlushInterval,
+			Retention:     cfg.Telemetry.Retention,
+		})
+		go telemetryStore.Run()
+		defer telemetryStore.Stop()
+		hub.SetTelemetrySink(telemetryStore)
+		log.Printf("🛰️  Telemetry persistence enabled (batch=%d, flush=%v, kept %v)",
+			cfg.Telemetry.BatchSize, cfg.Telemetry.FlushInterval, cfg.Telemetry.Retention)
+	}
+
+	// Share routing with the other replicas behind the load balancer
+	if cfg.Backplane.RedisURL != "" && cfg.Backplane.NATSURL != "" {
+		log.Fatalf("BACKPLANE_REDIS_URL and BACKPLANE_NATS_URL are mutually exclusive")
+	}
+	brokerTLS, err := backplane.LoadTLS(cfg.Backplane.TLSCAFile, cfg.Backplane.TLSCertFile, cfg.Backplane.TLSKeyFile)
+	if err != nil {
+		log.Fatalf("Invalid backplane TLS settings: %v", err)
+	}
+	var nats *backplane.NATS
+	if cfg.Backplane.NATSURL != "" {
+		nats, err = backplane.NewNATS(cfg.Backplane.NATSURL, cfg.Backplane.Prefix, brokerTLS)
+		if err != nil {
+			log.Fatalf("Failed to connect to the NATS backplane: %v", err)
+		}
+		defer nats.Close()
+		if err := hub.SetBackplane(nats); err != nil {
+			log.Fatalf("Failed to subscribe to the NATS backplane: %v", err)
+		}
+		log.Printf("🔗 Backplane enabled: %s", nats)
+	}
+	if cfg.Backplane.RedisURL != "" {
+		redis, err := backplane.NewRedis(cfg.Backplane.RedisURL, cfg.Backplane.Prefix, brokerTLS)
+		if err != nil {
+			log.Fatalf("Failed to connect to the Redis backplane: %v", err)
+		}
+		defer redis.Close()
+		if err := hub.SetBackplane(redis); err != nil {
+			log.Fatalf("Failed to subscribe to the Redis backplane: %v", err)
+		}
+		log.Printf("🔗 Backplane enabled: %s", redis)
+	}
+
+	// Let edge services without WebSocket exchange messages over NATS
+	if cfg.Backplane.BridgeURL != "" {
+		bridge := nats
+		if bridge == nil || cfg.Backplane.BridgeURL != cfg.Backplane.NATSURL {
+			bridge, err = backplane.NewNATS(cfg.Backplane.BridgeURL, cfg.Backplane.Prefix, brokerTLS)
+			if err != nil {
+				log.Fatalf("Failed to connect to the NATS bridge: %v", err)
+			}
+			defer bridge.Close()
+		}
+		if err := hub.SetEdgeBridge(bridge, cfg.Backplane.Prefix); err != nil {
+			log.Fatalf("Failed to subscribe to the NATS bridge: %v", err)
+		}
+		log.Printf("🛰️  Edge bridge enabled: %s", bridge)
+	}
 	go hub.Run()
 
 	log.Println("✅ WebSocket hub started")
 
+	// Persist periodic hub stats for /api/metrics/history
+	var history *metrics.History
+	if cfg.Metrics.HistoryEnabled {
+		history = metrics.NewHistory(db, hub, cfg.Metrics.SampleInterval, cfg.Metrics.Retention)
+		go history.Run()
+		defer history.Stop()
+		log.Printf("📊 Stats history enabled (every %v, kept %v)", cfg.Metrics.SampleInterval, cfg.Metrics.Retention)
+	}
+
+	// WireGuard mode: listen only on the tunnel and accept only its peers
+	var tunnel *wireguard.Monitor
+	if cfg.WireGuard.Interface != "" {
+		tunnel, err = wireguard.NewMonitor(cfg.WireGuard.Interface)
+		if err != nil {
+			log.Fatalf("Failed to initialize WireGuard mode: %v", err)
+		}
+		go tunnel.Run(cfg.WireGuard.RefreshInterval)
+		defer tunnel.Stop()
+		log.Printf("🛡️  WireGuard mode on %s (%d peers)", cfg.WireGuard.Interface, len(tunnel.Status().Peers))
+	}
+
 	// Create router
 	router := mux.NewRouter()
 
@@ -56,56 +413,440 @@ func main() {
 	router.Use(middleware.CORS(cfg.Server.AllowedOrigins))
 
 	// Health check (no auth required)
-	router.Handle("/health", api.NewHealthHandler(version)).Methods("GET")
+	healthHandler := api.NewHealthHandler(version)
+	if tunnel != nil {
+		healthHandler.SetTunnel(tunnel)
+	}
+	router.Handle("/health", healthHandler).Methods("GET")
+
+	// Fingerprint of the device listener certificate, for clients that pin it
+	var tlsCert *api.CertificateInfo
+	if cfg.MTLS.Addr != "" {
+		if tlsCert, err = api.LoadCertificateInfo(cfg.MTLS.CertFile, cfg.MTLS.KeyFile); err != nil {
+			log.Fatalf("Failed to load mTLS certificate: %v", err)
+		}
+		log.Printf("📌 Device listener certificate SHA-256 %s", tlsCert.SHA256)
+	}
+
+	// Client addresses come from the socket; X-Forwarded-For is only
+	// believed from the configured reverse proxies. With PROXY protocol the
+	// listener already reports the client, so the header is never used.
+	trustedProxies := cfg.Server.TrustedProxies
+	if cfg.Server.ProxyProtocol && len(trustedProxies) > 0 {
+		log.Printf("⚠️  TRUSTED_PROXIES ignored: PROXY protocol supplies the client address")
+		trustedProxies = nil
+	}
+	if err := middleware.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	if len(trustedProxies) > 0 {
+		log.Printf("🔁 X-Forwarded-For trusted from %v", trustedProxies)
+	}
+
+	// Temporarily ban IPs that keep failing login or WebSocket token validation
+	bans := middleware.NewBanList(cfg.Auth.BanThreshold, cfg.Auth.BanWindow, cfg.Auth.BanDuration)
+	if cfg.Auth.BanThreshold > 0 {
+		log.Printf("⛔ IPs banned for %v after %d auth failures within %v",
+			cfg.Auth.BanDuration, cfg.Auth.BanThreshold, cfg.Auth.BanWindow)
+	}
+	bans.SetBanHook(func(ban middleware.Ban) {
+		hub.PublishSecurityEvent(websocket.SecurityIPBanned, map[string]interface{}{
+			"remote_addr": ban.IP,
+			"failures":    ban.Failures,
+			"until":       ban.Until,
+		})
+	})
 
 	// Auth endpoints (no auth required)
-	router.Handle("/api/login", api.NewLoginHandler(authService)).Methods("POST", "OPTIONS")
-	router.Handle("/api/register", api.NewRegisterHandler(authService)).Methods("POST", "OPTIONS")
+	loginHandler := api.NewLoginHandler(authService, hub, bans)
+	loginHandler.SetSecurityEvents(hub)
+	router.Handle("/api/login", bans.Middleware(loginHandler)).Methods("POST", "OPTIONS")
+	var sessionCookie *middleware.SessionCookie
+	if cfg.Auth.SessionCookie {
+		sessionCookie = middleware.NewSessionCookie(cfg.Auth.SessionCookieSecure, cfg.Auth.JWTExpiry)
+		loginHandler.SetSessionCookie(sessionCookie)
+		router.Handle("/api/logout", api.NewLogoutHandler(sessionCookie)).Methods("POST", "OPTIONS")
+		log.Printf("🍪 Cookie sessions enabled (secure=%v)", cfg.Auth.SessionCookieSecure)
+	}
+	if cfg.Auth.MagicLinkURL != "" {
+		if cfg.SMTP.Host == "" || cfg.SMTP.From == "" {
+			log.Fatal("MAGIC_LINK_URL requires SMTP_HOST and SMTP_FROM")
+		}
+		if err := authService.EnableMagicLinks(cfg.Auth.MagicLinkTTL, cfg.Auth.MagicLinkScopes); err != nil {
+			log.Fatalf("Invalid MAGIC_LINK_SCOPES: %v", err)
+		}
+		sender := mailer.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From)
+		router.Handle("/api/login/magic", bans.Middleware(
+			api.NewMagicLinkHandler(authService, sender, cfg.Auth.MagicLinkURL))).Methods("POST", "OPTIONS")
+		verifyHandler := api.NewMagicLinkVerifyHandler(authService)
+		if sessionCookie != nil {
+			verifyHandler.SetSessionCookie(sessionCookie)
+		}
+		router.Handle("/api/login/magic/verify", bans.Middleware(verifyHandler)).Methods("GET")
+		log.Printf("✉️  Magic-link login enabled via %s (ttl=%v, scopes=%v)", cfg.SMTP.Host, cfg.Auth.MagicLinkTTL, cfg.Auth.MagicLinkScopes)
+	}
+	router.Handle("/api/register", api.NewRegisterHandler(authService, registrationChallenge)).Methods("POST", "OPTIONS")
+	router.Handle("/api/register/challenge", api.NewRegisterChallengeHandler(registrationChallenge)).Methods("GET", "OPTIONS")
+	if len(cfg.Auth.IntrospectionClients) > 0 {
+		router.Handle("/api/token/introspect", api.NewIntrospectHandler(authService, cfg.Auth.IntrospectionClients)).Methods("POST")
+		log.Printf("🔎 Token introspection enabled for %d client(s)", len(cfg.Auth.IntrospectionClients))
+	}
+	router.Handle("/api/token", api.NewServiceTokenHandler(authService)).Methods("POST")
+	// Wrong pairing codes lock out only their source; the endpoint keeps a
+	// ban list of its own when AUTH_BAN_THRESHOLD disables the shared one
+	pairBans := bans
+	if cfg.Auth.BanThreshold <= 0 {
+		pairBans = middleware.NewBanList(pairingBanThreshold, auth.PairingTTL, auth.PairingTTL)
+	}
+	router.Handle("/api/pair", pairBans.Middleware(api.NewPairHandler(authService, pairBans))).Methods("POST", "OPTIONS")
+	router.Handle("/api/setup", api.NewSetupHandler(authService)).Methods("GET", "POST", "OPTIONS")
+
+	// Public token verification keys (no auth required)
+	router.Handle("/.well-known/jwks.json", api.NewJWKSHandler(authService)).Methods("GET")
+
+	// Password change (accepts restricted tokens issued for forced password changes)
+	router.Handle("/api/password", middleware.Auth(&passwordChangeValidator{authService})(
+		api.NewChangePasswordHandler(authService))).Methods("POST", "OPTIONS")
+
+	// Signing key management (requires admin)
+	requireAdmin := func(h http.Handler) http.Handler {
+		return middleware.Auth(&authValidator{authService})(
+			middleware.RequireScope(auth.ScopeAPIAdmin)(
+				middleware.RequireRole(authService, auth.RoleAdmin)(h)))
+	}
+	router.Handle("/api/admin/keys", requireAdmin(api.NewSigningKeysHandler(authService))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/keys/rotate", requireAdmin(api.NewRotateKeyHandler(authService))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/config", requireAdmin(api.NewConfigHandler(cfg, version))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/users", requireAdmin(api.NewUsersHandler(authService))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/users/{username}", requireAdmin(api.NewUserHandler(authService))).Methods("GET", "PUT", "DELETE", "OPTIONS")
+	router.Handle("/api/admin/users/{username}/approve", requireAdmin(api.NewApproveUserHandler(authService))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/users/{username}/deactivate", requireAdmin(api.NewUserActivationHandler(authService, false))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/users/{username}/reactivate", requireAdmin(api.NewUserActivationHandler(authService, true))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/service-accounts", requireAdmin(api.NewServiceAccountsHandler(authService))).Methods("GET", "POST", "OPTIONS")
+	router.Handle("/api/admin/service-accounts/{name}/rotate", requireAdmin(api.NewRotateServiceAccountHandler(authService))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/pairing", requireAdmin(api.NewPairingHandler(authService))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/pairing/{name}", requireAdmin(api.NewPairingHandler(authService))).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/admin/groups", requireAdmin(api.NewGroupsHandler(authService))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/groups/{name}", requireAdmin(api.NewGroupHandler(authService))).Methods("GET", "PUT", "DELETE", "OPTIONS")
+	router.Handle("/api/admin/connections", requireAdmin(api.NewConnectionsHandler(hub))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/clients/{connection_id}/disconnect", requireAdmin(api.NewDisconnectClientHandler(hub))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/broadcast", requireAdmin(api.NewBroadcastHandler(hub))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/estop", requireAdmin(api.NewEmergencyStopHandler(hub))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/routes", requireAdmin(api.NewRoutesHandler(hub))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/commands", requireAdmin(api.NewCommandsHandler(hub))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/dead-letters", requireAdmin(api.NewDeadLettersHandler(hub))).Methods("GET", "DELETE", "OPTIONS")
+	router.Handle("/api/admin/logs", requireAdmin(api.NewLogsHandler(logging.DefaultTail()))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/tls", requireAdmin(api.NewTLSHandler(tlsCert))).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/bans", requireAdmin(api.NewBansHandler(bans))).Methods("GET", "DELETE", "OPTIONS")
+	router.Handle("/api/admin/bans/{ip}", requireAdmin(api.NewBansHandler(bans))).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/admin/blocklist", requireAdmin(api.NewBlocklistHandler(authService, hub))).Methods("GET", "POST", "OPTIONS")
+	router.Handle("/api/admin/blocklist/{id:[0-9]+}", requireAdmin(api.NewBlocklistHandler(authService, hub))).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/admin/recordings", requireAdmin(api.NewRecordingsHandler(recorder))).Methods("GET", "POST", "OPTIONS")
+	router.Handle("/api/admin/recordings/stop", requireAdmin(api.NewStopRecordingHandler(recorder))).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/recordings/{id:[0-9]+}", requireAdmin(api.NewRecordingHandler(recorder))).Methods("GET", "PATCH", "DELETE", "OPTIONS")
+	router.Handle("/api/admin/playback", requireAdmin(api.NewPlaybackHandler(player))).Methods("GET", "POST", "DELETE", "OPTIONS")
+	if tunnel != nil {
+		router.Handle("/api/admin/tunnel", requireAdmin(api.NewTunnelHandler(tunnel))).Methods("GET", "OPTIONS")
+	}
+	if telemetryStore != nil {
+		router.Handle("/api/admin/telemetry", requireAdmin(api.NewTelemetryHandler(telemetryStore))).Methods("GET", "OPTIONS")
+	}
+	if auditLog != nil {
+		router.Handle("/api/audit", requireAdmin(api.NewAuditHandler(auditLog))).Methods("GET", "OPTIONS")
+	}
+	router.Handle("/api/incidents", requireAdmin(api.NewIncidentHandler(
+		incident.NewBuilder(recorder, auditLog, telemetryStore)))).Methods("POST", "OPTIONS")
+
+	// One-time WebSocket tickets (requires auth)
+	router.Handle("/api/ws-ticket", middleware.Auth(&authValidator{authService})(
+		api.NewWSTicketHandler(authService))).Methods("POST", "OPTIONS")
+
+	// Time-limited TURN credentials (requires auth)
+	if cfg.TURN.Secret != "" {
+		router.Handle("/api/turn-credentials", middleware.Auth(&authValidator{authService})(
+			api.NewTURNCredentialsHandler(cfg.TURN.Secret, cfg.TURN.URIs(), cfg.TURN.CredentialTTL))).Methods("GET", "OPTIONS")
+	}
+
+	// Per-room status for dashboards (requires auth)
+	router.Handle("/api/rooms/{id}/status", middleware.Auth(&authValidator{authService})(
+		api.NewRoomStatusHandler(hub))).Methods("GET", "OPTIONS")
+
+	// Current hub stats (requires auth)
+	router.Handle("/api/metrics", middleware.Auth(&authValidator{authService})(
+		api.NewMetricsHandler(hub))).Methods("GET", "OPTIONS")
+
+	// Metrics history (requires auth)
+	if history != nil {
+		router.Handle("/api/metrics/history", middleware.Auth(&authValidator{authService})(
+			api.NewMetricsHistoryHandler(history))).Methods("GET", "OPTIONS")
+	}
 
 	// WebSocket endpoint (requires auth)
 	wsHandler := websocket.NewHandler(hub, &authValidator{authService},
 		cfg.Server.AllowedNetworks, cfg.Server.EnableIPWhitelist,
 		cfg.Server.HandshakeTimeout, cfg.Server.MaxMessageSize)
-	router.Handle("/ws", wsHandler)
+	wsHandler.SetHandshakeResend(cfg.Server.HandshakeResend)
+	wsHandler.StartHostRefresh(cfg.Server.DNSRefreshMin, cfg.Server.DNSRefreshMax)
+	if tunnel != nil {
+		wsHandler.SetPeerVerifier(tunnel)
+	}
+	wsHandler.SetAuthFailureRecorder(bans)
+	if cfg.Auth.SessionCookie {
+		wsHandler.SetSessionCookie(middleware.SessionCookieName)
+	}
+	if err := wsHandler.SetAllowedOrigins(cfg.Server.AllowedOrigins); err != nil {
+		log.Fatalf("Invalid ALLOWED_ORIGINS: %v", err)
+	}
+	defer wsHandler.Stop()
+	router.Handle("/ws", bans.Middleware(wsHandler))
+	router.Handle("/api/admin/whitelist", requireAdmin(api.NewWhitelistHandler(wsHandler))).Methods("GET", "OPTIONS")
+
+	// Admin panel (embedded in the binary; data comes from /api/admin)
+	router.Handle("/admin", http.RedirectHandler("/admin/", http.StatusMovedPermanently))
+	router.PathPrefix("/admin/").Handler(http.StripPrefix("/admin", admin.Handler()))
 
 	// Static files
 	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./static")))
 
-	// Start server
-	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("🚀 Server starting on %s", addr)
+	// Start server; SERVER_HOST/SERVER_PORT keep the default dual-stack
+	// socket, LISTEN_ADDRS entries are bound per family
+	addrs := []string{net.JoinHostPort(cfg.Server.Host, cfg.Server.Port)}
+	network := func(string) string { return "tcp" }
+	if len(cfg.Server.ListenAddrs) > 0 {
+		if tunnel != nil {
+			log.Fatal("LISTEN_ADDRS cannot be combined with WIREGUARD_INTERFACE")
+		}
+		addrs, network = nil, listenNetwork
+		for _, listenAddr := range cfg.Server.ListenAddrs {
+			addrs = append(addrs, strings.TrimSpace(listenAddr))
+		}
+	}
+	if tunnel != nil {
+		if addrs[0], err = tunnel.ListenAddr(cfg.Server.Port); err != nil {
+			log.Fatalf("Failed to resolve WireGuard listen address: %v", err)
+		}
+	}
+	log.Printf("🚀 Server starting on %s", strings.Join(addrs, ", "))
 	log.Printf("🔐 JWT expiry: %v", cfg.Auth.JWTExpiry)
-	log.Printf("🌐 Allowed origins: %v", cfg.Server.AllowedOrigins)
+	log.Printf("🔑 Password hashing: %s", cfg.Auth.PasswordHash)
+	log.Printf("🌐 Allowed origins: %v (and same origin)", cfg.Server.AllowedOrigins)
+	for _, origin := range cfg.Server.AllowedOrigins {
+		if strings.TrimSpace(origin) == websocket.AnyOrigin {
+			log.Printf("⚠️  ALLOWED_ORIGINS=* lets any website open WebSocket connections with a visitor's session")
+		}
+	}
 	if cfg.Server.EnableIPWhitelist {
 		log.Printf("🔒 IP whitelist enabled: %v", cfg.Server.AllowedNetworks)
 	}
-	log.Printf("⏱️  Handshake timeout: %v", cfg.Server.HandshakeTimeout)
+	log.Printf("⏱️  Handshake timeout: %v (request resent every %v)", cfg.Server.HandshakeTimeout, cfg.Server.HandshakeResend)
 	log.Printf("📦 Max message size: %d bytes", cfg.Server.MaxMessageSize)
+	if cfg.Server.RateLimit > 0 || len(cfg.Server.RateLimits) > 0 {
+		log.Printf("🚦 WebSocket rate limit: %d msg/s, burst %d (per type: %v, disconnect after %d warnings)",
+			cfg.Server.RateLimit, cfg.Server.RateLimitBurst, cfg.Server.RateLimits, cfg.Server.RateLimitStrikes)
+	}
+	log.Printf("📬 Send queue: %d messages (policies: %v)", cfg.Server.SendQueueSize, cfg.Server.SendQueuePolicies)
+	if limits := hub.ClientLimits(); len(limits) > 0 {
+		log.Printf("🚧 Client limits: %v", limits)
+	}
+	if limits := hub.EgressLimits(); len(limits) > 0 {
+		log.Printf("🐢 Egress limits (bytes/s): %v", limits)
+	}
+	if ttls := hub.MessageTTLs(); len(ttls) > 0 {
+		log.Printf("⌛ Message TTLs: %v", ttls)
+	}
+	if windows := hub.DedupWindows(); len(windows) > 0 {
+		log.Printf("🔁 Duplicate suppression windows: %v", windows)
+	}
+	if cfg.Server.Compression {
+		log.Printf("🗜️  WebSocket compression: %v (level %d)", cfg.Server.CompressionTypes, cfg.Server.CompressionLevel)
+	}
+	if cfg.Server.ControlIdleTimeout > 0 {
+		log.Printf("🎮 Control lock idle timeout: %v", cfg.Server.ControlIdleTimeout)
+	}
+	if cfg.Server.StaleTimeout > 0 {
+		log.Printf("💤 Stale client timeout: %v", cfg.Server.StaleTimeout)
+	}
+	if cfg.Server.ResumeWindow > 0 {
+		log.Printf("⏯️  Connection resume window: %v", cfg.Server.ResumeWindow)
+	}
 
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
+	// Country/region restrictions apply to every listener
+	var handler http.Handler = router
+	if cfg.Server.GeoIPDatabase != "" {
+		if len(cfg.Server.GeoIPAllowed) == 0 {
+			log.Fatal("GEOIP_ALLOWED is required when GEOIP_DATABASE is set")
+		}
+		geoDB, err := geoip.Open(cfg.Server.GeoIPDatabase)
+		if err != nil {
+			log.Fatalf("Failed to load GeoIP database: %v", err)
+		}
+		geo := middleware.NewGeoFilter(geoDB, cfg.Server.GeoIPAllowed, cfg.Server.GeoIPAllowUnknown)
+		geo.SetBlockHook(func(block middleware.GeoBlock) {
+			hub.PublishSecurityEvent(websocket.SecurityIPBlocked, map[string]interface{}{
+				"remote_addr": block.IP,
+				"reason":      "geoip",
+				"country":     block.Country,
+			})
+		})
+		handler = geo.Middleware(router)
+		log.Printf("🌍 GeoIP restrictions enabled (%s): allowed %v, unknown allowed: %v",
+			geoDB.DatabaseType, cfg.Server.GeoIPAllowed, cfg.Server.GeoIPAllowUnknown)
+	}
+
 	server := &http.Server{
-		Addr:    addr,
-		Handler: router,
+		Handler: handler,
+	}
+
+	listeners, err := listen(addrs, network, cfg.Server)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	if cfg.Server.ProxyProtocol {
+		log.Printf("🧭 PROXY protocol enabled (trusted: %v)", cfg.Server.ProxyProtocolTrusted)
 	}
 
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+	// One server serves every listener; Shutdown closes them all
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Server error on %s: %v", listener.Addr(), err)
+			}
+		}(listener)
+	}
+	servers := []*http.Server{server}
+
+	// Device listener with client-certificate authentication
+	if cfg.MTLS.Addr != "" {
+		mtlsServer, err := newMTLSServer(cfg.MTLS, handler)
+		if err != nil {
+			log.Fatalf("Failed to configure mTLS listener: %v", err)
 		}
-	}()
+		wsHandler.EnableCertAuth()
+
+		go func() {
+			if err := mtlsServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("mTLS server error: %v", err)
+			}
+		}()
+		servers = append(servers, mtlsServer)
+		log.Printf("🔏 mTLS device listener on %s", cfg.MTLS.Addr)
+	}
 
 	log.Println("✅ Server is running")
 	log.Println("📝 Endpoints:")
 	log.Println("   GET  /health          - Health check")
 	log.Println("   POST /api/login       - User login")
-	log.Println("   POST /api/register    - User registration")
-	log.Println("   WS   /ws?token=<jwt>  - WebSocket connection")
+	log.Println("   POST /api/login/magic - Email a login link (MAGIC_LINK_URL)")
+	log.Println("   POST /api/register    - User registration (ENABLE_REGISTRATION)")
+	log.Println("   GET  /api/register/challenge - Registration challenge (REGISTRATION_CHALLENGE)")
+	log.Println("   POST /api/logout      - End a cookie session (SESSION_COOKIE)")
+	log.Println("   POST /api/token       - Service account token (HTTP Basic)")
+	log.Println("   POST /api/pair        - Exchange a pairing code for device credentials")
+	log.Println("   POST /api/setup       - Create the initial admin (setup token)")
+	log.Println("   POST /api/password    - Change password")
+	log.Println("   POST /api/token/introspect - Token introspection (INTROSPECTION_CLIENTS)")
+	log.Println("   GET  /.well-known/jwks.json - JWT public keys")
+	log.Println("   GET  /api/admin/keys  - JWT signing keys (admin)")
+	log.Println("   POST /api/admin/keys/rotate - Rotate JWT signing key (admin)")
+	log.Println("   GET  /api/admin/config - Effective configuration (admin)")
+	log.Println("   PUT  /api/admin/users/{name} - Create/update user idempotently (admin, If-Match)")
+	log.Println("   POST /api/admin/users/{name}/{deactivate,reactivate} - Suspend or restore an account (admin)")
+	log.Println("   POST /api/admin/service-accounts - Create service account (admin)")
+	log.Println("   POST /api/admin/service-accounts/{name}/rotate - Rotate service account secret (admin)")
+	log.Println("   POST /api/admin/pairing - Issue a device pairing code (admin)")
+	log.Println("   PUT  /api/admin/groups/{name} - Set group members and robots (admin)")
+	log.Println("   POST /api/admin/broadcast - Send a system_notice to connected clients (admin)")
+	log.Println("   POST /api/admin/clients/{connection_id}/disconnect - Close a WebSocket connection (admin)")
+	log.Println("   DELETE /api/admin/bans[/{ip}] - Lift IP bans (admin)")
+	log.Println("   POST /api/admin/blocklist - Ban a user or network (admin; DELETE /api/admin/blocklist/{id} lifts it)")
+	log.Println("   GET  /api/admin/{users,service-accounts,groups,connections,estop,routes,commands,whitelist,logs,bans,blocklist,tls} - Admin status (admin)")
+	log.Println("   POST /api/admin/recordings[/stop] - Start or stop recording WebSocket messages (admin)")
+	log.Println("   GET  /api/admin/recordings[/{id}] - Recording sessions and their messages (admin)")
+	log.Println("   PATCH /api/admin/recordings/{id} - Legal hold and retention of a recording (admin)")
+	log.Println("   POST /api/admin/playback - Replay a recorded session to the replay room (admin)")
+	log.Println("   GET  /api/admin/telemetry - Persisted telemetry (admin, TELEMETRY_PERSIST)")
+	log.Println("   GET  /api/admin/tunnel - WireGuard peers (admin, WIREGUARD_INTERFACE)")
+	log.Println("   GET  /api/audit       - Audit events (admin)")
+	log.Println("   POST /api/incidents   - Download an incident bundle: timeline, audit, telemetry, recordings (admin)")
+	log.Println("   GET  /admin/          - Admin panel")
+	log.Println("   GET  /api/metrics     - Current hub stats and throughput")
+	log.Println("   GET  /api/metrics/history - Hub stats history")
+	log.Println("   GET  /api/rooms/{id}/status - Room status (default room: default)")
+	log.Println("   POST /api/ws-ticket   - One-time WebSocket ticket")
+	log.Println("   GET  /api/turn-credentials - Time-limited TURN credentials (TURN_SECRET)")
+	log.Println("   WS   /ws?token=<jwt>  - WebSocket connection (or ?ticket=<ticket>)")
 
-	<-stop
+	sig := <-stop
 	log.Println("🛑 Shutting down server...")
+
+	report := gracefulShutdown(servers, hub, sig, startedAt, cfg.Shutdown.Timeout)
+	logShutdownReport(report)
+	if cfg.Shutdown.WebhookURL != "" {
+		if err := sendShutdownWebhook(cfg.Shutdown.WebhookURL, report, 5*time.Second); err != nil {
+			log.Printf("❌ Failed to send shutdown report: %v", err)
+		}
+	}
+}
+
+// schemas lists every subsystem's migrations, applied in this order
+var schemas = []store.Schema{auth.Schema, audit.Schema, metrics.Schema, recording.Schema, telemetry.Schema}
+
+// configKeys lists the client and message types per-type settings may name
+func configKeys(hub *websocket.Hub) config.Keys {
+	keys := config.Keys{MessageTypes: hub.MessageTypes()}
+	for _, clientType := range websocket.ClientTypes() {
+		keys.ClientTypes = append(keys.ClientTypes, string(clientType))
+	}
+	return keys
+}
+
+// runMigrate implements the "migrate [up|status]" subcommand
+func runMigrate(cfg *config.Config, args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	db, err := store.Open(cfg.DB.Driver, cfg.DB.DataSource())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch action {
+	case "up":
+		applied, err := db.Migrate(schemas...)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Println("✅ Database schema is up to date")
+			return nil
+		}
+		log.Printf("✅ Applied %d migration(s)", len(applied))
+
+	case "status":
+		statuses, err := db.MigrationStatus(schemas...)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-10s %04d_%-30s %s\n", status.Component, status.Version, status.Name, state)
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate action %q (expected up or status)", action)
+	}
+
+	return nil
 }
 
 // authValidator adapts auth.Service to websocket.AuthValidator interface
@@ -121,28 +862,120 @@ func (av *authValidator) ValidateToken(token string) (int64, string, error) {
 	return claims.UserID, claims.Username, nil
 }
 
-// createDefaultUser creates a default admin user if no users exist
-func createDefaultUser(db *auth.DB) error {
-	users, err := db.ListUsers()
+func (av *authValidator) ValidateTokenScopes(token string) (int64, string, []string, error) {
+	claims, err := av.service.ValidateToken(token)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return claims.UserID, claims.Username, claims.Scopes, nil
+}
+
+func (av *authValidator) ValidateTokenBinding(token string) (int64, string, []string, websocket.TokenBinding, error) {
+	claims, err := av.service.ValidateToken(token)
+	if err != nil {
+		return 0, "", nil, websocket.TokenBinding{}, err
+	}
+	binding := websocket.TokenBinding{
+		ClientType: websocket.ClientType(claims.ClientType),
+		DeviceID:   claims.DeviceID,
+	}
+	return claims.UserID, claims.Username, claims.Scopes, binding, nil
+}
+
+func (av *authValidator) RedeemTicket(ticket string) (int64, string, []string, websocket.TokenBinding, error) {
+	claims, err := av.service.RedeemTicket(ticket)
+	if err != nil {
+		return 0, "", nil, websocket.TokenBinding{}, err
+	}
+	binding := websocket.TokenBinding{
+		ClientType: websocket.ClientType(claims.ClientType),
+		DeviceID:   claims.DeviceID,
+	}
+	return claims.UserID, claims.Username, claims.Scopes, binding, nil
+}
+
+func (av *authValidator) CheckSource(userID int64, remoteAddr string) error {
+	return av.service.CheckSource(userID, remoteAddr)
+}
+
+func (av *authValidator) CheckBan(userID int64, remoteAddr string) error {
+	return av.service.CheckBan(userID, remoteAddr)
+}
+
+// passwordChangeValidator adapts auth.Service for the password-change endpoint,
+// which must also accept restricted tokens
+type passwordChangeValidator struct {
+	service *auth.Service
+}
+
+func (pv *passwordChangeValidator) ValidateToken(token string) (int64, string, error) {
+	claims, err := pv.service.ValidatePasswordChangeToken(token)
+	if err != nil {
+		return 0, "", err
+	}
+	return claims.UserID, claims.Username, nil
+}
+
+// applyBootstrap applies the bootstrap file, if one is configured
+func applyBootstrap(authService *auth.Service, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	file, err := bootstrap.Load(path)
+	if err != nil {
+		return err
+	}
+	result, err := file.Apply(authService)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📋 Bootstrap %s applied: %d created, %d updated, %d unchanged",
+		path, len(result.Created), len(result.Updated), len(result.Unchanged))
+	return nil
+}
+
+// enableSetup arms first-run setup while no admin account exists. The
+// setup token comes from SETUP_TOKEN or is generated and logged once.
+func enableSetup(authService *auth.Service, token string) error {
+	required, err := authService.SetupRequired()
 	if err != nil {
 		return fmt.Errorf("failed to list users: %v", err)
 	}
+	if !required {
+		return nil
+	}
 
-	if len(users) == 0 {
-		// Create default admin user
-		username := "admin"
-		password := "admin123" // Default password (should be changed immediately)
+	generated := token == ""
+	if token, err = authService.EnableSetup(token); err != nil {
+		return fmt.Errorf("failed to generate setup token: %v", err)
+	}
 
-		_, err := db.CreateUser(username, password)
-		if err != nil {
-			return fmt.Errorf("failed to create default user: %v", err)
-		}
+	log.Println("🔑 No admin account exists, first-run setup is enabled")
+	if generated {
+		log.Printf("   Setup token: %s", token)
+	} else {
+		log.Println("   Setup token: provided via SETUP_TOKEN")
+	}
+	log.Println("   Create the initial admin via POST /api/setup")
+	return nil
+}
 
-		log.Println("⚠️  Default admin user created:")
-		log.Println("   Username: admin")
-		log.Println("   Password: admin123")
-		log.Println("   ⚠️  CHANGE THIS PASSWORD IMMEDIATELY!")
+// runFingerprint prints the device listener certificate fingerprints to
+// provision pinning clients with
+func runFingerprint(cfg *config.Config) error {
+	if cfg.MTLS.CertFile == "" || cfg.MTLS.KeyFile == "" {
+		return fmt.Errorf("MTLS_CERT_FILE and MTLS_KEY_FILE are required")
 	}
 
+	cert, err := api.LoadCertificateInfo(cfg.MTLS.CertFile, cfg.MTLS.KeyFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("subject:     %s\n", cert.Subject)
+	fmt.Printf("not_after:   %s\n", cert.NotAfter.Format(time.RFC3339))
+	fmt.Printf("sha256:      %s\n", cert.SHA256)
+	fmt.Printf("spki_sha256: %s\n", cert.SPKISHA256)
 	return nil
 }
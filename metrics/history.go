@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"encoding/json"
+	"log"
+	"oculo-pilot-server/store"
+	"time"
+)
+
+// StatsSource provides point-in-time statistics (implemented by websocket.Hub)
+type StatsSource interface {
+	GetStats() map[string]interface{}
+}
+
+// Sample is a persisted statistics snapshot
+type Sample struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Stats     map[string]interface{} `json:"stats"`
+}
+
+// History periodically persists stats samples and answers history queries
+type History struct {
+	db        *store.DB
+	source    StatsSource
+	interval  time.Duration
+	retention time.Duration
+	stop      chan struct{}
+}
+
+// NewHistory creates a stats history recorder
+func NewHistory(db *store.DB, source StatsSource, interval, retention time.Duration) *History {
+	return &History{
+		db:        db,
+		source:    source,
+		interval:  interval,
+		retention: retention,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Run samples stats every interval until Stop is called
+func (h *History) Run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := h.Record(now); err != nil {
+				log.Printf("❌ Failed to record stats sample: %v", err)
+			}
+
+			if now.Sub(lastPrune) >= time.Hour {
+				if removed, err := h.Prune(now); err != nil {
+					log.Printf("❌ Failed to prune stats history: %v", err)
+				} else if removed > 0 {
+					log.Printf("🧹 Pruned %d stats samples older than %v", removed, h.retention)
+				}
+				lastPrune = now
+			}
+
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the sampling loop
+func (h *History) Stop() {
+	close(h.stop)
+}
+
+// Record stores the current stats as a sample taken at now
+func (h *History) Record(now time.Time) error {
+	data, err := json.Marshal(h.source.GetStats())
+	if err != nil {
+		return err
+	}
+
+	_, err = h.db.Exec(
+		"INSERT INTO hub_stats_samples (sampled_at, stats) VALUES (?, ?)",
+		now.UTC(), string(data),
+	)
+	return err
+}
+
+// Since returns samples taken at or after since, oldest first
+func (h *History) Since(since time.Time, limit int) ([]Sample, error) {
+	rows, err := h.db.Query(
+		"SELECT sampled_at, stats FROM hub_stats_samples WHERE sampled_at >= ? ORDER BY sampled_at ASC LIMIT ?",
+		since.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []Sample{}
+	for rows.Next() {
+		var (
+			sample Sample
+			data   string
+		)
+		if err := rows.Scan(&sample.Timestamp, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &sample.Stats); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, rows.Err()
+}
+
+// Prune deletes samples older than the retention window
+func (h *History) Prune(now time.Time) (int64, error) {
+	result, err := h.db.Exec(
+		"DELETE FROM hub_stats_samples WHERE sampled_at < ?",
+		now.Add(-h.retention).UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Retention returns how long samples are kept
+func (h *History) Retention() time.Duration {
+	return h.retention
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"oculo-pilot-server/store"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// staticSource returns fixed stats
+type staticSource struct {
+	total int
+}
+
+func (s *staticSource) GetStats() map[string]interface{} {
+	return map[string]interface{}{"total": s.total, "web": 1}
+}
+
+// TestHistoryRecordAndQuery tests sample persistence, queries and pruning
+func TestHistoryRecordAndQuery(t *testing.T) {
+	db, err := store.Open(store.DriverSQLite, filepath.Join(t.TempDir(), "metrics.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Migrate(Schema); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	source := &staticSource{}
+	history := NewHistory(db, source, time.Minute, 48*time.Hour)
+
+	now := time.Now()
+	for i, age := range []time.Duration{72 * time.Hour, 36 * time.Hour, 2 * time.Hour, time.Minute} {
+		source.total = i
+		if err := history.Record(now.Add(-age)); err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+	}
+
+	samples, err := history.Since(now.Add(-24*time.Hour), 100)
+	if err != nil {
+		t.Fatalf("Since() failed: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples in the last 24h, got %d", len(samples))
+	}
+	if samples[0].Stats["total"] != float64(2) || samples[1].Stats["total"] != float64(3) {
+		t.Errorf("Unexpected sample order or contents: %+v", samples)
+	}
+
+	removed, err := history.Prune(now)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 sample pruned, got %d", removed)
+	}
+
+	samples, err = history.Since(now.Add(-100*time.Hour), 100)
+	if err != nil {
+		t.Fatalf("Since() failed: %v", err)
+	}
+	if len(samples) != 3 {
+		t.Errorf("Expected 3 samples after pruning, got %d", len(samples))
+	}
+}
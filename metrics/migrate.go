@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"embed"
+	"oculo-pilot-server/store"
+)
+
+// migrationFS holds the versioned schema migrations for every dialect
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+// Schema is the migrations for the hub stats samples behind
+// /api/metrics/history
+var Schema = store.Schema{
+	Component: "metrics",
+	FS:        migrationFS,
+	// Version in the schema_migrations table all subsystems shared
+	Legacy: map[int]int{3: 1},
+}
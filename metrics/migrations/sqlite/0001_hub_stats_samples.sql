@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS hub_stats_samples (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sampled_at DATETIME NOT NULL,
+	stats TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_hub_stats_samples_sampled_at ON hub_stats_samples(sampled_at);
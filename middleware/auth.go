@@ -14,6 +14,8 @@ const (
 	UserIDKey ContextKey = "user_id"
 	// UsernameKey is the context key for username
 	UsernameKey ContextKey = "username"
+	// ScopesKey is the context key for token scopes
+	ScopesKey ContextKey = "scopes"
 )
 
 // AuthService interface for auth validation
@@ -21,28 +23,46 @@ type AuthService interface {
 	ValidateToken(token string) (userID int64, username string, err error)
 }
 
-// Auth middleware validates JWT tokens
+// ScopedAuthService is implemented by auth services that also return token
+// scopes (nil for tokens issued before scopes, which are unrestricted)
+type ScopedAuthService interface {
+	ValidateTokenScopes(token string) (userID int64, username string, scopes []string, err error)
+}
+
+// validate validates a token, collecting scopes when the service supports them
+func validate(authService AuthService, token string) (int64, string, []string, error) {
+	if scoped, ok := authService.(ScopedAuthService); ok {
+		return scoped.ValidateTokenScopes(token)
+	}
+	userID, username, err := authService.ValidateToken(token)
+	return userID, username, nil, err
+}
+
+// Auth middleware validates JWT tokens from the Authorization header or,
+// for browsers, the session cookie
 func Auth(authService AuthService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get token from Authorization header
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			token := sessionToken(r)
+			if authHeader == "" && token == "" {
 				http.Error(w, "Missing authorization header", http.StatusUnauthorized)
 				return
 			}
 
 			// Check Bearer prefix
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-				return
+			if authHeader != "" {
+				parts := strings.Split(authHeader, " ")
+				if len(parts) != 2 || parts[0] != "Bearer" {
+					http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+					return
+				}
+				token = parts[1]
 			}
 
-			token := parts[1]
-
 			// Validate token
-			userID, username, err := authService.ValidateToken(token)
+			userID, username, scopes, err := validate(authService, token)
 			if err != nil {
 				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 				return
@@ -51,6 +71,7 @@ func Auth(authService AuthService) func(http.Handler) http.Handler {
 			// Add user info to context
 			ctx := context.WithValue(r.Context(), UserIDKey, userID)
 			ctx = context.WithValue(ctx, UsernameKey, username)
+			ctx = context.WithValue(ctx, ScopesKey, scopes)
 
 			// Call next handler
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -58,22 +79,60 @@ func Auth(authService AuthService) func(http.Handler) http.Handler {
 	}
 }
 
+// RoleChecker reports whether a user holds a role
+type RoleChecker interface {
+	HasRole(userID int64, role string) (bool, error)
+}
+
+// RequireRole middleware rejects authenticated users without the given role.
+// It must be wrapped by Auth so the user ID is in the request context.
+func RequireRole(checker RoleChecker, role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			allowed, err := checker.HasRole(userID, role)
+			if err != nil || !allowed {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope middleware rejects tokens without the given scope. It must be
+// wrapped by Auth; tokens issued before scopes existed are not restricted.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r, scope) {
+				http.Error(w, "Insufficient scope", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // OptionalAuth middleware validates JWT tokens but doesn't reject requests without tokens
 func OptionalAuth(authService AuthService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get token from Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader != "" {
-				parts := strings.Split(authHeader, " ")
-				if len(parts) == 2 && parts[0] == "Bearer" {
-					token := parts[1]
-					userID, username, err := authService.ValidateToken(token)
-					if err == nil {
-						ctx := context.WithValue(r.Context(), UserIDKey, userID)
-						ctx = context.WithValue(ctx, UsernameKey, username)
-						r = r.WithContext(ctx)
-					}
+			// Get token from Authorization header or session cookie
+			if token := RequestToken(r); token != "" {
+				userID, username, scopes, err := validate(authService, token)
+				if err == nil {
+					ctx := context.WithValue(r.Context(), UserIDKey, userID)
+					ctx = context.WithValue(ctx, UsernameKey, username)
+					ctx = context.WithValue(ctx, ScopesKey, scopes)
+					r = r.WithContext(ctx)
 				}
 			}
 
@@ -93,3 +152,17 @@ func GetUsername(r *http.Request) (string, bool) {
 	username, ok := r.Context().Value(UsernameKey).(string)
 	return username, ok
 }
+
+// HasScope reports whether the request's token grants a scope
+func HasScope(r *http.Request, scope string) bool {
+	scopes, _ := r.Context().Value(ScopesKey).([]string)
+	if scopes == nil {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ban is a temporary ban of a source IP, or of an IPv6 /64
+type Ban struct {
+	IP       string    `json:"ip"`
+	Failures int       `json:"failures"`
+	BannedAt time.Time `json:"banned_at"`
+	Until    time.Time `json:"until"`
+}
+
+// failureCount tracks failures from one IP within the current window
+type failureCount struct {
+	count int
+	since time.Time
+}
+
+// BanList counts authentication failures per source IP and temporarily
+// bans IPs that fail more than threshold times within window. IPv6
+// sources are counted per /64, since a single host can rotate through its
+// whole prefix.
+type BanList struct {
+	threshold int
+	window    time.Duration
+	duration  time.Duration
+
+	failures  map[string]*failureCount
+	bans      map[string]Ban
+	lastPrune time.Time
+	mu        sync.Mutex
+
+	// onBan is called, outside the lock, whenever an IP is banned
+	onBan func(Ban)
+}
+
+// NewBanList creates a ban list; threshold 0 disables banning
+func NewBanList(threshold int, window, duration time.Duration) *BanList {
+	return &BanList{
+		threshold: threshold,
+		window:    window,
+		duration:  duration,
+		failures:  make(map[string]*failureCount),
+		bans:      make(map[string]Ban),
+	}
+}
+
+// SetBanHook registers a function called whenever an IP is banned. Call
+// before the list is used.
+func (b *BanList) SetBanHook(hook func(Ban)) {
+	b.onBan = hook
+}
+
+// RecordFailure counts a failed authentication and reports whether it got
+// the IP banned
+func (b *BanList) RecordFailure(ip string) bool {
+	if b.threshold <= 0 || ip == "" {
+		return false
+	}
+
+	ip = banKey(ip)
+	ban, banned := b.recordFailure(ip, time.Now())
+	if banned {
+		log.Printf("⛔ Banned %s for %v after %d authentication failures", ip, b.duration, ban.Failures)
+		if b.onBan != nil {
+			b.onBan(ban)
+		}
+	}
+	return banned
+}
+
+// recordFailure counts a failure and bans the IP at the threshold
+func (b *BanList) recordFailure(ip string, now time.Time) (Ban, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pruneLocked(now)
+
+	entry, ok := b.failures[ip]
+	if !ok || now.Sub(entry.since) > b.window {
+		entry = &failureCount{since: now}
+		b.failures[ip] = entry
+	}
+	entry.count++
+	if entry.count < b.threshold {
+		return Ban{}, false
+	}
+
+	delete(b.failures, ip)
+	ban := Ban{IP: ip, Failures: entry.count, BannedAt: now, Until: now.Add(b.duration)}
+	b.bans[ip] = ban
+	return ban, true
+}
+
+// RecordSuccess resets the failure count of an IP
+func (b *BanList) RecordSuccess(ip string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, banKey(ip))
+}
+
+// Banned returns the active ban of an IP, if any
+func (b *BanList) Banned(ip string) (Ban, bool) {
+	ip = banKey(ip)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ban, ok := b.bans[ip]
+	if ok && time.Now().After(ban.Until) {
+		delete(b.bans, ip)
+		return Ban{}, false
+	}
+	return ban, ok
+}
+
+// Bans returns the active bans, soonest expiry first
+func (b *BanList) Bans() []Ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pruneLocked(time.Now())
+	bans := make([]Ban, 0, len(b.bans))
+	for _, ban := range b.bans {
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+	return bans
+}
+
+// Clear lifts the ban of an IP, or of the /64 an IPv6 address is in, and
+// reports whether there was one
+func (b *BanList) Clear(ip string) bool {
+	ip = banKey(ip)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.bans[ip]
+	delete(b.bans, ip)
+	delete(b.failures, ip)
+	return ok
+}
+
+// ClearAll lifts every ban and returns how many were lifted
+func (b *BanList) ClearAll() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := len(b.bans)
+	b.bans = make(map[string]Ban)
+	b.failures = make(map[string]*failureCount)
+	return count
+}
+
+// pruneLocked drops expired bans and failure windows at most once a
+// minute; mu must be held
+func (b *BanList) pruneLocked(now time.Time) {
+	if now.Sub(b.lastPrune) < time.Minute {
+		return
+	}
+	b.lastPrune = now
+
+	for ip, ban := range b.bans {
+		if now.After(ban.Until) {
+			delete(b.bans, ip)
+		}
+	}
+	for ip, entry := range b.failures {
+		if now.Sub(entry.since) > b.window {
+			delete(b.failures, ip)
+		}
+	}
+}
+
+// Middleware rejects requests from banned IPs with 429 Too Many Requests
+func (b *BanList) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ban, ok := b.Banned(ClientIP(r)); ok {
+			retryAfter := int(time.Until(ban.Until).Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "Too many authentication failures", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// banKey returns what an IP's failures and bans are counted under: the IP
+// itself for IPv4, its /64 prefix for IPv6
+func banKey(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil || addr.To4() != nil {
+		return ip
+	}
+	prefix := net.IPNet{IP: addr.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}
+	return prefix.String()
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestBanList tests banning after repeated failures, resets and clearing
+func TestBanList(t *testing.T) {
+	bans := NewBanList(3, time.Minute, time.Hour)
+	var hooked []Ban
+	bans.SetBanHook(func(ban Ban) { hooked = append(hooked, ban) })
+
+	bans.RecordFailure("10.0.0.5")
+	bans.RecordFailure("10.0.0.5")
+	bans.RecordSuccess("10.0.0.5")
+	bans.RecordFailure("10.0.0.5")
+	bans.RecordFailure("10.0.0.5")
+	if _, banned := bans.Banned("10.0.0.5"); banned {
+		t.Fatal("Expected a successful login to reset the failure count")
+	}
+	if !bans.RecordFailure("10.0.0.5") {
+		t.Fatal("Expected the third failure in a row to ban the IP")
+	}
+	if len(hooked) != 1 || hooked[0].IP != "10.0.0.5" {
+		t.Errorf("Expected the ban hook to run once for 10.0.0.5, got %+v", hooked)
+	}
+
+	handler := bans.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+	req.RemoteAddr = "10.0.0.5:41234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After for a banned IP, got %d", rec.Code)
+	}
+
+	req.RemoteAddr = "10.0.0.6:41234"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected other IPs to pass, got %d", rec.Code)
+	}
+
+	if list := bans.Bans(); len(list) != 1 || list[0].IP != "10.0.0.5" || list[0].Failures != 3 {
+		t.Errorf("Unexpected ban list: %+v", list)
+	}
+	if !bans.Clear("10.0.0.5") || bans.Clear("10.0.0.5") {
+		t.Error("Expected Clear to lift the ban exactly once")
+	}
+	if _, banned := bans.Banned("10.0.0.5"); banned {
+		t.Error("Expected the IP to be unbanned after Clear")
+	}
+}
+
+// TestBanListDisabled tests that a zero threshold never bans
+func TestBanListDisabled(t *testing.T) {
+	bans := NewBanList(0, time.Minute, time.Hour)
+	for i := 0; i < 100; i++ {
+		if bans.RecordFailure("10.0.0.5") {
+			t.Fatal("Expected no ban with threshold 0")
+		}
+	}
+}
+
+// TestBanForgedForwardedFor tests that a banned client cannot get around
+// the ban with an X-Forwarded-For header of its own
+func TestBanForgedForwardedFor(t *testing.T) {
+	bans := NewBanList(1, time.Minute, time.Hour)
+	bans.RecordFailure("203.0.113.5")
+
+	handler := bans.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+	req.RemoteAddr = "203.0.113.5:41234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected a forged X-Forwarded-For to stay banned, got %d", rec.Code)
+	}
+}
+
+// TestBanIPv6Prefix tests that IPv6 failures are counted per /64, so
+// rotating addresses within a prefix does not reset the count
+func TestBanIPv6Prefix(t *testing.T) {
+	bans := NewBanList(3, time.Minute, time.Hour)
+	bans.RecordFailure("2001:db8:1:2::1")
+	bans.RecordFailure("2001:db8:1:2::2")
+	if !bans.RecordFailure("2001:db8:1:2:aaaa::3") {
+		t.Fatal("Expected the third failure from the same /64 to ban it")
+	}
+	if _, banned := bans.Banned("2001:db8:1:2:ffff::9"); !banned {
+		t.Error("Expected every address in the /64 to be banned")
+	}
+	if _, banned := bans.Banned("2001:db8:1:3::1"); banned {
+		t.Error("Expected another /64 to pass")
+	}
+	if list := bans.Bans(); len(list) != 1 || list[0].IP != "2001:db8:1:2::/64" {
+		t.Errorf("Expected the ban listed as its /64, got %+v", list)
+	}
+	if !bans.Clear("2001:db8:1:2::1") {
+		t.Error("Expected an address in the /64 to lift its ban")
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientIP tests that X-Forwarded-For is only believed from trusted
+// proxies
+func TestClientIP(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}); err != nil {
+		t.Fatalf("SetTrustedProxies failed: %v", err)
+	}
+	defer SetTrustedProxies(nil)
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor string
+		expected      string
+	}{
+		{"no header", "203.0.113.5:4000", "", "203.0.113.5"},
+		{"forged by a direct client", "203.0.113.5:4000", "10.1.2.3", "203.0.113.5"},
+		{"from a trusted proxy", "10.0.0.2:4000", "198.51.100.7", "198.51.100.7"},
+		{"client-prepended entry ignored", "10.0.0.2:4000", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"chain of trusted proxies", "192.168.1.1:4000", "198.51.100.7, 10.0.0.9", "198.51.100.7"},
+		{"trusted proxy without header", "10.0.0.2:4000", "", "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.xForwardedFor)
+			}
+			if got := ClientIP(req); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	if err := SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Error("Expected an invalid entry to be rejected")
+	}
+}
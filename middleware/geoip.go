@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"oculo-pilot-server/geoip"
+	"oculo-pilot-server/logging"
+	"strings"
+)
+
+// GeoLocator finds where an address is (implemented by geoip.Reader)
+type GeoLocator interface {
+	Lookup(ip net.IP) (*geoip.Location, error)
+}
+
+// GeoBlock describes a request rejected by GeoFilter
+type GeoBlock struct {
+	IP      string
+	Country string // Empty when the database has no entry
+}
+
+// GeoFilter admits requests only from permitted countries or regions
+type GeoFilter struct {
+	locator      GeoLocator
+	allowed      map[string]bool
+	allowUnknown bool
+
+	// onBlock is called for every rejected request
+	onBlock func(GeoBlock)
+}
+
+// NewGeoFilter creates a filter permitting the listed ISO 3166-1 country
+// codes (e.g. "KR") and ISO 3166-2 regions (e.g. "US-CA"). Addresses the
+// database does not know, such as private ranges, pass when allowUnknown
+// is set.
+func NewGeoFilter(locator GeoLocator, allowed []string, allowUnknown bool) *GeoFilter {
+	f := &GeoFilter{locator: locator, allowed: make(map[string]bool), allowUnknown: allowUnknown}
+	for _, code := range allowed {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			f.allowed[code] = true
+		}
+	}
+	return f
+}
+
+// SetBlockHook registers a function called whenever a request is rejected.
+// Call before the filter is used.
+func (f *GeoFilter) SetBlockHook(hook func(GeoBlock)) {
+	f.onBlock = hook
+}
+
+// Allowed looks up ip and reports its country and whether it is permitted.
+// Lookup failures are treated as unknown.
+func (f *GeoFilter) Allowed(ip string) (string, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", f.allowUnknown
+	}
+	location, err := f.locator.Lookup(parsed)
+	if err != nil || location.Country == "" {
+		return "", f.allowUnknown
+	}
+
+	if f.allowed[location.Country] {
+		return location.Country, true
+	}
+	for _, region := range location.Regions {
+		if f.allowed[region] {
+			return location.Country, true
+		}
+	}
+	return location.Country, false
+}
+
+// Middleware rejects requests from outside the permitted countries with
+// 403 Forbidden. The country is looked up for ClientIP, so X-Forwarded-For
+// only counts from a trusted proxy
+func (f *GeoFilter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ClientIP(r)
+		country, ok := f.Allowed(ip)
+		if !ok {
+			logging.Sampled("geoip_blocked", "🌍 Request from %s (country=%q) blocked by GeoIP: %s %s", ip, country, r.Method, r.URL.Path)
+			if f.onBlock != nil {
+				f.onBlock(GeoBlock{IP: ip, Country: country})
+			}
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"oculo-pilot-server/geoip"
+	"testing"
+)
+
+// fakeLocator maps addresses to locations
+type fakeLocator map[string]*geoip.Location
+
+func (l fakeLocator) Lookup(ip net.IP) (*geoip.Location, error) {
+	if location, ok := l[ip.String()]; ok {
+		return location, nil
+	}
+	return &geoip.Location{}, nil
+}
+
+// TestGeoFilter tests country and region matching, unknown addresses and
+// the block hook
+func TestGeoFilter(t *testing.T) {
+	locator := fakeLocator{
+		"203.0.113.1":  {Country: "KR"},
+		"203.0.113.2":  {Country: "US", Regions: []string{"US-CA"}},
+		"203.0.113.3":  {Country: "US", Regions: []string{"US-NY"}},
+		"198.51.100.1": {Country: "CN"},
+	}
+	filter := NewGeoFilter(locator, []string{"kr", " US-CA"}, true)
+	var blocked []GeoBlock
+	filter.SetBlockHook(func(block GeoBlock) { blocked = append(blocked, block) })
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"203.0.113.1", true},
+		{"203.0.113.2", true},
+		{"203.0.113.3", false},
+		{"198.51.100.1", false},
+		{"10.0.0.1", true}, // Unknown
+	}
+	for _, tt := range tests {
+		if _, ok := filter.Allowed(tt.ip); ok != tt.allowed {
+			t.Errorf("Allowed(%s) = %v, expected %v", tt.ip, ok, tt.allowed)
+		}
+	}
+	if _, ok := NewGeoFilter(locator, []string{"KR"}, false).Allowed("10.0.0.1"); ok {
+		t.Error("Expected unknown addresses to be rejected without allowUnknown")
+	}
+
+	handler := filter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.RemoteAddr = "198.51.100.1:41234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", rec.Code)
+	}
+	if len(blocked) != 1 || blocked[0].IP != "198.51.100.1" || blocked[0].Country != "CN" {
+		t.Errorf("Expected the block hook to report CN, got %+v", blocked)
+	}
+
+	req.RemoteAddr = "203.0.113.1:41234"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+
+	// A blocked client cannot claim an allowed country with its own
+	// X-Forwarded-For
+	req.RemoteAddr = "198.51.100.1:41234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected a forged X-Forwarded-For to get 403, got %d", rec.Code)
+	}
+
+	// Behind a trusted proxy the forwarded address is the one checked
+	if err := SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies(nil)
+	req.RemoteAddr = "10.0.0.2:41234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected the proxied client to get 403, got %d", rec.Code)
+	}
+}
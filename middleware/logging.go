@@ -5,6 +5,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -47,10 +48,23 @@ func Logging(next http.Handler) http.Handler {
 		duration := time.Since(start)
 		log.Printf("%s %s %d %v %s",
 			r.Method,
-			r.RequestURI,
+			redactURI(r.URL),
 			wrapped.statusCode,
 			duration,
 			r.RemoteAddr,
 		)
 	})
 }
+
+// redactURI returns the request URI with credentials in the query string
+// (WebSocket and magic-link tokens) masked
+func redactURI(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("token") {
+		return u.RequestURI()
+	}
+	query.Set("token", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.RequestURI()
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SessionCookieName is the cookie holding a browser session's token
+const SessionCookieName = "oculo_session"
+
+// SessionCookie issues HttpOnly, SameSite=Strict session cookies carrying
+// the token, so browser frontends never handle the JWT themselves.
+// SameSite=Strict keeps the cookie off cross-site requests, including
+// cross-site WebSocket upgrades.
+type SessionCookie struct {
+	secure bool
+	maxAge time.Duration
+}
+
+// NewSessionCookie creates a session cookie issuer. maxAge should match
+// the token lifetime.
+func NewSessionCookie(secure bool, maxAge time.Duration) *SessionCookie {
+	return &SessionCookie{secure: secure, maxAge: maxAge}
+}
+
+// Set stores token in the session cookie
+func (c *SessionCookie) Set(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(c.maxAge / time.Second),
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// Clear removes the session cookie
+func (c *SessionCookie) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// RequestToken returns the bearer token of a request, falling back to the
+// session cookie ("" when there is neither)
+func RequestToken(r *http.Request) string {
+	if parts := strings.Split(r.Header.Get("Authorization"), " "); len(parts) == 2 && parts[0] == "Bearer" {
+		return parts[1]
+	}
+	return sessionToken(r)
+}
+
+// sessionToken returns the token in the session cookie ("" when absent)
+func sessionToken(r *http.Request) string {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
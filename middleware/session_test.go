@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// tokenService accepts a single token
+type tokenService struct {
+	token string
+}
+
+func (s tokenService) ValidateToken(token string) (int64, string, error) {
+	if token != s.token {
+		return 0, "", errors.New("invalid token")
+	}
+	return 1, "pilot", nil
+}
+
+// TestSessionCookie tests that Auth accepts the session cookie and that
+// the cookie is HttpOnly and SameSite=Strict
+func TestSessionCookie(t *testing.T) {
+	cookie := NewSessionCookie(true, time.Hour)
+	rec := httptest.NewRecorder()
+	cookie.Set(rec, "good")
+
+	issued := rec.Result().Cookies()
+	if len(issued) != 1 {
+		t.Fatalf("Expected one cookie, got %d", len(issued))
+	}
+	c := issued[0]
+	if c.Name != SessionCookieName || !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteStrictMode || c.MaxAge != 3600 {
+		t.Errorf("Unexpected cookie attributes: %+v", c)
+	}
+
+	handler := Auth(tokenService{token: "good"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _ := GetUsername(r)
+		w.Write([]byte(username))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/rooms/default/status", nil)
+	req.AddCookie(c)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "pilot" {
+		t.Errorf("Expected cookie to authenticate pilot, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// An Authorization header takes precedence over the cookie
+	req.Header.Set("Authorization", "Bearer other")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the header token to be used, got %d", rec.Code)
+	}
+	if RequestToken(req) != "other" {
+		t.Errorf("Expected RequestToken to prefer the header, got %q", RequestToken(req))
+	}
+
+	rec = httptest.NewRecorder()
+	cookie.Clear(rec)
+	if cleared := rec.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Errorf("Expected Clear to expire the cookie, got %+v", cleared)
+	}
+}
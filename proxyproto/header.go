@@ -0,0 +1,132 @@
+// Package proxyproto reads HAProxy PROXY protocol headers (v1 and v2), so
+// the real client address survives a TCP load balancer that cannot add
+// X-Forwarded-For.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// v1MaxLength is the longest valid v1 header line, including CRLF
+const v1MaxLength = 107
+
+// v2Signature starts every v2 header
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var (
+	// ErrNoHeader is returned when a connection does not start with a
+	// PROXY protocol header
+	ErrNoHeader = errors.New("missing PROXY protocol header")
+
+	// ErrInvalidHeader is returned for a malformed header
+	ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+)
+
+// ReadHeader consumes a v1 or v2 header from r and returns the client
+// address it carries. The address is nil for headers that do not describe
+// a proxied TCP connection (v1 UNKNOWN, v2 LOCAL such as load balancer
+// health checks, or unsupported families); callers keep the peer address.
+func ReadHeader(r *bufio.Reader) (net.Addr, error) {
+	prefix, err := r.Peek(5)
+	if err != nil {
+		return nil, err
+	}
+	if string(prefix) == "PROXY" {
+		return readV1(r)
+	}
+
+	prefix, err = r.Peek(len(v2Signature))
+	if err != nil || !bytes.Equal(prefix, v2Signature) {
+		return nil, ErrNoHeader
+	}
+	return readV2(r)
+}
+
+// readV1 parses a text header such as
+// "PROXY TCP4 203.0.113.7 10.0.0.1 51234 8080\r\n"
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= v1MaxLength {
+			return nil, ErrInvalidHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidHeader
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (ip.To4() != nil) != (fields[1] == "TCP4") || net.ParseIP(fields[3]) == nil {
+		return nil, ErrInvalidHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2 parses a binary header
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, ErrInvalidHeader
+	}
+	command := header[12] & 0x0f
+	family := header[13] >> 4
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	switch command {
+	case 0x0: // LOCAL: the proxy's own connection
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, ErrInvalidHeader
+	}
+
+	// Addresses are followed by optional TLVs, which are ignored
+	switch family {
+	case 0x1: // AF_INET
+		if len(payload) < 12 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default: // AF_UNSPEC, AF_UNIX
+		return nil, nil
+	}
+}
@@ -0,0 +1,97 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+// v2Header builds a v2 header with the given command, family and payload
+func v2Header(command, family byte, payload []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	return append(header, payload...)
+}
+
+// TestReadHeaderV1 tests parsing text headers
+func TestReadHeaderV1(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		addr   string // Empty when no address is expected
+		err    error
+	}{
+		{"tcp4", "PROXY TCP4 203.0.113.7 10.0.0.1 51234 8080\r\n", "203.0.113.7:51234", nil},
+		{"tcp6", "PROXY TCP6 2001:db8::7 2001:db8::1 51234 8080\r\n", "[2001:db8::7]:51234", nil},
+		{"unknown", "PROXY UNKNOWN\r\n", "", nil},
+		{"family mismatch", "PROXY TCP6 203.0.113.7 10.0.0.1 51234 8080\r\n", "", ErrInvalidHeader},
+		{"bad port", "PROXY TCP4 203.0.113.7 10.0.0.1 70000 8080\r\n", "", ErrInvalidHeader},
+		{"missing CR", "PROXY TCP4 203.0.113.7 10.0.0.1 51234 8080\n", "", ErrInvalidHeader},
+		{"too long", "PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n", "", ErrInvalidHeader},
+		{"http request", "GET / HTTP/1.1\r\n\r\n", "", ErrNoHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.header + "payload"))
+			addr, err := ReadHeader(r)
+			if err != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if tt.addr == "" && addr != nil || tt.addr != "" && (addr == nil || addr.String() != tt.addr) {
+				t.Errorf("Expected address %q, got %v", tt.addr, addr)
+			}
+			if rest, _ := r.ReadString(0); rest != "payload" {
+				t.Errorf("Expected the header to be consumed, %q left", rest)
+			}
+		})
+	}
+}
+
+// TestReadHeaderV2 tests parsing binary headers
+func TestReadHeaderV2(t *testing.T) {
+	ipv4 := append(append(net.ParseIP("203.0.113.7").To4(), 10, 0, 0, 1), 0xc8, 0x22, 0x1f, 0x90)
+	ipv6 := append(append(net.ParseIP("2001:db8::7"), net.ParseIP("2001:db8::1")...), 0xc8, 0x22, 0x1f, 0x90)
+	withTLV := append(append([]byte{}, ipv4...), 0x04, 0x00, 0x01, 0x00)
+
+	tests := []struct {
+		name   string
+		header []byte
+		addr   string
+		err    error
+	}{
+		{"ipv4", v2Header(0x1, 0x11, ipv4), "203.0.113.7:51234", nil},
+		{"ipv6", v2Header(0x1, 0x21, ipv6), "[2001:db8::7]:51234", nil},
+		{"tlv", v2Header(0x1, 0x11, withTLV), "203.0.113.7:51234", nil},
+		{"local", v2Header(0x0, 0x00, nil), "", nil},
+		{"unix", v2Header(0x1, 0x31, make([]byte, 216)), "", nil},
+		{"short", v2Header(0x1, 0x11, ipv4[:8]), "", ErrInvalidHeader},
+		{"bad command", v2Header(0x2, 0x11, ipv4), "", ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(append(tt.header, "payload"...)))
+			addr, err := ReadHeader(r)
+			if err != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if tt.addr == "" && addr != nil || tt.addr != "" && (addr == nil || addr.String() != tt.addr) {
+				t.Errorf("Expected address %q, got %v", tt.addr, addr)
+			}
+			if rest, _ := r.ReadString(0); rest != "payload" {
+				t.Errorf("Expected the header to be consumed, %q left", rest)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package proxyproto
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"oculo-pilot-server/logging"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headerTimeout bounds how long a trusted peer may take to send its header
+const headerTimeout = 5 * time.Second
+
+// Listener wraps a listener whose trusted peers prefix every connection
+// with a PROXY protocol header. Accepted connections report the client
+// address from the header as their RemoteAddr.
+type Listener struct {
+	net.Listener
+	trusted []*net.IPNet
+}
+
+// NewListener wraps inner. Connections from peers in trusted (CIDR format)
+// must send a header; others are passed through unchanged, so a client
+// reaching the port directly cannot claim another address. An empty
+// trusted list requires a header on every connection.
+func NewListener(inner net.Listener, trusted []string) (*Listener, error) {
+	l := &Listener{Listener: inner}
+	for _, cidr := range trusted {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy network %q: %v", cidr, err)
+		}
+		l.trusted = append(l.trusted, network)
+	}
+	return l, nil
+}
+
+// Accept returns the next connection. The header is read on first use, in
+// the connection's own goroutine, so a slow peer cannot stall Accept.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrusted(c.RemoteAddr()) {
+		return c, nil
+	}
+	return &conn{Conn: c, reader: bufio.NewReader(c)}, nil
+}
+
+// isTrusted reports whether a peer is expected to send a header
+func (l *Listener) isTrusted(addr net.Addr) bool {
+	if len(l.trusted) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.trusted {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// conn is a connection from a trusted proxy
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	once   sync.Once
+	client net.Addr // Nil when the header carried no address
+	err    error
+}
+
+// readHeader reads the header once; a connection with a missing or
+// malformed header is closed
+func (c *conn) readHeader() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(headerTimeout))
+		c.client, c.err = ReadHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+
+		if c.err != nil {
+			logging.Sampled("proxy_protocol_invalid", "🚫 PROXY protocol header from %s rejected: %v", c.Conn.RemoteAddr(), c.err)
+			c.Conn.Close()
+		}
+	})
+}
+
+// Read reads past the header
+func (c *conn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the header, or the proxy's
+// address when the header carried none
+func (c *conn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.client != nil {
+		return c.client
+	}
+	return c.Conn.RemoteAddr()
+}
@@ -0,0 +1,69 @@
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+// accept dials l, writes data and returns the accepted connection
+func accept(t *testing.T, l net.Listener, data string) net.Conn {
+	t.Helper()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.Write([]byte(data)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// TestListener tests that trusted peers report the client address from
+// the header and untrusted peers are passed through
+func TestListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer inner.Close()
+
+	if _, err := NewListener(inner, []string{"not-a-cidr"}); err == nil {
+		t.Error("Expected an invalid trusted network to be rejected")
+	}
+
+	l, err := NewListener(inner, []string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("NewListener failed: %v", err)
+	}
+
+	c := accept(t, l, "PROXY TCP4 203.0.113.7 127.0.0.1 51234 8080\r\nhello")
+	if got := c.RemoteAddr().String(); got != "203.0.113.7:51234" {
+		t.Errorf("Expected the header address, got %s", got)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "hello" {
+		t.Errorf("Expected payload after the header, got %q (%v)", buf, err)
+	}
+
+	// A trusted peer without a header is dropped
+	c = accept(t, l, "GET / HTTP/1.1\r\n\r\n")
+	if _, err := c.Read(buf); err != ErrNoHeader {
+		t.Errorf("Expected ErrNoHeader, got %v", err)
+	}
+
+	// Peers outside the trusted networks cannot claim an address
+	untrusted, _ := NewListener(inner, []string{"10.0.0.0/8"})
+	c = accept(t, untrusted, "PROXY TCP4 203.0.113.7 127.0.0.1 51234 8080\r\n")
+	if host, _, _ := net.SplitHostPort(c.RemoteAddr().String()); host != "127.0.0.1" {
+		t.Errorf("Expected the peer address for an untrusted peer, got %s", c.RemoteAddr())
+	}
+}
@@ -0,0 +1,19 @@
+package recording
+
+import (
+	"embed"
+	"oculo-pilot-server/store"
+)
+
+// migrationFS holds the versioned schema migrations for every dialect
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+// Schema is the migrations for recording sessions and their messages
+var Schema = store.Schema{
+	Component: "recording",
+	FS:        migrationFS,
+	// Version in the schema_migrations table all subsystems shared
+	Legacy: map[int]int{14: 1},
+}
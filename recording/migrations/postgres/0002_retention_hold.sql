@@ -0,0 +1,2 @@
+ALTER TABLE recording_sessions ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE recording_sessions ADD COLUMN IF NOT EXISTS retention_seconds BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS recording_sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	started_by TEXT NOT NULL DEFAULT '',
+	started_at DATETIME NOT NULL,
+	stopped_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_recording_sessions_started_at ON recording_sessions(started_at);
+
+CREATE TABLE IF NOT EXISTS recorded_messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id INTEGER NOT NULL,
+	recorded_at DATETIME NOT NULL,
+	direction TEXT NOT NULL,
+	connection_id TEXT NOT NULL DEFAULT '',
+	username TEXT NOT NULL DEFAULT '',
+	client_type TEXT NOT NULL DEFAULT '',
+	room TEXT NOT NULL DEFAULT '',
+	robot_id TEXT NOT NULL DEFAULT '',
+	message_type TEXT NOT NULL DEFAULT '',
+	payload TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_recorded_messages_session ON recorded_messages(session_id, id);
@@ -0,0 +1,2 @@
+ALTER TABLE recording_sessions ADD COLUMN legal_hold INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE recording_sessions ADD COLUMN retention_seconds INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,222 @@
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"oculo-pilot-server/websocket"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxSpeed bounds how much faster than recorded a session is played
+	MaxSpeed = 100
+
+	// playbackPage is how many recorded messages are loaded at a time
+	playbackPage = 500
+)
+
+var (
+	// ErrPlaying is returned when a playback is started while another runs
+	ErrPlaying = errors.New("a playback is running")
+
+	// ErrNotPlaying is returned when no playback is running to stop
+	ErrNotPlaying = errors.New("no playback is running")
+
+	// ErrInvalidSpeed is returned for a speed outside (0, MaxSpeed]
+	ErrInvalidSpeed = errors.New("speed must be greater than 0 and at most 100")
+)
+
+// replayTypes are the message types played back: telemetry and video
+// signaling. Commands are never replayed.
+var replayTypes = map[string]bool{
+	"location_update":    true,
+	"route_update":       true,
+	"offer":              true,
+	"answer":             true,
+	"ice-candidate":      true,
+	"video_client_ready": true,
+}
+
+// Broadcaster delivers played back messages to a room (implemented by
+// websocket.Hub)
+type Broadcaster interface {
+	BroadcastToRoom(room string, types []websocket.ClientType, message []byte) int
+}
+
+// Playback is the state of a running playback
+type Playback struct {
+	SessionID int64      `json:"session_id"`
+	Speed     float64    `json:"speed"`
+	StartedBy string     `json:"started_by"`
+	StartedAt time.Time  `json:"started_at"`
+	Sent      int64      `json:"sent"`               // Messages played so far
+	Position  *time.Time `json:"position,omitempty"` // Recorded time of the last one
+}
+
+// Player replays the telemetry and video signaling of recorded sessions to
+// the web clients in websocket.ReplayRoom, one session at a time
+type Player struct {
+	recorder *Recorder
+	target   Broadcaster
+
+	// Running playback (nil when idle) and how to stop it
+	mu       sync.Mutex
+	playback *Playback
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewPlayer creates a player for sessions of recorder
+func NewPlayer(recorder *Recorder, target Broadcaster) *Player {
+	return &Player{recorder: recorder, target: target}
+}
+
+// Start plays a stopped session back at speed times its original pace
+func (p *Player) Start(sessionID int64, speed float64, startedBy string) (*Playback, error) {
+	if !(speed > 0 && speed <= MaxSpeed) {
+		return nil, ErrInvalidSpeed
+	}
+	session, err := p.recorder.Session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.StoppedAt == nil {
+		return nil, ErrRecording
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.playback != nil {
+		return nil, ErrPlaying
+	}
+	p.playback = &Playback{SessionID: sessionID, Speed: speed, StartedBy: startedBy, StartedAt: time.Now()}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.run(session, p.stop, p.done)
+
+	log.Printf("▶️  Playback of recording session %d started by %s (%gx)", sessionID, startedBy, speed)
+	copied := *p.playback
+	return &copied, nil
+}
+
+// Stop ends the running playback and waits for it
+func (p *Player) Stop() error {
+	p.mu.Lock()
+	if p.playback == nil {
+		p.mu.Unlock()
+		return ErrNotPlaying
+	}
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	done := p.done
+	p.mu.Unlock()
+
+	<-done
+	return nil
+}
+
+// Status returns the running playback (nil when idle)
+func (p *Player) Status() *Playback {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.playback == nil {
+		return nil
+	}
+	copied := *p.playback
+	return &copied
+}
+
+// run sends the session's messages with their recorded spacing, divided
+// by the speed, until the session ends or stop is closed
+func (p *Player) run(session *Session, stop, done chan struct{}) {
+	defer close(done)
+
+	p.mu.Lock()
+	speed := p.playback.Speed
+	p.mu.Unlock()
+
+	p.notify(map[string]interface{}{
+		"type":        "replay_started",
+		"session_id":  session.ID,
+		"name":        session.Name,
+		"recorded_at": session.StartedAt,
+		"speed":       speed,
+	})
+
+	reason := p.play(session.ID, speed, stop)
+
+	p.mu.Lock()
+	sent := p.playback.Sent
+	p.playback = nil
+	p.mu.Unlock()
+
+	log.Printf("⏹️  Playback of recording session %d %s after %d messages", session.ID, reason, sent)
+	p.notify(map[string]interface{}{
+		"type":       "replay_finished",
+		"session_id": session.ID,
+		"reason":     reason,
+		"sent":       sent,
+	})
+}
+
+// play sends the messages and returns why it ended: completed, stopped or
+// failed
+func (p *Player) play(sessionID int64, speed float64, stop chan struct{}) string {
+	began := time.Now()
+	var first time.Time
+	var after int64
+	for {
+		messages, err := p.recorder.Messages(sessionID, after, playbackPage)
+		if err != nil {
+			log.Printf("❌ Failed to load recorded messages of session %d: %v", sessionID, err)
+			return "failed"
+		}
+		if len(messages) == 0 {
+			return "completed"
+		}
+
+		for _, message := range messages {
+			after = message.ID
+			// Each message is recorded once as received and once per
+			// recipient; the received copy is played
+			if message.Direction != websocket.DirectionIn || !replayTypes[message.Type] {
+				continue
+			}
+			if first.IsZero() {
+				first = message.Time
+			}
+
+			wait := time.Until(began.Add(time.Duration(float64(message.Time.Sub(first)) / speed)))
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return "stopped"
+			}
+
+			p.target.BroadcastToRoom(websocket.ReplayRoom, []websocket.ClientType{websocket.ClientTypeWeb}, message.Payload)
+			p.mu.Lock()
+			p.playback.Sent++
+			position := message.Time
+			p.playback.Position = &position
+			p.mu.Unlock()
+		}
+	}
+}
+
+// notify tells the web clients in the replay room about the playback
+func (p *Player) notify(message map[string]interface{}) {
+	message["timestamp"] = time.Now().Unix()
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	p.target.BroadcastToRoom(websocket.ReplayRoom, []websocket.ClientType{websocket.ClientTypeWeb}, data)
+}
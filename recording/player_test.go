@@ -0,0 +1,129 @@
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"oculo-pilot-server/websocket"
+	"sync"
+	"testing"
+	"time"
+)
+
+// capturingBroadcaster keeps the types of messages sent to each room
+type capturingBroadcaster struct {
+	mu    sync.Mutex
+	types []string
+	rooms []string
+}
+
+func (b *capturingBroadcaster) BroadcastToRoom(room string, types []websocket.ClientType, message []byte) int {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(message, &envelope)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.types = append(b.types, envelope.Type)
+	b.rooms = append(b.rooms, room)
+	return 1
+}
+
+// recordTestSession stores a stopped session with the given messages, each
+// a second after the previous
+func recordTestSession(t *testing.T, recorder *Recorder, messages []websocket.RecordedMessage) *Session {
+	t.Helper()
+	session, err := recorder.StartSession("", "", "admin")
+	if err != nil {
+		t.Fatalf("StartSession() failed: %v", err)
+	}
+	start := time.Now().Add(-time.Hour)
+	var batch []queued
+	for i, message := range messages {
+		message.Time = start.Add(time.Duration(i) * time.Second)
+		batch = append(batch, queued{sessionID: session.ID, message: message})
+	}
+	if err := recorder.write(batch); err != nil {
+		t.Fatalf("write() failed: %v", err)
+	}
+	if _, err := recorder.StopSession(); err != nil {
+		t.Fatalf("StopSession() failed: %v", err)
+	}
+	return session
+}
+
+// waitIdle waits for the running playback to finish
+func waitIdle(t *testing.T, player *Player) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for player.Status() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Playback did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestPlayerReplaysTelemetry tests that only received telemetry and
+// signaling is played, in order, to the replay room
+func TestPlayerReplaysTelemetry(t *testing.T) {
+	recorder := newTestRecorder(t)
+	session := recordTestSession(t, recorder, []websocket.RecordedMessage{
+		{Direction: websocket.DirectionIn, Data: []byte(`{"type":"location_update"}`)},
+		{Direction: websocket.DirectionOut, Data: []byte(`{"type":"location_update"}`)},
+		{Direction: websocket.DirectionIn, Data: []byte(`{"type":"control_command"}`)},
+		{Direction: websocket.DirectionIn, Data: []byte(`{"type":"offer"}`)},
+	})
+
+	broadcaster := &capturingBroadcaster{}
+	player := NewPlayer(recorder, broadcaster)
+	if _, err := player.Start(session.ID, 0, "admin"); !errors.Is(err, ErrInvalidSpeed) {
+		t.Errorf("Expected ErrInvalidSpeed for speed 0, got %v", err)
+	}
+	if _, err := player.Start(session.ID+1, 1, "admin"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if _, err := player.Start(session.ID, MaxSpeed, "admin"); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitIdle(t, player)
+
+	broadcaster.mu.Lock()
+	defer broadcaster.mu.Unlock()
+	want := []string{"replay_started", "location_update", "offer", "replay_finished"}
+	if len(broadcaster.types) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, broadcaster.types)
+	}
+	for i := range want {
+		if broadcaster.types[i] != want[i] || broadcaster.rooms[i] != websocket.ReplayRoom {
+			t.Errorf("Expected %v in the replay room, got %v in %v", want, broadcaster.types, broadcaster.rooms)
+			break
+		}
+	}
+}
+
+// TestPlayerStop tests that a playback waiting for its next message stops
+func TestPlayerStop(t *testing.T) {
+	recorder := newTestRecorder(t)
+	session := recordTestSession(t, recorder, []websocket.RecordedMessage{
+		{Direction: websocket.DirectionIn, Data: []byte(`{"type":"location_update"}`)},
+		{Direction: websocket.DirectionIn, Data: []byte(`{"type":"location_update"}`)},
+	})
+
+	player := NewPlayer(recorder, &capturingBroadcaster{})
+	if _, err := player.Start(session.ID, 1, "admin"); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if _, err := player.Start(session.ID, 1, "admin"); !errors.Is(err, ErrPlaying) {
+		t.Errorf("Expected ErrPlaying for a second playback, got %v", err)
+	}
+	if err := player.Stop(); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	if player.Status() != nil {
+		t.Error("Expected no playback after Stop")
+	}
+	if err := player.Stop(); !errors.Is(err, ErrNotPlaying) {
+		t.Errorf("Expected ErrNotPlaying, got %v", err)
+	}
+}
@@ -0,0 +1,565 @@
+package recording
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"oculo-pilot-server/store"
+	"oculo-pilot-server/websocket"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// queueSize bounds messages waiting to be written; further messages
+	// are dropped so recording never blocks routing
+	queueSize = 4096
+
+	// batchSize is how many queued messages one INSERT writes
+	batchSize = 64
+)
+
+var (
+	// ErrRecording is returned when a session is started while another is
+	// active, or an active session is deleted
+	ErrRecording = errors.New("a recording session is active")
+
+	// ErrNotRecording is returned when no session is active to stop
+	ErrNotRecording = errors.New("no recording session is active")
+
+	// ErrSessionNotFound is returned for an unknown session ID
+	ErrSessionNotFound = errors.New("recording session not found")
+
+	// ErrInvalidRetention is returned for a negative retention override
+	ErrInvalidRetention = errors.New("retention must not be negative")
+
+	// ErrLegalHold is returned when a session under legal hold is deleted
+	ErrLegalHold = errors.New("recording session is under legal hold")
+)
+
+// Session is one period of recording, started and stopped by an admin or
+// at startup
+type Session struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Room      string     `json:"room,omitempty"` // Empty records every room
+	StartedBy string     `json:"started_by"`
+	StartedAt time.Time  `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+	Messages  int64      `json:"messages"`
+
+	// A session under legal hold is never pruned or deleted
+	LegalHold bool `json:"legal_hold"`
+	// Retention overrides the recorder's for this session (empty uses it)
+	Retention string `json:"retention,omitempty"`
+}
+
+// Message is one recorded message. Payload has credentials redacted.
+type Message struct {
+	ID           int64           `json:"id"`
+	Time         time.Time       `json:"time"`
+	Direction    string          `json:"direction"`
+	ConnectionID string          `json:"connection_id"`
+	Username     string          `json:"username"`
+	ClientType   string          `json:"client_type"`
+	Room         string          `json:"room"`
+	RobotID      string          `json:"robot_id,omitempty"`
+	Type         string          `json:"type"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// queued is a routed message waiting to be written to its session
+type queued struct {
+	sessionID int64
+	message   websocket.RecordedMessage
+}
+
+// Recorder persists routed WebSocket messages to the active session
+// asynchronously and answers queries for past sessions
+type Recorder struct {
+	db        *store.DB
+	retention time.Duration
+
+	// Serializes starting and stopping sessions
+	mu     sync.Mutex
+	active atomic.Pointer[Session]
+
+	messages chan queued
+	dropped  atomic.Int64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewRecorder creates a recorder keeping messages for retention. Nothing is
+// recorded until StartSession is called.
+func NewRecorder(db *store.DB, retention time.Duration) *Recorder {
+	return &Recorder{
+		db:        db,
+		retention: retention,
+		messages:  make(chan queued, queueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// RecordMessage queues a routed message for the active session without
+// blocking
+func (r *Recorder) RecordMessage(message websocket.RecordedMessage) {
+	session := r.active.Load()
+	if session == nil || (session.Room != "" && session.Room != websocket.RoomID(message.Room)) {
+		return
+	}
+
+	select {
+	case r.messages <- queued{sessionID: session.ID, message: message}:
+	default:
+		if r.dropped.Add(1) == 1 {
+			log.Printf("⚠️  Recording queue full, dropping messages")
+		}
+	}
+}
+
+// Dropped returns how many messages were lost because the queue was full
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Retention returns how long recorded messages are kept
+func (r *Recorder) Retention() time.Duration {
+	return r.retention
+}
+
+// Active returns the session being recorded (nil when not recording)
+func (r *Recorder) Active() *Session {
+	if session := r.active.Load(); session != nil {
+		copied := *session
+		return &copied
+	}
+	return nil
+}
+
+// StartSession starts recording messages of room (every room when empty)
+func (r *Recorder) StartSession(name, room, startedBy string) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.active.Load() != nil {
+		return nil, ErrRecording
+	}
+
+	// Sessions left open by a run that did not stop cleanly end with their
+	// last message
+	if _, err := r.db.Exec(
+		`UPDATE recording_sessions SET stopped_at = COALESCE(
+			(SELECT MAX(recorded_at) FROM recorded_messages WHERE session_id = recording_sessions.id), started_at)
+		WHERE stopped_at IS NULL`,
+	); err != nil {
+		return nil, err
+	}
+
+	session := &Session{Name: name, Room: room, StartedBy: startedBy, StartedAt: time.Now().UTC()}
+	id, err := r.db.Insert(
+		"INSERT INTO recording_sessions (name, room, started_by, started_at) VALUES (?, ?, ?, ?)",
+		session.Name, session.Room, session.StartedBy, session.StartedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	session.ID = id
+	r.active.Store(session)
+
+	log.Printf("⏺️  Recording session %d started by %s (room=%q)", id, startedBy, room)
+	return r.Active(), nil
+}
+
+// StopSession stops the active session. Messages already queued for it
+// are still written.
+func (r *Recorder) StopSession() (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session := r.active.Load()
+	if session == nil {
+		return nil, ErrNotRecording
+	}
+	r.active.Store(nil)
+
+	now := time.Now().UTC()
+	if _, err := r.db.Exec("UPDATE recording_sessions SET stopped_at = ? WHERE id = ?", now, session.ID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("⏹️  Recording session %d stopped", session.ID)
+	stopped := *session
+	stopped.StoppedAt = &now
+	return &stopped, nil
+}
+
+// Run writes queued messages and prunes old ones hourly until Stop is
+// called
+func (r *Recorder) Run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case item := <-r.messages:
+			r.writeQueued(item)
+
+		case now := <-ticker.C:
+			if removed, err := r.Prune(now); err != nil {
+				log.Printf("❌ Failed to prune recordings: %v", err)
+			} else if removed > 0 {
+				log.Printf("🧹 Pruned %d recorded messages older than %v", removed, r.retention)
+			}
+
+		case <-r.stop:
+			// Write what is already queued so the last moments are kept
+			for {
+				select {
+				case item := <-r.messages:
+					r.writeQueued(item)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stop writes the remaining queued messages, ends the loop and stops the
+// active session
+func (r *Recorder) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+
+	if _, err := r.StopSession(); err != nil && !errors.Is(err, ErrNotRecording) {
+		log.Printf("❌ Failed to stop recording session: %v", err)
+	}
+}
+
+// writeQueued writes item together with whatever else is already queued,
+// up to batchSize
+func (r *Recorder) writeQueued(item queued) {
+	batch := []queued{item}
+drain:
+	for len(batch) < batchSize {
+		select {
+		case next := <-r.messages:
+			batch = append(batch, next)
+		default:
+			break drain
+		}
+	}
+
+	if err := r.write(batch); err != nil {
+		log.Printf("❌ Failed to write %d recorded messages: %v", len(batch), err)
+	}
+}
+
+// write stores a batch of messages in one statement
+func (r *Recorder) write(batch []queued) error {
+	const columns = 10
+	placeholders := make([]string, 0, len(batch))
+	args := make([]interface{}, 0, len(batch)*columns)
+	for _, item := range batch {
+		message := item.message
+		var envelope struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(message.Data, &envelope)
+
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args,
+			item.sessionID, message.Time.UTC(), message.Direction, message.ConnectionID,
+			message.Username, string(message.ClientType), websocket.RoomID(message.Room), message.RobotID,
+			envelope.Type, string(websocket.RedactMessage(message.Data)),
+		)
+	}
+
+	_, err := r.db.Exec(
+		"INSERT INTO recorded_messages (session_id, recorded_at, direction, connection_id, username, client_type, room, robot_id, message_type, payload) VALUES "+
+			strings.Join(placeholders, ", "),
+		args...,
+	)
+	return err
+}
+
+// sessionColumns lists the columns scanned by scanSession, with the
+// session's message count
+const sessionColumns = `id, name, room, started_by, started_at, stopped_at, legal_hold, retention_seconds,
+	(SELECT COUNT(*) FROM recorded_messages WHERE session_id = recording_sessions.id)`
+
+// scanSession scans a recording_sessions row selected with sessionColumns
+func scanSession(scan func(dest ...interface{}) error) (*Session, error) {
+	session := &Session{}
+	var retention int64
+	if err := scan(&session.ID, &session.Name, &session.Room, &session.StartedBy,
+		&session.StartedAt, &session.StoppedAt, &session.LegalHold, &retention, &session.Messages); err != nil {
+		return nil, err
+	}
+	if retention > 0 {
+		session.Retention = (time.Duration(retention) * time.Second).String()
+	}
+	return session, nil
+}
+
+// Sessions returns up to limit sessions, newest first
+func (r *Recorder) Sessions(limit int) ([]*Session, error) {
+	rows, err := r.db.Query(
+		"SELECT "+sessionColumns+" FROM recording_sessions ORDER BY started_at DESC, id DESC LIMIT ?",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// SessionsBetween returns the sessions that were recording at any time
+// between since and until, oldest first
+func (r *Recorder) SessionsBetween(since, until time.Time) ([]*Session, error) {
+	rows, err := r.db.Query(
+		"SELECT "+sessionColumns+` FROM recording_sessions
+		WHERE started_at < ? AND (stopped_at IS NULL OR stopped_at >= ?) ORDER BY started_at, id`,
+		until.UTC(), since.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Session returns one session
+func (r *Recorder) Session(id int64) (*Session, error) {
+	session, err := scanSession(r.db.QueryRow(
+		"SELECT "+sessionColumns+" FROM recording_sessions WHERE id = ?", id,
+	).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	return session, err
+}
+
+// MessageFilter selects recorded messages in FindMessages
+type MessageFilter struct {
+	Since    time.Time
+	Until    time.Time // Zero for no upper bound
+	Room     string    // As stored, see websocket.RoomID
+	RobotID  string
+	Username string
+	Limit    int
+}
+
+// Messages returns up to limit messages of a session recorded after the
+// message with ID after, oldest first
+func (r *Recorder) Messages(sessionID, after int64, limit int) ([]Message, error) {
+	rows, err := r.db.Query(
+		"SELECT "+messageColumns+" FROM recorded_messages WHERE session_id = ? AND id > ? ORDER BY id LIMIT ?",
+		sessionID, after, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// FindMessages returns up to filter.Limit messages of a session matching
+// filter, oldest first
+func (r *Recorder) FindMessages(sessionID int64, filter MessageFilter) ([]Message, error) {
+	query := "SELECT " + messageColumns + " FROM recorded_messages WHERE session_id = ? AND recorded_at >= ?"
+	args := []interface{}{sessionID, filter.Since.UTC()}
+	if !filter.Until.IsZero() {
+		query += " AND recorded_at < ?"
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.Room != "" {
+		query += " AND room = ?"
+		args = append(args, filter.Room)
+	}
+	if filter.RobotID != "" {
+		query += " AND robot_id = ?"
+		args = append(args, filter.RobotID)
+	}
+	if filter.Username != "" {
+		query += " AND username = ?"
+		args = append(args, filter.Username)
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// messageColumns lists the columns scanned by scanMessages
+const messageColumns = "id, recorded_at, direction, connection_id, username, client_type, room, robot_id, message_type, payload"
+
+// scanMessages reads and closes rows selected with messageColumns
+func scanMessages(rows *sql.Rows) ([]Message, error) {
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var (
+			message Message
+			payload string
+		)
+		if err := rows.Scan(&message.ID, &message.Time, &message.Direction, &message.ConnectionID, &message.Username,
+			&message.ClientType, &message.Room, &message.RobotID, &message.Type, &payload); err != nil {
+			return nil, err
+		}
+		message.Payload = json.RawMessage(payload)
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// DeleteSession deletes a stopped session and its messages, unless it is
+// under legal hold
+func (r *Recorder) DeleteSession(id int64) error {
+	if session := r.active.Load(); session != nil && session.ID == id {
+		return ErrRecording
+	}
+	session, err := r.Session(id)
+	if err != nil {
+		return err
+	}
+	if session.LegalHold {
+		return ErrLegalHold
+	}
+	if _, err := r.db.Exec("DELETE FROM recorded_messages WHERE session_id = ?", id); err != nil {
+		return err
+	}
+	result, err := r.db.Exec("DELETE FROM recording_sessions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// SetLegalHold places a session under legal hold, exempting it from
+// pruning and deletion, or releases it
+func (r *Recorder) SetLegalHold(id int64, hold bool) error {
+	result, err := r.db.Exec("UPDATE recording_sessions SET legal_hold = ? WHERE id = ?", hold, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// SetRetention keeps a session's messages for retention instead of the
+// recorder's window; 0 restores the default
+func (r *Recorder) SetRetention(id int64, retention time.Duration) error {
+	if retention < 0 {
+		return ErrInvalidRetention
+	}
+	result, err := r.db.Exec("UPDATE recording_sessions SET retention_seconds = ? WHERE id = ?",
+		int64(retention/time.Second), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Prune deletes messages older than their session's retention window and
+// the stopped sessions that ended before it. Sessions under legal hold are
+// kept whole.
+func (r *Recorder) Prune(now time.Time) (int64, error) {
+	cutoff := now.Add(-r.retention).UTC()
+	result, err := r.db.Exec(
+		`DELETE FROM recorded_messages WHERE recorded_at < ? AND session_id NOT IN
+			(SELECT id FROM recording_sessions WHERE legal_hold = ? OR retention_seconds > 0)`,
+		cutoff, true,
+	)
+	if err != nil {
+		return 0, err
+	}
+	removed, _ := result.RowsAffected()
+	if _, err := r.db.Exec(
+		"DELETE FROM recording_sessions WHERE stopped_at < ? AND legal_hold = ? AND retention_seconds = 0",
+		cutoff, false,
+	); err != nil {
+		return removed, err
+	}
+
+	// Sessions with their own window, usually few
+	overrides, err := r.retentionOverrides()
+	if err != nil {
+		return removed, err
+	}
+	for id, retention := range overrides {
+		cutoff := now.Add(-retention).UTC()
+		result, err := r.db.Exec("DELETE FROM recorded_messages WHERE session_id = ? AND recorded_at < ?", id, cutoff)
+		if err != nil {
+			return removed, err
+		}
+		n, _ := result.RowsAffected()
+		removed += n
+		if _, err := r.db.Exec("DELETE FROM recording_sessions WHERE id = ? AND stopped_at < ?", id, cutoff); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// retentionOverrides returns the window of each session that has its own
+// and is not under legal hold
+func (r *Recorder) retentionOverrides() (map[int64]time.Duration, error) {
+	rows, err := r.db.Query(
+		"SELECT id, retention_seconds FROM recording_sessions WHERE retention_seconds > 0 AND legal_hold = ?", false,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[int64]time.Duration)
+	for rows.Next() {
+		var id, seconds int64
+		if err := rows.Scan(&id, &seconds); err != nil {
+			return nil, err
+		}
+		overrides[id] = time.Duration(seconds) * time.Second
+	}
+	return overrides, rows.Err()
+}
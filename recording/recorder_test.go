@@ -0,0 +1,232 @@
+package recording
+
+import (
+	"errors"
+	"oculo-pilot-server/store"
+	"oculo-pilot-server/websocket"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRecorder opens a fresh database for a recorder
+func newTestRecorder(t *testing.T) *Recorder {
+	t.Helper()
+	db, err := store.Open(store.DriverSQLite, filepath.Join(t.TempDir(), "recording.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Migrate(Schema); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	return NewRecorder(db, 24*time.Hour)
+}
+
+// TestRecorderSession tests that only messages routed while a session is
+// active are recorded, in order and with credentials redacted
+func TestRecorderSession(t *testing.T) {
+	recorder := newTestRecorder(t)
+	go recorder.Run()
+
+	message := func(direction, room, data string) websocket.RecordedMessage {
+		return websocket.RecordedMessage{
+			Time:         time.Now(),
+			Direction:    direction,
+			ConnectionID: "conn-1",
+			Username:     "alice",
+			ClientType:   websocket.ClientTypeWeb,
+			Room:         room,
+			Data:         []byte(data),
+		}
+	}
+
+	recorder.RecordMessage(message(websocket.DirectionIn, "", `{"type":"ping"}`))
+	session, err := recorder.StartSession("incident", "", "admin")
+	if err != nil {
+		t.Fatalf("StartSession() failed: %v", err)
+	}
+	if _, err := recorder.StartSession("again", "", "admin"); !errors.Is(err, ErrRecording) {
+		t.Errorf("Expected ErrRecording for a second session, got %v", err)
+	}
+
+	recorder.RecordMessage(message(websocket.DirectionIn, "", `{"type":"handshake_response","auth_token":"secret"}`))
+	recorder.RecordMessage(message(websocket.DirectionOut, "lab", `{"type":"control_command","data":{"speed":1}}`))
+	if _, err := recorder.StopSession(); err != nil {
+		t.Fatalf("StopSession() failed: %v", err)
+	}
+	recorder.RecordMessage(message(websocket.DirectionIn, "", `{"type":"pong"}`))
+	recorder.Stop()
+
+	messages, err := recorder.Messages(session.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("Messages() failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 recorded messages, got %d", len(messages))
+	}
+	if messages[0].Type != "handshake_response" || messages[0].Room != "default" || messages[0].Direction != websocket.DirectionIn {
+		t.Errorf("Unexpected first message: %+v", messages[0])
+	}
+	if strings.Contains(string(messages[0].Payload), "secret") {
+		t.Errorf("Expected the auth token to be redacted, got %s", messages[0].Payload)
+	}
+	if messages[1].Type != "control_command" || messages[1].Direction != websocket.DirectionOut {
+		t.Errorf("Unexpected second message: %+v", messages[1])
+	}
+
+	later, err := recorder.Messages(session.ID, messages[0].ID, 10)
+	if err != nil || len(later) != 1 {
+		t.Errorf("Expected 1 message after the first, got %d (%v)", len(later), err)
+	}
+
+	stored, err := recorder.Session(session.ID)
+	if err != nil {
+		t.Fatalf("Session() failed: %v", err)
+	}
+	if stored.StoppedAt == nil || stored.Messages != 2 || stored.Name != "incident" {
+		t.Errorf("Unexpected stored session: %+v", stored)
+	}
+}
+
+// TestRecorderRoomFilter tests that a session for one room skips the others
+func TestRecorderRoomFilter(t *testing.T) {
+	recorder := newTestRecorder(t)
+	go recorder.Run()
+
+	session, err := recorder.StartSession("", "lab", "admin")
+	if err != nil {
+		t.Fatalf("StartSession() failed: %v", err)
+	}
+	recorder.RecordMessage(websocket.RecordedMessage{Time: time.Now(), Direction: websocket.DirectionIn, Room: "lab", Data: []byte(`{"type":"ping"}`)})
+	recorder.RecordMessage(websocket.RecordedMessage{Time: time.Now(), Direction: websocket.DirectionIn, Room: "", Data: []byte(`{"type":"ping"}`)})
+	recorder.Stop()
+
+	if recorder.Active() != nil {
+		t.Error("Expected Stop to end the active session")
+	}
+	messages, err := recorder.Messages(session.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("Messages() failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Room != "lab" {
+		t.Errorf("Expected only the lab message, got %+v", messages)
+	}
+}
+
+// TestRecorderDeleteAndPrune tests session deletion and retention
+func TestRecorderDeleteAndPrune(t *testing.T) {
+	recorder := newTestRecorder(t)
+
+	session, err := recorder.StartSession("", "", "admin")
+	if err != nil {
+		t.Fatalf("StartSession() failed: %v", err)
+	}
+	if err := recorder.DeleteSession(session.ID); !errors.Is(err, ErrRecording) {
+		t.Errorf("Expected ErrRecording deleting the active session, got %v", err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := recorder.write([]queued{{sessionID: session.ID, message: websocket.RecordedMessage{
+		Time: old, Direction: websocket.DirectionIn, Data: []byte(`{"type":"ping"}`),
+	}}}); err != nil {
+		t.Fatalf("write() failed: %v", err)
+	}
+	if _, err := recorder.StopSession(); err != nil {
+		t.Fatalf("StopSession() failed: %v", err)
+	}
+
+	removed, err := recorder.Prune(time.Now())
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 pruned message, got %d", removed)
+	}
+
+	if err := recorder.DeleteSession(session.ID); err != nil {
+		t.Fatalf("DeleteSession() failed: %v", err)
+	}
+	if _, err := recorder.Session(session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound after delete, got %v", err)
+	}
+	sessions, err := recorder.Sessions(10)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("Expected no sessions, got %d (%v)", len(sessions), err)
+	}
+}
+
+// TestRecorderLegalHoldAndRetention tests that held sessions survive
+// pruning and deletion and that a retention override replaces the default
+func TestRecorderLegalHoldAndRetention(t *testing.T) {
+	recorder := newTestRecorder(t)
+
+	// Three stopped sessions with a message from two days ago each
+	var ids []int64
+	old := time.Now().Add(-48 * time.Hour)
+	for i := 0; i < 3; i++ {
+		session, err := recorder.StartSession("", "", "admin")
+		if err != nil {
+			t.Fatalf("StartSession() failed: %v", err)
+		}
+		if err := recorder.write([]queued{{sessionID: session.ID, message: websocket.RecordedMessage{
+			Time: old, Direction: websocket.DirectionIn, Data: []byte(`{"type":"ping"}`),
+		}}}); err != nil {
+			t.Fatalf("write() failed: %v", err)
+		}
+		if _, err := recorder.StopSession(); err != nil {
+			t.Fatalf("StopSession() failed: %v", err)
+		}
+		ids = append(ids, session.ID)
+	}
+	held, extended, expired := ids[0], ids[1], ids[2]
+
+	if err := recorder.SetLegalHold(held, true); err != nil {
+		t.Fatalf("SetLegalHold() failed: %v", err)
+	}
+	if err := recorder.SetRetention(extended, 96*time.Hour); err != nil {
+		t.Fatalf("SetRetention() failed: %v", err)
+	}
+	if err := recorder.SetLegalHold(999, true); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if err := recorder.SetRetention(extended, -time.Hour); !errors.Is(err, ErrInvalidRetention) {
+		t.Errorf("Expected ErrInvalidRetention, got %v", err)
+	}
+	if err := recorder.DeleteSession(held); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("Expected ErrLegalHold deleting a held session, got %v", err)
+	}
+
+	// A day later: only the session on the default retention is pruned
+	removed, err := recorder.Prune(time.Now().Add(24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 pruned message, got %d", removed)
+	}
+	if _, err := recorder.Session(expired); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected the expired session pruned, got %v", err)
+	}
+	session, err := recorder.Session(extended)
+	if err != nil || session.Messages != 1 || session.Retention != "96h0m0s" {
+		t.Errorf("Expected the extended session kept with retention 96h, got %+v (%v)", session, err)
+	}
+
+	// Past the override: the held session is still whole
+	if removed, err = recorder.Prune(time.Now().Add(10 * 24 * time.Hour)); err != nil || removed != 1 {
+		t.Errorf("Expected the extended session's message pruned, got %d (%v)", removed, err)
+	}
+	session, err = recorder.Session(held)
+	if err != nil || !session.LegalHold || session.Messages != 1 {
+		t.Errorf("Expected the held session kept, got %+v (%v)", session, err)
+	}
+
+	// Released, it follows the default retention again
+	if err := recorder.SetLegalHold(held, false); err != nil {
+		t.Fatalf("SetLegalHold() failed: %v", err)
+	}
+	if removed, err = recorder.Prune(time.Now()); err != nil || removed != 1 {
+		t.Errorf("Expected the released session's message pruned, got %d (%v)", removed, err)
+	}
+}
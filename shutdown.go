@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"oculo-pilot-server/websocket"
+	"os"
+	"time"
+)
+
+// ShutdownReport summarizes a graceful shutdown so rollout impact can be measured
+type ShutdownReport struct {
+	Version  string    `json:"version"`
+	Hostname string    `json:"hostname,omitempty"`
+	Signal   string    `json:"signal"`
+	Started  time.Time `json:"started_at"`
+	Uptime   string    `json:"uptime"`
+
+	websocket.DrainReport
+	TotalClosed int `json:"connections_closed_total"`
+
+	HTTPDrainError string `json:"http_drain_error,omitempty"`
+	DrainDuration  string `json:"drain_duration"`
+	DrainMillis    int64  `json:"drain_duration_ms"`
+}
+
+// gracefulShutdown stops accepting requests, drains WebSocket clients and
+// returns a report of what was closed or dropped
+func gracefulShutdown(servers []*http.Server, hub *websocket.Hub, signal os.Signal, startedAt time.Time, timeout time.Duration) ShutdownReport {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	report := ShutdownReport{
+		Version: version,
+		Signal:  signal.String(),
+		Started: startedAt,
+		Uptime:  start.Sub(startedAt).Round(time.Second).String(),
+	}
+	report.Hostname, _ = os.Hostname()
+
+	// Stop accepting new connections; upgraded WebSockets are not tracked here
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil && report.HTTPDrainError == "" {
+			report.HTTPDrainError = err.Error()
+		}
+	}
+
+	report.DrainReport = hub.Shutdown(ctx)
+	report.TotalClosed = report.DrainReport.TotalClosed()
+
+	drain := time.Since(start)
+	report.DrainDuration = drain.Round(time.Millisecond).String()
+	report.DrainMillis = drain.Milliseconds()
+
+	return report
+}
+
+// logShutdownReport writes the report to the log
+func logShutdownReport(report ShutdownReport) {
+	log.Printf("📋 Shutdown report: closed %d connections %v, dropped %d queued messages, persisted %d sessions, drained in %s",
+		report.TotalClosed, report.ClosedByType, report.DroppedMessages, report.SessionsPersisted, report.DrainDuration)
+	if report.HTTPDrainError != "" {
+		log.Printf("⚠️  HTTP drain incomplete: %s", report.HTTPDrainError)
+	}
+
+	if data, err := json.Marshal(report); err == nil {
+		log.Printf("📋 %s", data)
+	}
+}
+
+// sendShutdownWebhook posts the report to the configured webhook
+func sendShutdownWebhook(url string, report ShutdownReport, timeout time.Duration) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
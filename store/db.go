@@ -0,0 +1,238 @@
+// Package store opens the server's SQL database and applies the schema
+// migrations each subsystem (auth, audit, metrics, ...) ships for it.
+// Queries are written with ? placeholders and rewritten per driver.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+// dialect captures the SQL differences between supported drivers
+type dialect struct {
+	// driver is the database/sql driver name
+	driver string
+
+	// migrationDir is the directory under migrations/ holding this dialect's files
+	migrationDir string
+
+	// timestampType is the column type used for timestamps
+	timestampType string
+
+	// numberedPlaceholders rewrites ? placeholders to $1, $2, ...
+	numberedPlaceholders bool
+
+	// returningID fetches inserted IDs with RETURNING instead of LastInsertId
+	returningID bool
+}
+
+var sqliteDialect = &dialect{
+	driver:        DriverSQLite,
+	migrationDir:  "sqlite",
+	timestampType: "DATETIME",
+}
+
+var postgresDialect = &dialect{
+	driver:               DriverPostgres,
+	migrationDir:         "postgres",
+	timestampType:        "TIMESTAMPTZ",
+	numberedPlaceholders: true,
+	returningID:          true,
+}
+
+// dialectFor returns the dialect for a driver name
+func dialectFor(driver string) (*dialect, error) {
+	switch driver {
+	case DriverSQLite, "sqlite":
+		return sqliteDialect, nil
+	case DriverPostgres, "postgresql", "pgx":
+		return postgresDialect, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+// rebind rewrites ? placeholders into the dialect's placeholder syntax
+func (d *dialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// DB is a connection pool for one of the supported drivers
+type DB struct {
+	conn    *sql.DB
+	dialect *dialect
+}
+
+// Open connects to the database without touching the schema; see Migrate
+func Open(driver, dsn string) (*DB, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &DB{conn: conn, dialect: d}, nil
+}
+
+// Driver returns the database/sql driver name
+func (db *DB) Driver() string {
+	return db.dialect.driver
+}
+
+// Close closes the database connection
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+// Exec runs a statement written with ? placeholders
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.conn.Exec(db.dialect.rebind(query), args...)
+}
+
+// QueryRow runs a single-row query written with ? placeholders
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.conn.QueryRow(db.dialect.rebind(query), args...)
+}
+
+// Query runs a query written with ? placeholders
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.conn.Query(db.dialect.rebind(query), args...)
+}
+
+// Insert runs an INSERT statement and returns the new row ID
+func (db *DB) Insert(query string, args ...interface{}) (int64, error) {
+	return insert(db.dialect, db.conn, query, args...)
+}
+
+// Begin starts a transaction
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, dialect: db.dialect}, nil
+}
+
+// Tx is a transaction whose queries use ? placeholders like DB's
+type Tx struct {
+	tx      *sql.Tx
+	dialect *dialect
+}
+
+// Exec runs a statement in the transaction
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.tx.Exec(tx.dialect.rebind(query), args...)
+}
+
+// QueryRow runs a single-row query in the transaction
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.tx.QueryRow(tx.dialect.rebind(query), args...)
+}
+
+// Insert runs an INSERT statement in the transaction and returns the new
+// row ID
+func (tx *Tx) Insert(query string, args ...interface{}) (int64, error) {
+	return insert(tx.dialect, tx.tx, query, args...)
+}
+
+// Commit commits the transaction
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback aborts the transaction; it does nothing after Commit
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insert runs an INSERT and returns the new row ID, with RETURNING where
+// the driver has no LastInsertId
+func insert(d *dialect, e execer, query string, args ...interface{}) (int64, error) {
+	if d.returningID {
+		var id int64
+		err := e.QueryRow(d.rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := e.Exec(d.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// HasColumn reports whether a table has the given column
+func (db *DB) HasColumn(table, column string) (bool, error) {
+	var count int
+	if db.dialect.driver == DriverPostgres {
+		err := db.QueryRow(
+			"SELECT COUNT(*) FROM information_schema.columns WHERE table_name = ? AND column_name = ?",
+			table, column,
+		).Scan(&count)
+		return count > 0, err
+	}
+
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+		table, column,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// hasTable reports whether a table exists
+func (db *DB) hasTable(table string) (bool, error) {
+	var count int
+	if db.dialect.driver == DriverPostgres {
+		err := db.QueryRow(
+			"SELECT COUNT(*) FROM information_schema.tables WHERE table_name = ?",
+			table,
+		).Scan(&count)
+		return count > 0, err
+	}
+
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?",
+		table,
+	).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB opens a SQLite database in a temporary directory
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// TestRebind tests placeholder rewriting per dialect
+func TestRebind(t *testing.T) {
+	query := "UPDATE users SET a = ?, b = ? WHERE id = ?"
+
+	if got := sqliteDialect.rebind(query); got != query {
+		t.Errorf("SQLite should keep ? placeholders, got %s", got)
+	}
+
+	expected := "UPDATE users SET a = $1, b = $2 WHERE id = $3"
+	if got := postgresDialect.rebind(query); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+// TestOpenUnsupportedDriver tests driver validation
+func TestOpenUnsupportedDriver(t *testing.T) {
+	if _, err := Open("oracle", ""); err == nil {
+		t.Error("Expected error for unsupported driver")
+	}
+}
+
+// TestTransactionInsert tests that a rolled back insert leaves no row
+func TestTransactionInsert(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() failed: %v", err)
+	}
+	if id, err := tx.Insert("INSERT INTO items (name) VALUES (?)", "draft"); err != nil || id != 1 {
+		t.Fatalf("Insert() = %d, %v", id, err)
+	}
+	tx.Rollback()
+
+	id, err := db.Insert("INSERT INTO items (name) VALUES (?)", "kept")
+	if err != nil {
+		t.Fatalf("Insert() failed: %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM items WHERE id = ?", id).Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected the committed row only, got count %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,309 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the set of migrations one subsystem ships, embedded as
+// migrations/<sqlite|postgres>/<version>_<name>.sql and applied in version
+// order. Versions are numbered per component.
+type Schema struct {
+	Component string
+	FS        fs.FS
+
+	// Legacy maps versions in the schema_migrations table, which databases
+	// created before per-component migrations share between all
+	// subsystems, to this component's versions
+	Legacy map[int]int
+
+	// Baseline returns the versions already reflected in a database
+	// created before migrations existed at all (optional)
+	Baseline func(db *DB) ([]int, error)
+}
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Component string
+	Version   int
+	Name      string
+	SQL       string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Component string     `json:"component"`
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Load reads the schema's migrations for a driver
+func (s Schema) Load(driver string) ([]Migration, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Join("migrations", d.migrationDir)
+	entries, err := fs.ReadDir(s.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", entry.Name(), err)
+		}
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		content, err := fs.ReadFile(s.FS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Component: s.Component,
+			Version:   version,
+			Name:      name,
+			SQL:       string(content),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates the component_migrations table
+func (db *DB) ensureMigrationsTable() error {
+	_, err := db.conn.Exec(`
+	CREATE TABLE IF NOT EXISTS component_migrations (
+		component TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		applied_at ` + db.dialect.timestampType + ` NOT NULL,
+		PRIMARY KEY (component, version)
+	)`)
+	return err
+}
+
+// appliedMigrations returns a component's applied versions and their
+// timestamps
+func (db *DB) appliedMigrations(component string) (map[int]time.Time, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM component_migrations WHERE component = ?", component)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// legacyMigrations returns the versions in the shared schema_migrations
+// table, or none when the database never had one
+func (db *DB) legacyMigrations() (map[int]time.Time, error) {
+	exists, err := db.hasTable("schema_migrations")
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	legacy := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		legacy[version] = appliedAt
+	}
+
+	return legacy, rows.Err()
+}
+
+// Migrate applies the pending migrations of every schema, in the order
+// given, and returns the ones it applied. A component with nothing
+// recorded yet first adopts what the database already has: its entries in
+// the legacy schema_migrations table or, without one, its Baseline.
+func (db *DB) Migrate(schemas ...Schema) ([]Migration, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, schema := range schemas {
+		migrations, err := schema.Load(db.dialect.driver)
+		if err != nil {
+			return ran, err
+		}
+
+		applied, err := db.appliedMigrations(schema.Component)
+		if err != nil {
+			return ran, err
+		}
+		if len(applied) == 0 {
+			if applied, err = db.adopt(schema, migrations); err != nil {
+				return ran, err
+			}
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			if err := db.applyMigration(m); err != nil {
+				return ran, fmt.Errorf("migration %s %04d_%s failed: %v", m.Component, m.Version, m.Name, err)
+			}
+			log.Printf("🗄️  Applied migration %s %04d_%s", m.Component, m.Version, m.Name)
+			ran = append(ran, m)
+		}
+	}
+
+	return ran, nil
+}
+
+// adopt records the migrations a database created by an older server
+// already reflects, so they are not re-run
+func (db *DB) adopt(schema Schema, migrations []Migration) (map[int]time.Time, error) {
+	legacy, err := db.legacyMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	adopted := make(map[int]time.Time)
+	source := "schema_migrations"
+	if len(legacy) > 0 {
+		for old, version := range schema.Legacy {
+			if appliedAt, ok := legacy[old]; ok {
+				adopted[version] = appliedAt
+			}
+		}
+	} else if schema.Baseline != nil {
+		versions, err := schema.Baseline(db)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		for _, version := range versions {
+			adopted[version] = now
+		}
+		source = "the existing schema"
+	}
+	if len(adopted) == 0 {
+		return adopted, nil
+	}
+
+	names := make(map[int]string, len(migrations))
+	for _, m := range migrations {
+		names[m.Version] = m.Name
+	}
+	for version, appliedAt := range adopted {
+		if _, err := db.Exec(
+			"INSERT INTO component_migrations (component, version, name, applied_at) VALUES (?, ?, ?, ?)",
+			schema.Component, version, names[version], appliedAt,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("🗄️  Adopted %d %s migration(s) from %s", len(adopted), schema.Component, source)
+	return adopted, nil
+}
+
+// applyMigration runs a migration and records it in a single transaction
+func (db *DB) applyMigration(m Migration) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(
+		db.dialect.rebind("INSERT INTO component_migrations (component, version, name, applied_at) VALUES (?, ?, ?, ?)"),
+		m.Component, m.Version, m.Name, time.Now(),
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MigrationStatus lists every known migration of the schemas and when it
+// was applied
+func (db *DB) MigrationStatus(schemas ...Schema) ([]MigrationStatus, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, schema := range schemas {
+		migrations, err := schema.Load(db.dialect.driver)
+		if err != nil {
+			return nil, err
+		}
+
+		applied, err := db.appliedMigrations(schema.Component)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range migrations {
+			status := MigrationStatus{Component: m.Component, Version: m.Version, Name: m.Name}
+			if appliedAt, ok := applied[m.Version]; ok {
+				appliedAt := appliedAt
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses, nil
+}
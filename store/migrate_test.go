@@ -0,0 +1,90 @@
+package store
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// testSchema returns a component with two migrations for both dialects
+func testSchema(component string) Schema {
+	files := fstest.MapFS{}
+	for _, dir := range []string{"sqlite", "postgres"} {
+		files["migrations/"+dir+"/0001_create.sql"] = &fstest.MapFile{
+			Data: []byte("CREATE TABLE " + component + "_items (id INTEGER PRIMARY KEY)"),
+		}
+		files["migrations/"+dir+"/0002_add_name.sql"] = &fstest.MapFile{
+			Data: []byte("ALTER TABLE " + component + "_items ADD COLUMN name TEXT NOT NULL DEFAULT ''"),
+		}
+	}
+	return Schema{Component: component, FS: files}
+}
+
+// TestMigrateComponents tests that each component's versions are tracked
+// separately and applied once
+func TestMigrateComponents(t *testing.T) {
+	db := newTestDB(t)
+
+	applied, err := db.Migrate(testSchema("alpha"), testSchema("beta"))
+	if err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	if len(applied) != 4 {
+		t.Errorf("Expected 4 migrations applied, got %d", len(applied))
+	}
+
+	applied, err = db.Migrate(testSchema("alpha"), testSchema("beta"))
+	if err != nil {
+		t.Fatalf("Second Migrate() failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations on second run, got %d", len(applied))
+	}
+
+	statuses, err := db.MigrationStatus(testSchema("alpha"), testSchema("gamma"))
+	if err != nil {
+		t.Fatalf("MigrationStatus() failed: %v", err)
+	}
+	for _, status := range statuses {
+		if applied := status.AppliedAt != nil; applied != (status.Component == "alpha") {
+			t.Errorf("Unexpected status for %s %04d: applied=%v", status.Component, status.Version, applied)
+		}
+	}
+}
+
+// TestMigrateAdoptsLegacyVersions tests that versions recorded in the
+// shared schema_migrations table are mapped to each component, and that
+// a component without legacy entries is migrated from scratch
+func TestMigrateAdoptsLegacyVersions(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.Exec(`
+	CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at DATETIME NOT NULL);
+	CREATE TABLE alpha_items (id INTEGER PRIMARY KEY);
+	INSERT INTO schema_migrations VALUES (7, 'alpha_items', CURRENT_TIMESTAMP);
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	alpha, beta := testSchema("alpha"), testSchema("beta")
+	alpha.Legacy = map[int]int{7: 1}
+	beta.Legacy = map[int]int{8: 1}
+	baselined := false
+	beta.Baseline = func(*DB) ([]int, error) {
+		baselined = true
+		return []int{1, 2}, nil
+	}
+
+	applied, err := db.Migrate(alpha, beta)
+	if err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	var got []string
+	for _, m := range applied {
+		got = append(got, m.Component+"/"+m.Name)
+	}
+	if len(got) != 3 || got[0] != "alpha/add_name" || got[1] != "beta/create" || got[2] != "beta/add_name" {
+		t.Errorf("Expected alpha 2 and all of beta applied, got %v", got)
+	}
+	if baselined {
+		t.Error("Expected no baseline once legacy migrations exist")
+	}
+}
@@ -0,0 +1,19 @@
+package telemetry
+
+import (
+	"embed"
+	"oculo-pilot-server/store"
+)
+
+// migrationFS holds the versioned schema migrations for every dialect
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+// Schema is the migrations for the telemetry table
+var Schema = store.Schema{
+	Component: "telemetry",
+	FS:        migrationFS,
+	// Version in the schema_migrations table all subsystems shared
+	Legacy: map[int]int{15: 1},
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS telemetry (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	robot_id TEXT NOT NULL,
+	room TEXT NOT NULL DEFAULT '',
+	message_type TEXT NOT NULL,
+	payload TEXT NOT NULL DEFAULT '{}',
+	recorded_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_telemetry_robot_recorded_at ON telemetry(robot_id, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_telemetry_recorded_at ON telemetry(recorded_at);
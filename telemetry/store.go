@@ -0,0 +1,241 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"log"
+	"oculo-pilot-server/store"
+	"oculo-pilot-server/websocket"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// queueSize bounds samples waiting to be written; further samples are
+	// dropped so persistence never slows routing
+	queueSize = 8192
+
+	// MaxBatchSize keeps one INSERT within the placeholder limits of both
+	// databases
+	MaxBatchSize = 1000
+)
+
+// Config holds how telemetry is batched and how long it is kept
+type Config struct {
+	BatchSize     int           // Samples per INSERT
+	FlushInterval time.Duration // Longest a sample waits for its batch to fill
+	Retention     time.Duration
+}
+
+// Sample is one persisted telemetry message
+type Sample struct {
+	ID      int64           `json:"id"`
+	Time    time.Time       `json:"time"`
+	RobotID string          `json:"robot_id"`
+	Room    string          `json:"room"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Filter selects samples in Query
+type Filter struct {
+	RobotID string
+	Room    string // As stored, see websocket.RoomID
+	Type    string
+	Since   time.Time
+	Until   time.Time // Zero for no upper bound
+	Limit   int
+}
+
+// Store persists routed telemetry in batches and answers queries
+type Store struct {
+	db     *store.DB
+	config Config
+
+	samples chan websocket.TelemetrySample
+	dropped atomic.Int64
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewStore creates a telemetry store. Out of range batch sizes use
+// MaxBatchSize and a missing flush interval one second.
+func NewStore(db *store.DB, config Config) *Store {
+	if config.BatchSize <= 0 || config.BatchSize > MaxBatchSize {
+		config.BatchSize = MaxBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	return &Store{
+		db:      db,
+		config:  config,
+		samples: make(chan websocket.TelemetrySample, queueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// RecordTelemetry queues a sample without blocking
+func (s *Store) RecordTelemetry(sample websocket.TelemetrySample) {
+	select {
+	case s.samples <- sample:
+	default:
+		if s.dropped.Add(1) == 1 {
+			log.Printf("⚠️  Telemetry queue full, dropping samples")
+		}
+	}
+}
+
+// Dropped returns how many samples were lost because the queue was full
+func (s *Store) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Retention returns how long samples are kept
+func (s *Store) Retention() time.Duration {
+	return s.config.Retention
+}
+
+// Run writes queued samples in batches of BatchSize, or whatever has
+// waited FlushInterval, and prunes old ones hourly until Stop is called
+func (s *Store) Run() {
+	defer close(s.done)
+
+	flush := time.NewTicker(s.config.FlushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	batch := make([]websocket.TelemetrySample, 0, s.config.BatchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.Write(batch); err != nil {
+			log.Printf("❌ Failed to write %d telemetry samples: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case sample := <-s.samples:
+			batch = append(batch, sample)
+			if len(batch) >= s.config.BatchSize {
+				write()
+			}
+
+		case <-flush.C:
+			write()
+
+		case now := <-prune.C:
+			if removed, err := s.Prune(now); err != nil {
+				log.Printf("❌ Failed to prune telemetry: %v", err)
+			} else if removed > 0 {
+				log.Printf("🧹 Pruned %d telemetry samples older than %v", removed, s.config.Retention)
+			}
+
+		case <-s.stop:
+			// Write what is already queued so the last positions are kept
+			for {
+				select {
+				case sample := <-s.samples:
+					batch = append(batch, sample)
+					if len(batch) >= s.config.BatchSize {
+						write()
+					}
+				default:
+					write()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stop writes the remaining queued samples and ends the loop
+func (s *Store) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// Write stores samples synchronously in one statement
+func (s *Store) Write(samples []websocket.TelemetrySample) error {
+	placeholders := make([]string, 0, len(samples))
+	args := make([]interface{}, 0, len(samples)*5)
+	for _, sample := range samples {
+		payload := "{}"
+		if len(sample.Data) > 0 {
+			payload = string(sample.Data)
+		}
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?)")
+		args = append(args, sample.RobotID, sample.Room, sample.Type, payload, sample.Time.UTC())
+	}
+
+	_, err := s.db.Exec(
+		"INSERT INTO telemetry (robot_id, room, message_type, payload, recorded_at) VALUES "+
+			strings.Join(placeholders, ", "),
+		args...,
+	)
+	return err
+}
+
+// Query returns matching samples, newest first
+func (s *Store) Query(filter Filter) ([]Sample, error) {
+	query := "SELECT id, recorded_at, robot_id, room, message_type, payload FROM telemetry WHERE recorded_at >= ?"
+	args := []interface{}{filter.Since.UTC()}
+	if !filter.Until.IsZero() {
+		query += " AND recorded_at < ?"
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.RobotID != "" {
+		query += " AND robot_id = ?"
+		args = append(args, filter.RobotID)
+	}
+	if filter.Room != "" {
+		query += " AND room = ?"
+		args = append(args, filter.Room)
+	}
+	if filter.Type != "" {
+		query += " AND message_type = ?"
+		args = append(args, filter.Type)
+	}
+	query += " ORDER BY recorded_at DESC, id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []Sample{}
+	for rows.Next() {
+		var (
+			sample  Sample
+			payload string
+		)
+		if err := rows.Scan(&sample.ID, &sample.Time, &sample.RobotID, &sample.Room, &sample.Type, &payload); err != nil {
+			return nil, err
+		}
+		sample.Payload = json.RawMessage(payload)
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// Prune deletes samples older than the retention window
+func (s *Store) Prune(now time.Time) (int64, error) {
+	result, err := s.db.Exec(
+		"DELETE FROM telemetry WHERE recorded_at < ?",
+		now.Add(-s.config.Retention).UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,83 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"oculo-pilot-server/store"
+	"oculo-pilot-server/websocket"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestStore opens a fresh database for a store
+func newTestStore(t *testing.T, config Config) *Store {
+	t.Helper()
+	db, err := store.Open(store.DriverSQLite, filepath.Join(t.TempDir(), "telemetry.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Migrate(Schema); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	return NewStore(db, config)
+}
+
+// TestStoreBatchesAndQueries tests batched writes, filtering and pruning
+func TestStoreBatchesAndQueries(t *testing.T) {
+	store := newTestStore(t, Config{BatchSize: 2, FlushInterval: time.Hour, Retention: 24 * time.Hour})
+	go store.Run()
+
+	now := time.Now()
+	for i, robotID := range []string{"rover-1", "rover-2", "rover-1"} {
+		store.RecordTelemetry(websocket.TelemetrySample{
+			Time:    now.Add(time.Duration(i) * time.Millisecond),
+			RobotID: robotID,
+			Room:    "default",
+			Type:    "location_update",
+			Data:    json.RawMessage(`{"seq":` + strconv.Itoa(i) + `}`),
+		})
+	}
+
+	// The first two fill a batch; the third waits for the flush interval
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		samples, err := store.Query(Filter{Since: now.Add(-time.Minute), Limit: 10})
+		if err != nil {
+			t.Fatalf("Query() failed: %v", err)
+		}
+		if len(samples) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a full batch to be written, got %d samples", len(samples))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	store.Stop()
+
+	samples, err := store.Query(Filter{Since: now.Add(-time.Minute), RobotID: "rover-1", Limit: 10})
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	if len(samples) != 2 || string(samples[0].Payload) != `{"seq":2}` {
+		t.Errorf("Expected rover-1's 2 samples newest first after Stop, got %+v", samples)
+	}
+
+	if err := store.Write([]websocket.TelemetrySample{{Time: now.Add(-48 * time.Hour), RobotID: "rover-1", Type: "route_update"}}); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	samples, err = store.Query(Filter{Since: now.Add(-72 * time.Hour), Until: now.Add(-time.Hour), Type: "route_update", Limit: 10})
+	if err != nil || len(samples) != 1 || string(samples[0].Payload) != "{}" {
+		t.Errorf("Expected the old route_update with an empty payload, got %+v (%v)", samples, err)
+	}
+
+	removed, err := store.Prune(now)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 pruned sample, got %d", removed)
+	}
+}
@@ -17,7 +17,7 @@ class OculoPilotClient:
     def __init__(self, server_url: str, auth_token: str, client_type: str = "control"):
         self.server_url = server_url
         self.auth_token = auth_token
-        self.client_type = client_type  # web, video, control, telemetry
+        self.client_type = client_type  # web, video, control, telemetry, audio
         self.ws = None
         self.connection_id = None
         self.connected = False
@@ -258,7 +258,7 @@ async def main():
         print("⚠️  No token provided. Please login first:")
         print(f"   curl -X POST http://localhost:8080/api/login \\")
         print(f'     -H "Content-Type: application/json" \\')
-        print(f'     -d \'{{"username":"admin","password":"admin123"}}\'')
+        print(f'     -d \'{{"username":"admin","password":"<password>"}}\'')
         print()
         print("Usage: python ws_simulator.py [ws://server:port/ws] [JWT_TOKEN]")
         return
@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"oculo-pilot-server/logging"
+)
+
+// ControlPolicy decides which robots a user may command. A robot is named
+// by the username its control client connected as.
+type ControlPolicy interface {
+	CanControl(username, robot string) bool
+}
+
+// SetControlPolicy restricts control commands to permitted robots; without
+// a policy they reach every control client
+func (h *Hub) SetControlPolicy(policy ControlPolicy) {
+	h.controlPolicy = policy
+}
+
+// routeControlCommand delivers a command to the control clients in the
+// sender's room that match target and that it may command, and returns how
+// many received it. Emergency stops are not
+// routed here: they reach every robot in the sender's room.
+func (h *Hub) routeControlCommand(sender *Client, msgType string, rawMessage []byte, target routeTarget) int {
+	message := h.withBudget(sender, msgType, rawMessage)
+
+	permitted, denied := h.permittedControlClients(sender.username, sender.Room(), target)
+	h.trackCommand(sender, permitted, rawMessage)
+	held := h.holdCommand(sender.username, sender.Room(), permitted, target, rawMessage, message.deadline)
+	for _, client := range permitted {
+		h.deliver(client, message)
+	}
+	if target.connectionID == "" {
+		envelope := relayEnvelope{
+			Kind:     relayControl,
+			Room:     sender.Room(),
+			RobotID:  target.robotID,
+			Types:    []ClientType{ClientTypeControl},
+			Sender:   sender.username,
+			Deadline: unixMilli(message.deadline),
+			Data:     rawMessage,
+		}
+		h.publishRelay(envelope)
+		h.publishEdge(sender, msgType, rawMessage, target)
+	}
+
+	if len(permitted) == 0 && denied == 0 && !held {
+		h.deadLetter(sender, msgType, rawMessage, target, ClientTypeControl)
+	}
+	if len(permitted) == 0 && denied > 0 {
+		logging.Sampled("ws_control_denied", "🚫 %s from %s rejected: no permitted robots connected",
+			msgType, sender.username)
+		sender.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "not_permitted",
+			"message_type": msgType,
+		})
+	}
+	return len(permitted)
+}
+
+// permittedControlClients returns the local control clients in room
+// matching target that username may command, and how many it may not
+func (h *Hub) permittedControlClients(username, room string, target routeTarget) ([]*Client, int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var permitted []*Client
+	denied := 0
+	for client := range h.clients[ClientTypeControl] {
+		if client.Room() != room || !target.matches(client) {
+			continue
+		}
+		if h.controlPolicy == nil || h.controlPolicy.CanControl(username, client.username) {
+			permitted = append(permitted, client)
+		} else {
+			denied++
+		}
+	}
+	return permitted, denied
+}
@@ -0,0 +1,43 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+)
+
+// mockControlPolicy allows only listed user/robot pairs
+type mockControlPolicy map[string]string
+
+func (p mockControlPolicy) CanControl(username, robot string) bool {
+	return p[username] == robot
+}
+
+// TestControlPolicy tests routing control commands to permitted robots only
+func TestControlPolicy(t *testing.T) {
+	hub := NewHub()
+	hub.SetControlPolicy(mockControlPolicy{"alice": "robot-1"})
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+	robot1 := newTestClient(hub, ClientTypeControl, "robot-1")
+	robot2 := newTestClient(hub, ClientTypeControl, "robot-2")
+
+	hub.RouteMessage(alice, []byte(`{"type":"control_command"}`))
+	if len(drainMessages(robot1)) != 1 || len(drainMessages(robot2)) != 0 {
+		t.Error("Expected command to reach only the permitted robot")
+	}
+
+	hub.RouteMessage(bob, []byte(`{"type":"control_command"}`))
+	if len(drainMessages(robot1)) != 0 || len(drainMessages(robot2)) != 0 {
+		t.Error("Expected command from unpermitted user to be dropped")
+	}
+	replies := drainMessages(bob)
+	if len(replies) != 1 || !strings.Contains(string(replies[0]), "not_permitted") {
+		t.Errorf("Expected not_permitted error, got %q", replies)
+	}
+
+	// Emergency stops always reach every robot
+	hub.RouteMessage(bob, []byte(`{"type":"emergency_stop"}`))
+	if len(drainMessages(robot1)) != 1 || len(drainMessages(robot2)) != 1 {
+		t.Error("Expected emergency stop to reach every robot")
+	}
+}
@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+// ConnectionInfo describes a connected client for the admin API
+type ConnectionInfo struct {
+	ConnectionID    string     `json:"connection_id"`
+	Type            ClientType `json:"client_type"`
+	Username        string     `json:"username"`
+	Room            string     `json:"room"`
+	RobotID         string     `json:"robot_id,omitempty"`
+	RemoteAddr      string     `json:"remote_addr,omitempty"`
+	ConnectedAt     time.Time  `json:"connected_at"`
+	LastActivity    time.Time  `json:"last_activity"`
+	Scopes          []string   `json:"scopes,omitempty"`
+	Queued          int        `json:"queued"`
+	Compressed      bool       `json:"compressed"`
+	ProtocolVersion int        `json:"protocol_version"`
+	Features        []string   `json:"features,omitempty"`    // Negotiated features (protocol version 2)
+	DetachedAt      *time.Time `json:"detached_at,omitempty"` // Set while waiting to be resumed
+
+	// Device and software reported at handshake
+	ClientMetadata
+}
+
+// EmergencyStopState is the last emergency stop seen by the hub
+type EmergencyStopState struct {
+	Active    bool       `json:"active"`
+	ChangedBy string     `json:"changed_by,omitempty"`
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+	Room      string     `json:"room,omitempty"`
+}
+
+// Route describes where RouteMessage forwards a message type. Every route
+// stays within the sender's room.
+type Route struct {
+	MessageType  string       `json:"message_type"`
+	From         []ClientType `json:"from,omitempty"` // Empty means any sender
+	To           []ClientType `json:"to"`             // Empty when the server handles it
+	Scope        string       `json:"required_scope,omitempty"`
+	Topic        string       `json:"topic,omitempty"`         // Also published on the topic (see topic.go)
+	CommandRules bool         `json:"command_rules,omitempty"` // Checked against the configured CommandRules
+	Recipients   int          `json:"recipients"`              // Currently connected recipients
+}
+
+// Connections lists connected clients ordered by connection time
+func (h *Hub) Connections() []ConnectionInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	connections := make([]ConnectionInfo, 0)
+	for clientType, clients := range h.clients {
+		for client := range clients {
+			info := ConnectionInfo{
+				ConnectionID:    client.connectionID,
+				Type:            clientType,
+				Username:        client.username,
+				Room:            RoomID(client.Room()),
+				RobotID:         client.RobotID(),
+				RemoteAddr:      client.remoteAddr,
+				ConnectedAt:     client.connectedAt,
+				LastActivity:    client.LastActivity(),
+				Scopes:          client.scopes,
+				Queued:          len(client.send),
+				Compressed:      client.Compressed(),
+				ProtocolVersion: client.ProtocolVersion(),
+				Features:        client.Features(),
+				ClientMetadata:  client.Metadata(),
+			}
+			if detachedAt := client.DetachedAt(); !detachedAt.IsZero() {
+				info.DetachedAt = &detachedAt
+			}
+			connections = append(connections, info)
+		}
+	}
+
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].ConnectedAt.Before(connections[j].ConnectedAt)
+	})
+	return connections
+}
+
+// EmergencyStop returns the current emergency stop state
+func (h *Hub) EmergencyStop() EmergencyStopState {
+	h.estopMu.RLock()
+	defer h.estopMu.RUnlock()
+	return h.estop
+}
+
+// setEmergencyStop records an emergency stop or reset in a room
+func (h *Hub) setEmergencyStop(active bool, username, room string) {
+	now := time.Now()
+	state := EmergencyStopState{Active: active, ChangedBy: username, ChangedAt: &now, Room: RoomID(room)}
+
+	h.estopMu.Lock()
+	defer h.estopMu.Unlock()
+	h.estop = state
+	if h.estopRooms == nil {
+		h.estopRooms = make(map[string]EmergencyStopState)
+	}
+	h.estopRooms[room] = state
+}
+
+// Routes returns the routing table of the registered handlers, then of
+// unregistered types and monitor copies, with current recipient counts
+func (h *Hub) Routes() []Route {
+	result := make([]Route, 0, len(h.handlers)+2)
+	for _, msgType := range h.MessageTypes() {
+		handler := h.handlers[msgType]
+		result = append(result, Route{
+			MessageType:  msgType,
+			From:         handler.senders,
+			To:           handler.route.To,
+			Scope:        handler.route.Scope,
+			Topic:        messageTopics[msgType],
+			CommandRules: handler.route.CommandRules && !h.commandRules.empty(),
+		})
+	}
+
+	// Unregistered types are broadcast to the room (see routeUnknown), and
+	// monitors get a redacted copy of everything
+	var others []ClientType
+	for _, clientType := range ClientTypes() {
+		if clientType != ClientTypeMonitor {
+			others = append(others, clientType)
+		}
+	}
+	result = append(result,
+		Route{MessageType: "* (unregistered)", To: others, Scope: ScopeControl},
+		Route{MessageType: "* (redacted copy)", To: []ClientType{ClientTypeMonitor}},
+	)
+
+	for i := range result {
+		if result[i].To == nil {
+			result[i].To = []ClientType{}
+		}
+		for _, clientType := range result[i].To {
+			result[i].Recipients += h.GetClientCountByType(clientType)
+		}
+	}
+	return result
+}
+
+// WhitelistStatus describes the effective IP whitelist
+type WhitelistStatus struct {
+	Enabled   bool                `json:"enabled"`
+	Networks  []string            `json:"networks"`
+	Hostnames map[string][]string `json:"hostnames,omitempty"` // Hostname -> resolved addresses
+}
+
+// Whitelist returns the parsed whitelist, including resolved hostnames
+func (h *Handler) Whitelist() WhitelistStatus {
+	status := WhitelistStatus{
+		Enabled:  h.enableWhitelist,
+		Networks: make([]string, 0, len(h.allowedNetworks)),
+	}
+	for _, network := range h.allowedNetworks {
+		status.Networks = append(status.Networks, network.String())
+	}
+	if h.allowedHosts != nil {
+		status.Hostnames = h.allowedHosts.Resolved()
+	}
+	return status
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHubConnections tests listing connected clients in connection order
+func TestHubConnections(t *testing.T) {
+	hub := NewHub()
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.connectedAt = time.Now().Add(-time.Minute)
+	robot.enqueue(outbound{data: []byte(`{"type":"control_command"}`)})
+	operator := newTestClient(hub, ClientTypeWeb, "operator")
+	operator.connectedAt = time.Now()
+
+	connections := hub.Connections()
+	if len(connections) != 2 {
+		t.Fatalf("Expected 2 connections, got %d", len(connections))
+	}
+	if connections[0].Username != "robot" || connections[0].Queued != 1 {
+		t.Errorf("Unexpected first connection: %+v", connections[0])
+	}
+	if connections[1].Username != "operator" || connections[1].Type != ClientTypeWeb {
+		t.Errorf("Unexpected second connection: %+v", connections[1])
+	}
+}
+
+// TestHubEmergencyStopState tests tracking emergency stop and reset
+func TestHubEmergencyStopState(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "operator")
+
+	if hub.EmergencyStop().Active {
+		t.Fatal("Expected no emergency stop at startup")
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop"}`))
+	state := hub.EmergencyStop()
+	if !state.Active || state.ChangedBy != "operator" || state.ChangedAt == nil {
+		t.Errorf("Unexpected state after stop: %+v", state)
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop_reset"}`))
+	if hub.EmergencyStop().Active {
+		t.Error("Expected emergency stop to be cleared after reset")
+	}
+}
+
+// TestHubRoutes tests that the routing table follows the registered
+// handlers, with recipient counts
+func TestHubRoutes(t *testing.T) {
+	hub := NewHub()
+	hub.SetCommandRules(CommandRules{Required: []string{"data.action"}})
+	hub.RegisterHandler("lidar_scan", func(ctx *MessageContext) {
+		hub.Forward(ctx, ClientTypeWeb)
+	}, ClientTypeControl)
+	hub.DescribeRoute("lidar_scan", RouteInfo{To: []ClientType{ClientTypeWeb}})
+	newTestClient(hub, ClientTypeControl, "robot")
+
+	routes := make(map[string]Route)
+	for _, route := range hub.Routes() {
+		routes[route.MessageType] = route
+	}
+	if route := routes["control_command"]; route.Recipients != 1 || !route.CommandRules || route.Scope != ScopeControl {
+		t.Errorf("Expected control_command checked against rules with 1 recipient, got %+v", route)
+	}
+	if route := routes["control_response"]; route.Recipients != 0 || route.Topic != "control.response" {
+		t.Errorf("Expected control_response on its topic with 0 recipients, got %+v", route)
+	}
+	if route := routes["lidar_scan"]; len(route.From) != 1 || len(route.To) != 1 || route.To[0] != ClientTypeWeb {
+		t.Errorf("Expected the registered lidar_scan route, got %+v", route)
+	}
+	if route, ok := routes["heartbeat"]; !ok || len(route.To) != 0 {
+		t.Errorf("Expected heartbeat handled by the server, got %+v", route)
+	}
+}
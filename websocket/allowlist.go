@@ -0,0 +1,398 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// resolveFunc looks up a hostname and returns its addresses and record TTL
+// (0 when unknown)
+type resolveFunc func(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+
+// hostEntry tracks the current addresses of one allowlisted hostname
+type hostEntry struct {
+	ips         []net.IP
+	nextRefresh time.Time
+}
+
+// HostAllowlist keeps allowlisted hostnames resolved, refreshing each one
+// when its DNS TTL expires (clamped to [minRefresh, maxRefresh])
+type HostAllowlist struct {
+	hosts   map[string]*hostEntry
+	resolve resolveFunc
+
+	minRefresh time.Duration
+	maxRefresh time.Duration
+
+	mu   sync.RWMutex
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewHostAllowlist creates an allowlist for the given hostnames
+func NewHostAllowlist(hostnames []string) *HostAllowlist {
+	hosts := make(map[string]*hostEntry, len(hostnames))
+	for _, host := range hostnames {
+		hosts[strings.ToLower(strings.TrimSuffix(host, "."))] = &hostEntry{}
+	}
+
+	return &HostAllowlist{
+		hosts:      hosts,
+		resolve:    lookupHostTTL,
+		minRefresh: 30 * time.Second,
+		maxRefresh: 10 * time.Minute,
+		stop:       make(chan struct{}),
+	}
+}
+
+// isHostname reports whether an allowlist entry names a host rather than an
+// address or CIDR. Hostnames must be fully qualified (contain a dot).
+func isHostname(entry string) bool {
+	if strings.ContainsAny(entry, "/:") || net.ParseIP(entry) != nil {
+		return false
+	}
+	entry = strings.TrimSuffix(entry, ".")
+	if !strings.Contains(entry, ".") {
+		return false
+	}
+
+	for _, label := range strings.Split(entry, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Contains reports whether ip currently belongs to any allowlisted hostname
+func (a *HostAllowlist) Contains(ip net.IP) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, entry := range a.hosts {
+		for _, resolved := range entry.ips {
+			if resolved.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Resolved returns the current addresses of every hostname
+func (a *HostAllowlist) Resolved() map[string][]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	resolved := make(map[string][]string, len(a.hosts))
+	for host, entry := range a.hosts {
+		ips := make([]string, 0, len(entry.ips))
+		for _, ip := range entry.ips {
+			ips = append(ips, ip.String())
+		}
+		resolved[host] = ips
+	}
+	return resolved
+}
+
+// Refresh resolves every hostname whose refresh time has passed. Addresses
+// from the previous lookup are kept when a lookup fails.
+func (a *HostAllowlist) Refresh(now time.Time) {
+	a.mu.RLock()
+	var due []string
+	for host, entry := range a.hosts {
+		if !now.Before(entry.nextRefresh) {
+			due = append(due, host)
+		}
+	}
+	a.mu.RUnlock()
+
+	for _, host := range due {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ips, ttl, err := a.resolve(ctx, host)
+		cancel()
+
+		a.mu.Lock()
+		entry := a.hosts[host]
+		if err != nil {
+			log.Printf("⚠️  Failed to resolve allowlisted host %s: %v", host, err)
+			entry.nextRefresh = now.Add(a.minRefresh)
+			a.mu.Unlock()
+			continue
+		}
+
+		if !sameIPs(entry.ips, ips) {
+			log.Printf("🔒 Allowlisted host %s resolved to %v", host, ips)
+		}
+		entry.ips = ips
+		entry.nextRefresh = now.Add(a.clampTTL(ttl))
+		a.mu.Unlock()
+	}
+}
+
+// clampTTL bounds a record TTL to the configured refresh interval
+func (a *HostAllowlist) clampTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return a.maxRefresh
+	}
+	if ttl < a.minRefresh {
+		return a.minRefresh
+	}
+	if ttl > a.maxRefresh {
+		return a.maxRefresh
+	}
+	return ttl
+}
+
+// nextDue returns the earliest pending refresh time
+func (a *HostAllowlist) nextDue() time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var next time.Time
+	for _, entry := range a.hosts {
+		if next.IsZero() || entry.nextRefresh.Before(next) {
+			next = entry.nextRefresh
+		}
+	}
+	return next
+}
+
+// Run refreshes hostnames as their TTLs expire until Stop is called
+func (a *HostAllowlist) Run() {
+	for {
+		wait := time.Until(a.nextDue())
+		if wait < time.Second {
+			wait = time.Second
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-a.stop:
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			a.Refresh(now)
+		}
+	}
+}
+
+// Stop ends the refresh loop
+func (a *HostAllowlist) Stop() {
+	a.once.Do(func() { close(a.stop) })
+}
+
+// sameIPs reports whether two address lists are equal
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupHostTTL resolves A and AAAA records directly against the system
+// nameservers to learn the record TTL, falling back to the Go resolver
+// (without TTL) when that fails
+func lookupHostTTL(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	for _, server := range systemNameservers() {
+		var (
+			ips    []net.IP
+			minTTL uint32
+			failed bool
+		)
+		for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			found, ttl, err := queryDNS(ctx, server, host, qtype)
+			if err != nil {
+				failed = true
+				break
+			}
+			if len(found) > 0 && (minTTL == 0 || ttl < minTTL) {
+				minTTL = ttl
+			}
+			ips = append(ips, found...)
+		}
+		if !failed && len(ips) > 0 {
+			return ips, time.Duration(minTTL) * time.Second, nil
+		}
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	return ips, 0, err
+}
+
+// systemNameservers reads nameserver addresses from /etc/resolv.conf
+func systemNameservers() []string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	return servers
+}
+
+// queryDNS sends a single UDP query and returns the answer addresses and
+// their smallest TTL. The query ID is random and the socket is connected
+// to the server, so an off-path sender has to guess both the ID and the
+// source port; replies that do not match the query are ignored.
+func queryDNS(ctx context.Context, server, host string, qtype dnsmessage.Type) ([]net.IP, uint32, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+	question := dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{question},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(packet); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, 1232)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var response dnsmessage.Message
+		if err := response.Unpack(buf[:n]); err != nil || !answersQuery(response, id, question) {
+			continue
+		}
+		if response.Truncated {
+			return nil, 0, fmt.Errorf("truncated DNS response")
+		}
+		if response.RCode != dnsmessage.RCodeSuccess && response.RCode != dnsmessage.RCodeNameError {
+			return nil, 0, fmt.Errorf("DNS query failed: %v", response.RCode)
+		}
+		ips, ttl := answerAddresses(response, question)
+		return ips, ttl, nil
+	}
+}
+
+// maxCNAMEHops bounds the CNAME chain followed in one answer
+const maxCNAMEHops = 8
+
+// answersQuery reports whether a response belongs to the query: same ID
+// and the question echoed back
+func answersQuery(response dnsmessage.Message, id uint16, question dnsmessage.Question) bool {
+	if !response.Response || response.ID != id || len(response.Questions) != 1 {
+		return false
+	}
+	echoed := response.Questions[0]
+	return echoed.Type == question.Type && echoed.Class == question.Class && sameName(echoed.Name, question.Name)
+}
+
+// answerAddresses returns the addresses of the question's name and their
+// smallest TTL. Only records of the asked type and class are used, owned
+// by the name itself or by a name its CNAME chain leads to; other records
+// in the answer section are ignored, so a resolver cannot slip in
+// addresses for a different host.
+func answerAddresses(response dnsmessage.Message, question dnsmessage.Question) ([]net.IP, uint32) {
+	// Follow the chain first: resolvers usually list it in order, but
+	// nothing requires them to
+	target := question.Name
+	for hops := 0; ; hops++ {
+		next, ok := cnameTarget(response.Answers, target)
+		if !ok {
+			break
+		}
+		if hops >= maxCNAMEHops {
+			// A loop; the caller falls back to the Go resolver
+			return nil, 0
+		}
+		target = next
+	}
+
+	var (
+		ips    []net.IP
+		minTTL uint32
+	)
+	for _, answer := range response.Answers {
+		if answer.Header.Class != question.Class || answer.Header.Type != question.Type || !sameName(answer.Header.Name, target) {
+			continue
+		}
+		var ip net.IP
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		default:
+			continue
+		}
+		ips = append(ips, ip)
+		if minTTL == 0 || answer.Header.TTL < minTTL {
+			minTTL = answer.Header.TTL
+		}
+	}
+	return ips, minTTL
+}
+
+// cnameTarget returns the name a CNAME record for owner points to
+func cnameTarget(answers []dnsmessage.Resource, owner dnsmessage.Name) (dnsmessage.Name, bool) {
+	for _, answer := range answers {
+		if body, ok := answer.Body.(*dnsmessage.CNAMEResource); ok &&
+			answer.Header.Class == dnsmessage.ClassINET && sameName(answer.Header.Name, owner) {
+			return body.CNAME, true
+		}
+	}
+	return dnsmessage.Name{}, false
+}
+
+// sameName compares domain names, which are case-insensitive
+func sameName(a, b dnsmessage.Name) bool {
+	return strings.EqualFold(a.String(), b.String())
+}
@@ -0,0 +1,221 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// TestIsHostname tests classification of allowlist entries
+func TestIsHostname(t *testing.T) {
+	tests := []struct {
+		entry    string
+		expected bool
+	}{
+		{"vpn.example.com", true},
+		{"vpn.example.com.", true},
+		{"office-1.example.org", true},
+		{"192.168.1.0/24", false},
+		{"10.0.0.1", false},
+		{"2001:db8::/32", false},
+		{"invalid", false},
+		{"bad_host.example.com", false},
+		{"-bad.example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := isHostname(tt.entry); got != tt.expected {
+			t.Errorf("isHostname(%q) = %v, expected %v", tt.entry, got, tt.expected)
+		}
+	}
+}
+
+// TestHostAllowlistRefresh tests TTL-aware refresh and keeping addresses on failure
+func TestHostAllowlistRefresh(t *testing.T) {
+	allowlist := NewHostAllowlist([]string{"VPN.example.com."})
+
+	var (
+		calls  int
+		answer = []net.IP{net.ParseIP("203.0.113.10")}
+		ttl    = 60 * time.Second
+		fail   error
+	)
+	allowlist.resolve = func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		calls++
+		if host != "vpn.example.com" {
+			t.Errorf("Unexpected host %q", host)
+		}
+		return answer, ttl, fail
+	}
+
+	now := time.Now()
+	allowlist.Refresh(now)
+	if !allowlist.Contains(net.ParseIP("203.0.113.10")) {
+		t.Fatal("Expected resolved address to be allowed")
+	}
+
+	// Not due again until the TTL expires
+	allowlist.Refresh(now.Add(30 * time.Second))
+	if calls != 1 {
+		t.Errorf("Expected 1 lookup before TTL expiry, got %d", calls)
+	}
+
+	// Address change is picked up after the TTL
+	answer = []net.IP{net.ParseIP("203.0.113.20")}
+	allowlist.Refresh(now.Add(61 * time.Second))
+	if allowlist.Contains(net.ParseIP("203.0.113.10")) {
+		t.Error("Expected old address to be removed")
+	}
+	if !allowlist.Contains(net.ParseIP("203.0.113.20")) {
+		t.Error("Expected new address to be allowed")
+	}
+
+	// Lookup failures keep the last known addresses
+	fail = errors.New("timeout")
+	allowlist.Refresh(now.Add(200 * time.Second))
+	if !allowlist.Contains(net.ParseIP("203.0.113.20")) {
+		t.Error("Expected address to survive failed lookup")
+	}
+}
+
+// TestHostAllowlistClampTTL tests TTL bounds
+func TestHostAllowlistClampTTL(t *testing.T) {
+	allowlist := NewHostAllowlist(nil)
+	allowlist.minRefresh = 30 * time.Second
+	allowlist.maxRefresh = 10 * time.Minute
+
+	tests := []struct {
+		ttl      time.Duration
+		expected time.Duration
+	}{
+		{0, 10 * time.Minute},
+		{5 * time.Second, 30 * time.Second},
+		{2 * time.Minute, 2 * time.Minute},
+		{time.Hour, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := allowlist.clampTTL(tt.ttl); got != tt.expected {
+			t.Errorf("clampTTL(%v) = %v, expected %v", tt.ttl, got, tt.expected)
+		}
+	}
+}
+
+// TestIsIPAllowedHostname tests whitelist checks against resolved hostnames
+func TestIsIPAllowedHostname(t *testing.T) {
+	handler := NewHandler(NewHub(), &mockAuthValidator{},
+		[]string{"192.168.1.0/24", "vpn.example.com"}, true, 10*time.Second, 65536)
+
+	if len(handler.allowedNetworks) != 1 || handler.allowedHosts == nil {
+		t.Fatal("Expected one CIDR and one hostname")
+	}
+	handler.allowedHosts.resolve = func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		return []net.IP{net.ParseIP("198.51.100.7")}, time.Minute, nil
+	}
+	handler.allowedHosts.Refresh(time.Now())
+
+	if !handler.isIPAllowed("198.51.100.7:5000") {
+		t.Error("Expected resolved hostname address to be allowed")
+	}
+	if handler.isIPAllowed("198.51.100.8:5000") {
+		t.Error("Expected other address to be blocked")
+	}
+	if !handler.isIPAllowed("192.168.1.5:5000") {
+		t.Error("Expected CIDR address to be allowed")
+	}
+}
+
+// staticPeerVerifier accepts a fixed set of addresses
+type staticPeerVerifier map[string]bool
+
+func (v staticPeerVerifier) Allowed(ip net.IP) bool {
+	return v[ip.String()]
+}
+
+// TestIsPeerAllowed tests tunnel peer verification against the socket address
+func TestIsPeerAllowed(t *testing.T) {
+	handler := NewHandler(NewHub(), &mockAuthValidator{}, nil, false, 10*time.Second, 65536)
+
+	if !handler.isPeerAllowed("203.0.113.1:4000") {
+		t.Error("Expected all peers allowed without a verifier")
+	}
+
+	handler.SetPeerVerifier(staticPeerVerifier{"10.8.0.2": true})
+	if !handler.isPeerAllowed("10.8.0.2:4000") {
+		t.Error("Expected tunnel peer to be allowed")
+	}
+	if handler.isPeerAllowed("10.8.0.3:4000") {
+		t.Error("Expected non-peer to be rejected")
+	}
+	if handler.isPeerAllowed("not-an-ip") {
+		t.Error("Expected unparsable address to be rejected")
+	}
+}
+
+// TestQueryDNSValidatesAnswers tests that replies to another query are
+// skipped and only records on the question's CNAME chain are used
+func TestQueryDNSValidatesAnswers(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer server.Close()
+
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil {
+			return
+		}
+		reply := func(id uint16, question dnsmessage.Question, answers ...dnsmessage.Resource) {
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: id, Response: true},
+				Questions: []dnsmessage.Question{question},
+				Answers:   answers,
+			}
+			packet, _ := response.Pack()
+			server.WriteTo(packet, addr)
+		}
+		question := query.Questions[0]
+		robot := question.Name
+		edge := dnsmessage.MustNewName("edge.cdn.example.")
+		other := dnsmessage.MustNewName("attacker.example.")
+		a := func(name dnsmessage.Name, class dnsmessage.Class, ttl uint32, ip byte) dnsmessage.Resource {
+			return dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeA, Class: class, TTL: ttl},
+				Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, ip}},
+			}
+		}
+
+		// A forged reply with the wrong ID, then one for another name
+		reply(query.ID+1, question, a(robot, dnsmessage.ClassINET, 5, 66))
+		reply(query.ID, dnsmessage.Question{Name: other, Type: question.Type, Class: question.Class}, a(other, dnsmessage.ClassINET, 5, 66))
+		reply(query.ID, question,
+			a(other, dnsmessage.ClassINET, 5, 66),
+			a(edge, dnsmessage.ClassINET, 60, 1),
+			a(robot, dnsmessage.ClassCHAOS, 5, 66),
+			dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: robot, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 300},
+				Body:   &dnsmessage.CNAMEResource{CNAME: edge},
+			},
+		)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ips, ttl, err := queryDNS(ctx, server.LocalAddr().String(), "Robot.Example.com", dnsmessage.TypeA)
+	if err != nil {
+		t.Fatalf("queryDNS failed: %v", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.IPv4(10, 0, 0, 1)) || ttl != 60 {
+		t.Errorf("Expected only 10.0.0.1 via the CNAME with TTL 60, got %v (TTL %d)", ips, ttl)
+	}
+}
@@ -0,0 +1,234 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AnomalyConfig configures statistical anomaly detection on telemetry
+type AnomalyConfig struct {
+	// Fields are dotted paths into telemetry messages (e.g. "data.speed").
+	// A ":rate" suffix tracks the per-second rate of change instead of the
+	// raw value (e.g. "data.battery:rate" for battery drain).
+	Fields []string
+
+	// Alpha is the EWMA smoothing factor (0 < alpha <= 1)
+	Alpha float64
+
+	// Threshold is the z-score above which a sample is anomalous
+	Threshold float64
+
+	// Warmup is the number of samples required before alerting
+	Warmup int
+}
+
+// Anomaly describes a telemetry sample that deviates from its baseline
+type Anomaly struct {
+	Field  string  `json:"field"`
+	Value  float64 `json:"value"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	ZScore float64 `json:"z_score"`
+}
+
+// anomalyField is a parsed field specification
+type anomalyField struct {
+	name string
+	path []string
+	rate bool
+}
+
+// ewmaState tracks the running baseline of a single series
+type ewmaState struct {
+	mean      float64
+	variance  float64
+	samples   int
+	lastValue float64
+	lastTime  time.Time
+}
+
+// AnomalyDetector flags telemetry values far from their EWMA baseline
+type AnomalyDetector struct {
+	fields    []anomalyField
+	alpha     float64
+	threshold float64
+	warmup    int
+
+	// Baselines keyed by source and field name
+	series map[string]*ewmaState
+	mu     sync.Mutex
+}
+
+// NewAnomalyDetector creates a detector for the configured fields
+func NewAnomalyDetector(cfg AnomalyConfig) *AnomalyDetector {
+	var fields []anomalyField
+	for _, spec := range cfg.Fields {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		field := anomalyField{name: spec}
+		if strings.HasSuffix(spec, ":rate") {
+			field.rate = true
+			spec = strings.TrimSuffix(spec, ":rate")
+		}
+		field.path = strings.Split(spec, ".")
+		fields = append(fields, field)
+	}
+
+	alpha := cfg.Alpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = 0.1
+	}
+
+	return &AnomalyDetector{
+		fields:    fields,
+		alpha:     alpha,
+		threshold: cfg.Threshold,
+		warmup:    cfg.Warmup,
+		series:    make(map[string]*ewmaState),
+	}
+}
+
+// Observe feeds a telemetry message from source into the detector and
+// returns any anomalies it contains
+func (d *AnomalyDetector) Observe(source string, rawMessage []byte, now time.Time) []Anomaly {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(rawMessage, &payload); err != nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var anomalies []Anomaly
+	for _, field := range d.fields {
+		value, ok := lookupNumber(payload, field.path)
+		if !ok {
+			continue
+		}
+
+		key := source + "|" + field.name
+		state := d.series[key]
+		if state == nil {
+			state = &ewmaState{}
+			d.series[key] = state
+		}
+
+		sample := value
+		if field.rate {
+			previous, previousTime := state.lastValue, state.lastTime
+			state.lastValue, state.lastTime = value, now
+			if previousTime.IsZero() {
+				continue
+			}
+			elapsed := now.Sub(previousTime).Seconds()
+			if elapsed <= 0 {
+				continue
+			}
+			sample = (value - previous) / elapsed
+		}
+
+		if anomaly, ok := d.update(state, field.name, sample); ok {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+
+	return anomalies
+}
+
+// update scores a sample against the baseline and then folds it in
+func (d *AnomalyDetector) update(state *ewmaState, name string, sample float64) (Anomaly, bool) {
+	var anomaly Anomaly
+	flagged := false
+
+	if state.samples == 0 {
+		state.mean = sample
+	} else {
+		stdDev := math.Sqrt(state.variance)
+		if state.samples >= d.warmup && stdDev > 0 {
+			z := (sample - state.mean) / stdDev
+			if math.Abs(z) >= d.threshold {
+				anomaly = Anomaly{
+					Field:  name,
+					Value:  sample,
+					Mean:   state.mean,
+					StdDev: stdDev,
+					ZScore: z,
+				}
+				flagged = true
+			}
+		}
+
+		diff := sample - state.mean
+		state.mean += d.alpha * diff
+		state.variance = (1 - d.alpha) * (state.variance + d.alpha*diff*diff)
+	}
+	state.samples++
+
+	return anomaly, flagged
+}
+
+// lookupNumber walks a dotted path through decoded JSON and returns a number
+func lookupNumber(payload map[string]interface{}, path []string) (float64, bool) {
+	current, ok := lookupValue(payload, path)
+	if !ok {
+		return 0, false
+	}
+	value, ok := current.(float64)
+	return value, ok
+}
+
+// lookupValue walks a dotted path through decoded JSON and returns the
+// value it names
+func lookupValue(payload map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = payload
+	for _, key := range path {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SetAnomalyDetector enables anomaly detection on telemetry routed by the hub
+func (h *Hub) SetAnomalyDetector(detector *AnomalyDetector) {
+	h.anomaly = detector
+}
+
+// detectAnomalies runs telemetry through the detector and alerts web clients
+func (h *Hub) detectAnomalies(sender *Client, msgType string, rawMessage []byte) {
+	if h.anomaly == nil {
+		return
+	}
+
+	for _, anomaly := range h.anomaly.Observe(sender.username, rawMessage, time.Now()) {
+		event := map[string]interface{}{
+			"type":         "telemetry_anomaly",
+			"source":       sender.username,
+			"message_type": msgType,
+			"anomaly":      anomaly,
+			"timestamp":    time.Now().Unix(),
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Failed to marshal telemetry anomaly: %v", err)
+			continue
+		}
+
+		h.broadcastToRobot(sender.Room(), sender.RobotID(), []ClientType{ClientTypeWeb}, data)
+		log.Printf("⚠️  Telemetry anomaly from %s: %s=%.3f (mean=%.3f, z=%.2f)",
+			sender.username, anomaly.Field, anomaly.Value, anomaly.Mean, anomaly.ZScore)
+	}
+}
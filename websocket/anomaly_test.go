@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestAnomalyDetectorFlagsSpike tests z-score detection after warmup
+func TestAnomalyDetectorFlagsSpike(t *testing.T) {
+	detector := NewAnomalyDetector(AnomalyConfig{
+		Fields:    []string{"data.speed"},
+		Alpha:     0.1,
+		Threshold: 3.0,
+		Warmup:    10,
+	})
+
+	now := time.Now()
+	for i := 0; i < 50; i++ {
+		speed := 10.0 + float64(i%3)*0.5
+		msg := fmt.Sprintf(`{"type":"location_update","data":{"speed":%f}}`, speed)
+		if anomalies := detector.Observe("robot", []byte(msg), now); len(anomalies) != 0 {
+			t.Fatalf("Unexpected anomaly for normal sample %d: %+v", i, anomalies)
+		}
+	}
+
+	anomalies := detector.Observe("robot", []byte(`{"type":"location_update","data":{"speed":40}}`), now)
+	if len(anomalies) != 1 {
+		t.Fatalf("Expected 1 anomaly for spike, got %d", len(anomalies))
+	}
+	if anomalies[0].Field != "data.speed" || anomalies[0].Value != 40 {
+		t.Errorf("Unexpected anomaly: %+v", anomalies[0])
+	}
+}
+
+// TestAnomalyDetectorWarmup tests that no alerts fire before the baseline is established
+func TestAnomalyDetectorWarmup(t *testing.T) {
+	detector := NewAnomalyDetector(AnomalyConfig{
+		Fields:    []string{"speed"},
+		Threshold: 3.0,
+		Warmup:    100,
+	})
+
+	now := time.Now()
+	for _, speed := range []float64{1, 2, 1, 2, 500} {
+		msg := fmt.Sprintf(`{"speed":%f}`, speed)
+		if anomalies := detector.Observe("robot", []byte(msg), now); len(anomalies) != 0 {
+			t.Fatalf("Unexpected anomaly during warmup: %+v", anomalies)
+		}
+	}
+}
+
+// TestAnomalyDetectorRate tests rate-of-change fields
+func TestAnomalyDetectorRate(t *testing.T) {
+	detector := NewAnomalyDetector(AnomalyConfig{
+		Fields:    []string{"data.battery:rate"},
+		Alpha:     0.1,
+		Threshold: 3.0,
+		Warmup:    10,
+	})
+
+	start := time.Now()
+	battery := 100.0
+	for i := 0; i < 40; i++ {
+		battery -= 0.1 + float64(i%2)*0.02
+		msg := fmt.Sprintf(`{"data":{"battery":%f}}`, battery)
+		if anomalies := detector.Observe("robot", []byte(msg), start.Add(time.Duration(i)*time.Second)); len(anomalies) != 0 {
+			t.Fatalf("Unexpected anomaly for steady drain at sample %d: %+v", i, anomalies)
+		}
+	}
+
+	// Sudden 10% drop in one second
+	msg := fmt.Sprintf(`{"data":{"battery":%f}}`, battery-10)
+	anomalies := detector.Observe("robot", []byte(msg), start.Add(40*time.Second))
+	if len(anomalies) != 1 {
+		t.Fatalf("Expected 1 anomaly for battery drop, got %d", len(anomalies))
+	}
+	if anomalies[0].ZScore >= 0 {
+		t.Errorf("Expected negative z-score for faster drain, got %f", anomalies[0].ZScore)
+	}
+}
+
+// TestHubAlertsWebClientsOnAnomaly tests that routed telemetry raises telemetry_anomaly events
+func TestHubAlertsWebClientsOnAnomaly(t *testing.T) {
+	hub := NewHub()
+	hub.SetAnomalyDetector(NewAnomalyDetector(AnomalyConfig{
+		Fields:    []string{"data.speed"},
+		Threshold: 3.0,
+		Warmup:    5,
+	}))
+	web := newTestClient(hub, ClientTypeWeb, "operator")
+	telemetry := newTestClient(hub, ClientTypeTelemetry, "robot")
+
+	for i := 0; i < 20; i++ {
+		msg := fmt.Sprintf(`{"type":"location_update","data":{"speed":%d}}`, 10+i%2)
+		hub.RouteMessage(telemetry, []byte(msg))
+	}
+	drainMessages(web)
+
+	hub.RouteMessage(telemetry, []byte(`{"type":"location_update","data":{"speed":90}}`))
+
+	var found bool
+	for _, raw := range drainMessages(web) {
+		var msg Message
+		if err := json.Unmarshal(raw, &msg); err == nil && msg.Type == "telemetry_anomaly" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected web client to receive telemetry_anomaly")
+	}
+}
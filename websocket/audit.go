@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// AuditRecorder receives connection lifecycle events. Record must not
+// block; it is called from the hub loop.
+type AuditRecorder interface {
+	Record(actor, action, target string, detail map[string]interface{})
+}
+
+// Connection lifecycle audit actions
+const (
+	AuditConnect         = "ws.connect"
+	AuditHandshake       = "ws.handshake"
+	AuditPromote         = "ws.promote"
+	AuditDisconnect      = "ws.disconnect"
+	AuditTakeover        = "ws.control_takeover"
+	AuditResume          = "ws.resume"
+	AuditAdminDisconnect = "ws.admin_disconnect"
+)
+
+// SetAuditRecorder records connection lifecycle events (nil disables)
+func (h *Hub) SetAuditRecorder(recorder AuditRecorder) {
+	h.audit = recorder
+}
+
+// auditEvent records an event about a client, keyed by its connection ID
+func (h *Hub) auditEvent(client *Client, action string, detail map[string]interface{}) {
+	if h.audit == nil {
+		return
+	}
+	h.audit.Record(client.username, action, client.connectionID, detail)
+}
+
+// auditDisconnect records why and after how long a client disconnected
+func (h *Hub) auditDisconnect(client *Client) {
+	reason := client.CloseReason()
+	if reason == "" {
+		reason = "unregistered"
+	}
+	h.auditEvent(client, AuditDisconnect, map[string]interface{}{
+		"client_type": client.Type(),
+		"room":        client.Room(),
+		"reason":      reason,
+		"duration_ms": time.Since(client.connectedAt).Milliseconds(),
+	})
+}
+
+// dropClient disconnects a client for a server-side reason
+func (h *Hub) dropClient(client *Client, reason string) {
+	client.setCloseReason(reason)
+	h.UnregisterClient(client)
+}
+
+// setCloseReason records why the connection ended. The first reason wins,
+// so a server-initiated close is not overwritten by the read error it causes.
+func (c *Client) setCloseReason(reason string) {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+
+	if c.closeReason == "" {
+		c.closeReason = reason
+	}
+}
+
+// CloseReason returns why the connection ended ("" while connected)
+func (c *Client) CloseReason() string {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+
+	return c.closeReason
+}
+
+// readCloseReason describes a read error that ended the connection
+func readCloseReason(err error) string {
+	if closeErr, ok := err.(*websocket.CloseError); ok {
+		if closeErr.Code == websocket.CloseNormalClosure || closeErr.Code == websocket.CloseGoingAway {
+			return "client_closed"
+		}
+		return "client_closed: " + closeErr.Error()
+	}
+	return "connection_lost: " + err.Error()
+}
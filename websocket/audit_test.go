@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// auditEntry is one event captured by recordingAuditor
+type auditEntry struct {
+	actor, action, target string
+	detail                map[string]interface{}
+}
+
+// recordingAuditor collects audit events on a channel
+type recordingAuditor struct {
+	events chan auditEntry
+}
+
+func (r *recordingAuditor) Record(actor, action, target string, detail map[string]interface{}) {
+	r.events <- auditEntry{actor, action, target, detail}
+}
+
+func (r *recordingAuditor) next(t *testing.T) auditEntry {
+	t.Helper()
+	select {
+	case entry := <-r.events:
+		return entry
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for audit event")
+		return auditEntry{}
+	}
+}
+
+// TestAuditConnectionLifecycle tests connect and disconnect events and
+// that the first disconnect reason is kept
+func TestAuditConnectionLifecycle(t *testing.T) {
+	hub := NewHub()
+	auditor := &recordingAuditor{events: make(chan auditEntry, 10)}
+	hub.SetAuditRecorder(auditor)
+	go hub.Run()
+
+	client := &Client{
+		hub:          hub,
+		send:         make(chan outbound, 1),
+		username:     "alice",
+		connectionID: "conn-1",
+		remoteAddr:   "10.0.0.5",
+		connectedAt:  time.Now(),
+	}
+	client.clientType.Store(ClientTypeWeb)
+
+	hub.RegisterClient(client)
+	entry := auditor.next(t)
+	if entry.action != AuditConnect || entry.actor != "alice" || entry.target != "conn-1" {
+		t.Fatalf("Unexpected connect event: %+v", entry)
+	}
+	if entry.detail["remote_addr"] != "10.0.0.5" {
+		t.Errorf("Expected remote_addr 10.0.0.5, got %v", entry.detail["remote_addr"])
+	}
+
+	hub.dropClient(client, "send_buffer_full")
+	hub.dropClient(client, "handshake_timeout")
+
+	entry = auditor.next(t)
+	if entry.action != AuditDisconnect || entry.detail["reason"] != "send_buffer_full" {
+		t.Fatalf("Expected disconnect with send_buffer_full, got %+v", entry)
+	}
+	if entry.detail["client_type"] != ClientTypeWeb {
+		t.Errorf("Expected client_type web, got %v", entry.detail["client_type"])
+	}
+
+	// The second unregister finds no client and records nothing
+	select {
+	case entry := <-auditor.events:
+		t.Errorf("Expected a single disconnect event, got %+v", entry)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
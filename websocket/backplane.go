@@ -0,0 +1,186 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"oculo-pilot-server/logging"
+	"sync/atomic"
+	"time"
+)
+
+// Backplane carries hub broadcasts between server instances, so clients of
+// one room can connect to different replicas behind a load balancer. Each
+// instance publishes what it routes and delivers what it receives to its
+// own clients.
+type Backplane interface {
+	// Publish sends payload on a channel named by the hub (see
+	// backplaneChannel)
+	Publish(channel string, payload []byte) error
+
+	// Subscribe calls handler with every payload published on the
+	// backplane, this instance's included, until Close
+	Subscribe(handler func(channel string, payload []byte)) error
+
+	Close() error
+}
+
+// Kinds of relayed messages, which decide how the receiving instance
+// delivers them
+const (
+	relayBroadcast = "broadcast" // broadcastTo
+	relayEmergency = "emergency" // broadcastEmergency
+	relayControl   = "control"   // routeControlCommand
+	relayTopic     = "topic"     // publishTopic
+)
+
+// allRoomsChannel names AllRooms in backplane channels
+const allRoomsChannel = "all-rooms"
+
+// relayEnvelope is a routed message as published on the backplane
+type relayEnvelope struct {
+	Origin  string       `json:"origin"`
+	Kind    string       `json:"kind"`
+	Room    string       `json:"room"`
+	RobotID string       `json:"robot_id,omitempty"`
+	Types   []ClientType `json:"types,omitempty"`
+
+	// Sender is the username of a control command's sender, checked
+	// against the control policy by the receiving instance
+	Sender string `json:"sender,omitempty"`
+
+	// Deadline of a command with a latency budget or a message with a TTL,
+	// in Unix milliseconds
+	Deadline int64 `json:"deadline,omitempty"`
+
+	// Topic of a topic message, and the client types that received it by
+	// type and are skipped
+	Topic string       `json:"topic,omitempty"`
+	Skip  []ClientType `json:"skip,omitempty"`
+
+	Data []byte `json:"data"`
+}
+
+// backplaneState is the hub's backplane and what went through it
+type backplaneState struct {
+	backplane  Backplane
+	instanceID string
+
+	published atomic.Int64
+	received  atomic.Int64
+	failed    atomic.Int64
+}
+
+// BackplaneStats summarizes backplane traffic
+type BackplaneStats struct {
+	Instance  string `json:"instance"`
+	Published int64  `json:"published"`
+	Received  int64  `json:"received"`
+	Failed    int64  `json:"failed"`
+}
+
+// SetBackplane shares broadcasts, emergency stops and control commands with
+// the other instances on backplane. Messages addressed to one connection
+// are not shared: checkTarget only accepts connections of this instance.
+// Call before serving.
+func (h *Hub) SetBackplane(backplane Backplane) error {
+	instanceID, err := UUIDProvider{}.NewConnectionID()
+	if err != nil {
+		return err
+	}
+	h.relay.backplane = backplane
+	h.relay.instanceID = instanceID
+	return backplane.Subscribe(h.receiveRelay)
+}
+
+// backplaneChannel names the channel of messages for one room and client
+// type ("all" when the message goes to every type)
+func backplaneChannel(room string, clientType ClientType) string {
+	name := RoomID(room)
+	if room == AllRooms {
+		name = allRoomsChannel
+	}
+	if clientType == "" {
+		return name + ".all"
+	}
+	return name + "." + string(clientType)
+}
+
+// publishRelay publishes a routed message for the other instances, once per
+// destination client type
+func (h *Hub) publishRelay(envelope relayEnvelope) {
+	if h.relay.backplane == nil {
+		return
+	}
+	envelope.Origin = h.relay.instanceID
+
+	types := envelope.Types
+	if len(types) == 0 {
+		types = []ClientType{""}
+	}
+	for _, clientType := range types {
+		if clientType != "" {
+			envelope.Types = []ClientType{clientType}
+		}
+		payload, err := json.Marshal(envelope)
+		if err != nil {
+			h.relay.failed.Add(1)
+			return
+		}
+		if err := h.relay.backplane.Publish(backplaneChannel(envelope.Room, clientType), payload); err != nil {
+			h.relay.failed.Add(1)
+			logging.Sampled("backplane_publish", "🛰️  Backplane publish failed: %v", err)
+			continue
+		}
+		h.relay.published.Add(1)
+	}
+}
+
+// receiveRelay delivers a message published by another instance to the
+// local clients it addresses
+func (h *Hub) receiveRelay(channel string, payload []byte) {
+	var envelope relayEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		h.relay.failed.Add(1)
+		logging.Sampled("backplane_invalid", "🛰️  Invalid backplane message on %s: %v", channel, err)
+		return
+	}
+	if envelope.Origin == h.relay.instanceID {
+		return
+	}
+	h.relay.received.Add(1)
+
+	target := routeTarget{robotID: envelope.RobotID}
+	message := outbound{data: envelope.Data}
+	if envelope.Deadline > 0 {
+		message.deadline = time.UnixMilli(envelope.Deadline)
+	}
+	switch envelope.Kind {
+	case relayBroadcast:
+		h.deliverOutbound(envelope.Room, target, envelope.Types, message)
+	case relayEmergency:
+		h.deliverEmergency(envelope.Room, envelope.Data)
+	case relayControl:
+		permitted, _ := h.permittedControlClients(envelope.Sender, envelope.Room, target)
+		for _, client := range permitted {
+			h.deliver(client, message)
+		}
+		h.holdCommand(envelope.Sender, envelope.Room, permitted, target, envelope.Data, message.deadline)
+	case relayTopic:
+		h.deliverTopic(envelope.Room, envelope.Topic, target, envelope.Skip, nil, message)
+	default:
+		log.Printf("🛰️  Unknown backplane message kind %q on %s", envelope.Kind, channel)
+	}
+}
+
+// backplaneStats returns backplane traffic (nil without a backplane)
+func (h *Hub) backplaneStats() *BackplaneStats {
+	if h.relay.backplane == nil {
+		return nil
+	}
+	return &BackplaneStats{
+		Instance:  h.relay.instanceID,
+		Published: h.relay.published.Load(),
+		Received:  h.relay.received.Load(),
+		Failed:    h.relay.failed.Load(),
+	}
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+)
+
+// memoryBackplane connects hubs in one process, delivering synchronously
+type memoryBackplane struct {
+	mu       sync.Mutex
+	handlers []func(channel string, payload []byte)
+	channels []string
+}
+
+func (b *memoryBackplane) Publish(channel string, payload []byte) error {
+	b.mu.Lock()
+	handlers := append([]func(string, []byte){}, b.handlers...)
+	b.channels = append(b.channels, channel)
+	b.mu.Unlock()
+	for _, handler := range handlers {
+		handler(channel, payload)
+	}
+	return nil
+}
+
+func (b *memoryBackplane) Subscribe(handler func(channel string, payload []byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *memoryBackplane) Close() error { return nil }
+
+// TestBackplane tests that broadcasts, emergency stops and control commands
+// reach clients connected to another instance, and that instance-local
+// notices do not
+func TestBackplane(t *testing.T) {
+	backplane := &memoryBackplane{}
+	hubA, hubB := NewHub(), NewHub()
+	if err := hubA.SetBackplane(backplane); err != nil {
+		t.Fatalf("SetBackplane failed: %v", err)
+	}
+	if err := hubB.SetBackplane(backplane); err != nil {
+		t.Fatalf("SetBackplane failed: %v", err)
+	}
+
+	robot := newTestClient(hubA, ClientTypeControl, "robot")
+	robot.place("lab", "")
+	viewer := newTestClient(hubB, ClientTypeWeb, "viewer")
+	viewer.place("lab", "")
+	operator := newTestClient(hubB, ClientTypeWeb, "operator")
+	operator.place("lab", "")
+	outsider := newTestClient(hubA, ClientTypeWeb, "outsider")
+	outsider.place("other", "")
+	operator.scopes = []string{ScopeControl}
+
+	hubA.RouteMessage(robot, []byte(`{"type":"location_update","data":{"lat":1}}`))
+	if types := messageTypes(viewer); len(types) != 1 || types[0] != "location_update" {
+		t.Errorf("Expected telemetry from the other instance, got %v", types)
+	}
+	if types := messageTypes(outsider); len(types) != 0 {
+		t.Errorf("Expected other rooms to receive nothing, got %v", types)
+	}
+	drainMessages(operator)
+
+	hubB.RouteMessage(operator, []byte(`{"type":"control_command","data":{"action":"forward"}}`))
+	if types := messageTypes(robot); len(types) != 1 || types[0] != "control_command" {
+		t.Errorf("Expected the command on the other instance, got %v", types)
+	}
+
+	hubB.RouteMessage(operator, []byte(`{"type":"emergency_stop"}`))
+	if types := messageTypes(robot); len(types) != 1 || types[0] != "emergency_stop" {
+		t.Errorf("Expected the emergency stop on the other instance, got %v", types)
+	}
+
+	// The control lock belongs to one instance
+	drainMessages(viewer)
+	hubA.RouteMessage(newTestClientIn(hubA, "lab", "pilot"), []byte(`{"type":"request_control"}`))
+	if types := messageTypes(viewer); len(types) != 0 {
+		t.Errorf("Expected control lock notices to stay local, got %v", types)
+	}
+
+	for _, channel := range backplane.channels {
+		if channel != "lab.web" && channel != "lab.control" && channel != "lab.all" {
+			t.Errorf("Unexpected channel %q", channel)
+		}
+	}
+	if stats := hubB.backplaneStats(); stats == nil || stats.Published == 0 || stats.Received == 0 {
+		t.Errorf("Expected backplane traffic in stats, got %+v", stats)
+	}
+}
+
+// newTestClientIn creates a web client with control scope in a room
+func newTestClientIn(hub *Hub, room, username string) *Client {
+	client := newTestClient(hub, ClientTypeWeb, username)
+	client.place(room, "")
+	client.scopes = []string{ScopeControl}
+	return client
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"oculo-pilot-server/logging"
+)
+
+// closeReasonBanned is the close reason of clients disconnected by a ban
+const closeReasonBanned = "banned"
+
+// BanChecker is implemented by validators that enforce a ban list on users
+// and source networks
+type BanChecker interface {
+	CheckBan(userID int64, remoteAddr string) error
+}
+
+// Ban is a ban that may cover connected clients (see DisconnectBanned)
+type Ban interface {
+	Covers(userID int64, remoteAddr string) bool
+}
+
+// isNotBanned checks the ban list, publishing a banned event when it covers
+// the user or the address. Certificate-authenticated devices have no user
+// and are only checked by address.
+func (h *Handler) isNotBanned(userID int64, username, remoteAddr string) bool {
+	checker, ok := h.auth.(BanChecker)
+	if !ok {
+		return true
+	}
+	if err := checker.CheckBan(userID, remoteAddr); err != nil {
+		logging.Sampled("ws_banned", "🚫 Connection for %s from %s rejected: %v", username, remoteAddr, err)
+		h.hub.PublishSecurityEvent(SecurityBanned, map[string]interface{}{
+			"username":    username,
+			"remote_addr": remoteAddr,
+		})
+		return false
+	}
+	return true
+}
+
+// DisconnectBanned disconnects every client a new ban covers, including
+// detached ones, and returns how many it disconnected. by is the admin who
+// added the ban.
+func (h *Hub) DisconnectBanned(ban Ban, by, reason string) int {
+	h.mu.RLock()
+	var banned []*Client
+	for _, clients := range h.clients {
+		for client := range clients {
+			if ban.Covers(client.userID, client.remoteAddr) {
+				banned = append(banned, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	text := "banned"
+	if reason != "" {
+		text += ": " + reason
+	}
+	for _, client := range banned {
+		h.forceDisconnect(client, closeReasonBanned, text, map[string]interface{}{
+			"by":     by,
+			"reason": reason,
+		})
+		h.UnregisterClient(client)
+	}
+	return len(banned)
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"oculo-pilot-server/logging"
+)
+
+// TokenBinding restricts a token to one client type and/or device. Empty
+// fields are unrestricted.
+type TokenBinding struct {
+	ClientType ClientType
+	DeviceID   string
+}
+
+// BoundAuthValidator is implemented by validators that also return the
+// token's scopes and binding
+type BoundAuthValidator interface {
+	ValidateTokenBinding(token string) (userID int64, username string, scopes []string, binding TokenBinding, err error)
+}
+
+// SetBinding restricts which handshake the client may complete
+func (c *Client) SetBinding(binding TokenBinding) {
+	c.binding = binding
+}
+
+// checkBinding verifies that a handshake matches the client's token
+// binding, replying with a token_binding_mismatch error when it does not
+func (h *Hub) checkBinding(client *Client, handshake *HandshakeResponse) bool {
+	binding := client.binding
+	typeOK := binding.ClientType == "" || binding.ClientType == handshake.ClientType
+	deviceOK := binding.DeviceID == "" || binding.DeviceID == handshake.DeviceID
+	if typeOK && deviceOK {
+		return true
+	}
+
+	logging.Sampled("ws_binding_mismatch", "🚫 Handshake from %s as %s (device=%q) rejected: token bound to %s (device=%q)",
+		client.username, handshake.ClientType, handshake.DeviceID, binding.ClientType, binding.DeviceID)
+	h.PublishSecurityEvent(SecurityBindingMismatch, map[string]interface{}{
+		"username":    client.username,
+		"remote_addr": client.remoteAddr,
+		"client_type": handshake.ClientType,
+		"device_id":   handshake.DeviceID,
+	})
+	client.SendJSON(map[string]interface{}{
+		"type":              "error",
+		"error":             "token_binding_mismatch",
+		"bound_client_type": binding.ClientType,
+		"bound_device_id":   binding.DeviceID,
+	})
+	return false
+}
@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"testing"
+)
+
+// TestTokenBindingHandshake tests that a bound token only completes the
+// handshake for its client type and device
+func TestTokenBindingHandshake(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	binding := TokenBinding{ClientType: ClientTypeVideo, DeviceID: "video-pi-01"}
+
+	control := newTestClient(hub, ClientTypePending, "video_pi")
+	control.SetBinding(binding)
+	control.SetConnectionID("conn_1")
+	hub.RouteMessage(control, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"control","device_id":"video-pi-01"}`))
+	if control.IsHandshakeComplete() {
+		t.Error("Expected video-bound token to be rejected as control client")
+	}
+	if types := messageTypes(control); len(types) != 1 || types[0] != "error" {
+		t.Errorf("Expected token_binding_mismatch error, got %v", types)
+	}
+
+	other := newTestClient(hub, ClientTypePending, "video_pi")
+	other.SetBinding(binding)
+	other.SetConnectionID("conn_2")
+	hub.RouteMessage(other, []byte(`{"type":"handshake_response","connection_id":"conn_2","client_type":"video","device_id":"video-pi-02"}`))
+	if other.IsHandshakeComplete() {
+		t.Error("Expected token bound to another device to be rejected")
+	}
+
+	video := newTestClient(hub, ClientTypePending, "video_pi")
+	video.SetBinding(binding)
+	video.SetConnectionID("conn_3")
+	hub.RouteMessage(video, []byte(`{"type":"handshake_response","connection_id":"conn_3","client_type":"video","device_id":"video-pi-01"}`))
+	if !video.IsHandshakeComplete() {
+		t.Error("Expected matching handshake to succeed")
+	}
+
+	unbound := newTestClient(hub, ClientTypePending, "pilot")
+	unbound.SetConnectionID("conn_4")
+	hub.RouteMessage(unbound, []byte(`{"type":"handshake_response","connection_id":"conn_4","client_type":"control"}`))
+	if !unbound.IsHandshakeComplete() {
+		t.Error("Expected unbound token to be unrestricted")
+	}
+}
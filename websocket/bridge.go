@@ -0,0 +1,186 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"oculo-pilot-server/logging"
+	"strings"
+	"sync/atomic"
+)
+
+// EdgeBus is a subject-based message bus, such as NATS, through which edge
+// services that do not speak WebSocket exchange hub messages
+type EdgeBus interface {
+	// PublishSubject sends payload on a subject
+	PublishSubject(subject string, payload []byte) error
+
+	// QueueSubscribe calls handler for messages on subject (which may end
+	// in a > wildcard); each message goes to one subscriber of the queue
+	// group
+	QueueSubscribe(subject, queue string, handler func(subject string, payload []byte)) error
+}
+
+// edgeTelemetryTypes are the message types edge services may inject
+var edgeTelemetryTypes = map[string]bool{
+	"location_update": true,
+	"route_update":    true,
+}
+
+// edgeCommandTypes are the message types published for edge services
+var edgeCommandTypes = map[string]bool{
+	"control_command":      true,
+	"emergency_stop":       true,
+	"emergency_stop_reset": true,
+}
+
+// edgeUsername stands in for the sender of bridged telemetry in logs and
+// telemetry without a robot ID
+const edgeUsername = "edge"
+
+// edgeBridge is the hub's edge bus and what went through it
+type edgeBridge struct {
+	bus    EdgeBus
+	prefix string
+
+	received  atomic.Int64
+	rejected  atomic.Int64
+	published atomic.Int64
+	denied    atomic.Int64
+	failed    atomic.Int64
+}
+
+// EdgeBridgeStats summarizes edge bridge traffic
+type EdgeBridgeStats struct {
+	Received  int64 `json:"received"`
+	Rejected  int64 `json:"rejected"`
+	Published int64 `json:"published"`
+	Denied    int64 `json:"denied"`
+	Failed    int64 `json:"failed"`
+}
+
+// edgeCommand is what edge services receive for a routed command: the
+// message as the sender wrote it and who sent it, so edge robots can
+// apply their own checks
+type edgeCommand struct {
+	Room     string          `json:"room"`
+	RobotID  string          `json:"robot_id,omitempty"`
+	Sender   string          `json:"sender"` // Connection ID
+	Username string          `json:"username"`
+	Message  json.RawMessage `json:"message"`
+}
+
+// SetEdgeBridge connects the hub to edge services on bus. Telemetry
+// published on <prefix>.telemetry.<room>.<type> is routed as if a telemetry
+// client in the room had sent it, and commands for a room's robots are
+// published on <prefix>.commands.<room>.<type>. Rooms are named as in
+// RoomID. Replicas share a queue group, so each message is injected once
+// and reaches the other instances through the backplane. Call before
+// serving.
+func (h *Hub) SetEdgeBridge(bus EdgeBus, prefix string) error {
+	h.edge.bus = bus
+	h.edge.prefix = prefix
+	return bus.QueueSubscribe(prefix+".telemetry.>", prefix+".hub", h.receiveEdge)
+}
+
+// receiveEdge routes telemetry published by an edge service. The payload is
+// the message a telemetry client would send, and its type must match the
+// subject.
+func (h *Hub) receiveEdge(subject string, payload []byte) {
+	rest := strings.TrimPrefix(subject, h.edge.prefix+".telemetry.")
+	roomID, msgType, ok := strings.Cut(rest, ".")
+	room := roomFromID(roomID)
+	if !ok || !edgeTelemetryTypes[msgType] || !validRoom(room, ClientTypeTelemetry) {
+		h.rejectEdge(subject, "unsupported subject")
+		return
+	}
+
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		h.rejectEdge(subject, err.Error())
+		return
+	}
+	if msg.Type != msgType {
+		h.rejectEdge(subject, "message type does not match the subject")
+		return
+	}
+	if !validRobotID(msg.RobotID) {
+		h.rejectEdge(subject, "invalid robot_id")
+		return
+	}
+	if schema, ok := h.schemas[msgType]; ok {
+		if err := schema.Validate(payload); err != nil {
+			h.rejectEdge(subject, err.Error())
+			return
+		}
+	}
+	h.edge.received.Add(1)
+	h.throughput.countRouted(msgType)
+	h.countRoomTraffic(room, len(payload))
+
+	sender := &Client{username: edgeUsername}
+	sender.place(room, msg.RobotID)
+	target := routeTarget{robotID: msg.RobotID}
+	message := h.withTTL(msgType, payload)
+	delivered := h.broadcastOutbound(room, target, []ClientType{ClientTypeWeb}, message)
+	h.publishTopic(sender, messageTopics[msgType], target, []ClientType{ClientTypeWeb}, message)
+	log.Printf("Forwarded bridged %s to %d web clients", msgType, delivered)
+	h.persistTelemetry(sender, &msg, target)
+	h.cacheLastState(sender, msgType, payload, target)
+}
+
+// rejectEdge counts and logs a bridged message that was not routed
+func (h *Hub) rejectEdge(subject, reason string) {
+	h.edge.rejected.Add(1)
+	logging.Sampled("edge_rejected", "🛰️  Rejected bridged message on %s: %s", subject, reason)
+}
+
+// publishEdge publishes a command routed in the sender's room for edge
+// services. Control commands are subject to the control policy like those
+// to WebSocket robots: edge robots are named by robot_id, so with a policy
+// only commands addressed to a robot the sender may command are published.
+// Emergency stops reach every edge robot, as they do every control client.
+func (h *Hub) publishEdge(sender *Client, msgType string, rawMessage []byte, target routeTarget) {
+	if h.edge.bus == nil || !edgeCommandTypes[msgType] {
+		return
+	}
+	if msgType == "control_command" && h.controlPolicy != nil &&
+		(target.robotID == "" || !h.controlPolicy.CanControl(sender.username, target.robotID)) {
+		h.edge.denied.Add(1)
+		logging.Sampled("edge_denied", "🚫 %s from %s not published to edge robots: robot %q not permitted",
+			msgType, sender.username, target.robotID)
+		return
+	}
+
+	payload, err := json.Marshal(edgeCommand{
+		Room:     RoomID(sender.Room()),
+		RobotID:  target.robotID,
+		Sender:   sender.GetConnectionID(),
+		Username: sender.username,
+		Message:  rawMessage,
+	})
+	if err != nil {
+		h.edge.failed.Add(1)
+		return
+	}
+	subject := h.edge.prefix + ".commands." + RoomID(sender.Room()) + "." + msgType
+	if err := h.edge.bus.PublishSubject(subject, payload); err != nil {
+		h.edge.failed.Add(1)
+		logging.Sampled("edge_publish", "🛰️  Edge bridge publish failed: %v", err)
+		return
+	}
+	h.edge.published.Add(1)
+}
+
+// edgeBridgeStats returns edge bridge traffic (nil without a bridge)
+func (h *Hub) edgeBridgeStats() *EdgeBridgeStats {
+	if h.edge.bus == nil {
+		return nil
+	}
+	return &EdgeBridgeStats{
+		Received:  h.edge.received.Load(),
+		Rejected:  h.edge.rejected.Load(),
+		Published: h.edge.published.Load(),
+		Denied:    h.edge.denied.Load(),
+		Failed:    h.edge.failed.Load(),
+	}
+}
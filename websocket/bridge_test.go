@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryEdgeBus records published subjects and hands out its subscription
+type memoryEdgeBus struct {
+	mu        sync.Mutex
+	published []string
+	subject   string
+	queue     string
+	handler   func(subject string, payload []byte)
+}
+
+func (b *memoryEdgeBus) PublishSubject(subject string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, subject+"="+string(payload))
+	return nil
+}
+
+func (b *memoryEdgeBus) QueueSubscribe(subject, queue string, handler func(subject string, payload []byte)) error {
+	b.subject, b.queue, b.handler = subject, queue, handler
+	return nil
+}
+
+// TestEdgeBridge tests that telemetry injected on a subject reaches web
+// clients in its room and that commands are published for edge services
+func TestEdgeBridge(t *testing.T) {
+	hub := NewHub()
+	bus := &memoryEdgeBus{}
+	if err := hub.SetEdgeBridge(bus, "pilot"); err != nil {
+		t.Fatalf("SetEdgeBridge failed: %v", err)
+	}
+	if bus.subject != "pilot.telemetry.>" || bus.queue == "" {
+		t.Errorf("Expected a queue subscription to telemetry, got %q (%q)", bus.subject, bus.queue)
+	}
+
+	viewer := newTestClientIn(hub, "lab", "viewer")
+	outsider := newTestClientIn(hub, "other", "outsider")
+	lobby := newTestClient(hub, ClientTypeWeb, "lobby")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.place("lab", "")
+
+	bus.handler("pilot.telemetry.lab.location_update", []byte(`{"type":"location_update","robot_id":"r1","data":{"lat":1}}`))
+	if types := messageTypes(viewer); len(types) != 1 || types[0] != "location_update" {
+		t.Errorf("Expected bridged telemetry, got %v", types)
+	}
+	if types := messageTypes(outsider); len(types) != 0 {
+		t.Errorf("Expected other rooms to receive nothing, got %v", types)
+	}
+	if messages := hub.lastStateMessages("lab", "r1", time.Now()); len(messages) != 1 {
+		t.Errorf("Expected bridged telemetry in the snapshot cache, got %d", len(messages))
+	}
+
+	bus.handler("pilot.telemetry.default.route_update", []byte(`{"type":"route_update","data":{}}`))
+	if types := messageTypes(lobby); len(types) != 1 || types[0] != "route_update" {
+		t.Errorf("Expected default room telemetry, got %v", types)
+	}
+
+	for _, rejected := range []struct{ subject, payload string }{
+		{"pilot.telemetry.lab.control_command", `{"type":"control_command"}`},
+		{"pilot.telemetry.lab.location_update", `{"type":"route_update"}`},
+		{"pilot.telemetry.lab.location_update", `not json`},
+		{"pilot.telemetry.lab", `{"type":"location_update"}`},
+		{"pilot.telemetry.replay.location_update", `{"type":"location_update"}`},
+	} {
+		bus.handler(rejected.subject, []byte(rejected.payload))
+	}
+	if types := messageTypes(viewer); len(types) != 0 {
+		t.Errorf("Expected invalid bridged messages to be dropped, got %v", types)
+	}
+
+	operator := newTestClientIn(hub, "lab", "operator")
+	operator.SetConnectionID("conn_operator")
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","data":{"action":"forward"}}`))
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop"}`))
+	hub.RouteMessage(robot, []byte(`{"type":"control_response","data":{}}`))
+
+	var subjects []string
+	for _, published := range bus.published {
+		subject, _, _ := strings.Cut(published, "=")
+		subjects = append(subjects, subject)
+	}
+	if len(subjects) != 2 || subjects[0] != "pilot.commands.lab.control_command" || subjects[1] != "pilot.commands.lab.emergency_stop" {
+		t.Fatalf("Expected the command and emergency stop to be published, got %v", subjects)
+	}
+	var command edgeCommand
+	_, payload, _ := strings.Cut(bus.published[0], "=")
+	if err := json.Unmarshal([]byte(payload), &command); err != nil ||
+		command.Username != "operator" || command.Sender != "conn_operator" || command.Room != "lab" ||
+		!strings.Contains(string(command.Message), `"action":"forward"`) {
+		t.Errorf("Expected the command with its sender, got %s (%v)", payload, err)
+	}
+
+	stats := hub.edgeBridgeStats()
+	if stats == nil || stats.Received != 2 || stats.Rejected != 5 || stats.Published != 2 {
+		t.Errorf("Unexpected edge bridge stats %+v", stats)
+	}
+}
+
+// TestEdgeBridgeControlPolicy tests that edge robots only get commands the
+// control policy permits, while emergency stops reach them all
+func TestEdgeBridgeControlPolicy(t *testing.T) {
+	hub := NewHub()
+	hub.SetControlPolicy(mockControlPolicy{"alice": "edge-1"})
+	bus := &memoryEdgeBus{}
+	hub.SetEdgeBridge(bus, "pilot")
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+
+	hub.RouteMessage(alice, []byte(`{"type":"control_command","robot_id":"edge-1"}`))
+	hub.RouteMessage(bob, []byte(`{"type":"control_command","robot_id":"edge-1"}`))
+	hub.RouteMessage(alice, []byte(`{"type":"control_command"}`))
+	hub.RouteMessage(bob, []byte(`{"type":"emergency_stop"}`))
+
+	if len(bus.published) != 2 || !strings.Contains(bus.published[0], `"username":"alice"`) ||
+		!strings.HasPrefix(bus.published[1], "pilot.commands.default.emergency_stop=") {
+		t.Errorf("Expected alice's command and the emergency stop only, got %v", bus.published)
+	}
+	if stats := hub.edgeBridgeStats(); stats.Denied != 2 {
+		t.Errorf("Expected 2 denied commands, got %+v", stats)
+	}
+}
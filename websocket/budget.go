@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"encoding/json"
+	"oculo-pilot-server/logging"
+	"time"
+)
+
+// commandBudget is the optional latency budget of a control_command
+type commandBudget struct {
+	// MaxAgeMs bounds the time from the hub receiving the command to
+	// writing it to the robot; later commands are dropped
+	MaxAgeMs int64 `json:"max_age_ms"`
+
+	// ID is echoed back when the command is dropped
+	ID interface{} `json:"id,omitempty"`
+}
+
+// withBudget queues a command with its latency budget, if it has one. A
+// command still queued when the budget runs out is dropped rather than
+// delivering stale input, and the sender is told.
+func (h *Hub) withBudget(sender *Client, msgType string, rawMessage []byte) outbound {
+	message := outbound{data: rawMessage}
+
+	var budget commandBudget
+	if err := json.Unmarshal(rawMessage, &budget); err != nil || budget.MaxAgeMs <= 0 {
+		return message
+	}
+
+	maxAge := time.Duration(budget.MaxAgeMs) * time.Millisecond
+	message.deadline = time.Now().Add(maxAge)
+	message.expired = func(late time.Duration) {
+		h.budgetExceeded.Add(1)
+		logging.Sampled("ws_budget_exceeded", "⏱️  %s from %s dropped: %v over its %v latency budget",
+			msgType, sender.username, late.Round(time.Millisecond), maxAge)
+		sender.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "latency_budget_exceeded",
+			"message_type": msgType,
+			"id":           budget.ID,
+			"max_age_ms":   budget.MaxAgeMs,
+			"age_ms":       (maxAge + late).Milliseconds(),
+		})
+	}
+	return message
+}
+
+// BudgetViolations returns how many commands were dropped for exceeding
+// their latency budget
+func (h *Hub) BudgetViolations() int64 {
+	return h.budgetExceeded.Load()
+}
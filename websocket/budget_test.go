@@ -0,0 +1,40 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLatencyBudget tests dropping commands that exceed their budget
+func TestLatencyBudget(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "operator")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"cmd-1","max_age_ms":50}`))
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"cmd-2"}`))
+
+	fresh := <-robot.send
+	unbudgeted := <-robot.send
+	if fresh.expire(time.Now()) {
+		t.Error("Expected command within its budget to be delivered")
+	}
+
+	later := time.Now().Add(time.Second)
+	if unbudgeted.expire(later) {
+		t.Error("Expected command without a budget never to expire")
+	}
+	if !fresh.expire(later) {
+		t.Fatal("Expected command past its budget to be dropped")
+	}
+
+	replies := drainMessages(operator)
+	if len(replies) != 1 || !strings.Contains(string(replies[0]), `"error":"latency_budget_exceeded"`) ||
+		!strings.Contains(string(replies[0]), `"id":"cmd-1"`) {
+		t.Errorf("Expected latency_budget_exceeded for cmd-1, got %q", replies)
+	}
+	if hub.BudgetViolations() != 1 || hub.GetStats()["latency_budget_exceeded"] != int64(1) {
+		t.Errorf("Expected 1 budget violation, got %d", hub.BudgetViolations())
+	}
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"crypto/tls"
+	"strings"
+)
+
+// devicePrefix marks usernames of certificate-authenticated devices so they
+// cannot be confused with database users
+const devicePrefix = "device:"
+
+// EnableCertAuth lets connections presenting a verified client certificate
+// skip the JWT requirement. The TLS listener decides which CAs are trusted.
+func (h *Handler) EnableCertAuth() {
+	h.certAuth = true
+}
+
+// DeviceIdentity returns the device name from a verified client certificate:
+// the subject CN, or the first DNS or URI SAN when the CN is empty
+func DeviceIdentity(state *tls.ConnectionState) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	leaf := state.VerifiedChains[0][0]
+	if name := strings.TrimSpace(leaf.Subject.CommonName); name != "" {
+		return name, true
+	}
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames[0], true
+	}
+	if len(leaf.URIs) > 0 {
+		return leaf.URIs[0].String(), true
+	}
+
+	return "", false
+}
+
+// deviceAuth authenticates a request by its client certificate. Devices act
+// as robots, so they are granted the control scope.
+func (h *Handler) deviceAuth(state *tls.ConnectionState) (username string, scopes []string, ok bool) {
+	if !h.certAuth {
+		return "", nil, false
+	}
+
+	device, ok := DeviceIdentity(state)
+	if !ok {
+		return "", nil, false
+	}
+	return devicePrefix + device, []string{ScopeControl}, true
+}
@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// verifiedState returns a connection state with a verified leaf certificate
+func verifiedState(leaf *x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+}
+
+// TestDeviceIdentity tests mapping client certificates to device names
+func TestDeviceIdentity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://fleet/robot-7")
+
+	tests := []struct {
+		name   string
+		state  *tls.ConnectionState
+		expect string
+		ok     bool
+	}{
+		{"No TLS", nil, "", false},
+		{"No verified chain", &tls.ConnectionState{}, "", false},
+		{"Common name", verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "pi-01"}, DNSNames: []string{"pi-01.fleet"}}), "pi-01", true},
+		{"DNS SAN", verifiedState(&x509.Certificate{DNSNames: []string{"pi-02.fleet"}}), "pi-02.fleet", true},
+		{"URI SAN", verifiedState(&x509.Certificate{URIs: []*url.URL{spiffe}}), "spiffe://fleet/robot-7", true},
+		{"No identity", verifiedState(&x509.Certificate{}), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, ok := DeviceIdentity(tt.state)
+			if identity != tt.expect || ok != tt.ok {
+				t.Errorf("DeviceIdentity() = (%q, %v), want (%q, %v)", identity, ok, tt.expect, tt.ok)
+			}
+		})
+	}
+}
+
+// TestServeHTTPClientCert tests that a verified certificate replaces the JWT
+// only when certificate auth is enabled
+func TestServeHTTPClientCert(t *testing.T) {
+	hub := NewHub()
+	state := verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "pi-01"}})
+
+	handler := NewHandler(hub, &mockAuthValidator{}, nil, false, 10*time.Second, 65536)
+
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.TLS = state
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without cert auth, got %d", w.Code)
+	}
+
+	handler.EnableCertAuth()
+
+	req = httptest.NewRequest("GET", "/ws", nil)
+	req.TLS = state
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	// Authentication passes; the recorder cannot be upgraded
+	if w.Code == http.StatusUnauthorized {
+		t.Error("Expected verified certificate to authenticate")
+	}
+
+	username, scopes, ok := handler.deviceAuth(state)
+	if !ok || username != "device:pi-01" || !hasScope(scopes, ScopeControl) {
+		t.Errorf("Unexpected device auth: %q %v %v", username, scopes, ok)
+	}
+}
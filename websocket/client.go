@@ -2,8 +2,9 @@ package websocket
 
 import (
 	"encoding/json"
-	"log"
+	"oculo-pilot-server/logging"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -20,6 +21,27 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 )
 
+// outbound is a message queued for a client. A message with a deadline is
+// dropped by writePump once the deadline passes, and expired is called with
+// how late it was.
+type outbound struct {
+	data     []byte
+	deadline time.Time
+	expired  func(late time.Duration)
+	seq      uint64 // Sequence number, 0 when unnumbered (see sequence.go)
+}
+
+// expire reports whether the message missed its deadline, calling expired
+func (m outbound) expire(now time.Time) bool {
+	if m.deadline.IsZero() || !now.After(m.deadline) {
+		return false
+	}
+	if m.expired != nil {
+		m.expired(now.Sub(m.deadline))
+	}
+	return true
+}
+
 // ClientType represents the type of WebSocket client
 type ClientType string
 
@@ -28,9 +50,18 @@ const (
 	ClientTypeVideo     ClientType = "video"     // Video streaming client (Raspberry Pi)
 	ClientTypeControl   ClientType = "control"   // Control client (Raspberry Pi)
 	ClientTypeTelemetry ClientType = "telemetry" // Telemetry client (GPS/sensors)
+	ClientTypeAudio     ClientType = "audio"     // Audio intercom client (Raspberry Pi speaker/mic)
+	ClientTypeMonitor   ClientType = "monitor"   // Admin protocol inspector (read-only copy of routed messages)
 	ClientTypePending   ClientType = "pending"   // Not yet identified
 )
 
+// ClientTypes returns the types a client can identify as
+func ClientTypes() []ClientType {
+	return []ClientType{
+		ClientTypeWeb, ClientTypeVideo, ClientTypeControl, ClientTypeTelemetry, ClientTypeAudio, ClientTypeMonitor,
+	}
+}
+
 // Client represents a WebSocket client connection
 type Client struct {
 	// Hub that manages this client
@@ -39,11 +70,19 @@ type Client struct {
 	// WebSocket connection
 	conn *websocket.Conn
 
-	// Buffered channel of outbound messages
-	send chan []byte
+	// Buffered channel of outbound messages, and whether the hub closed it
+	// (protected by sendMu; senders hold the read lock so the channel is
+	// never closed under them)
+	send       chan outbound
+	sendClosed bool
+	sendMu     sync.RWMutex
+
+	// Emergency stops, written before anything in send (see priority.go)
+	priority chan outbound
 
-	// Client type (web, video, control, telemetry)
-	clientType ClientType
+	// Client type (web, video, control, telemetry, audio), a ClientType
+	// only changed by the hub loop (see transition.go)
+	clientType atomic.Value
 
 	// User ID (if authenticated)
 	userID int64
@@ -51,126 +90,382 @@ type Client struct {
 	// Username (if authenticated)
 	username string
 
+	// Token scopes (nil = unrestricted)
+	scopes []string
+
+	// Client type and device the token is bound to (empty = unrestricted)
+	binding TokenBinding
+
 	// Connection ID for handshake validation
 	connectionID string
 
+	// Room scoping server notifications (DefaultRoom until assigned) and
+	// the robot the client registered for at handshake ("" for none, see
+	// servesRobot); only changed by the hub loop (see transition.go)
+	placement atomic.Pointer[clientPlacement]
+
+	// Filter of a monitor connection (nil receives everything)
+	monitor *MonitorFilter
+
+	// Topic patterns the client subscribed to (nil for none, see topic.go)
+	topics atomic.Pointer[[]string]
+
+	// Device and software reported at handshake (nil before, see
+	// metadata.go)
+	metadata atomic.Pointer[ClientMetadata]
+
+	// Client IP (see middleware.ClientIP) and connection time
+	remoteAddr  string
+	connectedAt time.Time
+
+	// When the client last sent a message or pong, in Unix nanoseconds
+	// (see liveness.go)
+	lastActivity atomic.Int64
+
+	// When the connection dropped while the client waits to be resumed, in
+	// Unix nanoseconds (0 while connected, see resume.go)
+	detached atomic.Int64
+
+	// Messages taken from the queues that could not be written, handed to
+	// the client that resumes this one (protected by unsentMu)
+	unsent   []outbound
+	unsentMu sync.Mutex
+
 	// Maximum message size allowed from peer
 	maxMessageSize int64
 
-	// Handshake completion flag (protected by handshakeMu)
+	// Message rate limit state (see ratelimit.go)
+	rate rateBucket
+
+	// Egress byte budget, only used by writePump (see egress.go)
+	egress egressBucket
+
+	// Last sequence number queued (protected by seqMu), and the numbers
+	// writePump accounted for (see sequence.go)
+	seq      uint64
+	seqMu    sync.Mutex
+	sequence sequenceState
+
+	// Frame encoding chosen at handshake (string, unset for JSON; see
+	// msgpack.go and protobuf.go)
+	encoding atomic.Value
+
+	// Whether the client offered permessage-deflate, and whether frames to
+	// it are compressed for its type (see compression.go)
+	deflate  bool
+	compress atomic.Bool
+
+	// Protocol version and features agreed at handshake (nil for version 1
+	// clients, see features.go)
+	negotiated atomic.Pointer[negotiation]
+
+	// Handshake completion flag (protected by handshakeMu), and a channel
+	// closed when it is set
 	handshakeComplete bool
+	handshakeDone     chan struct{}
 	handshakeMu       sync.RWMutex
+
+	// Closed when the read pump exits
+	readDone chan struct{}
+
+	// Why the connection ended, for the audit log (protected by closeMu)
+	closeReason string
+	closeMu     sync.Mutex
 }
 
 // NewClient creates a new WebSocket client
 func NewClient(hub *Hub, conn *websocket.Conn, clientType ClientType, userID int64, username string, maxMessageSize int64) *Client {
-	return &Client{
+	client := &Client{
 		hub:            hub,
 		conn:           conn,
-		send:           make(chan []byte, 256),
-		clientType:     clientType,
+		send:           make(chan outbound, hub.sendQueueSize()),
+		priority:       make(chan outbound, priorityQueueSize),
 		userID:         userID,
 		username:       username,
 		maxMessageSize: maxMessageSize,
+		connectedAt:    time.Now(),
+		handshakeDone:  make(chan struct{}),
+		readDone:       make(chan struct{}),
 	}
+	client.clientType.Store(clientType)
+	client.touch(client.connectedAt)
+	return client
 }
 
-// readPump pumps messages from the WebSocket connection to the hub
-func (c *Client) readPump() {
+// readPump pumps messages from the WebSocket connection to the hub. It
+// calls started once it is ready to read.
+func (c *Client) readPump(started func()) {
 	defer func() {
-		c.hub.unregister <- c
+		c.hub.UnregisterClient(c)
 		c.conn.Close()
+		close(c.readDone)
 	}()
 
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetReadLimit(c.maxMessageSize)
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.touch(time.Now())
 		return nil
 	})
+	started()
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		messageType, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				logging.Sampled("ws_read_error", "WebSocket error: %v", err)
 			}
+			c.setCloseReason(readCloseReason(err))
 			break
 		}
+		c.touch(time.Now())
+		c.hub.throughput.countIn(len(message))
+
+		// Binary frames are protobuf envelopes from clients that chose
+		// protobuf, and MessagePack from everyone else
+		if messageType == websocket.BinaryMessage {
+			decode := msgpackToJSON
+			if c.frameEncoding() == EncodingProtobuf {
+				decode = protobufToJSON
+			}
+			if message, err = decode(message); err != nil {
+				logging.Sampled("ws_invalid_message", "Invalid binary frame from %s: %v", c.Type(), err)
+				c.hub.throughput.invalid.Add(1)
+				continue
+			}
+		}
 
 		// Route message through hub
 		c.hub.RouteMessage(c, message)
 	}
 }
 
-// writePump pumps messages from the hub to the WebSocket connection
-func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+// writePump pumps messages from the hub to the WebSocket connection. It
+// calls started once it is ready to write.
+func (c *Client) writePump(started func()) {
+	ticker := time.NewTicker(c.hub.pingInterval())
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
 	}()
+	started()
 
 	for {
+		// Compression follows the client type, which changes at handshake
+		c.conn.EnableWriteCompression(c.compress.Load())
+
+		// Emergency stops go out before anything already queued
+		select {
+		case message := <-c.priority:
+			if err := c.writePriority(message); err != nil {
+				c.keepUnsent(message)
+				return
+			}
+			continue
+		default:
+		}
+
 		select {
+		case message := <-c.priority:
+			if err := c.writePriority(message); err != nil {
+				c.keepUnsent(message)
+				return
+			}
+
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// Hub closed the channel
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 
+			// Wait out the egress limit before the write deadline starts
+			if !c.throttleEgress(ticker.C) {
+				c.keepUnsent(message)
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if message.expire(time.Now()) {
+				c.skip(message)
+				continue
+			}
+			gap, data := c.stamp(message)
+			c.egress.spend(len(data))
+			if encoding := c.frameEncoding(); encoding != EncodingJSON {
+				if gap != nil {
+					if err := c.writeBinary(encoding, gap); err != nil {
+						c.keepUnsent(message)
+						return
+					}
+				}
+				if err := c.writeBinary(encoding, data); err != nil {
+					c.keepUnsent(message)
+					return
+				}
+				continue
+			}
+
 			w, err := c.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
+				c.keepUnsent(message)
 				return
 			}
-			w.Write(message)
+			if gap != nil {
+				w.Write(gap)
+				w.Write([]byte{'\n'})
+			}
+			w.Write(data)
+			c.hub.throughput.countOut(len(data))
+			batch := []outbound{message}
 
-			// Add queued messages to the current WebSocket message
+			// Add queued messages to the current WebSocket message, unless
+			// an emergency stop is waiting or the egress limit is spent
 			n := len(c.send)
-			for i := 0; i < n; i++ {
+			for i := 0; i < n && len(c.priority) == 0 && c.egressAvailable(); i++ {
+				queued := <-c.send
+				if queued.expire(time.Now()) {
+					c.skip(queued)
+					continue
+				}
+				gap, data := c.stamp(queued)
+				c.egress.spend(len(data))
+				if gap != nil {
+					w.Write([]byte{'\n'})
+					w.Write(gap)
+				}
 				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+				w.Write(data)
+				c.hub.throughput.countOut(len(data))
+				batch = append(batch, queued)
 			}
 
 			if err := w.Close(); err != nil {
+				c.keepUnsent(batch...)
 				return
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.writePing(); err != nil {
 				return
 			}
 		}
 	}
 }
 
-// SendJSON sends a JSON message to the client
+// writePing pings the client, whose pong extends the read deadline
+func (c *Client) writePing() error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+// frameEncoding returns the encoding the client chose at handshake
+func (c *Client) frameEncoding() string {
+	if encoding, ok := c.encoding.Load().(string); ok {
+		return encoding
+	}
+	return EncodingJSON
+}
+
+// writeBinary writes a message as a binary frame in the client's encoding.
+// Binary frames cannot be joined with newlines, so each message gets its
+// own.
+func (c *Client) writeBinary(encoding string, data []byte) error {
+	encode := jsonToMsgpack
+	if encoding == EncodingProtobuf {
+		encode = jsonToProtobuf
+	}
+	frame, err := encode(data)
+	if err != nil {
+		logging.Sampled("ws_binary_encode", "Failed to encode %s frame for %s: %v", encoding, c.username, err)
+		return nil
+	}
+	c.hub.throughput.countOut(len(frame))
+	return c.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// SendJSON sends a JSON message to the client. It only queues the message,
+// so it is safe to call before Run; the write pump sends it once running.
 func (c *Client) SendJSON(v interface{}) error {
 	data, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
 
+	if !c.enqueue(outbound{data: data}) {
+		return websocket.ErrCloseSent
+	}
+	return nil
+}
+
+// enqueue queues a message without blocking and reports whether there was
+// room in the send buffer. Nothing is queued once the hub closed it.
+func (c *Client) enqueue(message outbound) bool {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+
+	if c.sendClosed {
+		return false
+	}
+
+	// Numbers follow queue order, so concurrent senders take turns
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+	message = c.nextSeq(message)
 	select {
-	case c.send <- data:
-		return nil
+	case c.send <- message:
+		c.commitSeq(message)
+		return true
 	default:
-		return websocket.ErrCloseSent
+		return false
 	}
 }
 
-// Run starts the client's read and write pumps
+// closeSend closes the send channel so the write pump ends the connection.
+// Messages delivered afterwards, e.g. by a broadcast that picked the
+// client before it was removed, are dropped instead of panicking.
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.send)
+	}
+}
+
+// sendDone reports whether the hub closed the send channel
+func (c *Client) sendDone() bool {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+	return c.sendClosed
+}
+
+// Run starts the client's read and write pumps and returns once both are
+// running
 func (c *Client) Run() {
-	go c.writePump()
-	go c.readPump()
+	var started sync.WaitGroup
+	started.Add(2)
+	go c.writePump(started.Done)
+	go c.readPump(started.Done)
+	started.Wait()
 }
 
-// SetConnectionID sets the connection ID for handshake validation
+// SetConnectionID sets the connection ID for handshake validation and
+// directed messages
 func (c *Client) SetConnectionID(id string) {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	registered := c.hub.clients[c.Type()][c]
+	if registered {
+		c.hub.unindexConnection(c)
+	}
 	c.connectionID = id
+	if registered {
+		c.hub.indexConnection(c)
+	}
 }
 
 // GetConnectionID returns the connection ID
@@ -182,6 +477,9 @@ func (c *Client) GetConnectionID() string {
 func (c *Client) MarkHandshakeComplete() {
 	c.handshakeMu.Lock()
 	defer c.handshakeMu.Unlock()
+	if !c.handshakeComplete && c.handshakeDone != nil {
+		close(c.handshakeDone)
+	}
 	c.handshakeComplete = true
 }
 
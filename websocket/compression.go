@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"compress/flate"
+	"net/http"
+	"strings"
+)
+
+// CompressionConfig selects the client types whose frames are compressed
+// with permessage-deflate when the client offers it. Compression trades
+// CPU for bandwidth: worth it for verbose JSON telemetry, not for a
+// Raspberry Pi streaming video.
+type CompressionConfig struct {
+	Types map[ClientType]bool // Types that receive compressed frames
+	Level int                 // flate level 1-9 (0 uses 1, the fastest)
+}
+
+// SetCompression negotiates permessage-deflate and compresses frames sent
+// to the configured client types. Call before Run.
+func (h *Hub) SetCompression(config CompressionConfig) {
+	h.compression = config
+}
+
+// compressionEnabled reports whether any client type is compressed
+func (h *Hub) compressionEnabled() bool {
+	for _, enabled := range h.compression.Types {
+		if enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// compressionLevel returns the configured flate level
+func (h *Hub) compressionLevel() int {
+	if h.compression.Level < flate.BestSpeed || h.compression.Level > flate.BestCompression {
+		return flate.BestSpeed
+	}
+	return h.compression.Level
+}
+
+// compresses reports whether frames to a client type are compressed
+func (h *Hub) compresses(clientType ClientType) bool {
+	return h.compression.Types[clientType]
+}
+
+// offersDeflate reports whether an upgrade request offers
+// permessage-deflate
+func offersDeflate(r *http.Request) bool {
+	for _, extension := range r.Header.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(extension, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
+// updateCompression turns compression of the client's frames on or off
+// for its (new) type and negotiated features; it takes effect with the
+// next frame
+func (c *Client) updateCompression() {
+	c.compress.Store(c.deflate && c.hub.compresses(c.Type()) && c.HasFeature(FeatureCompression))
+}
+
+// Compressed reports whether frames sent to the client are compressed
+func (c *Client) Compressed() bool {
+	return c.compress.Load()
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialCompressed connects offering permessage-deflate, completes the
+// handshake as clientType and returns the negotiated extensions
+func dialCompressed(t *testing.T, handler *Handler, clientType string) (*websocket.Conn, string) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?token=good", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var request map[string]interface{}
+	if err := conn.ReadJSON(&request); err != nil {
+		t.Fatalf("Expected a handshake_request: %v", err)
+	}
+	conn.WriteJSON(map[string]interface{}{
+		"type":          "handshake_response",
+		"connection_id": request["connection_id"],
+		"client_type":   clientType,
+	})
+	var confirmation map[string]interface{}
+	if err := conn.ReadJSON(&confirmation); err != nil || confirmation["type"] != "connection_established" {
+		t.Fatalf("Expected connection_established, got %v (%v)", confirmation, err)
+	}
+	return conn, resp.Header.Get("Sec-WebSocket-Extensions")
+}
+
+// TestCompressionPerClientType tests that permessage-deflate is negotiated
+// and frames are compressed only for the configured client types
+func TestCompressionPerClientType(t *testing.T) {
+	hub := NewHub()
+	hub.SetCompression(CompressionConfig{Types: map[ClientType]bool{ClientTypeWeb: true}, Level: 1})
+	go hub.Run()
+	handler := NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096)
+
+	conn, extensions := dialCompressed(t, handler, "web")
+	if !strings.Contains(extensions, "permessage-deflate") {
+		t.Fatalf("Expected permessage-deflate to be negotiated, got %q", extensions)
+	}
+
+	// Compressed frames decode transparently
+	hub.BroadcastToRoom(DefaultRoom, []ClientType{ClientTypeWeb}, []byte(`{"type":"location_update","data":{"lat":37.5}}`))
+	for {
+		var message map[string]interface{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Expected the broadcast: %v", err)
+		}
+		if message["type"] == "location_update" {
+			break
+		}
+	}
+
+	connections := hub.Connections()
+	if len(connections) != 1 || !connections[0].Compressed {
+		t.Errorf("Expected the web client to be compressed, got %+v", connections)
+	}
+
+	// A type left out of the config is negotiated but sent plain
+	video := &Client{hub: hub, deflate: true}
+	video.clientType.Store(ClientTypeVideo)
+	video.updateCompression()
+	if video.Compressed() {
+		t.Error("Expected video clients to be sent uncompressed frames")
+	}
+}
+
+// TestCompressionDisabled tests that nothing is negotiated without
+// compressed client types
+func TestCompressionDisabled(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	handler := NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096)
+
+	if _, extensions := dialCompressed(t, handler, "web"); extensions != "" {
+		t.Errorf("Expected no extensions, got %q", extensions)
+	}
+	if connections := hub.Connections(); len(connections) != 1 || connections[0].Compressed {
+		t.Errorf("Expected an uncompressed client, got %+v", connections)
+	}
+}
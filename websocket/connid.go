@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// IDProvider generates connection IDs
+type IDProvider interface {
+	NewConnectionID() (string, error)
+}
+
+// UUIDProvider generates connection IDs as random (version 4) UUIDs. Unlike
+// the peer address they do not collide behind NAT and do not reveal
+// internal addressing to other clients.
+type UUIDProvider struct{}
+
+// NewConnectionID returns a new random UUID
+func (UUIDProvider) NewConnectionID() (string, error) {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return "", fmt.Errorf("failed to generate connection ID: %w", err)
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40 // Version 4
+	uuid[8] = uuid[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]), nil
+}
+
+// indexConnection makes a registered client findable by its connection
+// ID. The caller holds h.mu.
+func (h *Hub) indexConnection(client *Client) {
+	if client.connectionID != "" {
+		h.byConnection[client.connectionID] = client
+	}
+}
+
+// unindexConnection removes a client from the connection ID index. The
+// caller holds h.mu.
+func (h *Hub) unindexConnection(client *Client) {
+	if h.byConnection[client.connectionID] == client {
+		delete(h.byConnection, client.connectionID)
+	}
+}
+
+// clientByConnection returns the client with a connection ID (nil when
+// there is none)
+func (h *Hub) clientByConnection(connectionID string) *Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.byConnection[connectionID]
+}
@@ -0,0 +1,26 @@
+package websocket
+
+import (
+	"testing"
+)
+
+// TestConnectionIndex tests that registered clients are found by
+// connection ID until they are removed
+func TestConnectionIndex(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub, ClientTypeVideo, "camera")
+	client.SetConnectionID("conn_1")
+	if hub.clientByConnection("conn_1") != client {
+		t.Fatal("Expected the client to be indexed by its connection ID")
+	}
+
+	client.SetConnectionID("conn_2")
+	if hub.clientByConnection("conn_1") != nil || hub.clientByConnection("conn_2") != client {
+		t.Error("Expected the index to follow a changed connection ID")
+	}
+
+	hub.removeClient(client)
+	if hub.clientByConnection("conn_2") != nil {
+		t.Error("Expected a removed client to leave the index")
+	}
+}
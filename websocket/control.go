@@ -0,0 +1,205 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// ControlLockState describes which web client holds a room's control lock
+type ControlLockState struct {
+	Holder       string     `json:"holder,omitempty"`
+	ConnectionID string     `json:"connection_id,omitempty"`
+	AcquiredAt   *time.Time `json:"acquired_at,omitempty"`
+	LastActivity *time.Time `json:"last_activity,omitempty"`
+}
+
+// controlHold is the holder of one room's lock
+type controlHold struct {
+	holder       *Client
+	acquiredAt   time.Time
+	lastActivity time.Time
+
+	// Client waiting for the holder to grant a takeover (see takeover.go)
+	takeover   *Client
+	takeoverAt time.Time
+}
+
+// controlLock lets one web client per room claim exclusive control; the
+// others in the room are observers until it is released
+type controlLock struct {
+	holds map[string]*controlHold // By room
+
+	// idleTimeout releases the lock after no control_command or heartbeat
+	// from the holder (0 disables)
+	idleTimeout time.Duration
+
+	mu sync.Mutex
+}
+
+// SetControlIdleTimeout releases the control lock when its holder sends no
+// control_command or heartbeat for d (0 disables). Call before Run.
+func (h *Hub) SetControlIdleTimeout(d time.Duration) {
+	h.control.mu.Lock()
+	defer h.control.mu.Unlock()
+	h.control.idleTimeout = d
+}
+
+// ControlLock returns the control lock state of a room
+func (h *Hub) ControlLock(room string) ControlLockState {
+	h.control.mu.Lock()
+	defer h.control.mu.Unlock()
+	return h.controlStateLocked(room)
+}
+
+// controlStateLocked describes a room's lock; control.mu must be held
+func (h *Hub) controlStateLocked(room string) ControlLockState {
+	hold := h.control.holds[room]
+	if hold == nil {
+		return ControlLockState{}
+	}
+	acquiredAt, lastActivity := hold.acquiredAt, hold.lastActivity
+	return ControlLockState{
+		Holder:       hold.holder.username,
+		ConnectionID: hold.holder.connectionID,
+		AcquiredAt:   &acquiredAt,
+		LastActivity: &lastActivity,
+	}
+}
+
+// handleRequestControl grants the lock of its room to a web client if it
+// is free
+func (h *Hub) handleRequestControl(client *Client) {
+	if client.Type() != ClientTypeWeb {
+		return
+	}
+
+	h.control.mu.Lock()
+	hold := h.control.holds[client.Room()]
+	if hold != nil && hold.holder != client {
+		state := h.controlStateLocked(client.Room())
+		h.control.mu.Unlock()
+		client.SendJSON(map[string]interface{}{
+			"type":   "error",
+			"error":  "control_locked",
+			"holder": state.Holder,
+		})
+		return
+	}
+
+	acquired := hold == nil
+	if acquired {
+		if h.control.holds == nil {
+			h.control.holds = make(map[string]*controlHold)
+		}
+		hold = &controlHold{holder: client, acquiredAt: time.Now()}
+		h.control.holds[client.Room()] = hold
+	}
+	hold.lastActivity = time.Now()
+	state := h.controlStateLocked(client.Room())
+	h.control.mu.Unlock()
+
+	if acquired {
+		log.Printf("🎮 Control lock of room %s acquired by %s", RoomID(client.Room()), client.username)
+		h.broadcastControlLock(client.Room(), state, "acquired")
+	}
+}
+
+// handleReleaseControl releases the lock if client holds it
+func (h *Hub) handleReleaseControl(client *Client) {
+	h.releaseControl(client, "released")
+}
+
+// releaseControl releases the lock held by client and tells web clients in
+// its room why
+func (h *Hub) releaseControl(client *Client, reason string) bool {
+	h.control.mu.Lock()
+	hold := h.control.holds[client.Room()]
+	if hold == nil || hold.holder != client {
+		h.control.mu.Unlock()
+		return false
+	}
+	delete(h.control.holds, client.Room())
+	h.control.mu.Unlock()
+
+	log.Printf("🎮 Control lock of room %s released by %s (%s)", RoomID(client.Room()), client.username, reason)
+	h.broadcastControlLock(client.Room(), ControlLockState{}, reason)
+	return true
+}
+
+// allowControl reports whether sender may send control commands: anyone
+// while its room's lock is free, otherwise only the holder. Commands from
+// the holder count as activity.
+func (h *Hub) allowControl(sender *Client, msgType string) bool {
+	h.control.mu.Lock()
+	var holder *Client
+	if hold := h.control.holds[sender.Room()]; hold != nil {
+		holder = hold.holder
+		if holder == sender {
+			hold.lastActivity = time.Now()
+		}
+	}
+	h.control.mu.Unlock()
+
+	if holder == nil || holder == sender {
+		return true
+	}
+
+	sender.SendJSON(map[string]interface{}{
+		"type":         "error",
+		"error":        "control_locked",
+		"message_type": msgType,
+		"holder":       holder.username,
+	})
+	return false
+}
+
+// handleHeartbeat keeps the holder's lock alive without sending a command
+func (h *Hub) handleHeartbeat(client *Client) {
+	h.control.mu.Lock()
+	defer h.control.mu.Unlock()
+	if hold := h.control.holds[client.Room()]; hold != nil && hold.holder == client {
+		hold.lastActivity = time.Now()
+	}
+}
+
+// expireControlLock demotes holders that have been idle past the timeout
+func (h *Hub) expireControlLock(now time.Time) {
+	h.control.mu.Lock()
+	timeout := h.control.idleTimeout
+	idle := make(map[*Client]time.Duration)
+	for _, hold := range h.control.holds {
+		if d := now.Sub(hold.lastActivity); timeout > 0 && d >= timeout {
+			idle[hold.holder] = d
+		}
+	}
+	h.control.mu.Unlock()
+
+	for holder, d := range idle {
+		if h.releaseControl(holder, "idle_timeout") {
+			holder.SendJSON(map[string]interface{}{
+				"type":      "control_demoted",
+				"reason":    "idle_timeout",
+				"idle":      d.Round(time.Second).String(),
+				"timestamp": now.Unix(),
+			})
+		}
+	}
+}
+
+// broadcastControlLock tells web clients in a room who holds its lock now.
+// The lock belongs to this instance, so the notice is not shared on the
+// backplane.
+func (h *Hub) broadcastControlLock(room string, state ControlLockState, reason string) {
+	message, err := json.Marshal(map[string]interface{}{
+		"type":      "control_lock",
+		"holder":    state.Holder,
+		"reason":    reason,
+		"timestamp": time.Now().Unix(),
+	})
+	if err != nil {
+		return
+	}
+	h.deliverTo(room, routeTarget{}, []ClientType{ClientTypeWeb}, message)
+}
@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// messageTypes returns the type of each queued message
+func messageTypes(client *Client) []string {
+	var types []string
+	for _, raw := range drainMessages(client) {
+		var msg Message
+		json.Unmarshal(raw, &msg)
+		types = append(types, msg.Type)
+	}
+	return types
+}
+
+// TestControlLock tests exclusive control by the lock holder
+func TestControlLock(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+
+	hub.RouteMessage(alice, []byte(`{"type":"request_control"}`))
+	if hub.ControlLock(DefaultRoom).Holder != "alice" {
+		t.Fatalf("Expected alice to hold the lock, got %+v", hub.ControlLock(DefaultRoom))
+	}
+	drainMessages(alice)
+	drainMessages(bob)
+
+	hub.RouteMessage(bob, []byte(`{"type":"request_control"}`))
+	hub.RouteMessage(bob, []byte(`{"type":"control_command"}`))
+	if len(drainMessages(robot)) != 0 {
+		t.Error("Expected observer command to be blocked")
+	}
+	if types := messageTypes(bob); len(types) != 2 || types[0] != "error" || types[1] != "error" {
+		t.Errorf("Expected two control_locked errors, got %v", types)
+	}
+
+	hub.RouteMessage(alice, []byte(`{"type":"control_command"}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Error("Expected holder command to reach the robot")
+	}
+
+	// Emergency stop is never blocked by the lock
+	hub.RouteMessage(bob, []byte(`{"type":"emergency_stop"}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Error("Expected observer emergency stop to reach the robot")
+	}
+
+	hub.RouteMessage(alice, []byte(`{"type":"release_control"}`))
+	if hub.ControlLock(DefaultRoom).Holder != "" {
+		t.Error("Expected lock to be released")
+	}
+	hub.RouteMessage(bob, []byte(`{"type":"control_command"}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Error("Expected command to pass once the lock is free")
+	}
+}
+
+// TestControlLockIdleTimeout tests demoting an idle holder
+func TestControlLockIdleTimeout(t *testing.T) {
+	hub := NewHub()
+	hub.SetControlIdleTimeout(time.Minute)
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+
+	hub.RouteMessage(alice, []byte(`{"type":"request_control"}`))
+	drainMessages(alice)
+	drainMessages(bob)
+
+	// A heartbeat keeps the lock
+	hub.RouteMessage(alice, []byte(`{"type":"heartbeat"}`))
+	hub.expireControlLock(time.Now().Add(30 * time.Second))
+	if hub.ControlLock(DefaultRoom).Holder != "alice" {
+		t.Fatal("Expected lock to be kept within the timeout")
+	}
+
+	hub.expireControlLock(time.Now().Add(2 * time.Minute))
+	if hub.ControlLock(DefaultRoom).Holder != "" {
+		t.Fatal("Expected idle holder to be demoted")
+	}
+
+	types := messageTypes(alice)
+	if len(types) != 2 || types[0] != "control_lock" || types[1] != "control_demoted" {
+		t.Errorf("Expected control_lock and control_demoted, got %v", types)
+	}
+	if types := messageTypes(bob); len(types) != 1 || types[0] != "control_lock" {
+		t.Errorf("Expected observers to be told the lock is free, got %v", types)
+	}
+}
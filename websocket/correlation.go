@@ -0,0 +1,275 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// commandTimelineSize bounds how many commands the hub remembers
+const commandTimelineSize = 256
+
+// CommandResponse is the first control_response or command_nack from one
+// robot to a command
+type CommandResponse struct {
+	Robot       string    `json:"robot"`
+	RespondedAt time.Time `json:"responded_at"`
+	RTTMillis   int64     `json:"rtt_ms"`
+	Duplicates  int       `json:"duplicates"`
+	Nack        bool      `json:"nack,omitempty"`
+}
+
+// CommandRecord is one correlated control_command and its responses
+type CommandRecord struct {
+	ID        string            `json:"id"`
+	From      string            `json:"from"`
+	Robots    []string          `json:"robots"`
+	SentAt    time.Time         `json:"sent_at"`
+	Retries   int               `json:"retries"`
+	Responses []CommandResponse `json:"responses"`
+
+	// TimedOut lists the robots that did not answer before the command
+	// timeout, after any retry
+	TimedOut []string `json:"timed_out,omitempty"`
+
+	// Sender, robots and message, kept to report a timeout and retry (see
+	// timeoutCommand)
+	sender  *Client
+	clients []*Client
+	data    []byte
+	retry   bool
+	retried bool
+	timer   *time.Timer
+}
+
+// commandRetry is the field of a control_command that asks for one retry
+// after a timeout
+type commandRetry struct {
+	Retry bool `json:"retry"`
+}
+
+// commandTracker matches control_response messages to the control_command
+// they answer by correlation ID
+type commandTracker struct {
+	records map[string]*CommandRecord
+	order   []string // oldest first
+
+	// How long robots have to answer a command (0 disables timeouts)
+	timeout time.Duration
+
+	mu sync.Mutex
+}
+
+// correlationID returns the message field as a string ("" when absent).
+// IDs may be strings or numbers.
+func correlationID(rawMessage []byte, field string) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rawMessage, &fields); err != nil {
+		return ""
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(fields[field], &value); err != nil {
+		return ""
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// trackCommand records a command delivered to robots. Resending an ID the
+// same sender already used counts as a retry of that command.
+func (h *Hub) trackCommand(sender *Client, robots []*Client, rawMessage []byte) {
+	id := correlationID(rawMessage, "id")
+	if id == "" || len(robots) == 0 {
+		return
+	}
+
+	t := &h.commands
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.records == nil {
+		t.records = make(map[string]*CommandRecord)
+	}
+	if record, ok := t.records[id]; ok && record.From == sender.username {
+		record.Retries++
+		return
+	}
+
+	names := make([]string, 0, len(robots))
+	for _, robot := range robots {
+		names = append(names, robot.username)
+	}
+
+	if previous, ok := t.records[id]; !ok {
+		t.order = append(t.order, id)
+	} else if previous.timer != nil {
+		previous.timer.Stop()
+	}
+	record := &CommandRecord{
+		ID:      id,
+		From:    sender.username,
+		Robots:  names,
+		SentAt:  time.Now(),
+		sender:  sender,
+		clients: robots,
+		data:    rawMessage,
+	}
+	t.records[id] = record
+	if t.timeout > 0 {
+		var retry commandRetry
+		json.Unmarshal(rawMessage, &retry)
+		record.retry = retry.Retry
+		record.timer = time.AfterFunc(t.timeout, func() { h.timeoutCommand(record) })
+	}
+
+	for len(t.order) > commandTimelineSize {
+		if evicted := t.records[t.order[0]]; evicted.timer != nil {
+			evicted.timer.Stop()
+		}
+		delete(t.records, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// trackResponse matches a control_response or, with nack, a command_nack to
+// its command and reports whether it should be forwarded. Only the first
+// response from each robot is forwarded; repeats (a robot answering every
+// retry) are collapsed.
+func (h *Hub) trackResponse(sender *Client, rawMessage []byte, nack bool) bool {
+	id := correlationID(rawMessage, "correlation_id")
+	if id == "" {
+		return true
+	}
+
+	t := &h.commands
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	record, ok := t.records[id]
+	if !ok {
+		return true
+	}
+
+	for i := range record.Responses {
+		if record.Responses[i].Robot == sender.username {
+			record.Responses[i].Duplicates++
+			log.Printf("🔁 Duplicate control response %s from %s collapsed", id, sender.username)
+			return false
+		}
+	}
+
+	now := time.Now()
+	record.Responses = append(record.Responses, CommandResponse{
+		Robot:       sender.username,
+		RespondedAt: now,
+		RTTMillis:   now.Sub(record.SentAt).Milliseconds(),
+		Nack:        nack,
+	})
+	if record.timer != nil && len(record.unanswered()) == 0 {
+		record.timer.Stop()
+	}
+	return true
+}
+
+// unanswered returns the robots that have not responded to the command.
+// The caller holds the tracker's lock.
+func (r *CommandRecord) unanswered() []*Client {
+	var robots []*Client
+	for _, robot := range r.clients {
+		answered := false
+		for _, response := range r.Responses {
+			if response.Robot == robot.username {
+				answered = true
+				break
+			}
+		}
+		if !answered {
+			robots = append(robots, robot)
+		}
+	}
+	return robots
+}
+
+// SetCommandTimeout tells web clients with command_timeout when a robot
+// does not answer a control_command with an ID within d (0 disables).
+// Commands sent with "retry": true are delivered once more first. Call
+// before Run.
+func (h *Hub) SetCommandTimeout(d time.Duration) {
+	h.commands.timeout = d
+}
+
+// timeoutCommand reports the robots that did not answer a command in time
+// to its sender, retrying once if the command asked for it
+func (h *Hub) timeoutCommand(record *CommandRecord) {
+	t := &h.commands
+	t.mu.Lock()
+	if t.records[record.ID] != record {
+		// Evicted from the timeline or replaced by a resend
+		t.mu.Unlock()
+		return
+	}
+	robots := record.unanswered()
+	if len(robots) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	names := make([]string, 0, len(robots))
+	for _, robot := range robots {
+		names = append(names, robot.username)
+	}
+	retrying := record.retry && !record.retried
+	if retrying {
+		record.retried = true
+		record.Retries++
+		record.timer = time.AfterFunc(t.timeout, func() { h.timeoutCommand(record) })
+	} else {
+		record.TimedOut = names
+	}
+	t.mu.Unlock()
+
+	log.Printf("⌛ Command %s from %s unanswered by %v after %v (retrying: %v)",
+		record.ID, record.From, names, t.timeout, retrying)
+	record.sender.SendJSON(map[string]interface{}{
+		"type":       "command_timeout",
+		"id":         record.ID,
+		"robots":     names,
+		"timeout_ms": t.timeout.Milliseconds(),
+		"retrying":   retrying,
+	})
+	if retrying {
+		for _, robot := range robots {
+			h.deliver(robot, outbound{data: record.data})
+		}
+	}
+}
+
+// CommandTimeline returns the most recent correlated commands, newest
+// first, optionally only those sent by one user
+func (h *Hub) CommandTimeline(from string, limit int) []CommandRecord {
+	t := &h.commands
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timeline := make([]CommandRecord, 0)
+	for i := len(t.order) - 1; i >= 0 && len(timeline) < limit; i-- {
+		record := t.records[t.order[i]]
+		if from != "" && record.From != from {
+			continue
+		}
+
+		entry := *record
+		entry.TimedOut = append([]string(nil), record.TimedOut...)
+		entry.Robots = append([]string(nil), record.Robots...)
+		entry.Responses = append(make([]CommandResponse, 0, len(record.Responses)), record.Responses...)
+		timeline = append(timeline, entry)
+	}
+	return timeline
+}
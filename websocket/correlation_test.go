@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestCommandCorrelation tests matching responses to commands and
+// collapsing duplicate responses to a retried command
+func TestCommandCorrelation(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+
+	// The first send and a retry both reach the robot
+	hub.RouteMessage(alice, []byte(`{"type":"control_command","id":"cmd-1"}`))
+	hub.RouteMessage(alice, []byte(`{"type":"control_command","id":"cmd-1"}`))
+	if got := len(drainMessages(robot)); got != 2 {
+		t.Fatalf("Expected both sends to reach the robot, got %d", got)
+	}
+
+	// Only the first response per robot goes back
+	hub.RouteMessage(robot, []byte(`{"type":"control_response","correlation_id":"cmd-1"}`))
+	hub.RouteMessage(robot, []byte(`{"type":"control_response","correlation_id":"cmd-1"}`))
+	if got := len(drainMessages(alice)); got != 1 {
+		t.Errorf("Expected duplicate response to be collapsed, got %d responses", got)
+	}
+
+	// Uncorrelated and unknown responses are forwarded unchanged
+	hub.RouteMessage(robot, []byte(`{"type":"control_response"}`))
+	hub.RouteMessage(robot, []byte(`{"type":"control_response","correlation_id":"unknown"}`))
+	if got := len(drainMessages(alice)); got != 2 {
+		t.Errorf("Expected uncorrelated responses to be forwarded, got %d", got)
+	}
+
+	timeline := hub.CommandTimeline("", 10)
+	if len(timeline) != 1 {
+		t.Fatalf("Expected 1 command in timeline, got %d", len(timeline))
+	}
+	record := timeline[0]
+	if record.ID != "cmd-1" || record.From != "alice" || record.Retries != 1 {
+		t.Errorf("Unexpected command record: %+v", record)
+	}
+	if len(record.Responses) != 1 || record.Responses[0].Robot != "robot-1" || record.Responses[0].Duplicates != 1 {
+		t.Errorf("Unexpected responses: %+v", record.Responses)
+	}
+
+	if got := hub.CommandTimeline("bob", 10); len(got) != 0 {
+		t.Errorf("Expected no commands from bob, got %d", len(got))
+	}
+}
+
+// TestCommandTimelineBounded tests that only recent commands are kept
+func TestCommandTimelineBounded(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	newTestClient(hub, ClientTypeControl, "robot-1")
+
+	for i := 0; i < commandTimelineSize+10; i++ {
+		hub.RouteMessage(alice, []byte(`{"type":"control_command","id":`+strconv.Itoa(i)+`}`))
+	}
+
+	timeline := hub.CommandTimeline("", commandTimelineSize*2)
+	if len(timeline) != commandTimelineSize {
+		t.Fatalf("Expected %d commands, got %d", commandTimelineSize, len(timeline))
+	}
+	if timeline[0].ID != strconv.Itoa(commandTimelineSize+9) {
+		t.Errorf("Expected newest command first, got %s", timeline[0].ID)
+	}
+}
+
+// TestCommandTimeout tests that unanswered commands are reported, retried
+// once on request, and that a NACK counts as an answer
+func TestCommandTimeout(t *testing.T) {
+	hub := NewHub()
+	hub.SetCommandTimeout(20 * time.Millisecond)
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+
+	var timeouts []map[string]interface{}
+	collect := func() {
+		for _, data := range drainMessages(alice) {
+			var msg map[string]interface{}
+			json.Unmarshal(data, &msg)
+			if msg["type"] == "command_timeout" {
+				timeouts = append(timeouts, msg)
+			}
+		}
+	}
+
+	// An unanswered command is delivered once more, then reported
+	hub.RouteMessage(alice, []byte(`{"type":"control_command","id":"cmd-1","retry":true}`))
+	waitFor(t, "command timeouts", func() bool {
+		collect()
+		return len(timeouts) == 2
+	})
+	if timeouts[0]["retrying"] != true || timeouts[1]["retrying"] != false {
+		t.Errorf("Expected a retry before the final timeout, got %v", timeouts)
+	}
+	if got := len(drainMessages(robot)); got != 2 {
+		t.Errorf("Expected the command and its retry to reach the robot, got %d", got)
+	}
+	record := hub.CommandTimeline("", 1)[0]
+	if len(record.TimedOut) != 1 || record.TimedOut[0] != "robot-1" || record.Retries != 1 {
+		t.Errorf("Unexpected timed out command: %+v", record)
+	}
+
+	// A NACK is forwarded and stops the timeout
+	timeouts = nil
+	hub.RouteMessage(alice, []byte(`{"type":"control_command","id":"cmd-2"}`))
+	hub.RouteMessage(robot, []byte(`{"type":"command_nack","correlation_id":"cmd-2","reason":"busy"}`))
+	if got := messageTypes(alice); len(got) != 1 || got[0] != "command_nack" {
+		t.Errorf("Expected the NACK to be forwarded, got %v", got)
+	}
+	time.Sleep(60 * time.Millisecond)
+	collect()
+	if len(timeouts) != 0 {
+		t.Errorf("Expected no timeout after a NACK, got %v", timeouts)
+	}
+	record = hub.CommandTimeline("", 1)[0]
+	if len(record.Responses) != 1 || !record.Responses[0].Nack || len(record.TimedOut) != 0 {
+		t.Errorf("Unexpected NACKed command: %+v", record)
+	}
+}
@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// deadLetterQueueSize bounds how many undeliverable messages the hub keeps
+const deadLetterQueueSize = 256
+
+// Reasons a message was dead-lettered
+const (
+	// DeadLetterNoRecipients: no client of the destination type was
+	// connected in the room
+	DeadLetterNoRecipients = "no_recipients"
+
+	// DeadLetterUnacknowledged: an at-least-once command expired before
+	// its robot acknowledged it (see qos.go)
+	DeadLetterUnacknowledged = "unacknowledged"
+)
+
+// DeadLetter is a message the hub could not deliver
+type DeadLetter struct {
+	ID         int64           `json:"id"`
+	Time       time.Time       `json:"time"`
+	Type       string          `json:"type"`
+	From       string          `json:"from"`
+	Room       string          `json:"room"`
+	RobotID    string          `json:"robot_id,omitempty"`
+	ClientType ClientType      `json:"client_type"` // Destination type
+	Reason     string          `json:"reason"`
+	Message    json.RawMessage `json:"message"`
+}
+
+// deadLetterQueue keeps the most recent undeliverable messages
+type deadLetterQueue struct {
+	letters []DeadLetter // oldest first
+	nextID  int64
+
+	mu sync.Mutex
+}
+
+// deadLetter records a message no client of clientType received and tells
+// the sender with delivery_failed. With a backplane another instance may
+// have delivered it, so nothing is recorded.
+func (h *Hub) deadLetter(sender *Client, msgType string, rawMessage []byte, target routeTarget, clientType ClientType) {
+	if h.relay.backplane != nil {
+		return
+	}
+	letter := h.recordDeadLetter(DeadLetter{
+		Type:       msgType,
+		From:       sender.username,
+		Room:       RoomID(sender.Room()),
+		RobotID:    target.robotID,
+		ClientType: clientType,
+		Reason:     DeadLetterNoRecipients,
+		Message:    rawMessage,
+	})
+
+	notice := map[string]interface{}{
+		"type":           "delivery_failed",
+		"message_type":   msgType,
+		"reason":         letter.Reason,
+		"client_type":    clientType,
+		"dead_letter_id": letter.ID,
+	}
+	if id := correlationID(rawMessage, "id"); id != "" {
+		notice["id"] = id
+	}
+	if target.robotID != "" {
+		notice["robot_id"] = target.robotID
+	}
+	sender.SendJSON(notice)
+}
+
+// recordDeadLetter adds a message to the queue, dropping the oldest when
+// it is full, and returns it with its ID and time set
+func (h *Hub) recordDeadLetter(letter DeadLetter) DeadLetter {
+	q := &h.deadLetters
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	letter.ID = q.nextID
+	letter.Time = time.Now()
+	q.letters = append(q.letters, letter)
+	if len(q.letters) > deadLetterQueueSize {
+		q.letters = q.letters[len(q.letters)-deadLetterQueueSize:]
+	}
+	log.Printf("📭 %s from %s dead-lettered (%s)", letter.Type, letter.From, letter.Reason)
+	return letter
+}
+
+// DeadLetters returns the most recent undeliverable messages, newest first,
+// optionally only those of one message type
+func (h *Hub) DeadLetters(msgType string, limit int) []DeadLetter {
+	q := &h.deadLetters
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letters := make([]DeadLetter, 0)
+	for i := len(q.letters) - 1; i >= 0 && len(letters) < limit; i-- {
+		if msgType != "" && q.letters[i].Type != msgType {
+			continue
+		}
+		letters = append(letters, q.letters[i])
+	}
+	return letters
+}
+
+// ClearDeadLetters empties the queue and returns how many messages it held
+func (h *Hub) ClearDeadLetters() int {
+	q := &h.deadLetters
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := len(q.letters)
+	q.letters = nil
+	return count
+}
+
+// deadLetterCount returns how many messages were dead-lettered in total
+func (h *Hub) deadLetterCount() int64 {
+	q := &h.deadLetters
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.nextID
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestDeadLetters tests that messages without recipients are queued with
+// their reason and reported to the sender
+func TestDeadLetters(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClientIn(hub, "lab", "operator")
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"c1","data":{}}`))
+	messages := drainMessages(operator)
+	if len(messages) != 1 {
+		t.Fatalf("Expected delivery_failed, got %d messages", len(messages))
+	}
+	var notice map[string]interface{}
+	json.Unmarshal(messages[0], &notice)
+	if notice["type"] != "delivery_failed" || notice["id"] != "c1" || notice["reason"] != DeadLetterNoRecipients ||
+		notice["client_type"] != string(ClientTypeControl) {
+		t.Errorf("Unexpected notice %v", notice)
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"offer","sdp":"v=0"}`))
+	if types := messageTypes(operator); len(types) != 1 || types[0] != "delivery_failed" {
+		t.Errorf("Expected delivery_failed for the offer, got %v", types)
+	}
+
+	// Commands that reach a robot, or wait for one, are not dead letters
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.place("lab", "")
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"c2","data":{}}`))
+	hub.removeClient(robot)
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"c3","qos":1,"robot_id":"r1","data":{}}`))
+	if types := messageTypes(operator); len(types) != 0 {
+		t.Errorf("Expected no failure notices, got %v", types)
+	}
+
+	letters := hub.DeadLetters("", 10)
+	if len(letters) != 2 || letters[0].Type != "offer" || letters[1].Type != "control_command" {
+		t.Fatalf("Expected two dead letters, newest first, got %+v", letters)
+	}
+	if letters[1].From != "operator" || letters[1].Room != "lab" || string(letters[1].Message) != `{"type":"control_command","id":"c1","data":{}}` {
+		t.Errorf("Unexpected dead letter %+v", letters[1])
+	}
+	if filtered := hub.DeadLetters("offer", 10); len(filtered) != 1 {
+		t.Errorf("Expected one offer, got %d", len(filtered))
+	}
+
+	// An at-least-once command nobody acknowledged ends up there too
+	hub.SetCommandQoSTTL(time.Millisecond)
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"c4","qos":1,"robot_id":"r2","data":{}}`))
+	time.Sleep(5 * time.Millisecond)
+	hub.commandQoSStats()
+	if letters := hub.DeadLetters("", 1); len(letters) != 1 || letters[0].Reason != DeadLetterUnacknowledged {
+		t.Errorf("Expected an unacknowledged command, got %+v", letters)
+	}
+
+	if count := hub.ClearDeadLetters(); count != 3 {
+		t.Errorf("Expected 3 dead letters cleared, got %d", count)
+	}
+	if hub.deadLetterCount() != 3 || len(hub.DeadLetters("", 10)) != 0 {
+		t.Error("Expected an empty queue that keeps the total")
+	}
+}
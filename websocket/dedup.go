@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"oculo-pilot-server/logging"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dedupCacheSize bounds how many message IDs the hub remembers
+const dedupCacheSize = 4096
+
+// dedupEntry is one remembered message ID, in arrival order
+type dedupEntry struct {
+	key     string
+	expires time.Time
+}
+
+// dedupCache remembers recent message IDs per sender, so a message a
+// client resends after reconnecting is not routed twice
+type dedupCache struct {
+	// How long IDs are remembered, by message type (unlisted types are
+	// not checked)
+	windows map[string]time.Duration
+
+	seen       map[string]time.Time // Expiry by sender, type and ID
+	order      []dedupEntry         // Oldest first
+	suppressed atomic.Int64
+
+	mu sync.Mutex
+}
+
+// SetDedupWindows drops messages of each type whose id the same sender
+// already used within the window, e.g. control_command 30s. Call before
+// Run.
+func (h *Hub) SetDedupWindows(windows map[string]time.Duration) {
+	h.dedup.windows = windows
+}
+
+// DedupWindows returns the configured windows
+func (h *Hub) DedupWindows() map[string]time.Duration {
+	windows := make(map[string]time.Duration, len(h.dedup.windows))
+	for msgType, window := range h.dedup.windows {
+		if window > 0 {
+			windows[msgType] = window
+		}
+	}
+	return windows
+}
+
+// DuplicatesSuppressed returns how many duplicate messages were dropped
+func (h *Hub) DuplicatesSuppressed() int64 {
+	return h.dedup.suppressed.Load()
+}
+
+// dedupKey returns what a message is remembered under, or "" when it is
+// not checked: its type has no window, it has no id, or it is an
+// emergency stop, which must always get through. Senders are told apart by
+// username, so the check holds across connections.
+func (h *Hub) dedupKey(sender *Client, msgType string, rawMessage []byte) (key, id string) {
+	if h.dedup.windows[msgType] <= 0 || isEmergencyStop(msgType) {
+		return "", ""
+	}
+	if id = correlationID(rawMessage, "id"); id == "" {
+		return "", ""
+	}
+	return sender.username + "\x00" + msgType + "\x00" + id, id
+}
+
+// isDuplicate reports whether a message repeats an id its sender had
+// routed within the type's window. The sender is told about the dropped
+// copy, so a client retrying after a reconnect knows the first one got
+// through.
+func (h *Hub) isDuplicate(sender *Client, msgType, key, id string) bool {
+	if key == "" || !h.dedup.contains(key, time.Now()) {
+		return false
+	}
+	h.dedup.suppressed.Add(1)
+	logging.Sampled("ws_duplicate", "🔁 Duplicate %s %s from %s dropped", msgType, id, sender.username)
+	sender.SendJSON(map[string]interface{}{
+		"type":         "duplicate_message",
+		"message_type": msgType,
+		"id":           id,
+	})
+	return true
+}
+
+// rememberMessage records the id of a routed message for its type's
+// window. Only messages their handler did not reject are remembered, so a
+// command refused for lack of the control lock can be retried.
+func (h *Hub) rememberMessage(msgType, key string) {
+	if key != "" {
+		h.dedup.add(key, time.Now(), h.dedup.windows[msgType])
+	}
+}
+
+// contains reports whether a key is recorded and not yet expired
+func (d *dedupCache) contains(key string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expires, ok := d.seen[key]
+	return ok && now.Before(expires)
+}
+
+// add records a key until now+window
+func (d *dedupCache) add(key string, now time.Time, window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Forget expired IDs, and the oldest ones beyond the cache size
+	for len(d.order) > 0 && (len(d.order) >= dedupCacheSize || !now.Before(d.order[0].expires)) {
+		oldest := d.order[0]
+		if d.seen[oldest.key] == oldest.expires {
+			delete(d.seen, oldest.key)
+		}
+		d.order = d.order[1:]
+	}
+
+	if d.seen == nil {
+		d.seen = make(map[string]time.Time)
+	}
+	expires := now.Add(window)
+	d.seen[key] = expires
+	d.order = append(d.order, dedupEntry{key: key, expires: expires})
+}
@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestDuplicateCommandSuppressed tests that a command resent with the same
+// id reaches the robot once, even from a new connection
+func TestDuplicateCommandSuppressed(t *testing.T) {
+	hub := NewHub()
+	hub.SetDedupWindows(map[string]time.Duration{"control_command": 30 * time.Second})
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+
+	command := []byte(`{"type":"control_command","id":"cmd-1"}`)
+	hub.RouteMessage(alice, command)
+	hub.RouteMessage(alice, command)
+	if got := len(drainMessages(robot)); got != 1 {
+		t.Fatalf("Expected the command to reach the robot once, got %d", got)
+	}
+	if types := messageTypes(alice); !reflect.DeepEqual(types, []string{"duplicate_message"}) {
+		t.Errorf("Expected duplicate_message, got %v", types)
+	}
+
+	// Retrying after a reconnect is still a duplicate
+	reconnected := newTestClient(hub, ClientTypeWeb, "alice")
+	hub.RouteMessage(reconnected, command)
+	if got := len(drainMessages(robot)); got != 0 {
+		t.Errorf("Expected the retry from a new connection to be dropped, got %d", got)
+	}
+
+	// Other senders, other IDs and commands without an ID are routed
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+	hub.RouteMessage(bob, command)
+	hub.RouteMessage(alice, []byte(`{"type":"control_command","id":"cmd-2"}`))
+	hub.RouteMessage(alice, []byte(`{"type":"control_command"}`))
+	hub.RouteMessage(alice, []byte(`{"type":"control_command"}`))
+	if got := len(drainMessages(robot)); got != 4 {
+		t.Errorf("Expected 4 commands routed, got %d", got)
+	}
+	if suppressed := hub.GetStats()["duplicates_suppressed"]; suppressed != int64(2) {
+		t.Errorf("Expected duplicates_suppressed 2, got %v", suppressed)
+	}
+}
+
+// TestDuplicateAfterRejection tests that a command refused by its handler
+// is not remembered, that emergency stops are never suppressed and that
+// dropped copies do not reach monitors
+func TestDuplicateAfterRejection(t *testing.T) {
+	hub := NewHub()
+	hub.SetDedupWindows(map[string]time.Duration{
+		"control_command": 30 * time.Second,
+		"emergency_stop":  30 * time.Second,
+	})
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+	monitor := newTestClient(hub, ClientTypeMonitor, "inspector")
+
+	// Bob holds the lock, so alice's command is refused
+	hub.RouteMessage(bob, []byte(`{"type":"request_control"}`))
+	command := []byte(`{"type":"control_command","id":"cmd-1"}`)
+	hub.RouteMessage(alice, command)
+	if got := len(drainMessages(robot)); got != 0 {
+		t.Fatalf("Expected the command to be refused while bob holds the lock, got %d", got)
+	}
+
+	hub.RouteMessage(bob, []byte(`{"type":"release_control"}`))
+	drainMessages(alice)
+	drainMessages(monitor)
+	hub.RouteMessage(alice, command)
+	if got := len(drainMessages(robot)); got != 1 {
+		t.Fatalf("Expected the retry after the refusal to reach the robot, got %d", got)
+	}
+	mirrored := len(drainMessages(monitor))
+	hub.RouteMessage(alice, command)
+	if got := len(drainMessages(monitor)); got != 0 || mirrored == 0 {
+		t.Errorf("Expected only the first copy mirrored, got %d then %d", mirrored, got)
+	}
+
+	stop := []byte(`{"type":"emergency_stop","id":"stop-1"}`)
+	hub.RouteMessage(alice, stop)
+	hub.RouteMessage(alice, stop)
+	if got := len(drainMessages(robot)); got != 2 {
+		t.Errorf("Expected both emergency stops delivered, got %d", got)
+	}
+}
+
+// TestDedupCacheExpires tests that an ID is forgotten after its window
+func TestDedupCacheExpires(t *testing.T) {
+	var cache dedupCache
+	now := time.Now()
+	if cache.contains("alice/cmd-1", now) {
+		t.Fatal("Expected a new ID not to be a duplicate")
+	}
+	cache.add("alice/cmd-1", now, time.Second)
+	if !cache.contains("alice/cmd-1", now.Add(500*time.Millisecond)) {
+		t.Error("Expected a duplicate within the window")
+	}
+	if cache.contains("alice/cmd-1", now.Add(time.Second)) {
+		t.Error("Expected the ID to be forgotten after the window")
+	}
+	cache.add("alice/cmd-2", now.Add(time.Second), time.Second)
+	if len(cache.seen) != 1 || len(cache.order) != 1 {
+		t.Errorf("Expected the expired entry to be pruned, got %d/%d", len(cache.seen), len(cache.order))
+	}
+}
@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeReasonAdmin is the close reason of clients disconnected by an admin
+const closeReasonAdmin = "admin_disconnect"
+
+// maxCloseText bounds the text of a close frame, whose payload is at most
+// 125 bytes including the 2-byte code
+const maxCloseText = 123
+
+// disconnectRequest is a disconnect_client message
+type disconnectRequest struct {
+	ConnectionID string `json:"connection_id"`
+	Reason       string `json:"reason"`
+}
+
+// DisconnectClient closes the connection with a connection ID for an admin
+// (by) and removes its client from the hub. The client receives a close
+// frame with reason and cannot resume the connection.
+func (h *Hub) DisconnectClient(connectionID, by, reason string) error {
+	client := h.clientByConnection(connectionID)
+	if client == nil {
+		return ErrConnectionNotFound
+	}
+
+	text := "disconnected by admin"
+	if reason != "" {
+		text += ": " + reason
+	}
+	h.forceDisconnect(client, closeReasonAdmin, text, map[string]interface{}{
+		"by":     by,
+		"reason": reason,
+	})
+	return h.UnregisterClient(client)
+}
+
+// forceDisconnect closes a client's connection for good with a close
+// reason and close frame text, auditing detail. The caller unregisters it.
+func (h *Hub) forceDisconnect(client *Client, closeReason, text string, detail map[string]interface{}) {
+	// A stuck or rogue client must not come back through a resume
+	h.forgetResume(client)
+	client.setCloseReason(closeReason)
+
+	if len(text) > maxCloseText {
+		text = text[:maxCloseText]
+	}
+	if !client.Detached() {
+		client.closeConn(websocket.ClosePolicyViolation, text)
+	}
+
+	log.Printf("🔌 %s (%s, %s) disconnected: %s", client.username, client.Type(), client.connectionID, text)
+	detail["close_reason"] = closeReason
+	detail["client_type"] = client.Type()
+	h.auditEvent(client, AuditAdminDisconnect, detail)
+}
+
+// handleDisconnectClient disconnects the client a disconnect_client message
+// names, for admin clients
+func (h *Hub) handleDisconnectClient(sender *Client, rawMessage []byte) {
+	if !isAdminClient(sender) {
+		sender.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "not_permitted",
+			"message_type": "disconnect_client",
+		})
+		return
+	}
+
+	var request disconnectRequest
+	if err := json.Unmarshal(rawMessage, &request); err != nil {
+		return
+	}
+	if err := h.DisconnectClient(request.ConnectionID, sender.username, request.Reason); err == ErrConnectionNotFound {
+		sender.SendJSON(map[string]interface{}{
+			"type":                 "error",
+			"error":                "target_not_found",
+			"message_type":         "disconnect_client",
+			"target_connection_id": request.ConnectionID,
+		})
+		return
+	}
+	sender.SendJSON(map[string]interface{}{
+		"type":          "client_disconnected",
+		"connection_id": request.ConnectionID,
+	})
+}
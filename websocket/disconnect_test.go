@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestDisconnectClient tests that an admin disconnect removes the client
+// for good, even when it could otherwise resume
+func TestDisconnectClient(t *testing.T) {
+	hub := NewHub()
+	hub.SetResumeWindow(time.Minute)
+	auditor := &recordingAuditor{events: make(chan auditEntry, 10)}
+	hub.SetAuditRecorder(auditor)
+	go hub.Run()
+
+	robot := newTestClient(hub, ClientTypeVideo, "robot")
+	robot.SetConnectionID("conn_robot")
+	hub.issueResumeToken(robot, HandshakeResponse{ClientType: ClientTypeVideo})
+
+	if err := hub.DisconnectClient("conn_missing", "admin", ""); err != ErrConnectionNotFound {
+		t.Errorf("Expected ErrConnectionNotFound, got %v", err)
+	}
+
+	if err := hub.DisconnectClient("conn_robot", "admin", "stuck"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry := auditor.next(t)
+	if entry.action != AuditAdminDisconnect || entry.target != "conn_robot" || entry.detail["by"] != "admin" || entry.detail["reason"] != "stuck" {
+		t.Errorf("Unexpected audit event %+v", entry)
+	}
+	waitFor(t, "the client to be removed", func() bool { return hub.GetClientCount() == 0 })
+	if reason := robot.CloseReason(); reason != closeReasonAdmin {
+		t.Errorf("Expected close reason %q, got %q", closeReasonAdmin, reason)
+	}
+}
+
+// TestDisconnectClientMessage tests the admin-only disconnect_client message
+func TestDisconnectClientMessage(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	admin := newTestClient(hub, ClientTypeWeb, "admin")
+	admin.scopes = []string{ScopeAdmin}
+	viewer := newTestClient(hub, ClientTypeWeb, "viewer")
+	robot := newTestClient(hub, ClientTypeVideo, "robot")
+	robot.SetConnectionID("conn_robot")
+
+	request := []byte(`{"type":"disconnect_client","connection_id":"conn_robot"}`)
+	hub.RouteMessage(viewer, request)
+	if messages := drainMessages(viewer); len(messages) != 1 {
+		t.Fatalf("Expected a not_permitted error, got %d messages", len(messages))
+	} else {
+		var reply map[string]interface{}
+		json.Unmarshal(messages[0], &reply)
+		if reply["error"] != "not_permitted" {
+			t.Errorf("Expected not_permitted, got %v", reply)
+		}
+	}
+	if hub.clientByConnection("conn_robot") != robot {
+		t.Fatal("Expected a viewer not to disconnect the robot")
+	}
+
+	hub.RouteMessage(admin, request)
+	if types := messageTypes(admin); !reflect.DeepEqual(types, []string{"client_disconnected"}) {
+		t.Errorf("Expected client_disconnected, got %v", types)
+	}
+	waitFor(t, "the robot to be removed", func() bool { return hub.clientByConnection("conn_robot") == nil })
+
+	hub.RouteMessage(admin, request)
+	if types := messageTypes(admin); !reflect.DeepEqual(types, []string{"error"}) {
+		t.Errorf("Expected target_not_found, got %v", types)
+	}
+}
+
+// userBan bans a user ID in tests
+type userBan int64
+
+func (b userBan) Covers(userID int64, remoteAddr string) bool {
+	return userID == int64(b)
+}
+
+// TestDisconnectBanned tests that adding a ban closes the connections it
+// covers
+func TestDisconnectBanned(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	banned := newTestClient(hub, ClientTypeWeb, "mallory")
+	banned.userID = 7
+	video := newTestClient(hub, ClientTypeVideo, "mallory")
+	video.userID = 7
+	other := newTestClient(hub, ClientTypeWeb, "alice")
+	other.userID = 8
+
+	if count := hub.DisconnectBanned(userBan(7), "admin", "abuse"); count != 2 {
+		t.Errorf("Expected 2 clients disconnected, got %d", count)
+	}
+	waitFor(t, "the banned clients to be removed", func() bool { return hub.GetClientCount() == 1 })
+	if banned.CloseReason() != closeReasonBanned || other.CloseReason() != "" {
+		t.Errorf("Unexpected close reasons %q and %q", banned.CloseReason(), other.CloseReason())
+	}
+}
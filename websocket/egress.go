@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EgressLimits caps the bytes per second written to each client of a type,
+// so a chatty feed cannot saturate an uplink shared with WebRTC media;
+// unlisted types and 0 are unlimited. Emergency stops and pings are never
+// throttled.
+type EgressLimits map[ClientType]int
+
+// egressThrottle holds the configured limits and how often they delayed a
+// write
+type egressThrottle struct {
+	limits    EgressLimits
+	throttled atomic.Int64
+	delayed   atomic.Int64 // Nanoseconds writes waited in total
+}
+
+// SetEgressLimits throttles what the server writes to clients of each
+// type. Call before Run.
+func (h *Hub) SetEgressLimits(limits EgressLimits) {
+	h.egress.limits = limits
+}
+
+// EgressLimits returns the configured per-type limits, without unlimited
+// types
+func (h *Hub) EgressLimits() EgressLimits {
+	limits := make(EgressLimits)
+	for clientType, perSecond := range h.egress.limits {
+		if perSecond > 0 {
+			limits[clientType] = perSecond
+		}
+	}
+	return limits
+}
+
+// egressStats returns how often and how long writes were throttled
+func (h *Hub) egressStats() map[string]interface{} {
+	return map[string]interface{}{
+		"throttled":  h.egress.throttled.Load(),
+		"delayed_ms": time.Duration(h.egress.delayed.Load()).Milliseconds(),
+	}
+}
+
+// egressBucket tracks one client's byte budget: it refills at the limit up
+// to one second's worth and may go into debt by one frame, which the next
+// write waits to repay. It is only used from the client's writePump, so it
+// needs no lock.
+type egressBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// wait refills the bucket and returns how long until it is out of debt
+func (b *egressBucket) wait(perSecond int, now time.Time) time.Duration {
+	rate := float64(perSecond)
+	if b.last.IsZero() {
+		b.tokens = rate
+	} else if b.tokens += now.Sub(b.last).Seconds() * rate; b.tokens > rate {
+		b.tokens = rate
+	}
+	b.last = now
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// forgive clears the bucket's debt
+func (b *egressBucket) forgive() {
+	if b.tokens < 0 {
+		b.tokens = 0
+	}
+}
+
+// spend takes the bytes of a written frame from the bucket
+func (b *egressBucket) spend(n int) {
+	b.tokens -= float64(n)
+}
+
+// egressLimit returns the client's limit in bytes per second (0 for none)
+func (c *Client) egressLimit() int {
+	return c.hub.egress.limits[c.Type()]
+}
+
+// maxEgressWait bounds one throttled wait to half the ping interval, so a
+// frame far larger than the limit cannot hold up the connection; the debt
+// left after it is forgiven
+func (c *Client) maxEgressWait() time.Duration {
+	return c.hub.pingInterval() / 2
+}
+
+// throttleEgress waits until the client's egress limit allows another
+// frame, writing emergency stops and pings that come due meanwhile, and
+// reports whether the connection is still writable
+func (c *Client) throttleEgress(pings <-chan time.Time) bool {
+	limit := c.egressLimit()
+	if limit <= 0 {
+		return true
+	}
+	wait := c.egress.wait(limit, time.Now())
+	if wait <= 0 {
+		return true
+	}
+	capped := wait > c.maxEgressWait()
+	if capped {
+		wait = c.maxEgressWait()
+	}
+
+	c.hub.egress.throttled.Add(1)
+	c.hub.egress.delayed.Add(int64(wait))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case message := <-c.priority:
+			if err := c.writePriority(message); err != nil {
+				c.keepUnsent(message)
+				return false
+			}
+		case <-pings:
+			if err := c.writePing(); err != nil {
+				return false
+			}
+		case <-timer.C:
+			c.egress.wait(limit, time.Now())
+			if capped {
+				c.egress.forgive()
+			}
+			return true
+		}
+	}
+}
+
+// egressAvailable reports whether the client's budget still allows adding
+// to the current frame
+func (c *Client) egressAvailable() bool {
+	return c.egressLimit() <= 0 || c.egress.tokens > 0
+}
@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestEgressBucket tests that the byte budget refills at the limit and
+// makes writes wait out their debt
+func TestEgressBucket(t *testing.T) {
+	var bucket egressBucket
+	now := time.Now()
+	if wait := bucket.wait(1000, now); wait != 0 {
+		t.Fatalf("Expected a full bucket to start, waited %v", wait)
+	}
+	bucket.spend(1500)
+	if wait := bucket.wait(1000, now); wait != 500*time.Millisecond {
+		t.Errorf("Expected to wait 500ms to repay 500 bytes, got %v", wait)
+	}
+	if wait := bucket.wait(1000, now.Add(500*time.Millisecond)); wait != 0 {
+		t.Errorf("Expected the debt repaid after 500ms, got %v", wait)
+	}
+
+	// Idle time refills at most one second's worth
+	if bucket.wait(1000, now.Add(time.Hour)); bucket.tokens != 1000 {
+		t.Errorf("Expected the bucket capped at 1000 bytes, got %v", bucket.tokens)
+	}
+}
+
+// TestWritePumpEgressLimit tests that the write pump throttles a limited
+// client type but still sends emergency stops right away
+func TestWritePumpEgressLimit(t *testing.T) {
+	hub := NewHub()
+	hub.SetEgressLimits(EgressLimits{ClientTypeTelemetry: 100})
+	message := []byte(`{"type":"location_update","data":{"lat":37.56,"lng":126.97,"alt":42.0}}`)
+
+	ready := make(chan *Client, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(hub, conn, ClientTypeTelemetry, 1, "feed", 4096)
+		for i := 0; i < 3; i++ {
+			client.enqueue(outbound{data: message})
+		}
+		go client.writePump(func() { ready <- client })
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	client := <-ready
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// The first frame spends the budget; the rest waits
+	if _, frame, err := conn.ReadMessage(); err != nil || strings.Count(string(frame), "location_update") != 2 {
+		t.Fatalf("Expected a first frame of two messages, got %q (%v)", frame, err)
+	}
+	client.enqueuePriority(outbound{data: []byte(`{"type":"emergency_stop"}`)})
+	if _, frame, err := conn.ReadMessage(); err != nil || string(frame) != `{"type":"emergency_stop"}` {
+		t.Fatalf("Expected the emergency stop during the wait, got %q (%v)", frame, err)
+	}
+	if _, frame, err := conn.ReadMessage(); err != nil || string(frame) != string(message) {
+		t.Fatalf("Expected the last message after the wait, got %q (%v)", frame, err)
+	}
+	if stats := hub.egressStats(); stats["throttled"] != int64(1) {
+		t.Errorf("Expected one throttled write, got %v", stats)
+	}
+}
+
+// TestWritePumpEgressPings tests that a client far over its limit is still
+// pinged and that one wait is capped below the ping interval
+func TestWritePumpEgressPings(t *testing.T) {
+	hub := NewHub()
+	hub.SetStaleTimeout(300 * time.Millisecond) // Pings every 100ms
+	hub.SetEgressLimits(EgressLimits{ClientTypeTelemetry: 1})
+	message := []byte(`{"type":"location_update","data":{"lat":37.56,"lng":126.97,"alt":42.0}}`)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(hub, conn, ClientTypeTelemetry, 1, "feed", 4096)
+		go client.writePump(func() {})
+		go func() {
+			for i := 0; i < 10; i++ {
+				client.enqueue(outbound{data: message})
+				time.Sleep(20 * time.Millisecond)
+			}
+		}()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	pings := 0
+	conn.SetPingHandler(func(string) error {
+		pings++
+		return nil
+	})
+
+	// At 1 byte per second each message would wait over a minute
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	received := 0
+	for received < 10 {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Expected 10 messages within the capped waits, got %d (%v)", received, err)
+		}
+		received += strings.Count(string(frame), "location_update")
+	}
+	if pings < 2 {
+		t.Errorf("Expected pings during the throttled writes, got %d", pings)
+	}
+}
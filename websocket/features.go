@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"sort"
+)
+
+// ProtocolVersion is the handshake protocol version the server speaks.
+// Clients that send no protocol_version, like the Python clients, speak
+// version 1 and keep every feature the server had before negotiation.
+const ProtocolVersion = 2
+
+// Protocol features negotiated at handshake (version 2)
+const (
+	// FeatureCompression compresses frames to the client with
+	// permessage-deflate, when offered at upgrade (see compression.go)
+	FeatureCompression = "compression"
+
+	// FeatureBinary allows the msgpack and protobuf encodings
+	FeatureBinary = "binary"
+
+	// FeatureAck acknowledges each control_command ID with one correlated
+	// control_response (see correlation.go)
+	FeatureAck = "ack"
+
+	// FeatureResume issues a resume token for reconnecting (see resume.go)
+	FeatureResume = "resume"
+
+	// FeatureSequence numbers queued messages per connection and reports
+	// those a full send queue dropped with gap_detected (see sequence.go)
+	FeatureSequence = "sequence"
+)
+
+// negotiation is the protocol version and features agreed with a client
+type negotiation struct {
+	version  int
+	features map[string]bool
+}
+
+// offeredFeatures returns the features the server supports for a client,
+// sorted. Compression is offered when the client offered permessage-deflate;
+// its frames are still only compressed for the configured client types.
+func (h *Hub) offeredFeatures(client *Client) []string {
+	features := []string{FeatureAck, FeatureBinary, FeatureSequence}
+	if client.deflate && h.compressionEnabled() {
+		features = append(features, FeatureCompression)
+	}
+	if h.resume.window > 0 {
+		features = append(features, FeatureResume)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// negotiate agrees on the protocol version and features with a client from
+// its handshake and stores the result on the client. A version 1 client
+// gets no negotiation; it keeps the legacy behaviour.
+func (h *Hub) negotiate(client *Client, handshake *HandshakeResponse) {
+	if handshake.ProtocolVersion < 2 {
+		client.negotiated.Store(nil)
+		return
+	}
+
+	agreed := &negotiation{
+		version:  min(handshake.ProtocolVersion, ProtocolVersion),
+		features: make(map[string]bool),
+	}
+	offered := make(map[string]bool)
+	for _, feature := range h.offeredFeatures(client) {
+		offered[feature] = true
+	}
+	// Features the server does not know or offer are ignored
+	for _, feature := range handshake.Features {
+		if offered[feature] {
+			agreed.features[feature] = true
+		}
+	}
+	client.negotiated.Store(agreed)
+}
+
+// ProtocolVersion returns the protocol version agreed at handshake (1 for
+// legacy clients)
+func (c *Client) ProtocolVersion() int {
+	if agreed := c.negotiated.Load(); agreed != nil {
+		return agreed.version
+	}
+	return 1
+}
+
+// HasFeature reports whether a feature was agreed at handshake. Version 1
+// clients have every feature the server offers them.
+func (c *Client) HasFeature(feature string) bool {
+	if agreed := c.negotiated.Load(); agreed != nil {
+		return agreed.features[feature]
+	}
+	return true
+}
+
+// Features returns the features agreed at handshake, sorted (nil for
+// version 1 clients)
+func (c *Client) Features() []string {
+	agreed := c.negotiated.Load()
+	if agreed == nil {
+		return nil
+	}
+	features := make([]string, 0, len(agreed.features))
+	for feature := range agreed.features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// binaryEncoding reports whether an encoding needs FeatureBinary
+func binaryEncoding(encoding string) bool {
+	return encoding == EncodingMsgpack || encoding == EncodingProtobuf
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestNegotiateFeatures tests that a version 2 client gets the features
+// both sides support and a legacy client keeps every feature
+func TestNegotiateFeatures(t *testing.T) {
+	hub := NewHub()
+	hub.SetResumeWindow(time.Minute)
+	go hub.Run()
+
+	client := newTestClient(hub, ClientTypePending, "alice")
+	client.SetConnectionID("conn_1")
+	hub.RouteMessage(client, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"web",`+
+		`"protocol_version":3,"features":["resume","compression","teleport"]}`))
+
+	var established map[string]interface{}
+	for _, raw := range drainMessages(client) {
+		var msg map[string]interface{}
+		json.Unmarshal(raw, &msg)
+		if msg["type"] == "connection_established" {
+			established = msg
+		}
+	}
+	if established == nil {
+		t.Fatal("Expected connection_established")
+	}
+	// Compression was not offered at upgrade, teleport does not exist
+	if established["protocol_version"] != float64(ProtocolVersion) ||
+		!reflect.DeepEqual(established["features"], []interface{}{FeatureResume}) {
+		t.Errorf("Expected version %d with resume only, got %v %v",
+			ProtocolVersion, established["protocol_version"], established["features"])
+	}
+	if established["resume_token"] == nil {
+		t.Error("Expected a resume token for the negotiated resume feature")
+	}
+
+	legacy := newTestClient(hub, ClientTypePending, "bob")
+	if legacy.ProtocolVersion() != 1 || !legacy.HasFeature(FeatureBinary) || legacy.Features() != nil {
+		t.Error("Expected a legacy client to keep every feature without negotiation")
+	}
+}
+
+// TestNegotiateBinary tests that a version 2 client must negotiate binary
+// before choosing a binary encoding
+func TestNegotiateBinary(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	client := newTestClient(hub, ClientTypePending, "alice")
+	client.SetConnectionID("conn_1")
+	hub.RouteMessage(client, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"web",`+
+		`"protocol_version":2,"encoding":"msgpack"}`))
+	if client.IsHandshakeComplete() {
+		t.Error("Expected msgpack without the binary feature to be rejected")
+	}
+
+	hub.RouteMessage(client, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"web",`+
+		`"protocol_version":2,"features":["binary"],"encoding":"msgpack"}`))
+	if !client.IsHandshakeComplete() || client.frameEncoding() != EncodingMsgpack {
+		t.Error("Expected msgpack with the binary feature to be accepted")
+	}
+}
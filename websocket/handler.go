@@ -1,23 +1,36 @@
 package websocket
 
 import (
-	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"oculo-pilot-server/logging"
+	"oculo-pilot-server/middleware"
 	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// Subprotocol is echoed to clients that offer it. Browsers, which cannot
+// set headers on WebSocket requests, offer it alongside a "bearer.<token>"
+// entry carrying the token; the token itself is never echoed.
+const Subprotocol = "oculo-pilot"
+
+// subprotocolTokenPrefix marks the Sec-WebSocket-Protocol entry carrying
+// the token
+const subprotocolTokenPrefix = "bearer."
+
+// defaultHandshakeResend is how often an unanswered handshake_request is
+// sent again
+const defaultHandshakeResend = 2 * time.Second
+
+// upgrader is copied per request to apply the handler's origin policy and
+// compression
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// TODO: Implement proper origin checking based on config
-		return true
-	},
+	Subprotocols:    []string{Subprotocol},
 }
 
 // Handler handles WebSocket upgrade requests
@@ -25,8 +38,17 @@ type Handler struct {
 	hub              *Hub
 	auth             AuthValidator
 	allowedNetworks  []*net.IPNet
+	allowedHosts     *HostAllowlist
+	peerVerifier     PeerVerifier
+	authFailures     AuthFailureRecorder
+	sessionCookie    string
+	allowedOrigins   []originPattern
+	anyOrigin        bool
+	certAuth         bool
 	enableWhitelist  bool
 	handshakeTimeout time.Duration
+	handshakeResend  time.Duration
+	idProvider       IDProvider
 	maxMessageSize   int64
 }
 
@@ -35,50 +57,156 @@ type AuthValidator interface {
 	ValidateToken(token string) (userID int64, username string, err error)
 }
 
+// PeerVerifier checks the transport-level peer address, e.g. against the
+// allowed IPs of a WireGuard interface
+type PeerVerifier interface {
+	Allowed(ip net.IP) bool
+}
+
+// AuthFailureRecorder counts failed token validations per source IP, e.g.
+// to ban IPs that keep failing
+type AuthFailureRecorder interface {
+	RecordFailure(ip string) bool
+	RecordSuccess(ip string)
+}
+
 // NewHandler creates a new WebSocket handler
 func NewHandler(hub *Hub, auth AuthValidator, allowedNetworks []string, enableWhitelist bool, handshakeTimeout time.Duration, maxMessageSize int64) *Handler {
 	// Parse CIDR networks
 	var networks []*net.IPNet
+	var hostnames []string
 	if enableWhitelist {
 		for _, cidr := range allowedNetworks {
-			_, network, err := net.ParseCIDR(cidr)
+			if isHostname(cidr) {
+				hostnames = append(hostnames, cidr)
+				continue
+			}
+			network, err := parseNetwork(strings.TrimSpace(cidr))
 			if err != nil {
 				log.Printf("⚠️  Invalid CIDR notation '%s': %v", cidr, err)
 				continue
 			}
 			networks = append(networks, network)
 		}
-		log.Printf("🔒 IP whitelist enabled with %d networks", len(networks))
+		log.Printf("🔒 IP whitelist enabled with %d networks and %d hostnames", len(networks), len(hostnames))
 	} else {
 		log.Printf("ℹ️  IP whitelist disabled - accepting all connections")
 	}
 
-	return &Handler{
+	handler := &Handler{
 		hub:              hub,
 		auth:             auth,
 		allowedNetworks:  networks,
 		enableWhitelist:  enableWhitelist,
 		handshakeTimeout: handshakeTimeout,
+		handshakeResend:  defaultHandshakeResend,
+		idProvider:       UUIDProvider{},
 		maxMessageSize:   maxMessageSize,
 	}
+	if len(hostnames) > 0 {
+		handler.allowedHosts = NewHostAllowlist(hostnames)
+	}
+
+	return handler
 }
 
-// isIPAllowed checks if the client IP is in the allowed networks
-func (h *Handler) isIPAllowed(remoteAddr string) bool {
-	if !h.enableWhitelist {
+// SetHandshakeResend sets how often the handshake_request is sent again
+// while a client has not answered it, for clients that missed the first
+// one (0 sends it once). Call before serving.
+func (h *Handler) SetHandshakeResend(d time.Duration) {
+	h.handshakeResend = d
+}
+
+// SetIDProvider replaces the random UUIDs used as connection IDs. Call
+// before serving.
+func (h *Handler) SetIDProvider(provider IDProvider) {
+	h.idProvider = provider
+}
+
+// SetPeerVerifier rejects connections whose socket peer address (not
+// X-Forwarded-For) is not accepted by the verifier
+func (h *Handler) SetPeerVerifier(verifier PeerVerifier) {
+	h.peerVerifier = verifier
+}
+
+// SetAuthFailureRecorder reports token validation results by source IP
+func (h *Handler) SetAuthFailureRecorder(recorder AuthFailureRecorder) {
+	h.authFailures = recorder
+}
+
+// SetSessionCookie accepts the token from the named session cookie when a
+// request carries no other credentials
+func (h *Handler) SetSessionCookie(name string) {
+	h.sessionCookie = name
+}
+
+// recordAuthResult reports a token validation result for the client address
+func (h *Handler) recordAuthResult(remoteAddr string, ok bool) {
+	if h.authFailures == nil {
+		return
+	}
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if ok {
+		h.authFailures.RecordSuccess(ip)
+	} else {
+		h.authFailures.RecordFailure(ip)
+	}
+}
+
+// isPeerAllowed checks the socket peer address against the peer verifier
+func (h *Handler) isPeerAllowed(remoteAddr string) bool {
+	if h.peerVerifier == nil {
 		return true
 	}
 
-	// Extract IP from address (remove port)
 	host, _, err := net.SplitHostPort(remoteAddr)
 	if err != nil {
-		// If no port, use the address as-is
 		host = remoteAddr
 	}
 
 	ip := net.ParseIP(host)
+	return ip != nil && h.peerVerifier.Allowed(ip)
+}
+
+// StartHostRefresh resolves allowlisted hostnames and keeps them refreshed
+// in the background, re-resolving each one when its DNS TTL expires
+// (bounded by minRefresh and maxRefresh). It does nothing when the
+// whitelist contains no hostnames.
+func (h *Handler) StartHostRefresh(minRefresh, maxRefresh time.Duration) {
+	if h.allowedHosts == nil {
+		return
+	}
+
+	if minRefresh > 0 {
+		h.allowedHosts.minRefresh = minRefresh
+	}
+	if maxRefresh >= h.allowedHosts.minRefresh {
+		h.allowedHosts.maxRefresh = maxRefresh
+	}
+
+	h.allowedHosts.Refresh(time.Now())
+	go h.allowedHosts.Run()
+}
+
+// Stop stops background work started by the handler
+func (h *Handler) Stop() {
+	if h.allowedHosts != nil {
+		h.allowedHosts.Stop()
+	}
+}
+
+// isIPAllowed checks if the client IP is in the allowed networks
+func (h *Handler) isIPAllowed(remoteAddr string) bool {
+	if !h.enableWhitelist {
+		return true
+	}
+
+	ip := parseClientIP(remoteAddr)
 	if ip == nil {
-		log.Printf("⚠️  Failed to parse IP address: %s", host)
+		log.Printf("⚠️  Failed to parse IP address: %s", remoteAddr)
 		return false
 	}
 
@@ -89,115 +217,273 @@ func (h *Handler) isIPAllowed(remoteAddr string) bool {
 		}
 	}
 
+	// Check against resolved hostnames
+	if h.allowedHosts != nil && h.allowedHosts.Contains(ip) {
+		return true
+	}
+
 	return false
 }
 
 // ServeHTTP upgrades HTTP connection to WebSocket
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	remoteAddr := r.RemoteAddr
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		// Use first IP from X-Forwarded-For header
-		ips := strings.Split(forwarded, ",")
-		if len(ips) > 0 {
-			remoteAddr = strings.TrimSpace(ips[0])
-		}
-	}
+	// X-Forwarded-For only counts when it comes from a trusted proxy
+	remoteAddr := middleware.ClientIP(r)
 
 	log.Printf("🔌 Connection attempt from %s", remoteAddr)
 
 	// Check IP whitelist
 	if !h.isIPAllowed(remoteAddr) {
-		log.Printf("🚫 IP blocked by whitelist: %s", remoteAddr)
+		logging.Sampled("ws_ip_blocked", "🚫 IP blocked by whitelist: %s", remoteAddr)
+		h.hub.PublishSecurityEvent(SecurityIPBlocked, map[string]interface{}{
+			"remote_addr": remoteAddr,
+			"reason":      "whitelist",
+		})
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	// Get token from query parameter or header
-	token := r.URL.Query().Get("token")
-	if token == "" {
-		token = r.Header.Get("Authorization")
-		if len(token) > 7 && token[:7] == "Bearer " {
-			token = token[7:]
-		}
+	// Check tunnel peer
+	if !h.isPeerAllowed(r.RemoteAddr) {
+		logging.Sampled("ws_peer_blocked", "🚫 Peer not allowed by tunnel: %s", r.RemoteAddr)
+		h.hub.PublishSecurityEvent(SecurityIPBlocked, map[string]interface{}{
+			"remote_addr": r.RemoteAddr,
+			"reason":      "tunnel_peer",
+		})
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
 	}
 
-	// Validate token
-	if token == "" {
-		log.Printf("❌ Missing auth token from %s", remoteAddr)
-		http.Error(w, "Missing authentication token", http.StatusUnauthorized)
+	// Check browser origin before looking at credentials, which a
+	// cross-site page would be borrowing
+	if !h.checkOrigin(r) {
+		origin := r.Header.Get("Origin")
+		logging.Sampled("ws_origin_rejected", "🚫 Origin not allowed: %q from %s", origin, remoteAddr)
+		h.hub.PublishSecurityEvent(SecurityOriginRejected, map[string]interface{}{
+			"remote_addr": remoteAddr,
+			"origin":      origin,
+		})
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
 		return
 	}
 
-	userID, username, err := h.auth.ValidateToken(token)
-	if err != nil {
-		log.Printf("❌ Invalid auth token from %s: %v", remoteAddr, err)
-		http.Error(w, "Invalid authentication token", http.StatusUnauthorized)
+	var (
+		userID   int64
+		username string
+		scopes   []string
+		binding  TokenBinding
+		err      error
+	)
+
+	if device, deviceScopes, ok := h.deviceAuth(r.TLS); ok {
+		// Verified client certificate replaces the JWT
+		username, scopes = device, deviceScopes
+		log.Printf("✅ Certificate authentication successful: %s from %s", username, remoteAddr)
+	} else {
+		userID, username, scopes, binding, err = h.authenticate(r)
+		if err == errMissingCredentials {
+			logging.Sampled("ws_missing_token", "❌ Missing auth token from %s", remoteAddr)
+			http.Error(w, "Missing authentication token", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			logging.Sampled("ws_invalid_token", "❌ Invalid auth token from %s: %v", remoteAddr, err)
+			h.recordAuthResult(remoteAddr, false)
+			h.hub.PublishSecurityEvent(SecurityTokenRejected, map[string]interface{}{
+				"remote_addr": remoteAddr,
+			})
+			http.Error(w, "Invalid authentication token", http.StatusUnauthorized)
+			return
+		}
+		h.recordAuthResult(remoteAddr, true)
+
+		log.Printf("✅ Authentication successful: user=%s (id=%d) from %s", username, userID, remoteAddr)
+	}
+
+	if !h.isSourceAllowed(userID, username, remoteAddr) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+	if !h.isNotBanned(userID, username, remoteAddr) {
+		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	log.Printf("✅ Authentication successful: user=%s (id=%d) from %s", username, userID, remoteAddr)
+	if scopes != nil && !hasScope(scopes, ScopeView) && !hasScope(scopes, ScopeControl) {
+		logging.Sampled("ws_insufficient_scope", "🚫 Token for %s has no WebSocket scope", username)
+		http.Error(w, "Insufficient scope", http.StatusForbidden)
+		return
+	}
 
-	// Upgrade connection
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// Upgrade connection, negotiating permessage-deflate if any client type
+	// is compressed
+	wsUpgrader := upgrader
+	wsUpgrader.CheckOrigin = h.checkOrigin
+	wsUpgrader.EnableCompression = h.hub.compressionEnabled()
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("❌ WebSocket upgrade failed for %s: %v", username, err)
 		return
 	}
+	if wsUpgrader.EnableCompression {
+		conn.SetCompressionLevel(h.hub.compressionLevel())
+	}
 
 	log.Printf("🔄 WebSocket upgraded for %s, waiting for handshake...", username)
 
 	// Create client with pending type (will be determined during handshake)
 	client := NewClient(h.hub, conn, ClientTypePending, userID, username, h.maxMessageSize)
+	client.SetScopes(scopes)
+	client.SetBinding(binding)
+	client.remoteAddr = remoteAddr
+	client.deflate = wsUpgrader.EnableCompression && offersDeflate(r)
 
 	// Generate unique connection ID for this handshake
-	connectionID := generateConnectionID(r.RemoteAddr)
+	connectionID, err := h.idProvider.NewConnectionID()
+	if err != nil {
+		log.Printf("❌ Rejecting connection from %s: %v", username, err)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "internal error"), time.Now().Add(writeWait))
+		conn.Close()
+		return
+	}
 	client.SetConnectionID(connectionID)
 
-	// Register client
-	h.hub.RegisterClient(client)
+	// Register client; a stalled hub rejects the connection instead of
+	// blocking this handler forever
+	if err := h.hub.RegisterClient(client); err != nil {
+		log.Printf("❌ Rejecting connection from %s: %v", username, err)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "server busy"), time.Now().Add(writeWait))
+		conn.Close()
+		return
+	}
 
-	// Start client's read/write pumps BEFORE sending handshake
+	// Start client's read/write pumps BEFORE sending handshake; Run returns
+	// once both are running
 	client.Run()
 
-	// Give goroutines a moment to start
-	time.Sleep(10 * time.Millisecond)
-
 	// Send handshake request (Python-compatible) after pumps are running
-	handshakeReq := map[string]interface{}{
-		"type":                   "handshake_request",
-		"connection_id":          connectionID,
-		"timestamp":              time.Now().Unix(),
-		"supported_client_types": []string{"web", "video", "control", "telemetry"},
-	}
-	if err := client.SendJSON(handshakeReq); err != nil {
+	if err := client.SendJSON(handshakeRequest(client, 1)); err != nil {
 		log.Printf("❌ Failed to send handshake request to %s: %v", username, err)
-		h.hub.UnregisterClient(client)
+		h.hub.dropClient(client, "handshake_send_failed")
 		return
 	}
 
 	log.Printf("📤 Handshake request sent to %s (connection_id=%s)", username, connectionID)
 
 	// Start handshake timeout monitoring
-	go h.monitorHandshakeTimeout(client, connectionID, username)
+	go h.monitorHandshake(client, connectionID, username)
 }
 
-// generateConnectionID creates a unique connection ID for handshake
-func generateConnectionID(remoteAddr string) string {
-	return fmt.Sprintf("%s_%d", remoteAddr, time.Now().UnixNano()/1000000)
+// authenticate validates the credentials of an upgrade request: a
+// one-time ticket, or a token from the query string, Authorization header,
+// Sec-WebSocket-Protocol or session cookie
+func (h *Handler) authenticate(r *http.Request) (userID int64, username string, scopes []string, binding TokenBinding, err error) {
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		redeemer, ok := h.auth.(TicketRedeemer)
+		if !ok {
+			return 0, "", nil, TokenBinding{}, errTicketsUnsupported
+		}
+		return redeemer.RedeemTicket(ticket)
+	}
+
+	// Get token from query parameter or header
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = r.Header.Get("Authorization")
+		if len(token) > 7 && token[:7] == "Bearer " {
+			token = token[7:]
+		}
+	}
+	if token == "" {
+		token = subprotocolToken(r)
+	}
+	if token == "" && h.sessionCookie != "" {
+		if cookie, err := r.Cookie(h.sessionCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+	if token == "" {
+		return 0, "", nil, TokenBinding{}, errMissingCredentials
+	}
+
+	if bound, ok := h.auth.(BoundAuthValidator); ok {
+		return bound.ValidateTokenBinding(token)
+	}
+	if scoped, ok := h.auth.(ScopedAuthValidator); ok {
+		userID, username, scopes, err = scoped.ValidateTokenScopes(token)
+		return userID, username, scopes, TokenBinding{}, err
+	}
+	userID, username, err = h.auth.ValidateToken(token)
+	return userID, username, nil, TokenBinding{}, err
 }
 
-// monitorHandshakeTimeout monitors handshake completion and closes connection if timeout occurs
-func (h *Handler) monitorHandshakeTimeout(client *Client, connectionID, username string) {
-	// Wait for handshake timeout
-	time.Sleep(h.handshakeTimeout)
+// subprotocolToken returns the token offered as a "bearer.<token>"
+// subprotocol ("" when there is none)
+func subprotocolToken(r *http.Request) string {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if strings.HasPrefix(protocol, subprotocolTokenPrefix) {
+			return strings.TrimPrefix(protocol, subprotocolTokenPrefix)
+		}
+	}
+	return ""
+}
 
-	// Check if handshake is complete
-	if !client.IsHandshakeComplete() {
-		log.Printf("⏱️ Handshake timeout for %s (connection_id=%s) after %v",
-			username, connectionID, h.handshakeTimeout)
-		// Unregister client - this will close the connection
-		h.hub.UnregisterClient(client)
-	} else {
-		log.Printf("✅ Handshake completed within timeout for %s", username)
+// handshakeRequest builds the handshake_request for a client, with the
+// protocol version and features it may negotiate; attempt counts
+// retransmissions, starting at 1
+func handshakeRequest(client *Client, attempt int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":                   "handshake_request",
+		"connection_id":          client.GetConnectionID(),
+		"timestamp":              time.Now().Unix(),
+		"attempt":                attempt,
+		"supported_client_types": []string{"web", "video", "control", "telemetry", "audio", "monitor"},
+		"protocol_version":       ProtocolVersion,
+		"features":               client.hub.offeredFeatures(client),
+	}
+}
+
+// monitorHandshake waits for the client's handshake, sending the request
+// again every handshakeResend, and drops the client if the handshake
+// timeout passes first. It returns as soon as the handshake completes or
+// the connection ends.
+func (h *Handler) monitorHandshake(client *Client, connectionID, username string) {
+	timeout := time.NewTimer(h.handshakeTimeout)
+	defer timeout.Stop()
+
+	var resend <-chan time.Time
+	if h.handshakeResend > 0 {
+		ticker := time.NewTicker(h.handshakeResend)
+		defer ticker.Stop()
+		resend = ticker.C
+	}
+
+	attempt := 1
+	for {
+		select {
+		case <-client.handshakeDone:
+			log.Printf("✅ Handshake completed within timeout for %s", username)
+			return
+		case <-client.readDone:
+			return
+		case <-resend:
+			attempt++
+			logging.Sampled("ws_handshake_resend", "🔁 Resending handshake request to %s (connection_id=%s, attempt %d)",
+				username, connectionID, attempt)
+			if err := client.SendJSON(handshakeRequest(client, attempt)); err != nil {
+				log.Printf("❌ Failed to resend handshake request to %s: %v", username, err)
+			}
+		case <-timeout.C:
+			if client.IsHandshakeComplete() {
+				return
+			}
+			log.Printf("⏱️ Handshake timeout for %s (connection_id=%s) after %v",
+				username, connectionID, h.handshakeTimeout)
+			// Unregister client - this will close the connection
+			h.hub.dropClient(client, "handshake_timeout")
+			return
+		}
 	}
 }
@@ -3,6 +3,7 @@ package websocket
 import (
 	"net/http"
 	"net/http/httptest"
+	"oculo-pilot-server/middleware"
 	"strings"
 	"testing"
 	"time"
@@ -279,7 +280,8 @@ func TestServeHTTPIPWhitelist(t *testing.T) {
 	}
 }
 
-// TestServeHTTPXForwardedFor tests X-Forwarded-For header handling
+// TestServeHTTPXForwardedFor tests that X-Forwarded-For is only used when
+// it comes from a trusted proxy
 func TestServeHTTPXForwardedFor(t *testing.T) {
 	hub := NewHub()
 	go hub.Run()
@@ -287,36 +289,52 @@ func TestServeHTTPXForwardedFor(t *testing.T) {
 
 	handler := NewHandler(hub, auth, []string{"192.168.1.0/24"}, true,
 		10*time.Second, 65536)
+	if err := middleware.SetTrustedProxies([]string{"172.16.0.0/12"}); err != nil {
+		t.Fatal(err)
+	}
+	defer middleware.SetTrustedProxies(nil)
 
 	tests := []struct {
-		name           string
-		remoteAddr     string
-		xForwardedFor  string
-		expectBlocked  bool
+		name          string
+		remoteAddr    string
+		xForwardedFor string
+		expectBlocked bool
 	}{
 		{
-			name:           "Use X-Forwarded-For - allowed",
-			remoteAddr:     "10.0.0.1:5678",
-			xForwardedFor:  "192.168.1.100",
-			expectBlocked:  false,
+			name:          "Forged X-Forwarded-For - blocked",
+			remoteAddr:    "10.0.0.1:5678",
+			xForwardedFor: "192.168.1.100",
+			expectBlocked: true,
+		},
+		{
+			name:          "Forged X-Forwarded-For - socket address used",
+			remoteAddr:    "192.168.1.100:5678",
+			xForwardedFor: "10.0.0.1",
+			expectBlocked: false,
+		},
+		{
+			name:          "Trusted proxy - allowed",
+			remoteAddr:    "172.16.0.2:5678",
+			xForwardedFor: "192.168.1.100",
+			expectBlocked: false,
 		},
 		{
-			name:           "Use X-Forwarded-For - blocked",
-			remoteAddr:     "192.168.1.100:5678",
-			xForwardedFor:  "10.0.0.1",
-			expectBlocked:  true,
+			name:          "Trusted proxy - blocked",
+			remoteAddr:    "172.16.0.2:5678",
+			xForwardedFor: "10.0.0.1",
+			expectBlocked: true,
 		},
 		{
-			name:           "Multiple IPs in X-Forwarded-For - use first",
-			remoteAddr:     "10.0.0.1:5678",
-			xForwardedFor:  "192.168.1.100, 10.0.0.2, 10.0.0.3",
-			expectBlocked:  false,
+			name:          "Trusted proxy - prepended entries ignored",
+			remoteAddr:    "172.16.0.2:5678",
+			xForwardedFor: "192.168.1.100, 10.0.0.2",
+			expectBlocked: true,
 		},
 		{
-			name:           "No X-Forwarded-For - use RemoteAddr",
-			remoteAddr:     "192.168.1.100:5678",
-			xForwardedFor:  "",
-			expectBlocked:  false,
+			name:          "No X-Forwarded-For - use RemoteAddr",
+			remoteAddr:    "192.168.1.100:5678",
+			xForwardedFor: "",
+			expectBlocked: false,
 		},
 	}
 
@@ -344,28 +362,23 @@ func TestServeHTTPXForwardedFor(t *testing.T) {
 	}
 }
 
-// TestGenerateConnectionID tests connection ID generation
-func TestGenerateConnectionID(t *testing.T) {
-	remoteAddr := "192.168.1.100:5678"
-
-	id1 := generateConnectionID(remoteAddr)
-	time.Sleep(2 * time.Millisecond)
-	id2 := generateConnectionID(remoteAddr)
+// TestUUIDProvider tests that connection IDs are unique version 4 UUIDs
+// that do not contain the peer address
+func TestUUIDProvider(t *testing.T) {
+	id1, err := UUIDProvider{}.NewConnectionID()
+	if err != nil {
+		t.Fatalf("NewConnectionID failed: %v", err)
+	}
+	id2, _ := UUIDProvider{}.NewConnectionID()
 
-	// IDs should be different (timestamp-based)
 	if id1 == id2 {
 		t.Error("Connection IDs should be unique")
 	}
 
-	// IDs should contain remote address
-	if !strings.Contains(id1, "192.168.1.100:5678") {
-		t.Errorf("Connection ID should contain remote address, got %s", id1)
-	}
-
-	// IDs should be in expected format (address_timestamp)
-	parts := strings.Split(id1, "_")
-	if len(parts) != 2 {
-		t.Errorf("Connection ID should have format 'address_timestamp', got %s", id1)
+	// IDs should be in UUID format (8-4-4-4-12 hex digits, version 4)
+	parts := strings.Split(id1, "-")
+	if len(id1) != 36 || len(parts) != 5 || len(parts[0]) != 8 || parts[2][0] != '4' {
+		t.Errorf("Connection ID should be a version 4 UUID, got %s", id1)
 	}
 }
 
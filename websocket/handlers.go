@@ -0,0 +1,350 @@
+package websocket
+
+import (
+	"log"
+	"oculo-pilot-server/logging"
+	"sort"
+)
+
+// HandlerFunc routes one message after the checks common to every type
+// (rate limits, schema, target)
+type HandlerFunc func(ctx *MessageContext)
+
+// MessageContext is a message being routed
+type MessageContext struct {
+	Sender  *Client
+	Message *Message
+
+	// Raw is the message as received
+	Raw []byte
+
+	target   routeTarget
+	rejected bool
+}
+
+// Reject marks the message as refused, e.g. because the sender is not
+// permitted. Duplicate suppression does not remember a refused message's
+// id, so the sender can retry it (see dedup.go).
+func (ctx *MessageContext) Reject() {
+	ctx.rejected = true
+}
+
+// registeredHandler is a handler and the client types allowed to send its
+// message type (empty allows any)
+type registeredHandler struct {
+	fn      HandlerFunc
+	senders []ClientType
+	route   RouteInfo
+}
+
+// RouteInfo describes where a handler delivers its message type, for the
+// routing table (see Routes)
+type RouteInfo struct {
+	// To are the client types in the sender's room it reaches; empty when
+	// the server handles the message itself
+	To []ClientType
+
+	// Scope the sender needs
+	Scope string
+
+	// CommandRules is set when messages are checked against the
+	// deployment's CommandRules
+	CommandRules bool
+}
+
+// RegisterHandler routes messages of a type with fn, replacing any built-in
+// handler for the type. With senders, messages of the type from other
+// client types are dropped. Call before Run.
+func (h *Hub) RegisterHandler(msgType string, fn HandlerFunc, senders ...ClientType) {
+	if h.handlers == nil {
+		h.handlers = make(map[string]registeredHandler)
+	}
+	h.handlers[msgType] = registeredHandler{fn: fn, senders: senders}
+}
+
+// DescribeRoute records where the handler of a message type delivers it.
+// RegisterHandler clears the description, so call it after registering.
+func (h *Hub) DescribeRoute(msgType string, route RouteInfo) {
+	handler, ok := h.handlers[msgType]
+	if !ok {
+		log.Printf("⚠️  No handler registered for %s, route not described", msgType)
+		return
+	}
+	handler.route = route
+	h.handlers[msgType] = handler
+}
+
+// MessageTypes returns the message types with a registered handler, sorted
+func (h *Hub) MessageTypes() []string {
+	types := make([]string, 0, len(h.handlers))
+	for msgType := range h.handlers {
+		types = append(types, msgType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Forward sends the message to the clients of types in the sender's room
+// that it addresses, on this and other instances, and returns how many
+// local clients of those types it reached. Message types with a topic (see
+// messageTopics) also reach the topic's other subscribers.
+func (h *Hub) Forward(ctx *MessageContext, types ...ClientType) int {
+	message := h.withTTL(ctx.Message.Type, ctx.Raw)
+	delivered := h.broadcastOutbound(ctx.Sender.Room(), ctx.target, types, message)
+	if topic, ok := messageTopics[ctx.Message.Type]; ok && len(types) > 0 {
+		h.publishTopic(ctx.Sender, topic, ctx.target, types, message)
+	}
+	return delivered
+}
+
+// dispatch runs the handler of the message's type, or routes unknown types
+// to the room
+func (h *Hub) dispatch(ctx *MessageContext) {
+	handler, ok := h.handlers[ctx.Message.Type]
+	if !ok {
+		h.routeUnknown(ctx)
+		return
+	}
+	if len(handler.senders) > 0 && !containsType(handler.senders, ctx.Sender.Type()) {
+		logging.Sampled("ws_handler_sender", "Ignored %s from client_type=%s user=%s",
+			ctx.Message.Type, ctx.Sender.Type(), ctx.Sender.username)
+		ctx.Reject()
+		return
+	}
+	handler.fn(ctx)
+}
+
+// registerBuiltinHandlers registers the message types the server routes
+// itself
+func (h *Hub) registerBuiltinHandlers() {
+	h.RegisterHandler("handshake_response", func(ctx *MessageContext) {
+		h.handleHandshake(ctx.Sender, ctx.Raw)
+	})
+	h.RegisterHandler("ping", func(ctx *MessageContext) {
+		h.handlePing(ctx.Sender, ctx.Raw)
+	})
+	h.RegisterHandler("pong", func(ctx *MessageContext) {
+		// Just log pong messages
+		log.Printf("Pong received from %s", ctx.Sender.Type())
+	})
+
+	// Control commands from web clients go to the control clients the
+	// sender may command
+	h.RegisterHandler("control_command", func(ctx *MessageContext) {
+		if !h.authorize(ctx.Sender, ctx.Message.Type, ScopeControl) {
+			ctx.Reject()
+			return
+		}
+		if !h.allowControl(ctx.Sender, ctx.Message.Type) {
+			ctx.Reject()
+			return
+		}
+		if !h.checkCommandRules(ctx.Sender, ctx.Message.Type, ctx.Raw) {
+			ctx.Reject()
+			return
+		}
+		if commandQoSLevel(ctx.Raw) >= QoSAtLeastOnce && correlationID(ctx.Raw, "id") == "" {
+			ctx.Sender.SendJSON(map[string]interface{}{
+				"type":         "error",
+				"error":        "invalid_message",
+				"message_type": ctx.Message.Type,
+				"field":        "id",
+				"reason":       "required for at-least-once delivery",
+			})
+			ctx.Reject()
+			return
+		}
+		delivered := h.routeControlCommand(ctx.Sender, ctx.Message.Type, ctx.Raw, ctx.target)
+		log.Printf("Routed control command to %d control clients", delivered)
+	}, ClientTypeWeb)
+	h.DescribeRoute("control_command", RouteInfo{To: []ClientType{ClientTypeControl}, Scope: ScopeControl, CommandRules: true})
+
+	// Control responses from control clients go back to web clients, once
+	// per robot and command
+	h.RegisterHandler("control_response", func(ctx *MessageContext) {
+		// A response also acknowledges an at-least-once command
+		h.ackCommand(ctx.Sender, correlationID(ctx.Raw, "correlation_id"))
+		if !h.trackResponse(ctx.Sender, ctx.Raw, false) {
+			return
+		}
+		delivered := h.Forward(ctx, ClientTypeWeb)
+		log.Printf("Routed control response to %d web clients", delivered)
+	}, ClientTypeControl)
+	h.DescribeRoute("control_response", RouteInfo{To: []ClientType{ClientTypeWeb}})
+
+	// Robots refuse commands with a NACK, which reaches web clients like
+	// a response
+	h.RegisterHandler("command_nack", func(ctx *MessageContext) {
+		id := correlationID(ctx.Raw, "correlation_id")
+		h.ackCommand(ctx.Sender, id)
+		if !h.trackResponse(ctx.Sender, ctx.Raw, true) {
+			return
+		}
+		delivered := h.Forward(ctx, ClientTypeWeb)
+		log.Printf("Routed command NACK %s to %d web clients", id, delivered)
+	}, ClientTypeControl)
+	h.DescribeRoute("command_nack", RouteInfo{To: []ClientType{ClientTypeWeb}})
+
+	// Robots acknowledge at-least-once commands (see qos.go)
+	h.RegisterHandler("command_ack", func(ctx *MessageContext) {
+		h.ackCommand(ctx.Sender, correlationID(ctx.Raw, "id"))
+	}, ClientTypeControl)
+
+	// WebRTC signaling
+	for _, msgType := range []string{"offer", "answer", "ice-candidate"} {
+		h.RegisterHandler(msgType, func(ctx *MessageContext) {
+			h.handleWebRTCSignaling(ctx.Sender, ctx.Message.Type, ctx.Raw, ctx.target)
+		})
+		// From web clients to video and audio clients, and back
+		h.DescribeRoute(msgType, RouteInfo{To: []ClientType{ClientTypeWeb, ClientTypeVideo, ClientTypeAudio}})
+	}
+
+	// Audio and video clients are ready, notify web clients in their room
+	h.RegisterHandler("audio_client_ready", func(ctx *MessageContext) {
+		delivered := h.Forward(ctx, ClientTypeWeb)
+		log.Printf("Notified %d web clients that audio is ready", delivered)
+	})
+	h.RegisterHandler("video_client_ready", func(ctx *MessageContext) {
+		delivered := h.Forward(ctx, ClientTypeWeb)
+		log.Printf("Notified %d web clients that video is ready", delivered)
+	})
+	h.DescribeRoute("audio_client_ready", RouteInfo{To: []ClientType{ClientTypeWeb}})
+	h.DescribeRoute("video_client_ready", RouteInfo{To: []ClientType{ClientTypeWeb}})
+
+	// Emergency stop broadcasts to all control clients in the room,
+	// whatever robot it names
+	h.RegisterHandler("emergency_stop", func(ctx *MessageContext) {
+		if !h.authorize(ctx.Sender, ctx.Message.Type, ScopeControl) {
+			ctx.Reject()
+			return
+		}
+		h.setEmergencyStop(true, ctx.Sender.username, ctx.Sender.Room())
+		h.PublishSecurityEvent(SecurityEmergencyStop, map[string]interface{}{
+			"username": ctx.Sender.username,
+			"room":     RoomID(ctx.Sender.Room()),
+		})
+		delivered := h.broadcastEmergency(ctx.Sender.Room(), ctx.Raw)
+		log.Printf("🚨 Emergency stop broadcast to %d control clients", delivered)
+		if delivered == 0 {
+			h.deadLetter(ctx.Sender, ctx.Message.Type, ctx.Raw, routeTarget{}, ClientTypeControl)
+		}
+		h.publishEdge(ctx.Sender, ctx.Message.Type, ctx.Raw, routeTarget{})
+	})
+
+	// Reset emergency stop state - broadcast to control clients in the room
+	h.RegisterHandler("emergency_stop_reset", func(ctx *MessageContext) {
+		if !h.authorize(ctx.Sender, ctx.Message.Type, ScopeControl) {
+			ctx.Reject()
+			return
+		}
+		h.setEmergencyStop(false, ctx.Sender.username, ctx.Sender.Room())
+		h.PublishSecurityEvent(SecurityEmergencyStopReset, map[string]interface{}{
+			"username": ctx.Sender.username,
+			"room":     RoomID(ctx.Sender.Room()),
+		})
+		delivered := h.broadcastEmergency(ctx.Sender.Room(), ctx.Raw)
+		log.Printf("🔄 Emergency stop reset broadcast to %d control clients", delivered)
+		if delivered == 0 {
+			h.deadLetter(ctx.Sender, ctx.Message.Type, ctx.Raw, routeTarget{}, ClientTypeControl)
+		}
+		h.publishEdge(ctx.Sender, ctx.Message.Type, ctx.Raw, routeTarget{})
+	})
+	for _, msgType := range []string{"emergency_stop", "emergency_stop_reset"} {
+		h.DescribeRoute(msgType, RouteInfo{To: []ClientType{ClientTypeControl}, Scope: ScopeControl})
+	}
+
+	// Telemetry updates go to web clients in the sender's room
+	for _, msgType := range []string{"route_update", "location_update"} {
+		h.RegisterHandler(msgType, func(ctx *MessageContext) {
+			delivered := h.Forward(ctx, ClientTypeWeb)
+			log.Printf("Forwarded %s to %d web clients", ctx.Message.Type, delivered)
+			h.persistTelemetry(ctx.Sender, ctx.Message, ctx.target)
+			h.cacheLastState(ctx.Sender, ctx.Message.Type, ctx.Raw, ctx.target)
+			h.detectAnomalies(ctx.Sender, ctx.Message.Type, ctx.Raw)
+			h.forwardToInference(ctx.Sender, ctx.Raw)
+		})
+		h.DescribeRoute(msgType, RouteInfo{To: []ClientType{ClientTypeWeb}})
+	}
+
+	// Legacy Python client type identification (before handshake); modern
+	// clients use the handshake protocol instead
+	h.RegisterHandler("control_client_connect", func(ctx *MessageContext) {
+		log.Printf("Legacy control client identification from %s", ctx.Sender.username)
+	})
+	h.RegisterHandler("video_client_connect", func(ctx *MessageContext) {
+		log.Printf("Legacy video client identification from %s", ctx.Sender.username)
+	})
+
+	// Control lock and takeover
+	h.RegisterHandler("request_control", func(ctx *MessageContext) {
+		if !h.authorize(ctx.Sender, ctx.Message.Type, ScopeControl) {
+			ctx.Reject()
+			return
+		}
+		h.handleRequestControl(ctx.Sender)
+	})
+	h.DescribeRoute("request_control", RouteInfo{Scope: ScopeControl})
+	h.RegisterHandler("release_control", func(ctx *MessageContext) {
+		h.handleReleaseControl(ctx.Sender)
+	})
+	h.RegisterHandler("request_takeover", func(ctx *MessageContext) {
+		if !h.authorize(ctx.Sender, ctx.Message.Type, ScopeControl) {
+			ctx.Reject()
+			return
+		}
+		h.handleRequestTakeover(ctx.Sender, ctx.Raw)
+	})
+	h.DescribeRoute("request_takeover", RouteInfo{Scope: ScopeControl})
+	h.RegisterHandler("grant_takeover", func(ctx *MessageContext) {
+		h.handleGrantTakeover(ctx.Sender)
+	})
+	h.RegisterHandler("deny_takeover", func(ctx *MessageContext) {
+		h.handleDenyTakeover(ctx.Sender)
+	})
+	h.RegisterHandler("heartbeat", func(ctx *MessageContext) {
+		h.handleHeartbeat(ctx.Sender)
+	})
+	h.RegisterHandler("disconnect_client", func(ctx *MessageContext) {
+		h.handleDisconnectClient(ctx.Sender, ctx.Raw)
+	})
+
+	// Topic subscriptions (see topic.go)
+	for _, msgType := range []string{"subscribe", "unsubscribe"} {
+		h.RegisterHandler(msgType, func(ctx *MessageContext) {
+			h.handleSubscribe(ctx.Sender, ctx.Message.Type, ctx.Raw)
+		}, topicSenders...)
+	}
+	// Topic messages may reach control clients, so they need control scope
+	h.RegisterHandler("publish", func(ctx *MessageContext) {
+		if !h.authorize(ctx.Sender, ctx.Message.Type, ScopeControl) {
+			ctx.Reject()
+			return
+		}
+		h.handlePublish(ctx)
+	}, topicSenders...)
+	h.DescribeRoute("publish", RouteInfo{Scope: ScopeControl})
+
+	// Return server status to requester
+	h.RegisterHandler("get_status", func(ctx *MessageContext) {
+		h.handleGetStatus(ctx.Sender)
+	})
+
+	// WebRTC connection established notification
+	h.RegisterHandler("webrtc_connected", func(ctx *MessageContext) {
+		h.Forward(ctx, ClientTypeWeb)
+		log.Printf("📡 WebRTC connection status forwarded to web clients")
+	})
+	h.DescribeRoute("webrtc_connected", RouteInfo{To: []ClientType{ClientTypeWeb}})
+}
+
+// routeUnknown broadcasts a message of a type without a handler to the
+// room except its sender. Unknown types reach control clients too, so they
+// need control scope.
+func (h *Hub) routeUnknown(ctx *MessageContext) {
+	if !h.authorize(ctx.Sender, ctx.Message.Type, ScopeControl) {
+		ctx.Reject()
+		return
+	}
+	logging.Sampled("ws_unknown_type", "Unknown message type: %s, broadcasting to room", ctx.Message.Type)
+	h.broadcastExceptSender(ctx.Sender, ctx.Raw, ctx.target)
+}
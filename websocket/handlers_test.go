@@ -0,0 +1,43 @@
+package websocket
+
+import "testing"
+
+// TestRegisterHandler tests that registered handlers route new types and
+// replace built-in ones, and that sender constraints are enforced
+func TestRegisterHandler(t *testing.T) {
+	hub := NewHub()
+	var handled []string
+	hub.RegisterHandler("dock_status", func(ctx *MessageContext) {
+		handled = append(handled, ctx.Sender.username)
+		hub.Forward(ctx, ClientTypeWeb)
+	}, ClientTypeControl)
+	hub.RegisterHandler("pong", func(ctx *MessageContext) {
+		handled = append(handled, "pong")
+	})
+
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	viewer := newTestClient(hub, ClientTypeWeb, "viewer")
+	viewer.scopes = []string{ScopeControl}
+
+	hub.RouteMessage(robot, []byte(`{"type":"dock_status","data":{"docked":true}}`))
+	if types := messageTypes(viewer); len(types) != 1 || types[0] != "dock_status" {
+		t.Errorf("Expected the registered handler to forward the message, got %v", types)
+	}
+
+	// Web clients may not send the type, even with control scope
+	hub.RouteMessage(viewer, []byte(`{"type":"dock_status","data":{}}`))
+	if types := messageTypes(robot); len(types) != 0 {
+		t.Errorf("Expected a disallowed sender to be ignored, got %v", types)
+	}
+
+	hub.RouteMessage(viewer, []byte(`{"type":"pong"}`))
+	if len(handled) != 2 || handled[0] != "robot" || handled[1] != "pong" {
+		t.Errorf("Unexpected handler calls %v", handled)
+	}
+
+	// Built-in sender constraints still apply
+	hub.RouteMessage(robot, []byte(`{"type":"control_command","data":{"action":"forward"}}`))
+	if types := messageTypes(robot); len(types) != 0 {
+		t.Errorf("Expected control commands from control clients to be ignored, got %v", types)
+	}
+}
@@ -1,14 +1,26 @@
 package websocket
 
 import (
+	"errors"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// defaultChannelTimeout bounds how long RegisterClient and UnregisterClient
+// wait for a stalled hub loop
+const defaultChannelTimeout = 5 * time.Second
+
+// ErrHubStalled is returned when the hub loop does not accept a register or
+// unregister request in time
+var ErrHubStalled = errors.New("hub is not accepting requests")
+
 // Hub maintains the set of active clients and broadcasts messages
 type Hub struct {
-	// Registered clients by type
-	clients map[ClientType]map[*Client]bool
+	// Registered clients by type, and by connection ID (see connid.go)
+	clients      map[ClientType]map[*Client]bool
+	byConnection map[string]*Client
 
 	// Register requests from clients
 	register chan *Client
@@ -16,17 +28,155 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
+	// Type changes at handshake, serialized with registration (see
+	// transition.go)
+	typeChanges chan typeChange
+
+	// How long to wait on a full register/unregister channel, and how often
+	// that timed out (channel saturation)
+	channelTimeout     time.Duration
+	registerTimeouts   atomic.Int64
+	unregisterTimeouts atomic.Int64
+
 	// Mutex for thread-safe access to clients map
 	mu sync.RWMutex
+
+	// Optional anomaly detection on telemetry (nil when disabled)
+	anomaly *AnomalyDetector
+
+	// Optional external inference on telemetry windows (nil when disabled)
+	inference *InferenceHook
+
+	// Optional per-robot control permissions (nil allows every robot)
+	controlPolicy ControlPolicy
+
+	// Commands dropped for exceeding their latency budget (see budget.go)
+	budgetExceeded atomic.Int64
+
+	// Default time-to-live of forwarded messages by type, and how many
+	// stale copies write pumps dropped (see ttl.go)
+	messageTTLs map[string]time.Duration
+	ttlExpired  atomic.Int64
+
+	// Recent message IDs per sender, to drop retried duplicates (see
+	// dedup.go)
+	dedup dedupCache
+
+	// Limits on control commands and how many they rejected (see rules.go)
+	commandRules     CommandRules
+	commandsRejected atomic.Int64
+
+	// Recent control commands matched to their responses (see correlation.go)
+	commands commandTracker
+
+	// At-least-once commands not yet acknowledged (see qos.go)
+	qos commandQoS
+
+	// Recent messages no client received (see deadletter.go)
+	deadLetters deadLetterQueue
+
+	// WebRTC negotiations between one web client and one media client
+	// (see signaling.go)
+	signaling signalingSessions
+
+	// Exclusive control by one web client (see control.go)
+	control controlLock
+
+	// Optional connection lifecycle audit (nil when disabled, see audit.go)
+	audit AuditRecorder
+
+	// Rate limit of security_event messages (see security.go)
+	security securityLimiter
+
+	// Last emergency stop or reset, overall and per room (protected by estopMu)
+	estop      EmergencyStopState
+	estopRooms map[string]EmergencyStopState
+	estopMu    sync.RWMutex
+
+	// Messages received per room (protected by roomMu, see room.go)
+	roomTraffic map[string]*RoomTraffic
+	roomMu      sync.Mutex
+
+	// Payload schemas by message type (see schema.go)
+	schemas map[string]Schema
+
+	// Handlers by message type (see handlers.go)
+	handlers map[string]registeredHandler
+
+	// Message rate limits and how many messages they dropped (see
+	// ratelimit.go)
+	rateLimits  RateLimitConfig
+	rateLimited atomic.Int64
+
+	// Send queue size and full-queue policy, and how many queued messages
+	// the policy discarded (see sendqueue.go)
+	sendQueue   SendQueueConfig
+	sendDropped atomic.Int64
+
+	// Gaps in sequence numbers reported to clients (see sequence.go)
+	sequenceGaps sequenceGaps
+
+	// Clients silent for this long are evicted (0 disables), and how many
+	// were (see liveness.go)
+	staleTimeout time.Duration
+	staleEvicted atomic.Int64
+
+	// Optional recording of routed messages (nil when disabled, see
+	// recording.go)
+	recorder MessageRecorder
+
+	// Optional persistence of routed telemetry (nil when disabled, see
+	// telemetry.go)
+	telemetry TelemetrySink
+
+	// Messages, bytes and broadcast fan-out through the hub (see
+	// throughput.go)
+	throughput throughputStats
+
+	// Sessions that can be resumed after their connection drops (see
+	// resume.go)
+	resume resumeStore
+
+	// Client types sent compressed frames (see compression.go)
+	compression CompressionConfig
+
+	// Latest telemetry per room and robot for late joiners (see
+	// snapshot.go)
+	lastState lastStateCache
+
+	// Connected clients allowed per type (see limits.go)
+	limits clientLimits
+
+	// Bytes per second written to clients per type (see egress.go)
+	egress egressThrottle
+
+	// Broadcasts shared with other instances (see backplane.go)
+	relay backplaneState
+
+	// Telemetry and commands exchanged with edge services (see bridge.go)
+	edge edgeBridge
 }
 
 // NewHub creates a new Hub instance
 func NewHub() *Hub {
-	return &Hub{
-		clients:    make(map[ClientType]map[*Client]bool),
-		register:   make(chan *Client, 10),   // Buffered channel to prevent blocking
-		unregister: make(chan *Client, 10),   // Buffered channel to prevent blocking
+	schemas := make(map[string]Schema, len(defaultSchemas))
+	for msgType, schema := range defaultSchemas {
+		schemas[msgType] = schema
+	}
+
+	h := &Hub{
+		clients:      make(map[ClientType]map[*Client]bool),
+		byConnection: make(map[string]*Client),
+		register:     make(chan *Client, 10), // Buffered channel to prevent blocking
+		unregister:   make(chan *Client, 10), // Buffered channel to prevent blocking
+		typeChanges:  make(chan typeChange, 10),
+
+		channelTimeout: defaultChannelTimeout,
+		schemas:        schemas,
+		sendQueue:      defaultSendQueue,
 	}
+	h.registerBuiltinHandlers()
+	return h
 }
 
 // Run starts the hub's main loop
@@ -37,15 +187,33 @@ func (h *Hub) Run() {
 		}
 	}()
 
+	// Check for an idle control lock holder, stale clients and expired
+	// resume windows once a second
+	h.control.mu.Lock()
+	idleTimeout := h.control.idleTimeout
+	h.control.mu.Unlock()
+	var expire <-chan time.Time
+	if idleTimeout > 0 || h.staleTimeout > 0 || h.resume.window > 0 {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		expire = ticker.C
+	}
+
 	for {
 		select {
+		case now := <-expire:
+			h.expireControlLock(now)
+			h.evictStale(now)
+			h.expireResume(now)
+
 		case client := <-h.register:
-			log.Printf("📥 Processing register for %s (type=%s)", client.username, client.clientType)
+			log.Printf("📥 Processing register for %s (type=%s)", client.username, client.Type())
 			h.mu.Lock()
-			if h.clients[client.clientType] == nil {
-				h.clients[client.clientType] = make(map[*Client]bool)
+			if h.clients[client.Type()] == nil {
+				h.clients[client.Type()] = make(map[*Client]bool)
 			}
-			h.clients[client.clientType][client] = true
+			h.clients[client.Type()][client] = true
+			h.indexConnection(client)
 			// Calculate count without calling GetClientCount() to avoid potential issues
 			count := 0
 			for _, clients := range h.clients {
@@ -54,57 +222,113 @@ func (h *Hub) Run() {
 			h.mu.Unlock()
 
 			log.Printf("Client registered: type=%s, user=%s (total: %d)",
-				client.clientType, client.username, count)
+				client.Type(), client.username, count)
+			h.auditEvent(client, AuditConnect, map[string]interface{}{
+				"remote_addr": client.remoteAddr,
+			})
+
+		case change := <-h.typeChanges:
+			change.result <- h.applyTypeChange(change)
 
 		case client := <-h.unregister:
-			log.Printf("📤 Processing unregister for %s (type=%s)", client.username, client.clientType)
-			log.Printf("🔒 Attempting to lock mutex for unregister...")
-			h.mu.Lock()
-			log.Printf("✅ Mutex locked for unregister")
-			if clients, ok := h.clients[client.clientType]; ok {
-				if _, ok := clients[client]; ok {
-					delete(clients, client)
-					log.Printf("🗑️  Deleted client from map, about to close send channel...")
-
-					// Safely close channel with panic recovery
-					func() {
-						defer func() {
-							if r := recover(); r != nil {
-								log.Printf("🚨 Panic while closing send channel: %v", r)
-							}
-						}()
-						close(client.send)
-						log.Printf("✅ Send channel closed successfully")
-					}()
-
-					// Calculate count without calling GetClientCount() to avoid deadlock
-					count := 0
-					for _, clients := range h.clients {
-						count += len(clients)
-					}
-					log.Printf("Client unregistered: type=%s, user=%s (total: %d)",
-						client.clientType, client.username, count)
-				} else {
-					log.Printf("⚠️  Client not found in map for unregister: %s", client.username)
-				}
-			} else {
-				log.Printf("⚠️  Client type map not found for unregister: %s", client.clientType)
+			// A dropped connection may come back within the resume window
+			if h.detachForResume(client) {
+				continue
 			}
-			log.Printf("🔓 About to unlock mutex...")
-			h.mu.Unlock()
-			log.Printf("✅ Mutex unlocked")
+			h.removeClient(client)
 		}
 	}
 }
 
-// RegisterClient registers a new client
-func (h *Hub) RegisterClient(client *Client) {
-	h.register <- client
+// removeClient takes a client out of the hub for good
+func (h *Hub) removeClient(client *Client) {
+	log.Printf("📤 Processing unregister for %s (type=%s)", client.username, client.Type())
+	log.Printf("🔒 Attempting to lock mutex for unregister...")
+	h.mu.Lock()
+	log.Printf("✅ Mutex locked for unregister")
+	removed := false
+	if clients, ok := h.clients[client.Type()]; ok {
+		if _, ok := clients[client]; ok {
+			delete(clients, client)
+			h.unindexConnection(client)
+			removed = true
+			log.Printf("🗑️  Deleted client from map, about to close send channel...")
+
+			// Broadcasts still holding the client drop their messages
+			client.closeSend()
+			log.Printf("✅ Send channel closed successfully")
+
+			// Calculate count without calling GetClientCount() to avoid deadlock
+			count := 0
+			for _, clients := range h.clients {
+				count += len(clients)
+			}
+			log.Printf("Client unregistered: type=%s, user=%s (total: %d)",
+				client.Type(), client.username, count)
+		} else {
+			log.Printf("⚠️  Client not found in map for unregister: %s", client.username)
+		}
+	} else {
+		log.Printf("⚠️  Client type map not found for unregister: %s", client.Type())
+	}
+	log.Printf("🔓 About to unlock mutex...")
+	h.mu.Unlock()
+	log.Printf("✅ Mutex unlocked")
+
+	if removed {
+		h.auditDisconnect(client)
+	}
+	h.releaseControl(client, "disconnected")
+	h.withdrawTakeover(client)
+	h.forgetResume(client)
+	h.closeSignalingSessions(client)
 }
 
-// UnregisterClient unregisters a client
-func (h *Hub) UnregisterClient(client *Client) {
-	h.unregister <- client
+// RegisterClient registers a new client. It fails with ErrHubStalled when
+// the hub loop does not accept the client in time; the caller should
+// reject the connection.
+func (h *Hub) RegisterClient(client *Client) error {
+	select {
+	case h.register <- client:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(h.channelTimeout)
+	defer timer.Stop()
+	select {
+	case h.register <- client:
+		return nil
+	case <-timer.C:
+		h.registerTimeouts.Add(1)
+		log.Printf("🚨 Hub register channel saturated (%d queued), rejecting %s", len(h.register), client.username)
+		return ErrHubStalled
+	}
+}
+
+// UnregisterClient unregisters a client. When the hub loop does not accept
+// the request in time the connection is closed directly so the peer is not
+// left hanging, and ErrHubStalled is returned.
+func (h *Hub) UnregisterClient(client *Client) error {
+	select {
+	case h.unregister <- client:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(h.channelTimeout)
+	defer timer.Stop()
+	select {
+	case h.unregister <- client:
+		return nil
+	case <-timer.C:
+		h.unregisterTimeouts.Add(1)
+		log.Printf("🚨 Hub unregister channel saturated (%d queued), closing %s directly", len(h.unregister), client.username)
+		if client.conn != nil {
+			client.conn.Close()
+		}
+		return ErrHubStalled
+	}
 }
 
 // BroadcastToType sends a message to all clients of a specific type
@@ -113,14 +337,13 @@ func (h *Hub) BroadcastToType(clientType ClientType, message []byte) {
 	clients := h.clients[clientType]
 	h.mu.RUnlock()
 
+	delivered := 0
 	for client := range clients {
-		select {
-		case client.send <- message:
-		default:
-			// Client's send buffer is full, unregister it
-			go h.UnregisterClient(client)
+		if h.deliver(client, outbound{data: message}) {
+			delivered++
 		}
 	}
+	h.throughput.countFanout(delivered)
 }
 
 // BroadcastToAll sends a message to all clients
@@ -128,15 +351,15 @@ func (h *Hub) BroadcastToAll(message []byte) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	delivered := 0
 	for _, clients := range h.clients {
 		for client := range clients {
-			select {
-			case client.send <- message:
-			default:
-				go h.UnregisterClient(client)
+			if h.deliver(client, outbound{data: message}) {
+				delivered++
 			}
 		}
 	}
+	h.throughput.countFanout(delivered)
 }
 
 // GetClientCount returns the total number of connected clients
@@ -165,15 +388,53 @@ func (h *Hub) GetClientCountByType(clientType ClientType) int {
 // GetStats returns statistics about connected clients
 func (h *Hub) GetStats() map[string]interface{} {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
+
+	// Count inline: GetClientCount would re-acquire the read lock
+	total := 0
+	for _, clients := range h.clients {
+		total += len(clients)
+	}
 
 	stats := make(map[string]interface{})
-	stats["total"] = h.GetClientCount()
+	stats["total"] = total
 	stats["web"] = len(h.clients[ClientTypeWeb])
 	stats["video"] = len(h.clients[ClientTypeVideo])
 	stats["control"] = len(h.clients[ClientTypeControl])
 	stats["telemetry"] = len(h.clients[ClientTypeTelemetry])
+	stats["audio"] = len(h.clients[ClientTypeAudio])
+	stats["monitor"] = len(h.clients[ClientTypeMonitor])
 	stats["pending"] = len(h.clients[ClientTypePending])
+	stats["latency_budget_exceeded"] = h.budgetExceeded.Load()
+	stats["ttl_expired"] = h.ttlExpired.Load()
+	stats["duplicates_suppressed"] = h.dedup.suppressed.Load()
+	stats["commands_rejected"] = h.commandsRejected.Load()
+	stats["rate_limited"] = h.rateLimited.Load()
+	stats["send_dropped"] = h.sendDropped.Load()
+	stats["sequence"] = h.sequenceStats()
+	stats["egress"] = h.egressStats()
+	stats["liveness"] = h.livenessStats(time.Now())
+	stats["throughput"] = h.throughputSummary()
+	stats["client_limits"] = h.limitStats()
+	stats["hub_channels"] = map[string]interface{}{
+		"register_queued":     len(h.register),
+		"unregister_queued":   len(h.unregister),
+		"register_timeouts":   h.registerTimeouts.Load(),
+		"unregister_timeouts": h.unregisterTimeouts.Load(),
+	}
+	h.mu.RUnlock()
+
+	// Per-room breakdown takes the hub lock itself
+	stats["rooms"] = h.RoomStatuses()
+	stats["resume"] = h.resumeStats()
+	stats["command_qos"] = h.commandQoSStats()
+	stats["dead_letters"] = h.deadLetterCount()
+	stats["signaling_sessions"] = h.signalingSessionCount()
+	if backplane := h.backplaneStats(); backplane != nil {
+		stats["backplane"] = backplane
+	}
+	if edge := h.edgeBridgeStats(); edge != nil {
+		stats["edge_bridge"] = edge
+	}
 
 	return stats
 }
@@ -2,8 +2,53 @@ package websocket
 
 import (
 	"testing"
+	"time"
 )
 
+// newTestClient creates a client without a connection and registers it
+// directly in the hub's client map
+func newTestClient(hub *Hub, clientType ClientType, username string) *Client {
+	client := &Client{
+		hub:      hub,
+		send:     make(chan outbound, 256),
+		priority: make(chan outbound, priorityQueueSize),
+		username: username,
+	}
+	client.clientType.Store(clientType)
+
+	hub.mu.Lock()
+	if hub.clients[clientType] == nil {
+		hub.clients[clientType] = make(map[*Client]bool)
+	}
+	hub.clients[clientType][client] = true
+	hub.mu.Unlock()
+
+	return client
+}
+
+// drainMessages returns all messages currently queued for a client,
+// priority messages first like writePump
+func drainMessages(client *Client) [][]byte {
+	var messages [][]byte
+	for {
+		select {
+		case msg := <-client.priority:
+			messages = append(messages, msg.data)
+			continue
+		default:
+		}
+		select {
+		case msg, ok := <-client.send:
+			if !ok {
+				return messages
+			}
+			messages = append(messages, msg.data)
+		default:
+			return messages
+		}
+	}
+}
+
 // TestNewHub tests hub creation
 func TestNewHub(t *testing.T) {
 	hub := NewHub()
@@ -44,6 +89,7 @@ func TestHubGetClientCountByType(t *testing.T) {
 		ClientTypeControl,
 		ClientTypeVideo,
 		ClientTypeTelemetry,
+		ClientTypeAudio,
 		ClientTypePending,
 	}
 
@@ -67,7 +113,7 @@ func TestHubGetStats(t *testing.T) {
 	}
 
 	// Check required fields exist
-	requiredFields := []string{"total", "web", "video", "control", "telemetry", "pending"}
+	requiredFields := []string{"total", "web", "video", "control", "telemetry", "audio", "pending"}
 	for _, field := range requiredFields {
 		if _, ok := stats[field]; !ok {
 			t.Errorf("Stats missing required field: %s", field)
@@ -87,6 +133,7 @@ func TestClientTypes(t *testing.T) {
 		ClientTypeVideo:     "video",
 		ClientTypeControl:   "control",
 		ClientTypeTelemetry: "telemetry",
+		ClientTypeAudio:     "audio",
 		ClientTypePending:   "pending",
 	}
 
@@ -97,3 +144,34 @@ func TestClientTypes(t *testing.T) {
 	}
 }
 
+// TestHubStalledChannels tests that register and unregister give up on a
+// stalled hub loop and count the timeouts
+func TestHubStalledChannels(t *testing.T) {
+	hub := NewHub() // Run is never started, so the channels fill up
+	hub.channelTimeout = 20 * time.Millisecond
+	client := &Client{hub: hub, username: "alice", send: make(chan outbound, 1)}
+
+	for i := 0; i < cap(hub.register); i++ {
+		if err := hub.RegisterClient(client); err != nil {
+			t.Fatalf("Expected buffered register to succeed, got %v", err)
+		}
+	}
+	if err := hub.RegisterClient(client); err != ErrHubStalled {
+		t.Errorf("Expected ErrHubStalled on a full register channel, got %v", err)
+	}
+
+	for i := 0; i < cap(hub.unregister); i++ {
+		hub.UnregisterClient(client)
+	}
+	if err := hub.UnregisterClient(client); err != ErrHubStalled {
+		t.Errorf("Expected ErrHubStalled on a full unregister channel, got %v", err)
+	}
+
+	channels := hub.GetStats()["hub_channels"].(map[string]interface{})
+	if channels["register_timeouts"] != int64(1) || channels["unregister_timeouts"] != int64(1) {
+		t.Errorf("Expected one timeout per channel, got %v", channels)
+	}
+	if channels["register_queued"] != cap(hub.register) {
+		t.Errorf("Expected a full register queue, got %v", channels["register_queued"])
+	}
+}
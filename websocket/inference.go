@@ -0,0 +1,213 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// InferenceConfig configures forwarding of telemetry windows to an
+// external inference service
+type InferenceConfig struct {
+	// DefaultURL receives windows from robots without an explicit endpoint
+	DefaultURL string
+
+	// RobotURLs overrides the endpoint per robot (username); an empty URL
+	// disables inference for that robot
+	RobotURLs map[string]string
+
+	// WindowSize is the number of telemetry messages per request
+	WindowSize int
+
+	// Timeout bounds each inference request
+	Timeout time.Duration
+}
+
+// InferenceRequest is the payload posted to the inference service
+type InferenceRequest struct {
+	Robot   string            `json:"robot"`
+	Samples []json.RawMessage `json:"samples"`
+}
+
+// Classification is a single label returned by the inference service
+type Classification struct {
+	Name       string  `json:"name"`
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// InferenceResponse is the payload returned by the inference service
+type InferenceResponse struct {
+	Classifications []Classification `json:"classifications"`
+}
+
+// inferenceWindow buffers telemetry for one robot
+type inferenceWindow struct {
+	samples  []json.RawMessage
+	inFlight bool
+}
+
+// InferenceHook collects telemetry windows per robot and posts them to the
+// configured inference service
+type InferenceHook struct {
+	cfg    InferenceConfig
+	client *http.Client
+
+	windows map[string]*inferenceWindow
+	mu      sync.Mutex
+}
+
+// NewInferenceHook creates an inference hook
+func NewInferenceHook(cfg InferenceConfig) *InferenceHook {
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = 20
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	return &InferenceHook{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		windows: make(map[string]*inferenceWindow),
+	}
+}
+
+// endpointFor returns the inference endpoint for a robot ("" when disabled)
+func (i *InferenceHook) endpointFor(robot string) string {
+	if url, ok := i.cfg.RobotURLs[robot]; ok {
+		return url
+	}
+	return i.cfg.DefaultURL
+}
+
+// observe appends a telemetry message to the robot's window and returns a
+// full window ready to send, if any. Windows are dropped while a previous
+// request for the same robot is still in flight.
+func (i *InferenceHook) observe(robot string, rawMessage []byte) (string, []json.RawMessage) {
+	endpoint := i.endpointFor(robot)
+	if endpoint == "" {
+		return "", nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	window := i.windows[robot]
+	if window == nil {
+		window = &inferenceWindow{}
+		i.windows[robot] = window
+	}
+
+	sample := make(json.RawMessage, len(rawMessage))
+	copy(sample, rawMessage)
+	window.samples = append(window.samples, sample)
+
+	if len(window.samples) < i.cfg.WindowSize {
+		return "", nil
+	}
+
+	samples := window.samples
+	window.samples = nil
+	if window.inFlight {
+		return "", nil
+	}
+	window.inFlight = true
+
+	return endpoint, samples
+}
+
+// done marks the robot's in-flight request as finished
+func (i *InferenceHook) done(robot string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if window := i.windows[robot]; window != nil {
+		window.inFlight = false
+	}
+}
+
+// classify posts a window to the inference service
+func (i *InferenceHook) classify(endpoint, robot string, samples []json.RawMessage) (*InferenceResponse, error) {
+	body, err := json.Marshal(InferenceRequest{Robot: robot, Samples: samples})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := i.client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("inference service returned %s", resp.Status)
+	}
+
+	var result InferenceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SetInferenceHook enables forwarding of telemetry to an inference service
+func (h *Hub) SetInferenceHook(hook *InferenceHook) {
+	h.inference = hook
+}
+
+// forwardToInference feeds telemetry into the inference hook and injects
+// the returned classifications as synthetic telemetry
+func (h *Hub) forwardToInference(sender *Client, rawMessage []byte) {
+	if h.inference == nil {
+		return
+	}
+
+	robot, room, robotID := sender.username, sender.Room(), sender.RobotID()
+	endpoint, samples := h.inference.observe(robot, rawMessage)
+	if samples == nil {
+		return
+	}
+
+	go func() {
+		defer h.inference.done(robot)
+
+		result, err := h.inference.classify(endpoint, robot, samples)
+		if err != nil {
+			log.Printf("❌ Inference request for %s failed: %v", robot, err)
+			return
+		}
+		if len(result.Classifications) == 0 {
+			return
+		}
+
+		h.injectClassifications(room, robotID, robot, result.Classifications)
+	}()
+}
+
+// injectClassifications broadcasts inference results to web clients in the
+// robot's room watching it as telemetry
+func (h *Hub) injectClassifications(room, robotID, robot string, classifications []Classification) {
+	message := map[string]interface{}{
+		"type":            "telemetry_classification",
+		"robot":           robot,
+		"source":          "inference",
+		"classifications": classifications,
+		"timestamp":       time.Now().Unix(),
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal telemetry classification: %v", err)
+		return
+	}
+
+	delivered := h.broadcastToRobot(room, robotID, []ClientType{ClientTypeWeb}, data)
+	log.Printf("🧠 Injected %d classification(s) for %s to %d web clients",
+		len(classifications), robot, delivered)
+}
@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestInferenceHookInjectsClassifications tests the round trip to an inference service
+func TestInferenceHookInjectsClassifications(t *testing.T) {
+	requests := make(chan InferenceRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req InferenceRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests <- req
+
+		json.NewEncoder(w).Encode(InferenceResponse{
+			Classifications: []Classification{{Name: "terrain", Value: "gravel", Confidence: 0.9}},
+		})
+	}))
+	defer server.Close()
+
+	hub := NewHub()
+	hub.SetInferenceHook(NewInferenceHook(InferenceConfig{DefaultURL: server.URL, WindowSize: 3}))
+	web := newTestClient(hub, ClientTypeWeb, "operator")
+	robot := newTestClient(hub, ClientTypeTelemetry, "robot-1")
+
+	for i := 0; i < 3; i++ {
+		hub.RouteMessage(robot, []byte(fmt.Sprintf(`{"type":"location_update","data":{"speed":%d}}`, i)))
+	}
+
+	select {
+	case req := <-requests:
+		if req.Robot != "robot-1" || len(req.Samples) != 3 {
+			t.Errorf("Unexpected inference request: robot=%s samples=%d", req.Robot, len(req.Samples))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Inference service was not called")
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case raw := <-web.send:
+			var msg struct {
+				Type            string           `json:"type"`
+				Robot           string           `json:"robot"`
+				Classifications []Classification `json:"classifications"`
+			}
+			if err := json.Unmarshal(raw.data, &msg); err != nil || msg.Type != "telemetry_classification" {
+				continue
+			}
+			if msg.Robot != "robot-1" || len(msg.Classifications) != 1 || msg.Classifications[0].Value != "gravel" {
+				t.Errorf("Unexpected classification message: %s", raw.data)
+			}
+			return
+		case <-deadline:
+			t.Fatal("Web client did not receive telemetry_classification")
+		}
+	}
+}
+
+// TestInferenceHookPerRobotEndpoints tests per-robot overrides
+func TestInferenceHookPerRobotEndpoints(t *testing.T) {
+	hook := NewInferenceHook(InferenceConfig{
+		DefaultURL: "http://default",
+		RobotURLs: map[string]string{
+			"robot-a": "http://robot-a",
+			"robot-b": "",
+		},
+	})
+
+	tests := map[string]string{
+		"robot-a": "http://robot-a",
+		"robot-b": "",
+		"robot-c": "http://default",
+	}
+	for robot, expected := range tests {
+		if got := hook.endpointFor(robot); got != expected {
+			t.Errorf("endpointFor(%s) = %q, expected %q", robot, got, expected)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"log"
+	"sync"
+)
+
+// ClientLimits caps how many clients of each type may be connected at
+// once; unlisted types and 0 are unlimited
+type ClientLimits map[ClientType]int
+
+// clientLimits holds the configured limits and how many handshakes each
+// one turned away
+type clientLimits struct {
+	max      ClientLimits
+	rejected map[ClientType]int64 // Protected by mu
+	mu       sync.Mutex
+}
+
+// SetClientLimits caps connected clients per type, so a flood of viewers
+// cannot starve robots and the control path. Call before Run.
+func (h *Hub) SetClientLimits(limits ClientLimits) {
+	h.limits.max = limits
+}
+
+// ClientLimits returns the configured per-type limits, without unlimited
+// types
+func (h *Hub) ClientLimits() ClientLimits {
+	limits := make(ClientLimits)
+	for clientType, max := range h.limits.max {
+		if max > 0 {
+			limits[clientType] = max
+		}
+	}
+	return limits
+}
+
+// admitClientType reports whether another client of clientType fits under
+// its limit, counting the rejection when it does not. Detached clients
+// waiting to be resumed keep their place. The caller holds h.mu.
+func (h *Hub) admitClientType(client *Client, clientType ClientType) bool {
+	max := h.limits.max[clientType]
+	if max <= 0 {
+		return true
+	}
+
+	connected := len(h.clients[clientType])
+	if connected < max {
+		return true
+	}
+
+	h.limits.mu.Lock()
+	if h.limits.rejected == nil {
+		h.limits.rejected = make(map[ClientType]int64)
+	}
+	h.limits.rejected[clientType]++
+	h.limits.mu.Unlock()
+
+	log.Printf("🚫 Rejected %s as %s: %d/%d %s clients connected",
+		client.username, clientType, connected, max, clientType)
+	return false
+}
+
+// limitStats reports each limit with its current use and rejections. The
+// caller holds h.mu.
+func (h *Hub) limitStats() map[string]interface{} {
+	h.limits.mu.Lock()
+	defer h.limits.mu.Unlock()
+
+	stats := make(map[string]interface{}, len(h.limits.max))
+	for clientType, max := range h.limits.max {
+		if max <= 0 {
+			continue
+		}
+		connected := len(h.clients[clientType])
+		stats[string(clientType)] = map[string]interface{}{
+			"max":       max,
+			"connected": connected,
+			"saturated": connected >= max,
+			"rejected":  h.limits.rejected[clientType],
+		}
+	}
+	return stats
+}
@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestClientLimits tests that handshakes beyond a type's limit are
+// rejected and that saturation shows in the stats
+func TestClientLimits(t *testing.T) {
+	hub := NewHub()
+	hub.SetClientLimits(ClientLimits{ClientTypeWeb: 1})
+	go hub.Run()
+
+	first := newTestClient(hub, ClientTypePending, "alice")
+	first.SetConnectionID("conn_1")
+	hub.RouteMessage(first, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"web"}`))
+	if !first.IsHandshakeComplete() {
+		t.Fatal("Expected the first web client to be admitted")
+	}
+
+	second := newTestClient(hub, ClientTypePending, "bob")
+	second.SetConnectionID("conn_2")
+	hub.RouteMessage(second, []byte(`{"type":"handshake_response","connection_id":"conn_2","client_type":"web"}`))
+	if second.IsHandshakeComplete() || second.CloseReason() != "client_limit" {
+		t.Errorf("Expected the second web client to be rejected, got %q", second.CloseReason())
+	}
+	if types := messageTypes(second); len(types) != 1 || types[0] != "error" {
+		t.Errorf("Expected a client_limit_reached error, got %v", types)
+	}
+
+	robot := newTestClient(hub, ClientTypePending, "robot")
+	robot.SetConnectionID("conn_3")
+	hub.RouteMessage(robot, []byte(`{"type":"handshake_response","connection_id":"conn_3","client_type":"control"}`))
+	if !robot.IsHandshakeComplete() {
+		t.Error("Expected unlimited types to be admitted")
+	}
+
+	web := hub.GetStats()["client_limits"].(map[string]interface{})["web"].(map[string]interface{})
+	if web["connected"] != 1 || web["saturated"] != true || web["rejected"] != int64(1) {
+		t.Errorf("Expected a saturated web limit with one rejection, got %v", web)
+	}
+}
+
+// TestClientLimitsParallel tests that handshakes racing for the last place
+// of a type admit only one client
+func TestClientLimitsParallel(t *testing.T) {
+	hub := NewHub()
+	hub.SetClientLimits(ClientLimits{ClientTypeControl: 1})
+	go hub.Run()
+
+	clients := make([]*Client, 20)
+	for i := range clients {
+		clients[i] = newTestClient(hub, ClientTypePending, fmt.Sprintf("robot%d", i))
+		clients[i].SetConnectionID(fmt.Sprintf("conn_%d", i))
+	}
+
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *Client) {
+			defer wg.Done()
+			hub.RouteMessage(client, []byte(fmt.Sprintf(
+				`{"type":"handshake_response","connection_id":"conn_%d","client_type":"control"}`, i)))
+		}(i, client)
+	}
+	wg.Wait()
+
+	admitted := 0
+	for _, client := range clients {
+		if client.IsHandshakeComplete() {
+			admitted++
+		}
+	}
+	if admitted != 1 || hub.GetClientCountByType(ClientTypeControl) != 1 {
+		t.Errorf("Expected exactly one control client, got %d admitted and %d registered",
+			admitted, hub.GetClientCountByType(ClientTypeControl))
+	}
+}
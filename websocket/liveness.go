@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// livenessIdleThreshold is how long without activity a client counts as
+// idle in the hub stats
+const livenessIdleThreshold = 30 * time.Second
+
+// SetStaleTimeout evicts clients that sent no message or pong for d (0
+// disables). Pings are sent often enough that a live but quiet client
+// always answers in time. Call before Run.
+func (h *Hub) SetStaleTimeout(d time.Duration) {
+	h.staleTimeout = d
+}
+
+// pingInterval returns how often clients are pinged: pingPeriod, or more
+// often when the stale timeout is shorter
+func (h *Hub) pingInterval() time.Duration {
+	if h.staleTimeout > 0 && h.staleTimeout/3 < pingPeriod {
+		return h.staleTimeout / 3
+	}
+	return pingPeriod
+}
+
+// touch records activity from the client
+func (c *Client) touch(now time.Time) {
+	c.lastActivity.Store(now.UnixNano())
+}
+
+// LastActivity returns when the client last sent a message or pong (zero
+// if never recorded)
+func (c *Client) LastActivity() time.Time {
+	if nanos := c.lastActivity.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// evictStale drops clients idle past the stale timeout and tells web
+// clients in their room
+func (h *Hub) evictStale(now time.Time) {
+	if h.staleTimeout <= 0 {
+		return
+	}
+
+	h.mu.RLock()
+	var stale []*Client
+	for _, clients := range h.clients {
+		for client := range clients {
+			// Clients already closing are left to finish
+			if last := client.LastActivity(); !last.IsZero() && now.Sub(last) >= h.staleTimeout && client.CloseReason() == "" {
+				stale = append(stale, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range stale {
+		idle := now.Sub(client.LastActivity())
+		log.Printf("💤 Evicting stale client %s (%s), idle for %v", client.username, client.Type(), idle.Round(time.Second))
+		h.staleEvicted.Add(1)
+		client.setCloseReason("stale")
+		go h.dropClient(client, "stale")
+
+		notification := map[string]interface{}{
+			"type":          "client_stale",
+			"connection_id": client.connectionID,
+			"username":      client.username,
+			"client_type":   client.Type(),
+			"idle_ms":       idle.Milliseconds(),
+			"timestamp":     now.Unix(),
+		}
+		client.Metadata().addTo(notification)
+		message, err := json.Marshal(notification)
+		if err != nil {
+			continue
+		}
+		h.broadcastTo(client.Room(), routeTarget{}, []ClientType{ClientTypeWeb}, message)
+	}
+}
+
+// livenessStats summarizes client activity for GetStats; h.mu must be held
+func (h *Hub) livenessStats(now time.Time) map[string]interface{} {
+	var maxIdle time.Duration
+	idle := 0
+	for _, clients := range h.clients {
+		for client := range clients {
+			last := client.LastActivity()
+			if last.IsZero() {
+				continue
+			}
+			d := now.Sub(last)
+			if d > maxIdle {
+				maxIdle = d
+			}
+			if d >= livenessIdleThreshold {
+				idle++
+			}
+		}
+	}
+	return map[string]interface{}{
+		"max_idle_ms":      maxIdle.Milliseconds(),
+		"idle_clients":     idle,
+		"stale_timeout_ms": h.staleTimeout.Milliseconds(),
+		"stale_evicted":    h.staleEvicted.Load(),
+	}
+}
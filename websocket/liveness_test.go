@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestEvictStale tests that silent clients are evicted and web clients
+// told about it
+func TestEvictStale(t *testing.T) {
+	hub := NewHub()
+	hub.SetStaleTimeout(time.Minute)
+	now := time.Now()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	operator.touch(now)
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.SetConnectionID("conn-robot")
+	robot.touch(now.Add(-2 * time.Minute))
+
+	hub.evictStale(now)
+	if robot.CloseReason() != "stale" {
+		t.Errorf("Expected the silent robot to be evicted, got %q", robot.CloseReason())
+	}
+	if operator.CloseReason() != "" {
+		t.Error("Expected the active operator to stay")
+	}
+
+	messages := drainMessages(operator)
+	if len(messages) != 1 {
+		t.Fatalf("Expected one client_stale event, got %d messages", len(messages))
+	}
+	var event map[string]interface{}
+	json.Unmarshal(messages[0], &event)
+	if event["type"] != "client_stale" || event["connection_id"] != "conn-robot" || event["client_type"] != "control" {
+		t.Errorf("Unexpected event: %v", event)
+	}
+
+	// Not evicted twice while the unregister is pending
+	hub.evictStale(now.Add(time.Second))
+	if len(drainMessages(operator)) != 0 {
+		t.Error("Expected no second event for the same client")
+	}
+
+	liveness := hub.GetStats()["liveness"].(map[string]interface{})
+	if liveness["stale_evicted"] != int64(1) || liveness["idle_clients"] != 1 {
+		t.Errorf("Unexpected liveness stats: %v", liveness)
+	}
+}
+
+// TestPingInterval tests that a short stale timeout pings more often
+func TestPingInterval(t *testing.T) {
+	hub := NewHub()
+	if hub.pingInterval() != pingPeriod {
+		t.Errorf("Expected the default ping period, got %v", hub.pingInterval())
+	}
+	hub.SetStaleTimeout(30 * time.Second)
+	if hub.pingInterval() != 10*time.Second {
+		t.Errorf("Expected pings every 10s, got %v", hub.pingInterval())
+	}
+}
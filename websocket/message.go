@@ -3,6 +3,7 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	"oculo-pilot-server/logging"
 	"time"
 )
 
@@ -10,6 +11,27 @@ import (
 type Message struct {
 	Type string          `json:"type"`
 	Data json.RawMessage `json:"data,omitempty"`
+
+	// RobotID optionally addresses one robot (see servesRobot); without it
+	// a message reaches every matching client in the room
+	RobotID string `json:"robot_id,omitempty"`
+
+	// TargetConnectionID optionally addresses one connection in the room
+	// instead of every client of the destination type
+	TargetConnectionID string `json:"target_connection_id,omitempty"`
+}
+
+// MediaAudio marks WebRTC signaling messages that belong to an audio-only
+// intercom session rather than the default video session
+const MediaAudio = "audio"
+
+// MediaVideo marks the default video session
+const MediaVideo = "video"
+
+// signalingEnvelope carries the routing hints of a WebRTC signaling message
+type signalingEnvelope struct {
+	Media     string `json:"media,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
 }
 
 // HandshakeResponse represents handshake response from client
@@ -18,97 +40,84 @@ type HandshakeResponse struct {
 	ConnectionID string     `json:"connection_id"`
 	ClientType   ClientType `json:"client_type"`
 	AuthToken    string     `json:"auth_token,omitempty"`
+
+	// DeviceID identifies the device; required when the token is bound to one
+	DeviceID string `json:"device_id,omitempty"`
+
+	// SoftwareVersion, Platform and Capabilities describe the client's
+	// software for operators (see metadata.go)
+	SoftwareVersion string   `json:"software_version,omitempty"`
+	Platform        string   `json:"platform,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+
+	// Room is the room to join; routing stays within it (empty joins
+	// DefaultRoom)
+	Room string `json:"room,omitempty"`
+
+	// RobotID registers the client for one robot: robot-side clients then
+	// only receive messages addressed to it, web clients only its telemetry
+	RobotID string `json:"robot_id,omitempty"`
+
+	// Encoding of frames sent to the client: json (default), msgpack or
+	// protobuf
+	Encoding string `json:"encoding,omitempty"`
+
+	// Monitor optionally filters what a monitor connection receives
+	Monitor *MonitorFilter `json:"monitor,omitempty"`
+
+	// ResumeToken from an earlier connection_established takes that
+	// connection's place instead of the fields above (see resume.go)
+	ResumeToken string `json:"resume_token,omitempty"`
+
+	// ProtocolVersion and Features negotiate protocol features (version 2,
+	// see features.go); without them the client speaks version 1
+	ProtocolVersion int      `json:"protocol_version,omitempty"`
+	Features        []string `json:"features,omitempty"`
 }
 
 // RouteMessage routes a message from sender to appropriate recipients
 func (h *Hub) RouteMessage(sender *Client, rawMessage []byte) {
 	var msg Message
 	if err := json.Unmarshal(rawMessage, &msg); err != nil {
-		log.Printf("Invalid message format from %s: %v", sender.clientType, err)
+		logging.Sampled("ws_invalid_message", "Invalid message format from %s: %v", sender.Type(), err)
+		h.throughput.invalid.Add(1)
+		return
+	}
+	if !h.allowRate(sender, msg.Type) {
 		return
 	}
 
 	log.Printf("Message received: type=%s from client_type=%s user=%s",
-		msg.Type, sender.clientType, sender.username)
-
-	switch msg.Type {
-	case "handshake_response":
-		h.handleHandshake(sender, rawMessage)
-
-	case "ping":
-		h.handlePing(sender, rawMessage)
-
-	case "pong":
-		// Just log pong messages
-		log.Printf("Pong received from %s", sender.clientType)
+		msg.Type, sender.Type(), sender.username)
+	h.countRoomTraffic(sender.Room(), len(rawMessage))
 
-	case "control_command":
-		// Control commands from web clients go to control clients
-		if sender.clientType == ClientTypeWeb {
-			h.BroadcastToType(ClientTypeControl, rawMessage)
-			log.Printf("Routed control command to %d control clients",
-				h.GetClientCountByType(ClientTypeControl))
+	// Monitors are read-only; everything else is mirrored to them
+	if sender.Type() == ClientTypeMonitor {
+		if msg.Type == "ping" {
+			h.handlePing(sender, rawMessage)
 		}
+		return
+	}
+	if !h.validateMessage(sender, msg.Type, rawMessage) {
+		return
+	}
+	// Retried copies are dropped before monitors and recordings see them
+	dedupKey, id := h.dedupKey(sender, msg.Type, rawMessage)
+	if h.isDuplicate(sender, msg.Type, dedupKey, id) {
+		return
+	}
+	h.mirrorToMonitors(sender, msg.Type, rawMessage)
+	h.recordMessage(sender, DirectionIn, rawMessage)
+	target := messageTarget(sender, &msg)
+	if !h.checkTarget(sender, msg.Type, target) {
+		return
+	}
+	h.throughput.countRouted(msg.Type)
 
-	case "control_response":
-		// Control responses from control clients go back to web clients
-		if sender.clientType == ClientTypeControl {
-			h.BroadcastToType(ClientTypeWeb, rawMessage)
-			log.Printf("Routed control response to %d web clients",
-				h.GetClientCountByType(ClientTypeWeb))
-		}
-
-	case "offer", "answer", "ice-candidate":
-		// WebRTC signaling
-		h.handleWebRTCSignaling(sender, msg.Type, rawMessage)
-
-	case "video_client_ready":
-		// Video client is ready, notify web clients
-		h.BroadcastToType(ClientTypeWeb, rawMessage)
-		log.Printf("Notified %d web clients that video is ready",
-			h.GetClientCountByType(ClientTypeWeb))
-
-	case "emergency_stop":
-		// Emergency stop broadcasts to all control clients
-		h.BroadcastToType(ClientTypeControl, rawMessage)
-		log.Printf("🚨 Emergency stop broadcast to %d control clients",
-			h.GetClientCountByType(ClientTypeControl))
-
-	case "route_update", "location_update":
-		// Telemetry updates go to web clients
-		h.BroadcastToType(ClientTypeWeb, rawMessage)
-		log.Printf("Forwarded %s to %d web clients",
-			msg.Type, h.GetClientCountByType(ClientTypeWeb))
-
-	case "control_client_connect":
-		// Legacy Python client type identification (before handshake)
-		log.Printf("Legacy control client identification from %s", sender.username)
-		// Modern clients should use handshake protocol instead
-
-	case "video_client_connect":
-		// Legacy Python client type identification (before handshake)
-		log.Printf("Legacy video client identification from %s", sender.username)
-		// Modern clients should use handshake protocol instead
-
-	case "emergency_stop_reset":
-		// Reset emergency stop state - broadcast to control clients
-		h.BroadcastToType(ClientTypeControl, rawMessage)
-		log.Printf("🔄 Emergency stop reset broadcast to %d control clients",
-			h.GetClientCountByType(ClientTypeControl))
-
-	case "get_status":
-		// Return server status to requester
-		h.handleGetStatus(sender)
-
-	case "webrtc_connected":
-		// WebRTC connection established notification
-		h.BroadcastToType(ClientTypeWeb, rawMessage)
-		log.Printf("📡 WebRTC connection status forwarded to web clients")
-
-	default:
-		// Unknown message type - broadcast to all except sender
-		log.Printf("Unknown message type: %s, broadcasting to all", msg.Type)
-		h.broadcastExceptSender(sender, rawMessage)
+	ctx := &MessageContext{Sender: sender, Message: &msg, Raw: rawMessage, target: target}
+	h.dispatch(ctx)
+	if !ctx.rejected {
+		h.rememberMessage(msg.Type, dedupKey)
 	}
 }
 
@@ -116,8 +125,9 @@ func (h *Hub) RouteMessage(sender *Client, rawMessage []byte) {
 func (h *Hub) handleGetStatus(client *Client) {
 	stats := h.GetStats()
 	response := map[string]interface{}{
-		"type":  "status_response",
-		"stats": stats,
+		"type":      "status_response",
+		"stats":     stats,
+		"room":      RoomID(client.Room()),
 		"timestamp": time.Now().Unix(),
 	}
 
@@ -130,7 +140,7 @@ func (h *Hub) handleGetStatus(client *Client) {
 func (h *Hub) handleHandshake(client *Client, rawMessage []byte) {
 	var handshake HandshakeResponse
 	if err := json.Unmarshal(rawMessage, &handshake); err != nil {
-		log.Printf("❌ Invalid handshake response JSON: %v", err)
+		logging.Sampled("ws_invalid_handshake", "❌ Invalid handshake response JSON: %v", err)
 		return
 	}
 
@@ -139,90 +149,226 @@ func (h *Hub) handleHandshake(client *Client, rawMessage []byte) {
 
 	// Validate connection ID
 	if handshake.ConnectionID != client.GetConnectionID() {
-		log.Printf("❌ Invalid connection ID in handshake: expected=%s, got=%s",
+		logging.Sampled("ws_invalid_handshake", "❌ Invalid connection ID in handshake: expected=%s, got=%s",
 			client.GetConnectionID(), handshake.ConnectionID)
 		return
 	}
 
+	// A resume token stands in for the rest of the handshake
+	var resumed *resumeSession
+	if handshake.ResumeToken != "" {
+		if resumed = h.findResumeSession(client, &handshake); resumed == nil {
+			return
+		}
+	}
+
 	// Validate client type
 	validTypes := map[ClientType]bool{
 		ClientTypeWeb:       true,
 		ClientTypeVideo:     true,
 		ClientTypeControl:   true,
 		ClientTypeTelemetry: true,
+		ClientTypeAudio:     true,
+		ClientTypeMonitor:   true,
 	}
 	if !validTypes[handshake.ClientType] {
-		log.Printf("❌ Invalid client type in handshake: %s", handshake.ClientType)
+		logging.Sampled("ws_invalid_handshake", "❌ Invalid client type in handshake: %s", handshake.ClientType)
+		return
+	}
+	if !validRobotID(handshake.RobotID) {
+		logging.Sampled("ws_invalid_handshake", "❌ Invalid robot_id in handshake: %q", handshake.RobotID)
+		client.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "invalid_robot_id",
+			"message_type": "handshake_response",
+		})
+		return
+	}
+	if !validRoom(handshake.Room, handshake.ClientType) {
+		logging.Sampled("ws_invalid_handshake", "❌ Invalid room in handshake: %q (%s)", handshake.Room, handshake.ClientType)
+		client.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "invalid_room",
+			"message_type": "handshake_response",
+		})
+		return
+	}
+
+	metadata := handshake.metadata()
+	if !validMetadata(metadata) {
+		logging.Sampled("ws_invalid_handshake", "❌ Invalid client metadata in handshake from %s", client.username)
+		client.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "invalid_metadata",
+			"message_type": "handshake_response",
+		})
+		return
+	}
+
+	h.negotiate(client, &handshake)
+	if !validEncoding(handshake.Encoding) || binaryEncoding(handshake.Encoding) && !client.HasFeature(FeatureBinary) {
+		logging.Sampled("ws_invalid_handshake", "❌ Unsupported encoding in handshake: %q", handshake.Encoding)
+		client.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "unsupported_encoding",
+			"message_type": "handshake_response",
+		})
+		return
+	}
+
+	if !h.checkBinding(client, &handshake) {
+		return
+	}
+
+	// Only web clients may connect with a view-only token; robot-side
+	// types receive commands and publish telemetry
+	if handshake.ClientType == ClientTypeMonitor {
+		if !h.authorizeMonitor(client, handshake.Monitor) {
+			return
+		}
+	} else if handshake.ClientType != ClientTypeWeb && !h.authorize(client, "handshake_response", ScopeControl) {
 		return
 	}
 
 	log.Printf("✅ Handshake validation passed")
 
+	// A pending client moves to its type in the hub loop; a resumed client
+	// takes the place its previous connection holds
+	pending := client.Type() == ClientTypePending
+	if pending {
+		switch err := h.changeClientType(client, &handshake, resumed != nil); err {
+		case nil:
+		case ErrClientLimit:
+			client.SendJSON(map[string]interface{}{
+				"type":         "error",
+				"error":        "client_limit_reached",
+				"message_type": "handshake_response",
+				"client_type":  handshake.ClientType,
+			})
+			h.dropClient(client, "client_limit")
+			return
+		default:
+			log.Printf("❌ Handshake of %s not applied: %v", client.username, err)
+			return
+		}
+	}
+
 	// Mark handshake as complete
+	client.metadata.Store(&metadata)
 	client.MarkHandshakeComplete()
-
-	// Update client type - just change the field, hub.Run() will handle map updates
-	log.Printf("🔍 Current client type: %s (checking if pending)", client.clientType)
-	if client.clientType == ClientTypePending {
-		log.Printf("✅ Client is pending, updating type to %s", handshake.ClientType)
-
-		// Update client type field (this will be picked up by hub.Run() when it processes register)
-		oldType := client.clientType
-		client.clientType = handshake.ClientType
-
-		// If client is already registered in hub, we need to move it to the correct map
-		log.Printf("🔒 handleHandshake: Attempting to lock mutex...")
-		h.mu.Lock()
-		log.Printf("✅ handleHandshake: Mutex locked")
-		if clients, ok := h.clients[oldType]; ok {
-			if _, exists := clients[client]; exists {
-				// Client is already in hub, move it to new type
-				delete(clients, client)
-				if h.clients[client.clientType] == nil {
-					h.clients[client.clientType] = make(map[*Client]bool)
-				}
-				h.clients[client.clientType][client] = true
-				log.Printf("🔄 Moved client from %s to %s", oldType, client.clientType)
-			}
+	detail := map[string]interface{}{
+		"client_type": handshake.ClientType,
+	}
+	metadata.addTo(detail)
+	h.auditEvent(client, AuditHandshake, detail)
+
+	if pending {
+		h.auditEvent(client, AuditPromote, map[string]interface{}{
+			"from": ClientTypePending,
+			"to":   client.Type(),
+		})
+
+		// A resumed client takes over the previous connection's place
+		var previous *Client
+		if resumed != nil {
+			previous = h.completeResume(client, resumed)
 		}
-		log.Printf("🔓 handleHandshake: About to unlock mutex...")
-		h.mu.Unlock()
-		log.Printf("✅ handleHandshake: Mutex unlocked")
 
-		log.Printf("✅ Client handshake completed: type=%s, user=%s",
-			client.clientType, client.username)
+		log.Printf("✅ Client handshake completed: type=%s, user=%s, room=%s, robot=%s",
+			client.Type(), client.username, RoomID(client.Room()), client.RobotID())
 
-		// Check if video clients are available
-		videoAvailable := h.GetClientCountByType(ClientTypeVideo) > 0
+		// Check if video/audio clients are available in the room (for the
+		// client's robot, if it registered for one)
+		videoAvailable := h.roomClientCount(client.Room(), client.RobotID(), ClientTypeVideo) > 0
+		audioAvailable := h.roomClientCount(client.Room(), client.RobotID(), ClientTypeAudio) > 0
 
-		// Send Python-compatible confirmation
+		// Send Python-compatible confirmation, already in the chosen encoding
+		encoding := EncodingJSON
+		if handshake.Encoding != "" {
+			encoding = handshake.Encoding
+			client.encoding.Store(encoding)
+		}
 		response := map[string]interface{}{
 			"type":                    "connection_established",
-			"client_type":             client.clientType,
+			"client_type":             client.Type(),
 			"status":                  "connected",
+			"room":                    RoomID(client.Room()),
+			"encoding":                encoding,
 			"video_clients_available": videoAvailable,
+			"audio_clients_available": audioAvailable,
 			"timestamp":               time.Now().Unix(),
 		}
+		if client.RobotID() != "" {
+			response["robot_id"] = client.RobotID()
+		}
+		if client.ProtocolVersion() >= 2 {
+			response["protocol_version"] = client.ProtocolVersion()
+			response["features"] = client.Features()
+		}
+		if token := h.issueResumeToken(client, handshake); token != "" {
+			response["resume_token"] = token
+			response["resume_window_ms"] = h.resume.window.Milliseconds()
+		}
+		if previous != nil {
+			response["resumed"] = true
+			response["connection_id"] = client.GetConnectionID()
+		}
 		if err := client.SendJSON(response); err != nil {
 			log.Printf("❌ Failed to send connection_established to %s: %v", client.username, err)
 			return
 		}
 		log.Printf("📨 Sent connection_established to %s", client.username)
 
+		// Nothing changed for the rest of the room; the client gets what it
+		// missed
+		if previous != nil {
+			moved := h.moveQueued(previous, client)
+			log.Printf("▶️  %s (%s) resumed after %v, %d queued messages delivered",
+				client.username, client.Type(), offlineFor(previous).Round(time.Millisecond), moved)
+			if client.Type() == ClientTypeControl {
+				h.retransmitCommands(client)
+			}
+			return
+		}
+
+		// A robot that reconnected gets the commands it did not acknowledge
+		if client.Type() == ClientTypeControl {
+			h.retransmitCommands(client)
+		}
+
+		// A dashboard that just opened shows the current state instead of
+		// waiting for the next update
+		if client.Type() == ClientTypeWeb {
+			h.sendStateSnapshot(client)
+		}
+
 		// If video client connected, notify web clients
 		if handshake.ClientType == ClientTypeVideo {
-			h.notifyWebClientsVideoReady()
+			h.notifyWebClientsVideoReady(client)
+		}
+
+		// If audio client connected, notify web clients
+		if handshake.ClientType == ClientTypeAudio {
+			h.notifyWebClientsAudioReady(client)
 		}
 	}
 }
 
-// notifyWebClientsVideoReady notifies web clients that video is available
-func (h *Hub) notifyWebClientsVideoReady() {
+// notifyWebClientsVideoReady notifies web clients in a video client's room
+// (watching its robot, if it registered for one) that video is available.
+// The connection ID lets them address this video client directly; its
+// metadata tells them what it runs.
+func (h *Hub) notifyWebClientsVideoReady(video *Client) {
 	notification := map[string]interface{}{
-		"type":      "video_client_ready",
-		"status":    "ready",
-		"timestamp": time.Now().Unix(),
+		"type":          "video_client_ready",
+		"status":        "ready",
+		"connection_id": video.connectionID,
+		"timestamp":     time.Now().Unix(),
 	}
+	if video.RobotID() != "" {
+		notification["robot_id"] = video.RobotID()
+	}
+	video.Metadata().addTo(notification)
 
 	data, err := json.Marshal(notification)
 	if err != nil {
@@ -230,9 +376,33 @@ func (h *Hub) notifyWebClientsVideoReady() {
 		return
 	}
 
-	h.BroadcastToType(ClientTypeWeb, data)
-	log.Printf("📹 Notified %d web clients that video is ready",
-		h.GetClientCountByType(ClientTypeWeb))
+	delivered := h.broadcastToRobot(video.Room(), video.RobotID(), []ClientType{ClientTypeWeb}, data)
+	log.Printf("📹 Notified %d web clients that video is ready", delivered)
+}
+
+// notifyWebClientsAudioReady notifies web clients in an audio client's
+// room (watching its robot, if it registered for one) that the audio
+// intercom is available
+func (h *Hub) notifyWebClientsAudioReady(audio *Client) {
+	notification := map[string]interface{}{
+		"type":          "audio_client_ready",
+		"status":        "ready",
+		"connection_id": audio.connectionID,
+		"timestamp":     time.Now().Unix(),
+	}
+	if audio.RobotID() != "" {
+		notification["robot_id"] = audio.RobotID()
+	}
+	audio.Metadata().addTo(notification)
+
+	data, err := json.Marshal(notification)
+	if err != nil {
+		log.Printf("Failed to marshal audio ready notification: %v", err)
+		return
+	}
+
+	delivered := h.broadcastToRobot(audio.Room(), audio.RobotID(), []ClientType{ClientTypeWeb}, data)
+	log.Printf("🔊 Notified %d web clients that audio is ready", delivered)
 }
 
 // handlePing responds to ping messages with pong
@@ -251,39 +421,118 @@ func (h *Hub) handlePing(client *Client, rawMessage []byte) {
 }
 
 // handleWebRTCSignaling routes WebRTC signaling messages
-func (h *Hub) handleWebRTCSignaling(sender *Client, msgType string, rawMessage []byte) {
-	switch sender.clientType {
+//
+// Messages carrying "media": "audio" belong to an audio intercom session and
+// are exchanged between web clients and audio clients; everything else is
+// treated as part of the video session. A web client's offer opens a
+// signaling session with the one media client it addresses (see
+// signaling.go); answers and ICE candidates only travel within their
+// session. With a backplane, signaling for clients on other instances is
+// relayed without a session.
+func (h *Hub) handleWebRTCSignaling(sender *Client, msgType string, rawMessage []byte, target routeTarget) {
+	var envelope signalingEnvelope
+	if err := json.Unmarshal(rawMessage, &envelope); err != nil {
+		logging.Sampled("ws_invalid_message", "Invalid %s signaling message from %s: %v", msgType, sender.Type(), err)
+		return
+	}
+
+	// Signaling never leaves the sender's room
+	var destination ClientType
+	media := MediaVideo
+	switch sender.Type() {
 	case ClientTypeWeb:
-		// Web client's offer/ice-candidate goes to video client
-		h.BroadcastToType(ClientTypeVideo, rawMessage)
-		log.Printf("Routed %s from web to %d video clients",
-			msgType, h.GetClientCountByType(ClientTypeVideo))
+		// Web client's offer/ice-candidate goes to a video or audio client
+		destination = ClientTypeVideo
+		if envelope.Media == MediaAudio {
+			destination = ClientTypeAudio
+			media = MediaAudio
+		}
 
 	case ClientTypeVideo:
 		// Video client's answer/ice-candidate goes to web clients
-		h.BroadcastToType(ClientTypeWeb, rawMessage)
-		log.Printf("Routed %s from video to %d web clients",
-			msgType, h.GetClientCountByType(ClientTypeWeb))
+		destination = ClientTypeWeb
+
+	case ClientTypeAudio:
+		// Audio client's answer/ice-candidate goes to web clients
+		destination = ClientTypeWeb
+		media = MediaAudio
 
 	default:
-		log.Printf("Unexpected WebRTC signaling from %s", sender.clientType)
+		log.Printf("Unexpected WebRTC signaling from %s", sender.Type())
+		return
 	}
+
+	var session *signalingSession
+	if msgType == "offer" && sender.Type() == ClientTypeWeb && envelope.SessionID == "" {
+		peer, ok := h.signalingPeer(sender, destination, target)
+		if !ok {
+			return
+		}
+		if peer == nil {
+			delivered := h.broadcastTo(sender.Room(), target, []ClientType{destination}, rawMessage)
+			if delivered == 0 {
+				h.deadLetter(sender, msgType, rawMessage, target, destination)
+			}
+			return
+		}
+		if session = h.openSignalingSession(sender, peer, media); session == nil {
+			return
+		}
+	} else {
+		var reason string
+		session, reason = h.findSignalingSession(sender, media, envelope.SessionID, target)
+		if session == nil && reason == "unknown_session" && envelope.SessionID == "" && h.relay.backplane != nil {
+			// The other party may have sent its offer through another instance
+			h.broadcastTo(sender.Room(), target, []ClientType{destination}, rawMessage)
+			return
+		}
+		if session == nil {
+			logging.Sampled("ws_signaling_session", "📡 %s %s from %s outside a signaling session: %s",
+				media, msgType, sender.username, reason)
+			notice := map[string]interface{}{
+				"type":         "error",
+				"error":        reason,
+				"message_type": msgType,
+			}
+			if envelope.SessionID != "" {
+				notice["session_id"] = envelope.SessionID
+			}
+			sender.SendJSON(notice)
+			return
+		}
+	}
+
+	if envelope.SessionID == "" {
+		rawMessage = withSessionID(rawMessage, session.id)
+	}
+	recipient := session.other(sender)
+	if !h.deliver(recipient, outbound{data: rawMessage}) {
+		return
+	}
+	h.throughput.countFanout(1)
+	log.Printf("Routed %s %s from %s to %s in session %s", media, msgType, sender.Type(), recipient.Type(), session.id)
 }
 
-// broadcastExceptSender sends message to all clients except the sender
-func (h *Hub) broadcastExceptSender(sender *Client, message []byte) {
+// broadcastExceptSender sends message to all clients in the sender's room
+// matching target, except the sender
+func (h *Hub) broadcastExceptSender(sender *Client, message []byte, target routeTarget) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	for _, clients := range h.clients {
+	delivered := 0
+	for clientType, clients := range h.clients {
+		// Monitors already received a mirrored copy
+		if clientType == ClientTypeMonitor {
+			continue
+		}
 		for client := range clients {
-			if client != sender {
-				select {
-				case client.send <- message:
-				default:
-					go h.UnregisterClient(client)
-				}
+			if client == sender || client.Room() != sender.Room() || !target.matches(client) {
+				continue
+			}
+			if h.deliver(client, outbound{data: message}) {
+				delivered++
 			}
 		}
 	}
+	h.throughput.countFanout(delivered)
 }
@@ -323,3 +323,74 @@ func TestConnectionEstablishedStructure(t *testing.T) {
 		t.Error("Expected video_clients_available to be true")
 	}
 }
+
+// TestWebRTCSignalingAudioRouting tests that audio intercom signaling is
+// exchanged between web and audio clients only
+func TestWebRTCSignalingAudioRouting(t *testing.T) {
+	hub := NewHub()
+	web := newTestClient(hub, ClientTypeWeb, "operator")
+	video := newTestClient(hub, ClientTypeVideo, "video-pi")
+	audio := newTestClient(hub, ClientTypeAudio, "audio-pi")
+
+	// Audio offer from web goes to audio client only
+	hub.RouteMessage(web, []byte(`{"type":"offer","media":"audio","sdp":"test_sdp"}`))
+	if got := len(drainMessages(audio)); got != 1 {
+		t.Errorf("Expected 1 message for audio client, got %d", got)
+	}
+	if got := len(drainMessages(video)); got != 0 {
+		t.Errorf("Expected 0 messages for video client, got %d", got)
+	}
+
+	// Video offer from web goes to video client only
+	hub.RouteMessage(web, []byte(`{"type":"offer","sdp":"test_sdp"}`))
+	if got := len(drainMessages(video)); got != 1 {
+		t.Errorf("Expected 1 message for video client, got %d", got)
+	}
+	if got := len(drainMessages(audio)); got != 0 {
+		t.Errorf("Expected 0 messages for audio client, got %d", got)
+	}
+
+	// Each offer opened a signaling session
+	if got := messageTypes(web); len(got) != 2 || got[0] != "signaling_session" || got[1] != "signaling_session" {
+		t.Errorf("Expected 2 signaling sessions for web client, got %v", got)
+	}
+
+	// Answer from audio client goes back to web clients
+	hub.RouteMessage(audio, []byte(`{"type":"answer","media":"audio","sdp":"test_sdp"}`))
+	if got := len(drainMessages(web)); got != 1 {
+		t.Errorf("Expected 1 message for web client, got %d", got)
+	}
+}
+
+// TestHandshakeAcceptsAudioClient tests that audio is a valid handshake client type
+func TestHandshakeAcceptsAudioClient(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	web := newTestClient(hub, ClientTypeWeb, "operator")
+	client := newTestClient(hub, ClientTypePending, "audio-pi")
+	client.SetConnectionID("test_123")
+
+	hub.RouteMessage(client, []byte(`{"type":"handshake_response","connection_id":"test_123","client_type":"audio"}`))
+
+	if !client.IsHandshakeComplete() {
+		t.Fatal("Expected handshake to complete for audio client")
+	}
+	if client.Type() != ClientTypeAudio {
+		t.Errorf("Expected client type audio, got %s", client.Type())
+	}
+	if hub.GetClientCountByType(ClientTypeAudio) != 1 {
+		t.Errorf("Expected 1 audio client, got %d", hub.GetClientCountByType(ClientTypeAudio))
+	}
+
+	// Web client should be told the intercom is available
+	var notified bool
+	for _, raw := range drainMessages(web) {
+		var msg Message
+		if err := json.Unmarshal(raw, &msg); err == nil && msg.Type == "audio_client_ready" {
+			notified = true
+		}
+	}
+	if !notified {
+		t.Error("Expected web client to receive audio_client_ready")
+	}
+}
@@ -0,0 +1,75 @@
+package websocket
+
+import "unicode"
+
+// Limits on client metadata, which ends up in admin listings and logs
+const (
+	// maxMetadataLength bounds each metadata string, in bytes
+	maxMetadataLength = 128
+
+	// maxCapabilities bounds the capabilities one client may report
+	maxCapabilities = 32
+)
+
+// ClientMetadata describes the device and software behind a connection as
+// the client reported it at handshake, e.g. which firmware a robot runs.
+// It is informational: nothing is authorized by it.
+type ClientMetadata struct {
+	DeviceID        string   `json:"device_id,omitempty"`
+	SoftwareVersion string   `json:"software_version,omitempty"`
+	Platform        string   `json:"platform,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+}
+
+// metadata returns the client metadata of a handshake
+func (hs *HandshakeResponse) metadata() ClientMetadata {
+	return ClientMetadata{
+		DeviceID:        hs.DeviceID,
+		SoftwareVersion: hs.SoftwareVersion,
+		Platform:        hs.Platform,
+		Capabilities:    hs.Capabilities,
+	}
+}
+
+// validMetadata reports whether metadata fits the limits and holds only
+// printable text
+func validMetadata(m ClientMetadata) bool {
+	if len(m.Capabilities) > maxCapabilities {
+		return false
+	}
+	for _, value := range append([]string{m.DeviceID, m.SoftwareVersion, m.Platform}, m.Capabilities...) {
+		if len(value) > maxMetadataLength {
+			return false
+		}
+		for _, r := range value {
+			if !unicode.IsPrint(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addTo sets the metadata's non-empty fields in a notification
+func (m ClientMetadata) addTo(fields map[string]interface{}) {
+	if m.DeviceID != "" {
+		fields["device_id"] = m.DeviceID
+	}
+	if m.SoftwareVersion != "" {
+		fields["software_version"] = m.SoftwareVersion
+	}
+	if m.Platform != "" {
+		fields["platform"] = m.Platform
+	}
+	if len(m.Capabilities) > 0 {
+		fields["capabilities"] = m.Capabilities
+	}
+}
+
+// Metadata returns what the client reported about itself at handshake
+func (c *Client) Metadata() ClientMetadata {
+	if metadata := c.metadata.Load(); metadata != nil {
+		return *metadata
+	}
+	return ClientMetadata{}
+}
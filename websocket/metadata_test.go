@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestHandshakeMetadata tests that device and software details reported at
+// handshake are kept and shown to operators
+func TestHandshakeMetadata(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	web := newTestClient(hub, ClientTypeWeb, "operator")
+	camera := newTestClient(hub, ClientTypePending, "camera-pi")
+	camera.SetConnectionID("conn_camera")
+
+	hub.RouteMessage(camera, []byte(`{"type":"handshake_response","connection_id":"conn_camera","client_type":"video",`+
+		`"device_id":"pi-7","software_version":"1.4.2","platform":"linux/arm64","capabilities":["h264","ptz"]}`))
+	if !camera.IsHandshakeComplete() {
+		t.Fatal("Expected handshake to complete")
+	}
+
+	want := ClientMetadata{DeviceID: "pi-7", SoftwareVersion: "1.4.2", Platform: "linux/arm64", Capabilities: []string{"h264", "ptz"}}
+	if got := camera.Metadata(); got.DeviceID != want.DeviceID || got.SoftwareVersion != want.SoftwareVersion ||
+		got.Platform != want.Platform || strings.Join(got.Capabilities, ",") != "h264,ptz" {
+		t.Errorf("Unexpected metadata %+v", got)
+	}
+
+	var ready map[string]interface{}
+	for _, data := range drainMessages(web) {
+		json.Unmarshal(data, &ready)
+		if ready["type"] == "video_client_ready" {
+			break
+		}
+	}
+	if ready["type"] != "video_client_ready" || ready["software_version"] != "1.4.2" || ready["device_id"] != "pi-7" {
+		t.Errorf("Expected metadata in video_client_ready, got %v", ready)
+	}
+
+	for _, info := range hub.Connections() {
+		if info.ConnectionID == "conn_camera" && info.SoftwareVersion != "1.4.2" {
+			t.Errorf("Expected metadata in the admin listing, got %+v", info)
+		}
+	}
+}
+
+// TestHandshakeInvalidMetadata tests that oversized or unprintable
+// metadata fails the handshake
+func TestHandshakeInvalidMetadata(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	for _, fields := range []string{
+		`"software_version":"1.0\u0007"`,
+		`"platform":"` + strings.Repeat("x", maxMetadataLength+1) + `"`,
+		`"capabilities":["` + strings.Repeat(`c","`, maxCapabilities) + `c"]`,
+	} {
+		robot := newTestClient(hub, ClientTypePending, "robot-1")
+		robot.SetConnectionID("conn_robot")
+		hub.RouteMessage(robot, []byte(`{"type":"handshake_response","connection_id":"conn_robot","client_type":"control",`+fields+`}`))
+		if robot.IsHandshakeComplete() {
+			t.Errorf("Expected handshake with %s to fail", fields)
+		}
+		var reply map[string]interface{}
+		json.Unmarshal(drainMessages(robot)[0], &reply)
+		if reply["error"] != "invalid_metadata" {
+			t.Errorf("Expected invalid_metadata for %s, got %v", fields, reply)
+		}
+	}
+}
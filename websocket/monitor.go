@@ -0,0 +1,140 @@
+package websocket
+
+import (
+	"encoding/json"
+	"oculo-pilot-server/logging"
+	"time"
+)
+
+// ScopeAdmin grants administrative access; monitor connections require it
+// explicitly (tokens without scopes are not enough)
+const ScopeAdmin = "api:admin"
+
+// MonitorFilter selects which routed messages a monitor connection receives.
+// Empty lists match everything.
+type MonitorFilter struct {
+	Types []string `json:"types,omitempty"`
+	Rooms []string `json:"rooms,omitempty"`
+}
+
+// redactedFields are replaced in mirrored messages so monitors never see
+// credentials
+var redactedFields = map[string]bool{
+	"token":         true,
+	"auth_token":    true,
+	"access_token":  true,
+	"refresh_token": true,
+	"password":      true,
+	"secret":        true,
+	"setup_token":   true,
+	"credential":    true,
+}
+
+// matches reports whether a message from a room passes the filter
+func (f *MonitorFilter) matches(msgType, room string) bool {
+	if f == nil {
+		return true
+	}
+	return (len(f.Types) == 0 || containsString(f.Types, msgType)) &&
+		(len(f.Rooms) == 0 || containsString(f.Rooms, room))
+}
+
+// containsString reports whether value is listed
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeMonitor lets only admin tokens become monitors and records the
+// connection's filter
+func (h *Hub) authorizeMonitor(client *Client, filter *MonitorFilter) bool {
+	if client.scopes == nil || !hasScope(client.scopes, ScopeAdmin) {
+		logging.Sampled("ws_insufficient_scope", "🚫 Monitor handshake from %s rejected: missing scope %s",
+			client.username, ScopeAdmin)
+		client.SendJSON(map[string]interface{}{
+			"type":           "error",
+			"error":          "insufficient_scope",
+			"message_type":   "handshake_response",
+			"required_scope": ScopeAdmin,
+		})
+		return false
+	}
+
+	client.monitor = filter
+	return true
+}
+
+// mirrorToMonitors sends a redacted copy of a routed message to every
+// monitor connection whose filter matches it
+func (h *Hub) mirrorToMonitors(sender *Client, msgType string, rawMessage []byte) {
+	h.mu.RLock()
+	var monitors []*Client
+	for client := range h.clients[ClientTypeMonitor] {
+		if client != sender && client.monitor.matches(msgType, sender.Room()) {
+			monitors = append(monitors, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(monitors) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        "monitor",
+		"from":        sender.username,
+		"client_type": sender.Type(),
+		"room":        sender.Room(),
+		"message":     json.RawMessage(RedactMessage(rawMessage)),
+		"timestamp":   time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return
+	}
+
+	for _, monitor := range monitors {
+		// A slow inspector loses copies rather than its connection
+		if !monitor.enqueue(outbound{data: data}) {
+			logging.Sampled("ws_monitor_dropped", "⚠️  Monitor %s is not keeping up, dropped %s copy",
+				monitor.username, msgType)
+		}
+	}
+}
+
+// RedactMessage replaces credential fields at any depth, for copies of
+// messages that leave the routing path (monitors, recordings)
+func RedactMessage(rawMessage []byte) []byte {
+	var message interface{}
+	if err := json.Unmarshal(rawMessage, &message); err != nil {
+		return rawMessage
+	}
+
+	redacted, err := json.Marshal(redactValue(message))
+	if err != nil {
+		return rawMessage
+	}
+	return redacted
+}
+
+// redactValue walks a decoded JSON value
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redactedFields[key] {
+				v[key] = "[REDACTED]"
+				continue
+			}
+			v[key] = redactValue(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestMonitorMirrorsMessages tests that monitors receive redacted,
+// filtered copies of routed messages
+func TestMonitorMirrorsMessages(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+	monitor := newTestClient(hub, ClientTypeMonitor, "admin")
+	filtered := newTestClient(hub, ClientTypeMonitor, "auditor")
+	filtered.monitor = &MonitorFilter{Types: []string{"control_response"}}
+
+	hub.RouteMessage(alice, []byte(`{"type":"control_command","id":"cmd-1","data":{"token":"secret-jwt"}}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Fatal("Expected command to still reach the robot")
+	}
+
+	copies := drainMessages(monitor)
+	if len(copies) != 1 {
+		t.Fatalf("Expected 1 mirrored message, got %d", len(copies))
+	}
+	if strings.Contains(string(copies[0]), "secret-jwt") {
+		t.Errorf("Expected token to be redacted, got %s", copies[0])
+	}
+	var mirrored struct {
+		Type    string          `json:"type"`
+		From    string          `json:"from"`
+		Message json.RawMessage `json:"message"`
+	}
+	if err := json.Unmarshal(copies[0], &mirrored); err != nil {
+		t.Fatalf("Invalid mirrored message: %v", err)
+	}
+	if mirrored.Type != "monitor" || mirrored.From != "alice" || !strings.Contains(string(mirrored.Message), "cmd-1") {
+		t.Errorf("Unexpected mirrored message: %s", copies[0])
+	}
+
+	if got := len(drainMessages(filtered)); got != 0 {
+		t.Errorf("Expected filtered monitor to skip control_command, got %d", got)
+	}
+	hub.RouteMessage(robot, []byte(`{"type":"control_response"}`))
+	if got := len(drainMessages(filtered)); got != 1 {
+		t.Errorf("Expected filtered monitor to receive control_response, got %d", got)
+	}
+}
+
+// TestMonitorIsReadOnly tests that monitors cannot send commands
+func TestMonitorIsReadOnly(t *testing.T) {
+	hub := NewHub()
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+	monitor := newTestClient(hub, ClientTypeMonitor, "admin")
+
+	hub.RouteMessage(monitor, []byte(`{"type":"emergency_stop"}`))
+	if got := len(drainMessages(robot)); got != 0 {
+		t.Errorf("Expected monitor message to be ignored, got %d deliveries", got)
+	}
+}
+
+// TestMonitorRequiresAdmin tests the monitor handshake scope check
+func TestMonitorRequiresAdmin(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub, ClientTypePending, "pilot")
+	client.SetScopes([]string{ScopeView, ScopeControl})
+
+	if hub.authorizeMonitor(client, nil) {
+		t.Error("Expected non-admin monitor handshake to be rejected")
+	}
+	if replies := drainMessages(client); len(replies) != 1 || !strings.Contains(string(replies[0]), "insufficient_scope") {
+		t.Errorf("Expected insufficient_scope error, got %q", replies)
+	}
+
+	client.SetScopes([]string{ScopeView, ScopeAdmin})
+	if !hub.authorizeMonitor(client, nil) {
+		t.Error("Expected admin monitor handshake to be accepted")
+	}
+}
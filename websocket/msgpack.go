@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Frame encodings a client can choose at handshake (see also
+// EncodingProtobuf)
+const (
+	EncodingJSON    = "json"    // Text frames (default)
+	EncodingMsgpack = "msgpack" // Binary MessagePack frames, one message each
+)
+
+// validEncoding reports whether a handshake encoding is supported ("" is
+// JSON)
+func validEncoding(encoding string) bool {
+	switch encoding {
+	case "", EncodingJSON, EncodingMsgpack, EncodingProtobuf:
+		return true
+	}
+	return false
+}
+
+// msgpackToJSON converts a MessagePack frame to the JSON the hub routes.
+// Binary values become base64 strings, as encoding/json does for []byte.
+func msgpackToJSON(frame []byte) ([]byte, error) {
+	var message interface{}
+	if err := msgpack.Unmarshal(frame, &message); err != nil {
+		return nil, err
+	}
+	if _, ok := message.(map[string]interface{}); !ok {
+		return nil, errors.New("message must be a map")
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		// Maps with non-string keys have no JSON form
+		return nil, fmt.Errorf("message has no JSON form: %w", err)
+	}
+	return data, nil
+}
+
+// jsonToMsgpack converts a routed JSON message to a MessagePack frame.
+// Integers stay integers, and numbers use the smallest encoding that holds
+// them.
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var message interface{}
+	if err := decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.UseCompactInts(true)
+	encoder.UseCompactFloats(true)
+	if err := encoder.Encode(convertNumbers(message)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// convertNumbers replaces json.Number values with int64 or float64
+func convertNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = convertNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = convertNumbers(item)
+		}
+	}
+	return value
+}
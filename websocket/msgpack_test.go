@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// TestMsgpackTranscoding tests converting between JSON and MessagePack
+func TestMsgpackTranscoding(t *testing.T) {
+	original := `{"type":"location_update","data":{"lat":37.5665,"speed":3,"tags":["a"]}}`
+	frame, err := jsonToMsgpack([]byte(original))
+	if err != nil {
+		t.Fatalf("jsonToMsgpack failed: %v", err)
+	}
+	if len(frame) >= len(original) {
+		t.Errorf("Expected MessagePack to be smaller than JSON (%d >= %d)", len(frame), len(original))
+	}
+
+	var decoded map[string]interface{}
+	msgpack.Unmarshal(frame, &decoded)
+	data := decoded["data"].(map[string]interface{})
+	if _, ok := data["speed"].(int8); !ok {
+		t.Errorf("Expected an integer to stay an integer, got %T", data["speed"])
+	}
+
+	back, err := msgpackToJSON(frame)
+	if err != nil {
+		t.Fatalf("msgpackToJSON failed: %v", err)
+	}
+	var a, b interface{}
+	json.Unmarshal([]byte(original), &a)
+	json.Unmarshal(back, &b)
+	if !jsonEqual(a, b) {
+		t.Errorf("Expected a round trip, got %s", back)
+	}
+
+	notMap, _ := msgpack.Marshal([]int{1, 2})
+	if _, err := msgpackToJSON(notMap); err == nil {
+		t.Error("Expected a non-map frame to be rejected")
+	}
+}
+
+// jsonEqual compares decoded JSON values
+func jsonEqual(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+// TestMsgpackHandshake tests negotiating MessagePack at handshake over a
+// real connection
+func TestMsgpackHandshake(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?token=good", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var request map[string]interface{}
+	if err := conn.ReadJSON(&request); err != nil || request["type"] != "handshake_request" {
+		t.Fatalf("Expected a JSON handshake_request, got %v (%v)", request, err)
+	}
+
+	response, _ := msgpack.Marshal(map[string]interface{}{
+		"type":          "handshake_response",
+		"connection_id": request["connection_id"],
+		"client_type":   "web",
+		"encoding":      "msgpack",
+	})
+	if err := conn.WriteMessage(websocket.BinaryMessage, response); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	messageType, frame, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	var established map[string]interface{}
+	if messageType != websocket.BinaryMessage || msgpack.Unmarshal(frame, &established) != nil {
+		t.Fatalf("Expected a MessagePack frame, got type %d", messageType)
+	}
+	if established["type"] != "connection_established" || established["encoding"] != "msgpack" {
+		t.Errorf("Unexpected confirmation: %v", established)
+	}
+}
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"net"
+	"strings"
+)
+
+// parseNetwork parses a whitelist entry: a CIDR or a single IPv4 or IPv6
+// address. IPv4-mapped IPv6 networks (::ffff:10.0.0.0/104) are converted
+// to their IPv4 form so they match IPv4 clients.
+func parseNetwork(entry string) (*net.IPNet, error) {
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: entry}
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(entry)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := network.Mask.Size()
+	if ip4 := network.IP.To4(); ip4 != nil && bits == 128 && ones >= 96 {
+		network = &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones-96, 32)}
+	}
+	return network, nil
+}
+
+// parseClientIP parses a client address in any of the forms seen in
+// RemoteAddr and X-Forwarded-For: "ip", "ip:port", "[ipv6]" or
+// "[ipv6]:port", with an optional IPv6 zone ("fe80::1%eth0"). IPv4-mapped
+// IPv6 addresses compare equal to their IPv4 form in net.IPNet.Contains.
+func parseClientIP(addr string) net.IP {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host)
+}
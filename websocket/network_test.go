@@ -0,0 +1,57 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWhitelistIPv6 tests IPv6, IPv4-mapped and zoned addresses against the
+// whitelist
+func TestWhitelistIPv6(t *testing.T) {
+	handler := NewHandler(NewHub(), &mockAuthValidator{}, []string{
+		"10.0.0.0/8",
+		"2001:db8::/32",
+		"::ffff:192.168.1.0/120",
+		"fe80::1",
+		"203.0.113.5",
+	}, true, time.Second, 4096)
+	if len(handler.allowedNetworks) != 5 {
+		t.Fatalf("Expected 5 networks, got %d", len(handler.allowedNetworks))
+	}
+
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{"10.1.2.3:5000", true},
+		{"::ffff:10.1.2.3", true},
+		{"[::ffff:10.1.2.3]:5000", true},
+		{"192.168.1.20", true},
+		{"[::ffff:192.168.1.20]:5000", true},
+		{"192.168.2.20", false},
+		{"2001:db8::1", true},
+		{"[2001:db8::1]", true},
+		{"[2001:db8::1]:443", true},
+		{"[fe80::1%eth0]:5000", true},
+		{"fe80::2", false},
+		{"203.0.113.5", true},
+		{"203.0.113.6", false},
+		{"2001:db9::1", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := handler.isIPAllowed(tt.addr); got != tt.allowed {
+			t.Errorf("isIPAllowed(%q) = %v, expected %v", tt.addr, got, tt.allowed)
+		}
+	}
+
+	// Both families are needed to allow everyone
+	v4only := NewHandler(NewHub(), &mockAuthValidator{}, []string{"0.0.0.0/0"}, true, time.Second, 4096)
+	if v4only.isIPAllowed("2001:db8::1") {
+		t.Error("Expected 0.0.0.0/0 not to cover IPv6 clients")
+	}
+	all := NewHandler(NewHub(), &mockAuthValidator{}, []string{"0.0.0.0/0", "::/0"}, true, time.Second, 4096)
+	if !all.isIPAllowed("2001:db8::1") || !all.isIPAllowed("::ffff:10.0.0.1") {
+		t.Error("Expected 0.0.0.0/0 and ::/0 to allow every client")
+	}
+}
@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// maxNoticeLength bounds the text of a system notice
+const maxNoticeLength = 1024
+
+// System notice levels
+const (
+	NoticeInfo     = "info"
+	NoticeWarning  = "warning"
+	NoticeCritical = "critical"
+)
+
+// noticeTypes are the client types a system notice may address; all of
+// them when none are selected
+var noticeTypes = []ClientType{
+	ClientTypeWeb, ClientTypeVideo, ClientTypeControl, ClientTypeTelemetry, ClientTypeAudio,
+}
+
+// ErrInvalidNotice is returned for a system notice that cannot be sent
+var ErrInvalidNotice = errors.New("invalid system notice")
+
+// SystemNotice is a server-originated announcement, e.g. "maintenance in 5
+// minutes, land the drone"
+type SystemNotice struct {
+	Message string `json:"message"`
+
+	// Level is info (default), warning or critical
+	Level string `json:"level"`
+
+	// ClientTypes selects who receives the notice (empty for all)
+	ClientTypes []ClientType `json:"client_types"`
+
+	// Room limits the notice to one room by ID, e.g. "default" (empty for
+	// every room)
+	Room string `json:"room"`
+}
+
+// validate checks a notice and fills in its default level
+func (n *SystemNotice) validate() error {
+	if n.Message == "" || len(n.Message) > maxNoticeLength {
+		return fmt.Errorf("%w: message must be 1-%d characters", ErrInvalidNotice, maxNoticeLength)
+	}
+	switch n.Level {
+	case "":
+		n.Level = NoticeInfo
+	case NoticeInfo, NoticeWarning, NoticeCritical:
+	default:
+		return fmt.Errorf("%w: level must be info, warning or critical", ErrInvalidNotice)
+	}
+	for _, clientType := range n.ClientTypes {
+		if !containsType(noticeTypes, clientType) {
+			return fmt.Errorf("%w: unknown client type %q", ErrInvalidNotice, clientType)
+		}
+	}
+	return nil
+}
+
+// BroadcastNotice sends a system_notice from an admin (from) to the
+// selected clients, on every instance with a backplane, and returns how
+// many local clients received it
+func (h *Hub) BroadcastNotice(notice SystemNotice, from string) (int, error) {
+	if err := notice.validate(); err != nil {
+		return 0, err
+	}
+
+	message, err := json.Marshal(map[string]interface{}{
+		"type":      "system_notice",
+		"message":   notice.Message,
+		"level":     notice.Level,
+		"from":      from,
+		"timestamp": time.Now().Unix(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	room, types := AllRooms, notice.ClientTypes
+	if notice.Room != "" {
+		room = roomFromID(notice.Room)
+	}
+	if len(types) == 0 {
+		types = noticeTypes
+	}
+
+	delivered := h.broadcastTo(room, routeTarget{}, types, message)
+	log.Printf("📢 System notice (%s) from %s delivered to %d clients: %q", notice.Level, from, delivered, notice.Message)
+	return delivered, nil
+}
@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestBroadcastNotice tests that system notices reach the selected client
+// types in every room
+func TestBroadcastNotice(t *testing.T) {
+	hub := NewHub()
+	web := newTestClient(hub, ClientTypeWeb, "alice")
+	control := newTestClient(hub, ClientTypeControl, "robot")
+	control.place("lab", "")
+	pending := newTestClient(hub, ClientTypePending, "unknown")
+
+	delivered, err := hub.BroadcastNotice(SystemNotice{Message: "maintenance in 5 minutes, land the drone"}, "admin")
+	if err != nil || delivered != 2 {
+		t.Fatalf("Expected delivery to 2 clients, got %d (%v)", delivered, err)
+	}
+	messages := drainMessages(control)
+	if len(messages) != 1 {
+		t.Fatalf("Expected one notice, got %d messages", len(messages))
+	}
+	var notice map[string]interface{}
+	json.Unmarshal(messages[0], &notice)
+	if notice["type"] != "system_notice" || notice["level"] != NoticeInfo || notice["from"] != "admin" {
+		t.Errorf("Unexpected notice %v", notice)
+	}
+	drainMessages(web)
+	if len(drainMessages(pending)) != 0 {
+		t.Error("Expected clients that have not identified themselves to be skipped")
+	}
+
+	// Selected types in one room
+	delivered, _ = hub.BroadcastNotice(SystemNotice{Message: "landing", Level: NoticeCritical, ClientTypes: []ClientType{ClientTypeWeb}, Room: "default"}, "admin")
+	if delivered != 1 || !reflect.DeepEqual(messageTypes(web), []string{"system_notice"}) || len(drainMessages(control)) != 0 {
+		t.Errorf("Expected only the web client in the default room, delivered to %d", delivered)
+	}
+
+	for _, invalid := range []SystemNotice{
+		{},
+		{Message: "hi", Level: "urgent"},
+		{Message: "hi", ClientTypes: []ClientType{ClientTypeMonitor}},
+	} {
+		if _, err := hub.BroadcastNotice(invalid, "admin"); !errors.Is(err, ErrInvalidNotice) {
+			t.Errorf("Expected ErrInvalidNotice for %+v, got %v", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AnyOrigin disables origin checking when listed in the allowed origins
+const AnyOrigin = "*"
+
+// originPattern is one allowed origin: [scheme://]host[:port], where host
+// may start with "*." to allow any subdomain
+type originPattern struct {
+	scheme string // "" allows http and https
+	host   string // Lowercase; "*.example.com" keeps the leading "*."
+	port   string // Must equal the origin's port ("" for the default)
+}
+
+// matches reports whether a parsed Origin header matches the pattern
+func (p originPattern) matches(origin *url.URL) bool {
+	if p.scheme != "" && origin.Scheme != p.scheme {
+		return false
+	}
+	if origin.Port() != p.port {
+		return false
+	}
+	host := strings.ToLower(origin.Hostname())
+	if suffix, ok := strings.CutPrefix(p.host, "*"); ok {
+		// "*.example.com" allows a.example.com and a.b.example.com, not
+		// example.com itself
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == p.host
+}
+
+// parseOriginPattern parses an ALLOWED_ORIGINS entry
+func parseOriginPattern(entry string) (originPattern, error) {
+	var pattern originPattern
+	rest := strings.ToLower(entry)
+	if scheme, host, ok := strings.Cut(rest, "://"); ok {
+		if scheme != "http" && scheme != "https" {
+			return pattern, fmt.Errorf("origin %q: scheme must be http or https", entry)
+		}
+		pattern.scheme = scheme
+		rest = host
+	}
+	if strings.ContainsAny(rest, "/?#@") {
+		return pattern, fmt.Errorf("origin %q: must not contain a path", entry)
+	}
+
+	pattern.host = rest
+	if host, port, err := net.SplitHostPort(rest); err == nil {
+		pattern.host, pattern.port = host, port
+	}
+	name := strings.TrimPrefix(pattern.host, "*.")
+	if name == "" || strings.Contains(name, "*") {
+		return pattern, fmt.Errorf("origin %q: wildcards are only allowed as a leading \"*.\"", entry)
+	}
+	return pattern, nil
+}
+
+// SetAllowedOrigins lets browsers on the given origins open WebSocket
+// connections, besides the server's own origin. Entries are
+// [scheme://]host[:port] with an optional leading "*." for subdomains;
+// AnyOrigin allows every origin. Call before serving.
+func (h *Handler) SetAllowedOrigins(origins []string) error {
+	h.anyOrigin = false
+	h.allowedOrigins = nil
+	for _, entry := range origins {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == AnyOrigin {
+			h.anyOrigin = true
+			continue
+		}
+		pattern, err := parseOriginPattern(entry)
+		if err != nil {
+			return err
+		}
+		h.allowedOrigins = append(h.allowedOrigins, pattern)
+	}
+	return nil
+}
+
+// checkOrigin reports whether a browser on the request's origin may connect,
+// preventing cross-site WebSocket hijacking. Requests without an Origin
+// header come from non-browser clients (robots, scripts) and are allowed.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	header := r.Header.Get("Origin")
+	if header == "" || h.anyOrigin {
+		return true
+	}
+
+	origin, err := url.Parse(header)
+	if err != nil || origin.Host == "" {
+		return false
+	}
+	if strings.EqualFold(origin.Host, r.Host) {
+		return true
+	}
+	for _, pattern := range h.allowedOrigins {
+		if pattern.matches(origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestCheckOrigin tests exact, host-only and wildcard origin patterns
+func TestCheckOrigin(t *testing.T) {
+	handler := NewHandler(NewHub(), &mockAuthValidator{}, nil, false, time.Second, 4096)
+	if err := handler.SetAllowedOrigins([]string{
+		"https://app.example.com", "http://localhost:3000", "dash.example.org", "https://*.robots.example.com",
+	}); err != nil {
+		t.Fatalf("SetAllowedOrigins() failed: %v", err)
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true}, // Not a browser
+		{"https://server.example.net", true},
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"http://app.example.com", false},
+		{"https://app.example.com:8443", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:4000", false},
+		{"http://dash.example.org", true},
+		{"https://dash.example.org", true},
+		{"https://pi-1.robots.example.com", true},
+		{"https://a.b.robots.example.com", true},
+		{"https://robots.example.com", false},
+		{"https://evilrobots.example.com", false},
+		{"https://app.example.com.evil.com", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://server.example.net/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := handler.checkOrigin(r); got != tt.allowed {
+			t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.allowed)
+		}
+	}
+
+	if err := handler.SetAllowedOrigins([]string{AnyOrigin}); err != nil {
+		t.Fatalf("SetAllowedOrigins(*) failed: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://server.example.net/ws", nil)
+	r.Header.Set("Origin", "https://anything.example")
+	if !handler.checkOrigin(r) {
+		t.Error("Expected * to allow any origin")
+	}
+}
+
+// TestInvalidOriginPatterns tests that malformed entries are rejected
+func TestInvalidOriginPatterns(t *testing.T) {
+	handler := NewHandler(NewHub(), &mockAuthValidator{}, nil, false, time.Second, 4096)
+	for _, entry := range []string{"ftp://example.com", "https://example.com/app", "https://app.*.example.com", "*.", "https://"} {
+		if err := handler.SetAllowedOrigins([]string{entry}); err == nil {
+			t.Errorf("Expected an error for %q", entry)
+		}
+	}
+}
+
+// TestCrossSiteUpgradeRejected tests that a browser on another site cannot
+// open a connection with a valid token
+func TestCrossSiteUpgradeRejected(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?token=good"
+
+	header := http.Header{"Origin": []string{"https://evil.example"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url, header); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a cross-site origin, got %v", err)
+	}
+
+	header.Set("Origin", server.URL)
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("Expected the server's own origin to connect: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// priorityQueueSize is how many emergency stops can wait for a client's
+// write pump. They bypass the send queue, so an e-stop is never stuck
+// behind a backlog of telemetry.
+const priorityQueueSize = 16
+
+// isEmergencyStop reports whether a message type is an emergency stop or
+// reset, which are never rate limited, rejected or narrowed to one robot
+func isEmergencyStop(msgType string) bool {
+	return msgType == "emergency_stop" || msgType == "emergency_stop_reset"
+}
+
+// enqueuePriority queues a message ahead of the send queue without
+// blocking and reports whether there was room
+func (c *Client) enqueuePriority(message outbound) bool {
+	select {
+	case c.priority <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// writePriority writes a priority message as its own frame
+func (c *Client) writePriority(message outbound) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if encoding := c.frameEncoding(); encoding != EncodingJSON {
+		return c.writeBinary(encoding, message.data)
+	}
+	c.hub.throughput.countOut(len(message.data))
+	return c.conn.WriteMessage(websocket.TextMessage, message.data)
+}
+
+// broadcastEmergency sends an emergency stop or reset to the control
+// clients in a room ahead of their queued messages, here and on other
+// instances, and returns how many local clients received it
+func (h *Hub) broadcastEmergency(room string, message []byte) int {
+	delivered := h.deliverEmergency(room, message)
+	h.publishRelay(relayEnvelope{Kind: relayEmergency, Room: room, Types: []ClientType{ClientTypeControl}, Data: message})
+	return delivered
+}
+
+// deliverEmergency is broadcastEmergency for the local control clients
+func (h *Hub) deliverEmergency(room string, message []byte) int {
+	h.mu.RLock()
+	var recipients []*Client
+	for client := range h.clients[ClientTypeControl] {
+		if client.Room() == room {
+			recipients = append(recipients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	delivered := 0
+	for _, client := range recipients {
+		if !client.enqueuePriority(outbound{data: message}) {
+			// A robot that is not taking emergency stops must not look
+			// connected
+			go h.dropClient(client, "priority_queue_full")
+			continue
+		}
+		h.recordMessage(client, DirectionOut, message)
+		delivered++
+	}
+	h.throughput.countFanout(delivered)
+	return delivered
+}
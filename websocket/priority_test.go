@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestEmergencyStopPriority tests that an emergency stop is queued ahead of
+// a backlog
+func TestEmergencyStopPriority(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	for i := 0; i < 200; i++ {
+		robot.enqueue(outbound{data: []byte(`{"type":"location_update"}`)})
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop","reason":"test"}`))
+	messages := drainMessages(robot)
+	if len(messages) != 201 || !strings.Contains(string(messages[0]), `"emergency_stop"`) {
+		t.Errorf("Expected the emergency stop first of 201 messages, got %d starting with %s", len(messages), messages[0])
+	}
+}
+
+// TestWritePumpPriority tests that the write pump sends a waiting
+// emergency stop before the send queue
+func TestWritePumpPriority(t *testing.T) {
+	hub := NewHub()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(hub, conn, ClientTypeControl, 1, "robot", 4096)
+		for i := 0; i < 200; i++ {
+			client.enqueue(outbound{data: []byte(`{"type":"location_update"}`)})
+		}
+		client.enqueuePriority(outbound{data: []byte(`{"type":"emergency_stop"}`)})
+		go client.writePump(func() {})
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	_, frame, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(frame) != `{"type":"emergency_stop"}` {
+		t.Errorf("Expected the emergency stop in the first frame, got %.60s", frame)
+	}
+}
@@ -0,0 +1,176 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	oculov1 "oculo-pilot-server/proto/oculo/v1"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// EncodingProtobuf sends and receives one oculo.v1.Envelope per binary
+// frame (see proto/oculo/v1/envelope.proto)
+const EncodingProtobuf = "protobuf"
+
+// protobufToJSON converts an Envelope frame to the JSON the hub routes
+func protobufToJSON(frame []byte) ([]byte, error) {
+	var envelope oculov1.Envelope
+	if err := proto.Unmarshal(frame, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Type == "" {
+		return nil, errors.New("envelope has no type")
+	}
+
+	message := envelope.GetFields().AsMap()
+	message["type"] = envelope.Type
+	putString(message, "robot_id", envelope.RobotId)
+	putString(message, "target_connection_id", envelope.TargetConnectionId)
+	putInt(message, "timestamp", envelope.Timestamp)
+
+	switch payload := envelope.Payload.(type) {
+	case *oculov1.Envelope_ControlCommand:
+		putString(message, "id", payload.ControlCommand.Id)
+		putStruct(message, "data", payload.ControlCommand.Data)
+		putInt(message, "max_age_ms", payload.ControlCommand.MaxAgeMs)
+	case *oculov1.Envelope_ControlResponse:
+		putString(message, "correlation_id", payload.ControlResponse.CorrelationId)
+		putString(message, "status", payload.ControlResponse.Status)
+		putStruct(message, "data", payload.ControlResponse.Data)
+	case *oculov1.Envelope_Telemetry:
+		putStruct(message, "data", payload.Telemetry.Data)
+	case *oculov1.Envelope_SessionDescription:
+		putString(message, "sdp", payload.SessionDescription.Sdp)
+		putString(message, "media", payload.SessionDescription.Media)
+	case *oculov1.Envelope_IceCandidate:
+		putString(message, "candidate", payload.IceCandidate.Candidate)
+		putString(message, "media", payload.IceCandidate.Media)
+	case *oculov1.Envelope_Error:
+		putString(message, "error", payload.Error.Error)
+		putString(message, "message_type", payload.Error.MessageType)
+		putString(message, "field", payload.Error.Field)
+		putString(message, "reason", payload.Error.Reason)
+	}
+	return json.Marshal(message)
+}
+
+// jsonToProtobuf converts a routed JSON message to an Envelope frame.
+// Fields that are not modeled, or whose value does not fit the modeled
+// type, are kept in Envelope.fields so nothing is lost.
+func jsonToProtobuf(data []byte) ([]byte, error) {
+	var message map[string]interface{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, err
+	}
+
+	envelope := &oculov1.Envelope{
+		Type:               takeString(message, "type"),
+		RobotId:            takeString(message, "robot_id"),
+		TargetConnectionId: takeString(message, "target_connection_id"),
+		Timestamp:          takeInt(message, "timestamp"),
+	}
+
+	switch envelope.Type {
+	case "control_command":
+		envelope.Payload = &oculov1.Envelope_ControlCommand{ControlCommand: &oculov1.ControlCommand{
+			Id:       takeString(message, "id"),
+			Data:     takeStruct(message, "data"),
+			MaxAgeMs: takeInt(message, "max_age_ms"),
+		}}
+	case "control_response":
+		envelope.Payload = &oculov1.Envelope_ControlResponse{ControlResponse: &oculov1.ControlResponse{
+			CorrelationId: takeString(message, "correlation_id"),
+			Status:        takeString(message, "status"),
+			Data:          takeStruct(message, "data"),
+		}}
+	case "location_update", "route_update":
+		envelope.Payload = &oculov1.Envelope_Telemetry{Telemetry: &oculov1.Telemetry{
+			Data: takeStruct(message, "data"),
+		}}
+	case "offer", "answer":
+		envelope.Payload = &oculov1.Envelope_SessionDescription{SessionDescription: &oculov1.SessionDescription{
+			Sdp:   takeString(message, "sdp"),
+			Media: takeString(message, "media"),
+		}}
+	case "ice-candidate":
+		envelope.Payload = &oculov1.Envelope_IceCandidate{IceCandidate: &oculov1.IceCandidate{
+			Candidate: takeString(message, "candidate"),
+			Media:     takeString(message, "media"),
+		}}
+	case "error":
+		envelope.Payload = &oculov1.Envelope_Error{Error: &oculov1.Error{
+			Error:       takeString(message, "error"),
+			MessageType: takeString(message, "message_type"),
+			Field:       takeString(message, "field"),
+			Reason:      takeString(message, "reason"),
+		}}
+	}
+
+	if len(message) > 0 {
+		fields, err := structpb.NewStruct(message)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Fields = fields
+	}
+	return proto.Marshal(envelope)
+}
+
+// takeString removes and returns a string field ("" when absent or not a
+// string, which stays in the map)
+func takeString(message map[string]interface{}, key string) string {
+	value, ok := message[key].(string)
+	if ok {
+		delete(message, key)
+	}
+	return value
+}
+
+// takeInt removes and returns an integral number field (0 when absent or
+// not an integer, which stays in the map)
+func takeInt(message map[string]interface{}, key string) int64 {
+	value, ok := message[key].(float64)
+	if !ok || value != math.Trunc(value) || math.Abs(value) > 1<<53 {
+		return 0
+	}
+	delete(message, key)
+	return int64(value)
+}
+
+// takeStruct removes and returns an object field (nil when absent or not
+// an object, which stays in the map)
+func takeStruct(message map[string]interface{}, key string) *structpb.Struct {
+	value, ok := message[key].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	fields, err := structpb.NewStruct(value)
+	if err != nil {
+		return nil
+	}
+	delete(message, key)
+	return fields
+}
+
+// putString sets a string field unless it is empty
+func putString(message map[string]interface{}, key, value string) {
+	if value != "" {
+		message[key] = value
+	}
+}
+
+// putInt sets a number field unless it is zero
+func putInt(message map[string]interface{}, key string, value int64) {
+	if value != 0 {
+		message[key] = value
+	}
+}
+
+// putStruct sets an object field unless it is unset
+func putStruct(message map[string]interface{}, key string, value *structpb.Struct) {
+	if value != nil {
+		message[key] = value.AsMap()
+	}
+}
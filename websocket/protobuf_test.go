@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	oculov1 "oculo-pilot-server/proto/oculo/v1"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestProtobufTranscoding tests converting between JSON and Envelope
+func TestProtobufTranscoding(t *testing.T) {
+	tests := []string{
+		`{"type":"control_command","id":"cmd-1","data":{"action":"forward","speed":0.5},"max_age_ms":150,"robot_id":"rover-1"}`,
+		`{"type":"control_command","id":7,"data":{"action":"stop"}}`, // Numeric IDs stay numeric
+		`{"type":"offer","sdp":"v=0","media":"video","target_connection_id":"conn-1"}`,
+		`{"type":"location_update","data":{"lat":37.5665},"timestamp":1705734000123,"extra":[1,"a"]}`,
+		`{"type":"error","error":"invalid_message","message_type":"offer","field":"sdp","reason":"is required"}`,
+		`{"type":"security_event","event":"ip_banned","remote_addr":"203.0.113.7"}`,
+	}
+
+	for _, original := range tests {
+		frame, err := jsonToProtobuf([]byte(original))
+		if err != nil {
+			t.Fatalf("jsonToProtobuf(%s) failed: %v", original, err)
+		}
+		back, err := protobufToJSON(frame)
+		if err != nil {
+			t.Fatalf("protobufToJSON failed for %s: %v", original, err)
+		}
+		var a, b interface{}
+		json.Unmarshal([]byte(original), &a)
+		json.Unmarshal(back, &b)
+		if !jsonEqual(a, b) {
+			t.Errorf("Round trip changed %s into %s", original, back)
+		}
+	}
+
+	// Modeled fields land in the typed payload
+	frame, _ := jsonToProtobuf([]byte(tests[0]))
+	var envelope oculov1.Envelope
+	proto.Unmarshal(frame, &envelope)
+	if envelope.GetControlCommand().GetId() != "cmd-1" || envelope.RobotId != "rover-1" || envelope.Fields != nil {
+		t.Errorf("Unexpected envelope: %v", &envelope)
+	}
+
+	untyped, _ := proto.Marshal(&oculov1.Envelope{RobotId: "rover-1"})
+	if _, err := protobufToJSON(untyped); err == nil {
+		t.Error("Expected an envelope without a type to be rejected")
+	}
+}
+
+// TestProtobufHandshake tests that a client choosing protobuf exchanges
+// envelopes in binary frames
+func TestProtobufHandshake(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?token=good", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var request map[string]interface{}
+	if err := conn.ReadJSON(&request); err != nil {
+		t.Fatalf("Expected a JSON handshake_request: %v", err)
+	}
+	conn.WriteJSON(map[string]interface{}{
+		"type":          "handshake_response",
+		"connection_id": request["connection_id"],
+		"client_type":   "web",
+		"encoding":      "protobuf",
+	})
+
+	readEnvelope := func() *oculov1.Envelope {
+		messageType, frame, err := conn.ReadMessage()
+		if err != nil || messageType != websocket.BinaryMessage {
+			t.Fatalf("Expected a binary frame, got type %d (%v)", messageType, err)
+		}
+		var envelope oculov1.Envelope
+		if err := proto.Unmarshal(frame, &envelope); err != nil {
+			t.Fatalf("Expected an envelope: %v", err)
+		}
+		return &envelope
+	}
+	if envelope := readEnvelope(); envelope.Type != "connection_established" ||
+		envelope.Fields.AsMap()["encoding"] != "protobuf" {
+		t.Fatalf("Unexpected confirmation: %v", envelope)
+	}
+	if envelope := readEnvelope(); envelope.Type != "state_snapshot" {
+		t.Fatalf("Expected the state snapshot after the confirmation, got %v", envelope)
+	}
+
+	// An envelope is routed like the equivalent JSON message
+	offer, _ := proto.Marshal(&oculov1.Envelope{Type: "offer"})
+	conn.WriteMessage(websocket.BinaryMessage, offer)
+	if envelope := readEnvelope(); envelope.GetError().GetError() != "invalid_message" || envelope.GetError().GetField() != "sdp" {
+		t.Errorf("Expected invalid_message for an offer without sdp, got %v", envelope)
+	}
+}
@@ -0,0 +1,256 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QoS levels of control commands
+const (
+	// QoSAtMostOnce delivers a command once, as before QoS existed
+	QoSAtMostOnce = 0
+
+	// QoSAtLeastOnce keeps a command until the robot acknowledges it and
+	// sends it again when the robot reconnects
+	QoSAtLeastOnce = 1
+)
+
+// DefaultCommandQoSTTL is how long an unacknowledged command is kept
+const DefaultCommandQoSTTL = 30 * time.Second
+
+// maxPendingCommands bounds the unacknowledged commands kept per robot;
+// the oldest are dropped first
+const maxPendingCommands = 64
+
+// commandQoS holds unacknowledged at-least-once commands per robot
+type commandQoS struct {
+	ttl time.Duration
+
+	// Pending commands by robot, oldest first (protected by mu)
+	pending map[pendingKey][]pendingCommand
+	mu      sync.Mutex
+
+	acked         atomic.Int64
+	retransmitted atomic.Int64
+	expired       atomic.Int64
+}
+
+// pendingKey identifies a robot across reconnections: its room and the
+// robot ID it registered for, or its username without one
+type pendingKey struct {
+	room  string
+	robot string
+}
+
+// pendingCommand is a command waiting for its robot's acknowledgement
+type pendingCommand struct {
+	id      string
+	from    string
+	data    []byte
+	expires time.Time
+}
+
+// qosFields are the fields of a control_command that select its QoS
+type qosFields struct {
+	QoS int `json:"qos"`
+}
+
+// CommandQoSStats summarizes at-least-once command delivery
+type CommandQoSStats struct {
+	Pending       int   `json:"pending"`
+	Acked         int64 `json:"acked"`
+	Retransmitted int64 `json:"retransmitted"`
+	Expired       int64 `json:"expired"`
+}
+
+// SetCommandQoSTTL sets how long at-least-once commands are kept for a
+// robot that has not acknowledged them (0 uses DefaultCommandQoSTTL). Call
+// before Run.
+func (h *Hub) SetCommandQoSTTL(d time.Duration) {
+	h.qos.ttl = d
+}
+
+// commandQoSTTL returns how long unacknowledged commands are kept
+func (h *Hub) commandQoSTTL() time.Duration {
+	if h.qos.ttl <= 0 {
+		return DefaultCommandQoSTTL
+	}
+	return h.qos.ttl
+}
+
+// robotKey returns the key of a control client's pending commands
+func robotKey(client *Client) pendingKey {
+	if robotID := client.RobotID(); robotID != "" {
+		return pendingKey{room: client.Room(), robot: robotID}
+	}
+	return pendingKey{room: client.Room(), robot: client.username}
+}
+
+// commandQoSLevel returns a control_command's QoS level
+func commandQoSLevel(rawMessage []byte) int {
+	var fields qosFields
+	if err := json.Unmarshal(rawMessage, &fields); err != nil {
+		return QoSAtMostOnce
+	}
+	return fields.QoS
+}
+
+// holdCommand keeps an at-least-once command for the robots it was
+// delivered to until they acknowledge it, and reports whether it did. A
+// command for a robot ID that no connected robot serves is kept for the
+// robot's return. deadline, when set, ends the command's life before the
+// TTL.
+func (h *Hub) holdCommand(from, room string, robots []*Client, target routeTarget, rawMessage []byte, deadline time.Time) bool {
+	id := correlationID(rawMessage, "id")
+	if id == "" || commandQoSLevel(rawMessage) < QoSAtLeastOnce {
+		return false
+	}
+
+	expires := time.Now().Add(h.commandQoSTTL())
+	if !deadline.IsZero() && deadline.Before(expires) {
+		expires = deadline
+	}
+	command := pendingCommand{id: id, from: from, data: rawMessage, expires: expires}
+
+	keys := make([]pendingKey, 0, len(robots))
+	for _, robot := range robots {
+		keys = append(keys, robotKey(robot))
+	}
+	if len(robots) == 0 && target.robotID != "" {
+		keys = append(keys, pendingKey{room: room, robot: target.robotID})
+	}
+
+	q := &h.qos
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[pendingKey][]pendingCommand)
+	}
+	for _, key := range keys {
+		commands := q.pending[key][:0:0]
+		for _, pending := range q.pending[key] {
+			// A retry replaces the command it repeats
+			if pending.id != id {
+				commands = append(commands, pending)
+			}
+		}
+		commands = append(commands, command)
+		for len(commands) > maxPendingCommands {
+			h.expireCommand(key, commands[0])
+			commands = commands[1:]
+		}
+		q.pending[key] = commands
+	}
+	return len(keys) > 0
+}
+
+// expireCommand dead-letters a command dropped before its robot
+// acknowledged it. The caller holds qos.mu.
+func (h *Hub) expireCommand(key pendingKey, command pendingCommand) {
+	h.qos.expired.Add(1)
+	h.recordDeadLetter(DeadLetter{
+		Type:       "control_command",
+		From:       command.from,
+		Room:       RoomID(key.room),
+		RobotID:    key.robot,
+		ClientType: ClientTypeControl,
+		Reason:     DeadLetterUnacknowledged,
+		Message:    command.data,
+	})
+}
+
+// ackCommand releases a command the robot acknowledged
+func (h *Hub) ackCommand(robot *Client, id string) {
+	if id == "" {
+		return
+	}
+
+	q := &h.qos
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := robotKey(robot)
+	commands := q.pending[key]
+	for i, pending := range commands {
+		if pending.id == id {
+			commands = append(commands[:i:i], commands[i+1:]...)
+			q.acked.Add(1)
+			break
+		}
+	}
+	if len(commands) == 0 {
+		delete(q.pending, key)
+	} else {
+		q.pending[key] = commands
+	}
+}
+
+// retransmitCommands sends a control client that just connected the
+// unacknowledged commands of its robot that its senders may still give it
+func (h *Hub) retransmitCommands(client *Client) {
+	now := time.Now()
+	key := robotKey(client)
+
+	q := &h.qos
+	q.mu.Lock()
+	commands := h.pruneCommands(key, now)
+	q.mu.Unlock()
+
+	sent := 0
+	for _, pending := range commands {
+		if h.controlPolicy != nil && !h.controlPolicy.CanControl(pending.from, client.username) {
+			continue
+		}
+		if !h.deliver(client, outbound{data: pending.data, deadline: pending.expires}) {
+			break
+		}
+		sent++
+	}
+	if sent > 0 {
+		q.retransmitted.Add(int64(sent))
+		log.Printf("🔁 Retransmitted %d unacknowledged commands to %s", sent, client.username)
+	}
+}
+
+// pruneCommands drops a robot's expired commands and returns the rest.
+// The caller holds qos.mu.
+func (h *Hub) pruneCommands(key pendingKey, now time.Time) []pendingCommand {
+	q := &h.qos
+	var live []pendingCommand
+	for _, pending := range q.pending[key] {
+		if now.After(pending.expires) {
+			h.expireCommand(key, pending)
+			continue
+		}
+		live = append(live, pending)
+	}
+	if len(live) == 0 {
+		delete(q.pending, key)
+	} else {
+		q.pending[key] = live
+	}
+	return append([]pendingCommand(nil), live...)
+}
+
+// commandQoSStats returns at-least-once delivery counters, dropping expired
+// commands first
+func (h *Hub) commandQoSStats() CommandQoSStats {
+	q := &h.qos
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	pending := 0
+	for key := range q.pending {
+		pending += len(h.pruneCommands(key, now))
+	}
+	return CommandQoSStats{
+		Pending:       pending,
+		Acked:         q.acked.Load(),
+		Retransmitted: q.retransmitted.Load(),
+		Expired:       q.expired.Load(),
+	}
+}
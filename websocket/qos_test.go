@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCommandQoS tests that at-least-once commands are resent to a robot
+// that reconnects until it acknowledges them, and expire after the TTL
+func TestCommandQoS(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClientIn(hub, "lab", "operator")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.place("lab", "r1")
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"c1","qos":1,"robot_id":"r1","data":{}}`))
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"c2","robot_id":"r1","data":{}}`))
+	if types := messageTypes(robot); len(types) != 2 {
+		t.Fatalf("Expected both commands, got %v", types)
+	}
+	if stats := hub.commandQoSStats(); stats.Pending != 1 {
+		t.Errorf("Expected only the at-least-once command to be kept, got %+v", stats)
+	}
+
+	// The robot reconnects without having acknowledged it
+	hub.removeClient(robot)
+	robot = newTestClient(hub, ClientTypeControl, "robot")
+	robot.place("lab", "r1")
+	hub.retransmitCommands(robot)
+	if types := messageTypes(robot); len(types) != 1 || types[0] != "control_command" {
+		t.Errorf("Expected the unacknowledged command again, got %v", types)
+	}
+
+	hub.RouteMessage(robot, []byte(`{"type":"command_ack","id":"c1"}`))
+	hub.retransmitCommands(robot)
+	if types := messageTypes(robot); len(types) != 0 {
+		t.Errorf("Expected nothing after the acknowledgement, got %v", types)
+	}
+
+	// A command for a robot that is offline waits for it
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"c3","qos":1,"robot_id":"r2","data":{}}`))
+	other := newTestClient(hub, ClientTypeControl, "robot2")
+	other.place("lab", "r2")
+	hub.retransmitCommands(other)
+	if types := messageTypes(other); len(types) != 1 {
+		t.Errorf("Expected the command held for the offline robot, got %v", types)
+	}
+	// A control response acknowledges it too
+	hub.RouteMessage(other, []byte(`{"type":"control_response","correlation_id":"c3"}`))
+
+	stats := hub.commandQoSStats()
+	if stats.Pending != 0 || stats.Acked != 2 || stats.Retransmitted != 2 {
+		t.Errorf("Unexpected QoS stats %+v", stats)
+	}
+
+	// An ID is needed to acknowledge a command
+	drainMessages(operator)
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","qos":1,"data":{}}`))
+	if types := messageTypes(operator); len(types) != 1 || types[0] != "error" {
+		t.Errorf("Expected an at-least-once command without ID to be rejected, got %v", types)
+	}
+	drainMessages(robot)
+
+	hub.SetCommandQoSTTL(time.Millisecond)
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"c4","qos":1,"robot_id":"r1","data":{}}`))
+	drainMessages(robot)
+	time.Sleep(5 * time.Millisecond)
+	hub.retransmitCommands(robot)
+	if types := messageTypes(robot); len(types) != 0 {
+		t.Errorf("Expected expired commands not to be resent, got %v", types)
+	}
+	if stats := hub.commandQoSStats(); stats.Expired != 1 {
+		t.Errorf("Expected one expired command, got %+v", stats)
+	}
+}
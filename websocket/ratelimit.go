@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"log"
+	"oculo-pilot-server/logging"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// rateLimitWarnInterval bounds how often a client over its limit is sent a
+// rate_limited warning
+const rateLimitWarnInterval = time.Second
+
+// RateLimit is a token bucket: PerSecond messages refill each second, up to
+// Burst (PerSecond when 0). A zero PerSecond disables limiting.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// burst returns the bucket size
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return l.PerSecond
+}
+
+// RateLimitConfig holds the message rate limits of WebSocket connections
+type RateLimitConfig struct {
+	Default RateLimit
+	Types   map[ClientType]RateLimit // Overrides Default per client type
+
+	// MaxStrikes closes a connection after this many warnings without the
+	// bucket refilling in between (0 never closes)
+	MaxStrikes int
+}
+
+// limit returns the rate limit for a client type
+func (c RateLimitConfig) limit(clientType ClientType) RateLimit {
+	if limit, ok := c.Types[clientType]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+// rateBucket tracks one client's tokens. It is only used from the client's
+// readPump, so it needs no lock.
+type rateBucket struct {
+	tokens   float64
+	last     time.Time
+	lastWarn time.Time
+	strikes  int
+}
+
+// take refills the bucket for the time since the last message and reports
+// whether a token was available
+func (b *rateBucket) take(limit RateLimit, now time.Time) bool {
+	burst := limit.burst()
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * limit.PerSecond
+		if b.tokens >= burst {
+			// The client went quiet long enough; forgive earlier warnings
+			b.tokens = burst
+			b.strikes = 0
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SetRateLimits limits how many messages each connection may send. Call
+// before Run.
+func (h *Hub) SetRateLimits(config RateLimitConfig) {
+	h.rateLimits = config
+}
+
+// allowRate reports whether a message from sender is within its rate limit.
+// Messages over the limit are dropped; the sender is warned at most once a
+// second and disconnected after MaxStrikes warnings. Emergency stops are
+// never limited.
+func (h *Hub) allowRate(sender *Client, msgType string) bool {
+	limit := h.rateLimits.limit(sender.Type())
+	if limit.PerSecond <= 0 || isEmergencyStop(msgType) {
+		return true
+	}
+
+	now := time.Now()
+	bucket := &sender.rate
+	if bucket.take(limit, now) {
+		return true
+	}
+	h.rateLimited.Add(1)
+
+	if now.Sub(bucket.lastWarn) < rateLimitWarnInterval {
+		return false
+	}
+	bucket.lastWarn = now
+	bucket.strikes++
+
+	logging.Sampled("ws_rate_limited", "🚦 Rate limited %s (%s) at %.0f msg/s (strike %d)",
+		sender.username, sender.Type(), limit.PerSecond, bucket.strikes)
+	sender.SendJSON(map[string]interface{}{
+		"type":           "rate_limited",
+		"message_type":   msgType,
+		"limit":          limit.PerSecond,
+		"retry_after_ms": int64((1 - bucket.tokens) / limit.PerSecond * 1000),
+		"strikes":        bucket.strikes,
+		"max_strikes":    h.rateLimits.MaxStrikes,
+	})
+
+	if h.rateLimits.MaxStrikes > 0 && bucket.strikes >= h.rateLimits.MaxStrikes {
+		log.Printf("🚦 Disconnecting %s (%s): rate limit exceeded %d times",
+			sender.username, sender.Type(), bucket.strikes)
+		go sender.closeAfterDrain(websocket.ClosePolicyViolation, "rate limit exceeded")
+	}
+	return false
+}
+
+// RateLimited returns how many messages were dropped for exceeding a rate
+// limit
+func (h *Hub) RateLimited() int64 {
+	return h.rateLimited.Load()
+}
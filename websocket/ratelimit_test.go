@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestRateBucket tests token refill and strike reset
+func TestRateBucket(t *testing.T) {
+	limit := RateLimit{PerSecond: 10, Burst: 2}
+	var bucket rateBucket
+	now := time.Now()
+
+	if !bucket.take(limit, now) || !bucket.take(limit, now) {
+		t.Fatal("Expected the burst to be allowed")
+	}
+	if bucket.take(limit, now) {
+		t.Error("Expected a message beyond the burst to be refused")
+	}
+	if !bucket.take(limit, now.Add(100*time.Millisecond)) {
+		t.Error("Expected a token after 100ms at 10/s")
+	}
+
+	bucket.strikes = 3
+	bucket.take(limit, now.Add(time.Second))
+	if bucket.strikes != 0 {
+		t.Errorf("Expected strikes to reset once the bucket refilled, got %d", bucket.strikes)
+	}
+}
+
+// TestRateLimitedClient tests that messages over the limit are dropped with
+// one warning and that emergency stops always pass
+func TestRateLimitedClient(t *testing.T) {
+	hub := NewHub()
+	hub.SetRateLimits(RateLimitConfig{
+		Default: RateLimit{PerSecond: 1, Burst: 2},
+		Types:   map[ClientType]RateLimit{ClientTypeTelemetry: {}},
+	})
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+
+	for i := 0; i < 5; i++ {
+		hub.RouteMessage(operator, []byte(`{"type":"control_command","data":{"action":"forward"}}`))
+	}
+	if delivered := len(drainMessages(robot)); delivered != 2 {
+		t.Errorf("Expected the burst of 2 commands to be delivered, got %d", delivered)
+	}
+	warnings := drainMessages(operator)
+	if len(warnings) != 1 {
+		t.Fatalf("Expected one rate_limited warning, got %d messages", len(warnings))
+	}
+	var warning map[string]interface{}
+	json.Unmarshal(warnings[0], &warning)
+	if warning["type"] != "rate_limited" || warning["message_type"] != "control_command" || warning["strikes"] != float64(1) {
+		t.Errorf("Unexpected warning: %v", warning)
+	}
+	if hub.RateLimited() != 3 || hub.GetStats()["rate_limited"] != int64(3) {
+		t.Errorf("Expected 3 dropped messages, got %d", hub.RateLimited())
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop"}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Error("Expected the emergency stop to bypass the rate limit")
+	}
+
+	// A zero override disables limiting for the type
+	telemetry := newTestClient(hub, ClientTypeTelemetry, "gps")
+	for i := 0; i < 5; i++ {
+		hub.RouteMessage(telemetry, []byte(`{"type":"pong"}`))
+	}
+	if len(drainMessages(telemetry)) != 0 || hub.RateLimited() != 3 {
+		t.Error("Expected telemetry not to be limited")
+	}
+}
+
+// TestRateLimitDisconnect tests that a client that keeps exceeding its limit
+// is disconnected
+func TestRateLimitDisconnect(t *testing.T) {
+	hub := NewHub()
+	hub.SetRateLimits(RateLimitConfig{Default: RateLimit{PerSecond: 1, Burst: 1}, MaxStrikes: 2})
+	client := newTestClient(hub, ClientTypeWeb, "alice")
+
+	hub.RouteMessage(client, []byte(`{"type":"pong"}`))
+	hub.RouteMessage(client, []byte(`{"type":"pong"}`))
+	if client.CloseReason() != "" {
+		t.Fatal("Expected one warning not to disconnect")
+	}
+	client.rate.lastWarn = time.Time{}
+	hub.RouteMessage(client, []byte(`{"type":"pong"}`))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for client.CloseReason() == "" && time.Now().Before(deadline) {
+		drainMessages(client)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if client.CloseReason() != "rate limit exceeded" {
+		t.Errorf("Expected the client to be disconnected, got %q", client.CloseReason())
+	}
+}
@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"time"
+)
+
+// Directions of a recorded message, seen from the server
+const (
+	DirectionIn  = "in"  // Received from the client
+	DirectionOut = "out" // Queued for the client
+)
+
+// RecordedMessage is one message routed through the hub, with the client
+// that sent or received it
+type RecordedMessage struct {
+	Time         time.Time
+	Direction    string
+	ConnectionID string
+	Username     string
+	ClientType   ClientType
+	Room         string
+	RobotID      string
+	Data         []byte
+}
+
+// MessageRecorder receives every routed message. RecordMessage must not
+// block; it is called on the routing path. Data must not be modified.
+type MessageRecorder interface {
+	RecordMessage(message RecordedMessage)
+}
+
+// SetMessageRecorder records routed messages in both directions (nil
+// disables). Call before Run.
+func (h *Hub) SetMessageRecorder(recorder MessageRecorder) {
+	h.recorder = recorder
+}
+
+// recordMessage passes a message sent by or queued for client to the
+// recorder. Replayed traffic is not recorded again.
+func (h *Hub) recordMessage(client *Client, direction string, data []byte) {
+	if h.recorder == nil || client.Room() == ReplayRoom {
+		return
+	}
+	h.recorder.RecordMessage(RecordedMessage{
+		Time:         time.Now(),
+		Direction:    direction,
+		ConnectionID: client.connectionID,
+		Username:     client.username,
+		ClientType:   client.Type(),
+		Room:         client.Room(),
+		RobotID:      client.RobotID(),
+		Data:         data,
+	})
+}
@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+)
+
+// capturingRecorder keeps every recorded message
+type capturingRecorder struct {
+	mu       sync.Mutex
+	messages []RecordedMessage
+}
+
+func (r *capturingRecorder) RecordMessage(message RecordedMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, message)
+}
+
+// TestRecordRoutedMessages tests that a routed command is recorded as
+// received from the operator and queued for the robot
+func TestRecordRoutedMessages(t *testing.T) {
+	hub := NewHub()
+	recorder := &capturingRecorder{}
+	hub.SetMessageRecorder(recorder)
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	operator.connectionID = "conn-alice"
+	robot.connectionID = "conn-robot"
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","data":{"speed":1}}`))
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop"}`))
+	drainMessages(robot)
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	var got []string
+	for _, message := range recorder.messages {
+		got = append(got, message.Direction+":"+message.ConnectionID)
+	}
+	want := []string{"in:conn-alice", "out:conn-robot", "in:conn-alice", "out:conn-robot"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected recorded %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected recorded %v, got %v", want, got)
+			break
+		}
+	}
+	if recorder.messages[1].ClientType != ClientTypeControl || recorder.messages[1].Username != "robot" {
+		t.Errorf("Expected the robot's metadata on the outbound record, got %+v", recorder.messages[1])
+	}
+}
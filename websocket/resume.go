@@ -0,0 +1,322 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"oculo-pilot-server/logging"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// resumeTokenPrefix marks resume tokens so they are not mistaken for JWTs
+// or tickets
+const resumeTokenPrefix = "wsr_"
+
+// resumeSession is what a client connected with, kept so a reconnect can
+// take its place
+type resumeSession struct {
+	token     string
+	client    *Client
+	handshake HandshakeResponse
+}
+
+// resumeStore tracks resumable sessions. A client whose connection drops
+// stays registered for the window, queueing what it is sent, so that a
+// reconnect presenting its token picks up where it left off.
+type resumeStore struct {
+	window time.Duration // 0 disables resuming
+
+	sessions map[string]*resumeSession  // By token
+	byClient map[*Client]*resumeSession // By the client currently holding it
+	mu       sync.Mutex
+
+	resumed atomic.Int64
+	expired atomic.Int64
+}
+
+// SetResumeWindow issues resume tokens at handshake and keeps dropped
+// clients registered for d, buffering up to the send queue size, so they
+// can reconnect without a new handshake (0 disables). Call before Run.
+func (h *Hub) SetResumeWindow(d time.Duration) {
+	h.resume.window = d
+}
+
+// issueResumeToken starts (or replaces) the resume session of a client
+// that completed its handshake and returns its token ("" when disabled or
+// not negotiated)
+func (h *Hub) issueResumeToken(client *Client, handshake HandshakeResponse) string {
+	if h.resume.window <= 0 || !client.HasFeature(FeatureResume) {
+		return ""
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("❌ Failed to generate resume token: %v", err)
+		return ""
+	}
+	handshake.ConnectionID = ""
+	handshake.ResumeToken = ""
+	session := &resumeSession{
+		token:     resumeTokenPrefix + base64.RawURLEncoding.EncodeToString(buf),
+		client:    client,
+		handshake: handshake,
+	}
+
+	h.resume.mu.Lock()
+	defer h.resume.mu.Unlock()
+	if h.resume.sessions == nil {
+		h.resume.sessions = make(map[string]*resumeSession)
+		h.resume.byClient = make(map[*Client]*resumeSession)
+	}
+	if previous := h.resume.byClient[client]; previous != nil {
+		delete(h.resume.sessions, previous.token)
+	}
+	h.resume.sessions[session.token] = session
+	h.resume.byClient[client] = session
+	return session.token
+}
+
+// forgetResume ends the resume session of a client that is gone for good
+func (h *Hub) forgetResume(client *Client) {
+	h.resume.mu.Lock()
+	defer h.resume.mu.Unlock()
+	if session := h.resume.byClient[client]; session != nil {
+		delete(h.resume.sessions, session.token)
+		delete(h.resume.byClient, client)
+	}
+}
+
+// resumableReason reports whether a connection ended in a way worth
+// waiting for a reconnect: the network dropped or the client went silent,
+// not a clean close or a server-side disconnect
+func resumableReason(reason string) bool {
+	return strings.HasPrefix(reason, "connection_lost") ||
+		strings.HasPrefix(reason, "client_closed: ") ||
+		reason == "stale"
+}
+
+// detachForResume keeps an unregistering client in the hub for the resume
+// window instead of removing it, and reports whether it did. Messages sent
+// to it wait in its queue; once detached, only a resume or the end of the
+// window removes it.
+func (h *Hub) detachForResume(client *Client) bool {
+	h.resume.mu.Lock()
+	session := h.resume.byClient[client]
+	h.resume.mu.Unlock()
+	if session == nil {
+		return false
+	}
+	if client.Detached() {
+		return true
+	}
+	if !resumableReason(client.CloseReason()) {
+		return false
+	}
+
+	client.detached.Store(time.Now().UnixNano())
+	if client.conn != nil {
+		client.conn.Close()
+	}
+	log.Printf("⏸️  %s (%s) disconnected (%s), resumable for %v",
+		client.username, client.Type(), client.CloseReason(), h.resume.window)
+	return true
+}
+
+// Detached reports whether the client's connection dropped and it is
+// waiting to be resumed
+func (c *Client) Detached() bool {
+	return c.detached.Load() != 0
+}
+
+// DetachedAt returns when the client's connection dropped (zero while
+// connected)
+func (c *Client) DetachedAt() time.Time {
+	if nanos := c.detached.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// expireResume removes detached clients whose resume window has passed
+func (h *Hub) expireResume(now time.Time) {
+	if h.resume.window <= 0 {
+		return
+	}
+
+	h.resume.mu.Lock()
+	var expired []*Client
+	for client, session := range h.resume.byClient {
+		if detachedAt := client.DetachedAt(); !detachedAt.IsZero() && now.Sub(detachedAt) >= h.resume.window {
+			expired = append(expired, client)
+			delete(h.resume.sessions, session.token)
+			delete(h.resume.byClient, client)
+		}
+	}
+	h.resume.mu.Unlock()
+
+	for _, client := range expired {
+		log.Printf("⌛ Resume window of %s (%s) passed", client.username, client.Type())
+		h.resume.expired.Add(1)
+		h.removeClient(client)
+	}
+}
+
+// findResumeSession looks up the session a handshake's resume token names
+// and fills in the handshake the session connected with. Tokens only
+// resume sessions of the same user. On failure the client is told, and
+// may complete a full handshake instead.
+func (h *Hub) findResumeSession(client *Client, handshake *HandshakeResponse) *resumeSession {
+	h.resume.mu.Lock()
+	session := h.resume.sessions[handshake.ResumeToken]
+	h.resume.mu.Unlock()
+
+	if session == nil || session.client == client ||
+		session.client.userID != client.userID || session.client.username != client.username {
+		logging.Sampled("ws_resume_failed", "❌ Unknown or expired resume token from %s", client.username)
+		client.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "resume_failed",
+			"message_type": "handshake_response",
+		})
+		return nil
+	}
+
+	connectionID := handshake.ConnectionID
+	*handshake = session.handshake
+	handshake.ConnectionID = connectionID
+	return session
+}
+
+// completeResume hands a session's place in the hub to the client that
+// presented its token: connection ID, control lock, pending takeover and
+// signaling sessions. It returns the client that held it, or nil if the
+// session ended in the meantime. A still connected previous client is
+// closed; its connection is presumably dead.
+func (h *Hub) completeResume(client *Client, session *resumeSession) *Client {
+	h.resume.mu.Lock()
+	if h.resume.sessions[session.token] != session {
+		h.resume.mu.Unlock()
+		return nil
+	}
+	delete(h.resume.sessions, session.token)
+	delete(h.resume.byClient, session.client)
+	h.resume.mu.Unlock()
+
+	previous := session.client
+	previous.setCloseReason("resumed")
+	if !previous.Detached() && previous.conn != nil {
+		previous.conn.Close()
+	}
+
+	h.mu.Lock()
+	delete(h.clients[previous.Type()], previous)
+	h.unindexConnection(previous)
+	h.unindexConnection(client)
+	client.connectionID = previous.connectionID
+	h.indexConnection(client)
+	h.mu.Unlock()
+	client.topics.Store(previous.topics.Load())
+	h.handOverSignalingSessions(previous, client)
+
+	h.control.mu.Lock()
+	for _, hold := range h.control.holds {
+		if hold.holder == previous {
+			hold.holder = client
+		}
+		if hold.takeover == previous {
+			hold.takeover = client
+		}
+	}
+	h.control.mu.Unlock()
+
+	h.resume.resumed.Add(1)
+	h.auditEvent(client, AuditResume, map[string]interface{}{
+		"client_type": client.Type(),
+		"offline_ms":  offlineFor(previous).Milliseconds(),
+	})
+	return previous
+}
+
+// offlineFor returns how long a client has been detached
+func offlineFor(client *Client) time.Duration {
+	if detachedAt := client.DetachedAt(); !detachedAt.IsZero() {
+		return time.Since(detachedAt)
+	}
+	return 0
+}
+
+// keepUnsent holds messages the write pump could not write so a resume can
+// deliver them
+func (c *Client) keepUnsent(messages ...outbound) {
+	if c.hub.resume.window <= 0 {
+		return
+	}
+	c.unsentMu.Lock()
+	defer c.unsentMu.Unlock()
+	c.unsent = append(c.unsent, messages...)
+}
+
+// moveQueued hands the messages queued for the previous client to the one
+// that resumed it, emergency stops first, and returns how many moved
+func (h *Hub) moveQueued(previous, client *Client) int {
+	previous.unsentMu.Lock()
+	unsent := previous.unsent
+	previous.unsent = nil
+	previous.unsentMu.Unlock()
+
+	moved := 0
+priority:
+	for {
+		select {
+		case message := <-previous.priority:
+			if client.enqueuePriority(message) {
+				moved++
+			}
+		default:
+			break priority
+		}
+	}
+	for _, message := range unsent {
+		if !client.enqueue(message) {
+			h.sendDropped.Add(1)
+			continue
+		}
+		moved++
+	}
+	for {
+		select {
+		case message, ok := <-previous.send:
+			if !ok {
+				return moved
+			}
+			if !client.enqueue(message) {
+				h.sendDropped.Add(1)
+				continue
+			}
+			moved++
+		default:
+			return moved
+		}
+	}
+}
+
+// resumeStats summarizes resumable sessions for GetStats
+func (h *Hub) resumeStats() map[string]interface{} {
+	h.resume.mu.Lock()
+	detached := 0
+	for client := range h.resume.byClient {
+		if client.Detached() {
+			detached++
+		}
+	}
+	h.resume.mu.Unlock()
+
+	return map[string]interface{}{
+		"window_ms": h.resume.window.Milliseconds(),
+		"detached":  detached,
+		"resumed":   h.resume.resumed.Load(),
+		"expired":   h.resume.expired.Load(),
+	}
+}
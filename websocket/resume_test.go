@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readBatched returns the messages of the next frame, which may hold several
+// separated by newlines
+func readBatched(t *testing.T, conn *websocket.Conn) []map[string]interface{} {
+	t.Helper()
+	_, frame, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Expected a frame: %v", err)
+	}
+	var messages []map[string]interface{}
+	for _, line := range strings.Split(string(frame), "\n") {
+		var message map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("Invalid message %q: %v", line, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// resumeTestConn dials the server and answers the handshake_request with
+// fields (plus the connection ID), returning the connection and the
+// messages of the reply frame: connection_established (or an error) and
+// anything batched with it
+func resumeTestConn(t *testing.T, url string, fields map[string]interface{}) (*websocket.Conn, []map[string]interface{}) {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var request map[string]interface{}
+	if err := conn.ReadJSON(&request); err != nil {
+		t.Fatalf("Expected a handshake_request: %v", err)
+	}
+	response := map[string]interface{}{"type": "handshake_response", "connection_id": request["connection_id"]}
+	for key, value := range fields {
+		response[key] = value
+	}
+	conn.WriteJSON(response)
+	return conn, readBatched(t, conn)
+}
+
+// waitFor polls until condition holds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestResumeAfterDrop tests that a dropped client reconnecting with its
+// resume token keeps its type, room and control lock and receives what
+// was sent while it was away
+func TestResumeAfterDrop(t *testing.T) {
+	hub := NewHub()
+	hub.SetResumeWindow(time.Minute)
+	go hub.Run()
+	server := httptest.NewServer(NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?token=good"
+
+	conn, reply := resumeTestConn(t, url, map[string]interface{}{"client_type": "web", "room": "lab"})
+	token, _ := reply[0]["resume_token"].(string)
+	if !strings.HasPrefix(token, resumeTokenPrefix) {
+		t.Fatalf("Expected a resume token in %v", reply[0])
+	}
+	conn.WriteJSON(map[string]interface{}{"type": "request_control"})
+	waitFor(t, "the control lock", func() bool { return hub.ControlLock("lab").Holder == "testuser" })
+	previousID := hub.ControlLock("lab").ConnectionID
+
+	// Drop the TCP connection without a close frame, like a lost link
+	conn.UnderlyingConn().Close()
+	waitFor(t, "the client to detach", func() bool {
+		connections := hub.Connections()
+		return len(connections) == 1 && connections[0].DetachedAt != nil
+	})
+	hub.BroadcastToRoom("lab", []ClientType{ClientTypeWeb}, []byte(`{"type":"location_update","data":{"lat":1}}`))
+
+	resumedConn, reply := resumeTestConn(t, url, map[string]interface{}{"resume_token": token})
+	defer resumedConn.Close()
+	established := reply[0]
+	if established["type"] != "connection_established" || established["resumed"] != true ||
+		established["room"] != "lab" || established["client_type"] != "web" {
+		t.Fatalf("Expected a resumed web connection in lab, got %v", established)
+	}
+	if established["connection_id"] != previousID {
+		t.Errorf("Expected connection ID %s to be kept, got %v", previousID, established["connection_id"])
+	}
+	if next, _ := established["resume_token"].(string); next == "" || next == token {
+		t.Errorf("Expected a new resume token, got %q", next)
+	}
+
+	missed := reply[1:]
+	if len(missed) == 0 {
+		missed = readBatched(t, resumedConn)
+	}
+	if len(missed) != 1 || missed[0]["type"] != "location_update" {
+		t.Fatalf("Expected the location update sent while away, got %v", missed)
+	}
+	if lock := hub.ControlLock("lab"); lock.Holder != "testuser" || lock.ConnectionID != previousID {
+		t.Errorf("Expected the control lock to be kept, got %+v", lock)
+	}
+	if connections := hub.Connections(); len(connections) != 1 || connections[0].DetachedAt != nil {
+		t.Errorf("Expected only the resumed connection, got %+v", connections)
+	}
+
+	// The old token is spent
+	stale, reply := resumeTestConn(t, url, map[string]interface{}{"resume_token": token})
+	defer stale.Close()
+	if reply[0]["error"] != "resume_failed" {
+		t.Errorf("Expected resume_failed for a spent token, got %v", reply)
+	}
+}
+
+// TestResumeWindowExpires tests that a detached client is removed, and its
+// control lock released, when the window passes
+func TestResumeWindowExpires(t *testing.T) {
+	hub := NewHub()
+	hub.SetResumeWindow(time.Minute)
+	client := newTestClient(hub, ClientTypeWeb, "alice")
+	hub.issueResumeToken(client, HandshakeResponse{ClientType: ClientTypeWeb})
+	hub.handleRequestControl(client)
+
+	client.setCloseReason("connection_lost: EOF")
+	if !hub.detachForResume(client) {
+		t.Fatal("Expected a lost connection to detach")
+	}
+	hub.expireResume(time.Now())
+	if hub.GetClientCount() != 1 || hub.ControlLock(DefaultRoom).Holder != "alice" {
+		t.Error("Expected the client and its lock to be kept within the window")
+	}
+
+	hub.expireResume(time.Now().Add(time.Minute))
+	if hub.GetClientCount() != 0 || hub.ControlLock(DefaultRoom).Holder != "" {
+		t.Error("Expected the client removed and its lock released after the window")
+	}
+
+	// A clean close is not resumable
+	other := newTestClient(hub, ClientTypeWeb, "bob")
+	hub.issueResumeToken(other, HandshakeResponse{ClientType: ClientTypeWeb})
+	other.setCloseReason("client_closed")
+	if hub.detachForResume(other) {
+		t.Error("Expected a clean close not to detach")
+	}
+}
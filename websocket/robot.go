@@ -0,0 +1,42 @@
+package websocket
+
+import "regexp"
+
+// robotIDRegex matches robot IDs: 1-64 characters, alphanumeric, dash and
+// underscore
+var robotIDRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// validRobotID reports whether a robot ID may be registered at handshake;
+// empty registers none
+func validRobotID(id string) bool {
+	return id == "" || robotIDRegex.MatchString(id)
+}
+
+// RobotID returns the robot the client registered for ("" for none)
+func (c *Client) RobotID() string {
+	if placement := c.placement.Load(); placement != nil {
+		return placement.robotID
+	}
+	return ""
+}
+
+// servesRobot reports whether the client receives messages addressed to
+// robotID ("" addresses everyone). Robot-side clients only receive their
+// own robot's messages; web clients that registered for no robot watch
+// every robot, as before robot IDs existed.
+func (c *Client) servesRobot(robotID string) bool {
+	if robotID == "" || c.RobotID() == robotID {
+		return true
+	}
+	return c.RobotID() == "" && c.Type() == ClientTypeWeb
+}
+
+// messageRobot returns the robot a message is addressed to or comes from.
+// Robot-side clients that registered for a robot always speak for it, so
+// they cannot address another robot's operators.
+func messageRobot(sender *Client, msg *Message) string {
+	if sender.Type() != ClientTypeWeb && sender.RobotID() != "" {
+		return sender.RobotID()
+	}
+	return msg.RobotID
+}
@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestRobotIDRouting tests that messages carrying a robot_id only reach the
+// clients registered for that robot, and that messages without one are
+// broadcast as before
+func TestRobotIDRouting(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	watcher := newTestClient(hub, ClientTypeWeb, "bob")
+	watcher.place("", "rover-2")
+	rover1 := newTestClient(hub, ClientTypeControl, "rover1")
+	rover1.place("", "rover-1")
+	rover2 := newTestClient(hub, ClientTypeControl, "rover2")
+	rover2.place("", "rover-2")
+	legacy := newTestClient(hub, ClientTypeControl, "legacy")
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"cmd-1","robot_id":"rover-1","data":{}}`))
+	if len(drainMessages(rover1)) != 1 || len(drainMessages(rover2)) != 0 || len(drainMessages(legacy)) != 0 {
+		t.Error("Expected the addressed command to reach only rover-1")
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"cmd-2","data":{}}`))
+	if len(drainMessages(rover1)) != 1 || len(drainMessages(rover2)) != 1 || len(drainMessages(legacy)) != 1 {
+		t.Error("Expected a command without robot_id to reach every robot")
+	}
+
+	// Robots speak for the robot they registered, whatever they claim
+	hub.RouteMessage(rover1, []byte(`{"type":"location_update","robot_id":"rover-2","data":{}}`))
+	if len(drainMessages(operator)) != 1 || len(drainMessages(watcher)) != 0 {
+		t.Error("Expected rover-1 telemetry to skip the rover-2 watcher")
+	}
+	hub.RouteMessage(rover2, []byte(`{"type":"location_update","data":{}}`))
+	if len(drainMessages(operator)) != 1 || len(drainMessages(watcher)) != 1 {
+		t.Error("Expected rover-2 telemetry to reach its watcher and unregistered web clients")
+	}
+
+	// Emergency stops ignore robot_id
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop","robot_id":"rover-1"}`))
+	if len(drainMessages(rover2)) != 1 || len(drainMessages(legacy)) != 1 {
+		t.Error("Expected emergency stop to reach every robot in the room")
+	}
+}
+
+// TestHandshakeRobotID tests registering a robot at handshake
+func TestHandshakeRobotID(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	robot := newTestClient(hub, ClientTypePending, "rover1")
+	robot.SetConnectionID("conn_1")
+	hub.RouteMessage(robot, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"control","robot_id":"rover-1"}`))
+	if !robot.IsHandshakeComplete() || robot.RobotID() != "rover-1" {
+		t.Fatalf("Expected robot registered as rover-1, got %q", robot.RobotID())
+	}
+	var established map[string]interface{}
+	json.Unmarshal(drainMessages(robot)[0], &established)
+	if established["robot_id"] != "rover-1" {
+		t.Errorf("Expected connection_established to echo robot_id, got %v", established)
+	}
+
+	invalid := newTestClient(hub, ClientTypePending, "rover2")
+	invalid.SetConnectionID("conn_2")
+	hub.RouteMessage(invalid, []byte(`{"type":"handshake_response","connection_id":"conn_2","client_type":"control","robot_id":"rover 2"}`))
+	if invalid.IsHandshakeComplete() {
+		t.Error("Expected an invalid robot_id to be rejected")
+	}
+	if types := messageTypes(invalid); len(types) != 1 || types[0] != "error" {
+		t.Errorf("Expected invalid_robot_id error, got %v", types)
+	}
+}
@@ -0,0 +1,255 @@
+package websocket
+
+import "regexp"
+
+// Room names
+const (
+	// DefaultRoom holds every client that did not join a room at handshake
+	DefaultRoom = ""
+
+	// AllRooms addresses clients in every room, for server-wide notices
+	// such as shutdown
+	AllRooms = "*"
+
+	// ReplayRoom receives recorded sessions played back by the server;
+	// only web and monitor clients may join it, so live robots never mix
+	// with replayed traffic
+	ReplayRoom = "replay"
+)
+
+// roomNameRegex matches room names a client may join: 1-64 characters,
+// alphanumeric, dash and underscore
+var roomNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// validRoom reports whether a client of clientType may name this room at
+// handshake; an empty name joins DefaultRoom
+func validRoom(name string, clientType ClientType) bool {
+	if name == ReplayRoom {
+		return clientType == ClientTypeWeb || clientType == ClientTypeMonitor
+	}
+	return name == "" || roomNameRegex.MatchString(name)
+}
+
+// clientPlacement is the room and robot of a client
+type clientPlacement struct {
+	room    string
+	robotID string
+}
+
+// place sets the client's room and robot
+func (c *Client) place(room, robotID string) {
+	c.placement.Store(&clientPlacement{room: room, robotID: robotID})
+}
+
+// Room returns the room the client belongs to
+func (c *Client) Room() string {
+	if placement := c.placement.Load(); placement != nil {
+		return placement.room
+	}
+	return ""
+}
+
+// roomClientCount returns how many clients of a type are in a room,
+// counting only those registered for robotID unless it is empty
+func (h *Hub) roomClientCount(room, robotID string, clientType ClientType) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := 0
+	for client := range h.clients[clientType] {
+		if client.Room() == room && (robotID == "" || client.RobotID() == robotID) {
+			count++
+		}
+	}
+	return count
+}
+
+// BroadcastToRoom sends a message to the clients of the given types (every
+// type when none are given) in a room and returns how many received it.
+// Server-originated notifications go through here so they stay within
+// room boundaries.
+func (h *Hub) BroadcastToRoom(room string, types []ClientType, message []byte) int {
+	return h.broadcastTo(room, routeTarget{}, types, message)
+}
+
+// broadcastToRobot is BroadcastToRoom limited to the clients serving
+// robotID ("" for every client, see servesRobot)
+func (h *Hub) broadcastToRobot(room, robotID string, types []ClientType, message []byte) int {
+	return h.broadcastTo(room, routeTarget{robotID: robotID}, types, message)
+}
+
+// broadcastTo is BroadcastToRoom limited to the clients matching target.
+// The message is shared with other instances (see backplane.go); the count
+// is of local clients.
+func (h *Hub) broadcastTo(room string, target routeTarget, types []ClientType, message []byte) int {
+	return h.broadcastOutbound(room, target, types, outbound{data: message})
+}
+
+// broadcastOutbound is broadcastTo for a message with a deadline (see
+// ttl.go), which other instances keep
+func (h *Hub) broadcastOutbound(room string, target routeTarget, types []ClientType, message outbound) int {
+	delivered := h.deliverOutbound(room, target, types, message)
+	if target.connectionID == "" {
+		h.publishRelay(relayEnvelope{
+			Kind:     relayBroadcast,
+			Room:     room,
+			RobotID:  target.robotID,
+			Types:    types,
+			Deadline: unixMilli(message.deadline),
+			Data:     message.data,
+		})
+	}
+	return delivered
+}
+
+// deliverTo sends a message to the local clients matching room, target and
+// types and returns how many received it
+func (h *Hub) deliverTo(room string, target routeTarget, types []ClientType, message []byte) int {
+	return h.deliverOutbound(room, target, types, outbound{data: message})
+}
+
+// deliverOutbound is deliverTo for a message with a deadline
+func (h *Hub) deliverOutbound(room string, target routeTarget, types []ClientType, message outbound) int {
+	h.mu.RLock()
+	var recipients []*Client
+	for clientType, clients := range h.clients {
+		if len(types) > 0 && !containsType(types, clientType) {
+			continue
+		}
+		for client := range clients {
+			if (room == AllRooms || client.Room() == room) && target.matches(client) {
+				recipients = append(recipients, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	delivered := 0
+	for _, client := range recipients {
+		if !h.deliver(client, message) {
+			continue
+		}
+		delivered++
+	}
+	h.throughput.countFanout(delivered)
+	return delivered
+}
+
+// containsType reports whether clientType is listed
+func containsType(types []ClientType, clientType ClientType) bool {
+	for _, t := range types {
+		if t == clientType {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultRoomID names DefaultRoom in stats and REST paths
+const defaultRoomID = "default"
+
+// RoomID returns the identifier of a room used in stats and REST paths
+func RoomID(room string) string {
+	if room == DefaultRoom {
+		return defaultRoomID
+	}
+	return room
+}
+
+// roomFromID reverses RoomID
+func roomFromID(id string) string {
+	if id == defaultRoomID {
+		return DefaultRoom
+	}
+	return id
+}
+
+// RoomTraffic counts messages received from a room's clients
+type RoomTraffic struct {
+	Messages int64 `json:"messages"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// RoomStatus is the state of one room
+type RoomStatus struct {
+	Room          string             `json:"room"`
+	Total         int                `json:"total"`
+	Clients       map[ClientType]int `json:"clients"`
+	Traffic       RoomTraffic        `json:"traffic"`
+	EmergencyStop EmergencyStopState `json:"emergency_stop"`
+	ControlLock   *ControlLockState  `json:"control_lock,omitempty"` // Set while the room's lock is held
+}
+
+// countRoomTraffic records a message received from a client in room
+func (h *Hub) countRoomTraffic(room string, size int) {
+	h.roomMu.Lock()
+	defer h.roomMu.Unlock()
+
+	if h.roomTraffic == nil {
+		h.roomTraffic = make(map[string]*RoomTraffic)
+	}
+	traffic, ok := h.roomTraffic[room]
+	if !ok {
+		traffic = &RoomTraffic{}
+		h.roomTraffic[room] = traffic
+	}
+	traffic.Messages++
+	traffic.Bytes += int64(size)
+}
+
+// RoomStatuses returns the state of every room with connected clients or
+// recorded activity, keyed by RoomID
+func (h *Hub) RoomStatuses() map[string]RoomStatus {
+	statuses := make(map[string]RoomStatus)
+	status := func(room string) RoomStatus {
+		s, ok := statuses[RoomID(room)]
+		if !ok {
+			s = RoomStatus{Room: RoomID(room), Clients: make(map[ClientType]int)}
+		}
+		return s
+	}
+
+	h.mu.RLock()
+	for clientType, clients := range h.clients {
+		for client := range clients {
+			s := status(client.Room())
+			s.Clients[clientType]++
+			s.Total++
+			statuses[s.Room] = s
+		}
+	}
+	h.mu.RUnlock()
+
+	h.roomMu.Lock()
+	for room, traffic := range h.roomTraffic {
+		s := status(room)
+		s.Traffic = *traffic
+		statuses[s.Room] = s
+	}
+	h.roomMu.Unlock()
+
+	h.estopMu.RLock()
+	for room, estop := range h.estopRooms {
+		s := status(room)
+		s.EmergencyStop = estop
+		statuses[s.Room] = s
+	}
+	h.estopMu.RUnlock()
+
+	h.control.mu.Lock()
+	for room := range h.control.holds {
+		s := status(room)
+		lock := h.controlStateLocked(room)
+		s.ControlLock = &lock
+		statuses[s.Room] = s
+	}
+	h.control.mu.Unlock()
+
+	return statuses
+}
+
+// RoomStatus returns the state of one room by RoomID
+func (h *Hub) RoomStatus(id string) (RoomStatus, bool) {
+	status, ok := h.RoomStatuses()[RoomID(roomFromID(id))]
+	return status, ok
+}
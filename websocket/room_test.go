@@ -0,0 +1,222 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestBroadcastToRoom tests filtering recipients by room and client type
+func TestBroadcastToRoom(t *testing.T) {
+	hub := NewHub()
+	web := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+	other := newTestClient(hub, ClientTypeWeb, "bob")
+	other.place("lab", "")
+
+	if got := hub.BroadcastToRoom(DefaultRoom, []ClientType{ClientTypeWeb}, []byte(`{}`)); got != 1 {
+		t.Errorf("Expected 1 recipient, got %d", got)
+	}
+	if len(drainMessages(web)) != 1 || len(drainMessages(robot)) != 0 || len(drainMessages(other)) != 0 {
+		t.Error("Expected only web clients in the default room to receive the message")
+	}
+
+	if got := hub.BroadcastToRoom("lab", nil, []byte(`{}`)); got != 1 || len(drainMessages(other)) != 1 {
+		t.Errorf("Expected only the lab client to receive the message, got %d", got)
+	}
+
+	if got := hub.BroadcastToRoom(AllRooms, nil, []byte(`{}`)); got != 3 {
+		t.Errorf("Expected every client to receive the message, got %d", got)
+	}
+}
+
+// TestBroadcastToRemovedClient tests that a broadcast racing with a
+// client's removal drops the message instead of sending on its closed
+// send channel
+func TestBroadcastToRemovedClient(t *testing.T) {
+	hub := NewHub()
+	web := newTestClient(hub, ClientTypeWeb, "alice")
+
+	hub.removeClient(web)
+	if hub.deliver(web, outbound{data: []byte(`{}`)}) {
+		t.Error("Expected delivery to a removed client to fail")
+	}
+	if web.CloseReason() != "" || hub.SendDropped() != 0 {
+		t.Error("Expected a removed client to be left alone")
+	}
+}
+
+// TestEmergencyStopStaysInRoom tests that an emergency stop only reaches
+// control clients in the sender's room
+func TestEmergencyStopStaysInRoom(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+	elsewhere := newTestClient(hub, ClientTypeControl, "robot-2")
+	elsewhere.place("lab", "")
+
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop"}`))
+	if len(drainMessages(robot)) != 1 || len(drainMessages(elsewhere)) != 0 {
+		t.Error("Expected emergency stop to reach only robots in the sender's room")
+	}
+}
+
+// TestRoomStatuses tests the per-room breakdown of clients, traffic,
+// emergency stop and control lock
+func TestRoomStatuses(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	newTestClient(hub, ClientTypeControl, "robot-1")
+	lab := newTestClient(hub, ClientTypeWeb, "bob")
+	lab.place("lab", "")
+
+	hub.RouteMessage(operator, []byte(`{"type":"request_control"}`))
+	hub.RouteMessage(lab, []byte(`{"type":"emergency_stop"}`))
+
+	statuses := hub.RoomStatuses()
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 rooms, got %d", len(statuses))
+	}
+
+	def, ok := hub.RoomStatus("default")
+	if !ok {
+		t.Fatal("Expected the default room to be found by its ID")
+	}
+	if def.Total != 2 || def.Clients[ClientTypeControl] != 1 || def.Traffic.Messages != 1 {
+		t.Errorf("Unexpected default room status: %+v", def)
+	}
+	if def.ControlLock == nil || def.ControlLock.Holder != "alice" || def.EmergencyStop.Active {
+		t.Errorf("Expected alice's lock and no emergency stop in the default room, got %+v", def)
+	}
+
+	status, _ := hub.RoomStatus("lab")
+	if status.Total != 1 || !status.EmergencyStop.Active || status.ControlLock != nil {
+		t.Errorf("Expected an active emergency stop and no lock in lab, got %+v", status)
+	}
+
+	if _, ok := hub.RoomStatus("garage"); ok {
+		t.Error("Expected unknown room not to be found")
+	}
+	if _, ok := hub.GetStats()["rooms"]; !ok {
+		t.Error("Expected GetStats to include the per-room breakdown")
+	}
+}
+
+// TestHandshakeJoinsRoom tests that clients join the room named in the
+// handshake and that invalid names are rejected
+func TestHandshakeJoinsRoom(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	robot := newTestClient(hub, ClientTypePending, "robot-1")
+	robot.SetConnectionID("conn_1")
+	hub.RouteMessage(robot, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"control","room":"lab"}`))
+	if !robot.IsHandshakeComplete() || robot.Room() != "lab" {
+		t.Fatalf("Expected robot in room lab, got %q", robot.Room())
+	}
+	var established map[string]interface{}
+	json.Unmarshal(drainMessages(robot)[0], &established)
+	if established["room"] != "lab" {
+		t.Errorf("Expected connection_established to name the room, got %v", established)
+	}
+
+	web := newTestClient(hub, ClientTypePending, "alice")
+	web.SetConnectionID("conn_2")
+	hub.RouteMessage(web, []byte(`{"type":"handshake_response","connection_id":"conn_2","client_type":"web","room":"default"}`))
+	if web.Room() != DefaultRoom {
+		t.Errorf("Expected room default to join DefaultRoom, got %q", web.Room())
+	}
+
+	for _, room := range []string{"*", "lab/1", strings.Repeat("x", 65)} {
+		client := newTestClient(hub, ClientTypePending, "mallory")
+		client.SetConnectionID("conn_3")
+		hub.RouteMessage(client, []byte(`{"type":"handshake_response","connection_id":"conn_3","client_type":"web","room":"`+room+`"}`))
+		if client.IsHandshakeComplete() {
+			t.Errorf("Expected room %q to be rejected", room)
+		}
+	}
+}
+
+// TestReplayRoomIsWebOnly tests that robots cannot join the replay room
+func TestReplayRoomIsWebOnly(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	robot := newTestClient(hub, ClientTypePending, "robot-1")
+	robot.SetConnectionID("conn_1")
+	hub.RouteMessage(robot, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"control","room":"replay"}`))
+	if robot.IsHandshakeComplete() {
+		t.Error("Expected a control client to be kept out of the replay room")
+	}
+
+	web := newTestClient(hub, ClientTypePending, "alice")
+	web.SetConnectionID("conn_2")
+	hub.RouteMessage(web, []byte(`{"type":"handshake_response","connection_id":"conn_2","client_type":"web","room":"replay"}`))
+	if web.Room() != ReplayRoom {
+		t.Errorf("Expected a web client in the replay room, got %q", web.Room())
+	}
+}
+
+// TestRoutingStaysInRoom tests that commands, responses, telemetry and
+// signaling only reach clients in the sender's room
+func TestRoutingStaysInRoom(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+	video := newTestClient(hub, ClientTypeVideo, "camera-1")
+	labOperator := newTestClient(hub, ClientTypeWeb, "bob")
+	labOperator.place("lab", "")
+	labRobot := newTestClient(hub, ClientTypeControl, "robot-2")
+	labRobot.place("lab", "")
+	labVideo := newTestClient(hub, ClientTypeVideo, "camera-2")
+	labVideo.place("lab", "")
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"cmd-1","data":{}}`))
+	if len(drainMessages(robot)) != 1 || len(drainMessages(labRobot)) != 0 {
+		t.Error("Expected control_command to reach only the robot in the operator's room")
+	}
+
+	hub.RouteMessage(labRobot, []byte(`{"type":"location_update","data":{}}`))
+	if len(drainMessages(labOperator)) != 1 || len(drainMessages(operator)) != 0 {
+		t.Error("Expected telemetry to reach only web clients in the robot's room")
+	}
+
+	hub.RouteMessage(labRobot, []byte(`{"type":"control_response","correlation_id":"cmd-9"}`))
+	if len(drainMessages(labOperator)) != 1 || len(drainMessages(operator)) != 0 {
+		t.Error("Expected control_response to reach only web clients in the robot's room")
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"offer","sdp":"v=0"}`))
+	if len(drainMessages(video)) != 1 || len(drainMessages(labVideo)) != 0 {
+		t.Error("Expected the offer to reach only the video client in the operator's room")
+	}
+
+	hub.RouteMessage(labOperator, []byte(`{"type":"custom_event"}`))
+	if len(drainMessages(labRobot)) != 1 || len(drainMessages(labVideo)) != 1 || len(drainMessages(robot)) != 0 {
+		t.Error("Expected unknown types to be broadcast within the sender's room only")
+	}
+}
+
+// TestControlLockPerRoom tests that each room has its own control lock
+func TestControlLockPerRoom(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+	bob.place("lab", "")
+	robot := newTestClient(hub, ClientTypeControl, "robot-2")
+	robot.place("lab", "")
+
+	hub.RouteMessage(alice, []byte(`{"type":"request_control"}`))
+	hub.RouteMessage(bob, []byte(`{"type":"request_control"}`))
+	if hub.ControlLock(DefaultRoom).Holder != "alice" || hub.ControlLock("lab").Holder != "bob" {
+		t.Fatalf("Expected one holder per room, got %+v and %+v", hub.ControlLock(DefaultRoom), hub.ControlLock("lab"))
+	}
+	if types := messageTypes(bob); len(types) != 1 || types[0] != "control_lock" {
+		t.Errorf("Expected bob to see only the lab lock, got %v", types)
+	}
+
+	hub.RouteMessage(bob, []byte(`{"type":"control_command","id":"cmd-1","data":{}}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Error("Expected bob's command to reach the lab robot despite alice's lock")
+	}
+}
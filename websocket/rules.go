@@ -0,0 +1,154 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"oculo-pilot-server/logging"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CommandRange bounds a numeric control_command field, inclusive
+type CommandRange struct {
+	Min float64
+	Max float64
+}
+
+// ParseCommandRange parses a range written "min:max", e.g. "-1:1"
+func ParseCommandRange(s string) (CommandRange, error) {
+	lo, hi, ok := strings.Cut(s, ":")
+	if !ok {
+		return CommandRange{}, fmt.Errorf("range %q is not min:max", s)
+	}
+	min, err := strconv.ParseFloat(strings.TrimSpace(lo), 64)
+	if err != nil {
+		return CommandRange{}, fmt.Errorf("invalid minimum in %q", s)
+	}
+	max, err := strconv.ParseFloat(strings.TrimSpace(hi), 64)
+	if err != nil {
+		return CommandRange{}, fmt.Errorf("invalid maximum in %q", s)
+	}
+	if min > max {
+		return CommandRange{}, fmt.Errorf("minimum exceeds maximum in %q", s)
+	}
+	return CommandRange{Min: min, Max: max}, nil
+}
+
+// CommandRules are a deployment's limits on what control_command messages
+// may ask robots to do, checked after the message schema. Fields are
+// dotted paths into the message, e.g. "data.speed".
+type CommandRules struct {
+	// Actions are the allowed values of data.action; when set, every
+	// command needs one (empty allows any)
+	Actions []string
+
+	// Limits bound numeric fields; absent fields are not checked
+	Limits map[string]CommandRange
+
+	// Required fields every command must carry
+	Required []string
+}
+
+// empty reports whether the rules check nothing
+func (r CommandRules) empty() bool {
+	return len(r.Actions) == 0 && len(r.Limits) == 0 && len(r.Required) == 0
+}
+
+// Check validates a control_command against the rules
+func (r CommandRules) Check(rawMessage []byte) error {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(rawMessage, &payload); err != nil {
+		return &SchemaError{Field: "message", Reason: "must be a JSON object"}
+	}
+
+	for _, field := range r.Required {
+		if value, ok := lookupValue(payload, strings.Split(field, ".")); !ok || value == nil {
+			return &SchemaError{Field: field, Reason: "is required"}
+		}
+	}
+
+	if len(r.Actions) > 0 {
+		action, _ := lookupValue(payload, []string{"data", "action"})
+		name, ok := action.(string)
+		if !ok {
+			return &SchemaError{Field: "data.action", Reason: "is required"}
+		}
+		if !containsString(r.Actions, name) {
+			return &SchemaError{Field: "data.action", Reason: "must be one of " + strings.Join(r.Actions, ", ")}
+		}
+	}
+
+	// Sorted for a stable first error
+	fields := make([]string, 0, len(r.Limits))
+	for field := range r.Limits {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		value, ok := lookupValue(payload, strings.Split(field, "."))
+		if !ok || value == nil {
+			continue
+		}
+		number, ok := value.(float64)
+		if !ok {
+			return &SchemaError{Field: field, Reason: "must be of type number"}
+		}
+		if limit := r.Limits[field]; number < limit.Min || number > limit.Max {
+			return &SchemaError{Field: field, Reason: fmt.Sprintf("must be between %g and %g", limit.Min, limit.Max)}
+		}
+	}
+	return nil
+}
+
+// SetCommandRules limits the control commands web clients may send. Blank
+// actions and fields are ignored. Call before Run.
+func (h *Hub) SetCommandRules(rules CommandRules) {
+	rules.Actions = trimmedList(rules.Actions)
+	rules.Required = trimmedList(rules.Required)
+	h.commandRules = rules
+}
+
+// trimmedList returns the non-blank entries of a list, trimmed
+func trimmedList(list []string) []string {
+	var trimmed []string
+	for _, item := range list {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
+}
+
+// checkCommandRules rejects a control_command outside the deployment's
+// rules, telling the sender and admin dashboards
+func (h *Hub) checkCommandRules(sender *Client, msgType string, rawMessage []byte) bool {
+	if h.commandRules.empty() {
+		return true
+	}
+	err := h.commandRules.Check(rawMessage)
+	if err == nil {
+		return true
+	}
+
+	h.commandsRejected.Add(1)
+	logging.Sampled("ws_command_rejected", "⛔ Rejected %s from %s: %v", msgType, sender.username, err)
+	response := map[string]interface{}{
+		"type":         "error",
+		"error":        "command_rejected",
+		"message_type": msgType,
+	}
+	event := map[string]interface{}{
+		"username": sender.username,
+		"room":     RoomID(sender.Room()),
+	}
+	if schemaErr, ok := err.(*SchemaError); ok {
+		response["field"] = schemaErr.Field
+		response["reason"] = schemaErr.Reason
+		event["field"] = schemaErr.Field
+		event["reason"] = schemaErr.Reason
+	}
+	sender.SendJSON(response)
+	h.PublishSecurityEvent(SecurityCommandRejected, event)
+	return false
+}
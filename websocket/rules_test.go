@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestParseCommandRange tests parsing of min:max ranges
+func TestParseCommandRange(t *testing.T) {
+	if r, err := ParseCommandRange("-1.5: 2"); err != nil || r.Min != -1.5 || r.Max != 2 {
+		t.Errorf("Unexpected range %+v (%v)", r, err)
+	}
+	for _, s := range []string{"1", "a:2", "1:b", "3:1"} {
+		if _, err := ParseCommandRange(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
+
+// TestCommandRules tests that control commands outside the deployment's
+// rules are rejected instead of reaching the robot
+func TestCommandRules(t *testing.T) {
+	hub := NewHub()
+	hub.SetCommandRules(CommandRules{
+		Actions:  []string{"drive", " stop"},
+		Limits:   map[string]CommandRange{"data.speed": {Min: -1, Max: 1}, "data.steering": {Min: -30, Max: 30}},
+		Required: []string{"data"},
+	})
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot-1")
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","data":{"action":"drive","speed":0.5,"steering":-30}}`))
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","data":{"action":"stop"}}`))
+	if got := len(drainMessages(robot)); got != 2 {
+		t.Fatalf("Expected valid commands to reach the robot, got %d", got)
+	}
+
+	tests := []struct {
+		message, field string
+	}{
+		{`{"type":"control_command"}`, "data"},
+		{`{"type":"control_command","data":{"speed":0.5}}`, "data.action"},
+		{`{"type":"control_command","data":{"action":"self_destruct"}}`, "data.action"},
+		{`{"type":"control_command","data":{"action":"drive","speed":5}}`, "data.speed"},
+		{`{"type":"control_command","data":{"action":"drive","steering":"left"}}`, "data.steering"},
+	}
+	for _, tt := range tests {
+		hub.RouteMessage(operator, []byte(tt.message))
+		messages := drainMessages(operator)
+		if len(messages) != 1 {
+			t.Fatalf("Expected an error for %s, got %d messages", tt.message, len(messages))
+		}
+		var reply map[string]interface{}
+		json.Unmarshal(messages[0], &reply)
+		if reply["error"] != "command_rejected" || reply["field"] != tt.field {
+			t.Errorf("Expected command_rejected on %s for %s, got %v", tt.field, tt.message, reply)
+		}
+	}
+	if got := len(drainMessages(robot)); got != 0 {
+		t.Errorf("Expected rejected commands not to reach the robot, got %d", got)
+	}
+	if got := hub.GetStats()["commands_rejected"]; got != int64(len(tests)) {
+		t.Errorf("Expected %d rejected commands, got %v", len(tests), got)
+	}
+}
@@ -0,0 +1,212 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"oculo-pilot-server/logging"
+	"sort"
+	"strings"
+)
+
+// FieldType is a set of JSON types a message field may have
+type FieldType uint8
+
+// JSON types for schema fields; combine with | to allow several
+const (
+	FieldString FieldType = 1 << iota
+	FieldNumber
+	FieldBool
+	FieldObject
+	FieldArray
+)
+
+// String names the allowed types, e.g. "string or object"
+func (t FieldType) String() string {
+	var names []string
+	for _, kind := range []struct {
+		t    FieldType
+		name string
+	}{
+		{FieldString, "string"},
+		{FieldNumber, "number"},
+		{FieldBool, "boolean"},
+		{FieldObject, "object"},
+		{FieldArray, "array"},
+	} {
+		if t&kind.t != 0 {
+			names = append(names, kind.name)
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// accepts reports whether a raw JSON value has one of the allowed types.
+// null counts as absent and is checked by the caller.
+func (t FieldType) accepts(value json.RawMessage) bool {
+	if len(value) == 0 {
+		return false
+	}
+	var kind FieldType
+	switch value[0] {
+	case '"':
+		kind = FieldString
+	case '{':
+		kind = FieldObject
+	case '[':
+		kind = FieldArray
+	case 't', 'f':
+		kind = FieldBool
+	default:
+		kind = FieldNumber
+	}
+	return t&kind != 0
+}
+
+// Schema lists the fields a message type must or may carry and their JSON
+// types. Fields not listed are not checked, so clients can add their own.
+type Schema struct {
+	Required map[string]FieldType
+	Optional map[string]FieldType
+}
+
+// SchemaError describes why a message failed its schema
+type SchemaError struct {
+	Field  string
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
+
+// Validate checks a raw message against the schema
+func (s Schema) Validate(rawMessage []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rawMessage, &fields); err != nil {
+		return &SchemaError{Field: "message", Reason: "must be a JSON object"}
+	}
+
+	check := func(name string, allowed FieldType, required bool) error {
+		value, ok := fields[name]
+		if !ok || string(value) == "null" {
+			if required {
+				return &SchemaError{Field: name, Reason: "is required"}
+			}
+			return nil
+		}
+		if !allowed.accepts(value) {
+			return &SchemaError{Field: name, Reason: "must be of type " + allowed.String()}
+		}
+		return nil
+	}
+
+	// Sorted for a stable first error
+	for _, name := range sortedFields(s.Required) {
+		if err := check(name, s.Required[name], true); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedFields(s.Optional) {
+		if err := check(name, s.Optional[name], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedFields returns the field names in order
+func sortedFields(fields map[string]FieldType) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// telemetrySchema is shared by the telemetry message types
+var telemetrySchema = Schema{
+	Required: map[string]FieldType{"data": FieldObject},
+	Optional: map[string]FieldType{"timestamp": FieldNumber, "ttl_ms": FieldNumber},
+}
+
+// signalingSchema is shared by offer and answer
+var signalingSchema = Schema{
+	Required: map[string]FieldType{"sdp": FieldString},
+	Optional: map[string]FieldType{"media": FieldString, "session_id": FieldString},
+}
+
+// defaultSchemas validates the message types the hub routes to robots and
+// operators. Emergency stops are never validated: a stop with a malformed
+// field must still stop the robot.
+var defaultSchemas = map[string]Schema{
+	"control_command": {
+		// IDs may be strings or numbers (see correlationID)
+		Optional: map[string]FieldType{"id": FieldString | FieldNumber, "data": FieldObject, "max_age_ms": FieldNumber, "qos": FieldNumber, "retry": FieldBool},
+	},
+	"command_ack": {
+		Required: map[string]FieldType{"id": FieldString | FieldNumber},
+	},
+	"command_nack": {
+		Required: map[string]FieldType{"correlation_id": FieldString | FieldNumber},
+		Optional: map[string]FieldType{"reason": FieldString},
+	},
+	"control_response": {
+		Optional: map[string]FieldType{"correlation_id": FieldString | FieldNumber, "status": FieldString},
+	},
+	"location_update": telemetrySchema,
+	"route_update":    telemetrySchema,
+	"offer":           signalingSchema,
+	"answer":          signalingSchema,
+	"request_takeover": {
+		Optional: map[string]FieldType{"force": FieldBool},
+	},
+	"disconnect_client": {
+		Required: map[string]FieldType{"connection_id": FieldString},
+		Optional: map[string]FieldType{"reason": FieldString},
+	},
+	"subscribe":   {Required: map[string]FieldType{"topics": FieldArray}},
+	"unsubscribe": {Required: map[string]FieldType{"topics": FieldArray}},
+	"publish":     {Required: map[string]FieldType{"topic": FieldString}},
+	"ice-candidate": {
+		Required: map[string]FieldType{"candidate": FieldString | FieldObject},
+		Optional: map[string]FieldType{"media": FieldString, "session_id": FieldString},
+	},
+}
+
+// RegisterSchema validates messages of a type against schema before they
+// are routed, replacing any built-in schema for the type. Call before Run.
+func (h *Hub) RegisterSchema(msgType string, schema Schema) {
+	if h.schemas == nil {
+		h.schemas = make(map[string]Schema)
+	}
+	h.schemas[msgType] = schema
+}
+
+// validateMessage checks a message against its type's schema and tells the
+// sender what is wrong when it fails
+func (h *Hub) validateMessage(sender *Client, msgType string, rawMessage []byte) bool {
+	schema, ok := h.schemas[msgType]
+	if !ok || isEmergencyStop(msgType) {
+		return true
+	}
+	err := schema.Validate(rawMessage)
+	if err == nil {
+		return true
+	}
+
+	h.throughput.invalid.Add(1)
+	logging.Sampled("ws_invalid_schema", "❌ Rejected %s from %s (%s): %v",
+		msgType, sender.username, sender.Type(), err)
+	response := map[string]interface{}{
+		"type":         "error",
+		"error":        "invalid_message",
+		"message_type": msgType,
+	}
+	if schemaErr, ok := err.(*SchemaError); ok {
+		response["field"] = schemaErr.Field
+		response["reason"] = schemaErr.Reason
+	}
+	sender.SendJSON(response)
+	return false
+}
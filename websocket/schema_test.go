@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestSchemaValidate tests required fields, types and unlisted fields
+func TestSchemaValidate(t *testing.T) {
+	schema := Schema{
+		Required: map[string]FieldType{"data": FieldObject},
+		Optional: map[string]FieldType{"id": FieldString | FieldNumber},
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		field   string // "" when valid
+	}{
+		{"valid", `{"type":"x","data":{},"id":"a"}`, ""},
+		{"numeric id", `{"type":"x","data":{},"id":7}`, ""},
+		{"unlisted fields", `{"type":"x","data":{},"extra":[1]}`, ""},
+		{"missing required", `{"type":"x"}`, "data"},
+		{"null required", `{"type":"x","data":null}`, "data"},
+		{"wrong type", `{"type":"x","data":"forward"}`, "data"},
+		{"wrong optional type", `{"type":"x","data":{},"id":true}`, "id"},
+		{"not an object", `[1,2]`, "message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate([]byte(tt.message))
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Expected valid, got %v", err)
+				}
+				return
+			}
+			schemaErr, ok := err.(*SchemaError)
+			if !ok || schemaErr.Field != tt.field {
+				t.Errorf("Expected error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+// TestInvalidMessageRejected tests that malformed payloads are answered
+// with a structured error and not routed
+func TestInvalidMessageRejected(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	video := newTestClient(hub, ClientTypeVideo, "camera")
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"cmd-1","data":"forward"}`))
+	if len(drainMessages(robot)) != 0 {
+		t.Error("Expected the malformed command not to reach the robot")
+	}
+	messages := drainMessages(operator)
+	if len(messages) != 1 {
+		t.Fatalf("Expected one error, got %d messages", len(messages))
+	}
+	var reply map[string]interface{}
+	json.Unmarshal(messages[0], &reply)
+	if reply["error"] != "invalid_message" || reply["message_type"] != "control_command" ||
+		reply["field"] != "data" || reply["reason"] != "must be of type object" {
+		t.Errorf("Unexpected error reply: %v", reply)
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"offer"}`))
+	if len(drainMessages(video)) != 0 {
+		t.Error("Expected an offer without sdp not to reach the video client")
+	}
+
+	// Emergency stops are never rejected
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop","reason":42}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Error("Expected a malformed emergency stop to be delivered")
+	}
+}
+
+// TestRegisterSchema tests adding a schema for a custom message type
+func TestRegisterSchema(t *testing.T) {
+	hub := NewHub()
+	hub.RegisterSchema("arm_pose", Schema{Required: map[string]FieldType{"joints": FieldArray}})
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+
+	hub.RouteMessage(operator, []byte(`{"type":"arm_pose","joints":{}}`))
+	if len(drainMessages(robot)) != 0 {
+		t.Error("Expected the invalid arm_pose to be rejected")
+	}
+	hub.RouteMessage(operator, []byte(`{"type":"arm_pose","joints":[0.1,0.2]}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Error("Expected the valid arm_pose to be routed")
+	}
+}
@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"oculo-pilot-server/logging"
+)
+
+// Token scopes understood by the hub (must match the auth package)
+const (
+	ScopeView    = "ws:view"
+	ScopeControl = "ws:control"
+)
+
+// ScopedAuthValidator is implemented by validators that also return the
+// token's scopes. A nil scope list means the token predates scopes and is
+// unrestricted.
+type ScopedAuthValidator interface {
+	ValidateTokenScopes(token string) (userID int64, username string, scopes []string, err error)
+}
+
+// SetScopes restricts what the client may do; nil leaves it unrestricted
+func (c *Client) SetScopes(scopes []string) {
+	c.scopes = scopes
+}
+
+// HasScope reports whether the client's token grants a scope
+func (c *Client) HasScope(scope string) bool {
+	return c.scopes == nil || hasScope(c.scopes, scope)
+}
+
+// hasScope reports whether scopes contains scope
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize checks that sender holds scope for a message type, replying
+// with an insufficient_scope error when it does not
+func (h *Hub) authorize(sender *Client, msgType, scope string) bool {
+	if sender.HasScope(scope) {
+		return true
+	}
+
+	logging.Sampled("ws_insufficient_scope", "🚫 %s from %s (%s) rejected: missing scope %s",
+		msgType, sender.username, sender.Type(), scope)
+	sender.SendJSON(map[string]interface{}{
+		"type":           "error",
+		"error":          "insufficient_scope",
+		"message_type":   msgType,
+		"required_scope": scope,
+	})
+	return false
+}
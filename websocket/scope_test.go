@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestViewOnlyClientCannotCommand tests scope enforcement in RouteMessage
+func TestViewOnlyClientCannotCommand(t *testing.T) {
+	hub := NewHub()
+	viewer := newTestClient(hub, ClientTypeWeb, "viewer")
+	viewer.SetScopes([]string{ScopeView})
+	operator := newTestClient(hub, ClientTypeWeb, "operator")
+	operator.SetScopes([]string{ScopeView, ScopeControl})
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+
+	for _, msgType := range []string{"control_command", "emergency_stop", "emergency_stop_reset", "custom_action"} {
+		hub.RouteMessage(viewer, []byte(`{"type":"`+msgType+`"}`))
+	}
+	if got := drainMessages(robot); len(got) != 0 {
+		t.Errorf("Expected no messages from view-only client, robot got %d", len(got))
+	}
+
+	replies := drainMessages(viewer)
+	if len(replies) != 4 {
+		t.Fatalf("Expected 4 insufficient_scope errors, got %d", len(replies))
+	}
+	var reply map[string]interface{}
+	if err := json.Unmarshal(replies[0], &reply); err != nil {
+		t.Fatalf("Invalid reply: %v", err)
+	}
+	if reply["error"] != "insufficient_scope" || reply["required_scope"] != ScopeControl {
+		t.Errorf("Unexpected reply: %v", reply)
+	}
+
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","data":{"cmd":"forward"}}`))
+	if got := drainMessages(robot); len(got) != 1 {
+		t.Errorf("Expected control command from operator, robot got %d", len(got))
+	}
+}
+
+// TestUnscopedClientUnrestricted tests that tokens without scopes keep full access
+func TestUnscopedClientUnrestricted(t *testing.T) {
+	hub := NewHub()
+	web := newTestClient(hub, ClientTypeWeb, "legacy")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+
+	hub.RouteMessage(web, []byte(`{"type":"emergency_stop"}`))
+	if got := drainMessages(robot); len(got) != 1 {
+		t.Errorf("Expected emergency stop from unscoped client, robot got %d", len(got))
+	}
+}
+
+// TestViewOnlyHandshakeRestricted tests that view-only tokens can only join as web clients
+func TestViewOnlyHandshakeRestricted(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	robot := newTestClient(hub, ClientTypePending, "viewer")
+	robot.SetScopes([]string{ScopeView})
+	robot.SetConnectionID("conn_1")
+	hub.RouteMessage(robot, []byte(`{"type":"handshake_response","connection_id":"conn_1","client_type":"control"}`))
+	if robot.IsHandshakeComplete() {
+		t.Error("Expected view-only handshake as control client to be rejected")
+	}
+
+	web := newTestClient(hub, ClientTypePending, "viewer")
+	web.SetScopes([]string{ScopeView})
+	web.SetConnectionID("conn_2")
+	hub.RouteMessage(web, []byte(`{"type":"handshake_response","connection_id":"conn_2","client_type":"web"}`))
+	if !web.IsHandshakeComplete() {
+		t.Error("Expected view-only handshake as web client to succeed")
+	}
+}
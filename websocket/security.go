@@ -0,0 +1,114 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Security event kinds delivered as security_event messages
+const (
+	SecurityLoginFailed        = "login_failed"
+	SecurityTokenRejected      = "token_rejected"
+	SecurityIPBanned           = "ip_banned"
+	SecurityIPBlocked          = "ip_blocked"
+	SecurityBindingMismatch    = "token_binding_mismatch"
+	SecurityOriginRejected     = "origin_rejected"
+	SecurityEmergencyStop      = "emergency_stop"
+	SecurityEmergencyStopReset = "emergency_stop_reset"
+	SecurityCommandRejected    = "command_rejected"
+	SecurityBanned             = "banned"
+)
+
+// securityEventBurst bounds how many events of one kind are delivered per
+// second, so a flood of failures does not flood admin dashboards
+const securityEventBurst = 20
+
+// securityLimiter counts events per kind in the current one-second window
+type securityLimiter struct {
+	window     time.Time
+	counts     map[string]int
+	suppressed map[string]int
+
+	mu sync.Mutex
+}
+
+// allow reports whether an event of kind may be delivered now and how many
+// were suppressed since the last delivered one
+func (l *securityLimiter) allow(kind string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.counts == nil || now.Sub(l.window) >= time.Second {
+		l.window = now
+		l.counts = make(map[string]int)
+	}
+	if l.suppressed == nil {
+		l.suppressed = make(map[string]int)
+	}
+
+	if l.counts[kind] >= securityEventBurst {
+		l.suppressed[kind]++
+		return false, 0
+	}
+	l.counts[kind]++
+
+	suppressed := l.suppressed[kind]
+	delete(l.suppressed, kind)
+	return true, suppressed
+}
+
+// isAdminClient reports whether a client's token explicitly grants admin
+// access (tokens without scopes are not enough)
+func isAdminClient(client *Client) bool {
+	return client.scopes != nil && hasScope(client.scopes, ScopeAdmin)
+}
+
+// PublishSecurityEvent sends a security_event to every admin web and
+// monitor client in all rooms and returns how many received it. detail
+// fields are merged into the message.
+func (h *Hub) PublishSecurityEvent(kind string, detail map[string]interface{}) int {
+	now := time.Now()
+	ok, suppressed := h.security.allow(kind, now)
+	if !ok {
+		return 0
+	}
+
+	event := make(map[string]interface{}, len(detail)+4)
+	for key, value := range detail {
+		event[key] = value
+	}
+	event["type"] = "security_event"
+	event["event"] = kind
+	event["timestamp"] = now.Unix()
+	if suppressed > 0 {
+		event["suppressed"] = suppressed
+	}
+
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal security event %s: %v", kind, err)
+		return 0
+	}
+
+	h.mu.RLock()
+	var recipients []*Client
+	for _, clientType := range []ClientType{ClientTypeWeb, ClientTypeMonitor} {
+		for client := range h.clients[clientType] {
+			if isAdminClient(client) {
+				recipients = append(recipients, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	delivered := 0
+	for _, client := range recipients {
+		if !h.deliver(client, outbound{data: message}) {
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestSecurityEventsAdminOnly tests that security events reach admin web and
+// monitor clients only
+func TestSecurityEventsAdminOnly(t *testing.T) {
+	hub := NewHub()
+	admin := newTestClient(hub, ClientTypeWeb, "admin")
+	admin.scopes = []string{ScopeAdmin}
+	monitor := newTestClient(hub, ClientTypeMonitor, "ops")
+	monitor.scopes = []string{ScopeAdmin}
+	user := newTestClient(hub, ClientTypeWeb, "alice")
+	unscoped := newTestClient(hub, ClientTypeWeb, "legacy")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.scopes = []string{ScopeAdmin}
+
+	delivered := hub.PublishSecurityEvent(SecurityLoginFailed, map[string]interface{}{
+		"username":    "mallory",
+		"remote_addr": "10.0.0.9",
+	})
+	if delivered != 2 {
+		t.Fatalf("Expected 2 recipients, got %d", delivered)
+	}
+
+	messages := drainMessages(admin)
+	if len(messages) != 1 {
+		t.Fatalf("Expected one event for admin, got %d", len(messages))
+	}
+	var event map[string]interface{}
+	json.Unmarshal(messages[0], &event)
+	if event["type"] != "security_event" || event["event"] != SecurityLoginFailed {
+		t.Errorf("Unexpected event: %v", event)
+	}
+	if event["username"] != "mallory" || event["remote_addr"] != "10.0.0.9" {
+		t.Errorf("Expected detail fields in event, got %v", event)
+	}
+
+	if len(drainMessages(monitor)) != 1 {
+		t.Error("Expected admin monitor to receive the event")
+	}
+	for _, client := range []*Client{user, unscoped, robot} {
+		if n := len(drainMessages(client)); n != 0 {
+			t.Errorf("Expected no events for %s, got %d", client.username, n)
+		}
+	}
+}
+
+// TestSecurityEventsRateLimited tests that a burst of one kind is capped and
+// the next delivered event reports how many were suppressed
+func TestSecurityEventsRateLimited(t *testing.T) {
+	hub := NewHub()
+	admin := newTestClient(hub, ClientTypeWeb, "admin")
+	admin.scopes = []string{ScopeAdmin}
+
+	for i := 0; i < securityEventBurst+5; i++ {
+		hub.PublishSecurityEvent(SecurityTokenRejected, nil)
+	}
+	if n := len(drainMessages(admin)); n != securityEventBurst {
+		t.Fatalf("Expected %d events, got %d", securityEventBurst, n)
+	}
+
+	// Other kinds have their own budget
+	if hub.PublishSecurityEvent(SecurityIPBanned, nil) != 1 {
+		t.Error("Expected ip_banned to be delivered")
+	}
+	drainMessages(admin)
+
+	// Start a new window
+	hub.security.mu.Lock()
+	hub.security.window = hub.security.window.Add(-2 * time.Second)
+	hub.security.mu.Unlock()
+
+	hub.PublishSecurityEvent(SecurityTokenRejected, nil)
+	messages := drainMessages(admin)
+	if len(messages) != 1 {
+		t.Fatalf("Expected one event, got %d", len(messages))
+	}
+	var event map[string]interface{}
+	json.Unmarshal(messages[0], &event)
+	if event["suppressed"] != float64(5) {
+		t.Errorf("Expected suppressed=5, got %v", event["suppressed"])
+	}
+}
+
+// TestSecurityEventEmergencyStop tests that an e-stop is reported to admins
+func TestSecurityEventEmergencyStop(t *testing.T) {
+	hub := NewHub()
+	admin := newTestClient(hub, ClientTypeWeb, "admin")
+	admin.scopes = []string{ScopeAdmin}
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop"}`))
+
+	var found bool
+	for _, raw := range drainMessages(admin) {
+		var event map[string]interface{}
+		json.Unmarshal(raw, &event)
+		if event["type"] == "security_event" && event["event"] == SecurityEmergencyStop {
+			found = event["username"] == "alice" && event["room"] == "default"
+		}
+	}
+	if !found {
+		t.Error("Expected emergency_stop security event for alice")
+	}
+}
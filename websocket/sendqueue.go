@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"oculo-pilot-server/logging"
+)
+
+// DefaultSendQueueSize is how many outbound messages a client can have
+// queued when no size is configured
+const DefaultSendQueueSize = 256
+
+// SendPolicy decides what happens when a client's send queue is full
+type SendPolicy string
+
+const (
+	// SendDropOldest discards the oldest queued message to make room, for
+	// clients that only care about the latest state (e.g. telemetry views)
+	SendDropOldest SendPolicy = "drop_oldest"
+
+	// SendDisconnect drops the client, for clients that must not miss a
+	// message (e.g. robots receiving commands)
+	SendDisconnect SendPolicy = "disconnect"
+)
+
+// SendQueueConfig holds the size of client send queues and the policy per
+// client type when one is full
+type SendQueueConfig struct {
+	Size     int                       // 0 uses DefaultSendQueueSize
+	Policies map[ClientType]SendPolicy // Unlisted types use SendDropOldest
+}
+
+// defaultSendQueue disconnects robots that stop reading commands and lets
+// everyone else skip stale messages
+var defaultSendQueue = SendQueueConfig{
+	Size:     DefaultSendQueueSize,
+	Policies: map[ClientType]SendPolicy{ClientTypeControl: SendDisconnect},
+}
+
+// policy returns the send policy for a client type
+func (c SendQueueConfig) policy(clientType ClientType) SendPolicy {
+	if policy, ok := c.Policies[clientType]; ok {
+		return policy
+	}
+	return SendDropOldest
+}
+
+// SetSendQueue sets the send queue size of new clients and the policy when
+// a queue is full. Call before Run.
+func (h *Hub) SetSendQueue(config SendQueueConfig) {
+	h.sendQueue = config
+}
+
+// sendQueueSize returns the send queue size for a new client
+func (h *Hub) sendQueueSize() int {
+	if h.sendQueue.Size <= 0 {
+		return DefaultSendQueueSize
+	}
+	return h.sendQueue.Size
+}
+
+// deliver queues a message for a client. When the queue is full the
+// client's policy either discards its oldest queued messages or drops the
+// client; deliver reports false in the latter case.
+func (h *Hub) deliver(client *Client, message outbound) bool {
+	if client.enqueue(message) {
+		h.recordMessage(client, DirectionOut, message.data)
+		return true
+	}
+	// A removed client has nothing left to drop
+	if client.sendDone() {
+		return false
+	}
+
+	if h.sendQueue.policy(client.Type()) == SendDropOldest {
+		// Other senders may refill the queue between the two steps
+		for attempt := 0; attempt < 3; attempt++ {
+			select {
+			case <-client.send:
+				h.sendDropped.Add(1)
+			default:
+			}
+			if client.enqueue(message) {
+				h.recordMessage(client, DirectionOut, message.data)
+				logging.Sampled("ws_send_dropped", "⚠️  %s (%s) is not keeping up, dropped its oldest queued message",
+					client.username, client.Type())
+				return true
+			}
+		}
+	}
+
+	go h.dropClient(client, "send_buffer_full")
+	return false
+}
+
+// SendDropped returns how many queued messages were discarded to make room
+// for newer ones
+func (h *Hub) SendDropped() int64 {
+	return h.sendDropped.Load()
+}
@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSendQueueDropOldest tests that a full queue discards its oldest
+// message instead of dropping the client
+func TestSendQueueDropOldest(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub, ClientTypeWeb, "alice")
+	client.send = make(chan outbound, 2)
+
+	for _, message := range []string{"1", "2", "3"} {
+		if !hub.deliver(client, outbound{data: []byte(message)}) {
+			t.Fatalf("Expected message %s to be queued", message)
+		}
+	}
+
+	messages := drainMessages(client)
+	if len(messages) != 2 || string(messages[0]) != "2" || string(messages[1]) != "3" {
+		t.Errorf("Expected the two newest messages, got %q", messages)
+	}
+	if hub.SendDropped() != 1 || hub.GetStats()["send_dropped"] != int64(1) {
+		t.Errorf("Expected one dropped message, got %d", hub.SendDropped())
+	}
+	if client.CloseReason() != "" {
+		t.Errorf("Expected the client to stay connected, got %q", client.CloseReason())
+	}
+}
+
+// TestSendQueueDisconnect tests that control clients are dropped when their
+// queue is full, and that the policy is configurable
+func TestSendQueueDisconnect(t *testing.T) {
+	hub := NewHub()
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.send = make(chan outbound, 1)
+
+	hub.deliver(robot, outbound{data: []byte("1")})
+	if hub.deliver(robot, outbound{data: []byte("2")}) {
+		t.Fatal("Expected delivery to a full control client to fail")
+	}
+	deadline := time.Now().Add(time.Second)
+	for robot.CloseReason() == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if robot.CloseReason() != "send_buffer_full" {
+		t.Errorf("Expected the robot to be dropped, got %q", robot.CloseReason())
+	}
+
+	hub = NewHub()
+	hub.SetSendQueue(SendQueueConfig{Size: 8, Policies: map[ClientType]SendPolicy{ClientTypeWeb: SendDisconnect}})
+	if hub.sendQueueSize() != 8 {
+		t.Errorf("Expected queue size 8, got %d", hub.sendQueueSize())
+	}
+	if hub.sendQueue.policy(ClientTypeControl) != SendDropOldest || hub.sendQueue.policy(ClientTypeWeb) != SendDisconnect {
+		t.Error("Expected the configured policies to replace the defaults")
+	}
+}
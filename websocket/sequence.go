@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+)
+
+// sequenceGaps counts the gaps write pumps reported and the messages in
+// them
+type sequenceGaps struct {
+	notices atomic.Int64
+	missed  atomic.Int64
+}
+
+// sequenceStats returns how many gap_detected notices were sent and how
+// many messages they covered
+func (h *Hub) sequenceStats() map[string]interface{} {
+	return map[string]interface{}{
+		"gaps":   h.sequenceGaps.notices.Load(),
+		"missed": h.sequenceGaps.missed.Load(),
+	}
+}
+
+// sequenced reports whether messages to the client are numbered. Only a
+// version 2 client that asked for FeatureSequence gets numbers; version 1
+// clients would not expect the extra field.
+func (c *Client) sequenced() bool {
+	return c.ProtocolVersion() >= 2 && c.HasFeature(FeatureSequence)
+}
+
+// nextSeq numbers a message for a sequenced client, or clears a number it
+// carried from a previous connection. The number is only used once the
+// message is queued (see commitSeq), so a failed enqueue leaves no hole.
+func (c *Client) nextSeq(message outbound) outbound {
+	message.seq = 0
+	if c.sequenced() {
+		message.seq = c.seq + 1
+	}
+	return message
+}
+
+// commitSeq records the number of a queued message
+func (c *Client) commitSeq(message outbound) {
+	if message.seq != 0 {
+		c.seq = message.seq
+	}
+}
+
+// sequenceState tracks which numbers a write pump has accounted for, so
+// it can report the ones a full send queue dropped. It is only used from
+// the client's writePump, so it needs no lock.
+type sequenceState struct {
+	written uint64 // Highest number written or deliberately skipped
+	from    uint64 // First number missing since the last gap notice
+	to      uint64 // Last number missing since the last gap notice
+	missed  uint64 // How many numbers are missing in from..to
+}
+
+// account notes a number reaching the write pump and any missing before it
+func (s *sequenceState) account(seq uint64) {
+	if seq > s.written+1 {
+		if s.missed == 0 {
+			s.from = s.written + 1
+		}
+		s.to = seq - 1
+		s.missed += seq - s.written - 1
+	}
+	s.written = seq
+}
+
+// skip accounts for a message dropped on purpose, e.g. after its TTL
+// passed; the recipient sees its number skipped without a gap notice
+func (c *Client) skip(message outbound) {
+	if message.seq != 0 {
+		c.sequence.account(message.seq)
+	}
+}
+
+// stamp adds the message's sequence number to its data and returns the
+// gap_detected notice to write before it, if messages were dropped since
+// the last one. Unnumbered messages are returned as they are.
+func (c *Client) stamp(message outbound) (gap, data []byte) {
+	if message.seq == 0 {
+		return nil, message.data
+	}
+	c.sequence.account(message.seq)
+	if missed := c.sequence.missed; missed > 0 {
+		gap, _ = json.Marshal(map[string]interface{}{
+			"type":     "gap_detected",
+			"from_seq": c.sequence.from,
+			"to_seq":   c.sequence.to,
+			"missed":   missed,
+		})
+		c.hub.sequenceGaps.notices.Add(1)
+		c.hub.sequenceGaps.missed.Add(int64(missed))
+		c.sequence.missed = 0
+	}
+	return gap, stampSeq(message.data, message.seq)
+}
+
+// stampSeq adds a "seq" field to a JSON object without decoding it. The
+// field goes last, so it replaces any seq the sender set. Other JSON is
+// returned unchanged.
+func stampSeq(data []byte, seq uint64) []byte {
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	if len(trimmed) < 2 || trimmed[0] != '{' || trimmed[len(trimmed)-1] != '}' {
+		return data
+	}
+	body := bytes.TrimRight(trimmed[:len(trimmed)-1], " \t\r\n")
+
+	stamped := make([]byte, 0, len(body)+32)
+	stamped = append(stamped, body...)
+	if len(body) > 1 {
+		stamped = append(stamped, ',')
+	}
+	stamped = append(stamped, `"seq":`...)
+	stamped = strconv.AppendUint(stamped, seq, 10)
+	return append(stamped, '}')
+}
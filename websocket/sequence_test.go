@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestStampSeq tests that the sequence number is added last to JSON
+// objects and other data is left alone
+func TestStampSeq(t *testing.T) {
+	cases := map[string]string{
+		`{"type":"location_update"}`:   `{"type":"location_update","seq":7}`,
+		`{"type":"status","seq":99} `:  `{"type":"status","seq":99,"seq":7}`,
+		`{}`:                           `{"seq":7}`,
+		`[{"type":"location_update"}]`: `[{"type":"location_update"}]`,
+		`not json`:                     `not json`,
+	}
+	for data, want := range cases {
+		if got := string(stampSeq([]byte(data), 7)); got != want {
+			t.Errorf("stampSeq(%s) = %s, want %s", data, got, want)
+		}
+	}
+}
+
+// TestSequenceNumbering tests that only clients that negotiated sequence
+// get numbers and that a failed enqueue uses none
+func TestSequenceNumbering(t *testing.T) {
+	hub := NewHub()
+	legacy := newTestClient(hub, ClientTypeWeb, "bob")
+	client := newTestClient(hub, ClientTypeWeb, "alice")
+	client.negotiated.Store(&negotiation{version: 2, features: map[string]bool{FeatureSequence: true}})
+	client.send = make(chan outbound, 1)
+
+	legacy.enqueue(outbound{data: []byte(`{}`)})
+	if message := <-legacy.send; message.seq != 0 {
+		t.Errorf("Expected no number for a version 1 client, got %d", message.seq)
+	}
+
+	client.enqueue(outbound{data: []byte(`{}`)})
+	if client.enqueue(outbound{data: []byte(`{}`)}) {
+		t.Fatal("Expected the full queue to refuse a message")
+	}
+	<-client.send
+	client.enqueue(outbound{data: []byte(`{}`)})
+	if message := <-client.send; message.seq != 2 {
+		t.Errorf("Expected the refused message to leave no hole, got seq %d", message.seq)
+	}
+}
+
+// TestWritePumpGapDetected tests that messages a full queue dropped are
+// reported before the next one written
+func TestWritePumpGapDetected(t *testing.T) {
+	hub := NewHub()
+	hub.SetSendQueue(SendQueueConfig{Size: 2})
+
+	ready := make(chan *Client, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(hub, conn, ClientTypeWeb, 1, "alice", 4096)
+		client.negotiated.Store(&negotiation{version: 2, features: map[string]bool{FeatureSequence: true}})
+		for _, n := range []string{"1", "2", "3", "4"} {
+			hub.deliver(client, outbound{data: []byte(`{"type":"location_update","n":` + n + `}`)})
+		}
+		go client.writePump(func() { ready <- client })
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	<-ready
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	_, frame, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	want := `{"from_seq":1,"missed":2,"to_seq":2,"type":"gap_detected"}` + "\n" +
+		`{"type":"location_update","n":3,"seq":3}` + "\n" +
+		`{"type":"location_update","n":4,"seq":4}`
+	if string(frame) != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, frame)
+	}
+	if stats := hub.sequenceStats(); stats["gaps"] != int64(1) || stats["missed"] != int64(2) {
+		t.Errorf("Expected one gap of two messages, got %v", stats)
+	}
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// terminateDrainTimeout bounds how long a terminated session may take to
+// receive its notice before the connection is closed
+const terminateDrainTimeout = time.Second
+
+// NotifyLogin tells a user's connected web sessions that the account just
+// logged in from remoteAddr. With terminate set the sessions are closed
+// after the notice is delivered. Returns the number of sessions notified.
+func (h *Hub) NotifyLogin(username, remoteAddr string, terminate bool) int {
+	h.mu.RLock()
+	var sessions []*Client
+	for client := range h.clients[ClientTypeWeb] {
+		if client.username == username {
+			sessions = append(sessions, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	action := "warn"
+	if terminate {
+		action = "terminate"
+	}
+
+	for _, client := range sessions {
+		client.SendJSON(map[string]interface{}{
+			"type":        "session_login",
+			"username":    username,
+			"remote_addr": remoteAddr,
+			"action":      action,
+			"timestamp":   time.Now().Unix(),
+		})
+		if terminate {
+			go client.closeAfterDrain(websocket.ClosePolicyViolation, "session replaced by a newer login")
+		}
+	}
+
+	if len(sessions) > 0 {
+		log.Printf("👥 %s logged in from %s: %s %d existing session(s)", username, remoteAddr, action, len(sessions))
+	}
+	return len(sessions)
+}
+
+// closeAfterDrain closes the connection once queued messages are written or
+// terminateDrainTimeout passes
+func (c *Client) closeAfterDrain(code int, text string) {
+	deadline := time.Now().Add(terminateDrainTimeout)
+	for len(c.send) > 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	c.closeConn(code, text)
+}
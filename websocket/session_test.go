@@ -0,0 +1,34 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestNotifyLogin tests notifying only the user's web sessions
+func TestNotifyLogin(t *testing.T) {
+	hub := NewHub()
+	session := newTestClient(hub, ClientTypeWeb, "operator")
+	other := newTestClient(hub, ClientTypeWeb, "viewer")
+	robot := newTestClient(hub, ClientTypeControl, "operator")
+
+	if n := hub.NotifyLogin("operator", "10.0.0.5", false); n != 1 {
+		t.Fatalf("Expected 1 session notified, got %d", n)
+	}
+
+	messages := drainMessages(session)
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	var notice map[string]interface{}
+	if err := json.Unmarshal(messages[0], &notice); err != nil {
+		t.Fatalf("Invalid notice: %v", err)
+	}
+	if notice["type"] != "session_login" || notice["remote_addr"] != "10.0.0.5" || notice["action"] != "warn" {
+		t.Errorf("Unexpected notice: %v", notice)
+	}
+
+	if len(drainMessages(other)) != 0 || len(drainMessages(robot)) != 0 {
+		t.Error("Expected other users and robot clients not to be notified")
+	}
+}
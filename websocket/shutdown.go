@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DrainReport summarizes what happened to connected clients during shutdown
+type DrainReport struct {
+	// ClosedByType counts connections closed per client type
+	ClosedByType map[ClientType]int `json:"connections_closed"`
+
+	// DroppedMessages counts messages still queued when the drain timed out
+	DroppedMessages int `json:"messages_dropped"`
+
+	// SessionsPersisted counts sessions saved so clients can resume after a
+	// restart (zero until session resume is supported)
+	SessionsPersisted int `json:"sessions_persisted"`
+
+	Duration time.Duration `json:"-"`
+}
+
+// TotalClosed returns the number of connections closed
+func (r DrainReport) TotalClosed() int {
+	total := 0
+	for _, n := range r.ClosedByType {
+		total += n
+	}
+	return total
+}
+
+// Shutdown notifies every client, waits until their send queues drain or
+// ctx is done, then closes all connections
+func (h *Hub) Shutdown(ctx context.Context) DrainReport {
+	start := time.Now()
+	report := DrainReport{ClosedByType: make(map[ClientType]int)}
+
+	h.mu.RLock()
+	var clients []*Client
+	for _, typeClients := range h.clients {
+		for client := range typeClients {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	notice, err := json.Marshal(map[string]interface{}{
+		"type":      "server_shutdown",
+		"timestamp": time.Now().Unix(),
+	})
+	if err == nil {
+		// Only this instance is going away (see backplane.go)
+		h.deliverTo(AllRooms, routeTarget{}, nil, notice)
+	}
+
+	// Wait for queued messages to be written
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for !allDrained(clients) {
+		select {
+		case <-ctx.Done():
+			log.Printf("⏱️  Drain timed out with messages still queued")
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+
+	for _, client := range clients {
+		report.DroppedMessages += len(client.send)
+		report.ClosedByType[client.Type()]++
+		client.closeConn(websocket.CloseGoingAway, "server shutdown")
+	}
+
+	report.Duration = time.Since(start)
+	return report
+}
+
+// allDrained reports whether every client's send queue is empty
+func allDrained(clients []*Client) bool {
+	for _, client := range clients {
+		if len(client.send) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// closeConn sends a close frame and closes the underlying connection
+func (c *Client) closeConn(code int, text string) {
+	c.setCloseReason(text)
+	if c.conn == nil {
+		return
+	}
+	c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, text), time.Now().Add(writeWait))
+	c.conn.Close()
+}
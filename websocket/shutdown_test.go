@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestHubShutdownReport tests counting closed connections and dropped messages
+func TestHubShutdownReport(t *testing.T) {
+	hub := NewHub()
+	newTestClient(hub, ClientTypeWeb, "operator")
+	newTestClient(hub, ClientTypeWeb, "observer")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.enqueue(outbound{data: []byte(`{"type":"control_command"}`)})
+
+	// Test clients have no write pump, so queues never drain and time out
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report := hub.Shutdown(ctx)
+
+	if report.ClosedByType[ClientTypeWeb] != 2 || report.ClosedByType[ClientTypeControl] != 1 {
+		t.Errorf("Unexpected closed counts: %v", report.ClosedByType)
+	}
+	if report.TotalClosed() != 3 {
+		t.Errorf("Expected 3 closed connections, got %d", report.TotalClosed())
+	}
+	// One server_shutdown notice per client plus the queued command
+	if report.DroppedMessages != 4 {
+		t.Errorf("Expected 4 dropped messages, got %d", report.DroppedMessages)
+	}
+}
+
+// TestHubShutdownDrained tests a shutdown with empty queues returns promptly
+func TestHubShutdownDrained(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub, ClientTypeWeb, "operator")
+
+	// Drain the shutdown notice as a write pump would
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-client.send
+	}()
+
+	report := hub.Shutdown(context.Background())
+	<-done
+
+	if report.DroppedMessages != 0 {
+		t.Errorf("Expected no dropped messages, got %d", report.DroppedMessages)
+	}
+	if report.TotalClosed() != 1 {
+		t.Errorf("Expected 1 closed connection, got %d", report.TotalClosed())
+	}
+}
@@ -0,0 +1,260 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxSignalingSessions bounds the sessions one web client may hold; opening
+// another closes its oldest
+const maxSignalingSessions = 8
+
+// Reasons a signaling session was closed
+const (
+	// SignalingClosedDisconnected: the other party left the hub
+	SignalingClosedDisconnected = "peer_disconnected"
+
+	// SignalingClosedReplaced: the web client opened too many sessions and
+	// this was its oldest
+	SignalingClosedReplaced = "replaced"
+)
+
+// signalingSession is the WebRTC negotiation between one web client and
+// one video or audio client. Answers and ICE candidates are exchanged only
+// between the pair, so several viewers can negotiate with one camera.
+type signalingSession struct {
+	id     string
+	media  string // MediaVideo or MediaAudio
+	web    *Client
+	peer   *Client
+	opened time.Time
+}
+
+// signalingSessions holds the open signaling sessions by ID
+type signalingSessions struct {
+	sessions map[string]*signalingSession
+	mu       sync.Mutex
+}
+
+// other returns the party of the session that is not client
+func (s *signalingSession) other(client *Client) *Client {
+	if client == s.web {
+		return s.peer
+	}
+	return s.web
+}
+
+// closedNotice tells the party left in a session that it ended
+func (s *signalingSession) closedNotice(reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "signaling_closed",
+		"session_id": s.id,
+		"media":      s.media,
+		"reason":     reason,
+	}
+}
+
+// newSignalingSessionID returns a random session ID
+func newSignalingSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// signalingPeer returns the one local client of type destination in the
+// web client's room that an offer addresses, or nil if there is none here.
+// When several match, the web client is asked to pick one with
+// target_connection_id and ok is false.
+func (h *Hub) signalingPeer(web *Client, destination ClientType, target routeTarget) (peer *Client, ok bool) {
+	h.mu.RLock()
+	var candidates []*Client
+	for client := range h.clients[destination] {
+		if client.Room() == web.Room() && target.matches(client) {
+			candidates = append(candidates, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(candidates) <= 1 {
+		if len(candidates) == 1 {
+			peer = candidates[0]
+		}
+		return peer, true
+	}
+
+	connectionIDs := make([]string, 0, len(candidates))
+	for _, client := range candidates {
+		connectionIDs = append(connectionIDs, client.connectionID)
+	}
+	web.SendJSON(map[string]interface{}{
+		"type":           "error",
+		"error":          "ambiguous_target",
+		"message_type":   "offer",
+		"client_type":    destination,
+		"connection_ids": connectionIDs,
+	})
+	return nil, false
+}
+
+// openSignalingSession returns the session between a web client and a
+// media client, opening it if the pair has none for the media, or nil if
+// no session ID could be generated. Renewed offers reuse the session.
+func (h *Hub) openSignalingSession(web, peer *Client, media string) *signalingSession {
+	s := &h.signaling
+	s.mu.Lock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*signalingSession)
+	}
+
+	id, err := newSignalingSessionID()
+	if err != nil {
+		s.mu.Unlock()
+		log.Printf("❌ Failed to generate signaling session ID: %v", err)
+		return nil
+	}
+
+	var oldest *signalingSession
+	count := 0
+	for _, session := range s.sessions {
+		if session.web != web {
+			continue
+		}
+		if session.peer == peer && session.media == media {
+			s.mu.Unlock()
+			return session
+		}
+		count++
+		if oldest == nil || session.opened.Before(oldest.opened) {
+			oldest = session
+		}
+	}
+	if count < maxSignalingSessions {
+		oldest = nil
+	} else {
+		delete(s.sessions, oldest.id)
+	}
+
+	session := &signalingSession{
+		id:     id,
+		media:  media,
+		web:    web,
+		peer:   peer,
+		opened: time.Now(),
+	}
+	s.sessions[session.id] = session
+	s.mu.Unlock()
+
+	if oldest != nil {
+		oldest.peer.SendJSON(oldest.closedNotice(SignalingClosedReplaced))
+	}
+	log.Printf("📡 Opened %s signaling session %s between %s and %s",
+		media, session.id, web.username, peer.username)
+	web.SendJSON(map[string]interface{}{
+		"type":               "signaling_session",
+		"session_id":         session.id,
+		"media":              media,
+		"peer_connection_id": peer.connectionID,
+	})
+	return session
+}
+
+// findSignalingSession returns the session of client for the media that a
+// message belongs to: the one named by sessionID, or else the client's
+// only session with a party matching target. On failure it returns the
+// error to report.
+func (h *Hub) findSignalingSession(client *Client, media, sessionID string, target routeTarget) (*signalingSession, string) {
+	s := &h.signaling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sessionID != "" {
+		session := s.sessions[sessionID]
+		if session == nil || (session.web != client && session.peer != client) {
+			return nil, "unknown_session"
+		}
+		return session, ""
+	}
+
+	var found *signalingSession
+	for _, session := range s.sessions {
+		if session.media != media || (session.web != client && session.peer != client) ||
+			!target.matches(session.other(client)) {
+			continue
+		}
+		if found != nil {
+			return nil, "session_required"
+		}
+		found = session
+	}
+	if found == nil {
+		return nil, "unknown_session"
+	}
+	return found, ""
+}
+
+// closeSignalingSessions ends the sessions of a client leaving the hub and
+// tells the other parties
+func (h *Hub) closeSignalingSessions(client *Client) {
+	s := &h.signaling
+	s.mu.Lock()
+	var closed []*signalingSession
+	for id, session := range s.sessions {
+		if session.web == client || session.peer == client {
+			closed = append(closed, session)
+			delete(s.sessions, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, session := range closed {
+		session.other(client).SendJSON(session.closedNotice(SignalingClosedDisconnected))
+	}
+	if len(closed) > 0 {
+		log.Printf("📡 Closed %d signaling sessions of %s", len(closed), client.username)
+	}
+}
+
+// handOverSignalingSessions moves a resumed client's sessions to the client
+// that resumed it
+func (h *Hub) handOverSignalingSessions(previous, client *Client) {
+	s := &h.signaling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range s.sessions {
+		if session.web == previous {
+			session.web = client
+		}
+		if session.peer == previous {
+			session.peer = client
+		}
+	}
+}
+
+// signalingSessionCount returns how many signaling sessions are open
+func (h *Hub) signalingSessionCount() int {
+	s := &h.signaling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// withSessionID returns a signaling message with its session_id set, so
+// the receiving party can name the session in its replies
+func withSessionID(rawMessage []byte, sessionID string) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rawMessage, &fields); err != nil {
+		return rawMessage
+	}
+	fields["session_id"], _ = json.Marshal(sessionID)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return rawMessage
+	}
+	return data
+}
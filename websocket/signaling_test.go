@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// lastMessage returns the last queued message of a client, decoded
+func lastMessage(t *testing.T, client *Client) map[string]interface{} {
+	t.Helper()
+	messages := drainMessages(client)
+	if len(messages) == 0 {
+		t.Fatalf("Expected a message for %s", client.username)
+	}
+	var msg map[string]interface{}
+	json.Unmarshal(messages[len(messages)-1], &msg)
+	return msg
+}
+
+// TestSignalingSessions tests that two viewers negotiating with one camera
+// only receive the signaling of their own session
+func TestSignalingSessions(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+	camera := newTestClient(hub, ClientTypeVideo, "camera")
+	camera.SetConnectionID("conn_camera")
+
+	// Each offer opens a session, named in the forwarded offer
+	hub.RouteMessage(alice, []byte(`{"type":"offer","sdp":"v=0"}`))
+	opened := lastMessage(t, alice)
+	aliceSession, _ := opened["session_id"].(string)
+	if opened["type"] != "signaling_session" || aliceSession == "" || opened["peer_connection_id"] != "conn_camera" {
+		t.Fatalf("Expected a signaling session for alice, got %v", opened)
+	}
+	if offer := lastMessage(t, camera); offer["type"] != "offer" || offer["session_id"] != aliceSession {
+		t.Errorf("Expected the offer to carry alice's session, got %v", offer)
+	}
+	hub.RouteMessage(bob, []byte(`{"type":"offer","sdp":"v=0"}`))
+	bobSession, _ := lastMessage(t, bob)["session_id"].(string)
+	drainMessages(camera)
+	if bobSession == "" || bobSession == aliceSession {
+		t.Fatalf("Expected bob to get his own session, got %q", bobSession)
+	}
+
+	// The camera's answer reaches only the viewer of its session
+	hub.RouteMessage(camera, []byte(`{"type":"answer","sdp":"v=0","session_id":"`+bobSession+`"}`))
+	if len(drainMessages(alice)) != 0 || lastMessage(t, bob)["type"] != "answer" {
+		t.Error("Expected the answer to reach only bob")
+	}
+
+	// With two sessions the camera must name one
+	hub.RouteMessage(camera, []byte(`{"type":"ice-candidate","candidate":"c"}`))
+	if reply := lastMessage(t, camera); reply["error"] != "session_required" {
+		t.Errorf("Expected session_required, got %v", reply)
+	}
+	if len(drainMessages(alice))+len(drainMessages(bob)) != 0 {
+		t.Error("Expected an unnamed candidate to reach no viewer")
+	}
+
+	// A viewer's only session is implied, and others' sessions are not
+	// usable
+	hub.RouteMessage(alice, []byte(`{"type":"ice-candidate","candidate":"c"}`))
+	if candidate := lastMessage(t, camera); candidate["session_id"] != aliceSession {
+		t.Errorf("Expected alice's candidate in her session, got %v", candidate)
+	}
+	hub.RouteMessage(alice, []byte(`{"type":"ice-candidate","candidate":"c","session_id":"`+bobSession+`"}`))
+	if reply := lastMessage(t, alice); reply["error"] != "unknown_session" {
+		t.Errorf("Expected unknown_session, got %v", reply)
+	}
+	if len(drainMessages(camera)) != 0 {
+		t.Error("Expected a candidate in another's session not to be routed")
+	}
+
+	// Leaving closes the viewer's sessions
+	hub.removeClient(alice)
+	if closed := lastMessage(t, camera); closed["type"] != "signaling_closed" || closed["session_id"] != aliceSession {
+		t.Errorf("Expected signaling_closed for alice's session, got %v", closed)
+	}
+	if got := hub.signalingSessionCount(); got != 1 {
+		t.Errorf("Expected 1 open session, got %d", got)
+	}
+}
+
+// TestSignalingAmbiguousOffer tests that an offer matching several cameras
+// must pick one with target_connection_id
+func TestSignalingAmbiguousOffer(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	front := newTestClient(hub, ClientTypeVideo, "camera")
+	front.SetConnectionID("conn_front")
+	rear := newTestClient(hub, ClientTypeVideo, "camera")
+	rear.SetConnectionID("conn_rear")
+
+	hub.RouteMessage(operator, []byte(`{"type":"offer","sdp":"v=0"}`))
+	reply := lastMessage(t, operator)
+	if reply["error"] != "ambiguous_target" {
+		t.Fatalf("Expected ambiguous_target, got %v", reply)
+	}
+	if ids, _ := reply["connection_ids"].([]interface{}); len(ids) != 2 {
+		t.Errorf("Expected both cameras listed, got %v", reply["connection_ids"])
+	}
+	if len(drainMessages(front))+len(drainMessages(rear)) != 0 {
+		t.Error("Expected an ambiguous offer to reach no camera")
+	}
+}
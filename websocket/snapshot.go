@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// lastStateMaxAge is how long a cached telemetry message is still sent to
+// late joiners; older state would show a robot where it no longer is
+const lastStateMaxAge = time.Hour
+
+// lastStateTypes are the message types whose latest copy per robot is kept
+var lastStateTypes = map[string]bool{
+	"location_update": true,
+	"route_update":    true,
+}
+
+// lastStateKey identifies a cached message within a room
+type lastStateKey struct {
+	robotID string
+	msgType string
+}
+
+// lastStateEntry is the latest message of one type from one robot
+type lastStateEntry struct {
+	data       []byte
+	receivedAt time.Time
+}
+
+// lastStateCache keeps the latest telemetry per room, robot and type so
+// web clients that join late see the current state at once
+type lastStateCache struct {
+	mu    sync.Mutex
+	rooms map[string]map[lastStateKey]lastStateEntry
+}
+
+// cacheLastState keeps a telemetry message as the latest of its type from
+// its robot
+func (h *Hub) cacheLastState(sender *Client, msgType string, rawMessage []byte, target routeTarget) {
+	if !lastStateTypes[msgType] {
+		return
+	}
+
+	h.lastState.mu.Lock()
+	defer h.lastState.mu.Unlock()
+	if h.lastState.rooms == nil {
+		h.lastState.rooms = make(map[string]map[lastStateKey]lastStateEntry)
+	}
+	entries, ok := h.lastState.rooms[sender.Room()]
+	if !ok {
+		entries = make(map[lastStateKey]lastStateEntry)
+		h.lastState.rooms[sender.Room()] = entries
+	}
+	entries[lastStateKey{robotID: telemetryRobot(sender, target), msgType: msgType}] = lastStateEntry{
+		data:       rawMessage,
+		receivedAt: time.Now(),
+	}
+}
+
+// lastStateMessages returns the cached messages in a room that a client
+// watching robotID ("" for every robot) receives, oldest first. Expired
+// entries are dropped.
+func (h *Hub) lastStateMessages(room, robotID string, now time.Time) []json.RawMessage {
+	h.lastState.mu.Lock()
+	defer h.lastState.mu.Unlock()
+
+	var entries []lastStateEntry
+	for key, entry := range h.lastState.rooms[room] {
+		if now.Sub(entry.receivedAt) >= lastStateMaxAge {
+			delete(h.lastState.rooms[room], key)
+			continue
+		}
+		if robotID == "" || key.robotID == robotID {
+			entries = append(entries, entry)
+		}
+	}
+	if len(h.lastState.rooms[room]) == 0 {
+		delete(h.lastState.rooms, room)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].receivedAt.Before(entries[j].receivedAt)
+	})
+	messages := make([]json.RawMessage, len(entries))
+	for i, entry := range entries {
+		messages[i] = entry.data
+	}
+	return messages
+}
+
+// sendStateSnapshot sends a web client that just joined the latest
+// telemetry, emergency stop state and video/audio availability of its room
+func (h *Hub) sendStateSnapshot(client *Client) {
+	now := time.Now()
+	snapshot := map[string]interface{}{
+		"type":                    "state_snapshot",
+		"room":                    RoomID(client.Room()),
+		"messages":                h.lastStateMessages(client.Room(), client.RobotID(), now),
+		"video_clients_available": h.roomClientCount(client.Room(), client.RobotID(), ClientTypeVideo) > 0,
+		"audio_clients_available": h.roomClientCount(client.Room(), client.RobotID(), ClientTypeAudio) > 0,
+		"timestamp":               now.Unix(),
+	}
+
+	h.estopMu.RLock()
+	if estop, ok := h.estopRooms[client.Room()]; ok {
+		snapshot["emergency_stop"] = estop
+	}
+	h.estopMu.RUnlock()
+
+	client.SendJSON(snapshot)
+}
@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// snapshotOf completes a web client's handshake and returns the state
+// snapshot it received
+func snapshotOf(t *testing.T, hub *Hub, handshake string) map[string]interface{} {
+	t.Helper()
+	web := newTestClient(hub, ClientTypePending, "alice")
+	web.SetConnectionID("conn_web")
+	hub.RouteMessage(web, []byte(handshake))
+
+	for _, message := range drainMessages(web) {
+		var parsed map[string]interface{}
+		json.Unmarshal(message, &parsed)
+		if parsed["type"] == "state_snapshot" {
+			return parsed
+		}
+	}
+	t.Fatal("Expected a state_snapshot after the handshake")
+	return nil
+}
+
+// TestStateSnapshot tests that a web client joining late receives the
+// latest telemetry per robot and the room's emergency stop state
+func TestStateSnapshot(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	rover1 := newTestClient(hub, ClientTypeControl, "robot-1")
+	rover1.place("", "rover-1")
+	rover2 := newTestClient(hub, ClientTypeControl, "robot-2")
+	rover2.place("", "rover-2")
+	operator := newTestClient(hub, ClientTypeWeb, "bob")
+
+	hub.RouteMessage(rover1, []byte(`{"type":"location_update","data":{"seq":1}}`))
+	hub.RouteMessage(rover1, []byte(`{"type":"location_update","data":{"seq":2}}`))
+	hub.RouteMessage(rover2, []byte(`{"type":"route_update","data":{"seq":3}}`))
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop"}`))
+
+	snapshot := snapshotOf(t, hub, `{"type":"handshake_response","connection_id":"conn_web","client_type":"web"}`)
+	messages, _ := snapshot["messages"].([]interface{})
+	if len(messages) != 2 {
+		t.Fatalf("Expected the latest message of each robot, got %v", snapshot["messages"])
+	}
+	first := messages[0].(map[string]interface{})
+	if first["type"] != "location_update" || first["data"].(map[string]interface{})["seq"] != float64(2) {
+		t.Errorf("Expected rover-1's latest location first, got %v", first)
+	}
+	if estop, ok := snapshot["emergency_stop"].(map[string]interface{}); !ok || estop["active"] != true {
+		t.Errorf("Expected the active emergency stop in the snapshot, got %v", snapshot["emergency_stop"])
+	}
+
+	// A dashboard for one robot only gets that robot's state
+	snapshot = snapshotOf(t, hub, `{"type":"handshake_response","connection_id":"conn_web","client_type":"web","robot_id":"rover-2"}`)
+	if messages, _ := snapshot["messages"].([]interface{}); len(messages) != 1 {
+		t.Errorf("Expected only rover-2's route, got %v", snapshot["messages"])
+	}
+
+	// Other rooms start blank
+	snapshot = snapshotOf(t, hub, `{"type":"handshake_response","connection_id":"conn_web","client_type":"web","room":"lab"}`)
+	if messages, _ := snapshot["messages"].([]interface{}); len(messages) != 0 || snapshot["emergency_stop"] != nil {
+		t.Errorf("Expected an empty snapshot in another room, got %v", snapshot)
+	}
+}
+
+// TestStateSnapshotExpires tests that old telemetry is not sent
+func TestStateSnapshotExpires(t *testing.T) {
+	hub := NewHub()
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	hub.RouteMessage(robot, []byte(`{"type":"location_update","data":{}}`))
+
+	if messages := hub.lastStateMessages(DefaultRoom, "", time.Now().Add(lastStateMaxAge)); len(messages) != 0 {
+		t.Errorf("Expected expired state to be dropped, got %d messages", len(messages))
+	}
+	if len(hub.lastState.rooms) != 0 {
+		t.Error("Expected the empty room to be removed from the cache")
+	}
+}
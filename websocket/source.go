@@ -0,0 +1,31 @@
+package websocket
+
+import (
+	"oculo-pilot-server/logging"
+)
+
+// SourceChecker is implemented by validators that restrict users to
+// per-user source networks
+type SourceChecker interface {
+	CheckSource(userID int64, remoteAddr string) error
+}
+
+// isSourceAllowed checks the authenticated user's own allowed networks,
+// publishing an ip_blocked event when the connection comes from outside
+// them. Certificate-authenticated devices have no user and are not checked.
+func (h *Handler) isSourceAllowed(userID int64, username, remoteAddr string) bool {
+	checker, ok := h.auth.(SourceChecker)
+	if !ok || userID == 0 {
+		return true
+	}
+	if err := checker.CheckSource(userID, remoteAddr); err != nil {
+		logging.Sampled("ws_source_blocked", "🚫 Connection for %s from %s rejected: %v", username, remoteAddr, err)
+		h.hub.PublishSecurityEvent(SecurityIPBlocked, map[string]interface{}{
+			"username":    username,
+			"remote_addr": remoteAddr,
+			"reason":      "user_networks",
+		})
+		return false
+	}
+	return true
+}
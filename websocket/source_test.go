@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sourceValidator accepts any token and allows connections from one
+// address
+type sourceValidator struct {
+	mockAuthValidator
+	allowed string
+}
+
+func (v *sourceValidator) CheckSource(userID int64, remoteAddr string) error {
+	if remoteAddr != v.allowed {
+		return errors.New("access from this address is not allowed")
+	}
+	return nil
+}
+
+// TestSourceNetworks tests that a user connecting from outside their
+// allowed networks is refused before the upgrade
+func TestSourceNetworks(t *testing.T) {
+	hub := NewHub()
+	admin := newTestClient(hub, ClientTypeWeb, "admin")
+	admin.scopes = []string{ScopeAdmin}
+	handler := NewHandler(hub, &sourceValidator{allowed: "10.0.0.5"}, nil, false, time.Second, 4096)
+
+	req := httptest.NewRequest("GET", "/ws?token=valid", nil)
+	req.RemoteAddr = "198.51.100.4:41234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", rec.Code)
+	}
+	if types := messageTypes(admin); len(types) != 1 || types[0] != "security_event" {
+		t.Errorf("Expected an ip_blocked security event, got %v", types)
+	}
+
+	if !handler.isSourceAllowed(1, "testuser", "10.0.0.5") {
+		t.Error("Expected the allowed address to pass")
+	}
+	// Certificate-authenticated devices have no user to check
+	if !handler.isSourceAllowed(0, "robot-1", "198.51.100.4") {
+		t.Error("Expected devices without a user to pass")
+	}
+
+	plain := NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096)
+	if !plain.isSourceAllowed(1, "testuser", "198.51.100.4") {
+		t.Error("Expected validators without source checks to allow any address")
+	}
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestSubprotocolToken tests authenticating with a bearer.<token>
+// subprotocol and that only the agreed subprotocol is echoed
+func TestSubprotocolToken(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	dialer := websocket.Dialer{Subprotocols: []string{Subprotocol, "bearer.good-token"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	if conn.Subprotocol() != Subprotocol {
+		t.Errorf("Expected subprotocol %q, got %q", Subprotocol, conn.Subprotocol())
+	}
+	conn.Close()
+
+	dialer.Subprotocols = []string{Subprotocol, "bearer.invalid"}
+	if _, resp, err := dialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an invalid subprotocol token, got %v", err)
+	}
+
+	dialer.Subprotocols = []string{Subprotocol}
+	if _, resp, err := dialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %v", err)
+	}
+}
+
+// TestHandshakeResend tests that an unanswered handshake_request is sent
+// again with an increasing attempt number
+func TestHandshakeResend(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	handler := NewHandler(hub, &mockAuthValidator{}, nil, false, time.Second, 4096)
+	handler.SetHandshakeResend(50 * time.Millisecond)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?token=good", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	for want := 1; want <= 2; want++ {
+		var req map[string]interface{}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if err := conn.ReadJSON(&req); err != nil {
+			t.Fatalf("Read handshake_request %d failed: %v", want, err)
+		}
+		if req["type"] != "handshake_request" || req["attempt"] != float64(want) {
+			t.Errorf("Expected handshake_request attempt %d, got %v", want, req)
+		}
+	}
+}
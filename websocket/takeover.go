@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// takeoverRequestTTL is how long a takeover request waits for the holder
+// to grant or deny it
+const takeoverRequestTTL = 30 * time.Second
+
+// takeoverMessage is a request_takeover; force is only honored for admins
+type takeoverMessage struct {
+	Force bool `json:"force"`
+}
+
+// pendingTakeoverLocked returns the live takeover request on a hold;
+// control.mu must be held
+func pendingTakeoverLocked(hold *controlHold, now time.Time) *Client {
+	if hold.takeover == nil || now.Sub(hold.takeoverAt) >= takeoverRequestTTL {
+		return nil
+	}
+	return hold.takeover
+}
+
+// handleRequestTakeover asks the holder of the room's lock to hand it to
+// client. A free lock is acquired directly, and an admin sending
+// "force": true seizes the lock without asking.
+func (h *Hub) handleRequestTakeover(client *Client, rawMessage []byte) {
+	if client.Type() != ClientTypeWeb {
+		return
+	}
+	var request takeoverMessage
+	json.Unmarshal(rawMessage, &request)
+	if request.Force && !isAdminClient(client) {
+		client.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "not_permitted",
+			"message_type": "request_takeover",
+		})
+		return
+	}
+
+	now := time.Now()
+	h.control.mu.Lock()
+	hold := h.control.holds[client.Room()]
+	if hold == nil || hold.holder == client {
+		h.control.mu.Unlock()
+		h.handleRequestControl(client)
+		return
+	}
+	holder := hold.holder
+
+	if request.Force {
+		h.control.holds[client.Room()] = &controlHold{holder: client, acquiredAt: now, lastActivity: now}
+		h.control.mu.Unlock()
+		h.completeTakeover(holder, client, "taken_over", true)
+		return
+	}
+
+	if pending := pendingTakeoverLocked(hold, now); pending != nil && pending != client {
+		h.control.mu.Unlock()
+		client.SendJSON(map[string]interface{}{
+			"type":      "error",
+			"error":     "takeover_pending",
+			"holder":    holder.username,
+			"requester": pending.username,
+		})
+		return
+	}
+	hold.takeover = client
+	hold.takeoverAt = now
+	h.control.mu.Unlock()
+
+	log.Printf("🎮 %s requested control of room %s from %s", client.username, RoomID(client.Room()), holder.username)
+	holder.SendJSON(map[string]interface{}{
+		"type":          "takeover_requested",
+		"requester":     client.username,
+		"connection_id": client.connectionID,
+		"expires_in":    int(takeoverRequestTTL / time.Second),
+		"timestamp":     now.Unix(),
+	})
+	client.SendJSON(map[string]interface{}{
+		"type":       "takeover_pending",
+		"holder":     holder.username,
+		"expires_in": int(takeoverRequestTTL / time.Second),
+	})
+}
+
+// handleGrantTakeover hands the lock from its holder to the client that
+// requested it
+func (h *Hub) handleGrantTakeover(client *Client) {
+	now := time.Now()
+	h.control.mu.Lock()
+	hold := h.control.holds[client.Room()]
+	if hold == nil || hold.holder != client {
+		h.control.mu.Unlock()
+		return
+	}
+	requester := pendingTakeoverLocked(hold, now)
+	if requester == nil {
+		h.control.mu.Unlock()
+		client.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "no_takeover_request",
+			"message_type": "grant_takeover",
+		})
+		return
+	}
+	h.control.holds[client.Room()] = &controlHold{holder: requester, acquiredAt: now, lastActivity: now}
+	h.control.mu.Unlock()
+
+	h.completeTakeover(client, requester, "handed_off", false)
+}
+
+// handleDenyTakeover turns down the pending takeover request
+func (h *Hub) handleDenyTakeover(client *Client) {
+	h.control.mu.Lock()
+	hold := h.control.holds[client.Room()]
+	if hold == nil || hold.holder != client {
+		h.control.mu.Unlock()
+		return
+	}
+	requester := pendingTakeoverLocked(hold, time.Now())
+	hold.takeover = nil
+	h.control.mu.Unlock()
+
+	if requester != nil {
+		log.Printf("🎮 %s denied %s control of room %s", client.username, requester.username, RoomID(client.Room()))
+		requester.SendJSON(map[string]interface{}{
+			"type":   "takeover_denied",
+			"holder": client.username,
+		})
+	}
+}
+
+// withdrawTakeover drops takeover requests made by a client that left
+func (h *Hub) withdrawTakeover(client *Client) {
+	h.control.mu.Lock()
+	defer h.control.mu.Unlock()
+	for _, hold := range h.control.holds {
+		if hold.takeover == client {
+			hold.takeover = nil
+		}
+	}
+}
+
+// completeTakeover tells the previous holder and the room that the lock
+// changed hands, and records who took it from whom
+func (h *Hub) completeTakeover(previous, next *Client, reason string, forced bool) {
+	log.Printf("🎮 Control lock of room %s passed from %s to %s (%s)",
+		RoomID(next.Room()), previous.username, next.username, reason)
+	previous.SendJSON(map[string]interface{}{
+		"type":      "control_demoted",
+		"reason":    reason,
+		"by":        next.username,
+		"timestamp": time.Now().Unix(),
+	})
+	h.broadcastControlLock(next.Room(), h.ControlLock(next.Room()), reason)
+	h.auditEvent(next, AuditTakeover, map[string]interface{}{
+		"room":               RoomID(next.Room()),
+		"from":               previous.username,
+		"from_connection_id": previous.connectionID,
+		"forced":             forced,
+	})
+}
@@ -0,0 +1,104 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestTakeoverGrant tests handing the lock over at the holder's consent
+func TestTakeoverGrant(t *testing.T) {
+	hub := NewHub()
+	auditor := &recordingAuditor{events: make(chan auditEntry, 10)}
+	hub.SetAuditRecorder(auditor)
+	trainee := newTestClient(hub, ClientTypeWeb, "trainee")
+	supervisor := newTestClient(hub, ClientTypeWeb, "supervisor")
+	carol := newTestClient(hub, ClientTypeWeb, "carol")
+
+	hub.RouteMessage(trainee, []byte(`{"type":"request_control"}`))
+	drainMessages(trainee)
+	drainMessages(supervisor)
+	drainMessages(carol)
+
+	hub.RouteMessage(supervisor, []byte(`{"type":"request_takeover"}`))
+	if types := messageTypes(trainee); !reflect.DeepEqual(types, []string{"takeover_requested"}) {
+		t.Errorf("Expected the holder to be asked, got %v", types)
+	}
+	if types := messageTypes(supervisor); !reflect.DeepEqual(types, []string{"takeover_pending"}) {
+		t.Errorf("Expected takeover_pending, got %v", types)
+	}
+
+	// One request at a time
+	hub.RouteMessage(carol, []byte(`{"type":"request_takeover"}`))
+	if types := messageTypes(carol); !reflect.DeepEqual(types, []string{"error"}) {
+		t.Errorf("Expected a takeover_pending error, got %v", types)
+	}
+
+	hub.RouteMessage(trainee, []byte(`{"type":"grant_takeover"}`))
+	if holder := hub.ControlLock(DefaultRoom).Holder; holder != "supervisor" {
+		t.Fatalf("Expected supervisor to hold the lock, got %q", holder)
+	}
+	if types := messageTypes(trainee); !reflect.DeepEqual(types, []string{"control_demoted", "control_lock"}) {
+		t.Errorf("Expected the trainee to be demoted, got %v", types)
+	}
+	entry := auditor.next(t)
+	if entry.action != AuditTakeover || entry.actor != "supervisor" || entry.detail["from"] != "trainee" || entry.detail["forced"] != false {
+		t.Errorf("Unexpected audit event %+v", entry)
+	}
+
+	// Nothing left to grant
+	hub.RouteMessage(supervisor, []byte(`{"type":"grant_takeover"}`))
+	if types := messageTypes(supervisor); !reflect.DeepEqual(types, []string{"control_lock", "error"}) {
+		t.Errorf("Expected no_takeover_request, got %v", types)
+	}
+}
+
+// TestTakeoverDeny tests that the holder can turn a request down
+func TestTakeoverDeny(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient(hub, ClientTypeWeb, "alice")
+	bob := newTestClient(hub, ClientTypeWeb, "bob")
+
+	hub.RouteMessage(alice, []byte(`{"type":"request_control"}`))
+	hub.RouteMessage(bob, []byte(`{"type":"request_takeover"}`))
+	drainMessages(bob)
+	hub.RouteMessage(alice, []byte(`{"type":"deny_takeover"}`))
+
+	if types := messageTypes(bob); !reflect.DeepEqual(types, []string{"takeover_denied"}) {
+		t.Errorf("Expected takeover_denied, got %v", types)
+	}
+	if hub.ControlLock(DefaultRoom).Holder != "alice" {
+		t.Error("Expected alice to keep the lock")
+	}
+}
+
+// TestTakeoverForce tests the admin override
+func TestTakeoverForce(t *testing.T) {
+	hub := NewHub()
+	auditor := &recordingAuditor{events: make(chan auditEntry, 10)}
+	hub.SetAuditRecorder(auditor)
+	trainee := newTestClient(hub, ClientTypeWeb, "trainee")
+	operator := newTestClient(hub, ClientTypeWeb, "operator")
+	operator.scopes = []string{ScopeControl}
+	admin := newTestClient(hub, ClientTypeWeb, "admin")
+	admin.scopes = []string{ScopeControl, ScopeAdmin}
+
+	hub.RouteMessage(trainee, []byte(`{"type":"request_control"}`))
+	drainMessages(trainee)
+	drainMessages(operator)
+
+	hub.RouteMessage(operator, []byte(`{"type":"request_takeover","force":true}`))
+	if types := messageTypes(operator); !reflect.DeepEqual(types, []string{"error"}) || hub.ControlLock(DefaultRoom).Holder != "trainee" {
+		t.Errorf("Expected a forced takeover without admin scope to be refused, got %v", types)
+	}
+
+	hub.RouteMessage(admin, []byte(`{"type":"request_takeover","force":true}`))
+	if holder := hub.ControlLock(DefaultRoom).Holder; holder != "admin" {
+		t.Fatalf("Expected admin to seize the lock, got %q", holder)
+	}
+	if types := messageTypes(trainee); !reflect.DeepEqual(types, []string{"control_demoted", "control_lock"}) {
+		t.Errorf("Expected the trainee to be demoted, got %v", types)
+	}
+	if entry := auditor.next(t); entry.action != AuditTakeover || entry.detail["forced"] != true {
+		t.Errorf("Unexpected audit event %+v", entry)
+	}
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"errors"
+	"oculo-pilot-server/logging"
+)
+
+// ErrConnectionNotFound is returned by SendToConnection when no client has
+// the connection ID
+var ErrConnectionNotFound = errors.New("connection not found")
+
+// ErrSendBufferFull is returned by SendToConnection when the client's send
+// buffer is full; the client is dropped
+var ErrSendBufferFull = errors.New("send buffer full")
+
+// routeTarget narrows where a routed message goes: to the clients serving
+// one robot, one connection, or both. The zero value addresses everyone.
+type routeTarget struct {
+	robotID      string
+	connectionID string
+}
+
+// matches reports whether client is addressed by the target
+func (t routeTarget) matches(client *Client) bool {
+	if t.connectionID != "" && client.connectionID != t.connectionID {
+		return false
+	}
+	return client.servesRobot(t.robotID)
+}
+
+// messageTarget returns the target of a message from sender
+func messageTarget(sender *Client, msg *Message) routeTarget {
+	return routeTarget{robotID: messageRobot(sender, msg), connectionID: msg.TargetConnectionID}
+}
+
+// checkTarget rejects a message addressed to a connection that is not in
+// the sender's room, so a typo does not fail silently. Emergency stops
+// ignore the target and are never rejected.
+func (h *Hub) checkTarget(sender *Client, msgType string, target routeTarget) bool {
+	if target.connectionID == "" || isEmergencyStop(msgType) {
+		return true
+	}
+	if client := h.clientByConnection(target.connectionID); client != nil && client.Room() == sender.Room() {
+		return true
+	}
+
+	logging.Sampled("ws_target_not_found", "🎯 %s from %s addressed to unknown connection %s",
+		msgType, sender.username, target.connectionID)
+	sender.SendJSON(map[string]interface{}{
+		"type":                 "error",
+		"error":                "target_not_found",
+		"message_type":         msgType,
+		"target_connection_id": target.connectionID,
+	})
+	return false
+}
+
+// SendToConnection sends a message to the client with a connection ID,
+// whatever its room or type
+func (h *Hub) SendToConnection(connectionID string, message []byte) error {
+	client := h.clientByConnection(connectionID)
+	if client == nil {
+		return ErrConnectionNotFound
+	}
+	if !h.deliver(client, outbound{data: message}) {
+		return ErrSendBufferFull
+	}
+	return nil
+}
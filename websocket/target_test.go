@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestTargetConnectionID tests that a message with target_connection_id
+// reaches only that connection, and that unknown targets are reported
+func TestTargetConnectionID(t *testing.T) {
+	hub := NewHub()
+	operator := newTestClient(hub, ClientTypeWeb, "alice")
+	operator.SetConnectionID("conn_web")
+	front := newTestClient(hub, ClientTypeVideo, "camera")
+	front.SetConnectionID("conn_front")
+	rear := newTestClient(hub, ClientTypeVideo, "camera")
+	rear.SetConnectionID("conn_rear")
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	robot.SetConnectionID("conn_robot")
+	elsewhere := newTestClient(hub, ClientTypeVideo, "camera")
+	elsewhere.SetConnectionID("conn_lab")
+	elsewhere.place("lab", "")
+
+	hub.RouteMessage(operator, []byte(`{"type":"offer","sdp":"v=0","target_connection_id":"conn_rear"}`))
+	if len(drainMessages(rear)) != 1 || len(drainMessages(front)) != 0 {
+		t.Error("Expected the offer to reach only the rear camera")
+	}
+	drainMessages(operator)
+
+	// The target must still be a destination of the message type
+	hub.RouteMessage(operator, []byte(`{"type":"control_command","id":"cmd-1","target_connection_id":"conn_rear","data":{}}`))
+	if len(drainMessages(rear)) != 0 || len(drainMessages(robot)) != 0 {
+		t.Error("Expected a control_command targeted at a video client to go nowhere")
+	}
+	if types := messageTypes(operator); len(types) != 1 || types[0] != "delivery_failed" {
+		t.Errorf("Expected delivery_failed for the undeliverable command, got %v", types)
+	}
+
+	for _, target := range []string{"conn_missing", "conn_lab"} {
+		hub.RouteMessage(operator, []byte(`{"type":"offer","sdp":"v=0","target_connection_id":"`+target+`"}`))
+		messages := drainMessages(operator)
+		if len(messages) != 1 {
+			t.Fatalf("Expected an error for target %s, got %d messages", target, len(messages))
+		}
+		var reply map[string]interface{}
+		json.Unmarshal(messages[0], &reply)
+		if reply["error"] != "target_not_found" || reply["target_connection_id"] != target {
+			t.Errorf("Expected target_not_found for %s, got %v", target, reply)
+		}
+		if len(drainMessages(elsewhere)) != 0 {
+			t.Error("Expected no delivery across rooms")
+		}
+	}
+
+	// Emergency stops ignore the target
+	hub.RouteMessage(operator, []byte(`{"type":"emergency_stop","target_connection_id":"conn_missing"}`))
+	if len(drainMessages(robot)) != 1 {
+		t.Error("Expected emergency stop to be delivered despite an unknown target")
+	}
+}
+
+// TestSendToConnection tests sending to one connection from server code
+func TestSendToConnection(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub, ClientTypeControl, "robot")
+	client.SetConnectionID("conn_1")
+	other := newTestClient(hub, ClientTypeControl, "robot")
+	other.SetConnectionID("conn_2")
+
+	if err := hub.SendToConnection("conn_1", []byte(`{"type":"notice"}`)); err != nil {
+		t.Fatalf("SendToConnection failed: %v", err)
+	}
+	if len(drainMessages(client)) != 1 || len(drainMessages(other)) != 0 {
+		t.Error("Expected only conn_1 to receive the message")
+	}
+	if err := hub.SendToConnection("conn_3", []byte(`{}`)); err != ErrConnectionNotFound {
+		t.Errorf("Expected ErrConnectionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TelemetrySample is one location_update or route_update routed through
+// the hub
+type TelemetrySample struct {
+	Time    time.Time
+	RobotID string // The robot the telemetry is about, or the sender's username
+	Room    string
+	Type    string
+	Data    json.RawMessage
+}
+
+// TelemetrySink persists routed telemetry. RecordTelemetry must not block;
+// it is called on the routing path. Data must not be modified.
+type TelemetrySink interface {
+	RecordTelemetry(sample TelemetrySample)
+}
+
+// SetTelemetrySink persists location_update and route_update payloads
+// (nil disables). Call before Run.
+func (h *Hub) SetTelemetrySink(sink TelemetrySink) {
+	h.telemetry = sink
+}
+
+// persistTelemetry passes a telemetry message from sender to the sink
+func (h *Hub) persistTelemetry(sender *Client, msg *Message, target routeTarget) {
+	if h.telemetry == nil {
+		return
+	}
+	h.telemetry.RecordTelemetry(TelemetrySample{
+		Time:    time.Now(),
+		RobotID: telemetryRobot(sender, target),
+		Room:    RoomID(sender.Room()),
+		Type:    msg.Type,
+		Data:    msg.Data,
+	})
+}
+
+// telemetryRobot returns the robot telemetry is about: the one it names or
+// its sender registered for, otherwise the sender's username
+func telemetryRobot(sender *Client, target routeTarget) string {
+	if target.robotID != "" {
+		return target.robotID
+	}
+	return sender.username
+}
@@ -0,0 +1,45 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+)
+
+// capturingSink keeps every telemetry sample
+type capturingSink struct {
+	mu      sync.Mutex
+	samples []TelemetrySample
+}
+
+func (s *capturingSink) RecordTelemetry(sample TelemetrySample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, sample)
+}
+
+// TestPersistTelemetry tests that routed telemetry reaches the sink keyed
+// by robot, and that other messages do not
+func TestPersistTelemetry(t *testing.T) {
+	hub := NewHub()
+	sink := &capturingSink{}
+	hub.SetTelemetrySink(sink)
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	rover := newTestClient(hub, ClientTypeControl, "robot")
+	rover.place("", "rover-1")
+
+	hub.RouteMessage(robot, []byte(`{"type":"location_update","data":{"lat":1}}`))
+	hub.RouteMessage(rover, []byte(`{"type":"route_update","data":{"points":[]}}`))
+	hub.RouteMessage(robot, []byte(`{"type":"control_response","data":{}}`))
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.samples) != 2 {
+		t.Fatalf("Expected 2 telemetry samples, got %d", len(sink.samples))
+	}
+	if sample := sink.samples[0]; sample.RobotID != "robot" || sample.Type != "location_update" || sample.Room != "default" || string(sample.Data) != `{"lat":1}` {
+		t.Errorf("Unexpected sample from an unregistered robot: %+v", sample)
+	}
+	if sample := sink.samples[1]; sample.RobotID != "rover-1" || sample.Type != "route_update" {
+		t.Errorf("Expected the registered robot ID, got %+v", sample)
+	}
+}
@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// maxRoutedTypes bounds the per-type counters; clients can send any type,
+// so further types are counted as "other"
+const maxRoutedTypes = 64
+
+// fanoutBuckets are the upper bounds of the broadcast fan-out histogram;
+// larger broadcasts fall in the last, open bucket
+var fanoutBuckets = [...]struct {
+	max   int
+	label string
+}{
+	{0, "0"},
+	{1, "1"},
+	{5, "2-5"},
+	{20, "6-20"},
+	{100, "21-100"},
+}
+
+// throughputStats counts traffic through the hub for capacity monitoring
+type throughputStats struct {
+	// Frames read from and written to clients and their payload sizes
+	messagesIn  atomic.Int64
+	bytesIn     atomic.Int64
+	messagesOut atomic.Int64
+	bytesOut    atomic.Int64
+
+	// Messages discarded because they could not be decoded or failed their
+	// schema
+	invalid atomic.Int64
+
+	// Routed messages by type, and broadcast fan-out (protected by mu)
+	mu          sync.Mutex
+	routed      map[string]int64
+	broadcasts  int64
+	recipients  int64
+	maxFanout   int
+	fanoutCount [len(fanoutBuckets) + 1]int64 // Last counts larger broadcasts
+}
+
+// countIn records a frame read from a client
+func (t *throughputStats) countIn(size int) {
+	t.messagesIn.Add(1)
+	t.bytesIn.Add(int64(size))
+}
+
+// countOut records a message written to a client
+func (t *throughputStats) countOut(size int) {
+	t.messagesOut.Add(1)
+	t.bytesOut.Add(int64(size))
+}
+
+// countRouted records a message accepted for routing
+func (t *throughputStats) countRouted(msgType string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.routed == nil {
+		t.routed = make(map[string]int64)
+	}
+	if _, ok := t.routed[msgType]; !ok && len(t.routed) >= maxRoutedTypes {
+		msgType = "other"
+	}
+	t.routed[msgType]++
+}
+
+// countFanout records how many clients a broadcast was delivered to
+func (t *throughputStats) countFanout(delivered int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.broadcasts++
+	t.recipients += int64(delivered)
+	if delivered > t.maxFanout {
+		t.maxFanout = delivered
+	}
+	bucket := len(fanoutBuckets)
+	for i, b := range fanoutBuckets {
+		if delivered <= b.max {
+			bucket = i
+			break
+		}
+	}
+	t.fanoutCount[bucket]++
+}
+
+// throughputSummary summarizes hub traffic for GetStats, including the drop
+// counters kept elsewhere
+func (h *Hub) throughputSummary() map[string]interface{} {
+	t := &h.throughput
+	t.mu.Lock()
+	routed := make(map[string]int64, len(t.routed))
+	for msgType, count := range t.routed {
+		routed[msgType] = count
+	}
+	histogram := make(map[string]int64, len(fanoutBuckets)+1)
+	for i, b := range fanoutBuckets {
+		histogram[b.label] = t.fanoutCount[i]
+	}
+	histogram[">100"] = t.fanoutCount[len(fanoutBuckets)]
+	fanout := map[string]interface{}{
+		"broadcasts": t.broadcasts,
+		"recipients": t.recipients,
+		"max":        t.maxFanout,
+		"histogram":  histogram,
+	}
+	t.mu.Unlock()
+
+	return map[string]interface{}{
+		"messages_in":  t.messagesIn.Load(),
+		"bytes_in":     t.bytesIn.Load(),
+		"messages_out": t.messagesOut.Load(),
+		"bytes_out":    t.bytesOut.Load(),
+		"routed":       routed,
+		"dropped": map[string]int64{
+			"invalid":         t.invalid.Load(),
+			"rate_limited":    h.rateLimited.Load(),
+			"latency_budget":  h.budgetExceeded.Load(),
+			"send_queue_full": h.sendDropped.Load(),
+			"duplicate":       h.dedup.suppressed.Load(),
+		},
+		"fanout": fanout,
+	}
+}
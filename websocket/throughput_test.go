@@ -0,0 +1,48 @@
+package websocket
+
+import "testing"
+
+// TestThroughputStats tests that routed types, invalid messages and
+// broadcast fan-out show up in the hub stats
+func TestThroughputStats(t *testing.T) {
+	hub := NewHub()
+	robot := newTestClient(hub, ClientTypeTelemetry, "robot")
+	newTestClient(hub, ClientTypeWeb, "alice")
+	newTestClient(hub, ClientTypeWeb, "bob")
+
+	hub.RouteMessage(robot, []byte(`{"type":"location_update","data":{"lat":1}}`))
+	hub.RouteMessage(robot, []byte(`{"type":"location_update","data":{"lat":2}}`))
+	hub.RouteMessage(robot, []byte(`not json`))
+
+	stats := hub.GetStats()["throughput"].(map[string]interface{})
+	if routed := stats["routed"].(map[string]int64); routed["location_update"] != 2 {
+		t.Errorf("Expected 2 routed location updates, got %v", routed)
+	}
+	if dropped := stats["dropped"].(map[string]int64); dropped["invalid"] != 1 {
+		t.Errorf("Expected 1 invalid message, got %v", dropped)
+	}
+	fanout := stats["fanout"].(map[string]interface{})
+	if fanout["broadcasts"] != int64(2) || fanout["recipients"] != int64(4) || fanout["max"] != 2 {
+		t.Errorf("Expected 2 broadcasts to 2 web clients, got %v", fanout)
+	}
+	if histogram := fanout["histogram"].(map[string]int64); histogram["2-5"] != 2 {
+		t.Errorf("Expected both broadcasts in the 2-5 bucket, got %v", histogram)
+	}
+}
+
+// TestRoutedTypesBounded tests that unknown types beyond the limit are
+// counted together
+func TestRoutedTypesBounded(t *testing.T) {
+	var stats throughputStats
+	for i := 0; i < maxRoutedTypes+10; i++ {
+		stats.countRouted(string(rune('A'+i%26)) + string(rune('a'+i/26)))
+	}
+	stats.countRouted("Aa")
+
+	if len(stats.routed) != maxRoutedTypes+1 {
+		t.Errorf("Expected %d types plus other, got %d", maxRoutedTypes, len(stats.routed))
+	}
+	if stats.routed["other"] != 10 || stats.routed["Aa"] != 2 {
+		t.Errorf("Expected 10 other and known types counted, got other=%d Aa=%d", stats.routed["other"], stats.routed["Aa"])
+	}
+}
@@ -0,0 +1,21 @@
+package websocket
+
+import (
+	"errors"
+)
+
+var (
+	// errMissingCredentials is returned when an upgrade request carries
+	// neither a ticket nor a token
+	errMissingCredentials = errors.New("missing credentials")
+
+	// errTicketsUnsupported is returned for tickets when the validator
+	// cannot redeem them
+	errTicketsUnsupported = errors.New("tickets not supported")
+)
+
+// TicketRedeemer is implemented by validators that accept one-time tickets
+// (?ticket=...) in place of a token. Redeeming consumes the ticket.
+type TicketRedeemer interface {
+	RedeemTicket(ticket string) (userID int64, username string, scopes []string, binding TokenBinding, err error)
+}
@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// ticketValidator redeems a single ticket and accepts no tokens
+type ticketValidator struct {
+	ticket string
+}
+
+func (v *ticketValidator) ValidateToken(token string) (int64, string, error) {
+	return 0, "", errors.New("invalid token")
+}
+
+func (v *ticketValidator) RedeemTicket(ticket string) (int64, string, []string, TokenBinding, error) {
+	if ticket == "" || ticket != v.ticket {
+		return 0, "", nil, TokenBinding{}, errors.New("invalid ticket")
+	}
+	v.ticket = ""
+	return 7, "pilot", []string{ScopeView}, TokenBinding{}, nil
+}
+
+// TestAuthenticate tests that ?ticket= is redeemed instead of a token
+// and that the session cookie is accepted once enabled
+func TestAuthenticate(t *testing.T) {
+	validator := &ticketValidator{ticket: "wst_abc"}
+	handler := NewHandler(NewHub(), validator, nil, false, time.Second, 4096)
+
+	userID, username, scopes, _, err := handler.authenticate(httptest.NewRequest("GET", "/ws?ticket=wst_abc", nil))
+	if err != nil || userID != 7 || username != "pilot" || len(scopes) != 1 {
+		t.Fatalf("Expected ticket to authenticate pilot, got %d %s %v (%v)", userID, username, scopes, err)
+	}
+	if _, _, _, _, err := handler.authenticate(httptest.NewRequest("GET", "/ws?ticket=wst_abc", nil)); err == nil {
+		t.Error("Expected a redeemed ticket to be rejected")
+	}
+	if _, _, _, _, err := handler.authenticate(httptest.NewRequest("GET", "/ws", nil)); err != errMissingCredentials {
+		t.Errorf("Expected errMissingCredentials, got %v", err)
+	}
+
+	plain := NewHandler(NewHub(), &mockAuthValidator{}, nil, false, time.Second, 4096)
+
+	// The session cookie is only read when enabled
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.AddCookie(&http.Cookie{Name: "oculo_session", Value: "cookie-token"})
+	if _, _, _, _, err := plain.authenticate(req); err != errMissingCredentials {
+		t.Errorf("Expected cookie to be ignored by default, got %v", err)
+	}
+	plain.SetSessionCookie("oculo_session")
+	if _, username, _, _, err := plain.authenticate(req); err != nil || username != "testuser" {
+		t.Errorf("Expected cookie to authenticate, got %q (%v)", username, err)
+	}
+
+	if _, _, _, _, err := plain.authenticate(httptest.NewRequest("GET", "/ws?ticket=wst_abc", nil)); err != errTicketsUnsupported {
+		t.Errorf("Expected errTicketsUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Topic limits
+const (
+	// maxTopicSubscriptions bounds the patterns one client may subscribe to
+	maxTopicSubscriptions = 32
+
+	// maxTopicSegments bounds the dot-separated segments of a topic
+	maxTopicSegments = 8
+)
+
+// topicSegmentRegex matches one segment of a topic name: 1-64
+// characters, alphanumeric, dash and underscore
+var topicSegmentRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// messageTopics are the topics routed message types are published on, so
+// clients outside the types they are routed to can subscribe to them
+var messageTopics = map[string]string{
+	"location_update":    "telemetry.gps",
+	"route_update":       "telemetry.route",
+	"control_response":   "control.response",
+	"video_client_ready": "video.status",
+	"webrtc_connected":   "video.status",
+	"audio_client_ready": "audio.status",
+}
+
+// topicSenders are the client types that may subscribe and publish
+var topicSenders = []ClientType{
+	ClientTypeWeb, ClientTypeVideo, ClientTypeControl, ClientTypeTelemetry, ClientTypeAudio,
+}
+
+// topicRequest is a subscribe, unsubscribe or publish message
+type topicRequest struct {
+	Topics []string `json:"topics"`
+	Topic  string   `json:"topic"`
+}
+
+// validTopic reports whether name is a topic, or with pattern a topic
+// pattern in which * matches one segment and a final > matches the rest
+func validTopic(name string, pattern bool) bool {
+	segments := strings.Split(name, ".")
+	if len(segments) > maxTopicSegments {
+		return false
+	}
+	for i, segment := range segments {
+		switch {
+		case pattern && segment == "*":
+		case pattern && segment == ">" && i == len(segments)-1:
+		case !topicSegmentRegex.MatchString(segment):
+			return false
+		}
+	}
+	return true
+}
+
+// topicMatches reports whether topic matches pattern
+func topicMatches(pattern, topic string) bool {
+	patternSegments, segments := strings.Split(pattern, "."), strings.Split(topic, ".")
+	for i, segment := range patternSegments {
+		if segment == ">" {
+			return len(segments) > i
+		}
+		if i >= len(segments) || (segment != "*" && segment != segments[i]) {
+			return false
+		}
+	}
+	return len(segments) == len(patternSegments)
+}
+
+// Topics returns the topic patterns the client subscribed to
+func (c *Client) Topics() []string {
+	if topics := c.topics.Load(); topics != nil {
+		return *topics
+	}
+	return nil
+}
+
+// subscribedTo reports whether one of the client's patterns matches topic
+func (c *Client) subscribedTo(topic string) bool {
+	for _, pattern := range c.Topics() {
+		if topicMatches(pattern, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleSubscribe adds or removes topic patterns and replies with the
+// client's subscriptions
+func (h *Hub) handleSubscribe(sender *Client, msgType string, rawMessage []byte) {
+	var request topicRequest
+	if err := json.Unmarshal(rawMessage, &request); err != nil {
+		return
+	}
+	for _, pattern := range request.Topics {
+		if !validTopic(pattern, true) {
+			sender.SendJSON(map[string]interface{}{
+				"type":         "error",
+				"error":        "invalid_topic",
+				"message_type": msgType,
+				"topic":        pattern,
+			})
+			return
+		}
+	}
+
+	var topics []string
+	for _, pattern := range sender.Topics() {
+		if msgType == "subscribe" || !containsTopic(request.Topics, pattern) {
+			topics = append(topics, pattern)
+		}
+	}
+	if msgType == "subscribe" {
+		for _, pattern := range request.Topics {
+			if !containsTopic(topics, pattern) {
+				topics = append(topics, pattern)
+			}
+		}
+		if len(topics) > maxTopicSubscriptions {
+			sender.SendJSON(map[string]interface{}{
+				"type":         "error",
+				"error":        "too_many_topics",
+				"message_type": msgType,
+				"max":          maxTopicSubscriptions,
+			})
+			return
+		}
+	}
+	sender.topics.Store(&topics)
+
+	if topics == nil {
+		topics = []string{}
+	}
+	sender.SendJSON(map[string]interface{}{
+		"type":   "subscriptions",
+		"topics": topics,
+	})
+}
+
+// containsTopic reports whether topic is listed
+func containsTopic(topics []string, topic string) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePublish sends a message to the subscribers of its topic in the
+// sender's room
+func (h *Hub) handlePublish(ctx *MessageContext) {
+	var request topicRequest
+	if err := json.Unmarshal(ctx.Raw, &request); err != nil {
+		ctx.Reject()
+		return
+	}
+	if !validTopic(request.Topic, false) {
+		ctx.Sender.SendJSON(map[string]interface{}{
+			"type":         "error",
+			"error":        "invalid_topic",
+			"message_type": ctx.Message.Type,
+			"topic":        request.Topic,
+		})
+		ctx.Reject()
+		return
+	}
+	delivered := h.publishTopic(ctx.Sender, request.Topic, ctx.target, nil, h.withTTL(ctx.Message.Type, ctx.Raw))
+	log.Printf("Published %s to %d subscribers", request.Topic, delivered)
+}
+
+// publishTopic sends a message to the subscribers of topic in the sender's
+// room, on this and other instances, skipping the sender and clients of
+// types in skip, and returns how many local clients received it
+func (h *Hub) publishTopic(sender *Client, topic string, target routeTarget, skip []ClientType, message outbound) int {
+	delivered := h.deliverTopic(sender.Room(), topic, target, skip, sender, message)
+	if target.connectionID == "" {
+		h.publishRelay(relayEnvelope{
+			Kind:     relayTopic,
+			Room:     sender.Room(),
+			RobotID:  target.robotID,
+			Topic:    topic,
+			Skip:     skip,
+			Deadline: unixMilli(message.deadline),
+			Data:     message.data,
+		})
+	}
+	return delivered
+}
+
+// deliverTopic sends a message to the local subscribers of topic in room
+// matching target, except sender and clients of types in skip
+func (h *Hub) deliverTopic(room, topic string, target routeTarget, skip []ClientType, sender *Client, message outbound) int {
+	h.mu.RLock()
+	var recipients []*Client
+	for _, clientType := range topicSenders {
+		if containsType(skip, clientType) {
+			continue
+		}
+		for client := range h.clients[clientType] {
+			if client != sender && (room == AllRooms || client.Room() == room) &&
+				target.matches(client) && client.subscribedTo(topic) {
+				recipients = append(recipients, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(recipients) == 0 {
+		return 0
+	}
+
+	delivered := 0
+	for _, client := range recipients {
+		if h.deliver(client, message) {
+			delivered++
+		}
+	}
+	h.throughput.countFanout(delivered)
+	return delivered
+}
@@ -0,0 +1,98 @@
+package websocket
+
+import "testing"
+
+// TestTopicMatches tests topic names, patterns and wildcard matching
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"telemetry.gps", "telemetry.gps", true},
+		{"telemetry.gps", "telemetry.route", false},
+		{"alerts.*", "alerts.battery", true},
+		{"alerts.*", "alerts.battery.low", false},
+		{"alerts.*", "alerts", false},
+		{"alerts.>", "alerts.battery.low", true},
+		{"alerts.>", "alerts", false},
+		{"*.status", "video.status", true},
+	}
+	for _, tt := range tests {
+		if got := topicMatches(tt.pattern, tt.topic); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.pattern, tt.topic, got, tt.want)
+		}
+	}
+
+	for _, valid := range []string{"alerts.*", "alerts.>", "*", "video.status"} {
+		if !validTopic(valid, true) {
+			t.Errorf("Expected pattern %q to be valid", valid)
+		}
+	}
+	for _, invalid := range []string{"", "alerts.", "alerts.>.low", "a b", "a.b.c.d.e.f.g.h.i"} {
+		if validTopic(invalid, true) {
+			t.Errorf("Expected pattern %q to be invalid", invalid)
+		}
+	}
+	if validTopic("alerts.*", false) {
+		t.Error("Expected wildcards to be rejected in topic names")
+	}
+}
+
+// TestTopicSubscriptions tests subscribing, publishing to a topic and
+// receiving routed message types by topic
+func TestTopicSubscriptions(t *testing.T) {
+	hub := NewHub()
+	robot := newTestClient(hub, ClientTypeControl, "robot")
+	video := newTestClient(hub, ClientTypeVideo, "camera")
+	viewer := newTestClient(hub, ClientTypeWeb, "viewer")
+	outsider := newTestClient(hub, ClientTypeControl, "outsider")
+	outsider.place("other", "")
+
+	hub.RouteMessage(robot, []byte(`{"type":"subscribe","topics":["alerts.*","telemetry.gps"]}`))
+	hub.RouteMessage(outsider, []byte(`{"type":"subscribe","topics":["alerts.*"]}`))
+	hub.RouteMessage(viewer, []byte(`{"type":"subscribe","topics":["telemetry.>"]}`))
+	if types := messageTypes(robot); len(types) != 1 || types[0] != "subscriptions" {
+		t.Errorf("Expected the subscriptions, got %v", types)
+	}
+	drainMessages(outsider)
+	drainMessages(viewer)
+
+	hub.RouteMessage(video, []byte(`{"type":"publish","topic":"alerts.battery","data":{"level":5}}`))
+	if types := messageTypes(robot); len(types) != 1 || types[0] != "publish" {
+		t.Errorf("Expected the topic message, got %v", types)
+	}
+	if types := messageTypes(outsider); len(types) != 0 {
+		t.Errorf("Expected other rooms to receive nothing, got %v", types)
+	}
+	if types := messageTypes(viewer); len(types) != 0 {
+		t.Errorf("Expected non-subscribers to receive nothing, got %v", types)
+	}
+
+	// Telemetry reaches a subscribed control client, and web clients once
+	hub.RouteMessage(video, []byte(`{"type":"location_update","data":{"lat":1}}`))
+	if types := messageTypes(robot); len(types) != 1 || types[0] != "location_update" {
+		t.Errorf("Expected telemetry by topic, got %v", types)
+	}
+	if types := messageTypes(viewer); len(types) != 1 {
+		t.Errorf("Expected one copy for a subscribed web client, got %v", types)
+	}
+
+	hub.RouteMessage(robot, []byte(`{"type":"unsubscribe","topics":["alerts.*"]}`))
+	if topics := robot.Topics(); len(topics) != 1 || topics[0] != "telemetry.gps" {
+		t.Errorf("Expected one subscription left, got %v", topics)
+	}
+	drainMessages(robot)
+	hub.RouteMessage(video, []byte(`{"type":"publish","topic":"alerts.battery"}`))
+	if types := messageTypes(robot); len(types) != 0 {
+		t.Errorf("Expected no message after unsubscribing, got %v", types)
+	}
+
+	hub.RouteMessage(robot, []byte(`{"type":"subscribe","topics":["alerts..x"]}`))
+	if types := messageTypes(robot); len(types) != 1 || types[0] != "error" {
+		t.Errorf("Expected an invalid pattern to be rejected, got %v", types)
+	}
+	hub.RouteMessage(video, []byte(`{"type":"publish","topic":"alerts.*"}`))
+	if types := messageTypes(video); len(types) != 1 || types[0] != "error" {
+		t.Errorf("Expected publishing to a pattern to be rejected, got %v", types)
+	}
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrClientLimit is returned by changeClientType when the client's new
+// type is at its connection limit (see limits.go)
+var ErrClientLimit = errors.New("client type limit reached")
+
+// typeChange asks the hub loop to move a pending client to its handshake
+// type, room and robot
+type typeChange struct {
+	client  *Client
+	to      ClientType
+	room    string
+	robotID string
+
+	// A resumed client takes its previous connection's place, so the
+	// type's limit does not apply
+	resumed bool
+
+	result chan error
+}
+
+// Type returns the client's type (ClientTypePending until its handshake)
+func (c *Client) Type() ClientType {
+	clientType, _ := c.clientType.Load().(ClientType)
+	return clientType
+}
+
+// changeClientType moves a pending client to its handshake type through
+// the hub loop, so transitions are serialized with registration and
+// removal. It waits for the result; a hub that does not apply it within
+// the channel timeout fails with ErrHubStalled.
+func (h *Hub) changeClientType(client *Client, handshake *HandshakeResponse, resumed bool) error {
+	change := typeChange{
+		client:  client,
+		to:      handshake.ClientType,
+		room:    roomFromID(handshake.Room),
+		robotID: handshake.RobotID,
+		resumed: resumed,
+		result:  make(chan error, 1),
+	}
+
+	timer := time.NewTimer(h.channelTimeout)
+	defer timer.Stop()
+	select {
+	case h.typeChanges <- change:
+	case <-timer.C:
+		log.Printf("🚨 Hub type change channel saturated, handshake of %s not applied", client.username)
+		return ErrHubStalled
+	}
+
+	// The hub loop may stall after taking the request; a late result is
+	// dropped into the buffered channel
+	select {
+	case err := <-change.result:
+		return err
+	case <-timer.C:
+		log.Printf("🚨 Hub did not apply the handshake of %s in time", client.username)
+		return ErrHubStalled
+	}
+}
+
+// applyTypeChange moves a client between the type maps. It runs in the hub
+// loop; a client that is no longer pending is left alone.
+func (h *Hub) applyTypeChange(change typeChange) error {
+	client := change.client
+
+	h.mu.Lock()
+	oldType := client.Type()
+	if oldType != ClientTypePending {
+		h.mu.Unlock()
+		return nil
+	}
+	if !change.resumed && !h.admitClientType(client, change.to) {
+		h.mu.Unlock()
+		return ErrClientLimit
+	}
+
+	client.place(change.room, change.robotID)
+	client.clientType.Store(change.to)
+	if clients, ok := h.clients[oldType]; ok {
+		if _, exists := clients[client]; exists {
+			// Client is already in hub, move it to new type
+			delete(clients, client)
+			if h.clients[change.to] == nil {
+				h.clients[change.to] = make(map[*Client]bool)
+			}
+			h.clients[change.to][client] = true
+			log.Printf("🔄 Moved client from %s to %s", oldType, change.to)
+		}
+	}
+	h.mu.Unlock()
+
+	client.updateCompression()
+	return nil
+}
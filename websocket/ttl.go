@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"oculo-pilot-server/logging"
+	"time"
+)
+
+// messageTTL is the optional time-to-live of a forwarded message
+type messageTTL struct {
+	// TTLMs bounds how long the message may wait in a recipient's send
+	// queue; a slow client skips it rather than replaying a backlog
+	TTLMs int64 `json:"ttl_ms"`
+}
+
+// SetMessageTTLs gives forwarded messages of each type a default
+// time-to-live, e.g. location_update 2s, used when a message carries no
+// ttl_ms of its own. Call before Run.
+func (h *Hub) SetMessageTTLs(ttls map[string]time.Duration) {
+	h.messageTTLs = ttls
+}
+
+// MessageTTLs returns the configured default TTLs
+func (h *Hub) MessageTTLs() map[string]time.Duration {
+	ttls := make(map[string]time.Duration, len(h.messageTTLs))
+	for msgType, ttl := range h.messageTTLs {
+		if ttl > 0 {
+			ttls[msgType] = ttl
+		}
+	}
+	return ttls
+}
+
+// withTTL queues a forwarded message with its time-to-live, if it has one.
+// Recipients' write pumps drop it once the TTL passes, so viewers see
+// current positions instead of a stale backlog.
+func (h *Hub) withTTL(msgType string, rawMessage []byte) outbound {
+	message := outbound{data: rawMessage}
+
+	// Only messages mentioning the field are parsed for it
+	ttl := h.messageTTLs[msgType]
+	if bytes.Contains(rawMessage, []byte(`"ttl_ms"`)) {
+		var field messageTTL
+		if err := json.Unmarshal(rawMessage, &field); err == nil && field.TTLMs > 0 {
+			ttl = time.Duration(field.TTLMs) * time.Millisecond
+		}
+	}
+	if ttl <= 0 {
+		return message
+	}
+
+	message.deadline = time.Now().Add(ttl)
+	message.expired = func(late time.Duration) {
+		h.ttlExpired.Add(1)
+		logging.Sampled("ws_ttl_expired", "⌛ Stale %s dropped %v after its %v TTL", msgType, late.Round(time.Millisecond), ttl)
+	}
+	return message
+}
+
+// unixMilli returns a deadline in Unix milliseconds (0 for none)
+func unixMilli(deadline time.Time) int64 {
+	if deadline.IsZero() {
+		return 0
+	}
+	return deadline.UnixMilli()
+}
@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMessageTTL tests that forwarded telemetry carries its TTL to the
+// recipients' queues and is dropped once stale
+func TestMessageTTL(t *testing.T) {
+	hub := NewHub()
+	hub.SetMessageTTLs(map[string]time.Duration{"location_update": 2 * time.Second})
+	robot := newTestClient(hub, ClientTypeTelemetry, "robot")
+	viewer := newTestClient(hub, ClientTypeWeb, "alice")
+
+	next := func() outbound {
+		t.Helper()
+		select {
+		case message := <-viewer.send:
+			return message
+		default:
+			t.Fatal("Expected a forwarded message")
+			return outbound{}
+		}
+	}
+
+	start := time.Now()
+	hub.RouteMessage(robot, []byte(`{"type":"location_update","data":{"lat":37.5}}`))
+	message := next()
+	if ttl := message.deadline.Sub(start); ttl < time.Second || ttl > 3*time.Second {
+		t.Errorf("Expected the default 2s TTL, got %v", ttl)
+	}
+	if message.expire(time.Now()) {
+		t.Error("Expected a fresh message to be written")
+	}
+	if !message.expire(start.Add(3 * time.Second)) {
+		t.Error("Expected a stale message to be dropped")
+	}
+	if expired := hub.GetStats()["ttl_expired"]; expired != int64(1) {
+		t.Errorf("Expected ttl_expired 1, got %v", expired)
+	}
+
+	// ttl_ms overrides the default
+	hub.RouteMessage(robot, []byte(`{"type":"location_update","data":{"lat":37.5},"ttl_ms":100}`))
+	if ttl := next().deadline.Sub(start); ttl > time.Second {
+		t.Errorf("Expected the message's own 100ms TTL, got %v", ttl)
+	}
+
+	// Types without a TTL never expire
+	hub.RouteMessage(robot, []byte(`{"type":"route_update","data":{"points":[]}}`))
+	if message := next(); !message.deadline.IsZero() {
+		t.Errorf("Expected no deadline, got %v", message.deadline)
+	}
+}
@@ -0,0 +1,198 @@
+package wireguard
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// handshakeFreshness is how recent a peer handshake must be for the peer to
+// count as connected (WireGuard re-handshakes at least every 2 minutes)
+const handshakeFreshness = 3 * time.Minute
+
+// PeerStatus describes one WireGuard peer
+type PeerStatus struct {
+	PublicKey     string     `json:"public_key"`
+	Endpoint      string     `json:"endpoint,omitempty"`
+	AllowedIPs    []string   `json:"allowed_ips"`
+	LastHandshake *time.Time `json:"last_handshake,omitempty"`
+	Connected     bool       `json:"connected"`
+	ReceiveBytes  int64      `json:"rx_bytes"`
+	TransmitBytes int64      `json:"tx_bytes"`
+}
+
+// Status describes the WireGuard tunnel as reported in /health
+type Status struct {
+	Interface      string       `json:"interface"`
+	Up             bool         `json:"up"`
+	Error          string       `json:"error,omitempty"`
+	Peers          []PeerStatus `json:"peers"`
+	ConnectedPeers int          `json:"connected_peers"`
+	CheckedAt      time.Time    `json:"checked_at"`
+}
+
+// Monitor tracks a WireGuard interface, its peers' allowed IPs and tunnel status
+type Monitor struct {
+	iface  string
+	client *wgctrl.Client
+
+	allowed []net.IPNet
+	status  Status
+	mu      sync.RWMutex
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewMonitor opens the WireGuard control interface and reads the device once
+func NewMonitor(iface string) (*Monitor, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WireGuard control: %v", err)
+	}
+
+	m := &Monitor{
+		iface:  iface,
+		client: client,
+		stop:   make(chan struct{}),
+	}
+
+	if err := m.Refresh(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// ListenAddr returns host:port bound to the interface's first address
+func (m *Monitor) ListenAddr(port string) (string, error) {
+	iface, err := net.InterfaceByName(m.iface)
+	if err != nil {
+		return "", err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			return net.JoinHostPort(ipNet.IP.String(), port), nil
+		}
+	}
+
+	return "", fmt.Errorf("interface %s has no address", m.iface)
+}
+
+// Refresh reads the device and updates peer allowed IPs and status. The
+// previous allowed IPs are kept when the device cannot be read.
+func (m *Monitor) Refresh() error {
+	device, err := m.client.Device(m.iface)
+	if err != nil {
+		m.mu.Lock()
+		m.status.Interface = m.iface
+		m.status.Up = false
+		m.status.Error = err.Error()
+		m.status.CheckedAt = time.Now()
+		m.mu.Unlock()
+		return fmt.Errorf("failed to read WireGuard interface %s: %v", m.iface, err)
+	}
+
+	m.update(device.Peers, time.Now())
+	return nil
+}
+
+// update replaces the peer state from a device snapshot
+func (m *Monitor) update(peers []wgtypes.Peer, now time.Time) {
+	status := Status{
+		Interface: m.iface,
+		Up:        true,
+		Peers:     make([]PeerStatus, 0, len(peers)),
+		CheckedAt: now,
+	}
+
+	var allowed []net.IPNet
+	for _, peer := range peers {
+		peerStatus := PeerStatus{
+			PublicKey:     peer.PublicKey.String(),
+			AllowedIPs:    make([]string, 0, len(peer.AllowedIPs)),
+			ReceiveBytes:  peer.ReceiveBytes,
+			TransmitBytes: peer.TransmitBytes,
+		}
+		if peer.Endpoint != nil {
+			peerStatus.Endpoint = peer.Endpoint.String()
+		}
+		if !peer.LastHandshakeTime.IsZero() {
+			handshake := peer.LastHandshakeTime
+			peerStatus.LastHandshake = &handshake
+			peerStatus.Connected = now.Sub(handshake) < handshakeFreshness
+		}
+		if peerStatus.Connected {
+			status.ConnectedPeers++
+		}
+
+		for _, ipNet := range peer.AllowedIPs {
+			peerStatus.AllowedIPs = append(peerStatus.AllowedIPs, ipNet.String())
+			allowed = append(allowed, ipNet)
+		}
+
+		status.Peers = append(status.Peers, peerStatus)
+	}
+
+	m.mu.Lock()
+	m.allowed = allowed
+	m.status = status
+	m.mu.Unlock()
+}
+
+// Allowed reports whether ip falls within any peer's allowed IPs
+func (m *Monitor) Allowed(ip net.IP) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, ipNet := range m.allowed {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the latest tunnel status
+func (m *Monitor) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Run refreshes the device periodically until Stop is called
+func (m *Monitor) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			if err := m.Refresh(); err != nil {
+				log.Printf("⚠️  %v", err)
+			}
+		}
+	}
+}
+
+// Stop ends the refresh loop and closes the control client
+func (m *Monitor) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+		m.client.Close()
+	})
+}
@@ -0,0 +1,73 @@
+package wireguard
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// mustCIDR parses a CIDR for tests
+func mustCIDR(t *testing.T, cidr string) net.IPNet {
+	t.Helper()
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("Invalid CIDR %s: %v", cidr, err)
+	}
+	return *ipNet
+}
+
+// TestMonitorUpdate tests allowed IP checks and peer status from a device snapshot
+func TestMonitorUpdate(t *testing.T) {
+	m := &Monitor{iface: "wg0"}
+	now := time.Now()
+
+	m.update([]wgtypes.Peer{
+		{
+			AllowedIPs:        []net.IPNet{mustCIDR(t, "10.8.0.2/32")},
+			LastHandshakeTime: now.Add(-30 * time.Second),
+			Endpoint:          &net.UDPAddr{IP: net.ParseIP("198.51.100.4"), Port: 51820},
+		},
+		{
+			AllowedIPs:        []net.IPNet{mustCIDR(t, "10.8.1.0/24")},
+			LastHandshakeTime: now.Add(-10 * time.Minute),
+		},
+		{
+			AllowedIPs: []net.IPNet{mustCIDR(t, "10.8.2.0/24")},
+		},
+	}, now)
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.8.0.2", true},
+		{"10.8.0.3", false},
+		{"10.8.1.77", true},
+		{"10.8.2.1", true},
+		{"192.168.1.10", false},
+	}
+	for _, tt := range tests {
+		if got := m.Allowed(net.ParseIP(tt.ip)); got != tt.expected {
+			t.Errorf("Allowed(%s) = %v, expected %v", tt.ip, got, tt.expected)
+		}
+	}
+
+	status := m.Status()
+	if !status.Up || status.Interface != "wg0" {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if len(status.Peers) != 3 {
+		t.Fatalf("Expected 3 peers, got %d", len(status.Peers))
+	}
+	if status.ConnectedPeers != 1 {
+		t.Errorf("Expected 1 connected peer, got %d", status.ConnectedPeers)
+	}
+	if status.Peers[0].Endpoint != "198.51.100.4:51820" {
+		t.Errorf("Unexpected endpoint: %s", status.Peers[0].Endpoint)
+	}
+	if status.Peers[2].LastHandshake != nil {
+		t.Error("Expected no handshake for idle peer")
+	}
+}